@@ -10,7 +10,6 @@ import (
 	"fmt"
 	"math"
 	"math/big"
-	"os"
 
 	"github.com/consensys/gnark/constraint/solver"
 	"github.com/consensys/gnark/frontend"
@@ -20,12 +19,18 @@ import (
 var modulus = new(big.Int).SetUint64(2013265921)
 var modulus_sub_1 = new(big.Int).SetUint64(2013265920)
 
+// two_32 bounds the felts of a witness. Upper bounds are never modified, so every
+// witness felt shares it instead of allocating its own.
+var two_32 = new(big.Int).Lsh(big.NewInt(1), 32)
+
 func init() {
 	// These functions must be public so Gnark's hint system can access them.
-	solver.RegisterHint(InvFHint)
-	solver.RegisterHint(InvEHint)
-	solver.RegisterHint(ReduceHint)
-	solver.RegisterHint(SplitLimbsHint)
+	solver.RegisterHint(Hints()...)
+}
+
+// Hints returns the solver hints of the field chip.
+func Hints() []solver.Hint {
+	return []solver.Hint{InvFHint, InvEHint, ReduceHint, SplitLimbsHint}
 }
 
 type Variable struct {
@@ -40,13 +45,34 @@ type ExtensionVariable struct {
 type Chip struct {
 	api          frontend.API
 	RangeChecker frontend.Rangechecker
+
+	// lookup selects RangeChecker over bit decomposition, see RangeCheck.
+	lookup bool
 }
 
-func NewChip(api frontend.API) *Chip {
-	return &Chip{
+// Option configures a Chip. Every option changes the circuit, so a circuit built with
+// other options needs its own keys.
+type Option func(*Chip)
+
+// WithLookupRangeCheck range checks with gnark's commitment based (LogUp) range checker
+// instead of bit decomposition, see RangeCheck.
+func WithLookupRangeCheck() Option {
+	return func(c *Chip) {
+		c.lookup = true
+	}
+}
+
+// NewChip returns a babybear chip. Without options it range checks by bit
+// decomposition.
+func NewChip(api frontend.API, opts ...Option) *Chip {
+	c := &Chip{
 		api:          api,
 		RangeChecker: rangecheck.New(api),
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 func Zero() Variable {
@@ -77,7 +103,7 @@ func NewFConst(value string) Variable {
 func NewF(value string) Variable {
 	return Variable{
 		Value:      frontend.Variable(value),
-		UpperBound: new(big.Int).SetUint64(uint64(math.Pow(2, 32))),
+		UpperBound: two_32,
 	}
 }
 
@@ -101,6 +127,18 @@ func Felts2Ext(a, b, c, d Variable) ExtensionVariable {
 	return ExtensionVariable{Value: [4]Variable{a, b, c, d}}
 }
 
+// RangeCheck asserts that v fits in bits. For Groth16 it decomposes v into bits by
+// default, which keeps the proof free of commitments. WithLookupRangeCheck switches to
+// gnark's commitment based (LogUp) range checker, which is much cheaper across the
+// thousands of reductions of a proof but adds a commitment to the proof.
+func (c *Chip) RangeCheck(v frontend.Variable, bits int) {
+	if c.lookup {
+		c.RangeChecker.Check(v, bits)
+	} else {
+		c.api.ToBinary(v, bits)
+	}
+}
+
 func (c *Chip) AddF(a, b Variable, forceReduce ...bool) Variable {
 	result := Variable{
 		Value:      c.api.Add(a.Value, b.Value),
@@ -160,11 +198,7 @@ func (c *Chip) invF(in Variable) Variable {
 		Value:      result[0],
 		UpperBound: new(big.Int).SetUint64(2147483648),
 	}
-	if os.Getenv("GROTH16") != "1" {
-		c.RangeChecker.Check(result[0], 31)
-	} else {
-		c.api.ToBinary(result[0], 31)
-	}
+	c.RangeCheck(result[0], 31)
 	product := c.MulF(in, xinv)
 	c.AssertIsEqualF(product, NewFConst("1"))
 
@@ -287,17 +321,10 @@ func (c *Chip) InvE(in ExtensionVariable) ExtensionVariable {
 	yinv := Variable{Value: result[1], UpperBound: new(big.Int).SetUint64(2147483648)}
 	zinv := Variable{Value: result[2], UpperBound: new(big.Int).SetUint64(2147483648)}
 	linv := Variable{Value: result[3], UpperBound: new(big.Int).SetUint64(2147483648)}
-	if os.Getenv("GROTH16") != "1" {
-		c.RangeChecker.Check(result[0], 31)
-		c.RangeChecker.Check(result[1], 31)
-		c.RangeChecker.Check(result[2], 31)
-		c.RangeChecker.Check(result[3], 31)
-	} else {
-		c.api.ToBinary(result[0], 31)
-		c.api.ToBinary(result[1], 31)
-		c.api.ToBinary(result[2], 31)
-		c.api.ToBinary(result[3], 31)
-	}
+	c.RangeCheck(result[0], 31)
+	c.RangeCheck(result[1], 31)
+	c.RangeCheck(result[2], 31)
+	c.RangeCheck(result[3], 31)
 	out := ExtensionVariable{Value: [4]Variable{xinv, yinv, zinv, linv}}
 
 	product := c.MulE(in, out)
@@ -364,11 +391,7 @@ func (p *Chip) reduceWithMaxBits(x frontend.Variable, maxNbBits uint64) frontend
 	quotient := result[0]
 	remainder := result[1]
 
-	if os.Getenv("GROTH16") != "1" {
-		p.RangeChecker.Check(quotient, int(maxNbBits-30))
-	} else {
-		p.api.ToBinary(quotient, int(maxNbBits-30))
-	}
+	p.RangeCheck(quotient, int(maxNbBits-30))
 
 	// Check that the remainder has size less than the BabyBear modulus, by decomposing it into a 27
 	// bit limb and a 4 bit limb.
@@ -388,13 +411,8 @@ func (p *Chip) reduceWithMaxBits(x frontend.Variable, maxNbBits uint64) frontend
 		),
 		remainder,
 	)
-	if os.Getenv("GROTH16") != "1" {
-		p.RangeChecker.Check(highLimb, 4)
-		p.RangeChecker.Check(lowLimb, 27)
-	} else {
-		p.api.ToBinary(highLimb, 4)
-		p.api.ToBinary(lowLimb, 27)
-	}
+	p.RangeCheck(highLimb, 4)
+	p.RangeCheck(lowLimb, 27)
 
 	// If the most significant bits are all 1, then we need to check that the least significant bits
 	// are all zero in order for element to be less than the BabyBear modulus. Otherwise, we don't
@@ -1,238 +1,140 @@
 package babybear_verifier
 
 import (
-	"encoding/json"
-	"fmt"
 	"github.com/brevis-network/pico/gnark/babybear"
+	"github.com/brevis-network/pico/gnark/challenger"
 	"github.com/brevis-network/pico/gnark/poseidon2"
 	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/brevis-network/pico/gnark/verifier_core"
 	"github.com/consensys/gnark/frontend"
-	"os"
-	"strconv"
 )
 
-type Circuit struct {
-	VkeyHash              frontend.Variable `gnark:",public"`
-	CommittedValuesDigest frontend.Variable `gnark:",public"`
-	Vars                  []frontend.Variable
-	Felts                 []babybear.Variable
-	Exts                  []babybear.ExtensionVariable
+type (
+	Circuit          = verifier_core.Circuit[babybear.Variable, babybear.ExtensionVariable]
+	Chunk            = verifier_core.Chunk[babybear.Variable, babybear.ExtensionVariable]
+	MultiCircuit     = verifier_core.MultiCircuit[babybear.Variable, babybear.ExtensionVariable]
+	AggregateCircuit = verifier_core.AggregateCircuit[babybear.Variable, babybear.ExtensionVariable]
+	LayoutCircuit    = verifier_core.LayoutCircuit[babybear.Variable, babybear.ExtensionVariable]
+	EmbeddedProof    = verifier_core.EmbeddedProof[babybear.Variable, babybear.ExtensionVariable]
+)
+
+// NewCircuit returns the BabyBear wrapper of one chunk proof, see verifier_core.Circuit.
+func NewCircuit(witnessInput utils.WitnessInput, opts verifier_core.Options) *Circuit {
+	return verifier_core.NewCircuit[babybear.Variable, babybear.ExtensionVariable](witnessInput, fieldBuilder{}, opts)
 }
 
-func NewCircuit(witnessInput utils.WitnessInput) *Circuit {
-	vars := make([]frontend.Variable, len(witnessInput.Vars))
-	felts := make([]babybear.Variable, len(witnessInput.Felts))
-	exts := make([]babybear.ExtensionVariable, len(witnessInput.Exts))
-	for i := 0; i < len(witnessInput.Vars); i++ {
-		vars[i] = frontend.Variable(witnessInput.Vars[i])
-	}
-	for i := 0; i < len(witnessInput.Felts); i++ {
-		felts[i] = babybear.NewF(witnessInput.Felts[i])
+func NewChunk(witnessInput utils.WitnessInput) Chunk {
+	return verifier_core.NewChunk[babybear.Variable, babybear.ExtensionVariable](witnessInput, fieldBuilder{})
+}
+
+// NewMultiCircuit wraps several BabyBear chunk proofs, see verifier_core.MultiCircuit.
+func NewMultiCircuit(witnessInput utils.MultiWitnessInput, opts verifier_core.Options) (*MultiCircuit, error) {
+	return verifier_core.NewMultiCircuit[babybear.Variable, babybear.ExtensionVariable](witnessInput, fieldBuilder{}, opts)
+}
+
+// NewAggregateCircuit wraps BabyBear chunk proofs of several programs, see
+// verifier_core.AggregateCircuit.
+func NewAggregateCircuit(witnessInput utils.MultiWitnessInput, opts verifier_core.Options) (*AggregateCircuit, error) {
+	return verifier_core.NewAggregateCircuit[babybear.Variable, babybear.ExtensionVariable](witnessInput, fieldBuilder{}, opts)
+}
+
+// NewLayoutCircuit wraps one chunk proof with a public input layout other than the
+// default, see verifier_core.LayoutCircuit.
+func NewLayoutCircuit(witnessInput utils.WitnessInput, layout utils.PublicLayout, opts verifier_core.Options) (*LayoutCircuit, error) {
+	return verifier_core.NewLayoutCircuit[babybear.Variable, babybear.ExtensionVariable](witnessInput, layout, fieldBuilder{}, opts)
+}
+
+// NewEmbeddedProof assigns a BabyBear Pico proof verified inside an application's own
+// circuit, whose public values Verify also constrains with statements, see
+// verifier_core.EmbeddedProof.
+func NewEmbeddedProof(witnessInput utils.WitnessInput, opts verifier_core.Options, statements ...verifier_core.Statement) *EmbeddedProof {
+	return verifier_core.NewEmbeddedProof[babybear.Variable, babybear.ExtensionVariable](witnessInput, fieldBuilder{}, opts, statements...)
+}
+
+// VerifyPicoProof verifies proof in the circuit being defined and returns the vkey
+// hash and committed values digest it commits to.
+func VerifyPicoProof(api frontend.API, proof *EmbeddedProof) (verifier_core.PublicValues, error) {
+	return proof.Verify(api)
+}
+
+// NewChallenger returns the BabyBear DuplexChallenger of the Rust prover built on the
+// chips of the verifier circuit with opts, see koalabear_verifier.NewChallenger.
+func NewChallenger(api frontend.API, opts verifier_core.Options) *challenger.Challenger {
+	chip, hash := newChips(api, opts)
+	return challenger.New(api, poseidon2.NewBabyBearFieldChip(chip, hash))
+}
+
+// fieldBuilder is the BabyBear verifier_core.FieldBuilder.
+type fieldBuilder struct{}
+
+func (fieldBuilder) NewField(api frontend.API, opts verifier_core.Options) verifier_core.Field[babybear.Variable, babybear.ExtensionVariable] {
+	chip, hash := newChips(api, opts)
+	return field{Chip: chip, hash: hash}
+}
+
+// newChips returns the field and Poseidon2 chips of the verifier circuit with opts.
+func newChips(api frontend.API, opts verifier_core.Options) (*babybear.Chip, *poseidon2.Poseidon2BabyBearChip) {
+	var chipOpts []babybear.Option
+	if opts.LookupRangeCheck {
+		chipOpts = append(chipOpts, babybear.WithLookupRangeCheck())
 	}
-	for i := 0; i < len(witnessInput.Exts); i++ {
-		exts[i] = babybear.NewE(witnessInput.Exts[i])
+	hashBabyBearAPI := poseidon2.NewBabyBearChip(api, chipOpts...)
+	if opts.Poseidon2CrossCheck {
+		hashBabyBearAPI.EnableCrossCheck()
 	}
-	return &Circuit{
-		VkeyHash:              witnessInput.VkeyHash,
-		CommittedValuesDigest: witnessInput.CommittedValuesDigest,
-		Vars:                  vars,
-		Felts:                 felts,
-		Exts:                  exts,
+	if opts.Poseidon2ConstantFolding {
+		hashBabyBearAPI.EnableConstantFolding()
 	}
+	return babybear.NewChip(api, chipOpts...), hashBabyBearAPI
 }
 
-type Constraint struct {
-	Opcode string     `json:"opcode"`
-	Args   [][]string `json:"args"`
+func (fieldBuilder) NewF(value string) babybear.Variable {
+	return babybear.NewF(value)
 }
 
-type Proof struct {
-	PublicInputs [2]string `json:"public_inputs"`
-	EncodedProof string    `json:"encoded_proof"`
-	RawProof     string    `json:"raw_proof"`
+func (fieldBuilder) NewE(value []string) babybear.ExtensionVariable {
+	return babybear.NewE(value)
 }
 
-func (circuit *Circuit) Define(api frontend.API) error {
-	// Get the file name from an environment variable.
-	fileName := os.Getenv("CONSTRAINTS_JSON")
-	if fileName == "" {
-		fileName = "constraints.json"
-	}
+// field is the BabyBear verifier_core.Field.
+type field struct {
+	*babybear.Chip
+	hash *poseidon2.Poseidon2BabyBearChip
+}
 
-	// Read the file.
-	data, err := os.ReadFile(fileName)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
-	}
+func (field) NewF(value string) babybear.Variable {
+	return babybear.NewF(value)
+}
 
-	// Deserialize the JSON data into a slice of Instruction structs.
-	var constraints []Constraint
-	err = json.Unmarshal(data, &constraints)
-	if err != nil {
-		return fmt.Errorf("error deserializing JSON: %v", err)
-	}
+func (field) NewE(value []string) babybear.ExtensionVariable {
+	return babybear.NewE(value)
+}
 
-	hashAPI := poseidon2.NewChip(api)
-	hashBabyBearAPI := poseidon2.NewBabyBearChip(api)
-	fieldAPI := babybear.NewChip(api)
-	vars := make(map[string]frontend.Variable)
-	felts := make(map[string]babybear.Variable)
-	exts := make(map[string]babybear.ExtensionVariable)
-
-	// Iterate through the witnesses and range check them, if necessary.
-	for i := 0; i < len(circuit.Felts); i++ {
-		if os.Getenv("GROTH16") != "1" {
-			fieldAPI.RangeChecker.Check(circuit.Felts[i].Value, 31)
-		} else {
-			api.ToBinary(circuit.Felts[i].Value, 31)
-		}
-	}
-	for i := 0; i < len(circuit.Exts); i++ {
-		for j := 0; j < 4; j++ {
-			if os.Getenv("GROTH16") != "1" {
-				fieldAPI.RangeChecker.Check(circuit.Exts[i].Value[j].Value, 31)
-			} else {
-				api.ToBinary(circuit.Exts[i].Value[j].Value, 31)
-			}
-		}
-	}
+func (field) Felts2Ext(a, b, c, d babybear.Variable) babybear.ExtensionVariable {
+	return babybear.Felts2Ext(a, b, c, d)
+}
 
-	// Iterate through the instructions and handle each opcode.
-	for _, cs := range constraints {
-		switch cs.Opcode {
-		case "ImmV":
-			vars[cs.Args[0][0]] = frontend.Variable(cs.Args[1][0])
-		case "ImmF":
-			felts[cs.Args[0][0]] = babybear.NewF(cs.Args[1][0])
-		case "ImmE":
-			exts[cs.Args[0][0]] = babybear.NewE(cs.Args[1])
-		case "AddV":
-			vars[cs.Args[0][0]] = api.Add(vars[cs.Args[1][0]], vars[cs.Args[2][0]])
-		case "AddF":
-			felts[cs.Args[0][0]] = fieldAPI.AddF(felts[cs.Args[1][0]], felts[cs.Args[2][0]])
-		case "AddE":
-			exts[cs.Args[0][0]] = fieldAPI.AddE(exts[cs.Args[1][0]], exts[cs.Args[2][0]])
-		case "AddEF":
-			exts[cs.Args[0][0]] = fieldAPI.AddEF(exts[cs.Args[1][0]], felts[cs.Args[2][0]])
-		case "SubV":
-			vars[cs.Args[0][0]] = api.Sub(vars[cs.Args[1][0]], vars[cs.Args[2][0]])
-		case "SubF":
-			felts[cs.Args[0][0]] = fieldAPI.SubF(felts[cs.Args[1][0]], felts[cs.Args[2][0]])
-		case "DivF":
-			felts[cs.Args[0][0]] = fieldAPI.DivF(felts[cs.Args[1][0]], felts[cs.Args[2][0]])
-		case "SubE":
-			exts[cs.Args[0][0]] = fieldAPI.SubE(exts[cs.Args[1][0]], exts[cs.Args[2][0]])
-		case "SubEF":
-			exts[cs.Args[0][0]] = fieldAPI.SubEF(exts[cs.Args[1][0]], felts[cs.Args[2][0]])
-		case "MulV":
-			vars[cs.Args[0][0]] = api.Mul(vars[cs.Args[1][0]], vars[cs.Args[2][0]])
-		case "MulF":
-			felts[cs.Args[0][0]] = fieldAPI.MulF(felts[cs.Args[1][0]], felts[cs.Args[2][0]])
-		case "MulE":
-			exts[cs.Args[0][0]] = fieldAPI.MulE(exts[cs.Args[1][0]], exts[cs.Args[2][0]])
-		case "MulEF":
-			exts[cs.Args[0][0]] = fieldAPI.MulEF(exts[cs.Args[1][0]], felts[cs.Args[2][0]])
-		case "DivE":
-			exts[cs.Args[0][0]] = fieldAPI.DivE(exts[cs.Args[1][0]], exts[cs.Args[2][0]])
-		case "DivEF":
-			exts[cs.Args[0][0]] = fieldAPI.DivEF(exts[cs.Args[1][0]], felts[cs.Args[2][0]])
-		case "NegE":
-			exts[cs.Args[0][0]] = fieldAPI.NegE(exts[cs.Args[1][0]])
-		case "InvE":
-			exts[cs.Args[0][0]] = fieldAPI.InvE(exts[cs.Args[1][0]])
-		case "Num2BitsV":
-			numBits, err := strconv.Atoi(cs.Args[2][0])
-			if err != nil {
-				return fmt.Errorf("error converting number of bits to int: %v", err)
-			}
-			bits := api.ToBinary(vars[cs.Args[1][0]], numBits)
-			for i := 0; i < len(cs.Args[0]); i++ {
-				vars[cs.Args[0][i]] = bits[i]
-			}
-		case "Num2BitsF":
-			bits := fieldAPI.ToBinary(felts[cs.Args[1][0]])
-			for i := 0; i < len(cs.Args[0]); i++ {
-				vars[cs.Args[0][i]] = bits[i]
-			}
-		case "Permute":
-			state := [3]frontend.Variable{vars[cs.Args[0][0]], vars[cs.Args[1][0]], vars[cs.Args[2][0]]}
-			hashAPI.PermuteMut(&state)
-			vars[cs.Args[0][0]] = state[0]
-			vars[cs.Args[1][0]] = state[1]
-			vars[cs.Args[2][0]] = state[2]
-		case "PermuteBabyBear":
-			var state [16]babybear.Variable
-			for i := 0; i < 16; i++ {
-				state[i] = felts[cs.Args[i][0]]
-			}
-			hashBabyBearAPI.PermuteMut(&state)
-			for i := 0; i < 16; i++ {
-				felts[cs.Args[i][0]] = state[i]
-			}
-		case "SelectV":
-			vars[cs.Args[0][0]] = api.Select(vars[cs.Args[1][0]], vars[cs.Args[2][0]], vars[cs.Args[3][0]])
-		case "SelectF":
-			felts[cs.Args[0][0]] = fieldAPI.SelectF(vars[cs.Args[1][0]], felts[cs.Args[2][0]], felts[cs.Args[3][0]])
-		case "SelectE":
-			exts[cs.Args[0][0]] = fieldAPI.SelectE(vars[cs.Args[1][0]], exts[cs.Args[2][0]], exts[cs.Args[3][0]])
-		case "Ext2Felt":
-			out := fieldAPI.Ext2Felt(exts[cs.Args[4][0]])
-			for i := 0; i < 4; i++ {
-				felts[cs.Args[i][0]] = out[i]
-			}
-		case "AssertEqV":
-			api.AssertIsEqual(vars[cs.Args[0][0]], vars[cs.Args[1][0]])
-		case "AssertEqF":
-			fieldAPI.AssertIsEqualF(felts[cs.Args[0][0]], felts[cs.Args[1][0]])
-		case "AssertNeF":
-			fieldAPI.AssertNotEqualF(felts[cs.Args[0][0]], felts[cs.Args[1][0]])
-		case "AssertEqE":
-			fieldAPI.AssertIsEqualE(exts[cs.Args[0][0]], exts[cs.Args[1][0]])
-		case "PrintV":
-			api.Println(vars[cs.Args[0][0]])
-		case "PrintF":
-			f := fieldAPI.ReduceSlow(felts[cs.Args[0][0]])
-			api.Println(f.Value)
-		case "PrintE":
-			e := fieldAPI.ReduceE(exts[cs.Args[0][0]])
-			api.Println(e.Value[0].Value)
-			api.Println(e.Value[1].Value)
-			api.Println(e.Value[2].Value)
-			api.Println(e.Value[3].Value)
-		case "WitnessV":
-			i, err := strconv.Atoi(cs.Args[1][0])
-			if err != nil {
-				panic(err)
-			}
-			vars[cs.Args[0][0]] = circuit.Vars[i]
-		case "WitnessF":
-			i, err := strconv.Atoi(cs.Args[1][0])
-			if err != nil {
-				panic(err)
-			}
-			felts[cs.Args[0][0]] = circuit.Felts[i]
-		case "WitnessE":
-			i, err := strconv.Atoi(cs.Args[1][0])
-			if err != nil {
-				panic(err)
-			}
-			exts[cs.Args[0][0]] = circuit.Exts[i]
-		case "CommitVkeyHash":
-			element := vars[cs.Args[0][0]]
-			api.AssertIsEqual(circuit.VkeyHash, element)
-		case "CommitCommitedValuesDigest":
-			element := vars[cs.Args[0][0]]
-			api.AssertIsEqual(circuit.CommittedValuesDigest, element)
-		case "CircuitFelts2Ext":
-			exts[cs.Args[0][0]] = babybear.Felts2Ext(felts[cs.Args[1][0]], felts[cs.Args[2][0]], felts[cs.Args[3][0]], felts[cs.Args[4][0]])
-		case "CircuitFelt2Var":
-			vars[cs.Args[0][0]] = fieldAPI.ReduceSlow(felts[cs.Args[1][0]]).Value
-		case "ReduceE":
-			exts[cs.Args[0][0]] = fieldAPI.ReduceE(exts[cs.Args[0][0]])
-		default:
-			return fmt.Errorf("unhandled opcode: %s", cs.Opcode)
-		}
-	}
+func (field) FeltValue(f babybear.Variable) frontend.Variable {
+	return f.Value
+}
+
+func (field) ExtValues(e babybear.ExtensionVariable) [4]frontend.Variable {
+	return [4]frontend.Variable{e.Value[0].Value, e.Value[1].Value, e.Value[2].Value, e.Value[3].Value}
+}
+
+func (field) PermuteOpcode() string {
+	return "PermuteBabyBear"
+}
+
+func (f field) Permute(state *[16]babybear.Variable) {
+	f.hash.PermuteMut(state)
+}
 
-	return nil
+// NativeField is the BabyBear verifier_core.NativeField, checking witnesses outside
+// the circuit.
+var NativeField = verifier_core.NativeField{
+	Modulus:       2013265921,
+	W:             11,
+	PermuteOpcode: field{}.PermuteOpcode(),
+	Permute:       poseidon2.PermuteBabyBear,
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/brevis-network/pico/gnark/verifier_core"
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/backend"
 	"github.com/consensys/gnark/backend/groth16"
@@ -15,6 +16,8 @@ import (
 	"github.com/rs/zerolog"
 	"golang.org/x/crypto/sha3"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -50,8 +53,8 @@ func doSolve(assert *test.Assert) (circuit *Circuit, assigment *Circuit) {
 	var inputs utils.WitnessInput
 	err = json.Unmarshal(data, &inputs)
 	assert.NoError(err)
-	assigment = NewCircuit(inputs)
-	circuit = NewCircuit(inputs)
+	assigment = NewCircuit(inputs, verifier_core.Options{})
+	circuit = NewCircuit(inputs, verifier_core.Options{})
 
 	err = test.IsSolved(circuit, assigment, ecc.BN254.ScalarField())
 	assert.NoError(err)
@@ -88,3 +91,81 @@ func doSetUp(assert *test.Assert, circuit *Circuit, assigment *Circuit) {
 	err = utils.WriteVerifyingKey("vm_vk", vk)
 	assert.NoError(err)
 }
+
+func TestFeltConstraints(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	constraints := `[
+		{"opcode": "WitnessF", "args": [["a"], ["0"]]},
+		{"opcode": "WitnessF", "args": [["b"], ["1"]]},
+		{"opcode": "MulF", "args": [["c"], ["a"], ["b"]]},
+		{"opcode": "CircuitFelt2Var", "args": [["vk"], ["c"]]},
+		{"opcode": "CommitVkeyHash", "args": [["vk"]]},
+		{"opcode": "WitnessV", "args": [["digest"], ["0"]]},
+		{"opcode": "CommitCommitedValuesDigest", "args": [["digest"]]}
+	]`
+	constraintsFile := filepath.Join(t.TempDir(), "constraints.json")
+	assert.NoError(os.WriteFile(constraintsFile, []byte(constraints), 0644))
+	t.Setenv("CONSTRAINTS_JSON", constraintsFile)
+
+	// 2013265920 * 2 is -2 in BabyBear
+	inputs := utils.WitnessInput{
+		Vars:                  []string{"9"},
+		Felts:                 []string{"2013265920", "2"},
+		VkeyHash:              "2013265919",
+		CommittedValuesDigest: "9",
+	}
+	assert.NoError(test.IsSolved(NewCircuit(inputs, verifier_core.Options{}), NewCircuit(inputs, verifier_core.Options{}), ecc.BN254.ScalarField()))
+
+	inputs.VkeyHash = "4"
+	assert.Error(test.IsSolved(NewCircuit(inputs, verifier_core.Options{}), NewCircuit(inputs, verifier_core.Options{}), ecc.BN254.ScalarField()))
+
+	// the KoalaBear permutation is not a BabyBear opcode
+	assert.NoError(os.WriteFile(constraintsFile, []byte(`[{"opcode": "PermuteKoalaBear", "args": [`+strings.Repeat(`["f"], `, 15)+`["f"]]}]`), 0644))
+	_, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, NewCircuit(inputs, verifier_core.Options{}))
+	assert.ErrorContains(err, "unhandled opcode: PermuteKoalaBear")
+
+	assert.NoError(os.WriteFile(constraintsFile, []byte(`[{"opcode": "WitnessF", "args": [["f"], ["5"]]}]`), 0644))
+	_, err = frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, NewCircuit(inputs, verifier_core.Options{}))
+	assert.ErrorContains(err, "witness index 5 out of range, the witness has 2 values")
+}
+
+func TestNativeField(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	permute := make([]string, 16)
+	for i := range permute {
+		permute[i] = fmt.Sprintf(`["p%d"]`, i)
+	}
+	constraints := `[
+		{"opcode": "WitnessE", "args": [["x"], ["0"]]},
+		{"opcode": "WitnessE", "args": [["y"], ["1"]]},
+		{"opcode": "DivE", "args": [["d"], ["x"], ["y"]]},
+		{"opcode": "MulE", "args": [["m"], ["d"], ["x"]]},
+		{"opcode": "Ext2Felt", "args": [["p0"], ["p1"], ["p2"], ["p3"], ["m"]]},
+		{"opcode": "Ext2Felt", "args": [["p4"], ["p5"], ["p6"], ["p7"], ["d"]]},
+		{"opcode": "Ext2Felt", "args": [["p8"], ["p9"], ["p10"], ["p11"], ["x"]]},
+		{"opcode": "Ext2Felt", "args": [["p12"], ["p13"], ["p14"], ["p15"], ["y"]]},
+		{"opcode": "PermuteBabyBear", "args": [` + strings.Join(permute, ", ") + `]},
+		{"opcode": "CircuitFelt2Var", "args": [["vk"], ["p0"]]},
+		{"opcode": "CommitVkeyHash", "args": [["vk"]]},
+		{"opcode": "CircuitFelt2Var", "args": [["digest"], ["p15"]]},
+		{"opcode": "CommitCommitedValuesDigest", "args": [["digest"]]}
+	]`
+	constraintsFile := filepath.Join(t.TempDir(), "constraints.json")
+	assert.NoError(os.WriteFile(constraintsFile, []byte(constraints), 0644))
+	t.Setenv("CONSTRAINTS_JSON", constraintsFile)
+	file, err := utils.ReadConstraints(constraintsFile)
+	assert.NoError(err)
+
+	inputs := utils.WitnessInput{Exts: [][]string{{"1", "2", "3", "4"}, {"7", "0", "11", "2013265920"}}}
+	commitments, err := NativeField.Evaluate(file, inputs)
+	assert.NoError(err)
+	inputs.VkeyHash = commitments.VkeyHash.String()
+	inputs.CommittedValuesDigest = commitments.CommittedValuesDigest.String()
+	assert.NoError(test.IsSolved(NewCircuit(inputs, verifier_core.Options{}), NewCircuit(inputs, verifier_core.Options{}), ecc.BN254.ScalarField()))
+
+	inputs.Exts[1] = []string{"0", "0", "0", "0"}
+	_, err = NativeField.Evaluate(file, inputs)
+	assert.ErrorContains(err, "constraint 2 (DivE): inverse of zero")
+}
@@ -0,0 +1,69 @@
+package challenger
+
+import (
+	"github.com/brevis-network/pico/gnark/poseidon2"
+	"github.com/consensys/gnark/frontend"
+)
+
+// ExtDegree is the degree of the extension field challenges are sampled from.
+const ExtDegree = 4
+
+// Challenger is the Fiat-Shamir transcript of the Rust prover, plonky3's
+// DuplexChallenger over the width 16 Poseidon2 permutation with rate 8, in circuit.
+// Observed values must be elements of the field; samples are canonical.
+type Challenger struct {
+	api    frontend.API
+	sponge *poseidon2.Sponge
+}
+
+// NewChallenger returns a challenger over field, "bb" (BabyBear) or "kb" (KoalaBear).
+func NewChallenger(api frontend.API, field string) (*Challenger, error) {
+	chip, err := poseidon2.NewFieldChip(api, field)
+	if err != nil {
+		return nil, err
+	}
+	return New(api, chip), nil
+}
+
+// New returns a challenger permuting with chip. The verifier packages build it on
+// the chips of their circuits, see koalabear_verifier.NewChallenger.
+func New(api frontend.API, chip poseidon2.FieldChip) *Challenger {
+	return &Challenger{api: api, sponge: poseidon2.NewSponge(chip)}
+}
+
+// Observe adds values to the transcript.
+func (c *Challenger) Observe(values ...frontend.Variable) {
+	c.sponge.Absorb(values...)
+}
+
+// ObserveExt adds the coefficients of an extension field element to the transcript.
+func (c *Challenger) ObserveExt(value [ExtDegree]frontend.Variable) {
+	c.sponge.Absorb(value[:]...)
+}
+
+// Sample returns a field element challenge.
+func (c *Challenger) Sample() frontend.Variable {
+	return c.sponge.Squeeze()
+}
+
+// SampleExt returns an extension field challenge, its coefficients sampled in order.
+func (c *Challenger) SampleExt() [ExtDegree]frontend.Variable {
+	var res [ExtDegree]frontend.Variable
+	for i := range res {
+		res[i] = c.Sample()
+	}
+	return res
+}
+
+// SampleBits returns the low bits of a field element challenge, for query indices.
+func (c *Challenger) SampleBits(bits int) frontend.Variable {
+	// samples are canonical, so their 31 bit decomposition is unique
+	return c.api.FromBinary(c.api.ToBinary(c.Sample(), 31)[:bits]...)
+}
+
+// CheckWitness observes the proof of work witness and asserts the next bits
+// challenge is zero.
+func (c *Challenger) CheckWitness(bits int, witness frontend.Variable) {
+	c.Observe(witness)
+	c.api.AssertIsEqual(c.SampleBits(bits), 0)
+}
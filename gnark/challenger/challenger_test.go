@@ -0,0 +1,88 @@
+package challenger
+
+import (
+	"encoding/json"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"os"
+	"testing"
+)
+
+type transcriptOp struct {
+	Op     string   `json:"op"`
+	Bits   int      `json:"bits"`
+	Values []uint64 `json:"values"`
+}
+
+// transcriptCircuit replays ops, its Values holding the values of all ops in order.
+type transcriptCircuit struct {
+	Values []frontend.Variable
+
+	ops []transcriptOp
+}
+
+func (circuit *transcriptCircuit) Define(api frontend.API) error {
+	c, err := NewChallenger(api, "kb")
+	if err != nil {
+		return err
+	}
+	values := circuit.Values
+	next := func() frontend.Variable {
+		v := values[0]
+		values = values[1:]
+		return v
+	}
+	for _, op := range circuit.ops {
+		switch op.Op {
+		case "observe":
+			for range op.Values {
+				c.Observe(next())
+			}
+		case "sample":
+			for range op.Values {
+				api.AssertIsEqual(c.Sample(), next())
+			}
+		case "sample_ext":
+			for _, v := range c.SampleExt() {
+				api.AssertIsEqual(v, next())
+			}
+		case "sample_bits":
+			api.AssertIsEqual(c.SampleBits(op.Bits), next())
+		case "check_witness":
+			c.CheckWitness(op.Bits, next())
+		}
+	}
+	return nil
+}
+
+// challengerVectors reads testdata/challenger_vectors.json, generated from plonky3's
+// DuplexChallenger by vm/examples/gen_challenger_vectors.rs.
+func challengerVectors(assert *test.Assert) [][]transcriptOp {
+	data, err := os.ReadFile("testdata/challenger_vectors.json")
+	assert.NoError(err)
+	var vectors [][]transcriptOp
+	assert.NoError(json.Unmarshal(data, &vectors))
+	return vectors
+}
+
+func TestChallengerVectors(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	for i, ops := range challengerVectors(assert) {
+		var values []frontend.Variable
+		for _, op := range ops {
+			for _, v := range op.Values {
+				values = append(values, v)
+			}
+		}
+		circuit := &transcriptCircuit{Values: make([]frontend.Variable, len(values)), ops: ops}
+		witness := &transcriptCircuit{Values: values, ops: ops}
+		assert.NoError(test.IsSolved(circuit, witness, ecc.BN254.ScalarField()), i)
+
+		// every vector ends with a sample, tampering with it breaks the transcript
+		witness.Values = append([]frontend.Variable{}, values...)
+		witness.Values[len(values)-1] = values[len(values)-1].(uint64) + 1
+		assert.Error(test.IsSolved(circuit, witness, ecc.BN254.ScalarField()), i)
+	}
+}
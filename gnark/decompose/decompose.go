@@ -0,0 +1,135 @@
+// Package decompose splits native variables into bytes, nibbles or limbs of any width,
+// range checked with gnark's commitment based (LogUp) range checker instead of one
+// boolean constraint per bit. Every decomposition of a circuit shares the lookup table
+// of the range checker, so the gadgets of a circuit should split through it rather
+// than api.ToBinary wherever the proof may carry a commitment.
+package decompose
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/rangecheck"
+)
+
+func init() {
+	solver.RegisterHint(Hints()...)
+}
+
+// Hints returns the solver hints of the decompositions.
+func Hints() []solver.Hint {
+	return []solver.Hint{SplitHint}
+}
+
+// Decomposer splits variables of the circuit of its api.
+type Decomposer struct {
+	api frontend.API
+	rc  frontend.Rangechecker
+}
+
+// New returns a Decomposer range checking with the range checker of api.
+func New(api frontend.API) *Decomposer {
+	return &Decomposer{api: api, rc: rangecheck.New(api)}
+}
+
+// Bytes splits v into n bytes, least significant first.
+func (d *Decomposer) Bytes(v frontend.Variable, n int) []frontend.Variable {
+	return d.Split(v, repeat(8, n)...)
+}
+
+// Nibbles splits v into n nibbles, least significant first.
+func (d *Decomposer) Nibbles(v frontend.Variable, n int) []frontend.Variable {
+	return d.Split(v, repeat(4, n)...)
+}
+
+// RangeCheck asserts that v fits in bits, as bytes and a last limb of the remaining
+// bits, so the checks of a circuit share the byte table.
+func (d *Decomposer) RangeCheck(v frontend.Variable, bits int) {
+	widths := repeat(8, bits/8)
+	if bits%8 != 0 {
+		widths = append(widths, bits%8)
+	}
+	d.Split(v, widths...)
+}
+
+// Split splits v into limbs of widths bits, least significant first, and asserts v is
+// their sum. The decomposition is unique: when the widths cover the field, the limbs
+// are also asserted to encode a value below the modulus, as api.ToBinary does.
+func (d *Decomposer) Split(v frontend.Variable, widths ...int) []frontend.Variable {
+	inputs := []frontend.Variable{v}
+	total := 0
+	for _, width := range widths {
+		if width <= 0 {
+			panic(fmt.Sprintf("invalid limb width %d", width))
+		}
+		inputs = append(inputs, width)
+		total += width
+	}
+	limbs, err := d.api.Compiler().NewHint(SplitHint, len(widths), inputs...)
+	if err != nil {
+		panic(err)
+	}
+
+	var sum frontend.Variable = 0
+	shift := 0
+	for i, limb := range limbs {
+		d.rc.Check(limb, widths[i])
+		sum = d.api.Add(sum, d.api.Mul(limb, new(big.Int).Lsh(big.NewInt(1), uint(shift))))
+		shift += widths[i]
+	}
+	d.api.AssertIsEqual(sum, v)
+	if total >= d.api.Compiler().FieldBitLen() {
+		d.assertBelowModulus(limbs, widths)
+	}
+	return limbs
+}
+
+// assertBelowModulus asserts that limbs of widths encode a value below the modulus,
+// comparing them with the limbs of the modulus from the most significant one: while
+// the limbs so far equal those of the modulus, the next one must not exceed its own,
+// and they can not all be equal.
+func (d *Decomposer) assertBelowModulus(limbs []frontend.Variable, widths []int) {
+	modulus := d.api.Compiler().Field()
+	bounds := make([]*big.Int, len(widths))
+	rest := new(big.Int).Set(modulus)
+	for i, width := range widths {
+		mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(width)), big.NewInt(1))
+		bounds[i] = new(big.Int).And(rest, mask)
+		rest.Rsh(rest, uint(width))
+	}
+
+	var equal frontend.Variable = 1
+	for i := len(limbs) - 1; i >= 0; i-- {
+		diff := d.api.Sub(bounds[i], limbs[i])
+		d.rc.Check(d.api.Mul(equal, diff), widths[i])
+		equal = d.api.Mul(equal, d.api.IsZero(diff))
+	}
+	d.api.AssertIsEqual(equal, 0)
+}
+
+// SplitHint splits inputs[0] into limbs of inputs[1:] bits, least significant first.
+func SplitHint(_ *big.Int, inputs []*big.Int, results []*big.Int) error {
+	if len(inputs) != len(results)+1 {
+		return fmt.Errorf("SplitHint expects a value and %d widths, got %d inputs", len(results), len(inputs))
+	}
+	rest := new(big.Int).Set(inputs[0])
+	for i, width := range inputs[1:] {
+		mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(width.Uint64())), big.NewInt(1))
+		results[i].And(rest, mask)
+		rest.Rsh(rest, uint(width.Uint64()))
+	}
+	if rest.Sign() != 0 {
+		return fmt.Errorf("value does not fit in its limbs")
+	}
+	return nil
+}
+
+func repeat(width, n int) []int {
+	widths := make([]int, n)
+	for i := range widths {
+		widths[i] = width
+	}
+	return widths
+}
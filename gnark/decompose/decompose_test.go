@@ -0,0 +1,81 @@
+package decompose
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+type splitCircuit struct {
+	V       frontend.Variable
+	Bytes   [4]frontend.Variable
+	Nibbles [8]frontend.Variable
+	Wide    frontend.Variable
+	Low     frontend.Variable
+}
+
+func (circuit *splitCircuit) Define(api frontend.API) error {
+	d := New(api)
+	for i, b := range d.Bytes(circuit.V, 4) {
+		api.AssertIsEqual(b, circuit.Bytes[i])
+	}
+	for i, n := range d.Nibbles(circuit.V, 8) {
+		api.AssertIsEqual(n, circuit.Nibbles[i])
+	}
+	d.RangeCheck(circuit.V, 31)
+	api.AssertIsEqual(d.Split(circuit.Wide, 100, 154)[0], circuit.Low)
+	return nil
+}
+
+func TestSplit(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	wide := new(big.Int).Sub(ecc.BN254.ScalarField(), big.NewInt(1))
+	low := new(big.Int).And(wide, new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 100), big.NewInt(1)))
+	assignment := &splitCircuit{
+		V:       0x7f0a0b0c,
+		Bytes:   [4]frontend.Variable{0x0c, 0x0b, 0x0a, 0x7f},
+		Nibbles: [8]frontend.Variable{0xc, 0x0, 0xb, 0x0, 0xa, 0x0, 0xf, 0x7},
+		Wide:    wide,
+		Low:     low,
+	}
+	assert.NoError(test.IsSolved(&splitCircuit{}, assignment, ecc.BN254.ScalarField()))
+
+	// 2^31 does not fit the range check
+	assignment.V, assignment.Bytes[3], assignment.Nibbles[6], assignment.Nibbles[7] = 0x800a0b0c, 0x80, 0x0, 0x8
+	assert.Error(test.IsSolved(&splitCircuit{}, assignment, ecc.BN254.ScalarField()))
+}
+
+// limbsCircuit checks limbs given in the witness, as a dishonest hint could set them.
+type limbsCircuit struct {
+	V     frontend.Variable
+	Limbs [2]frontend.Variable
+}
+
+func (circuit *limbsCircuit) Define(api frontend.API) error {
+	d := New(api)
+	widths := []int{128, 126}
+	api.AssertIsEqual(api.Add(circuit.Limbs[0], api.Mul(circuit.Limbs[1], new(big.Int).Lsh(big.NewInt(1), 128))), circuit.V)
+	for i, limb := range circuit.Limbs {
+		d.rc.Check(limb, widths[i])
+	}
+	d.assertBelowModulus(circuit.Limbs[:], widths)
+	return nil
+}
+
+func TestSplitIsCanonical(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	limbs := func(v *big.Int) [2]frontend.Variable {
+		mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+		return [2]frontend.Variable{new(big.Int).And(v, mask), new(big.Int).Rsh(v, 128)}
+	}
+	v := big.NewInt(5)
+	assert.NoError(test.IsSolved(&limbsCircuit{}, &limbsCircuit{V: v, Limbs: limbs(v)}, ecc.BN254.ScalarField()))
+	// v + r fits the limbs too and encodes the same field element
+	aliased := new(big.Int).Add(v, ecc.BN254.ScalarField())
+	assert.Error(test.IsSolved(&limbsCircuit{}, &limbsCircuit{V: v, Limbs: limbs(aliased)}, ecc.BN254.ScalarField()))
+}
@@ -0,0 +1,155 @@
+// Package evmcheck verifies proofs with the exported verifier contract on an
+// in-process go-ethereum simulated chain. It is kept out of the sdk so the prover and
+// the server do not link the simulated backend and its database.
+package evmcheck
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/onchain"
+	"github.com/brevis-network/pico/gnark/sdk"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// simulatedGasLimit is the block gas limit of the simulated chain of Check, that of
+// mainnet.
+const simulatedGasLimit = 30_000_000
+
+// Check deploys the verifier contract to a simulated go-ethereum chain and calls
+// verifyProof with the proof at PROOF_PATH, so a mismatch between the exported
+// contract and the proof encoding is caught before any real chain. The creation
+// bytecode is read from VERIFIER_BYTECODE_PATH, as hex, or else compiled from the
+// contract at SOLIDITY_PATH with the solc at SOLC_PATH.
+func Check(ctx context.Context) error {
+	bytecode, err := verifierBytecode(ctx)
+	if err != nil {
+		return err
+	}
+	calldata, err := sdk.ReadProofCalldata()
+	if err != nil {
+		return err
+	}
+	gas, err := simulateVerify(ctx, bytecode, calldata.Encode())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("simulated verifier contract accepts the proof, verifyProof gas %d\n", gas)
+	return nil
+}
+
+// verifierBytecode returns the creation bytecode of the verifier contract of Check.
+func verifierBytecode(ctx context.Context) ([]byte, error) {
+	if path := os.Getenv("VERIFIER_BYTECODE_PATH"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("fail to read verifier bytecode: %v", err)
+		}
+		bytecode, err := onchain.DecodeHex(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid verifier bytecode in %s: %v", path, err)
+		}
+		return bytecode, nil
+	}
+	return compileContract(ctx, os.Getenv("SOLC_PATH"), os.Getenv("SOLIDITY_PATH"), "Verifier")
+}
+
+// compileContract compiles the contract name of the file at path, such as Verifier of
+// the verifier exported by gnark, with solc.
+func compileContract(ctx context.Context, solc, path, name string) ([]byte, error) {
+	if solc == "" {
+		solc = "solc"
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, solc, "--optimize", "--combined-json", "bin", path)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("fail to compile %s with %s: %v %s", path, solc, err, strings.TrimSpace(stderr.String()))
+	}
+	var out struct {
+		Contracts map[string]struct {
+			Bin string `json:"bin"`
+		} `json:"contracts"`
+	}
+	err = json.Unmarshal(stdout.Bytes(), &out)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse solc output: %v", err)
+	}
+	for contractName, contract := range out.Contracts {
+		if strings.HasSuffix(contractName, ":"+name) {
+			return onchain.DecodeHex(contract.Bin)
+		}
+	}
+	return nil, fmt.Errorf("no contract %s in %s", name, path)
+}
+
+// simulateVerify deploys bytecode to a fresh simulated chain, calls it with calldata
+// and returns the gas of the call. A revert of the call is returned as
+// *onchain.RevertError.
+func simulateVerify(ctx context.Context, bytecode, calldata []byte) (uint64, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return 0, err
+	}
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	balance := new(big.Int).Lsh(big.NewInt(1), 100)
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{from: {Balance: balance}}, simulatedGasLimit)
+	defer backend.Close()
+
+	auth, err := bind.NewKeyedTransactorWithChainID(key, backend.Blockchain().Config().ChainID)
+	if err != nil {
+		return 0, err
+	}
+	auth.Context = ctx
+	address, tx, _, err := bind.DeployContract(auth, abi.ABI{}, bytecode, backend)
+	if err != nil {
+		return 0, fmt.Errorf("fail to deploy verifier contract: %v", err)
+	}
+	backend.Commit()
+	receipt, err := backend.TransactionReceipt(ctx, tx.Hash())
+	if err != nil {
+		return 0, fmt.Errorf("fail to get deployment receipt: %v", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return 0, fmt.Errorf("deployment of verifier contract reverted")
+	}
+	fmt.Printf("verifier contract deployed to the simulated chain, gas used %d\n", receipt.GasUsed)
+
+	msg := ethereum.CallMsg{From: from, To: &address, Data: calldata}
+	_, err = backend.CallContract(ctx, msg, nil)
+	if err != nil {
+		return 0, simulatedRevert(err)
+	}
+	gas, err := backend.EstimateGas(ctx, msg)
+	if err != nil {
+		return 0, simulatedRevert(err)
+	}
+	return gas, nil
+}
+
+// simulatedRevert converts a revert of the simulated chain to *onchain.RevertError,
+// which names the errors of the verifier contract.
+func simulatedRevert(err error) error {
+	revert, ok := err.(interface{ ErrorData() interface{} })
+	if !ok {
+		return fmt.Errorf("fail to call verifier contract: %v", err)
+	}
+	data, _ := revert.ErrorData().(string)
+	payload, decodeErr := onchain.DecodeHex(data)
+	if decodeErr != nil {
+		payload = nil
+	}
+	return fmt.Errorf("simulated verifier contract rejects the proof: %w", &onchain.RevertError{Message: "execution reverted", Data: payload})
+}
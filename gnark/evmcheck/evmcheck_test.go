@@ -0,0 +1,132 @@
+package evmcheck
+
+import (
+	"context"
+	"errors"
+	"github.com/brevis-network/pico/gnark/onchain"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+type cubicCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (c *cubicCircuit) Define(api frontend.API) error {
+	x3 := api.Mul(c.X, c.X, c.X)
+	api.AssertIsEqual(c.Y, api.Add(x3, c.X, 5))
+	return nil
+}
+
+// deployCode returns the creation bytecode of a contract whose code is runtime.
+func deployCode(runtime []byte) []byte {
+	n := byte(len(runtime))
+	// CODECOPY the runtime after these 12 bytes to memory, then RETURN it
+	return append([]byte{0x60, n, 0x60, 0x0c, 0x60, 0x00, 0x39, 0x60, n, 0x60, 0x00, 0xf3}, runtime...)
+}
+
+func TestSimulateVerify(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// STOP
+	_, err := simulateVerify(context.Background(), deployCode([]byte{0x00}), []byte{0x01})
+	assert.NoError(err)
+
+	// revert with the selector of ProofInvalid()
+	selector := onchain.Selector("ProofInvalid()")
+	runtime := append([]byte{0x63}, selector...)
+	runtime = append(runtime, 0x60, 0xe0, 0x1b, 0x60, 0x00, 0x52, 0x60, 0x04, 0x60, 0x00, 0xfd)
+	_, err = simulateVerify(context.Background(), deployCode(runtime), []byte{0x01})
+	var revert *onchain.RevertError
+	assert.True(errors.As(err, &revert))
+	assert.ErrorContains(err, "rejects the proof: execution reverted: ProofInvalid()")
+}
+
+func TestCompileContract(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	_, err := compileContract(context.Background(), filepath.Join(t.TempDir(), "solc"), "Groth16Verifier.sol", "Verifier")
+	assert.ErrorContains(err, "fail to compile Groth16Verifier.sol")
+
+	solc, err := exec.LookPath("solc")
+	if err != nil {
+		t.Skip("solc is not installed")
+	}
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &cubicCircuit{})
+	assert.NoError(err)
+	pk, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	fullWitness, err := frontend.NewWitness(&cubicCircuit{X: 2, Y: 15}, ecc.BN254.ScalarField())
+	assert.NoError(err)
+	pubWitness, err := fullWitness.Public()
+	assert.NoError(err)
+	proof, err := groth16.Prove(ccs, pk, fullWitness)
+	assert.NoError(err)
+	calldata, err := onchain.NewProofCalldata(proof, pubWitness)
+	assert.NoError(err)
+
+	path := filepath.Join(t.TempDir(), "Groth16Verifier.sol")
+	f, err := os.Create(path)
+	assert.NoError(err)
+	assert.NoError(vk.ExportSolidity(f))
+	assert.NoError(f.Close())
+	bytecode, err := compileContract(context.Background(), solc, path, "Verifier")
+	assert.NoError(err)
+	_, err = simulateVerify(context.Background(), bytecode, calldata.Encode())
+	assert.NoError(err)
+
+	calldata.Input[0].SetInt64(16)
+	_, err = simulateVerify(context.Background(), bytecode, calldata.Encode())
+	assert.ErrorContains(err, "ProofInvalid()")
+}
+
+func TestBatchVerifier(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	solc, err := exec.LookPath("solc")
+	if err != nil {
+		t.Skip("solc is not installed")
+	}
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &cubicCircuit{})
+	assert.NoError(err)
+	pk, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	calldatas := make([]*onchain.ProofCalldata, 3)
+	for i := range calldatas {
+		fullWitness, err := frontend.NewWitness(&cubicCircuit{X: 2, Y: 15}, ecc.BN254.ScalarField())
+		assert.NoError(err)
+		pubWitness, err := fullWitness.Public()
+		assert.NoError(err)
+		proof, err := groth16.Prove(ccs, pk, fullWitness)
+		assert.NoError(err)
+		calldatas[i], err = onchain.NewProofCalldata(proof, pubWitness)
+		assert.NoError(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "PicoBatchVerifier.sol")
+	f, err := os.Create(path)
+	assert.NoError(err)
+	assert.NoError(utils.WriteBatchVerifierSolidity(f, vk))
+	assert.NoError(f.Close())
+	bytecode, err := compileContract(context.Background(), solc, path, "PicoBatchVerifier")
+	assert.NoError(err)
+	data, err := onchain.EncodeBatch(calldatas)
+	assert.NoError(err)
+	_, err = simulateVerify(context.Background(), bytecode, data)
+	assert.NoError(err)
+
+	calldatas[2].Input[0].SetInt64(16)
+	data, err = onchain.EncodeBatch(calldatas)
+	assert.NoError(err)
+	_, err = simulateVerify(context.Background(), bytecode, data)
+	assert.ErrorContains(err, "ProofInvalid()")
+}
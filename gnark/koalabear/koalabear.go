@@ -10,8 +10,9 @@ import (
 	"fmt"
 	"math"
 	"math/big"
-	"os"
+	"math/bits"
 
+	"github.com/brevis-network/pico/gnark/decompose"
 	"github.com/consensys/gnark/constraint/solver"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/std/rangecheck"
@@ -20,12 +21,18 @@ import (
 var modulus = new(big.Int).SetUint64(2130706433)
 var modulus_sub_1 = new(big.Int).SetUint64(2130706432)
 
+// two_32 bounds the felts of a witness. Upper bounds are never modified, so every
+// witness felt shares it instead of allocating its own.
+var two_32 = new(big.Int).Lsh(big.NewInt(1), 32)
+
 func init() {
 	// These functions must be public so Gnark's hint system can access them.
-	solver.RegisterHint(InvFHint)
-	solver.RegisterHint(InvEHint)
-	solver.RegisterHint(ReduceHint)
-	solver.RegisterHint(SplitLimbsHint)
+	solver.RegisterHint(Hints()...)
+}
+
+// Hints returns the solver hints of the field chip.
+func Hints() []solver.Hint {
+	return []solver.Hint{InvFHint, InvEHint, ReduceHint, SplitLimbsHint}
 }
 
 type Variable struct {
@@ -40,13 +47,53 @@ type ExtensionVariable struct {
 type Chip struct {
 	api          frontend.API
 	RangeChecker frontend.Rangechecker
+
+	// lookup selects RangeChecker over bit decomposition, see RangeCheck.
+	lookup bool
+
+	// lookupDecomposition splits through decomposer, created by NewChip, see
+	// WithLookupDecomposition.
+	lookupDecomposition bool
+	decomposer          *decompose.Decomposer
 }
 
-func NewChip(api frontend.API) *Chip {
-	return &Chip{
+// Option configures a Chip. Every option changes the circuit, so a circuit built with
+// other options needs its own keys.
+type Option func(*Chip)
+
+// WithLookupRangeCheck range checks with gnark's commitment based (LogUp) range checker
+// instead of bit decomposition, see RangeCheck.
+func WithLookupRangeCheck() Option {
+	return func(c *Chip) {
+		c.lookup = true
+	}
+}
+
+// WithLookupDecomposition splits the remainders of reductions, to check they are
+// canonical, with the shared lookup gadget of the decompose package instead of a hint
+// of their own. It implies WithLookupRangeCheck and costs the same, for circuits that
+// split other values with the gadget.
+func WithLookupDecomposition() Option {
+	return func(c *Chip) {
+		c.lookup = true
+		c.lookupDecomposition = true
+	}
+}
+
+// NewChip returns a koalabear chip. Without options it range checks by bit
+// decomposition and reduces to canonical form.
+func NewChip(api frontend.API, opts ...Option) *Chip {
+	c := &Chip{
 		api:          api,
 		RangeChecker: rangecheck.New(api),
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.lookupDecomposition {
+		c.decomposer = decompose.New(api)
+	}
+	return c
 }
 
 func Zero() Variable {
@@ -77,7 +124,7 @@ func NewFConst(value string) Variable {
 func NewF(value string) Variable {
 	return Variable{
 		Value:      frontend.Variable(value),
-		UpperBound: new(big.Int).SetUint64(uint64(math.Pow(2, 32))),
+		UpperBound: two_32,
 	}
 }
 
@@ -101,6 +148,18 @@ func Felts2Ext(a, b, c, d Variable) ExtensionVariable {
 	return ExtensionVariable{Value: [4]Variable{a, b, c, d}}
 }
 
+// RangeCheck asserts that v fits in bits. For Groth16 it decomposes v into bits by
+// default, which keeps the proof free of commitments. WithLookupRangeCheck switches to
+// gnark's commitment based (LogUp) range checker, which is much cheaper across the
+// thousands of reductions of a proof but adds a commitment to the proof.
+func (c *Chip) RangeCheck(v frontend.Variable, bits int) {
+	if c.lookup {
+		c.RangeChecker.Check(v, bits)
+	} else {
+		c.api.ToBinary(v, bits)
+	}
+}
+
 func (c *Chip) AddF(a, b Variable, forceReduce ...bool) Variable {
 	result := Variable{
 		Value:      c.api.Add(a.Value, b.Value),
@@ -113,7 +172,7 @@ func (c *Chip) AddF(a, b Variable, forceReduce ...bool) Variable {
 }
 
 func (c *Chip) SubF(a, b Variable) Variable {
-	negB := c.negF(b)
+	negB := c.NegF(b)
 	return c.AddF(a, negB)
 }
 
@@ -139,7 +198,9 @@ func (c *Chip) MulFConst(a Variable, b int, forceReduce ...bool) Variable {
 	return c.reduceFast(result)
 }
 
-func (c *Chip) negF(a Variable) Variable {
+// NegF returns -a, lifting the modulus above the upper bound of a so the difference
+// stays non-negative.
+func (c *Chip) NegF(a Variable) Variable {
 	divisor := new(big.Int).Div(a.UpperBound, modulus)
 	divisorPlusOne := new(big.Int).Add(divisor, big.NewInt(1))
 	liftedModulus := new(big.Int).Mul(divisorPlusOne, modulus)
@@ -150,7 +211,9 @@ func (c *Chip) negF(a Variable) Variable {
 	})
 }
 
-func (c *Chip) invF(in Variable) Variable {
+// InvF returns the inverse of in, hinted and checked by multiplication. An in of zero
+// has no inverse and leaves the circuit unsatisfied.
+func (c *Chip) InvF(in Variable) Variable {
 	result, err := c.api.Compiler().NewHint(InvFHint, 1, in.Value)
 	if err != nil {
 		panic(err)
@@ -160,22 +223,70 @@ func (c *Chip) invF(in Variable) Variable {
 		Value:      result[0],
 		UpperBound: new(big.Int).SetUint64(2147483648),
 	}
-	if os.Getenv("GROTH16") != "1" {
-		c.RangeChecker.Check(result[0], 31)
-	} else {
-		c.api.ToBinary(result[0], 31)
-	}
+	c.RangeCheck(result[0], 31)
 	product := c.MulF(in, xinv)
 	c.AssertIsEqualF(product, NewFConst("1"))
 
 	return xinv
 }
 
+// DivF returns a / b, unsatisfied if b is zero.
 func (c *Chip) DivF(a, b Variable) Variable {
-	bInv := c.invF(b)
+	bInv := c.InvF(b)
 	return c.MulF(a, bInv)
 }
 
+// expWindow is the largest window of ExpF and ExpE, the table of the powers of a
+// window taking 2^expWindow - 2 multiplications.
+const expWindow = 4
+
+// ExpF returns a^exponent by fixed window exponentiation: a multiplication per non-zero
+// window of the exponent on top of the squarings, instead of one per set bit.
+func (c *Chip) ExpF(a Variable, exponent uint64) Variable {
+	return exp(a, exponent, One(), func(x, y Variable) Variable { return c.MulF(x, y) })
+}
+
+// ExpE returns a^exponent, see ExpF.
+func (c *Chip) ExpE(a ExtensionVariable, exponent uint64) ExtensionVariable {
+	return exp(a, exponent, NewEConst([]string{"1", "0", "0", "0"}), c.MulE)
+}
+
+// exp raises a to exponent with mul, scanning the exponent from its most significant
+// window of up to expWindow bits.
+func exp[T any](a T, exponent uint64, one T, mul func(x, y T) T) T {
+	if exponent == 0 {
+		return one
+	}
+	window := min(expWindow, bits.Len64(exponent))
+	table := make([]T, 1<<window)
+	table[1] = a
+	for i := 2; i < len(table); i++ {
+		table[i] = mul(table[i-1], a)
+	}
+	mask := uint64(len(table) - 1)
+	var res T
+	started := false
+	for i := (bits.Len64(exponent) - 1) / window; i >= 0; i-- {
+		if started {
+			for j := 0; j < window; j++ {
+				res = mul(res, res)
+			}
+		}
+		digit := exponent >> (i * window) & mask
+		if digit == 0 {
+			continue
+		}
+		if started {
+			res = mul(res, table[digit])
+		} else {
+			res, started = table[digit], true
+		}
+	}
+	return res
+}
+
+// AssertIsEqualF asserts that a and b are the same field element, comparing their
+// canonical forms whatever their upper bounds.
 func (c *Chip) AssertIsEqualF(a, b Variable) {
 	a2 := c.ReduceSlow(a)
 	b2 := c.ReduceSlow(b)
@@ -188,6 +299,24 @@ func (c *Chip) AssertNotEqualF(a, b Variable) {
 	c.api.AssertIsDifferent(a2.Value, b2.Value)
 }
 
+// AssertIsCanonicalF asserts that the value of a is below the modulus, as the encoding
+// of a felt outside the circuit must be. Unlike AssertIsEqualF it does not reduce a: a
+// value congruent to a canonical one but larger leaves the circuit unsatisfied.
+func (c *Chip) AssertIsCanonicalF(a Variable) {
+	if a.UpperBound.Cmp(modulus) == -1 {
+		return
+	}
+	c.assertCanonical(a.Value)
+}
+
+// AssertIsCanonicalE asserts that every coordinate of a is canonical, see
+// AssertIsCanonicalF.
+func (c *Chip) AssertIsCanonicalE(a ExtensionVariable) {
+	for i := range a.Value {
+		c.AssertIsCanonicalF(a.Value[i])
+	}
+}
+
 func (c *Chip) AssertIsEqualE(a, b ExtensionVariable) {
 	c.AssertIsEqualF(a.Value[0], b.Value[0])
 	c.AssertIsEqualF(a.Value[1], b.Value[1])
@@ -287,17 +416,10 @@ func (c *Chip) InvE(in ExtensionVariable) ExtensionVariable {
 	yinv := Variable{Value: result[1], UpperBound: new(big.Int).SetUint64(2147483648)}
 	zinv := Variable{Value: result[2], UpperBound: new(big.Int).SetUint64(2147483648)}
 	linv := Variable{Value: result[3], UpperBound: new(big.Int).SetUint64(2147483648)}
-	if os.Getenv("GROTH16") != "1" {
-		c.RangeChecker.Check(result[0], 31)
-		c.RangeChecker.Check(result[1], 31)
-		c.RangeChecker.Check(result[2], 31)
-		c.RangeChecker.Check(result[3], 31)
-	} else {
-		c.api.ToBinary(result[0], 31)
-		c.api.ToBinary(result[1], 31)
-		c.api.ToBinary(result[2], 31)
-		c.api.ToBinary(result[3], 31)
-	}
+	c.RangeCheck(result[0], 31)
+	c.RangeCheck(result[1], 31)
+	c.RangeCheck(result[2], 31)
+	c.RangeCheck(result[3], 31)
 	out := ExtensionVariable{Value: [4]Variable{xinv, yinv, zinv, linv}}
 
 	product := c.MulE(in, out)
@@ -316,15 +438,15 @@ func (c *Chip) DivE(a, b ExtensionVariable) ExtensionVariable {
 }
 
 func (c *Chip) DivEF(a ExtensionVariable, b Variable) ExtensionVariable {
-	bInv := c.invF(b)
+	bInv := c.InvF(b)
 	return c.MulEF(a, bInv)
 }
 
 func (c *Chip) NegE(a ExtensionVariable) ExtensionVariable {
-	v1 := c.negF(a.Value[0])
-	v2 := c.negF(a.Value[1])
-	v3 := c.negF(a.Value[2])
-	v4 := c.negF(a.Value[3])
+	v1 := c.NegF(a.Value[0])
+	v2 := c.NegF(a.Value[1])
+	v3 := c.NegF(a.Value[2])
+	v4 := c.NegF(a.Value[3])
 	return ExtensionVariable{Value: [4]Variable{v1, v2, v3, v4}}
 }
 
@@ -368,15 +490,22 @@ func (p *Chip) reduceWithMaxBits(x frontend.Variable, maxNbBits uint64) frontend
 
 	//fmt.Printf("quotient: %d, remainder: %d \n", quotient, remainder)
 
-	if os.Getenv("GROTH16") != "1" {
-		p.RangeChecker.Check(quotient, int(maxNbBits-30))
-	} else {
-		p.api.ToBinary(quotient, int(maxNbBits-30))
-	}
+	p.RangeCheck(quotient, int(maxNbBits-30))
+	p.assertCanonical(remainder)
+
+	p.api.AssertIsEqual(x, p.api.Add(p.api.Mul(quotient, modulus), remainder))
 
-	// Check that the remainder has size less than the KoalaBear modulus, by decomposing it into a 24
-	// bit limb and a 7 bit limb.
-	new_result, new_err := p.api.Compiler().NewHint(SplitLimbsHint, 2, remainder)
+	return remainder
+}
+
+// assertCanonical checks that v has size less than the KoalaBear modulus, by
+// decomposing it into a 24 bit limb and a 7 bit limb.
+func (p *Chip) assertCanonical(v frontend.Variable) {
+	if p.decomposer != nil {
+		p.assertCanonicalDecomposed(v)
+		return
+	}
+	new_result, new_err := p.api.Compiler().NewHint(SplitLimbsHint, 2, v)
 	if new_err != nil {
 		panic(new_err)
 	}
@@ -390,24 +519,15 @@ func (p *Chip) reduceWithMaxBits(x frontend.Variable, maxNbBits uint64) frontend
 			p.api.Mul(highLimb, frontend.Variable(uint64(math.Pow(2, 24)))),
 			lowLimb,
 		),
-		remainder,
+		v,
 	)
-	if os.Getenv("GROTH16") != "1" {
-		p.RangeChecker.Check(highLimb, 7)
-		p.RangeChecker.Check(lowLimb, 24)
-	} else {
-		p.api.ToBinary(highLimb, 7)
-		p.api.ToBinary(lowLimb, 24)
-	}
-
-	//fmt.Printf("reminder: %x \n", remainder)
-	//fmt.Printf("highLimb: %x, lowLimb: %x \n", highLimb, lowLimb)
+	p.RangeCheck(highLimb, 7)
+	p.RangeCheck(lowLimb, 24)
 
 	// If the most significant bits are all 1, then we need to check that the least significant bits
 	// are all zero in order for element to be less than the KoalaBear modulus. Otherwise, we don't
 	// need to do any checks, since we already know that the element is less than the KoalaBear modulus.
 	shouldCheck := p.api.IsZero(p.api.Sub(highLimb, uint64(math.Pow(2, 7))-1))
-	// fmt.Printf("reduceWithMaxBits shouldCheck: %d\n", shouldCheck)
 	p.api.AssertIsEqual(
 		p.api.Mul(
 			shouldCheck,
@@ -415,10 +535,15 @@ func (p *Chip) reduceWithMaxBits(x frontend.Variable, maxNbBits uint64) frontend
 		),
 		frontend.Variable(0),
 	)
+}
 
-	p.api.AssertIsEqual(x, p.api.Add(p.api.Mul(quotient, modulus), remainder))
-
-	return remainder
+// assertCanonicalDecomposed checks that v has size less than the KoalaBear modulus
+// 0x7f000001 like assertCanonical, splitting it with the shared gadget into a 24 bit
+// limb and a 7 bit limb that is all ones only if the low limb is zero.
+func (p *Chip) assertCanonicalDecomposed(v frontend.Variable) {
+	limbs := p.decomposer.Split(v, 24, 7)
+	shouldCheck := p.api.IsZero(p.api.Sub(limbs[1], 1<<7-1))
+	p.api.AssertIsEqual(p.api.Mul(shouldCheck, limbs[0]), 0)
 }
 
 // The hint used to compute Reduce.
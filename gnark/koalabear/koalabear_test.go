@@ -0,0 +1,170 @@
+package koalabear
+
+import (
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"math/big"
+	"testing"
+)
+
+// mulChainCircuit folds extension elements the way FRI verification does.
+type mulChainCircuit struct {
+	Inputs   [32]ExtensionVariable
+	Expected ExtensionVariable
+
+	opts []Option
+}
+
+func (circuit *mulChainCircuit) Define(api frontend.API) error {
+	chip := NewChip(api, circuit.opts...)
+	acc := circuit.Inputs[0]
+	for i := 1; i < len(circuit.Inputs); i++ {
+		acc = chip.AddE(chip.MulE(acc, circuit.Inputs[i]), circuit.Inputs[i])
+	}
+	chip.AssertIsEqualE(acc, circuit.Expected)
+	return nil
+}
+
+func newMulChain(opts ...Option) *mulChainCircuit {
+	circuit := mulChainCircuit{opts: opts}
+	acc := [4]uint64{1, 2, 3, 4}
+	circuit.Inputs[0] = NewE(extString(acc))
+	for i := 1; i < len(circuit.Inputs); i++ {
+		in := [4]uint64{uint64(i), uint64(2 * i), 2130706432, uint64(i * i)}
+		circuit.Inputs[i] = NewE(extString(in))
+		acc = extAdd(extMul(acc, in), in)
+	}
+	circuit.Expected = NewE(extString(acc))
+	return &circuit
+}
+
+func extString(v [4]uint64) []string {
+	res := make([]string, 4)
+	for i := range v {
+		res[i] = new(big.Int).SetUint64(v[i]).String()
+	}
+	return res
+}
+
+// extMul multiplies in F_p[x]/(x^4 - 3).
+func extMul(a, b [4]uint64) [4]uint64 {
+	p := modulus.Uint64()
+	var res [4]uint64
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			term := a[i] * b[j] % p
+			if i+j >= 4 {
+				res[i+j-4] = (res[i+j-4] + 3*term) % p
+			} else {
+				res[i+j] = (res[i+j] + term) % p
+			}
+		}
+	}
+	return res
+}
+
+func extAdd(a, b [4]uint64) [4]uint64 {
+	p := modulus.Uint64()
+	var res [4]uint64
+	for i := range res {
+		res[i] = (a[i] + b[i]) % p
+	}
+	return res
+}
+
+func TestLookupRangeCheck(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	var counts [2]int
+	for i, opts := range [][]Option{nil, {WithLookupRangeCheck()}} {
+		assert.NoError(test.IsSolved(newMulChain(opts...), newMulChain(opts...), ecc.BN254.ScalarField()))
+
+		ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, newMulChain(opts...))
+		assert.NoError(err)
+		counts[i] = ccs.GetNbConstraints()
+	}
+	t.Logf("constraints: bits %d, lookup %d", counts[0], counts[1])
+	assert.Less(counts[1], counts[0])
+}
+
+// opsCircuit checks the field operations of the chip against values computed natively.
+type opsCircuit struct {
+	A, B                     Variable
+	Sub, Neg, Div, Pow7, Inv Variable
+	E, EPow                  ExtensionVariable
+}
+
+func (circuit *opsCircuit) Define(api frontend.API) error {
+	chip := NewChip(api)
+	chip.AssertIsCanonicalF(circuit.A)
+	chip.AssertIsCanonicalE(circuit.E)
+	chip.AssertIsEqualF(chip.SubF(circuit.A, circuit.B), circuit.Sub)
+	chip.AssertIsEqualF(chip.NegF(circuit.A), circuit.Neg)
+	chip.AssertIsEqualF(chip.DivF(circuit.A, circuit.B), circuit.Div)
+	chip.AssertIsEqualF(chip.ExpF(circuit.A, 7), circuit.Pow7)
+	chip.AssertIsEqualF(chip.ExpF(circuit.B, modulus.Uint64()-2), circuit.Inv)
+	chip.AssertIsEqualF(chip.ExpF(circuit.A, 0), One())
+	chip.AssertIsEqualF(chip.ExpF(circuit.A, 1), circuit.A)
+	chip.AssertIsEqualE(chip.ExpE(circuit.E, 21), circuit.EPow)
+	return nil
+}
+
+func newOps(a, b uint64) *opsCircuit {
+	p := modulus.Uint64()
+	pow := func(x, e uint64) uint64 {
+		return new(big.Int).Exp(new(big.Int).SetUint64(x), new(big.Int).SetUint64(e), modulus).Uint64()
+	}
+	f := func(v uint64) Variable {
+		return NewF(new(big.Int).SetUint64(v).String())
+	}
+	e := [4]uint64{a, b, 5, p - 1}
+	ePow := [4]uint64{1, 0, 0, 0}
+	for i := 0; i < 21; i++ {
+		ePow = extMul(ePow, e)
+	}
+	return &opsCircuit{
+		A:    f(a),
+		B:    f(b),
+		Sub:  f((a + p - b) % p),
+		Neg:  f((p - a) % p),
+		Div:  f(a * pow(b, p-2) % p),
+		Pow7: f(pow(a, 7)),
+		Inv:  f(pow(b, p-2)),
+		E:    NewE(extString(e)),
+		EPow: NewE(extString(ePow)),
+	}
+}
+
+func TestOps(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	for _, ab := range [][2]uint64{{3, 5}, {0, 1}, {2130706432, 2130706432}, {123456789, 987654321}} {
+		assert.NoError(test.IsSolved(newOps(0, 1), newOps(ab[0], ab[1]), ecc.BN254.ScalarField()), ab)
+	}
+
+	// the modulus is congruent to zero but not its canonical encoding
+	nonCanonical := newOps(0, 1)
+	nonCanonical.A = NewF(modulus.String())
+	assert.Error(test.IsSolved(newOps(0, 1), nonCanonical, ecc.BN254.ScalarField()))
+	wrong := newOps(3, 5)
+	wrong.Pow7 = NewF("2188")
+	assert.Error(test.IsSolved(newOps(0, 1), wrong, ecc.BN254.ScalarField()))
+}
+
+func TestLookupDecomposition(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	var counts [3]int
+	for i, opts := range [][]Option{nil, {WithLookupRangeCheck()}, {WithLookupDecomposition()}} {
+		assert.NoError(test.IsSolved(newMulChain(opts...), newMulChain(opts...), ecc.BN254.ScalarField()))
+
+		ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, newMulChain(opts...))
+		assert.NoError(err)
+		counts[i] = ccs.GetNbConstraints()
+	}
+	t.Logf("constraints: bits %d, lookup %d, decompose %d", counts[0], counts[1], counts[2])
+	assert.Equal(counts[1], counts[2])
+	assert.NoError(test.IsSolved(newOps(0, 1), newOps(123456789, 987654321), ecc.BN254.ScalarField()))
+}
@@ -0,0 +1,61 @@
+package koalabear_native
+
+import (
+	"github.com/brevis-network/pico/gnark/poseidon2"
+	"math/big"
+)
+
+// DigestSize is the number of felts of a Poseidon2 digest.
+const DigestSize = poseidon2.FieldDigestSize
+
+// Digest is a Poseidon2 digest.
+type Digest [DigestSize]F
+
+// Hash hashes inputs with the Poseidon2 sponge of the Rust prover.
+func Hash(inputs ...F) Digest {
+	words := make([]uint32, len(inputs))
+	for i, v := range inputs {
+		words[i] = uint32(v)
+	}
+	var digest Digest
+	for i, v := range poseidon2.NativeHash(poseidon2.PermuteKoalaBear, words) {
+		digest[i] = F(v)
+	}
+	return digest
+}
+
+// Domain is a preprocessed trace domain of a verifying key: the coset of size 2^LogN
+// shifted by Shift.
+type Domain struct {
+	LogN  int
+	Shift F
+}
+
+// VkeyDigest is the digest of a verifying key with preprocessed commitment commit,
+// program counter start pcStart and preprocessed domains, HashableKey::hash_field of
+// the Rust prover.
+func VkeyDigest(commit Digest, pcStart F, domains []Domain) Digest {
+	inputs := append(commit[:], pcStart)
+	for _, domain := range domains {
+		inputs = append(inputs, NewF(uint64(domain.LogN)), NewF(1<<domain.LogN), domain.Shift, TwoAdicGenerator(domain.LogN))
+	}
+	return Hash(inputs...)
+}
+
+// BN254 packs digest into a BN254 scalar, 31 bits per felt, as the vkey hash of the
+// wrapper's public inputs.
+func (d Digest) BN254() *big.Int {
+	res := new(big.Int)
+	for _, v := range d {
+		res.Lsh(res, 31)
+		res.Add(res, big.NewInt(int64(v)))
+	}
+	return res
+}
+
+// BytesToBN254 packs 32 bytes into a BN254 scalar, big endian with the 3 top bits
+// dropped, as the committed values digest of the wrapper's public inputs.
+func BytesToBN254(bytes [32]byte) *big.Int {
+	bytes[0] &= 0x1f
+	return new(big.Int).SetBytes(bytes[:])
+}
@@ -0,0 +1,36 @@
+package koalabear_native
+
+import (
+	"github.com/brevis-network/pico/gnark/poseidon2"
+	"github.com/consensys/gnark/test"
+	"math/big"
+	"testing"
+)
+
+func TestDigest(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// the all-zero permutation recorded from the Rust side, truncated
+	assert.Equal(Digest{}, Hash())
+	assert.Equal(Digest{1330215056, 1388930081, 1337212159, 2038180411, 1881671374, 164509734, 498654582, 1841854018}, Hash(0))
+
+	commit := Digest{1, 2, 3, 4, 5, 6, 7, 8}
+	domains := []Domain{{LogN: 3, Shift: 3}, {LogN: 20, Shift: 1}}
+	var inputs []uint32
+	for _, v := range commit {
+		inputs = append(inputs, uint32(v))
+	}
+	inputs = append(inputs, 9, 3, 8, 3, uint32(TwoAdicGenerator(3)), 20, 1<<20, 1, uint32(TwoAdicGenerator(20)))
+	var expected Digest
+	for i, v := range poseidon2.NativeHash(poseidon2.PermuteKoalaBear, inputs) {
+		expected[i] = F(v)
+	}
+	assert.Equal(expected, VkeyDigest(commit, 9, domains))
+
+	digest := Digest{1, 0, 0, 0, 0, 0, 0, 2}
+	assert.Equal(0, digest.BN254().Cmp(new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), 7*31), big.NewInt(2))))
+
+	var bytes [32]byte
+	bytes[0], bytes[31] = 0xff, 1
+	assert.Equal(0, BytesToBN254(bytes).Cmp(new(big.Int).Add(new(big.Int).Lsh(big.NewInt(0x1f), 248), big.NewInt(1))))
+}
@@ -0,0 +1,145 @@
+package koalabear_native
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+const (
+	// Modulus is the KoalaBear prime 2^31 - 2^24 + 1.
+	Modulus = 2130706433
+	// Generator generates the multiplicative group.
+	Generator = 3
+	// TwoAdicity is the largest k with 2^k dividing Modulus - 1.
+	TwoAdicity = 24
+	// ExtW is the W of the degree 4 extension F[X]/(X^4 - W) of the circuits.
+	ExtW = 3
+)
+
+// F is a KoalaBear field element, always canonical.
+type F uint32
+
+// NewF reduces v into the field.
+func NewF(v uint64) F {
+	return F(v % Modulus)
+}
+
+// ParseF parses a decimal felt of a witness or constraints file, which must be
+// canonical.
+func ParseF(s string) (F, error) {
+	v, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || v >= Modulus {
+		return 0, fmt.Errorf("invalid koalabear felt %q", s)
+	}
+	return F(v), nil
+}
+
+func (a F) String() string {
+	return strconv.FormatUint(uint64(a), 10)
+}
+
+func (a F) Add(b F) F {
+	return F((uint64(a) + uint64(b)) % Modulus)
+}
+
+func (a F) Sub(b F) F {
+	return F((uint64(a) + Modulus - uint64(b)) % Modulus)
+}
+
+func (a F) Neg() F {
+	return F((Modulus - uint64(a)) % Modulus)
+}
+
+func (a F) Mul(b F) F {
+	return F(uint64(a) * uint64(b) % Modulus)
+}
+
+// Exp returns a^e.
+func (a F) Exp(e uint64) F {
+	res, base := F(1), a
+	for ; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			res = res.Mul(base)
+		}
+		base = base.Mul(base)
+	}
+	return res
+}
+
+// Inverse returns 1/a, and 0 for 0 as the circuit's inverse hint does.
+func (a F) Inverse() F {
+	return a.Exp(Modulus - 2)
+}
+
+// TwoAdicGenerator returns the generator of the subgroup of order 2^bits, the one the
+// Rust prover's domains use.
+func TwoAdicGenerator(bits int) F {
+	if bits < 0 || bits > TwoAdicity {
+		panic(fmt.Sprintf("no subgroup of order 2^%d", bits))
+	}
+	return F(Generator).Exp((Modulus - 1) >> bits)
+}
+
+// E is an element of the degree 4 extension, coefficients of 1, X, X^2, X^3.
+type E [4]F
+
+// NewE embeds a in the extension.
+func NewE(a F) E {
+	return E{a}
+}
+
+func (a E) Add(b E) E {
+	return E{a[0].Add(b[0]), a[1].Add(b[1]), a[2].Add(b[2]), a[3].Add(b[3])}
+}
+
+func (a E) Sub(b E) E {
+	return E{a[0].Sub(b[0]), a[1].Sub(b[1]), a[2].Sub(b[2]), a[3].Sub(b[3])}
+}
+
+func (a E) Neg() E {
+	return E{a[0].Neg(), a[1].Neg(), a[2].Neg(), a[3].Neg()}
+}
+
+// MulF multiplies a by the base field element b.
+func (a E) MulF(b F) E {
+	return E{a[0].Mul(b), a[1].Mul(b), a[2].Mul(b), a[3].Mul(b)}
+}
+
+func (a E) Mul(b E) E {
+	var res E
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			if i+j >= 4 {
+				res[i+j-4] = res[i+j-4].Add(a[i].Mul(b[j]).Mul(ExtW))
+			} else {
+				res[i+j] = res[i+j].Add(a[i].Mul(b[j]))
+			}
+		}
+	}
+	return res
+}
+
+// Exp returns a^e.
+func (a E) Exp(e *big.Int) E {
+	res := NewE(1)
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		res = res.Mul(res)
+		if e.Bit(i) == 1 {
+			res = res.Mul(a)
+		}
+	}
+	return res
+}
+
+// extOrderMinus2 is p^4 - 2, the exponent of the inverse in the extension.
+var extOrderMinus2 = new(big.Int).Sub(new(big.Int).Exp(big.NewInt(Modulus), big.NewInt(4), nil), big.NewInt(2))
+
+// Inverse returns 1/a, and 0 for 0.
+func (a E) Inverse() E {
+	return a.Exp(extOrderMinus2)
+}
+
+func (a E) IsZero() bool {
+	return a == E{}
+}
@@ -0,0 +1,84 @@
+package koalabear_native
+
+import (
+	"github.com/brevis-network/pico/gnark/koalabear"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"math/rand"
+	"testing"
+)
+
+func TestField(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	rng := rand.New(rand.NewSource(354))
+	for i := 0; i < 100; i++ {
+		a, b := NewF(rng.Uint64()), NewF(rng.Uint64())
+		assert.Equal(a, a.Add(b).Sub(b))
+		assert.Equal(F(0), a.Add(a.Neg()))
+		if a != 0 {
+			assert.Equal(F(1), a.Mul(a.Inverse()))
+		}
+		x := E{a, b, NewF(rng.Uint64()), NewF(rng.Uint64())}
+		assert.Equal(NewE(1), x.Mul(x.Inverse()))
+		assert.Equal(x.MulF(b), x.Mul(NewE(b)))
+	}
+	assert.Equal(F(0), F(0).Inverse())
+	assert.True(E{}.Inverse().IsZero())
+
+	// the two-adic generators of plonky3's KoalaBear
+	assert.Equal(F(Modulus-1), TwoAdicGenerator(1))
+	assert.Equal(F(0x7e010002), TwoAdicGenerator(2))
+	assert.Equal(F(0x6ac49f88), TwoAdicGenerator(TwoAdicity))
+	for bits := 1; bits <= TwoAdicity; bits++ {
+		g := TwoAdicGenerator(bits)
+		assert.Equal(F(1), g.Exp(1<<bits))
+		assert.Equal(F(Modulus-1), g.Exp(1<<(bits-1)))
+	}
+
+	_, err := ParseF("2130706433")
+	assert.Error(err)
+	f, err := ParseF("7")
+	assert.NoError(err)
+	assert.Equal("7", f.String())
+}
+
+// chipCircuit computes with the circuit chip, checked against the native results.
+type chipCircuit struct {
+	A, B                  koalabear.ExtensionVariable
+	Mul, Inv, Sum, Scaled koalabear.ExtensionVariable
+}
+
+func (c *chipCircuit) Define(api frontend.API) error {
+	chip := koalabear.NewChip(api)
+	chip.AssertIsEqualE(chip.MulE(c.A, c.B), c.Mul)
+	chip.AssertIsEqualE(chip.InvE(c.A), c.Inv)
+	chip.AssertIsEqualE(chip.AddE(c.A, c.B), c.Sum)
+	chip.AssertIsEqualE(chip.MulEF(c.A, c.B.Value[0]), c.Scaled)
+	return nil
+}
+
+func toVariable(e E) koalabear.ExtensionVariable {
+	return koalabear.NewE([]string{e[0].String(), e[1].String(), e[2].String(), e[3].String()})
+}
+
+func TestChip(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	rng := rand.New(rand.NewSource(354))
+	for i := 0; i < 4; i++ {
+		var a, b E
+		for j := range a {
+			a[j], b[j] = NewF(rng.Uint64()), NewF(rng.Uint64())
+		}
+		assignment := &chipCircuit{
+			A: toVariable(a), B: toVariable(b),
+			Mul: toVariable(a.Mul(b)), Inv: toVariable(a.Inverse()),
+			Sum: toVariable(a.Add(b)), Scaled: toVariable(a.MulF(b[0])),
+		}
+		// the circuit takes the bounds of its variables from the values
+		circuit := *assignment
+		assert.NoError(test.IsSolved(&circuit, assignment, ecc.BN254.ScalarField()))
+	}
+}
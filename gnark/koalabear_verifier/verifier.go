@@ -1,239 +1,145 @@
 package koalabear_verifier
 
 import (
-	"encoding/json"
-	"fmt"
+	"github.com/brevis-network/pico/gnark/challenger"
 	"github.com/brevis-network/pico/gnark/koalabear"
+	"github.com/brevis-network/pico/gnark/koalabear_native"
 	"github.com/brevis-network/pico/gnark/poseidon2"
 	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/brevis-network/pico/gnark/verifier_core"
 	"github.com/consensys/gnark/frontend"
-	"os"
-	"strconv"
 )
 
-type Circuit struct {
-	VkeyHash              frontend.Variable `gnark:",public"`
-	CommittedValuesDigest frontend.Variable `gnark:",public"`
-	Vars                  []frontend.Variable
-	Felts                 []koalabear.Variable
-	Exts                  []koalabear.ExtensionVariable
+type (
+	Circuit          = verifier_core.Circuit[koalabear.Variable, koalabear.ExtensionVariable]
+	Chunk            = verifier_core.Chunk[koalabear.Variable, koalabear.ExtensionVariable]
+	MultiCircuit     = verifier_core.MultiCircuit[koalabear.Variable, koalabear.ExtensionVariable]
+	AggregateCircuit = verifier_core.AggregateCircuit[koalabear.Variable, koalabear.ExtensionVariable]
+	LayoutCircuit    = verifier_core.LayoutCircuit[koalabear.Variable, koalabear.ExtensionVariable]
+	EmbeddedProof    = verifier_core.EmbeddedProof[koalabear.Variable, koalabear.ExtensionVariable]
+)
+
+// NewCircuit returns the KoalaBear wrapper of one chunk proof, see verifier_core.Circuit.
+func NewCircuit(witnessInput utils.WitnessInput, opts verifier_core.Options) *Circuit {
+	return verifier_core.NewCircuit[koalabear.Variable, koalabear.ExtensionVariable](witnessInput, fieldBuilder{}, opts)
+}
+
+func NewChunk(witnessInput utils.WitnessInput) Chunk {
+	return verifier_core.NewChunk[koalabear.Variable, koalabear.ExtensionVariable](witnessInput, fieldBuilder{})
+}
+
+// NewMultiCircuit wraps several KoalaBear chunk proofs, see verifier_core.MultiCircuit.
+func NewMultiCircuit(witnessInput utils.MultiWitnessInput, opts verifier_core.Options) (*MultiCircuit, error) {
+	return verifier_core.NewMultiCircuit[koalabear.Variable, koalabear.ExtensionVariable](witnessInput, fieldBuilder{}, opts)
+}
+
+// NewAggregateCircuit wraps KoalaBear chunk proofs of several programs, see
+// verifier_core.AggregateCircuit.
+func NewAggregateCircuit(witnessInput utils.MultiWitnessInput, opts verifier_core.Options) (*AggregateCircuit, error) {
+	return verifier_core.NewAggregateCircuit[koalabear.Variable, koalabear.ExtensionVariable](witnessInput, fieldBuilder{}, opts)
+}
+
+// NewLayoutCircuit wraps one chunk proof with a public input layout other than the
+// default, see verifier_core.LayoutCircuit.
+func NewLayoutCircuit(witnessInput utils.WitnessInput, layout utils.PublicLayout, opts verifier_core.Options) (*LayoutCircuit, error) {
+	return verifier_core.NewLayoutCircuit[koalabear.Variable, koalabear.ExtensionVariable](witnessInput, layout, fieldBuilder{}, opts)
+}
+
+// NewEmbeddedProof assigns a KoalaBear Pico proof verified inside an application's own
+// circuit, whose public values Verify also constrains with statements, see
+// verifier_core.EmbeddedProof.
+func NewEmbeddedProof(witnessInput utils.WitnessInput, opts verifier_core.Options, statements ...verifier_core.Statement) *EmbeddedProof {
+	return verifier_core.NewEmbeddedProof[koalabear.Variable, koalabear.ExtensionVariable](witnessInput, fieldBuilder{}, opts, statements...)
+}
+
+// VerifyPicoProof verifies proof in the circuit being defined and returns the vkey
+// hash and committed values digest it commits to.
+func VerifyPicoProof(api frontend.API, proof *EmbeddedProof) (verifier_core.PublicValues, error) {
+	return proof.Verify(api)
+}
+
+// NewChallenger returns the KoalaBear DuplexChallenger of the Rust prover built on the
+// chips of the verifier circuit with opts, for circuits embedding a Pico proof that
+// draw their own Fiat-Shamir challenges, see challenger.Challenger.
+func NewChallenger(api frontend.API, opts verifier_core.Options) *challenger.Challenger {
+	chip, hash := newChips(api, opts)
+	return challenger.New(api, poseidon2.NewKoalaBearFieldChip(chip, hash))
+}
+
+// fieldBuilder is the KoalaBear verifier_core.FieldBuilder.
+type fieldBuilder struct{}
+
+func (fieldBuilder) NewField(api frontend.API, opts verifier_core.Options) verifier_core.Field[koalabear.Variable, koalabear.ExtensionVariable] {
+	chip, hash := newChips(api, opts)
+	return field{Chip: chip, hash: hash}
 }
 
-func NewCircuit(witnessInput utils.WitnessInput) *Circuit {
-	vars := make([]frontend.Variable, len(witnessInput.Vars))
-	felts := make([]koalabear.Variable, len(witnessInput.Felts))
-	exts := make([]koalabear.ExtensionVariable, len(witnessInput.Exts))
-	for i := 0; i < len(witnessInput.Vars); i++ {
-		vars[i] = frontend.Variable(witnessInput.Vars[i])
+// newChips returns the field and Poseidon2 chips of the verifier circuit with opts.
+func newChips(api frontend.API, opts verifier_core.Options) (*koalabear.Chip, *poseidon2.Poseidon2KoalaBearChip) {
+	var chipOpts []koalabear.Option
+	if opts.LookupRangeCheck {
+		chipOpts = append(chipOpts, koalabear.WithLookupRangeCheck())
 	}
-	for i := 0; i < len(witnessInput.Felts); i++ {
-		felts[i] = koalabear.NewF(witnessInput.Felts[i])
+	if opts.LookupDecomposition {
+		chipOpts = append(chipOpts, koalabear.WithLookupDecomposition())
 	}
-	for i := 0; i < len(witnessInput.Exts); i++ {
-		exts[i] = koalabear.NewE(witnessInput.Exts[i])
+	hashKoalaBearAPI := poseidon2.NewKoalaBearChip(api, chipOpts...)
+	if opts.Poseidon2CrossCheck {
+		hashKoalaBearAPI.EnableCrossCheck()
 	}
-	return &Circuit{
-		VkeyHash:              witnessInput.VkeyHash,
-		CommittedValuesDigest: witnessInput.CommittedValuesDigest,
-		Vars:                  vars,
-		Felts:                 felts,
-		Exts:                  exts,
+	if opts.Poseidon2ConstantFolding {
+		hashKoalaBearAPI.EnableConstantFolding()
 	}
+	return koalabear.NewChip(api, chipOpts...), hashKoalaBearAPI
 }
 
-type Constraint struct {
-	Opcode string     `json:"opcode"`
-	Args   [][]string `json:"args"`
+func (fieldBuilder) NewF(value string) koalabear.Variable {
+	return koalabear.NewF(value)
 }
 
-type Proof struct {
-	PublicInputs [2]string `json:"public_inputs"`
-	EncodedProof string    `json:"encoded_proof"`
-	RawProof     string    `json:"raw_proof"`
+func (fieldBuilder) NewE(value []string) koalabear.ExtensionVariable {
+	return koalabear.NewE(value)
 }
 
-func (circuit *Circuit) Define(api frontend.API) error {
-	// Get the file name from an environment variable.
-	fileName := os.Getenv("CONSTRAINTS_JSON")
-	if fileName == "" {
-		fileName = "constraints.json"
-	}
+// field is the KoalaBear verifier_core.Field.
+type field struct {
+	*koalabear.Chip
+	hash *poseidon2.Poseidon2KoalaBearChip
+}
 
-	// Read the file.
-	data, err := os.ReadFile(fileName)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
-	}
+func (field) NewF(value string) koalabear.Variable {
+	return koalabear.NewF(value)
+}
 
-	// Deserialize the JSON data into a slice of Instruction structs.
-	var constraints []Constraint
-	err = json.Unmarshal(data, &constraints)
-	if err != nil {
-		return fmt.Errorf("error deserializing JSON: %v", err)
-	}
+func (field) NewE(value []string) koalabear.ExtensionVariable {
+	return koalabear.NewE(value)
+}
 
-	hashAPI := poseidon2.NewChip(api)
-	hashKoalaBearAPI := poseidon2.NewKoalaBearChip(api)
-	fieldAPI := koalabear.NewChip(api)
-	vars := make(map[string]frontend.Variable)
-	felts := make(map[string]koalabear.Variable)
-	exts := make(map[string]koalabear.ExtensionVariable)
-
-	// Iterate through the witnesses and range check them, if necessary.
-	for i := 0; i < len(circuit.Felts); i++ {
-		if os.Getenv("GROTH16") != "1" {
-			fieldAPI.RangeChecker.Check(circuit.Felts[i].Value, 31)
-		} else {
-			api.ToBinary(circuit.Felts[i].Value, 31)
-		}
-	}
-	for i := 0; i < len(circuit.Exts); i++ {
-		for j := 0; j < 4; j++ {
-			if os.Getenv("GROTH16") != "1" {
-				fieldAPI.RangeChecker.Check(circuit.Exts[i].Value[j].Value, 31)
-			} else {
-				api.ToBinary(circuit.Exts[i].Value[j].Value, 31)
-			}
-		}
-	}
+func (field) Felts2Ext(a, b, c, d koalabear.Variable) koalabear.ExtensionVariable {
+	return koalabear.Felts2Ext(a, b, c, d)
+}
 
-	// Iterate through the instructions and handle each opcode.
-	for _, cs := range constraints {
-		//fmt.Printf("cs: %s \n", cs.Opcode)
-		switch cs.Opcode {
-		case "ImmV":
-			vars[cs.Args[0][0]] = frontend.Variable(cs.Args[1][0])
-		case "ImmF":
-			felts[cs.Args[0][0]] = koalabear.NewF(cs.Args[1][0])
-		case "ImmE":
-			exts[cs.Args[0][0]] = koalabear.NewE(cs.Args[1])
-		case "AddV":
-			vars[cs.Args[0][0]] = api.Add(vars[cs.Args[1][0]], vars[cs.Args[2][0]])
-		case "AddF":
-			felts[cs.Args[0][0]] = fieldAPI.AddF(felts[cs.Args[1][0]], felts[cs.Args[2][0]])
-		case "AddE":
-			exts[cs.Args[0][0]] = fieldAPI.AddE(exts[cs.Args[1][0]], exts[cs.Args[2][0]])
-		case "AddEF":
-			exts[cs.Args[0][0]] = fieldAPI.AddEF(exts[cs.Args[1][0]], felts[cs.Args[2][0]])
-		case "SubV":
-			vars[cs.Args[0][0]] = api.Sub(vars[cs.Args[1][0]], vars[cs.Args[2][0]])
-		case "SubF":
-			felts[cs.Args[0][0]] = fieldAPI.SubF(felts[cs.Args[1][0]], felts[cs.Args[2][0]])
-		case "DivF":
-			felts[cs.Args[0][0]] = fieldAPI.DivF(felts[cs.Args[1][0]], felts[cs.Args[2][0]])
-		case "SubE":
-			exts[cs.Args[0][0]] = fieldAPI.SubE(exts[cs.Args[1][0]], exts[cs.Args[2][0]])
-		case "SubEF":
-			exts[cs.Args[0][0]] = fieldAPI.SubEF(exts[cs.Args[1][0]], felts[cs.Args[2][0]])
-		case "MulV":
-			vars[cs.Args[0][0]] = api.Mul(vars[cs.Args[1][0]], vars[cs.Args[2][0]])
-		case "MulF":
-			felts[cs.Args[0][0]] = fieldAPI.MulF(felts[cs.Args[1][0]], felts[cs.Args[2][0]])
-		case "MulE":
-			exts[cs.Args[0][0]] = fieldAPI.MulE(exts[cs.Args[1][0]], exts[cs.Args[2][0]])
-		case "MulEF":
-			exts[cs.Args[0][0]] = fieldAPI.MulEF(exts[cs.Args[1][0]], felts[cs.Args[2][0]])
-		case "DivE":
-			exts[cs.Args[0][0]] = fieldAPI.DivE(exts[cs.Args[1][0]], exts[cs.Args[2][0]])
-		case "DivEF":
-			exts[cs.Args[0][0]] = fieldAPI.DivEF(exts[cs.Args[1][0]], felts[cs.Args[2][0]])
-		case "NegE":
-			exts[cs.Args[0][0]] = fieldAPI.NegE(exts[cs.Args[1][0]])
-		case "InvE":
-			exts[cs.Args[0][0]] = fieldAPI.InvE(exts[cs.Args[1][0]])
-		case "Num2BitsV":
-			numBits, err := strconv.Atoi(cs.Args[2][0])
-			if err != nil {
-				return fmt.Errorf("error converting number of bits to int: %v", err)
-			}
-			bits := api.ToBinary(vars[cs.Args[1][0]], numBits)
-			for i := 0; i < len(cs.Args[0]); i++ {
-				vars[cs.Args[0][i]] = bits[i]
-			}
-		case "Num2BitsF":
-			bits := fieldAPI.ToBinary(felts[cs.Args[1][0]])
-			for i := 0; i < len(cs.Args[0]); i++ {
-				vars[cs.Args[0][i]] = bits[i]
-			}
-		case "Permute":
-			state := [3]frontend.Variable{vars[cs.Args[0][0]], vars[cs.Args[1][0]], vars[cs.Args[2][0]]}
-			hashAPI.PermuteMut(&state)
-			vars[cs.Args[0][0]] = state[0]
-			vars[cs.Args[1][0]] = state[1]
-			vars[cs.Args[2][0]] = state[2]
-		case "PermuteKoalaBear":
-			var state [16]koalabear.Variable
-			for i := 0; i < 16; i++ {
-				state[i] = felts[cs.Args[i][0]]
-			}
-			hashKoalaBearAPI.PermuteMut(&state)
-			for i := 0; i < 16; i++ {
-				felts[cs.Args[i][0]] = state[i]
-			}
-		case "SelectV":
-			vars[cs.Args[0][0]] = api.Select(vars[cs.Args[1][0]], vars[cs.Args[2][0]], vars[cs.Args[3][0]])
-		case "SelectF":
-			felts[cs.Args[0][0]] = fieldAPI.SelectF(vars[cs.Args[1][0]], felts[cs.Args[2][0]], felts[cs.Args[3][0]])
-		case "SelectE":
-			exts[cs.Args[0][0]] = fieldAPI.SelectE(vars[cs.Args[1][0]], exts[cs.Args[2][0]], exts[cs.Args[3][0]])
-		case "Ext2Felt":
-			out := fieldAPI.Ext2Felt(exts[cs.Args[4][0]])
-			for i := 0; i < 4; i++ {
-				felts[cs.Args[i][0]] = out[i]
-			}
-		case "AssertEqV":
-			api.AssertIsEqual(vars[cs.Args[0][0]], vars[cs.Args[1][0]])
-		case "AssertEqF":
-			fieldAPI.AssertIsEqualF(felts[cs.Args[0][0]], felts[cs.Args[1][0]])
-		case "AssertNeF":
-			fieldAPI.AssertNotEqualF(felts[cs.Args[0][0]], felts[cs.Args[1][0]])
-		case "AssertEqE":
-			fieldAPI.AssertIsEqualE(exts[cs.Args[0][0]], exts[cs.Args[1][0]])
-		case "PrintV":
-			api.Println(vars[cs.Args[0][0]])
-		case "PrintF":
-			f := fieldAPI.ReduceSlow(felts[cs.Args[0][0]])
-			api.Println(f.Value)
-		case "PrintE":
-			e := fieldAPI.ReduceE(exts[cs.Args[0][0]])
-			api.Println(e.Value[0].Value)
-			api.Println(e.Value[1].Value)
-			api.Println(e.Value[2].Value)
-			api.Println(e.Value[3].Value)
-		case "WitnessV":
-			i, err := strconv.Atoi(cs.Args[1][0])
-			if err != nil {
-				panic(err)
-			}
-			vars[cs.Args[0][0]] = circuit.Vars[i]
-		case "WitnessF":
-			i, err := strconv.Atoi(cs.Args[1][0])
-			if err != nil {
-				panic(err)
-			}
-			felts[cs.Args[0][0]] = circuit.Felts[i]
-		case "WitnessE":
-			i, err := strconv.Atoi(cs.Args[1][0])
-			if err != nil {
-				panic(err)
-			}
-			exts[cs.Args[0][0]] = circuit.Exts[i]
-		case "CommitVkeyHash":
-			element := vars[cs.Args[0][0]]
-			api.AssertIsEqual(circuit.VkeyHash, element)
-		case "CommitCommitedValuesDigest":
-			element := vars[cs.Args[0][0]]
-			api.AssertIsEqual(circuit.CommittedValuesDigest, element)
-		case "CircuitFelts2Ext":
-			exts[cs.Args[0][0]] = koalabear.Felts2Ext(felts[cs.Args[1][0]], felts[cs.Args[2][0]], felts[cs.Args[3][0]], felts[cs.Args[4][0]])
-		case "CircuitFelt2Var":
-			vars[cs.Args[0][0]] = fieldAPI.ReduceSlow(felts[cs.Args[1][0]]).Value
-		case "ReduceE":
-			exts[cs.Args[0][0]] = fieldAPI.ReduceE(exts[cs.Args[0][0]])
-		default:
-			return fmt.Errorf("unhandled opcode: %s", cs.Opcode)
-		}
-	}
+func (field) FeltValue(f koalabear.Variable) frontend.Variable {
+	return f.Value
+}
+
+func (field) ExtValues(e koalabear.ExtensionVariable) [4]frontend.Variable {
+	return [4]frontend.Variable{e.Value[0].Value, e.Value[1].Value, e.Value[2].Value, e.Value[3].Value}
+}
+
+func (field) PermuteOpcode() string {
+	return "PermuteKoalaBear"
+}
+
+func (f field) Permute(state *[16]koalabear.Variable) {
+	f.hash.PermuteMut(state)
+}
 
-	return nil
+// NativeField is the KoalaBear verifier_core.NativeField, checking witnesses outside
+// the circuit.
+var NativeField = verifier_core.NativeField{
+	Modulus:       koalabear_native.Modulus,
+	W:             koalabear_native.ExtW,
+	PermuteOpcode: field{}.PermuteOpcode(),
+	Permute:       poseidon2.PermuteKoalaBear,
 }
@@ -4,11 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/brevis-network/pico/gnark/verifier_core"
 	"github.com/consensys/gnark-crypto/ecc"
 	bn254_fr "github.com/consensys/gnark-crypto/ecc/bn254/fr"
 	"github.com/consensys/gnark/backend"
 	"github.com/consensys/gnark/backend/groth16"
 	groth16_bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"github.com/consensys/gnark/constraint"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/frontend/cs/r1cs"
 	"github.com/consensys/gnark/logger"
@@ -17,7 +19,10 @@ import (
 	"github.com/rs/zerolog"
 	"golang.org/x/crypto/sha3"
 	"log"
+	"math/big"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -54,8 +59,8 @@ func doSolve(assert *test.Assert) (circuit *Circuit, assigment *Circuit) {
 	var inputs utils.WitnessInput
 	err = json.Unmarshal(data, &inputs)
 	assert.NoError(err)
-	assigment = NewCircuit(inputs)
-	circuit = NewCircuit(inputs)
+	assigment = NewCircuit(inputs, verifier_core.Options{})
+	circuit = NewCircuit(inputs, verifier_core.Options{})
 
 	err = test.IsSolved(circuit, assigment, ecc.BN254.ScalarField())
 	assert.NoError(err)
@@ -130,3 +135,546 @@ func TestVerifyProof(t *testing.T) {
 	err = groth16_bn254.Verify(&bn254Proof, &bn254Vk, pubWitness)
 	assert.NoError(err)
 }
+
+func TestFriConfigConstraints(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	constraintsFile := filepath.Join(t.TempDir(), "constraints.json")
+	err := os.WriteFile(constraintsFile, []byte(`{
+		"fri_config": {"log_blowup": 1, "num_queries": 100, "proof_of_work_bits": 16},
+		"constraints": [
+			{"opcode": "ImmV", "args": [["q"], ["100"]]},
+			{"opcode": "AssertEqV", "args": [["q"], ["q"]]}
+		]
+	}`), 0644)
+	assert.NoError(err)
+	t.Setenv("CONSTRAINTS_JSON", constraintsFile)
+
+	inputs := utils.WitnessInput{
+		VkeyHash:              "1",
+		CommittedValuesDigest: "2",
+		FriConfig:             &utils.FriConfig{LogBlowup: 1, NumQueries: 100, ProofOfWorkBits: 16},
+	}
+	assert.NoError(test.IsSolved(NewCircuit(inputs, verifier_core.Options{}), NewCircuit(inputs, verifier_core.Options{}), ecc.BN254.ScalarField()))
+
+	inputs.FriConfig = &utils.FriConfig{LogBlowup: 2, NumQueries: 50, ProofOfWorkBits: 16}
+	assert.Error(test.IsSolved(NewCircuit(inputs, verifier_core.Options{}), NewCircuit(inputs, verifier_core.Options{}), ecc.BN254.ScalarField()))
+}
+
+func TestMultiCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	constraintsFile := filepath.Join(t.TempDir(), "constraints.json")
+	err := os.WriteFile(constraintsFile, []byte(`[
+		{"opcode": "WitnessV", "args": [["vk"], ["0"]]},
+		{"opcode": "CommitVkeyHash", "args": [["vk"]]},
+		{"opcode": "WitnessV", "args": [["digest"], ["1"]]},
+		{"opcode": "CommitCommitedValuesDigest", "args": [["digest"]]}
+	]`), 0644)
+	assert.NoError(err)
+	t.Setenv("CONSTRAINTS_JSON", constraintsFile)
+
+	data := []byte(`{"chunks": [
+		{"vars": ["7", "100"], "vkey_hash": "7", "committed_values_digest": "100"},
+		{"vars": ["7", "200"], "vkey_hash": "0x7", "committed_values_digest": "200"}
+	]}`)
+	single, multi, err := utils.ParseWitness(data)
+	assert.NoError(err)
+	assert.Nil(single)
+
+	circuit, err := NewMultiCircuit(*multi, verifier_core.Options{})
+	assert.NoError(err)
+	assignment, err := NewMultiCircuit(*multi, verifier_core.Options{})
+	assert.NoError(err)
+	assert.NoError(test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()))
+	assert.Equal(2, circuit.NbChunks())
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
+	assert.NoError(err)
+	assert.Equal(4, ccs.GetNbPublicVariables())
+
+	assignment.CommittedValuesDigests[1] = "100"
+	assert.Error(test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()))
+
+	multi.Chunks[1].VkeyHash = "8"
+	_, err = NewMultiCircuit(*multi, verifier_core.Options{})
+	assert.Error(err)
+}
+
+func TestAggregateCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+	writeCommitConstraints(t)
+
+	data := []byte(`{"allowed_vkeys": ["7", "8", "9"], "chunks": [
+		{"vars": ["7", "100"], "vkey_hash": "7", "committed_values_digest": "100"},
+		{"vars": ["9", "200"], "vkey_hash": "9", "committed_values_digest": "200"}
+	]}`)
+	_, multi, err := utils.ParseWitness(data)
+	assert.NoError(err)
+
+	circuit, err := NewAggregateCircuit(*multi, verifier_core.Options{})
+	assert.NoError(err)
+	assignment, err := NewAggregateCircuit(*multi, verifier_core.Options{})
+	assert.NoError(err)
+	assert.NoError(test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()))
+	assert.Equal(2, circuit.NbChunks())
+	assert.Equal(4, circuit.VkeyTreeSize())
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
+	assert.NoError(err)
+	assert.Equal(5, ccs.GetNbPublicVariables(), "the one, two root limbs and two digests")
+
+	// the root binds the allowed vkeys
+	assignment.VkeysRoot[1] = 0
+	assert.Error(test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()))
+
+	multi.Chunks[1].VkeyHash = "10"
+	_, err = NewAggregateCircuit(*multi, verifier_core.Options{})
+	assert.ErrorContains(err, "not an allowed vkey")
+	_, err = NewAggregateCircuit(utils.MultiWitnessInput{Chunks: multi.Chunks[:1]}, verifier_core.Options{})
+	assert.Error(err)
+}
+
+// writeCommitConstraints writes constraints committing to var 0 as the vkey hash and
+// var 1 as the committed values digest.
+func writeCommitConstraints(t *testing.T) {
+	constraintsFile := filepath.Join(t.TempDir(), "constraints.json")
+	err := os.WriteFile(constraintsFile, []byte(`[
+		{"opcode": "WitnessV", "args": [["vk"], ["0"]]},
+		{"opcode": "CommitVkeyHash", "args": [["vk"]]},
+		{"opcode": "WitnessV", "args": [["digest"], ["1"]]},
+		{"opcode": "CommitCommitedValuesDigest", "args": [["digest"]]}
+	]`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONSTRAINTS_JSON", constraintsFile)
+}
+
+func TestLayoutCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+	writeCommitConstraints(t)
+
+	vkeyHash, _ := new(big.Int).SetString("0x1b2c3d4e5f60718293a4b5c6d7e8f90112233445566778899aabbccddeeff00", 0)
+	digest, _ := new(big.Int).SetString("0x2a1b2c3d4e5f60718293a4b5c6d7e8f90112233445566778899aabbccddeeff", 0)
+	input := utils.WitnessInput{
+		Vars:                  []string{vkeyHash.String(), digest.String()},
+		VkeyHash:              vkeyHash.String(),
+		CommittedValuesDigest: digest.String(),
+	}
+
+	for _, layout := range []utils.PublicLayout{
+		{DigestFirst: true},
+		{DigestBits: 253},
+		{Limbs: true},
+		{DigestFirst: true, DigestBits: 248, Limbs: true},
+	} {
+		circuit, err := NewLayoutCircuit(input, layout, verifier_core.Options{})
+		assert.NoError(err)
+		assignment, err := NewLayoutCircuit(input, layout, verifier_core.Options{})
+		assert.NoError(err)
+		assert.Equal(layout.NbPublicInputs(), len(assignment.PublicInputs))
+		assert.NoError(test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()), layout.String())
+
+		public := layout.Pack(vkeyHash, digest)
+		gotHash, gotDigest, err := layout.Unpack(public)
+		assert.NoError(err)
+		assert.Equal(0, gotHash.Cmp(vkeyHash))
+		truncated := new(big.Int).Set(digest)
+		if layout.DigestBits > 0 {
+			truncated.Mod(truncated, new(big.Int).Lsh(big.NewInt(1), uint(layout.DigestBits)))
+		}
+		assert.Equal(0, gotDigest.Cmp(truncated))
+
+		// another digest does not solve
+		assignment.PublicInputs[len(assignment.PublicInputs)-1] = 1
+		assert.Error(test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()), layout.String())
+	}
+}
+
+// readRustWitness reads the witness the Rust prover writes to dir of utils/testdata,
+// with its public values.
+func readRustWitness(t *testing.T, dir string) utils.WitnessInput {
+	data, err := utils.ReadWitnessJson(filepath.Join("../utils/testdata", dir, "groth16_witness.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	single, _, err := utils.ParseWitness(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *single
+}
+
+func TestRustWitnessLayout(t *testing.T) {
+	assert := test.NewAssert(t)
+	writeCommitConstraints(t)
+
+	for _, dir := range []string{"rust_witness", "rust_witness_pv_file"} {
+		input := readRustWitness(t, dir)
+		for _, layout := range []utils.PublicLayout{
+			{PublicValues: 2},
+			{Limbs: true, PublicValues: 2, PublicValuesRoot: true},
+		} {
+			circuit, err := NewLayoutCircuit(input, layout, verifier_core.Options{})
+			assert.NoError(err)
+			assignment, err := NewLayoutCircuit(input, layout, verifier_core.Options{})
+			assert.NoError(err)
+			assert.NoError(test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()), dir+" "+layout.String())
+		}
+	}
+}
+
+func TestRustWitnessKeccakDigest(t *testing.T) {
+	assert := test.NewAssert(t)
+	writeCommitConstraints(t)
+
+	layout := utils.PublicLayout{PublicValues: 2, KeccakDigest: true}
+	for _, dir := range []string{"rust_witness", "rust_witness_pv_file"} {
+		input := readRustWitness(t, dir)
+		circuit, err := NewLayoutCircuit(input, layout, verifier_core.Options{})
+		assert.NoError(err)
+		assignment, err := NewLayoutCircuit(input, layout, verifier_core.Options{})
+		assert.NoError(err)
+		assert.NoError(test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()), dir)
+
+		// the exposed digest is the keccak256 of the values of the witness, top 3 bits
+		// dropped, in place of the sha256 the proof commits to
+		values, err := utils.DecodePublicValues(input.PublicValues)
+		assert.NoError(err)
+		hash := sha3.NewLegacyKeccak256()
+		hash.Write(values)
+		keccak := hash.Sum(nil)
+		keccak[0] &= 0x1f
+		assert.Equal(0, new(big.Int).SetBytes(keccak).Cmp(assignment.PublicInputs[1].(*big.Int)), dir)
+		assert.NotEqual(input.CommittedValuesDigest, assignment.PublicInputs[1].(*big.Int).String())
+
+		assignment.PublicInputs[1] = input.CommittedValuesDigest
+		assert.Error(test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()), dir)
+	}
+}
+
+func TestCircuitOptions(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	var muls []string
+	for i := 0; i < 64; i++ {
+		muls = append(muls, `{"opcode": "MulE", "args": [["x"], ["x"], ["y"]]}`)
+	}
+	constraintsFile := filepath.Join(t.TempDir(), "constraints.json")
+	assert.NoError(os.WriteFile(constraintsFile, []byte(`[
+		{"opcode": "WitnessE", "args": [["x"], ["0"]]},
+		{"opcode": "WitnessE", "args": [["y"], ["1"]]},
+		`+strings.Join(muls, ",\n")+`,
+		{"opcode": "Ext2Felt", "args": [["a"], ["b"], ["c"], ["d"], ["x"]]},
+		{"opcode": "WitnessV", "args": [["vk"], ["0"]]},
+		{"opcode": "CommitVkeyHash", "args": [["vk"]]},
+		{"opcode": "CircuitFelt2Var", "args": [["digest"], ["a"]]},
+		{"opcode": "CommitCommitedValuesDigest", "args": [["digest"]]}
+	]`), 0644))
+	t.Setenv("CONSTRAINTS_JSON", constraintsFile)
+	file, err := utils.ReadConstraints(constraintsFile)
+	assert.NoError(err)
+
+	inputs := utils.WitnessInput{
+		Vars: []string{"7"},
+		Exts: [][]string{{"1", "2", "3", "4"}, {"7", "0", "11", "2130706432"}},
+	}
+	commitments, err := NativeField.Evaluate(file, inputs)
+	assert.NoError(err)
+	inputs.VkeyHash = commitments.VkeyHash.String()
+	inputs.CommittedValuesDigest = commitments.CommittedValuesDigest.String()
+
+	counts := make(map[verifier_core.Options]int)
+	for _, opts := range []verifier_core.Options{{}, {LookupRangeCheck: true}} {
+		assert.NoError(test.IsSolved(NewCircuit(inputs, opts), NewCircuit(inputs, opts), ecc.BN254.ScalarField()))
+		ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, NewCircuit(inputs, opts))
+		assert.NoError(err)
+		counts[opts] = ccs.GetNbConstraints()
+		assert.Equal(opts.LookupRangeCheck, len(ccs.GetCommitments().(constraint.Groth16Commitments)) > 0, "%+v", opts)
+	}
+	t.Logf("constraints: bits %d, lookup %d", counts[verifier_core.Options{}], counts[verifier_core.Options{LookupRangeCheck: true}])
+	assert.Less(counts[verifier_core.Options{LookupRangeCheck: true}], counts[verifier_core.Options{}])
+}
+
+func TestConstantFolding(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// the challenger of the Rust recursion DSL starts from a state of 16 ImmF zeros;
+	// permuting it before anything is observed permutes a constant state
+	var imms, lanes []string
+	for i := 0; i < 16; i++ {
+		imms = append(imms, fmt.Sprintf(`{"opcode": "ImmF", "args": [["s%d"], ["0"]]}`, i))
+		lanes = append(lanes, fmt.Sprintf(`["s%d"]`, i))
+	}
+	constraintsFile := filepath.Join(t.TempDir(), "constraints.json")
+	assert.NoError(os.WriteFile(constraintsFile, []byte(`[
+		`+strings.Join(imms, ",\n")+`,
+		{"opcode": "PermuteKoalaBear", "args": [`+strings.Join(lanes, ", ")+`]},
+		{"opcode": "WitnessV", "args": [["vk"], ["0"]]},
+		{"opcode": "CommitVkeyHash", "args": [["vk"]]},
+		{"opcode": "CircuitFelt2Var", "args": [["digest"], ["s0"]]},
+		{"opcode": "CommitCommitedValuesDigest", "args": [["digest"]]}
+	]`), 0644))
+	t.Setenv("CONSTRAINTS_JSON", constraintsFile)
+	file, err := utils.ReadConstraints(constraintsFile)
+	assert.NoError(err)
+
+	inputs := utils.WitnessInput{Vars: []string{"7"}}
+	commitments, err := NativeField.Evaluate(file, inputs)
+	assert.NoError(err)
+	inputs.VkeyHash = commitments.VkeyHash.String()
+	inputs.CommittedValuesDigest = commitments.CommittedValuesDigest.String()
+
+	var counts [2]int
+	for i, opts := range []verifier_core.Options{{}, {Poseidon2ConstantFolding: true}} {
+		assert.NoError(test.IsSolved(NewCircuit(inputs, opts), NewCircuit(inputs, opts), ecc.BN254.ScalarField()))
+		ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, NewCircuit(inputs, opts))
+		assert.NoError(err)
+		counts[i] = ccs.GetNbConstraints()
+	}
+	t.Logf("constraints: %d, folded %d", counts[0], counts[1])
+	assert.Less(counts[1], counts[0])
+}
+
+// appCircuit combines a Pico proof with its own constraint on the committed values
+// digest, which it exposes.
+type appCircuit struct {
+	Digest frontend.Variable `gnark:",public"`
+	Proof  *EmbeddedProof
+}
+
+func (c *appCircuit) Define(api frontend.API) error {
+	values, err := VerifyPicoProof(api, c.Proof)
+	if err != nil {
+		return err
+	}
+	api.AssertIsEqual(c.Digest, values.CommittedValuesDigest)
+	api.AssertIsDifferent(values.VkeyHash, 0)
+	return nil
+}
+
+func TestEmbeddedProof(t *testing.T) {
+	assert := test.NewAssert(t)
+	writeCommitConstraints(t)
+
+	input := utils.WitnessInput{Vars: []string{"3", "5"}, VkeyHash: "3", CommittedValuesDigest: "5"}
+
+	circuit := &appCircuit{Proof: NewEmbeddedProof(input, verifier_core.Options{})}
+	assignment := &appCircuit{Digest: 5, Proof: NewEmbeddedProof(input, verifier_core.Options{})}
+	assert.NoError(test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()))
+
+	// the proof must hold
+	assignment.Proof.Chunk.Vars[1] = 6
+	assert.Error(test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()))
+
+	// and so must the application's own constraints
+	assignment = &appCircuit{Digest: 6, Proof: NewEmbeddedProof(input, verifier_core.Options{})}
+	assert.Error(test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()))
+}
+
+// challengerCircuit draws a challenge with the verifier's chips after observing
+// Inputs.
+type challengerCircuit struct {
+	Inputs [3]frontend.Variable
+	Sample frontend.Variable
+
+	opts verifier_core.Options
+}
+
+func (c *challengerCircuit) Define(api frontend.API) error {
+	challenger := NewChallenger(api, c.opts)
+	challenger.Observe(c.Inputs[:]...)
+	api.AssertIsEqual(challenger.Sample(), c.Sample)
+	return nil
+}
+
+func TestChallenger(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// the first observation and sample of the second vector of
+	// ../challenger/testdata/challenger_vectors.json
+	inputs := [3]frontend.Variable{453488309, 520945533, 978294481}
+	for _, opts := range []verifier_core.Options{{}, {LookupRangeCheck: true}, {Poseidon2ConstantFolding: true}} {
+		circuit := &challengerCircuit{opts: opts}
+		assert.NoError(test.IsSolved(circuit, &challengerCircuit{Inputs: inputs, Sample: 1259093927}, ecc.BN254.ScalarField()), "%+v", opts)
+		assert.Error(test.IsSolved(circuit, &challengerCircuit{Inputs: inputs, Sample: 1259093928}, ecc.BN254.ScalarField()), "%+v", opts)
+	}
+}
+
+// blockStatement asserts that the first word of the public values, an ABI uint64
+// block number, is at least min and that the second is true.
+func blockStatement(min uint64) verifier_core.Statement {
+	return verifier_core.StatementFunc(func(api frontend.API, values *verifier_core.StatementValues) error {
+		number, err := values.Uint(0, 64)
+		if err != nil {
+			return err
+		}
+		api.AssertIsLessOrEqual(min, number)
+		ok, err := values.Bool(1)
+		if err != nil {
+			return err
+		}
+		api.AssertIsEqual(ok, 1)
+		return nil
+	})
+}
+
+type statementCircuit struct {
+	Proof *EmbeddedProof
+}
+
+func (c *statementCircuit) Define(api frontend.API) error {
+	_, err := VerifyPicoProof(api, c.Proof)
+	return err
+}
+
+func TestEmbeddedProofStatement(t *testing.T) {
+	assert := test.NewAssert(t)
+	writeCommitConstraints(t)
+
+	publicValues := make([]byte, 64)
+	big.NewInt(1000).FillBytes(publicValues[:32])
+	publicValues[63] = 1
+	digest := utils.CommittedValuesDigest(publicValues)
+	input := utils.WitnessInput{Vars: []string{"3", digest.String()}, VkeyHash: "3", CommittedValuesDigest: digest.String(),
+		PublicValues: fmt.Sprintf("0x%x", publicValues)}
+	solve := func(statement verifier_core.Statement, input utils.WitnessInput) error {
+		circuit := &statementCircuit{Proof: NewEmbeddedProof(input, verifier_core.Options{}, statement)}
+		assignment := &statementCircuit{Proof: NewEmbeddedProof(input, verifier_core.Options{}, statement)}
+		return test.IsSolved(circuit, assignment, ecc.BN254.ScalarField())
+	}
+
+	assert.NoError(solve(blockStatement(900), input))
+	assert.NoError(solve(blockStatement(1000), input))
+	assert.Error(solve(blockStatement(1001), input), "block number below the bound")
+
+	// the values must be those the proof commits to
+	tampered := input
+	big.NewInt(2000).FillBytes(publicValues[:32])
+	tampered.PublicValues = fmt.Sprintf("0x%x", publicValues)
+	assert.Error(solve(blockStatement(900), tampered))
+
+	noValues := input
+	noValues.PublicValues = ""
+	assert.ErrorContains(solve(blockStatement(900), noValues), "statements need the public values")
+	assert.ErrorContains(solve(verifier_core.StatementFunc(func(api frontend.API, values *verifier_core.StatementValues) error {
+		_, err := values.Uint(2, 64)
+		return err
+	}), input), "no word 2")
+}
+
+func TestRustWitnessStatement(t *testing.T) {
+	assert := test.NewAssert(t)
+	writeCommitConstraints(t)
+
+	// the public values of the Rust witnesses are block 1000 and true
+	for _, dir := range []string{"rust_witness", "rust_witness_pv_file"} {
+		input := readRustWitness(t, dir)
+		for bound, ok := range map[uint64]bool{1000: true, 1001: false} {
+			circuit := &statementCircuit{Proof: NewEmbeddedProof(input, verifier_core.Options{}, blockStatement(bound))}
+			assignment := &statementCircuit{Proof: NewEmbeddedProof(input, verifier_core.Options{}, blockStatement(bound))}
+			err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField())
+			if ok {
+				assert.NoError(err, dir)
+			} else {
+				assert.Error(err, dir)
+			}
+		}
+	}
+}
+
+func TestNativeField(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	permute := make([]string, 16)
+	for i := range permute {
+		permute[i] = fmt.Sprintf(`["p%d"]`, i)
+	}
+	bits := make([]string, 31)
+	for i := range bits {
+		bits[i] = fmt.Sprintf(`"b%d"`, i)
+	}
+	constraints := `[
+		{"opcode": "WitnessF", "args": [["a"], ["0"]]},
+		{"opcode": "WitnessF", "args": [["b"], ["1"]]},
+		{"opcode": "WitnessE", "args": [["x"], ["0"]]},
+		{"opcode": "WitnessE", "args": [["y"], ["1"]]},
+		{"opcode": "WitnessV", "args": [["v"], ["0"]]},
+		{"opcode": "AssertNeF", "args": [["a"], ["b"]]},
+		{"opcode": "DivF", "args": [["c"], ["a"], ["b"]]},
+		{"opcode": "SubF", "args": [["c"], ["c"], ["b"]]},
+		{"opcode": "MulE", "args": [["m"], ["x"], ["y"]]},
+		{"opcode": "InvE", "args": [["i"], ["m"]]},
+		{"opcode": "DivE", "args": [["d"], ["x"], ["y"]]},
+		{"opcode": "AddEF", "args": [["e"], ["d"], ["c"]]},
+		{"opcode": "SubEF", "args": [["e"], ["e"], ["a"]]},
+		{"opcode": "MulEF", "args": [["e"], ["e"], ["b"]]},
+		{"opcode": "DivEF", "args": [["e"], ["e"], ["a"]]},
+		{"opcode": "NegE", "args": [["e"], ["e"]]},
+		{"opcode": "SubE", "args": [["e"], ["e"], ["x"]]},
+		{"opcode": "AddE", "args": [["e"], ["e"], ["i"]]},
+		{"opcode": "ImmE", "args": [["k"], ["1", "2", "3", "2130706432"]]},
+		{"opcode": "MulE", "args": [["e"], ["e"], ["k"]]},
+		{"opcode": "ReduceE", "args": [["e"]]},
+		{"opcode": "Ext2Felt", "args": [["p0"], ["p1"], ["p2"], ["p3"], ["e"]]},
+		{"opcode": "CircuitFelts2Ext", "args": [["f"], ["p3"], ["p2"], ["p1"], ["p0"]]},
+		{"opcode": "MulE", "args": [["g"], ["f"], ["e"]]},
+		{"opcode": "Ext2Felt", "args": [["p4"], ["p5"], ["p6"], ["p7"], ["g"]]},
+		{"opcode": "ImmF", "args": [["p8"], ["2130706432"]]},
+		{"opcode": "AddF", "args": [["p9"], ["p8"], ["c"]]},
+		{"opcode": "MulF", "args": [["p10"], ["p9"], ["p7"]]},
+		{"opcode": "ImmF", "args": [["z"], ["0"]]},
+		{"opcode": "Ext2Felt", "args": [["p11"], ["p12"], ["p13"], ["p14"], ["i"]]},
+		{"opcode": "AddF", "args": [["p15"], ["z"], ["z"]]},
+		{"opcode": "PermuteKoalaBear", "args": [` + strings.Join(permute, ", ") + `]},
+		{"opcode": "Num2BitsF", "args": [[` + strings.Join(bits, ", ") + `], ["p0"]]},
+		{"opcode": "SelectF", "args": [["s"], ["b0"], ["p1"], ["p2"]]},
+		{"opcode": "SelectE", "args": [["se"], ["b1"], ["e"], ["g"]]},
+		{"opcode": "AssertEqE", "args": [["se"], ["se"]]},
+		{"opcode": "CircuitFelt2Var", "args": [["w"], ["s"]]},
+		{"opcode": "ImmV", "args": [["zero"], ["0"]]},
+		{"opcode": "Permute", "args": [["w"], ["v"], ["zero"]]},
+		{"opcode": "MulV", "args": [["w"], ["w"], ["v"]]},
+		{"opcode": "CommitVkeyHash", "args": [["w"]]},
+		{"opcode": "WitnessV", "args": [["small"], ["1"]]},
+		{"opcode": "Num2BitsV", "args": [["v0", "v1", "v2"], ["small"], ["8"]]},
+		{"opcode": "SubV", "args": [["t"], ["w"], ["zero"]]},
+		{"opcode": "AddV", "args": [["t"], ["zero"], ["small"]]},
+		{"opcode": "SelectV", "args": [["sv"], ["v1"], ["t"], ["v"]]},
+		{"opcode": "AssertEqV", "args": [["sv"], ["sv"]]},
+		{"opcode": "CircuitFelt2Var", "args": [["digest"], ["p10"]]},
+		{"opcode": "AddV", "args": [["digest"], ["digest"], ["sv"]]},
+		{"opcode": "CommitCommitedValuesDigest", "args": [["digest"]]}
+	]`
+	constraintsFile := filepath.Join(t.TempDir(), "constraints.json")
+	assert.NoError(os.WriteFile(constraintsFile, []byte(constraints), 0644))
+	t.Setenv("CONSTRAINTS_JSON", constraintsFile)
+	file, err := utils.ReadConstraints(constraintsFile)
+	assert.NoError(err)
+
+	inputs := utils.WitnessInput{
+		Vars:  []string{"12345678901234567890", "6"},
+		Felts: []string{"5", "2130706430"},
+		Exts:  [][]string{{"1", "2", "3", "4"}, {"7", "0", "11", "2130706432"}},
+	}
+	commitments, err := NativeField.Evaluate(file, inputs)
+	assert.NoError(err)
+	inputs.VkeyHash = commitments.VkeyHash.String()
+	inputs.CommittedValuesDigest = commitments.CommittedValuesDigest.String()
+	assert.NoError(test.IsSolved(NewCircuit(inputs, verifier_core.Options{}), NewCircuit(inputs, verifier_core.Options{}), ecc.BN254.ScalarField()))
+
+	inputs.CommittedValuesDigest = "1"
+	assert.Error(test.IsSolved(NewCircuit(inputs, verifier_core.Options{}), NewCircuit(inputs, verifier_core.Options{}), ecc.BN254.ScalarField()))
+
+	// the evaluation fails where the circuit cannot be solved
+	inputs.Felts[1] = "5"
+	_, err = NativeField.Evaluate(file, inputs)
+	assert.ErrorContains(err, "constraint 5 (AssertNeF): both are 5")
+	inputs.Felts[1] = "0"
+	_, err = NativeField.Evaluate(file, inputs)
+	assert.ErrorContains(err, "constraint 6 (DivF): inverse of zero")
+	inputs.Felts[1], inputs.Vars[1] = "2130706430", "256"
+	_, err = NativeField.Evaluate(file, inputs)
+	assert.ErrorContains(err, "var 256 does not fit in 8 bits")
+}
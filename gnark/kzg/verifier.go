@@ -0,0 +1,73 @@
+package kzg
+
+import (
+	"fmt"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	kzg_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/kzg"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/algebra/emulated/sw_bn254"
+	stdkzg "github.com/consensys/gnark/std/commitments/kzg"
+	"github.com/consensys/gnark/std/math/emulated"
+)
+
+// The wrapper circuit is defined over the BN254 scalar field, so BN254 commitments
+// and pairings are emulated.
+type (
+	Scalar       = emulated.Element[sw_bn254.ScalarField]
+	Commitment   = stdkzg.Commitment[sw_bn254.G1Affine]
+	OpeningProof = stdkzg.OpeningProof[sw_bn254.ScalarField, sw_bn254.G1Affine]
+	VerifyingKey = stdkzg.VerifyingKey[sw_bn254.G1Affine, sw_bn254.G2Affine]
+)
+
+// Verifier checks KZG opening proofs over BN254 inside a circuit.
+type Verifier struct {
+	verifier *stdkzg.Verifier[sw_bn254.ScalarField, sw_bn254.G1Affine, sw_bn254.G2Affine, sw_bn254.GTEl]
+}
+
+func NewVerifier(api frontend.API) (*Verifier, error) {
+	verifier, err := stdkzg.NewVerifier[sw_bn254.ScalarField, sw_bn254.G1Affine, sw_bn254.G2Affine, sw_bn254.GTEl](api)
+	if err != nil {
+		return nil, fmt.Errorf("fail to create kzg verifier: %v", err)
+	}
+	return &Verifier{verifier: verifier}, nil
+}
+
+// CheckOpening asserts that the polynomial committed to by commitment evaluates to
+// proof.ClaimedValue at point.
+func (v *Verifier) CheckOpening(commitment Commitment, proof OpeningProof, point Scalar, vk VerifyingKey) error {
+	return v.verifier.CheckOpeningProof(commitment, proof, point, vk)
+}
+
+// CheckOpenings asserts several openings, each at its own point, with a single
+// pairing check.
+func (v *Verifier) CheckOpenings(commitments []Commitment, proofs []OpeningProof, points []Scalar, vk VerifyingKey) error {
+	if len(commitments) != len(proofs) || len(commitments) != len(points) {
+		return fmt.Errorf("got %d commitments, %d proofs and %d points", len(commitments), len(proofs), len(points))
+	}
+	if len(commitments) == 1 {
+		return v.CheckOpening(commitments[0], proofs[0], points[0], vk)
+	}
+	return v.verifier.BatchVerifyMultiPoints(commitments, proofs, points, vk)
+}
+
+func ValueOfScalar(s fr.Element) Scalar {
+	return emulated.ValueOf[sw_bn254.ScalarField](s)
+}
+
+func ValueOfCommitment(digest kzg_bn254.Digest) Commitment {
+	return Commitment{G1El: sw_bn254.NewG1Affine(digest)}
+}
+
+func ValueOfOpeningProof(proof kzg_bn254.OpeningProof) OpeningProof {
+	return OpeningProof{
+		Quotient:     sw_bn254.NewG1Affine(proof.H),
+		ClaimedValue: ValueOfScalar(proof.ClaimedValue),
+	}
+}
+
+func ValueOfVerifyingKey(vk kzg_bn254.VerifyingKey) VerifyingKey {
+	return VerifyingKey{
+		G1: sw_bn254.NewG1Affine(vk.G1),
+		G2: [2]sw_bn254.G2Affine{sw_bn254.NewG2Affine(vk.G2[0]), sw_bn254.NewG2Affine(vk.G2[1])},
+	}
+}
@@ -0,0 +1,62 @@
+package kzg
+
+import (
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	kzg_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/kzg"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"math/big"
+	"testing"
+)
+
+type openingCircuit struct {
+	Commitments []Commitment
+	Proofs      []OpeningProof
+	Points      []Scalar
+	Vk          VerifyingKey
+}
+
+func (c *openingCircuit) Define(api frontend.API) error {
+	verifier, err := NewVerifier(api)
+	if err != nil {
+		return err
+	}
+	return verifier.CheckOpenings(c.Commitments, c.Proofs, c.Points, c.Vk)
+}
+
+func TestCheckOpenings(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	srs, err := kzg_bn254.NewSRS(16, big.NewInt(42))
+	assert.NoError(err)
+
+	for _, n := range []int{1, 2} {
+		circuit := openingCircuit{
+			Commitments: make([]Commitment, n),
+			Proofs:      make([]OpeningProof, n),
+			Points:      make([]Scalar, n),
+		}
+		assignment := openingCircuit{Vk: ValueOfVerifyingKey(srs.Vk)}
+		for i := 0; i < n; i++ {
+			f := make([]fr.Element, 10)
+			for j := range f {
+				f[j].SetRandom()
+			}
+			var point fr.Element
+			point.SetRandom()
+			digest, err := kzg_bn254.Commit(f, srs.Pk)
+			assert.NoError(err)
+			proof, err := kzg_bn254.Open(f, point, srs.Pk)
+			assert.NoError(err)
+
+			assignment.Commitments = append(assignment.Commitments, ValueOfCommitment(digest))
+			assignment.Proofs = append(assignment.Proofs, ValueOfOpeningProof(proof))
+			assignment.Points = append(assignment.Points, ValueOfScalar(point))
+		}
+		assert.NoError(test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()))
+
+		assignment.Proofs[0].ClaimedValue = ValueOfScalar(fr.NewElement(7))
+		assert.Error(test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()))
+	}
+}
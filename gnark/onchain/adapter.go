@@ -0,0 +1,79 @@
+package onchain
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// WritePicoVerifierInterface writes IPicoVerifier, the interface applications verify
+// Pico proofs with, whatever the gnark verifier contract behind it.
+func WritePicoVerifierInterface(w io.Writer) error {
+	_, err := io.WriteString(w, `// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.0;
+
+/// @title Verifier of Pico proofs
+/// @notice Stable interface of the verifier of a Pico program, independent of the gnark export behind it.
+interface IPicoVerifier {
+    /// @notice Reverts unless proof proves the program of vkeyHash committing to the values of digest.
+    /// @param proof The Groth16 proof points, then the commitment and its proof of knowledge if the
+    /// circuit has one, as 32 byte words.
+    function verify(bytes calldata proof, bytes32 vkeyHash, bytes32 digest) external view;
+}
+`)
+	return err
+}
+
+// WritePicoVerifierAdapter writes PicoVerifierAdapter, the IPicoVerifier of a single
+// chunk wrapper calling the gnark verifier contract deployed at the address passed
+// to its constructor. The imports are the paths of IPicoVerifier, of the gnark
+// verifier and of the PicoPublicInputs library of the layout of the wrapper.
+func WritePicoVerifierAdapter(w io.Writer, interfaceImport, verifierImport, publicInputsImport string, hasCommitment bool, nbPublicInputs int) error {
+	size, decode, args := 8*32, "uint256[8] memory p = abi.decode(proof, (uint256[8]));", "p, input"
+	if hasCommitment {
+		size = 12 * 32
+		decode = "(uint256[8] memory p, uint256[2] memory commitments, uint256[2] memory commitmentPok) =\n            abi.decode(proof, (uint256[8], uint256[2], uint256[2]));"
+		args = "p, commitments, commitmentPok, input"
+	}
+	_, err := fmt.Fprintf(w, `// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.0;
+
+import {IPicoVerifier} from %q;
+import {Verifier} from %q;
+import {PicoPublicInputs} from %q;
+
+/// @title IPicoVerifier of the gnark verifier of a Pico wrapper
+contract PicoVerifierAdapter is IPicoVerifier {
+    error InvalidProofLength(uint256 length);
+
+    Verifier public immutable verifier;
+
+    constructor(Verifier _verifier) {
+        verifier = _verifier;
+    }
+
+    function verify(bytes calldata proof, bytes32 vkeyHash, bytes32 digest) external view {
+        if (proof.length != %d) {
+            revert InvalidProofLength(proof.length);
+        }
+        %s
+        uint256[%d] memory input = PicoPublicInputs.pack(uint256(vkeyHash), uint256(digest));
+        verifier.verifyProof(%s);
+    }
+}
+`, interfaceImport, verifierImport, publicInputsImport, size, decode, nbPublicInputs, args)
+	return err
+}
+
+// ProofBytes returns the proof argument of IPicoVerifier.verify: the words of the
+// proof, then those of the commitment and its proof of knowledge if any.
+func (c *ProofCalldata) ProofBytes() []byte {
+	var data []byte
+	for _, w := range c.Proof {
+		data = append(data, word(w)...)
+	}
+	for _, w := range append(append([]*big.Int{}, c.Commitment...), c.CommitmentPok...) {
+		data = append(data, word(w)...)
+	}
+	return data
+}
@@ -0,0 +1,43 @@
+package onchain
+
+import (
+	"bytes"
+	"github.com/consensys/gnark/test"
+	"math/big"
+	"testing"
+)
+
+func TestPicoVerifierAdapter(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	var out bytes.Buffer
+	assert.NoError(WritePicoVerifierInterface(&out))
+	assert.Contains(out.String(), "function verify(bytes calldata proof, bytes32 vkeyHash, bytes32 digest) external view;")
+
+	out.Reset()
+	assert.NoError(WritePicoVerifierAdapter(&out, "./IPicoVerifier.sol", "./Groth16Verifier.sol", "./PicoPublicInputs.sol", false, 2))
+	assert.Contains(out.String(), `import {Verifier} from "./Groth16Verifier.sol";`)
+	assert.Contains(out.String(), "if (proof.length != 256) {")
+	assert.Contains(out.String(), "uint256[2] memory input = PicoPublicInputs.pack(uint256(vkeyHash), uint256(digest));")
+	assert.Contains(out.String(), "verifier.verifyProof(p, input);")
+
+	out.Reset()
+	assert.NoError(WritePicoVerifierAdapter(&out, "./IPicoVerifier.sol", "./Groth16Verifier.sol", "./PicoPublicInputs.sol", true, 4))
+	assert.Contains(out.String(), "if (proof.length != 384) {")
+	assert.Contains(out.String(), "abi.decode(proof, (uint256[8], uint256[2], uint256[2]));")
+	assert.Contains(out.String(), "uint256[4] memory input")
+	assert.Contains(out.String(), "verifier.verifyProof(p, commitments, commitmentPok, input);")
+
+	// the proof argument is the calldata of verifyProof without selector and inputs
+	calldata := &ProofCalldata{
+		Commitment:    []*big.Int{big.NewInt(8), big.NewInt(9)},
+		CommitmentPok: []*big.Int{big.NewInt(10), big.NewInt(11)},
+		Input:         []*big.Int{big.NewInt(7)},
+	}
+	for i := range calldata.Proof {
+		calldata.Proof[i] = big.NewInt(int64(i))
+	}
+	proof := calldata.ProofBytes()
+	assert.Equal(384, len(proof))
+	assert.Equal(calldata.Encode()[4:4+384], proof)
+}
@@ -0,0 +1,183 @@
+// Package onchain builds calls to the exported Groth16 verifier contract and talks to
+// an Ethereum JSON-RPC endpoint to check and submit proofs.
+package onchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark/backend/groth16"
+	groth16_bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"github.com/consensys/gnark/backend/witness"
+	"golang.org/x/crypto/sha3"
+	"math/big"
+	"strings"
+)
+
+// ProofCalldata holds the arguments of verifyProof of the verifier contract exported
+// by gnark, with the proof points in the order the contract expects.
+type ProofCalldata struct {
+	Proof [8]*big.Int
+	// Commitment and CommitmentPok are nil for circuits without commitment.
+	Commitment    []*big.Int
+	CommitmentPok []*big.Int
+	Input         []*big.Int
+}
+
+// NewProofCalldata builds the verifyProof arguments of a BN254 proof.
+func NewProofCalldata(proof groth16.Proof, pubWitness witness.Witness) (*ProofCalldata, error) {
+	p, err := utils.GetAggOnChainProof(proof, pubWitness)
+	if err != nil {
+		return nil, err
+	}
+	res := &ProofCalldata{Input: p.PublicInputs}
+	words := p.Words()
+	copy(res.Proof[:], words[:8])
+	if p.HasCommitment() {
+		res.Commitment = words[8:10]
+		res.CommitmentPok = words[10:12]
+	}
+	return res, nil
+}
+
+// ParseProofFile reads the verifyProof arguments back from a proof file written by
+// prove, in legacy or json format. The legacy format does not say whether the proof
+// has a commitment, so hasCommitment must tell, see HasCommitment.
+func ParseProofFile(data []byte, hasCommitment bool) (*ProofCalldata, error) {
+	text := strings.TrimSpace(string(data))
+	if strings.HasPrefix(text, "0x") && !strings.Contains(text, ",") {
+		return nil, fmt.Errorf("proofs in the gateway format are not read back, prove in the legacy or json format")
+	}
+	if strings.HasPrefix(text, "{") {
+		var proof utils.PicoProof
+		err := json.Unmarshal(data, &proof)
+		if err != nil {
+			return nil, fmt.Errorf("fail to parse json proof: %v", err)
+		}
+		p := proof.Proof
+		words := []string{p.A[0], p.A[1], p.B[0][0], p.B[0][1], p.B[1][0], p.B[1][1], p.C[0], p.C[1]}
+		if p.Commitment[0] != "" {
+			words = append(words, p.Commitment[0], p.Commitment[1], p.CommitmentPok[0], p.CommitmentPok[1])
+		}
+		return parseWords(append(words, proof.PublicInputs...), p.Commitment[0] != "")
+	}
+	return parseWords(strings.Split(text, ","), hasCommitment)
+}
+
+func parseWords(words []string, hasCommitment bool) (*ProofCalldata, error) {
+	values := make([]*big.Int, len(words))
+	for i, word := range words {
+		v, ok := new(big.Int).SetString(strings.TrimPrefix(strings.TrimSpace(word), "0x"), 16)
+		if !ok {
+			return nil, fmt.Errorf("invalid proof word %d: %q", i, word)
+		}
+		values[i] = v
+	}
+
+	head := 8
+	if hasCommitment {
+		head = 12
+	}
+	if len(values) <= head {
+		return nil, fmt.Errorf("proof has %d words, expected more than %d", len(values), head)
+	}
+	res := &ProofCalldata{Input: values[head:]}
+	copy(res.Proof[:], values[:8])
+	if hasCommitment {
+		res.Commitment = values[8:10]
+		res.CommitmentPok = values[10:12]
+	}
+	return res, nil
+}
+
+// Signature is the solidity signature of the verifyProof overload taking c.
+func (c *ProofCalldata) Signature() string {
+	if c.Commitment != nil {
+		return fmt.Sprintf("verifyProof(uint256[8],uint256[2],uint256[2],uint256[%d])", len(c.Input))
+	}
+	return fmt.Sprintf("verifyProof(uint256[8],uint256[%d])", len(c.Input))
+}
+
+// Encode returns the abi encoded call. All arguments are fixed size arrays, so they
+// are encoded in place as 32 byte words.
+func (c *ProofCalldata) Encode() []byte {
+	data := Selector(c.Signature())
+	words := append([]*big.Int{}, c.Proof[:]...)
+	words = append(words, c.Commitment...)
+	words = append(words, c.CommitmentPok...)
+	words = append(words, c.Input...)
+	for _, w := range words {
+		data = append(data, word(w)...)
+	}
+	return data
+}
+
+// Selector is the 4 byte function or error selector of signature.
+func Selector(signature string) []byte {
+	return Keccak256([]byte(signature))[:4]
+}
+
+func Keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+func word(v *big.Int) []byte {
+	w := make([]byte, 32)
+	v.FillBytes(w)
+	return w
+}
+
+// HasCommitment reports whether proofs of vk carry a commitment.
+func HasCommitment(vk groth16.VerifyingKey) bool {
+	bn254Vk, ok := vk.(*groth16_bn254.VerifyingKey)
+	return ok && len(bn254Vk.CommitmentKeys) > 0
+}
+
+// CheckAddress validates a 0x prefixed hex account address.
+func CheckAddress(address string) error {
+	b, err := DecodeHex(address)
+	if err != nil || len(b) != 20 || !strings.HasPrefix(address, "0x") {
+		return fmt.Errorf("invalid address %q", address)
+	}
+	return nil
+}
+
+// EncodeBatch returns the abi encoded verifyProofs call of the PicoBatchVerifier
+// contract verifying calldatas, which must be proofs of the same key without
+// commitment.
+func EncodeBatch(calldatas []*ProofCalldata) ([]byte, error) {
+	if len(calldatas) == 0 {
+		return nil, fmt.Errorf("no proof to verify")
+	}
+	nbPublic := len(calldatas[0].Input)
+	for i, c := range calldatas {
+		if c.Commitment != nil {
+			return nil, fmt.Errorf("proof %d has a commitment, which the batch verifier does not support", i)
+		}
+		if len(c.Input) != nbPublic {
+			return nil, fmt.Errorf("proof %d has %d public inputs, expected %d", i, len(c.Input), nbPublic)
+		}
+	}
+	n := len(calldatas)
+	data := Selector(fmt.Sprintf("verifyProofs(uint256[8][],uint256[%d][])", nbPublic))
+	// the offsets of both arrays, then each array as its length and words
+	data = append(data, word(big.NewInt(2*32))...)
+	data = append(data, word(big.NewInt(int64(3*32+n*8*32)))...)
+	data = append(data, word(big.NewInt(int64(n)))...)
+	for _, c := range calldatas {
+		for _, w := range c.Proof {
+			data = append(data, word(w)...)
+		}
+	}
+	data = append(data, word(big.NewInt(int64(n)))...)
+	for _, c := range calldatas {
+		for _, w := range c.Input {
+			data = append(data, word(w)...)
+		}
+	}
+	return data, nil
+}
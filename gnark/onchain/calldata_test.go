@@ -0,0 +1,97 @@
+package onchain
+
+import (
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"math/big"
+	"testing"
+)
+
+type wrapperCircuit struct {
+	VkeyHash              frontend.Variable `gnark:",public"`
+	CommittedValuesDigest frontend.Variable `gnark:",public"`
+	X                     frontend.Variable
+}
+
+func (c *wrapperCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Mul(c.X, c.X), api.Add(c.VkeyHash, c.CommittedValuesDigest))
+	return nil
+}
+
+func TestProofCalldata(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &wrapperCircuit{})
+	assert.NoError(err)
+	pk, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	fullWitness, err := frontend.NewWitness(&wrapperCircuit{VkeyHash: 7, CommittedValuesDigest: 9, X: 4}, ecc.BN254.ScalarField())
+	assert.NoError(err)
+	pubWitness, err := fullWitness.Public()
+	assert.NoError(err)
+	proof, err := groth16.Prove(ccs, pk, fullWitness)
+	assert.NoError(err)
+
+	calldata, err := NewProofCalldata(proof, pubWitness)
+	assert.NoError(err)
+	assert.Nil(calldata.Commitment)
+	assert.Equal("verifyProof(uint256[8],uint256[2])", calldata.Signature())
+	encoded := calldata.Encode()
+	assert.Equal(4+32*10, len(encoded))
+	assert.Equal(Selector(calldata.Signature()), encoded[:4])
+
+	for _, format := range []string{utils.ProofFormatLegacy, utils.ProofFormatJson} {
+		data, err := utils.FormatProof(format, proof, pubWitness)
+		assert.NoError(err)
+		parsed, err := ParseProofFile(data, HasCommitment(vk))
+		assert.NoError(err)
+		assert.Equal(encoded, parsed.Encode(), format)
+	}
+
+	// the gateway format holds the proof argument of IPicoVerifier.verify, and is not
+	// read back
+	data, err := utils.FormatProof(utils.ProofFormatGateway, proof, pubWitness)
+	assert.NoError(err)
+	gateway, err := DecodeHex(string(data))
+	assert.NoError(err)
+	assert.Equal(calldata.ProofBytes(), gateway[4*32:])
+	_, err = ParseProofFile(data, HasCommitment(vk))
+	assert.ErrorContains(err, "gateway format")
+}
+
+func TestEncodeBatch(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	calldatas := make([]*ProofCalldata, 2)
+	for j := range calldatas {
+		calldatas[j] = &ProofCalldata{Input: []*big.Int{big.NewInt(int64(10 * j)), big.NewInt(int64(10*j + 1))}}
+		for i := range calldatas[j].Proof {
+			calldatas[j].Proof[i] = big.NewInt(int64(100*j + i))
+		}
+	}
+	data, err := EncodeBatch(calldatas)
+	assert.NoError(err)
+	assert.Equal(Selector("verifyProofs(uint256[8][],uint256[2][])"), data[:4])
+	words := data[4:]
+	assert.Equal(32*(2+1+2*8+1+2*2), len(words))
+	wordAt := func(i int) int64 {
+		return new(big.Int).SetBytes(words[32*i : 32*(i+1)]).Int64()
+	}
+	assert.Equal(int64(64), wordAt(0))
+	assert.Equal(int64(32*(3+16)), wordAt(1))
+	assert.Equal(int64(2), wordAt(2))
+	assert.Equal(int64(107), wordAt(3+15))
+	assert.Equal(int64(2), wordAt(19))
+	assert.Equal(int64(11), wordAt(23))
+
+	calldatas[1].Input = calldatas[1].Input[:1]
+	_, err = EncodeBatch(calldatas)
+	assert.ErrorContains(err, "proof 1 has 1 public inputs")
+	calldatas[1].Commitment = []*big.Int{big.NewInt(1), big.NewInt(2)}
+	_, err = EncodeBatch(calldatas)
+	assert.ErrorContains(err, "commitment")
+}
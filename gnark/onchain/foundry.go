@@ -0,0 +1,83 @@
+package onchain
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// WriteFoundryTest writes a Foundry test of the gnark verifier contract imported from
+// verifierImport: it deploys the contract, checks it accepts the proof of c and that
+// it rejects c with a tampered public input, proof point or commitment.
+func WriteFoundryTest(w io.Writer, verifierImport string, c *ProofCalldata) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, `// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.0;
+
+import {Test} from "forge-std/Test.sol";
+import {Verifier} from %q;
+
+/// @notice Generated with the verifier, from a proof of its circuit.
+contract VerifierTest is Test {
+    Verifier verifier;
+
+    function setUp() public {
+        verifier = new Verifier();
+    }
+
+`, verifierImport)
+	writeSolidityArray(&b, "proof", c.Proof[:])
+	if c.Commitment != nil {
+		writeSolidityArray(&b, "commitments", c.Commitment)
+		writeSolidityArray(&b, "commitmentPok", c.CommitmentPok)
+	}
+	writeSolidityArray(&b, "input", c.Input)
+
+	args := "p, i"
+	declare := "        uint256[8] memory p = proof();\n"
+	if c.Commitment != nil {
+		args = "p, cm, pok, i"
+		declare += "        uint256[2] memory cm = commitments();\n        uint256[2] memory pok = commitmentPok();\n"
+	}
+	declare += fmt.Sprintf("        uint256[%d] memory i = input();\n", len(c.Input))
+
+	fmt.Fprintf(&b, `    function test_VerifyProof() public view {
+%s        verifier.verifyProof(%s);
+    }
+
+    function test_RevertWhen_InputTampered() public {
+%s        i[0] ^= 1;
+        vm.expectRevert();
+        verifier.verifyProof(%s);
+    }
+
+    function test_RevertWhen_ProofTampered() public {
+%s        p[0] ^= 1;
+        vm.expectRevert();
+        verifier.verifyProof(%s);
+    }
+`, declare, args, declare, args, declare, args)
+	if c.Commitment != nil {
+		fmt.Fprintf(&b, `
+    function test_RevertWhen_CommitmentTampered() public {
+%s        cm[0] ^= 1;
+        vm.expectRevert();
+        verifier.verifyProof(%s);
+    }
+`, declare, args)
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeSolidityArray writes a function returning values as a fixed size array.
+func writeSolidityArray(b *strings.Builder, name string, values []*big.Int) {
+	fmt.Fprintf(b, "    function %s() internal pure returns (uint256[%d] memory values) {\n", name, len(values))
+	for i, v := range values {
+		fmt.Fprintf(b, "        values[%d] = %s;\n", i, v.String())
+	}
+	b.WriteString("    }\n\n")
+}
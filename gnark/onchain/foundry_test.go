@@ -0,0 +1,34 @@
+package onchain
+
+import (
+	"bytes"
+	"github.com/consensys/gnark/test"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestWriteFoundryTest(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	calldata := &ProofCalldata{Input: []*big.Int{big.NewInt(7), big.NewInt(9)}}
+	for i := range calldata.Proof {
+		calldata.Proof[i] = big.NewInt(int64(100 + i))
+	}
+	var out bytes.Buffer
+	assert.NoError(WriteFoundryTest(&out, "./Groth16Verifier.sol", calldata))
+	assert.Contains(out.String(), `import {Verifier} from "./Groth16Verifier.sol";`)
+	assert.Contains(out.String(), "function proof() internal pure returns (uint256[8] memory values) {\n        values[0] = 100;\n")
+	assert.Contains(out.String(), "function input() internal pure returns (uint256[2] memory values) {\n        values[0] = 7;\n        values[1] = 9;\n")
+	assert.Contains(out.String(), "verifier.verifyProof(p, i);")
+	assert.Equal(2, strings.Count(out.String(), "vm.expectRevert();"))
+	assert.NotContains(out.String(), "commitments")
+
+	calldata.Commitment = []*big.Int{big.NewInt(1), big.NewInt(2)}
+	calldata.CommitmentPok = []*big.Int{big.NewInt(3), big.NewInt(4)}
+	out.Reset()
+	assert.NoError(WriteFoundryTest(&out, "./Groth16Verifier.sol", calldata))
+	assert.Contains(out.String(), "verifier.verifyProof(p, cm, pok, i);")
+	assert.Contains(out.String(), "function test_RevertWhen_CommitmentTampered() public {")
+	assert.Equal(3, strings.Count(out.String(), "vm.expectRevert();"))
+}
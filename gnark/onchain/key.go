@@ -0,0 +1,57 @@
+package onchain
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"strings"
+)
+
+// Key is a secp256k1 account key signing transactions.
+type Key struct {
+	priv    *ecdsa.PrivateKey
+	Address string
+}
+
+// NewKey builds the key of a 32 byte private key.
+func NewKey(secret []byte) (*Key, error) {
+	priv, err := crypto.ToECDSA(secret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key")
+	}
+	return newKey(priv), nil
+}
+
+func newKey(priv *ecdsa.PrivateKey) *Key {
+	return &Key{priv: priv, Address: EncodeHex(crypto.PubkeyToAddress(priv.PublicKey).Bytes())}
+}
+
+// ParseKey reads a hex encoded private key, with or without 0x prefix.
+func ParseKey(s string) (*Key, error) {
+	secret, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(s), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key")
+	}
+	return NewKey(secret)
+}
+
+// DecryptKeystore decrypts a version 3 keystore file as written by geth and clef.
+func DecryptKeystore(keyjson []byte, password string) (*Key, error) {
+	key, err := keystore.DecryptKey(keyjson, password)
+	if err != nil {
+		return nil, fmt.Errorf("fail to decrypt keystore: %v", err)
+	}
+	return newKey(key.PrivateKey), nil
+}
+
+// SignTx signs tx for its chain id.
+func (k *Key) SignTx(tx *types.Transaction) (*types.Transaction, error) {
+	signed, err := types.SignTx(tx, types.LatestSignerForChainID(tx.ChainId()), k.priv)
+	if err != nil {
+		return nil, fmt.Errorf("fail to sign transaction: %v", err)
+	}
+	return signed, nil
+}
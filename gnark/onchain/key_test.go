@@ -0,0 +1,62 @@
+package onchain
+
+import (
+	"github.com/consensys/gnark/test"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestKeyAddress(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	key, err := ParseKey("0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318")
+	assert.NoError(err)
+	assert.Equal(strings.ToLower("0x2c7536E3605D9C16a7a3D7b1898e529396a65c23"), key.Address)
+
+	_, err = ParseKey("0x00")
+	assert.Error(err)
+}
+
+func TestDecryptKeystore(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// test vector of the web3 secret storage definition
+	keystore := `{
+		"crypto": {
+			"cipher": "aes-128-ctr",
+			"cipherparams": {"iv": "6087dab2f9fdbbfaddc31a909735c1e6"},
+			"ciphertext": "5318b4d5bcd28de64ee5559e671353e16f075ecae9f99c7a79a38af5f869aa46",
+			"kdf": "pbkdf2",
+			"kdfparams": {"c": 262144, "dklen": 32, "prf": "hmac-sha256", "salt": "ae3cd4e7013836a3df6bd7241b12db061dbe2c6785853cce422d148a624ce0bd"},
+			"mac": "517ead924a9d0dc3124507e3393d175ce3ff7c1e96529c6c555ce9e51205e9b2"
+		},
+		"id": "3198bc9c-6672-5ab3-d995-4942343ae5b6",
+		"version": 3
+	}`
+	key, err := DecryptKeystore([]byte(keystore), "testpassword")
+	assert.NoError(err)
+	expected, err := ParseKey("7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d")
+	assert.NoError(err)
+	assert.Equal(expected.Address, key.Address)
+
+	_, err = DecryptKeystore([]byte(keystore), "wrong")
+	assert.Error(err)
+}
+
+func TestSignTx(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	key, err := ParseKey("4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318")
+	assert.NoError(err)
+	to := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	for i := 0; i < 8; i++ {
+		tx, err := key.SignTx(types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(1), Nonce: uint64(i), To: &to}))
+		assert.NoError(err)
+		sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+		assert.NoError(err)
+		assert.Equal(key.Address, EncodeHex(sender.Bytes()))
+	}
+}
@@ -0,0 +1,71 @@
+package onchain
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark/backend/groth16"
+	"strconv"
+)
+
+// VerifierMetadata identifies the wrapper circuit of an exported verifier contract,
+// so a deployed verifier can be matched with the keys and the Pico release behind it.
+type VerifierMetadata struct {
+	// VkHash is the 0x prefixed keccak256 of the vk serialization, as printed by vk-hash.
+	VkHash         string
+	CircuitVersion int
+	Field          string
+	GnarkVersion   string
+}
+
+// NewVerifierMetadata describes the verifier of vk, for the wrapper of field built by
+// this binary.
+func NewVerifierMetadata(field string, vk groth16.VerifyingKey) (*VerifierMetadata, error) {
+	vkHash, err := VkHash(vk)
+	if err != nil {
+		return nil, err
+	}
+	return &VerifierMetadata{
+		VkHash:         vkHash,
+		CircuitVersion: utils.WitnessVersion,
+		Field:          field,
+		GnarkVersion:   utils.GnarkVersion(),
+	}, nil
+}
+
+// VkHash returns the 0x prefixed keccak256 of the gnark serialization of vk, without
+// the header of the vk file.
+func VkHash(vk groth16.VerifyingKey) (string, error) {
+	var buf bytes.Buffer
+	_, err := vk.WriteTo(&buf)
+	if err != nil {
+		return "", err
+	}
+	return EncodeHex(Keccak256(buf.Bytes())), nil
+}
+
+// Embed returns the solidity of contract with the metadata added as public constants
+// at the start of its body, readable by on-chain consumers and explorers.
+func (m *VerifierMetadata) Embed(solidity []byte, contract string) ([]byte, error) {
+	start := []byte("contract " + contract + " {\n")
+	i := bytes.Index(solidity, start)
+	if i < 0 {
+		return nil, fmt.Errorf("no contract %s in the solidity", contract)
+	}
+	i += len(start)
+	constants := fmt.Sprintf(`    /// @notice keccak256 of the gnark serialization of the verifying key.
+    bytes32 public constant PICO_VK_HASH = %s;
+    /// @notice Format version of the witnesses of the wrapper circuit.
+    uint256 public constant PICO_CIRCUIT_VERSION = %d;
+    /// @notice Field of the Pico proofs the wrapper verifies: kb (KoalaBear) or bb (BabyBear).
+    string public constant PICO_FIELD = %s;
+    /// @notice Version of gnark the verifier was exported with.
+    string public constant PICO_GNARK_VERSION = %s;
+
+`, m.VkHash, m.CircuitVersion, strconv.Quote(m.Field), strconv.Quote(m.GnarkVersion))
+
+	res := make([]byte, 0, len(solidity)+len(constants))
+	res = append(res, solidity[:i]...)
+	res = append(res, constants...)
+	return append(res, solidity[i:]...), nil
+}
@@ -0,0 +1,43 @@
+package onchain
+
+import (
+	"bytes"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"strings"
+	"testing"
+)
+
+func TestVerifierMetadata(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &wrapperCircuit{})
+	assert.NoError(err)
+	_, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	metadata, err := NewVerifierMetadata("kb", vk)
+	assert.NoError(err)
+	var buf bytes.Buffer
+	_, err = vk.WriteTo(&buf)
+	assert.NoError(err)
+	assert.Equal(EncodeHex(Keccak256(buf.Bytes())), metadata.VkHash)
+	assert.Equal(66, len(metadata.VkHash))
+	assert.Equal(utils.WitnessVersion, metadata.CircuitVersion)
+
+	var out bytes.Buffer
+	assert.NoError(vk.ExportSolidity(&out))
+	solidity, err := metadata.Embed(out.Bytes(), "Verifier")
+	assert.NoError(err)
+	assert.Contains(string(solidity), "contract Verifier {\n    /// @notice keccak256")
+	assert.Contains(string(solidity), "bytes32 public constant PICO_VK_HASH = "+metadata.VkHash+";")
+	assert.Contains(string(solidity), `string public constant PICO_FIELD = "kb";`)
+	assert.Contains(string(solidity), "uint256 public constant PICO_CIRCUIT_VERSION = 1;")
+	assert.True(strings.HasSuffix(string(solidity), out.String()[strings.Index(out.String(), "contract Verifier {\n")+len("contract Verifier {\n"):]))
+
+	_, err = metadata.Embed(out.Bytes(), "PicoBatchVerifier")
+	assert.ErrorContains(err, "no contract PicoBatchVerifier")
+}
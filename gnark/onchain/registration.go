@@ -0,0 +1,76 @@
+package onchain
+
+import (
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark/backend/groth16"
+	"math/big"
+)
+
+// Registration is what registering a Pico program with the Brevis verification
+// gateway takes: the program vkey, the verifier contract checking its wrapper proofs
+// and the wrapper circuit behind that contract.
+type Registration struct {
+	// ProgramVkey is the program vkey hash as the bytes32 riscvVKey of inputs.json.
+	ProgramVkey string          `json:"program_vkey"`
+	Verifier    string          `json:"verifier"`
+	ChainID     string          `json:"chain_id,omitempty"`
+	Circuit     CircuitMetadata `json:"circuit"`
+}
+
+// CircuitMetadata identifies the wrapper circuit and how its verifier is called.
+type CircuitMetadata struct {
+	Field          string `json:"field"`
+	Curve          string `json:"curve"`
+	NbPublicInputs int    `json:"nb_public_inputs"`
+	HasCommitment  bool   `json:"has_commitment"`
+	// VerifyFunction is the solidity signature of the verifyProof overload the
+	// gateway calls, VerifySelector its selector.
+	VerifyFunction string `json:"verify_function"`
+	VerifySelector string `json:"verify_selector"`
+	VkFingerprint  string `json:"vk_fingerprint"`
+}
+
+// NewRegistration builds the registration of the program with vkeyHash, decimal as in
+// the witness or 0x prefixed hex, whose proofs are checked by the verifier contract
+// exported for vk at address verifier.
+func NewRegistration(vkeyHash, verifier string, vk groth16.VerifyingKey) (*Registration, error) {
+	err := CheckAddress(verifier)
+	if err != nil {
+		return nil, err
+	}
+	programVkey, err := Bytes32(vkeyHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vkey hash: %v", err)
+	}
+	fingerprint, err := utils.VerifyingKeyFingerprint(vk)
+	if err != nil {
+		return nil, err
+	}
+
+	call := &ProofCalldata{Input: make([]*big.Int, vk.NbPublicWitness())}
+	if HasCommitment(vk) {
+		call.Commitment = make([]*big.Int, 2)
+	}
+	return &Registration{
+		ProgramVkey: programVkey,
+		Verifier:    verifier,
+		Circuit: CircuitMetadata{
+			Curve:          vk.CurveID().String(),
+			NbPublicInputs: vk.NbPublicWitness(),
+			HasCommitment:  call.Commitment != nil,
+			VerifyFunction: call.Signature(),
+			VerifySelector: EncodeHex(Selector(call.Signature())),
+			VkFingerprint:  fingerprint,
+		},
+	}, nil
+}
+
+// Bytes32 encodes a decimal or 0x prefixed hex number as a 0x prefixed 32 byte word.
+func Bytes32(v string) (string, error) {
+	n, ok := new(big.Int).SetString(v, 0)
+	if !ok || n.Sign() < 0 || n.BitLen() > 256 {
+		return "", fmt.Errorf("%q is not a 32 byte number", v)
+	}
+	return EncodeHex(word(n)), nil
+}
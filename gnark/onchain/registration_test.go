@@ -0,0 +1,34 @@
+package onchain
+
+import (
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"testing"
+)
+
+func TestRegistration(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &wrapperCircuit{})
+	assert.NoError(err)
+	_, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+
+	registration, err := NewRegistration("255", "0x0000000000000000000000000000000000000001", vk)
+	assert.NoError(err)
+	assert.Equal("0x00000000000000000000000000000000000000000000000000000000000000ff", registration.ProgramVkey)
+	assert.Equal("bn254", registration.Circuit.Curve)
+	assert.Equal(2, registration.Circuit.NbPublicInputs)
+	assert.False(registration.Circuit.HasCommitment)
+	assert.Equal("verifyProof(uint256[8],uint256[2])", registration.Circuit.VerifyFunction)
+	assert.Equal(EncodeHex(Selector("verifyProof(uint256[8],uint256[2])")), registration.Circuit.VerifySelector)
+	assert.Len(registration.Circuit.VkFingerprint, 64)
+
+	_, err = NewRegistration("255", "0x01", vk)
+	assert.Error(err)
+	_, err = NewRegistration("-1", "0x0000000000000000000000000000000000000001", vk)
+	assert.Error(err)
+}
@@ -0,0 +1,157 @@
+package onchain
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"math/big"
+	"strings"
+)
+
+// Client is an Ethereum JSON-RPC client.
+type Client struct {
+	eth *ethclient.Client
+}
+
+// Dial connects to the node at url.
+func Dial(url string) (*Client, error) {
+	eth, err := ethclient.Dial(url)
+	if err != nil {
+		return nil, fmt.Errorf("fail to connect to rpc %s: %v", url, err)
+	}
+	return &Client{eth: eth}, nil
+}
+
+func (c *Client) Close() {
+	c.eth.Close()
+}
+
+// CallMsg is the transaction simulated by Call.
+type CallMsg struct {
+	From string
+	To   string
+	Data []byte
+}
+
+func (m CallMsg) ethereum() (ethereum.CallMsg, error) {
+	err := CheckAddress(m.To)
+	if err != nil {
+		return ethereum.CallMsg{}, err
+	}
+	to := common.HexToAddress(m.To)
+	msg := ethereum.CallMsg{To: &to, Data: m.Data}
+	if m.From != "" {
+		err = CheckAddress(m.From)
+		if err != nil {
+			return ethereum.CallMsg{}, err
+		}
+		msg.From = common.HexToAddress(m.From)
+	}
+	return msg, nil
+}
+
+// Call runs msg against the latest block with eth_call. A revert is returned as
+// *RevertError.
+func (c *Client) Call(ctx context.Context, msg CallMsg) ([]byte, error) {
+	call, err := msg.ethereum()
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.eth.CallContract(ctx, call, nil)
+	if err != nil {
+		return nil, callError("eth_call", err)
+	}
+	return res, nil
+}
+
+// EstimateGas is the gas msg uses, as estimated by the node. A revert is returned
+// as *RevertError.
+func (c *Client) EstimateGas(ctx context.Context, msg CallMsg) (uint64, error) {
+	call, err := msg.ethereum()
+	if err != nil {
+		return 0, err
+	}
+	gas, err := c.eth.EstimateGas(ctx, call)
+	if err != nil {
+		return 0, callError("eth_estimateGas", err)
+	}
+	return gas, nil
+}
+
+// RevertError is a call reverted by the contract.
+type RevertError struct {
+	Message string
+	// Data is the revert payload, starting with the error selector.
+	Data []byte
+}
+
+func (e *RevertError) Error() string {
+	if reason := revertReason(e.Data); reason != "" {
+		return fmt.Sprintf("%s: %s", e.Message, reason)
+	}
+	if len(e.Data) > 0 {
+		return fmt.Sprintf("%s: %s", e.Message, EncodeHex(e.Data))
+	}
+	return e.Message
+}
+
+// verifierErrors are the custom errors of the gnark verifier contract and of
+// PicoBatchVerifier.
+var verifierErrors = []string{"ProofInvalid()", "PublicInputNotInField()", "CommitmentInvalid()", "InvalidBatchLength()"}
+
+func revertReason(data []byte) string {
+	if len(data) < 4 {
+		return ""
+	}
+	for _, e := range verifierErrors {
+		if bytes.Equal(data[:4], Selector(e)) {
+			return e
+		}
+	}
+	// Error(string): offset, length, then the message
+	if bytes.Equal(data[:4], Selector("Error(string)")) && len(data) >= 4+64 {
+		length := new(big.Int).SetBytes(data[4+32 : 4+64])
+		if length.IsInt64() && length.Int64() <= int64(len(data)-4-64) {
+			return string(data[4+64 : 4+64+length.Int64()])
+		}
+	}
+	return ""
+}
+
+// callError converts a revert reported by the node to *RevertError.
+func callError(method string, err error) error {
+	// nodes report reverts as code 3 with the payload in data
+	var rpcErr rpc.Error
+	coded := errors.As(err, &rpcErr) && rpcErr.ErrorCode() == 3
+	if !coded && !strings.Contains(err.Error(), "revert") {
+		return fmt.Errorf("%s: %v", method, err)
+	}
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if data, ok := dataErr.ErrorData().(string); ok {
+			payload, decodeErr := DecodeHex(data)
+			if decodeErr == nil {
+				return &RevertError{Message: err.Error(), Data: payload}
+			}
+		}
+	}
+	return &RevertError{Message: err.Error()}
+}
+
+func EncodeHex(b []byte) string {
+	return "0x" + hex.EncodeToString(b)
+}
+
+func DecodeHex(s string) ([]byte, error) {
+	s = strings.TrimPrefix(s, "0x")
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+	return hex.DecodeString(s)
+}
@@ -0,0 +1,36 @@
+package onchain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/consensys/gnark/test"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCallRevert(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     int64           `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		assert.NoError(json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal("eth_call", req.Method)
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"error":{"code":3,"message":"execution reverted","data":"%s"}}`, req.ID, EncodeHex(Selector("ProofInvalid()")))
+	}))
+	defer server.Close()
+
+	client, err := Dial(server.URL)
+	assert.NoError(err)
+	defer client.Close()
+	_, err = client.Call(context.Background(), CallMsg{To: "0x0000000000000000000000000000000000000001"})
+	var revert *RevertError
+	assert.True(errors.As(err, &revert))
+	assert.Equal("execution reverted: ProofInvalid()", err.Error())
+}
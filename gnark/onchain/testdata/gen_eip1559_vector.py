@@ -0,0 +1,147 @@
+# Prints the signed EIP-1559 transaction checked by TestSignedTxKnownAnswer:
+# python3 testdata/gen_eip1559_vector.py
+#
+# An independent keccak-256, RLP and RFC 6979 secp256k1 signer. It must reproduce the
+# signed legacy transaction of the EIP-155 example before signing the EIP-1559 one.
+import hashlib
+import hmac
+
+RC = [0x0000000000000001, 0x0000000000008082, 0x800000000000808A, 0x8000000080008000,
+      0x000000000000808B, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+      0x000000000000008A, 0x0000000000000088, 0x0000000080008009, 0x000000008000000A,
+      0x000000008000808B, 0x800000000000008B, 0x8000000000008089, 0x8000000000008003,
+      0x8000000000008002, 0x8000000000000080, 0x000000000000800A, 0x800000008000000A,
+      0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008]
+ROT = [[0, 36, 3, 41, 18], [1, 44, 10, 45, 2], [62, 6, 43, 15, 61], [28, 55, 25, 21, 56], [27, 20, 39, 8, 14]]
+M64 = (1 << 64) - 1
+
+
+def rol(x, n):
+    return ((x << n) | (x >> (64 - n))) & M64 if n else x
+
+
+def keccak_f(a):
+    for rc in RC:
+        c = [a[x][0] ^ a[x][1] ^ a[x][2] ^ a[x][3] ^ a[x][4] for x in range(5)]
+        d = [c[(x - 1) % 5] ^ rol(c[(x + 1) % 5], 1) for x in range(5)]
+        a = [[a[x][y] ^ d[x] for y in range(5)] for x in range(5)]
+        b = [[0] * 5 for _ in range(5)]
+        for x in range(5):
+            for y in range(5):
+                b[y][(2 * x + 3 * y) % 5] = rol(a[x][y], ROT[x][y])
+        a = [[b[x][y] ^ (~b[(x + 1) % 5][y] & b[(x + 2) % 5][y]) for y in range(5)] for x in range(5)]
+        a[0][0] ^= rc
+    return a
+
+
+def keccak256(data):
+    rate = 136
+    data = bytearray(data) + b'\x01'
+    data += b'\x00' * (-len(data) % rate)
+    data[-1] |= 0x80
+    a = [[0] * 5 for _ in range(5)]
+    for off in range(0, len(data), rate):
+        for i in range(rate // 8):
+            a[i % 5][i // 5] ^= int.from_bytes(data[off + 8 * i:off + 8 * i + 8], 'little')
+        a = keccak_f(a)
+    return b''.join(a[i % 5][i // 5].to_bytes(8, 'little') for i in range(4))
+
+
+def rlp(item):
+    if isinstance(item, list):
+        payload = b''.join(rlp(i) for i in item)
+        return rlp_length(len(payload), 0xc0) + payload
+    if isinstance(item, int):
+        item = item.to_bytes((item.bit_length() + 7) // 8, 'big')
+    if len(item) == 1 and item[0] < 0x80:
+        return item
+    return rlp_length(len(item), 0x80) + item
+
+
+def rlp_length(n, offset):
+    if n < 56:
+        return bytes([offset + n])
+    b = n.to_bytes((n.bit_length() + 7) // 8, 'big')
+    return bytes([offset + 55 + len(b)]) + b
+
+
+P = 2**256 - 2**32 - 977
+N = 0xFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141
+G = (0x79BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798,
+     0x483ADA7726A3C4655DA4FBFC0E1108A8FD17B448A68554199C47D08FFB10D4B8)
+
+
+def add(p, q):
+    if p is None:
+        return q
+    if q is None:
+        return p
+    if p[0] == q[0] and (p[1] + q[1]) % P == 0:
+        return None
+    if p == q:
+        l = 3 * p[0] * p[0] * pow(2 * p[1], -1, P)
+    else:
+        l = (q[1] - p[1]) * pow(q[0] - p[0], -1, P)
+    x = (l * l - p[0] - q[0]) % P
+    return x, (l * (p[0] - x) - p[1]) % P
+
+
+def mul(k, p):
+    r = None
+    while k:
+        if k & 1:
+            r = add(r, p)
+        p = add(p, p)
+        k >>= 1
+    return r
+
+
+def rfc6979(key, h):
+    x = key.to_bytes(32, 'big')
+    v, k = b'\x01' * 32, b'\x00' * 32
+    h = (int.from_bytes(h, 'big') % N).to_bytes(32, 'big')
+    k = hmac.new(k, v + b'\x00' + x + h, hashlib.sha256).digest()
+    v = hmac.new(k, v, hashlib.sha256).digest()
+    k = hmac.new(k, v + b'\x01' + x + h, hashlib.sha256).digest()
+    v = hmac.new(k, v, hashlib.sha256).digest()
+    while True:
+        v = hmac.new(k, v, hashlib.sha256).digest()
+        t = int.from_bytes(v, 'big')
+        if 1 <= t < N:
+            return t
+        k = hmac.new(k, v + b'\x00', hashlib.sha256).digest()
+        v = hmac.new(k, v, hashlib.sha256).digest()
+
+
+def sign(key, h):
+    k = rfc6979(key, h)
+    point = mul(k, G)
+    r = point[0] % N
+    s = pow(k, -1, N) * (int.from_bytes(h, 'big') + r * key) % N
+    parity = point[1] & 1
+    if s > N // 2:
+        s, parity = N - s, parity ^ 1
+    return parity, r, s
+
+
+assert keccak256(b'').hex() == 'c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470'
+
+# EIP-155 example
+key = int('46' * 32, 16)
+fields = [9, 20 * 10**9, 21000, bytes.fromhex('35' * 20), 10**18, b'']
+parity, r, s = sign(key, keccak256(rlp(fields + [1, 0, 0])))
+assert rlp(fields + [37 + parity, r, s]).hex() == (
+    'f86c098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a76400008025'
+    'a028ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276'
+    'a067cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83')
+
+# EIP-1559 transaction of TestSignedTxKnownAnswer, value 0 and empty access list
+key = 0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318
+fields = [11155111, 7, 2, 22, 1200, bytes.fromhex('00' * 19 + '01'), 0, bytes.fromhex('01'), []]
+h = keccak256(b'\x02' + rlp(fields))
+parity, r, s = sign(key, h)
+print('signing hash', h.hex())
+print('y parity', parity)
+print('r', hex(r))
+print('s', hex(s))
+print('raw', '02' + rlp(fields + [parity, r, s]).hex())
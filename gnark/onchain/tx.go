@@ -0,0 +1,111 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"time"
+)
+
+// defaultGasTip is used when the node does not support eth_maxPriorityFeePerGas.
+var defaultGasTip = big.NewInt(1_000_000_000)
+
+// BuildTx builds the unsigned EIP-1559 transaction of a call to msg.To, with the
+// chain id, pending nonce of from, gas limit and fees filled. The estimated gas is
+// raised by 20% as verification cost varies with the proof.
+func (c *Client) BuildTx(ctx context.Context, msg CallMsg) (*types.Transaction, error) {
+	call, err := msg.ethereum()
+	if err != nil {
+		return nil, err
+	}
+	tx := &types.DynamicFeeTx{To: call.To, Data: msg.Data}
+	tx.ChainID, err = c.ChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx.Nonce, err = c.PendingNonce(ctx, msg.From)
+	if err != nil {
+		return nil, err
+	}
+
+	gas, err := c.EstimateGas(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	tx.Gas = gas * 6 / 5
+
+	tx.GasTipCap, err = c.eth.SuggestGasTipCap(ctx)
+	if err != nil {
+		tx.GasTipCap = new(big.Int).Set(defaultGasTip)
+	}
+	head, err := c.eth.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if head.BaseFee == nil {
+		return nil, fmt.Errorf("chain %s does not support EIP-1559 transactions", tx.ChainID)
+	}
+	// leaves room for the base fee to double before the transaction is included
+	tx.GasFeeCap = new(big.Int).Add(new(big.Int).Lsh(head.BaseFee, 1), tx.GasTipCap)
+	return types.NewTx(tx), nil
+}
+
+// PendingNonce is the next nonce of account, counting its pending transactions.
+func (c *Client) PendingNonce(ctx context.Context, account string) (uint64, error) {
+	err := CheckAddress(account)
+	if err != nil {
+		return 0, err
+	}
+	return c.eth.PendingNonceAt(ctx, common.HexToAddress(account))
+}
+
+func (c *Client) ChainID(ctx context.Context) (*big.Int, error) {
+	return c.eth.ChainID(ctx)
+}
+
+// SendTransaction broadcasts a signed transaction and returns its hash.
+func (c *Client) SendTransaction(ctx context.Context, tx *types.Transaction) (string, error) {
+	err := c.eth.SendTransaction(ctx, tx)
+	if err != nil {
+		return "", err
+	}
+	return tx.Hash().Hex(), nil
+}
+
+// Receipt is the part of a transaction receipt reported after submission.
+type Receipt struct {
+	BlockNumber uint64
+	GasUsed     uint64
+	Succeeded   bool
+}
+
+// receiptPollInterval is how often WaitReceipt asks for the receipt.
+var receiptPollInterval = 2 * time.Second
+
+// WaitReceipt polls the receipt of the transaction hash until it is mined or ctx is done.
+func (c *Client) WaitReceipt(ctx context.Context, hash string) (*Receipt, error) {
+	ticker := time.NewTicker(receiptPollInterval)
+	defer ticker.Stop()
+	for {
+		receipt, err := c.eth.TransactionReceipt(ctx, common.HexToHash(hash))
+		if err == nil {
+			return &Receipt{
+				BlockNumber: receipt.BlockNumber.Uint64(),
+				GasUsed:     receipt.GasUsed,
+				Succeeded:   receipt.Status == types.ReceiptStatusSuccessful,
+			}, nil
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package onchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/consensys/gnark/test"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var (
+	zeroHash  = EncodeHex(make([]byte, 32))
+	zeroBloom = EncodeHex(make([]byte, 256))
+)
+
+func TestSubmitTx(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	key, err := ParseKey("4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318")
+	assert.NoError(err)
+	var sent string
+	headerJSON := `{"parentHash":"` + zeroHash + `","sha3Uncles":"` + zeroHash + `","miner":"0x0000000000000000000000000000000000000000",` +
+		`"stateRoot":"` + zeroHash + `","transactionsRoot":"` + zeroHash + `","receiptsRoot":"` + zeroHash + `","logsBloom":"` + zeroBloom + `",` +
+		`"difficulty":"0x0","number":"0x10","gasLimit":"0x1c9c380","gasUsed":"0x0","timestamp":"0x0","extraData":"0x","baseFeePerGas":"0xa"}`
+	receiptJSON := `{"blockNumber":"0x10","gasUsed":"0x300","cumulativeGasUsed":"0x300","status":"0x1","logs":[],"logsBloom":"` + zeroBloom + `","transactionHash":"` + zeroHash + `"}`
+	results := map[string]string{
+		"eth_chainId":               `"0xaa36a7"`,
+		"eth_getTransactionCount":   `"0x7"`,
+		"eth_estimateGas":           `"0x3e8"`,
+		"eth_maxPriorityFeePerGas":  `"0x2"`,
+		"eth_getBlockByNumber":      headerJSON,
+		"eth_sendRawTransaction":    `"0x1234"`,
+		"eth_getTransactionReceipt": receiptJSON,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     int64             `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		assert.NoError(json.NewDecoder(r.Body).Decode(&req))
+		switch req.Method {
+		case "eth_getTransactionCount":
+			assert.Equal(`"`+key.Address+`"`, string(req.Params[0]))
+			assert.Equal(`"pending"`, string(req.Params[1]))
+		case "eth_sendRawTransaction":
+			assert.NoError(json.Unmarshal(req.Params[0], &sent))
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"result":%s}`, req.ID, results[req.Method])
+	}))
+	defer server.Close()
+
+	client, err := Dial(server.URL)
+	assert.NoError(err)
+	defer client.Close()
+	ctx := context.Background()
+	tx, err := client.BuildTx(ctx, CallMsg{From: key.Address, To: "0x0000000000000000000000000000000000000001", Data: []byte{1}})
+	assert.NoError(err)
+	assert.Equal("11155111", tx.ChainId().String())
+	assert.Equal(uint64(7), tx.Nonce())
+	assert.Equal(uint64(1200), tx.Gas())
+	assert.Equal("22", tx.GasFeeCap().String())
+
+	tx, err = key.SignTx(tx)
+	assert.NoError(err)
+	raw, err := tx.MarshalBinary()
+	assert.NoError(err)
+	assert.Equal(byte(types.DynamicFeeTxType), raw[0])
+	hash, err := client.SendTransaction(ctx, tx)
+	assert.NoError(err)
+	assert.Equal(EncodeHex(Keccak256(raw)), hash)
+	assert.Equal(EncodeHex(raw), sent)
+
+	receipt, err := client.WaitReceipt(ctx, hash)
+	assert.NoError(err)
+	assert.Equal(Receipt{BlockNumber: 16, GasUsed: 0x300, Succeeded: true}, *receipt)
+}
+
+// TestSignedTxKnownAnswer checks the signing hash and signed encoding of the
+// transaction of TestSubmitTx against testdata/gen_eip1559_vector.py.
+func TestSignedTxKnownAnswer(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	to := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   big.NewInt(11155111),
+		Nonce:     7,
+		GasTipCap: big.NewInt(2),
+		GasFeeCap: big.NewInt(22),
+		Gas:       1200,
+		To:        &to,
+		Data:      []byte{1},
+	})
+	signer := types.LatestSignerForChainID(tx.ChainId())
+	assert.Equal("0xccd0d49427744b7349105846b9da6153a50005604618db9608de29759a5b0e0f", signer.Hash(tx).Hex())
+
+	sig, err := DecodeHex("84935cd60d3900eb9ea01644e3c4debd35dc12cd89262190bc24b39ed591cf4f" + "0730fa900b0032fcdee0747970c0d8dad24239e9cfe51281720c6b1aba3c63f5" + "00")
+	assert.NoError(err)
+	signed, err := tx.WithSignature(signer, sig)
+	assert.NoError(err)
+	raw, err := signed.MarshalBinary()
+	assert.NoError(err)
+	assert.Equal("0x02f86583aa36a70702168204b09400000000000000000000000000000000000000018001c080a084935cd60d3900eb9ea01644e3c4debd35dc12cd89262190bc24b39ed591cf4fa00730fa900b0032fcdee0747970c0d8dad24239e9cfe51281720c6b1aba3c63f5", EncodeHex(raw))
+
+	// the signature of the vector is valid for the key of TestSubmitTx
+	key, err := ParseKey("4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318")
+	assert.NoError(err)
+	sender, err := types.Sender(signer, signed)
+	assert.NoError(err)
+	assert.Equal(key.Address, EncodeHex(sender.Bytes()))
+}
@@ -0,0 +1,85 @@
+package outer_verifier
+
+import (
+	"fmt"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/algebra"
+	"github.com/consensys/gnark/std/algebra/emulated/sw_bn254"
+	"github.com/consensys/gnark/std/math/emulated"
+	stdgroth16 "github.com/consensys/gnark/std/recursion/groth16"
+)
+
+// Circuit verifies a Pico wrapper proof (the Groth16 proof of koalabear_verifier or
+// babybear_verifier) inside another gnark circuit, so it can be composed into proving
+// systems that already use gnark recursion. The wrapper's verifying key is baked in
+// as a constant, so only proofs of that exact wrapper circuit are accepted, and the
+// wrapper's public inputs (vkey hash and committed values digest) are re-exposed as
+// public inputs of the outer circuit.
+//
+// The wrapper proof is verified with emulated pairings, so any outer curve works.
+// Wrapper circuits using BSB22 commitments must be proven with
+// stdgroth16.GetNativeProverOptions instead of the keccak hash-to-field.
+type Circuit[FR emulated.FieldParams, G1El algebra.G1ElementT, G2El algebra.G2ElementT, GtEl algebra.GtElementT] struct {
+	Proof        stdgroth16.Proof[G1El, G2El]
+	InnerWitness stdgroth16.Witness[FR] `gnark:",public"`
+
+	vk stdgroth16.VerifyingKey[G1El, G2El, GtEl] `gnark:"-"`
+}
+
+func (c *Circuit[FR, G1El, G2El, GtEl]) Define(api frontend.API) error {
+	verifier, err := stdgroth16.NewVerifier[FR, G1El, G2El, GtEl](api)
+	if err != nil {
+		return fmt.Errorf("new verifier: %w", err)
+	}
+	return verifier.AssertProof(c.vk, c.Proof, c.InnerWitness)
+}
+
+// NewCircuit returns the outer circuit definition for the wrapper circuit compiled
+// to innerCcs with verifying key innerVk.
+func NewCircuit[FR emulated.FieldParams, G1El algebra.G1ElementT, G2El algebra.G2ElementT, GtEl algebra.GtElementT](innerCcs constraint.ConstraintSystem, innerVk groth16.VerifyingKey) (*Circuit[FR, G1El, G2El, GtEl], error) {
+	vk, err := stdgroth16.ValueOfVerifyingKeyFixed[G1El, G2El, GtEl](innerVk)
+	if err != nil {
+		return nil, fmt.Errorf("fail to convert verifying key: %v", err)
+	}
+	return &Circuit[FR, G1El, G2El, GtEl]{
+		Proof:        stdgroth16.PlaceholderProof[G1El, G2El](innerCcs),
+		InnerWitness: stdgroth16.PlaceholderWitness[FR](innerCcs),
+		vk:           vk,
+	}, nil
+}
+
+// NewAssignment returns the outer circuit assignment for a wrapper proof and its
+// public witness.
+func NewAssignment[FR emulated.FieldParams, G1El algebra.G1ElementT, G2El algebra.G2ElementT, GtEl algebra.GtElementT](innerVk groth16.VerifyingKey, innerProof groth16.Proof, innerPubWitness witness.Witness) (*Circuit[FR, G1El, G2El, GtEl], error) {
+	vk, err := stdgroth16.ValueOfVerifyingKeyFixed[G1El, G2El, GtEl](innerVk)
+	if err != nil {
+		return nil, fmt.Errorf("fail to convert verifying key: %v", err)
+	}
+	proof, err := stdgroth16.ValueOfProof[G1El, G2El](innerProof)
+	if err != nil {
+		return nil, fmt.Errorf("fail to convert proof: %v", err)
+	}
+	pubWitness, err := stdgroth16.ValueOfWitness[FR](innerPubWitness)
+	if err != nil {
+		return nil, fmt.Errorf("fail to convert public witness: %v", err)
+	}
+	return &Circuit[FR, G1El, G2El, GtEl]{
+		Proof:        proof,
+		InnerWitness: pubWitness,
+		vk:           vk,
+	}, nil
+}
+
+// BN254Circuit verifies a BN254 wrapper proof.
+type BN254Circuit = Circuit[sw_bn254.ScalarField, sw_bn254.G1Affine, sw_bn254.G2Affine, sw_bn254.GTEl]
+
+func NewBN254Circuit(innerCcs constraint.ConstraintSystem, innerVk groth16.VerifyingKey) (*BN254Circuit, error) {
+	return NewCircuit[sw_bn254.ScalarField, sw_bn254.G1Affine, sw_bn254.G2Affine, sw_bn254.GTEl](innerCcs, innerVk)
+}
+
+func NewBN254Assignment(innerVk groth16.VerifyingKey, innerProof groth16.Proof, innerPubWitness witness.Witness) (*BN254Circuit, error) {
+	return NewAssignment[sw_bn254.ScalarField, sw_bn254.G1Affine, sw_bn254.G2Affine, sw_bn254.GTEl](innerVk, innerProof, innerPubWitness)
+}
@@ -0,0 +1,45 @@
+package outer_verifier
+
+import (
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"testing"
+)
+
+// innerCircuit has the same public shape as the Pico wrapper circuit.
+type innerCircuit struct {
+	VkeyHash              frontend.Variable `gnark:",public"`
+	CommittedValuesDigest frontend.Variable `gnark:",public"`
+	X                     frontend.Variable
+}
+
+func (c *innerCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Mul(c.X, c.VkeyHash), c.CommittedValuesDigest)
+	return nil
+}
+
+func TestVerifyBN254WrapperProof(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	innerCcs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &innerCircuit{})
+	assert.NoError(err)
+	innerPk, innerVk, err := groth16.Setup(innerCcs)
+	assert.NoError(err)
+	innerWitness, err := frontend.NewWitness(&innerCircuit{VkeyHash: 3, CommittedValuesDigest: 21, X: 7}, ecc.BN254.ScalarField())
+	assert.NoError(err)
+	innerProof, err := groth16.Prove(innerCcs, innerPk, innerWitness)
+	assert.NoError(err)
+	innerPubWitness, err := innerWitness.Public()
+	assert.NoError(err)
+
+	circuit, err := NewBN254Circuit(innerCcs, innerVk)
+	assert.NoError(err)
+	assignment, err := NewBN254Assignment(innerVk, innerProof, innerPubWitness)
+	assert.NoError(err)
+
+	err = test.IsSolved(circuit, assignment, ecc.BN254.ScalarField())
+	assert.NoError(err)
+}
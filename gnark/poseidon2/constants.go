@@ -18,8 +18,11 @@ var rc16_koalabear [30][KOALABEAR_WIDTH]koalabear.Variable
 
 func init() {
 	init_rc3()
+	init_rc3_native()
 	init_rc16()
+	init_rc16_native()
 	init_rc16_koalabear()
+	init_rc16_koalabear_native()
 }
 
 func init_rc3() {
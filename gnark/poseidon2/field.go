@@ -0,0 +1,87 @@
+package poseidon2
+
+import (
+	"fmt"
+	"github.com/brevis-network/pico/gnark/babybear"
+	"github.com/brevis-network/pico/gnark/koalabear"
+	"github.com/consensys/gnark/frontend"
+	"math/big"
+)
+
+// FieldWidth is the state width of the 31 bit field permutations.
+const FieldWidth = 16
+
+// FieldChip is the width 16 Poseidon2 permutation of a 31 bit field, for gadgets that
+// hold field elements in native variables rather than in the field chip's types.
+type FieldChip interface {
+	// Permute returns the permutation of state. Every input is range checked to 31
+	// bits, then reduced, and the outputs are canonical field elements.
+	Permute(state [FieldWidth]frontend.Variable) [FieldWidth]frontend.Variable
+}
+
+// NewFieldChip returns the permutation of field, "bb" (BabyBear) or "kb" (KoalaBear),
+// built on the same chips as the verifier circuits.
+func NewFieldChip(api frontend.API, field string) (FieldChip, error) {
+	switch field {
+	case "bb":
+		return NewBabyBearFieldChip(babybear.NewChip(api), NewBabyBearChip(api)), nil
+	case "kb":
+		return NewKoalaBearFieldChip(koalabear.NewChip(api), NewKoalaBearChip(api)), nil
+	default:
+		return nil, fmt.Errorf("invalid field: %s", field)
+	}
+}
+
+// NewBabyBearFieldChip returns the FieldChip permuting with chip, which checks and
+// reduces its inputs and outputs with fieldApi, for gadgets sharing the chips and
+// options of a verifier circuit.
+func NewBabyBearFieldChip(fieldApi *babybear.Chip, chip *Poseidon2BabyBearChip) FieldChip {
+	return &babybearFieldChip{fieldApi: fieldApi, chip: chip}
+}
+
+// NewKoalaBearFieldChip returns the KoalaBear FieldChip permuting with chip, see
+// NewBabyBearFieldChip.
+func NewKoalaBearFieldChip(fieldApi *koalabear.Chip, chip *Poseidon2KoalaBearChip) FieldChip {
+	return &koalabearFieldChip{fieldApi: fieldApi, chip: chip}
+}
+
+// inputBound is the upper bound of a 31 bit range checked input.
+var inputBound = new(big.Int).SetUint64(1<<31 - 1)
+
+type babybearFieldChip struct {
+	fieldApi *babybear.Chip
+	chip     *Poseidon2BabyBearChip
+}
+
+func (c *babybearFieldChip) Permute(state [FieldWidth]frontend.Variable) [FieldWidth]frontend.Variable {
+	var s [BABYBEAR_WIDTH]babybear.Variable
+	for i := range s {
+		c.fieldApi.RangeCheck(state[i], 31)
+		s[i] = c.fieldApi.ReduceSlow(babybear.Variable{Value: state[i], UpperBound: inputBound})
+	}
+	c.chip.PermuteMut(&s)
+	var res [FieldWidth]frontend.Variable
+	for i := range res {
+		res[i] = c.fieldApi.ReduceSlow(s[i]).Value
+	}
+	return res
+}
+
+type koalabearFieldChip struct {
+	fieldApi *koalabear.Chip
+	chip     *Poseidon2KoalaBearChip
+}
+
+func (c *koalabearFieldChip) Permute(state [FieldWidth]frontend.Variable) [FieldWidth]frontend.Variable {
+	var s [KOALABEAR_WIDTH]koalabear.Variable
+	for i := range s {
+		c.fieldApi.RangeCheck(state[i], 31)
+		s[i] = c.fieldApi.ReduceSlow(koalabear.Variable{Value: state[i], UpperBound: inputBound})
+	}
+	c.chip.PermuteMut(&s)
+	var res [FieldWidth]frontend.Variable
+	for i := range res {
+		res[i] = c.fieldApi.ReduceSlow(s[i]).Value
+	}
+	return res
+}
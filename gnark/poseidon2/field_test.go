@@ -0,0 +1,111 @@
+package poseidon2
+
+import (
+	"github.com/brevis-network/pico/gnark/babybear"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"math/big"
+	"strconv"
+	"testing"
+)
+
+type fieldChipCircuit struct {
+	Input, ExpectedOutput [FieldWidth]frontend.Variable
+
+	field string
+}
+
+func (c *fieldChipCircuit) Define(api frontend.API) error {
+	chip, err := NewFieldChip(api, c.field)
+	if err != nil {
+		return err
+	}
+	output := chip.Permute(c.Input)
+	for i := range output {
+		api.AssertIsEqual(output[i], c.ExpectedOutput[i])
+	}
+	return nil
+}
+
+func TestPermuteBabyBear(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// the output of the circuit in TestPoseidon2BabyBear
+	var state [BABYBEAR_WIDTH]uint32
+	PermuteBabyBear(&state)
+	assert.Equal([BABYBEAR_WIDTH]uint32{
+		618910652, 1488604963, 659088560, 1999029727, 1121255343, 20724378, 956965955, 1084245564,
+		751155763, 1075356210, 1159054104, 47710013, 179166241, 42705162, 1517988227, 1481867517,
+	}, state)
+}
+
+func TestFieldChip(t *testing.T) {
+	for _, c := range []struct {
+		field   string
+		modulus uint32
+		permute func(*[FieldWidth]uint32)
+	}{
+		{"bb", babybearModulus, PermuteBabyBear},
+		{"kb", koalabearModulus, PermuteKoalaBear},
+	} {
+		assert := test.NewAssert(t)
+
+		var input, output [FieldWidth]uint32
+		for i := range input {
+			input[i] = (uint32(i)*0x9e3779b1 + 7) % c.modulus
+		}
+		input[0] = c.modulus - 1
+		output = input
+		c.permute(&output)
+
+		circuit := &fieldChipCircuit{field: c.field}
+		assignment := &fieldChipCircuit{field: c.field}
+		for i := range input {
+			assignment.Input[i] = input[i]
+			assignment.ExpectedOutput[i] = output[i]
+		}
+		assert.NoError(test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()), c.field)
+
+		assignment.ExpectedOutput[3] = (output[3] + 1) % c.modulus
+		assert.Error(test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()), c.field)
+	}
+
+	_, err := NewFieldChip(nil, "goldilocks")
+	if err == nil {
+		t.Fatal("expected an error for an unknown field")
+	}
+}
+
+type crossCheckBabyBearCircuit struct {
+	Input [BABYBEAR_WIDTH]babybear.Variable
+}
+
+func (circuit *crossCheckBabyBearCircuit) Define(api frontend.API) error {
+	poseidon2Chip := NewBabyBearChip(api)
+	poseidon2Chip.EnableCrossCheck()
+
+	state := circuit.Input
+	poseidon2Chip.PermuteMut(&state)
+	return nil
+}
+
+func TestPoseidon2BabyBearCrossCheck(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	var input [BABYBEAR_WIDTH]babybear.Variable
+	var hintInputs []*big.Int
+	for i := 0; i < BABYBEAR_WIDTH; i++ {
+		input[i] = babybear.NewF(strconv.Itoa(i))
+		hintInputs = append(hintInputs, big.NewInt(int64(i)))
+	}
+	circuit := &crossCheckBabyBearCircuit{Input: input}
+	witness := &crossCheckBabyBearCircuit{Input: input}
+	assert.NoError(test.IsSolved(circuit, witness, ecc.BN254.ScalarField()))
+
+	// A wrong circuit output is reported by the hint.
+	for i := 0; i < BABYBEAR_WIDTH; i++ {
+		hintInputs = append(hintInputs, big.NewInt(int64(i)))
+	}
+	assert.Error(CrossCheckBabyBearHint(ecc.BN254.ScalarField(), hintInputs, []*big.Int{new(big.Int)}))
+}
@@ -0,0 +1,138 @@
+package poseidon2
+
+import (
+	"fmt"
+	"github.com/consensys/gnark/constraint/solver"
+	"math/big"
+)
+
+const babybearModulus = 2013265921
+
+// babybearMatInternalDiagM1 is the diagonal of the internal linear layer minus the
+// identity, shared by the circuit and the native permutation.
+var babybearMatInternalDiagM1 = [BABYBEAR_WIDTH]uint64{
+	2013265919, 1, 2, 1006632961, 3, 4, 1006632960, 2013265918,
+	2013265917, 2005401601, 1509949441, 1761607681, 2013265906, 7864320, 125829120, 15,
+}
+
+// Round constants of rc16 reduced into the field.
+var rc16BabyBearNative [30][BABYBEAR_WIDTH]uint64
+
+func init() {
+	solver.RegisterHint(CrossCheckBabyBearHint)
+}
+
+func init_rc16_native() {
+	modulus := big.NewInt(babybearModulus)
+	for r := range rc16 {
+		for i := range rc16[r] {
+			rc16BabyBearNative[r][i] = new(big.Int).Mod(rc16[r][i].UpperBound, modulus).Uint64()
+		}
+	}
+}
+
+// PermuteBabyBear is the native counterpart of Poseidon2BabyBearChip.PermuteMut. It
+// is the Go reference the circuit is cross-checked against.
+func PermuteBabyBear(state *[BABYBEAR_WIDTH]uint32) {
+	PermuteBabyBearWithParams(BabyBearParams, state)
+}
+
+// PermuteBabyBearWithParams is PermuteBabyBear with the parameters params.
+func PermuteBabyBearWithParams(params Params, state *[BABYBEAR_WIDTH]uint32) {
+	params.check(babybearModulus)
+	var s [BABYBEAR_WIDTH]uint64
+	for i := range state {
+		s[i] = uint64(state[i]) % babybearModulus
+	}
+
+	babybearExternalLinearLayer(&s)
+
+	rounds := babybearNumExternalRounds + babybearNumInternalRounds
+	roundsFBeginning := babybearNumExternalRounds / 2
+	for r := 0; r < roundsFBeginning; r++ {
+		for i := range s {
+			s[i] = params.sboxNative((s[i]+rc16BabyBearNative[r][i])%babybearModulus, babybearModulus)
+		}
+		babybearExternalLinearLayer(&s)
+	}
+
+	pEnd := roundsFBeginning + babybearNumInternalRounds
+	for r := roundsFBeginning; r < pEnd; r++ {
+		s[0] = params.sboxNative((s[0]+rc16BabyBearNative[r][0])%babybearModulus, babybearModulus)
+		babybearInternalLinearLayer(&s)
+	}
+
+	for r := pEnd; r < rounds; r++ {
+		for i := range s {
+			s[i] = params.sboxNative((s[i]+rc16BabyBearNative[r][i])%babybearModulus, babybearModulus)
+		}
+		babybearExternalLinearLayer(&s)
+	}
+
+	for i := range state {
+		state[i] = uint32(s[i])
+	}
+}
+
+func babybearMds4x4(s []uint64) {
+	t01 := s[0] + s[1]
+	t23 := s[2] + s[3]
+	t0123 := t01 + t23
+	t01123 := t0123 + s[1]
+	t01233 := t0123 + s[3]
+	s[3] = (t01233 + 2*s[0]) % babybearModulus
+	s[1] = (t01123 + 2*s[2]) % babybearModulus
+	s[0] = (t01123 + t01) % babybearModulus
+	s[2] = (t01233 + t23) % babybearModulus
+}
+
+func babybearExternalLinearLayer(s *[BABYBEAR_WIDTH]uint64) {
+	for i := 0; i < BABYBEAR_WIDTH; i += 4 {
+		babybearMds4x4(s[i : i+4])
+	}
+
+	var sums [4]uint64
+	for i := 0; i < BABYBEAR_WIDTH; i++ {
+		sums[i%4] += s[i]
+	}
+	for i := 0; i < BABYBEAR_WIDTH; i++ {
+		s[i] = (s[i] + sums[i%4]) % babybearModulus
+	}
+}
+
+func babybearInternalLinearLayer(s *[BABYBEAR_WIDTH]uint64) {
+	var sum uint64
+	for i := 0; i < BABYBEAR_WIDTH; i++ {
+		sum += s[i]
+	}
+	sum %= babybearModulus
+
+	for i := 0; i < BABYBEAR_WIDTH; i++ {
+		s[i] = (s[i]*babybearMatInternalDiagM1[i] + sum) % babybearModulus
+	}
+}
+
+// CrossCheckBabyBearHint is CrossCheckKoalaBearHint for the BabyBear permutation.
+func CrossCheckBabyBearHint(_ *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	params, err := crossCheckParams(BabyBearParams, babybearModulus, BABYBEAR_WIDTH, inputs, outputs)
+	if err != nil {
+		return err
+	}
+	outputs[0].SetUint64(0)
+
+	modulus := big.NewInt(babybearModulus)
+	var state [BABYBEAR_WIDTH]uint32
+	for i := 0; i < BABYBEAR_WIDTH; i++ {
+		state[i] = uint32(new(big.Int).Mod(inputs[i], modulus).Uint64())
+	}
+	input := state
+	PermuteBabyBearWithParams(params, &state)
+
+	for i := 0; i < BABYBEAR_WIDTH; i++ {
+		circuitOut := new(big.Int).Mod(inputs[BABYBEAR_WIDTH+i], modulus).Uint64()
+		if circuitOut != uint64(state[i]) {
+			return fmt.Errorf("poseidon2 babybear mismatch at lane %d: circuit %d, native %d (input %v)", i, circuitOut, state[i], input)
+		}
+	}
+	return nil
+}
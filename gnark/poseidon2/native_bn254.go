@@ -0,0 +1,74 @@
+package poseidon2
+
+import (
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+// Round constants of rc3 as field elements.
+var rc3Native [numExternalRounds + numInternalRounds][width]fr.Element
+
+func init_rc3_native() {
+	for r := range rc3 {
+		for i := range rc3[r] {
+			_, err := rc3Native[r][i].SetString(rc3[r][i].(string))
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+// PermuteBN254 is the native counterpart of Poseidon2Chip.PermuteMut, the Permute
+// opcode of the constraints outside the circuit.
+func PermuteBN254(state *[width]fr.Element) {
+	bn254MatrixPermuteMut(state)
+
+	rounds := numExternalRounds + numInternalRounds
+	roundsFBeginning := numExternalRounds / 2
+	for r := 0; r < roundsFBeginning; r++ {
+		for i := range state {
+			state[i].Add(&state[i], &rc3Native[r][i])
+			bn254Sbox(&state[i])
+		}
+		bn254MatrixPermuteMut(state)
+	}
+
+	pEnd := roundsFBeginning + numInternalRounds
+	for r := roundsFBeginning; r < pEnd; r++ {
+		state[0].Add(&state[0], &rc3Native[r][0])
+		bn254Sbox(&state[0])
+		bn254DiffusionPermuteMut(state)
+	}
+
+	for r := pEnd; r < rounds; r++ {
+		for i := range state {
+			state[i].Add(&state[i], &rc3Native[r][i])
+			bn254Sbox(&state[i])
+		}
+		bn254MatrixPermuteMut(state)
+	}
+}
+
+func bn254Sbox(x *fr.Element) {
+	var x4 fr.Element
+	x4.Square(x).Square(&x4)
+	x.Mul(x, &x4)
+}
+
+func bn254MatrixPermuteMut(state *[width]fr.Element) {
+	var sum fr.Element
+	sum.Add(&state[0], &state[1]).Add(&sum, &state[2])
+	for i := range state {
+		state[i].Add(&state[i], &sum)
+	}
+}
+
+func bn254DiffusionPermuteMut(state *[width]fr.Element) {
+	var sum fr.Element
+	sum.Add(&state[0], &state[1]).Add(&sum, &state[2])
+	// the internal linear layer is diag(1, 1, 2) plus the all ones matrix
+	state[2].Double(&state[2])
+	for i := range state {
+		state[i].Add(&state[i], &sum)
+	}
+}
@@ -0,0 +1,72 @@
+package poseidon2
+
+// NativePermutation is a native width 16 permutation, PermuteKoalaBear or
+// PermuteBabyBear.
+type NativePermutation func(state *[FieldWidth]uint32)
+
+// NativeHash is Hash outside the circuit, the Rust side's padding free sponge.
+func NativeHash(permute NativePermutation, inputs []uint32) [FieldDigestSize]uint32 {
+	var state [FieldWidth]uint32
+	for len(inputs) > 0 {
+		n := copy(state[:min(len(inputs), FieldRate)], inputs)
+		inputs = inputs[n:]
+		permute(&state)
+	}
+	var digest [FieldDigestSize]uint32
+	copy(digest[:], state[:])
+	return digest
+}
+
+// NativeCompress is the Rust side's 2 to 1 compression of Merkle tree nodes: the
+// permutation of left and right, truncated to a digest.
+func NativeCompress(permute NativePermutation, left, right [FieldDigestSize]uint32) [FieldDigestSize]uint32 {
+	var state [FieldWidth]uint32
+	copy(state[:], left[:])
+	copy(state[FieldDigestSize:], right[:])
+	permute(&state)
+	var digest [FieldDigestSize]uint32
+	copy(digest[:], state[:])
+	return digest
+}
+
+// NativeSponge is Sponge outside the circuit, the state of the Rust side's duplex
+// challenger.
+type NativeSponge struct {
+	permute NativePermutation
+	state   [FieldWidth]uint32
+	input   []uint32
+	output  []uint32
+}
+
+// NewNativeSponge returns a sponge with an all zero state.
+func NewNativeSponge(permute NativePermutation) *NativeSponge {
+	return &NativeSponge{permute: permute}
+}
+
+// Absorb absorbs values, which must be canonical field elements.
+func (s *NativeSponge) Absorb(values ...uint32) {
+	for _, v := range values {
+		s.output = nil
+		s.input = append(s.input, v)
+		if len(s.input) == FieldRate {
+			s.duplex()
+		}
+	}
+}
+
+// Squeeze returns the next field element of the sponge.
+func (s *NativeSponge) Squeeze() uint32 {
+	if len(s.input) > 0 || len(s.output) == 0 {
+		s.duplex()
+	}
+	v := s.output[len(s.output)-1]
+	s.output = s.output[:len(s.output)-1]
+	return v
+}
+
+func (s *NativeSponge) duplex() {
+	copy(s.state[:], s.input)
+	s.input = nil
+	s.permute(&s.state)
+	s.output = append([]uint32(nil), s.state[:FieldRate]...)
+}
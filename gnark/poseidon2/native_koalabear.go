@@ -0,0 +1,140 @@
+package poseidon2
+
+import (
+	"fmt"
+	"github.com/consensys/gnark/constraint/solver"
+	"math/big"
+)
+
+const koalabearModulus = 2130706433
+
+// koalabearMatInternalDiagM1 is the diagonal of the internal linear layer minus the
+// identity, shared by the circuit and the native permutation.
+var koalabearMatInternalDiagM1 = [KOALABEAR_WIDTH]uint64{
+	2130706431, 1, 2, 1065353217, 3, 4, 1065353216, 2130706430,
+	2130706429, 2122383361, 1864368129, 2130706306, 8323072, 266338304, 133169152, 127,
+}
+
+// Round constants of rc16_koalabear reduced into the field.
+var rc16KoalaBearNative [30][KOALABEAR_WIDTH]uint64
+
+func init() {
+	solver.RegisterHint(CrossCheckKoalaBearHint)
+}
+
+func init_rc16_koalabear_native() {
+	modulus := big.NewInt(koalabearModulus)
+	for r := range rc16_koalabear {
+		for i := range rc16_koalabear[r] {
+			rc16KoalaBearNative[r][i] = new(big.Int).Mod(rc16_koalabear[r][i].UpperBound, modulus).Uint64()
+		}
+	}
+}
+
+// PermuteKoalaBear is the native counterpart of Poseidon2KoalaBearChip.PermuteMut. It
+// is the Go reference the circuit is cross-checked against.
+func PermuteKoalaBear(state *[KOALABEAR_WIDTH]uint32) {
+	PermuteKoalaBearWithParams(KoalaBearParams, state)
+}
+
+// PermuteKoalaBearWithParams is PermuteKoalaBear with the parameters params.
+func PermuteKoalaBearWithParams(params Params, state *[KOALABEAR_WIDTH]uint32) {
+	params.check(koalabearModulus)
+	var s [KOALABEAR_WIDTH]uint64
+	for i := range state {
+		s[i] = uint64(state[i]) % koalabearModulus
+	}
+
+	koalabearExternalLinearLayer(&s)
+
+	rounds := koalabearNumExternalRounds + koalabearNumInternalRounds
+	roundsFBeginning := koalabearNumExternalRounds / 2
+	for r := 0; r < roundsFBeginning; r++ {
+		for i := range s {
+			s[i] = params.sboxNative((s[i]+rc16KoalaBearNative[r][i])%koalabearModulus, koalabearModulus)
+		}
+		koalabearExternalLinearLayer(&s)
+	}
+
+	pEnd := roundsFBeginning + koalabearNumInternalRounds
+	for r := roundsFBeginning; r < pEnd; r++ {
+		s[0] = params.sboxNative((s[0]+rc16KoalaBearNative[r][0])%koalabearModulus, koalabearModulus)
+		koalabearInternalLinearLayer(&s)
+	}
+
+	for r := pEnd; r < rounds; r++ {
+		for i := range s {
+			s[i] = params.sboxNative((s[i]+rc16KoalaBearNative[r][i])%koalabearModulus, koalabearModulus)
+		}
+		koalabearExternalLinearLayer(&s)
+	}
+
+	for i := range state {
+		state[i] = uint32(s[i])
+	}
+}
+
+func koalabearMds4x4(s []uint64) {
+	t01 := s[0] + s[1]
+	t23 := s[2] + s[3]
+	t0123 := t01 + t23
+	t01123 := t0123 + s[1]
+	t01233 := t0123 + s[3]
+	s[3] = (t01233 + 2*s[0]) % koalabearModulus
+	s[1] = (t01123 + 2*s[2]) % koalabearModulus
+	s[0] = (t01123 + t01) % koalabearModulus
+	s[2] = (t01233 + t23) % koalabearModulus
+}
+
+func koalabearExternalLinearLayer(s *[KOALABEAR_WIDTH]uint64) {
+	for i := 0; i < KOALABEAR_WIDTH; i += 4 {
+		koalabearMds4x4(s[i : i+4])
+	}
+
+	var sums [4]uint64
+	for i := 0; i < KOALABEAR_WIDTH; i++ {
+		sums[i%4] += s[i]
+	}
+	for i := 0; i < KOALABEAR_WIDTH; i++ {
+		s[i] = (s[i] + sums[i%4]) % koalabearModulus
+	}
+}
+
+func koalabearInternalLinearLayer(s *[KOALABEAR_WIDTH]uint64) {
+	var sum uint64
+	for i := 0; i < KOALABEAR_WIDTH; i++ {
+		sum += s[i]
+	}
+	sum %= koalabearModulus
+
+	for i := 0; i < KOALABEAR_WIDTH; i++ {
+		s[i] = (s[i]*koalabearMatInternalDiagM1[i] + sum) % koalabearModulus
+	}
+}
+
+// CrossCheckKoalaBearHint takes the 16 inputs followed by the 16 outputs of a circuit
+// permutation, and optionally its s-box degree, and fails the solver if the native
+// permutation disagrees. Its single output is always zero.
+func CrossCheckKoalaBearHint(_ *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	params, err := crossCheckParams(KoalaBearParams, koalabearModulus, KOALABEAR_WIDTH, inputs, outputs)
+	if err != nil {
+		return err
+	}
+	outputs[0].SetUint64(0)
+
+	modulus := big.NewInt(koalabearModulus)
+	var state [KOALABEAR_WIDTH]uint32
+	for i := 0; i < KOALABEAR_WIDTH; i++ {
+		state[i] = uint32(new(big.Int).Mod(inputs[i], modulus).Uint64())
+	}
+	input := state
+	PermuteKoalaBearWithParams(params, &state)
+
+	for i := 0; i < KOALABEAR_WIDTH; i++ {
+		circuitOut := new(big.Int).Mod(inputs[KOALABEAR_WIDTH+i], modulus).Uint64()
+		if circuitOut != uint64(state[i]) {
+			return fmt.Errorf("poseidon2 koalabear mismatch at lane %d: circuit %d, native %d (input %v)", i, circuitOut, state[i], input)
+		}
+	}
+	return nil
+}
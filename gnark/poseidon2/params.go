@@ -0,0 +1,83 @@
+package poseidon2
+
+import (
+	"fmt"
+	"github.com/consensys/gnark/frontend"
+	"math/big"
+)
+
+// Params is a parameter set of the width 16 Poseidon2 permutations over a 31 bit
+// field, as the Pico field configurations of the Rust side choose it.
+type Params struct {
+	// SboxDegree is the degree d of the S-box x^d, 3 or 7. It must be coprime to
+	// p-1 for the S-box to permute the field.
+	SboxDegree int
+}
+
+// KoalaBearParams are the parameters of the KoalaBear permutation of Pico.
+var KoalaBearParams = Params{SboxDegree: 3}
+
+// BabyBearParams are the parameters of the BabyBear permutation of Pico.
+var BabyBearParams = Params{SboxDegree: 7}
+
+// validate checks that params define a permutation of the field of modulus.
+func (params Params) validate(modulus uint64) error {
+	if params.SboxDegree != 3 && params.SboxDegree != 7 {
+		return fmt.Errorf("unsupported poseidon2 s-box degree %d, support 3/7", params.SboxDegree)
+	}
+	if (modulus-1)%uint64(params.SboxDegree) == 0 {
+		return fmt.Errorf("s-box x^%d does not permute the field of modulus %d", params.SboxDegree, modulus)
+	}
+	return nil
+}
+
+// check panics if params do not define a permutation of the field of modulus.
+func (params Params) check(modulus uint64) {
+	if err := params.validate(modulus); err != nil {
+		panic(err)
+	}
+}
+
+// sboxBound is the upper bound of the S-box of a reduced value of the field of modulus.
+func (params Params) sboxBound(modulus uint64) *big.Int {
+	return new(big.Int).Exp(new(big.Int).SetUint64(modulus), big.NewInt(int64(params.SboxDegree)), nil)
+}
+
+// sbox returns x^SboxDegree with the multiplications the chips used before the degree
+// was a parameter, so their circuits are unchanged.
+func (params Params) sbox(api frontend.API, x frontend.Variable) frontend.Variable {
+	x2 := api.Mul(x, x)
+	if params.SboxDegree == 3 {
+		return api.Mul(x2, x)
+	}
+	x4 := api.Mul(x2, x2)
+	x6 := api.Mul(x4, x2)
+	return api.Mul(x6, x)
+}
+
+// sboxNative is sbox on a reduced value of the field of modulus.
+func (params Params) sboxNative(x, modulus uint64) uint64 {
+	res := x
+	for i := 1; i < params.SboxDegree; i++ {
+		res = res * x % modulus
+	}
+	return res
+}
+
+// crossCheckParams checks the inputs and outputs of a cross-check hint of a permutation
+// of width lanes over the field of modulus and returns its parameters: defaults with the
+// trailing s-box degree input, if any, as circuits compiled before the degree was a
+// parameter do not pass it.
+func crossCheckParams(defaults Params, modulus uint64, width int, inputs, outputs []*big.Int) (Params, error) {
+	if (len(inputs) != 2*width && len(inputs) != 2*width+1) || len(outputs) != 1 {
+		return Params{}, fmt.Errorf("expected %d inputs and 1 output, got %d and %d", 2*width+1, len(inputs), len(outputs))
+	}
+	params := defaults
+	if len(inputs) == 2*width+1 {
+		if !inputs[2*width].IsInt64() {
+			return Params{}, fmt.Errorf("invalid s-box degree %s", inputs[2*width])
+		}
+		params.SboxDegree = int(inputs[2*width].Int64())
+	}
+	return params, params.validate(modulus)
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/brevis-network/pico/gnark/babybear"
 	"github.com/consensys/gnark/frontend"
 	"math/big"
+	"strconv"
 )
 
 const BABYBEAR_WIDTH = 16
@@ -13,12 +14,24 @@ const babybearNumInternalRounds = 13
 type Poseidon2BabyBearChip struct {
 	State       [16]babybear.Variable
 	bufferCount int
+	crossCheck  bool
+	folding     bool
+	params      Params
 
 	api      frontend.API
 	fieldApi *babybear.Chip
 }
 
-func NewBabyBearChip(api frontend.API) *Poseidon2BabyBearChip {
+// NewBabyBearChip returns the babybear permutation, reducing with a field chip built with
+// opts.
+func NewBabyBearChip(api frontend.API, opts ...babybear.Option) *Poseidon2BabyBearChip {
+	return NewBabyBearChipWithParams(api, BabyBearParams, opts...)
+}
+
+// NewBabyBearChipWithParams returns the babybear permutation with the parameters params. It
+// panics if they do not define a permutation of the field.
+func NewBabyBearChipWithParams(api frontend.API, params Params, opts ...babybear.Option) *Poseidon2BabyBearChip {
+	params.check(babybearModulus)
 	return &Poseidon2BabyBearChip{
 		State: [16]babybear.Variable{
 			babybear.Zero(),
@@ -38,8 +51,9 @@ func NewBabyBearChip(api frontend.API) *Poseidon2BabyBearChip {
 			babybear.Zero(),
 			babybear.Zero(),
 		},
+		params:   params,
 		api:      api,
-		fieldApi: babybear.NewChip(api),
+		fieldApi: babybear.NewChip(api, opts...),
 	}
 }
 
@@ -68,7 +82,29 @@ func (p *Poseidon2BabyBearChip) Finalize() [16]babybear.Variable {
 	return res
 }
 
+// EnableCrossCheck makes every permutation compare its output against the native
+// PermuteBabyBear while the witness is solved, see Poseidon2KoalaBearChip.EnableCrossCheck.
+func (p *Poseidon2BabyBearChip) EnableCrossCheck() {
+	p.crossCheck = true
+}
+
+// EnableConstantFolding makes permutations of states that are constants of the circuit,
+// such as the initial states of challengers, computed with PermuteBabyBear while the circuit
+// is compiled instead of constrained. It changes the constraint system of circuits that
+// permute such states, so it needs its own setup.
+func (p *Poseidon2BabyBearChip) EnableConstantFolding() {
+	p.folding = true
+}
+
 func (p *Poseidon2BabyBearChip) PermuteMut(state *[BABYBEAR_WIDTH]babybear.Variable) {
+	if p.folding && p.foldConstant(state) {
+		return
+	}
+	if p.crossCheck {
+		input := *state
+		defer p.crossCheckPermutation(&input, state)
+	}
+
 	// The initial linear layer.
 	p.externalLinearLayer(state)
 
@@ -107,16 +143,10 @@ func (p *Poseidon2BabyBearChip) sboxP(input babybear.Variable) babybear.Variable
 	zero := babybear.NewFConst("0")
 	inputCpy := p.fieldApi.AddF(input, zero)
 	inputCpy = p.fieldApi.ReduceSlow(inputCpy)
-	inputValue := inputCpy.Value
-	i2 := p.api.Mul(inputValue, inputValue)
-	i4 := p.api.Mul(i2, i2)
-	i6 := p.api.Mul(i4, i2)
-	i7 := p.api.Mul(i6, inputValue)
-	i7bb := p.fieldApi.ReduceSlow(babybear.Variable{
-		Value:      i7,
-		UpperBound: new(big.Int).Exp(new(big.Int).SetUint64(2013265921), new(big.Int).SetUint64(7), new(big.Int).SetUint64(0)),
+	return p.fieldApi.ReduceSlow(babybear.Variable{
+		Value:      p.params.sbox(p.api, inputCpy.Value),
+		UpperBound: p.params.sboxBound(babybearModulus),
 	})
-	return i7bb
 }
 
 func (p *Poseidon2BabyBearChip) sbox(state *[BABYBEAR_WIDTH]babybear.Variable) {
@@ -161,23 +191,9 @@ func (p *Poseidon2BabyBearChip) externalLinearLayer(state *[BABYBEAR_WIDTH]babyb
 }
 
 func (p *Poseidon2BabyBearChip) diffusionPermuteMut(state *[BABYBEAR_WIDTH]babybear.Variable) {
-	matInternalDiagM1 := [BABYBEAR_WIDTH]babybear.Variable{
-		babybear.NewFConst("2013265919"),
-		babybear.NewFConst("1"),
-		babybear.NewFConst("2"),
-		babybear.NewFConst("1006632961"),
-		babybear.NewFConst("3"),
-		babybear.NewFConst("4"),
-		babybear.NewFConst("1006632960"),
-		babybear.NewFConst("2013265918"),
-		babybear.NewFConst("2013265917"),
-		babybear.NewFConst("2005401601"),
-		babybear.NewFConst("1509949441"),
-		babybear.NewFConst("1761607681"),
-		babybear.NewFConst("2013265906"),
-		babybear.NewFConst("7864320"),
-		babybear.NewFConst("125829120"),
-		babybear.NewFConst("15"),
+	var matInternalDiagM1 [BABYBEAR_WIDTH]babybear.Variable
+	for i := 0; i < BABYBEAR_WIDTH; i++ {
+		matInternalDiagM1[i] = babybear.NewFConst(strconv.FormatUint(babybearMatInternalDiagM1[i], 10))
 	}
 	p.matmulInternal(state, &matInternalDiagM1)
 }
@@ -196,3 +212,40 @@ func (p *Poseidon2BabyBearChip) matmulInternal(
 		state[i] = p.fieldApi.AddF(state[i], sum)
 	}
 }
+
+func (p *Poseidon2BabyBearChip) crossCheckPermutation(input, output *[BABYBEAR_WIDTH]babybear.Variable) {
+	values := make([]frontend.Variable, 0, 2*BABYBEAR_WIDTH+1)
+	for i := 0; i < BABYBEAR_WIDTH; i++ {
+		values = append(values, input[i].Value)
+	}
+	for i := 0; i < BABYBEAR_WIDTH; i++ {
+		values = append(values, output[i].Value)
+	}
+	values = append(values, p.params.SboxDegree)
+	out, err := p.api.Compiler().NewHint(CrossCheckBabyBearHint, 1, values...)
+	if err != nil {
+		panic(err)
+	}
+	p.api.AssertIsEqual(out[0], 0)
+}
+
+// foldConstant permutes state with PermuteBabyBearWithParams and reports true if every lane of it is a
+// constant of the circuit. States are folded whole: the initial linear layer, which runs
+// before any round, makes every lane depend on all 16 inputs, so no lane of any round is
+// constant once a single input lane is not.
+func (p *Poseidon2BabyBearChip) foldConstant(state *[BABYBEAR_WIDTH]babybear.Variable) bool {
+	var native [BABYBEAR_WIDTH]uint32
+	modulus := big.NewInt(babybearModulus)
+	for i := 0; i < BABYBEAR_WIDTH; i++ {
+		value, ok := p.api.Compiler().ConstantValue(state[i].Value)
+		if !ok {
+			return false
+		}
+		native[i] = uint32(new(big.Int).Mod(value, modulus).Uint64())
+	}
+	PermuteBabyBearWithParams(p.params, &native)
+	for i := 0; i < BABYBEAR_WIDTH; i++ {
+		state[i] = babybear.NewFConst(strconv.FormatUint(uint64(native[i]), 10))
+	}
+	return true
+}
@@ -1,13 +1,16 @@
 package poseidon2
 
 import (
+	"encoding/json"
 	"github.com/brevis-network/pico/gnark/babybear"
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
 	"github.com/consensys/gnark/logger"
 	"github.com/consensys/gnark/test"
 	"github.com/rs/zerolog"
 	"os"
+	"strconv"
 	"testing"
 )
 
@@ -82,3 +85,70 @@ func TestPoseidon2BabyBear(t *testing.T) {
 	err := test.IsSolved(circuit, witness, ecc.BN254.ScalarField())
 	assert.NoError(err)
 }
+
+// babybearVectors reads testdata/babybear_vectors.json, see koalabearVectors.
+func babybearVectors(assert *test.Assert) []struct {
+	Input  [BABYBEAR_WIDTH]uint32 `json:"input"`
+	Output [BABYBEAR_WIDTH]uint32 `json:"output"`
+} {
+	data, err := os.ReadFile("testdata/babybear_vectors.json")
+	assert.NoError(err)
+	var vectors []struct {
+		Input  [BABYBEAR_WIDTH]uint32 `json:"input"`
+		Output [BABYBEAR_WIDTH]uint32 `json:"output"`
+	}
+	assert.NoError(json.Unmarshal(data, &vectors))
+	assert.True(len(vectors) > 1, "no non-trivial vectors")
+	return vectors
+}
+
+func TestNativePoseidon2BabyBearVectors(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	for _, vector := range babybearVectors(assert) {
+		state := vector.Input
+		PermuteBabyBear(&state)
+		assert.Equal(vector.Output, state)
+	}
+}
+
+func TestPoseidon2BabyBearVectors(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	for _, vector := range babybearVectors(assert) {
+		var input, output [BABYBEAR_WIDTH]babybear.Variable
+		for i := 0; i < BABYBEAR_WIDTH; i++ {
+			input[i] = babybear.NewF(strconv.FormatUint(uint64(vector.Input[i]), 10))
+			output[i] = babybear.NewF(strconv.FormatUint(uint64(vector.Output[i]), 10))
+		}
+		circuit := &TestPoseidon2BabyBearCircuit{Input: input, ExpectedOutput: output}
+		witness := &TestPoseidon2BabyBearCircuit{Input: input, ExpectedOutput: output}
+		assert.NoError(test.IsSolved(circuit, witness, ecc.BN254.ScalarField()))
+	}
+}
+
+type foldingBabyBearCircuit struct {
+	Unused frontend.Variable
+}
+
+func (circuit *foldingBabyBearCircuit) Define(api frontend.API) error {
+	poseidon2Chip := NewBabyBearChip(api)
+	poseidon2Chip.EnableConstantFolding()
+
+	var expected [BABYBEAR_WIDTH]uint32
+	PermuteBabyBear(&expected)
+	state := poseidon2Chip.State
+	poseidon2Chip.PermuteMut(&state)
+	for i := 0; i < BABYBEAR_WIDTH; i++ {
+		poseidon2Chip.fieldApi.AssertIsEqualF(babybear.NewFConst(strconv.FormatUint(uint64(expected[i]), 10)), state[i])
+	}
+	return nil
+}
+
+func TestPoseidon2BabyBearConstantFolding(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &foldingBabyBearCircuit{})
+	assert.NoError(err)
+	assert.Equal(0, ccs.GetNbConstraints())
+}
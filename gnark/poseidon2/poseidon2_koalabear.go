@@ -4,6 +4,7 @@ import (
 	"github.com/brevis-network/pico/gnark/koalabear"
 	"github.com/consensys/gnark/frontend"
 	"math/big"
+	"strconv"
 )
 
 const KOALABEAR_WIDTH = 16
@@ -13,12 +14,24 @@ const koalabearNumInternalRounds = 20
 type Poseidon2KoalaBearChip struct {
 	State       [16]koalabear.Variable
 	bufferCount int
+	crossCheck  bool
+	folding     bool
+	params      Params
 
 	api      frontend.API
 	fieldApi *koalabear.Chip
 }
 
-func NewKoalaBearChip(api frontend.API) *Poseidon2KoalaBearChip {
+// NewKoalaBearChip returns the koalabear permutation, reducing with a field chip built with
+// opts.
+func NewKoalaBearChip(api frontend.API, opts ...koalabear.Option) *Poseidon2KoalaBearChip {
+	return NewKoalaBearChipWithParams(api, KoalaBearParams, opts...)
+}
+
+// NewKoalaBearChipWithParams returns the koalabear permutation with the parameters params. It
+// panics if they do not define a permutation of the field.
+func NewKoalaBearChipWithParams(api frontend.API, params Params, opts ...koalabear.Option) *Poseidon2KoalaBearChip {
+	params.check(koalabearModulus)
 	return &Poseidon2KoalaBearChip{
 		State: [16]koalabear.Variable{
 			koalabear.Zero(),
@@ -38,8 +51,9 @@ func NewKoalaBearChip(api frontend.API) *Poseidon2KoalaBearChip {
 			koalabear.Zero(),
 			koalabear.Zero(),
 		},
+		params:   params,
 		api:      api,
-		fieldApi: koalabear.NewChip(api),
+		fieldApi: koalabear.NewChip(api, opts...),
 	}
 }
 
@@ -68,7 +82,30 @@ func (p *Poseidon2KoalaBearChip) Finalize() [16]koalabear.Variable {
 	return res
 }
 
+// EnableCrossCheck makes every permutation compare its output against the native
+// PermuteKoalaBear while the witness is solved. It adds one hint and one constraint per
+// permutation and is meant for debugging drift between the Rust and Go parameters.
+func (p *Poseidon2KoalaBearChip) EnableCrossCheck() {
+	p.crossCheck = true
+}
+
+// EnableConstantFolding makes permutations of states that are constants of the circuit,
+// such as the initial states of challengers, computed with PermuteKoalaBear while the circuit
+// is compiled instead of constrained. It changes the constraint system of circuits that
+// permute such states, so it needs its own setup.
+func (p *Poseidon2KoalaBearChip) EnableConstantFolding() {
+	p.folding = true
+}
+
 func (p *Poseidon2KoalaBearChip) PermuteMut(state *[KOALABEAR_WIDTH]koalabear.Variable) {
+	if p.folding && p.foldConstant(state) {
+		return
+	}
+	if p.crossCheck {
+		input := *state
+		defer p.crossCheckPermutation(&input, state)
+	}
+
 	// The initial linear layer.
 	p.externalLinearLayer(state)
 
@@ -107,14 +144,10 @@ func (p *Poseidon2KoalaBearChip) sboxP(input koalabear.Variable) koalabear.Varia
 	zero := koalabear.NewFConst("0")
 	inputCpy := p.fieldApi.AddF(input, zero)
 	inputCpy = p.fieldApi.ReduceSlow(inputCpy)
-	inputValue := inputCpy.Value
-	i2 := p.api.Mul(inputValue, inputValue)
-	i3 := p.api.Mul(i2, inputValue)
-	i3bb := p.fieldApi.ReduceSlow(koalabear.Variable{
-		Value:      i3,
-		UpperBound: new(big.Int).Exp(new(big.Int).SetUint64(2130706433), new(big.Int).SetUint64(3), new(big.Int).SetUint64(0)),
+	return p.fieldApi.ReduceSlow(koalabear.Variable{
+		Value:      p.params.sbox(p.api, inputCpy.Value),
+		UpperBound: p.params.sboxBound(koalabearModulus),
 	})
-	return i3bb
 }
 
 func (p *Poseidon2KoalaBearChip) sbox(state *[KOALABEAR_WIDTH]koalabear.Variable) {
@@ -159,23 +192,9 @@ func (p *Poseidon2KoalaBearChip) externalLinearLayer(state *[KOALABEAR_WIDTH]koa
 }
 
 func (p *Poseidon2KoalaBearChip) diffusionPermuteMut(state *[KOALABEAR_WIDTH]koalabear.Variable) {
-	matInternalDiagM1 := [KOALABEAR_WIDTH]koalabear.Variable{
-		koalabear.NewFConst("2130706431"),
-		koalabear.NewFConst("1"),
-		koalabear.NewFConst("2"),
-		koalabear.NewFConst("1065353217"),
-		koalabear.NewFConst("3"),
-		koalabear.NewFConst("4"),
-		koalabear.NewFConst("1065353216"),
-		koalabear.NewFConst("2130706430"),
-		koalabear.NewFConst("2130706429"),
-		koalabear.NewFConst("2122383361"),
-		koalabear.NewFConst("1864368129"),
-		koalabear.NewFConst("2130706306"),
-		koalabear.NewFConst("8323072"),
-		koalabear.NewFConst("266338304"),
-		koalabear.NewFConst("133169152"),
-		koalabear.NewFConst("127"),
+	var matInternalDiagM1 [KOALABEAR_WIDTH]koalabear.Variable
+	for i := 0; i < KOALABEAR_WIDTH; i++ {
+		matInternalDiagM1[i] = koalabear.NewFConst(strconv.FormatUint(koalabearMatInternalDiagM1[i], 10))
 	}
 	p.matmulInternal(state, &matInternalDiagM1)
 }
@@ -194,3 +213,40 @@ func (p *Poseidon2KoalaBearChip) matmulInternal(
 		state[i] = p.fieldApi.AddF(state[i], sum)
 	}
 }
+
+func (p *Poseidon2KoalaBearChip) crossCheckPermutation(input, output *[KOALABEAR_WIDTH]koalabear.Variable) {
+	values := make([]frontend.Variable, 0, 2*KOALABEAR_WIDTH+1)
+	for i := 0; i < KOALABEAR_WIDTH; i++ {
+		values = append(values, input[i].Value)
+	}
+	for i := 0; i < KOALABEAR_WIDTH; i++ {
+		values = append(values, output[i].Value)
+	}
+	values = append(values, p.params.SboxDegree)
+	out, err := p.api.Compiler().NewHint(CrossCheckKoalaBearHint, 1, values...)
+	if err != nil {
+		panic(err)
+	}
+	p.api.AssertIsEqual(out[0], 0)
+}
+
+// foldConstant permutes state with PermuteKoalaBearWithParams and reports true if every lane of it is a
+// constant of the circuit. States are folded whole: the initial linear layer, which runs
+// before any round, makes every lane depend on all 16 inputs, so no lane of any round is
+// constant once a single input lane is not.
+func (p *Poseidon2KoalaBearChip) foldConstant(state *[KOALABEAR_WIDTH]koalabear.Variable) bool {
+	var native [KOALABEAR_WIDTH]uint32
+	modulus := big.NewInt(koalabearModulus)
+	for i := 0; i < KOALABEAR_WIDTH; i++ {
+		value, ok := p.api.Compiler().ConstantValue(state[i].Value)
+		if !ok {
+			return false
+		}
+		native[i] = uint32(new(big.Int).Mod(value, modulus).Uint64())
+	}
+	PermuteKoalaBearWithParams(p.params, &native)
+	for i := 0; i < KOALABEAR_WIDTH; i++ {
+		state[i] = koalabear.NewFConst(strconv.FormatUint(uint64(native[i]), 10))
+	}
+	return true
+}
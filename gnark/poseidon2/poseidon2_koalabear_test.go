@@ -1,13 +1,18 @@
 package poseidon2
 
 import (
+	"encoding/json"
 	"github.com/brevis-network/pico/gnark/koalabear"
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
 	"github.com/consensys/gnark/logger"
 	"github.com/consensys/gnark/test"
 	"github.com/rs/zerolog"
+	"math/big"
+	"math/rand"
 	"os"
+	"strconv"
 	"testing"
 )
 
@@ -85,3 +90,178 @@ func TestPoseidon2KoalaBear(t *testing.T) {
 	err := test.IsSolved(circuit, witness, ecc.BN254.ScalarField())
 	assert.NoError(err)
 }
+
+// koalabearVectors reads testdata/koalabear_vectors.json, recorded from the permutation
+// of the prover by vm/examples/gen_poseidon2_vectors.rs.
+func koalabearVectors(assert *test.Assert) []struct {
+	Input  [KOALABEAR_WIDTH]uint32 `json:"input"`
+	Output [KOALABEAR_WIDTH]uint32 `json:"output"`
+} {
+	data, err := os.ReadFile("testdata/koalabear_vectors.json")
+	assert.NoError(err)
+	var vectors []struct {
+		Input  [KOALABEAR_WIDTH]uint32 `json:"input"`
+		Output [KOALABEAR_WIDTH]uint32 `json:"output"`
+	}
+	assert.NoError(json.Unmarshal(data, &vectors))
+	assert.True(len(vectors) > 1, "no non-trivial vectors")
+	return vectors
+}
+
+func TestNativePoseidon2KoalaBearVectors(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	for _, vector := range koalabearVectors(assert) {
+		state := vector.Input
+		PermuteKoalaBear(&state)
+		assert.Equal(vector.Output, state)
+	}
+}
+
+func TestPoseidon2KoalaBearVectors(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	for _, vector := range koalabearVectors(assert) {
+		var input, output [KOALABEAR_WIDTH]koalabear.Variable
+		for i := 0; i < KOALABEAR_WIDTH; i++ {
+			input[i] = koalabear.NewF(strconv.FormatUint(uint64(vector.Input[i]), 10))
+			output[i] = koalabear.NewF(strconv.FormatUint(uint64(vector.Output[i]), 10))
+		}
+		circuit := &TestPoseidon2KoalaBearCircuit{Input: input, ExpectedOutput: output}
+		witness := &TestPoseidon2KoalaBearCircuit{Input: input, ExpectedOutput: output}
+		assert.NoError(test.IsSolved(circuit, witness, ecc.BN254.ScalarField()))
+	}
+}
+
+type crossCheckKoalaBearCircuit struct {
+	Input [KOALABEAR_WIDTH]koalabear.Variable
+}
+
+func (circuit *crossCheckKoalaBearCircuit) Define(api frontend.API) error {
+	poseidon2Chip := NewKoalaBearChip(api)
+	poseidon2Chip.EnableCrossCheck()
+
+	state := circuit.Input
+	poseidon2Chip.PermuteMut(&state)
+	poseidon2Chip.PermuteMut(&state)
+	return nil
+}
+
+func TestPoseidon2KoalaBearCrossCheck(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	var input [KOALABEAR_WIDTH]koalabear.Variable
+	var hintInputs []*big.Int
+	for i := 0; i < KOALABEAR_WIDTH; i++ {
+		value := uint64(rand.Int63n(koalabearModulus))
+		input[i] = koalabear.NewF(strconv.FormatUint(value, 10))
+		hintInputs = append(hintInputs, new(big.Int).SetUint64(value))
+	}
+	circuit := &crossCheckKoalaBearCircuit{Input: input}
+	witness := &crossCheckKoalaBearCircuit{Input: input}
+	assert.NoError(test.IsSolved(circuit, witness, ecc.BN254.ScalarField()))
+
+	_, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
+	assert.NoError(err)
+
+	// A wrong circuit output is reported by the hint.
+	for i := 0; i < KOALABEAR_WIDTH; i++ {
+		hintInputs = append(hintInputs, big.NewInt(int64(i)))
+	}
+	assert.Error(CrossCheckKoalaBearHint(ecc.BN254.ScalarField(), hintInputs, []*big.Int{new(big.Int)}))
+}
+
+type foldingKoalaBearCircuit struct {
+	Lane     koalabear.Variable
+	constant [KOALABEAR_WIDTH]uint32
+	folding  bool
+}
+
+func (circuit *foldingKoalaBearCircuit) Define(api frontend.API) error {
+	poseidon2Chip := NewKoalaBearChip(api)
+	if circuit.folding {
+		poseidon2Chip.EnableConstantFolding()
+	}
+
+	expected := circuit.constant
+	PermuteKoalaBear(&expected)
+	var state [KOALABEAR_WIDTH]koalabear.Variable
+	for i := 0; i < KOALABEAR_WIDTH; i++ {
+		state[i] = koalabear.NewFConst(strconv.FormatUint(uint64(circuit.constant[i]), 10))
+	}
+	poseidon2Chip.PermuteMut(&state)
+	for i := 0; i < KOALABEAR_WIDTH; i++ {
+		poseidon2Chip.fieldApi.AssertIsEqualF(koalabear.NewFConst(strconv.FormatUint(uint64(expected[i]), 10)), state[i])
+	}
+
+	// a single variable lane keeps the whole permutation constrained
+	state[0] = circuit.Lane
+	poseidon2Chip.PermuteMut(&state)
+	return nil
+}
+
+func TestPoseidon2KoalaBearConstantFolding(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	var constant [KOALABEAR_WIDTH]uint32
+	for i := range constant {
+		constant[i] = uint32(rand.Int63n(koalabearModulus))
+	}
+	nbConstraints := make(map[bool]int)
+	for _, folding := range []bool{false, true} {
+		circuit := &foldingKoalaBearCircuit{Lane: koalabear.NewF("0"), constant: constant, folding: folding}
+		witness := &foldingKoalaBearCircuit{Lane: koalabear.NewF("7")}
+		assert.NoError(test.IsSolved(circuit, witness, ecc.BN254.ScalarField()))
+
+		ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
+		assert.NoError(err)
+		nbConstraints[folding] = ccs.GetNbConstraints()
+	}
+	// the folded permutation and the check of its output cost nothing
+	assert.Less(nbConstraints[true], nbConstraints[false]/2)
+}
+
+type paramsKoalaBearCircuit struct {
+	Input, ExpectedOutput [KOALABEAR_WIDTH]koalabear.Variable
+	params                Params
+}
+
+func (circuit *paramsKoalaBearCircuit) Define(api frontend.API) error {
+	poseidon2Chip := NewKoalaBearChipWithParams(api, circuit.params)
+	poseidon2Chip.EnableCrossCheck()
+
+	state := circuit.Input
+	poseidon2Chip.PermuteMut(&state)
+	for i := 0; i < KOALABEAR_WIDTH; i++ {
+		poseidon2Chip.fieldApi.AssertIsEqualF(circuit.ExpectedOutput[i], state[i])
+	}
+	return nil
+}
+
+func TestPoseidon2KoalaBearParams(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	params := Params{SboxDegree: 7}
+	var input, output [KOALABEAR_WIDTH]uint32
+	for i := range input {
+		input[i] = uint32(rand.Int63n(koalabearModulus))
+	}
+	output = input
+	PermuteKoalaBearWithParams(params, &output)
+	defaults := input
+	PermuteKoalaBear(&defaults)
+	assert.NotEqual(defaults, output)
+
+	circuit := &paramsKoalaBearCircuit{params: params}
+	witness := &paramsKoalaBearCircuit{}
+	for i := 0; i < KOALABEAR_WIDTH; i++ {
+		circuit.Input[i], circuit.ExpectedOutput[i] = koalabear.NewF("0"), koalabear.NewF("0")
+		witness.Input[i] = koalabear.NewF(strconv.FormatUint(uint64(input[i]), 10))
+		witness.ExpectedOutput[i] = koalabear.NewF(strconv.FormatUint(uint64(output[i]), 10))
+	}
+	assert.NoError(test.IsSolved(circuit, witness, ecc.BN254.ScalarField()))
+
+	// x^3 is not a permutation of BabyBear, 3 divides p-1
+	assert.Panics(func() { NewBabyBearChipWithParams(nil, Params{SboxDegree: 3}) })
+	assert.Panics(func() { NewKoalaBearChipWithParams(nil, Params{SboxDegree: 5}) })
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
 	"github.com/consensys/gnark/backend"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/test"
@@ -50,3 +51,25 @@ func TestPoseidon2(t *testing.T) {
 	witness = TestPoseidon2Circuit{Input: input, ExpectedOutput: expected_output}
 	assert.ProverSucceeded(&circuit, &witness, test.WithCurves(ecc.BN254), test.WithBackends(backend.PLONK))
 }
+
+func TestPermuteBN254(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	var state [width]fr.Element
+	PermuteBN254(&state)
+	assert.Equal("0x2ed1da00b14d635bd35b88ab49390d5c13c90da7e9e3a5f1ea69cd87a0aa3e82", "0x"+state[0].Text(16))
+	assert.Equal("0x1e21e979cc3fd844b88c2016fd18f4db07a698aa27deca67ca509f5b0a4480d0", "0x"+state[1].Text(16))
+	assert.Equal("0x2c40d0115da2c9b55553b231be55295f411e628ed0cd0e187917066515f0a060", "0x"+state[2].Text(16))
+
+	var input [width]frontend.Variable
+	for i := range state {
+		state[i].SetUint64(uint64(i + 1))
+		input[i] = uint64(i + 1)
+	}
+	PermuteBN254(&state)
+	var output [width]frontend.Variable
+	for i := range state {
+		output[i] = state[i].String()
+	}
+	assert.NoError(test.IsSolved(&TestPoseidon2Circuit{}, &TestPoseidon2Circuit{Input: input, ExpectedOutput: output}, ecc.BN254.ScalarField()))
+}
@@ -0,0 +1,91 @@
+package poseidon2
+
+import (
+	"github.com/consensys/gnark/frontend"
+)
+
+const (
+	// FieldRate is the rate of the sponges over a FieldChip, the remaining
+	// FieldWidth-FieldRate elements are the capacity.
+	FieldRate = 8
+	// FieldDigestSize is the number of field elements returned by Hash.
+	FieldDigestSize = 8
+)
+
+// Hash hashes inputs with the padding free sponge of the Rust side: inputs overwrite
+// the rate FieldRate elements at a time, the state is permuted after every chunk,
+// the last partial one included, and the digest is the first FieldDigestSize
+// elements of the state. It returns the zero state for no input.
+func Hash(chip FieldChip, inputs ...frontend.Variable) [FieldDigestSize]frontend.Variable {
+	var state [FieldWidth]frontend.Variable
+	for i := range state {
+		state[i] = 0
+	}
+	for len(inputs) > 0 {
+		n := min(len(inputs), FieldRate)
+		copy(state[:n], inputs[:n])
+		inputs = inputs[n:]
+		state = chip.Permute(state)
+	}
+	var digest [FieldDigestSize]frontend.Variable
+	copy(digest[:], state[:FieldDigestSize])
+	return digest
+}
+
+// Sponge is a duplex sponge over a FieldChip, absorbing and squeezing in any order as
+// the challenger of the Rust side does: absorbed values overwrite the rate once
+// FieldRate of them are buffered or before the next squeeze, and squeezed values
+// are taken from the end of the rate.
+type Sponge struct {
+	chip   FieldChip
+	state  [FieldWidth]frontend.Variable
+	input  []frontend.Variable
+	output []frontend.Variable
+}
+
+// NewSponge returns a sponge with an all zero state.
+func NewSponge(chip FieldChip) *Sponge {
+	s := &Sponge{chip: chip}
+	for i := range s.state {
+		s.state[i] = 0
+	}
+	return s
+}
+
+// Absorb absorbs values, which must be field elements of the chip's field.
+func (s *Sponge) Absorb(values ...frontend.Variable) {
+	for _, v := range values {
+		// absorbing invalidates what is left to squeeze
+		s.output = nil
+		s.input = append(s.input, v)
+		if len(s.input) == FieldRate {
+			s.duplex()
+		}
+	}
+}
+
+// Squeeze returns the next field element of the sponge.
+func (s *Sponge) Squeeze() frontend.Variable {
+	if len(s.input) > 0 || len(s.output) == 0 {
+		s.duplex()
+	}
+	v := s.output[len(s.output)-1]
+	s.output = s.output[:len(s.output)-1]
+	return v
+}
+
+// SqueezeN returns the next n field elements of the sponge.
+func (s *Sponge) SqueezeN(n int) []frontend.Variable {
+	res := make([]frontend.Variable, n)
+	for i := range res {
+		res[i] = s.Squeeze()
+	}
+	return res
+}
+
+func (s *Sponge) duplex() {
+	copy(s.state[:len(s.input)], s.input)
+	s.input = nil
+	s.state = s.chip.Permute(s.state)
+	s.output = append([]frontend.Variable(nil), s.state[:FieldRate]...)
+}
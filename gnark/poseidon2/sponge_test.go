@@ -0,0 +1,99 @@
+package poseidon2
+
+import (
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"testing"
+)
+
+// spongeOps absorbs and squeezes in turns: absorb spongeOps[0] values, squeeze
+// spongeOps[1], absorb spongeOps[2], ...
+var spongeOps = []int{3, 2, 10, 9, 8, 1}
+
+type spongeCircuit struct {
+	Inputs   [21]frontend.Variable
+	Digest   [FieldDigestSize]frontend.Variable
+	Squeezed [12]frontend.Variable
+
+	field string
+}
+
+func (c *spongeCircuit) Define(api frontend.API) error {
+	chip, err := NewFieldChip(api, c.field)
+	if err != nil {
+		return err
+	}
+	digest := Hash(chip, c.Inputs[:]...)
+	for i := range digest {
+		api.AssertIsEqual(digest[i], c.Digest[i])
+	}
+
+	sponge := NewSponge(chip)
+	inputs, squeezed := c.Inputs[:], c.Squeezed[:]
+	for i, n := range spongeOps {
+		if i%2 == 0 {
+			sponge.Absorb(inputs[:n]...)
+			inputs = inputs[n:]
+			continue
+		}
+		for _, v := range sponge.SqueezeN(n) {
+			api.AssertIsEqual(v, squeezed[0])
+			squeezed = squeezed[1:]
+		}
+	}
+	return nil
+}
+
+// nativeSponge replays spongeOps on a NativeSponge.
+func nativeSponge(permute NativePermutation, inputs []uint32) []uint32 {
+	sponge := NewNativeSponge(permute)
+	var squeezed []uint32
+	for i, n := range spongeOps {
+		if i%2 == 0 {
+			sponge.Absorb(inputs[:n]...)
+			inputs = inputs[n:]
+			continue
+		}
+		for j := 0; j < n; j++ {
+			squeezed = append(squeezed, sponge.Squeeze())
+		}
+	}
+	return squeezed
+}
+
+func TestSponge(t *testing.T) {
+	for _, c := range []struct {
+		field   string
+		modulus uint32
+		permute func(*[FieldWidth]uint32)
+	}{
+		{"bb", babybearModulus, PermuteBabyBear},
+		{"kb", koalabearModulus, PermuteKoalaBear},
+	} {
+		assert := test.NewAssert(t)
+
+		var inputs [21]uint32
+		for i := range inputs {
+			inputs[i] = (uint32(i)*0x9e3779b1 + 11) % c.modulus
+		}
+		digest := NativeHash(c.permute, inputs[:])
+		squeezed := nativeSponge(c.permute, inputs[:])
+
+		circuit := &spongeCircuit{field: c.field}
+		assignment := &spongeCircuit{field: c.field}
+		for i := range inputs {
+			assignment.Inputs[i] = inputs[i]
+		}
+		for i := range digest {
+			assignment.Digest[i] = digest[i]
+		}
+		for i := range squeezed {
+			assignment.Squeezed[i] = squeezed[i]
+		}
+		assert.NoError(test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()), c.field)
+
+		assignment.Squeezed[5] = (squeezed[5] + 1) % c.modulus
+		assert.Error(test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()), c.field)
+	}
+}
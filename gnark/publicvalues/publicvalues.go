@@ -0,0 +1,114 @@
+// Package publicvalues decodes the public values a Pico program commits to, ABI encoded
+// as abi.encode does, and checks them against the committed values digest of a proof,
+// so applications read the outputs of a proof without slicing bytes by hand.
+package publicvalues
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/koalabear_native"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"math/big"
+	"os"
+)
+
+// Digest returns the committed values digest of publicValues: their sha256, big endian
+// with the 3 top bits dropped to fit BN254, as the Rust prover and the Solidity
+// verifiers hash them.
+func Digest(publicValues []byte) *big.Int {
+	return koalabear_native.BytesToBN254(sha256.Sum256(publicValues))
+}
+
+// Check fails unless publicValues hash to the committed values digest of public, the
+// public inputs of a wrapper laid out as layout, or to their keccak256 for layouts
+// exposing it. With digest bits, only the bits the public inputs keep are compared.
+func Check(publicValues []byte, layout utils.PublicLayout, public []*big.Int) error {
+	_, committedValuesDigest, err := layout.Unpack(public)
+	if err != nil {
+		return err
+	}
+	digest := layout.Digest(publicValues)
+	if layout.DigestBits > 0 {
+		digest.Mod(digest, new(big.Int).Lsh(big.NewInt(1), uint(layout.DigestBits)))
+	}
+	if digest.Cmp(committedValuesDigest) != 0 {
+		return fmt.Errorf("public values hash to %#x, the proof commits to %#x", digest, committedValuesDigest)
+	}
+	return nil
+}
+
+// Spec is the ABI of the public values of a program, the types of the abi.encode call
+// committing them.
+type Spec struct {
+	args abi.Arguments
+}
+
+// ParseSpec parses spec, a JSON list of ABI arguments as in the inputs of a function
+// of solc's ABI output, e.g. [{"name":"n","type":"uint32"},{"name":"b","type":"uint32"}].
+// Tuples take their fields as components.
+func ParseSpec(spec []byte) (*Spec, error) {
+	var args abi.Arguments
+	err := json.Unmarshal(spec, &args)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public values abi: %v", err)
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("invalid public values abi: no argument")
+	}
+	return &Spec{args: args}, nil
+}
+
+// ReadSpec parses the spec in the file at path.
+func ReadSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSpec(data)
+}
+
+// Values decodes publicValues into one Go value per argument of s, of the types
+// go-ethereum's abi package maps them to, e.g. uint32, *big.Int or [32]byte. It
+// fails unless publicValues are exactly their encoding, without trailing bytes.
+func (s *Spec) Values(publicValues []byte) ([]any, error) {
+	values, err := s.args.Unpack(publicValues)
+	if err != nil {
+		return nil, fmt.Errorf("fail to decode public values: %v", err)
+	}
+	encoded, err := s.args.Pack(values...)
+	if err != nil {
+		return nil, fmt.Errorf("fail to encode public values: %v", err)
+	}
+	if !bytes.Equal(encoded, publicValues) {
+		return nil, fmt.Errorf("public values are %d bytes, their abi encoding %d", len(publicValues), len(encoded))
+	}
+	return values, nil
+}
+
+// Decode decodes publicValues into v, a pointer to a struct with one field per argument
+// of s, matched by its abi tag or by the camel case of the argument name, or a pointer
+// to the value of a single argument.
+func (s *Spec) Decode(publicValues []byte, v any) error {
+	values, err := s.Values(publicValues)
+	if err != nil {
+		return err
+	}
+	err = s.args.Copy(v, values)
+	if err != nil {
+		return fmt.Errorf("fail to decode public values: %v", err)
+	}
+	return nil
+}
+
+// DecodeVerified checks publicValues against the public inputs of a proof with Check,
+// then decodes them into v with Decode.
+func (s *Spec) DecodeVerified(publicValues []byte, layout utils.PublicLayout, public []*big.Int, v any) error {
+	err := Check(publicValues, layout, public)
+	if err != nil {
+		return err
+	}
+	return s.Decode(publicValues, v)
+}
@@ -0,0 +1,95 @@
+package publicvalues
+
+import (
+	"crypto/sha256"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark/test"
+	"math/big"
+	"testing"
+)
+
+const fibonacciSpec = `[
+	{"name": "n", "type": "uint32"},
+	{"name": "a", "type": "uint32"},
+	{"name": "b", "type": "uint32"},
+	{"name": "program", "type": "tuple", "components": [{"name": "name", "type": "string"}, {"name": "hash", "type": "bytes32"}]}
+]`
+
+type fibonacci struct {
+	N       uint32
+	A       uint32
+	B       uint32
+	Program struct {
+		Name string
+		Hash [32]byte
+	}
+}
+
+func TestDecode(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	spec, err := ParseSpec([]byte(fibonacciSpec))
+	assert.NoError(err)
+	var expected fibonacci
+	expected.N, expected.A, expected.B = 10, 34, 55
+	expected.Program.Name = "fibonacci"
+	expected.Program.Hash = sha256.Sum256([]byte("elf"))
+	publicValues, err := spec.args.Pack(expected.N, expected.A, expected.B, expected.Program)
+	assert.NoError(err)
+
+	var decoded fibonacci
+	assert.NoError(spec.Decode(publicValues, &decoded))
+	assert.Equal(expected, decoded)
+	values, err := spec.Values(publicValues)
+	assert.NoError(err)
+	assert.Equal(uint32(55), values[2])
+
+	// a single argument decodes into its value
+	single, err := ParseSpec([]byte(`[{"name": "root", "type": "uint256"}]`))
+	assert.NoError(err)
+	var root *big.Int
+	assert.NoError(single.Decode(publicValues[:32], &root))
+	assert.Equal(int64(10), root.Int64())
+
+	assert.ErrorContains(spec.Decode(append(publicValues, 0), &decoded), "abi encoding")
+	assert.Error(spec.Decode(publicValues[:64], &decoded))
+	_, err = ParseSpec([]byte(`[{"name": "n", "type": "felt"}]`))
+	assert.ErrorContains(err, "invalid public values abi")
+	_, err = ParseSpec([]byte(`[]`))
+	assert.ErrorContains(err, "no argument")
+}
+
+func TestCheck(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	publicValues := []byte("public values")
+	digest := Digest(publicValues)
+	hash := sha256.Sum256(publicValues)
+	hash[0] &= 0x1f
+	assert.Equal(0, digest.Cmp(new(big.Int).SetBytes(hash[:])))
+
+	vkeyHash := big.NewInt(7)
+	for _, layout := range []utils.PublicLayout{{}, {DigestFirst: true, DigestBits: 128, Limbs: true}} {
+		public := layout.Pack(vkeyHash, digest)
+		assert.NoError(Check(publicValues, layout, public))
+		assert.ErrorContains(Check([]byte("other values"), layout, public), "the proof commits to")
+	}
+
+	// a keccak digest layout exposes the keccak256 of the words
+	keccak := utils.PublicLayout{PublicValues: 1, KeccakDigest: true}
+	word := make([]byte, 32)
+	word[31] = 5
+	public, err := keccak.PackPublicValues(vkeyHash, word)
+	assert.NoError(err)
+	assert.NoError(Check(word, keccak, public))
+	assert.Error(Check(word, utils.PublicLayout{PublicValues: 1}, public))
+
+	spec, err := ParseSpec([]byte(`[{"name": "n", "type": "uint32"}]`))
+	assert.NoError(err)
+	publicValues, err = spec.args.Pack(uint32(3))
+	assert.NoError(err)
+	var n uint32
+	assert.NoError(spec.DecodeVerified(publicValues, utils.PublicLayout{}, []*big.Int{vkeyHash, Digest(publicValues)}, &n))
+	assert.Equal(uint32(3), n)
+	assert.Error(spec.DecodeVerified(publicValues, utils.PublicLayout{}, []*big.Int{vkeyHash, digest}, &n))
+}
@@ -1,210 +1,93 @@
 package sdk
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"github.com/brevis-network/pico/gnark/babybear_verifier"
 	"github.com/brevis-network/pico/gnark/utils"
-	"github.com/consensys/gnark-crypto/ecc"
-	"github.com/consensys/gnark/backend"
-	"github.com/consensys/gnark/backend/groth16"
-	bn254cs "github.com/consensys/gnark/constraint/bn254"
 	"github.com/consensys/gnark/frontend"
-	"github.com/consensys/gnark/frontend/cs/r1cs"
-	"github.com/consensys/gnark/test"
-	"golang.org/x/crypto/sha3"
-	"os"
 )
 
-func BabyBearCmd(cmd string) (err error) {
-	switch cmd {
-	case "prove":
-		err = BabyBearProve()
-		if err != nil {
-			return fmt.Errorf("fail to prove: %v\n", err)
-		}
-	case "setup":
-		err = BabyBearSetup()
-		if err != nil {
-			return fmt.Errorf("fail to setup: %v\n", err)
-		}
-		err = ExportSolidify()
-		if err != nil {
-			return fmt.Errorf("fail to export solidity: %v\n", err)
-		}
-	case "solve":
-		_, _, err = DoBabyBearSolve()
-		if err != nil {
-			return fmt.Errorf("fail to solve: %v\n", err)
-		}
-	case "setupAndProve":
-		err = BabyBearSetup()
-		if err != nil {
-			return fmt.Errorf("fail to setup: %v\n", err)
-		}
-		err = ExportSolidify()
-		if err != nil {
-			return fmt.Errorf("fail to export solidity: %v\n", err)
-		}
-		err = BabyBearProve()
-		if err != nil {
-			return fmt.Errorf("fail to prove: %v\n", err)
-		}
-	case "exportSolidity":
-		err = ExportSolidify()
-		if err != nil {
-			return fmt.Errorf("fail to export solidity: %v\n", err)
-		}
-	default:
-		return fmt.Errorf("unknown command: %s", cmd)
-	}
-	return
-}
-
-func DoBabyBearSolve() (circuit *babybear_verifier.Circuit, assigment *babybear_verifier.Circuit, err error) {
-	witnessFile := os.Getenv("WITNESS_JSON")
+// babyBearSdk is the sdk of BabyBear proofs.
+var babyBearSdk = fieldSdk{name: "bb", newCircuits: newBabyBearCircuits, native: babybear_verifier.NativeField}
 
-	data, err := os.ReadFile(witnessFile)
-	if err != nil {
-		return nil, nil, fmt.Errorf("fail to read witness file: %v\n", err)
-	}
-
-	var inputs utils.WitnessInput
-	err = json.Unmarshal(data, &inputs)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to parse witness json: %v\n", err)
-	}
-	assigment = babybear_verifier.NewCircuit(inputs)
-	circuit = babybear_verifier.NewCircuit(inputs)
-
-	err = test.IsSolved(circuit, assigment, ecc.BN254.ScalarField())
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to solve: %v\n", err)
-	}
-	fmt.Println("solved with success")
-
-	return circuit, assigment, nil
+// BabyBearCmd runs cmd, one of the commands building the circuit, for BabyBear proofs.
+func BabyBearCmd(ctx context.Context, cmd string) error {
+	return babyBearSdk.run(ctx, cmd)
 }
 
-func BabyBearSetup() error {
-	circuit, assigment, err := DoBabyBearSolve()
-	if err != nil {
-		return fmt.Errorf("fail to solve: %v\n", err)
-	}
-	fullWitness, err := frontend.NewWitness(assigment, ecc.BN254.ScalarField())
-	if err != nil {
-		return fmt.Errorf("fail to gen full witness: %v", err)
-	}
-	pubWitness, err := fullWitness.Public()
-	if err != nil {
-		return fmt.Errorf("fail to gen public witness: %v", err)
-	}
-	//fmt.Printf("fullWitness: %v \n", pubWitness)
-
-	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
-	if err != nil {
-		return fmt.Errorf("fail to compile frontend: %v", err)
-	}
-	Ccs = ccs.(*bn254cs.R1CS)
-	fmt.Printf("ccs: %d \n", ccs.GetNbConstraints())
-
-	Pk, Vk, err = groth16.Setup(Ccs)
-	if err != nil {
-		return fmt.Errorf("fail to setup groth16: %v", err)
-	}
-
-	pf, err := groth16.Prove(Ccs, Pk, fullWitness, backend.WithProverHashToFieldFunction(sha3.NewLegacyKeccak256()))
-	if err != nil {
-		return fmt.Errorf("fail to prove groth16: %v", err)
-	}
-
-	err = groth16.Verify(pf, Vk, pubWitness, backend.WithVerifierHashToFieldFunction(sha3.NewLegacyKeccak256()))
-	if err != nil {
-		return fmt.Errorf("fail to verify: %v", err)
-	}
-
-	err = utils.WriteProvingKey(os.Getenv("PK_PATH"), Pk)
-	if err != nil {
-		return fmt.Errorf("fail to write pk: %v", err)
-	}
-
-	err = utils.WriteVerifyingKey(os.Getenv("VK_PATH"), Vk)
-	if err != nil {
-		return fmt.Errorf("fail to write vk: %v", err)
-	}
-
-	err = utils.WriteCcs(os.Getenv("CCS_PATH"), ccs)
-	if err != nil {
-		return fmt.Errorf("fail to write vk: %v", err)
-	}
-	return nil
+// DoBabyBearSolve builds the circuit of the witness of WITNESS_JSON and checks it is solved.
+func DoBabyBearSolve(ctx context.Context) (circuit frontend.Circuit, assigment frontend.Circuit, err error) {
+	return babyBearSdk.solve(ctx)
 }
 
-func BabyBearProve() error {
-	loadLock.Add(2) // 1 for load pk, 1 for compile ccs
+// BabyBearSetup runs the groth16 setup of the circuit of WITNESS_JSON and writes the keys.
+func BabyBearSetup(ctx context.Context) error {
+	return babyBearSdk.setup(ctx)
+}
 
-	var reafProveKeyErr, compileCcsErr error
-	go func() {
-		defer loadLock.Done()
-		reafProveKeyErr = utils.ReadProvingKey(os.Getenv("PK_PATH"), Pk)
-	}()
+// BabyBearProve proves the witness of WITNESS_JSON with the keys written by BabyBearSetup.
+func BabyBearProve(ctx context.Context) error {
+	return babyBearSdk.prove(ctx)
+}
 
-	err := utils.ReadVerifyingKey(os.Getenv("VK_PATH"), Vk)
+// newBabyBearCircuits builds the circuit and assignment for a witness json, wrapping
+// all chunk proofs in one circuit if the witness has several.
+func newBabyBearCircuits(data []byte) (circuit frontend.Circuit, assigment frontend.Circuit, err error) {
+	single, multi, err := utils.ParseWitness(data)
 	if err != nil {
-		return fmt.Errorf("failed to read verifing key: %v", err)
+		return nil, nil, fmt.Errorf("failed to parse witness json: %w", err)
 	}
+	return buildCircuits("bb", single, multi)
+}
 
-	witnessFile := os.Getenv("WITNESS_JSON")
-
-	data, err := os.ReadFile(witnessFile)
-	if err != nil {
-		return fmt.Errorf("fail to read witness file: %v\n", err)
+// newBabyBearCircuitsV1 is the circuit construction of version 1 witnesses.
+func newBabyBearCircuitsV1(single *utils.WitnessInput, multi *utils.MultiWitnessInput) (circuit frontend.Circuit, assigment frontend.Circuit, err error) {
+	opts := circuitOptions()
+	if single != nil {
+		layout, err := publicLayout(1, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if layout.IsDefault() {
+			return babybear_verifier.NewCircuit(*single, opts), babybear_verifier.NewCircuit(*single, opts), nil
+		}
+		fmt.Printf("public input layout %s\n", layout)
+		circuit, err := babybear_verifier.NewLayoutCircuit(*single, layout, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		assigment, err := babybear_verifier.NewLayoutCircuit(*single, layout, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		return circuit, assigment, nil
 	}
 
-	var inputs utils.WitnessInput
-	err = json.Unmarshal(data, &inputs)
+	_, err = publicLayout(len(multi.Chunks), multi.Aggregated())
 	if err != nil {
-		return fmt.Errorf("failed to parse witness json: %v", err)
+		return nil, nil, err
 	}
-	assigment := babybear_verifier.NewCircuit(inputs)
-	circuit := babybear_verifier.NewCircuit(inputs)
-
-	err = test.IsSolved(circuit, assigment, ecc.BN254.ScalarField())
-	if err != nil {
-		return fmt.Errorf("failed to solve: %v", err)
+	if multi.Aggregated() {
+		fmt.Printf("aggregating %d chunk proofs of %d allowed programs\n", len(multi.Chunks), len(multi.AllowedVkeys))
+		circuit, err := babybear_verifier.NewAggregateCircuit(*multi, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		assigment, err := babybear_verifier.NewAggregateCircuit(*multi, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		return circuit, assigment, nil
 	}
-
-	fullWitness, err := frontend.NewWitness(assigment, ecc.BN254.ScalarField())
+	fmt.Printf("wrapping %d chunk proofs\n", len(multi.Chunks))
+	multiCircuit, err := babybear_verifier.NewMultiCircuit(*multi, opts)
 	if err != nil {
-		return fmt.Errorf("failed to get full witness: %v", err)
+		return nil, nil, err
 	}
-	pubWitness, err := fullWitness.Public()
+	multiAssigment, err := babybear_verifier.NewMultiCircuit(*multi, opts)
 	if err != nil {
-		return fmt.Errorf("failed to get public witness: %v", err)
-	}
-	fmt.Printf("fullWitness: %v \n", pubWitness)
-
-	go func() {
-		defer loadLock.Done()
-		ccs, ccsErr := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
-		if ccsErr != nil {
-			compileCcsErr = ccsErr
-			return
-		}
-		Ccs = ccs.(*bn254cs.R1CS)
-		fmt.Printf("ccs: %d \n", ccs.GetNbConstraints())
-	}()
-
-	loadLock.Wait()
-
-	if compileCcsErr != nil {
-		return fmt.Errorf("fail to compile compiler: %v", compileCcsErr)
-	}
-	if reafProveKeyErr != nil {
-		return fmt.Errorf("fail to read reproving key: %v", reafProveKeyErr)
+		return nil, nil, err
 	}
-
-	err = Prove(fullWitness, pubWitness)
-
-	return err
+	return multiCircuit, multiAssigment, nil
 }
@@ -0,0 +1,122 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark/frontend"
+	"os"
+	"strconv"
+)
+
+// ErrCircuitChanged is returned when the compiled circuit differs from its baseline or
+// from the ccs hash it is expected to have.
+var ErrCircuitChanged = errors.New("circuit changed")
+
+// CircuitBaseline is the shape of a compiled circuit, committed next to the deployed
+// verifier contract so that CheckBaseline catches circuit drift.
+type CircuitBaseline struct {
+	Field          string `json:"field"`
+	Curve          string `json:"curve"`
+	Chunks         int    `json:"chunks"`
+	NbConstraints  int    `json:"nb_constraints"`
+	NbPublicInputs int    `json:"nb_public_inputs"`
+	// PublicInputLayout is the PUBLIC_INPUT_* layout, empty for the default one.
+	PublicInputLayout string `json:"public_input_layout,omitempty"`
+	CcsHash           string `json:"ccs_hash"`
+}
+
+// CheckBaseline compiles the circuit of the witness at WITNESS_JSON and compares it
+// with the baseline at BASELINE_PATH. It fails if the public inputs changed, which
+// breaks the deployed verifier, or if the constraints grew by more than
+// MAX_CONSTRAINT_GROWTH percent (0 by default). With BASELINE_UPDATE=1 it writes the
+// baseline instead.
+func CheckBaseline(ctx context.Context, field string, newCircuits func(data []byte) (frontend.Circuit, frontend.Circuit, error)) error {
+	maxGrowth := 0.0
+	if v := os.Getenv("MAX_CONSTRAINT_GROWTH"); v != "" {
+		var err error
+		maxGrowth, err = strconv.ParseFloat(v, 64)
+		if err != nil || maxGrowth < 0 {
+			return fmt.Errorf("invalid MAX_CONSTRAINT_GROWTH %q", v)
+		}
+	}
+	curve, circuit, ccs, err := compileWitnessCircuit(ctx, newCircuits)
+	if err != nil {
+		return err
+	}
+	layout, err := utils.PublicLayoutFromEnv()
+	if err != nil {
+		return err
+	}
+	hash, err := utils.CcsHash(ccs)
+	if err != nil {
+		return fmt.Errorf("fail to hash ccs: %w", err)
+	}
+	current := CircuitBaseline{
+		Field:             field,
+		Curve:             curve.String(),
+		Chunks:            chunkCount(circuit),
+		NbConstraints:     ccs.GetNbConstraints(),
+		NbPublicInputs:    ccs.GetNbPublicVariables() - 1,
+		PublicInputLayout: layout.String(),
+		CcsHash:           hash,
+	}
+	fmt.Printf("ccs: %d constraints, %d public inputs, hash %s\n", current.NbConstraints, current.NbPublicInputs, current.CcsHash)
+
+	path := os.Getenv("BASELINE_PATH")
+	if os.Getenv("BASELINE_UPDATE") == "1" {
+		data, err := json.MarshalIndent(current, "", "  ")
+		if err != nil {
+			return err
+		}
+		err = utils.WriteBytesAtomic(path, append(data, '\n'))
+		if err != nil {
+			return fmt.Errorf("fail to write baseline: %w", err)
+		}
+		fmt.Printf("baseline written to %s\n", path)
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no baseline at %s, write it with BASELINE_UPDATE=1", path)
+	}
+	if err != nil {
+		return fmt.Errorf("fail to read baseline: %w", err)
+	}
+	var baseline CircuitBaseline
+	err = json.Unmarshal(data, &baseline)
+	if err != nil {
+		return fmt.Errorf("fail to parse baseline %s: %w", path, err)
+	}
+	return compareBaseline(&baseline, &current, maxGrowth)
+}
+
+// compareBaseline returns an error describing how current drifted from baseline
+// beyond maxGrowth percent more constraints.
+func compareBaseline(baseline, current *CircuitBaseline, maxGrowth float64) error {
+	if baseline.Field != current.Field || baseline.Curve != current.Curve || baseline.Chunks != current.Chunks {
+		return fmt.Errorf("baseline is for field %s, curve %s and %d chunks, circuit for field %s, curve %s and %d chunks",
+			baseline.Field, baseline.Curve, baseline.Chunks, current.Field, current.Curve, current.Chunks)
+	}
+	if baseline.NbPublicInputs != current.NbPublicInputs || baseline.PublicInputLayout != current.PublicInputLayout {
+		return fmt.Errorf("%w: public inputs changed from %d (layout %q) to %d (layout %q), the deployed verifier would reject the proofs",
+			ErrCircuitChanged, baseline.NbPublicInputs, baseline.PublicInputLayout, current.NbPublicInputs, current.PublicInputLayout)
+	}
+	delta := current.NbConstraints - baseline.NbConstraints
+	growth := 0.0
+	if baseline.NbConstraints > 0 {
+		growth = float64(delta) * 100 / float64(baseline.NbConstraints)
+	}
+	if delta > 0 && (baseline.NbConstraints == 0 || growth > maxGrowth) {
+		return fmt.Errorf("%w: constraints grew from %d to %d (+%.2f%%), more than the %g%% allowed", ErrCircuitChanged, baseline.NbConstraints, current.NbConstraints, growth, maxGrowth)
+	}
+	if current.CcsHash == baseline.CcsHash {
+		fmt.Println("circuit matches the baseline")
+		return nil
+	}
+	fmt.Printf("circuit changed within the baseline: %+d constraints (%+.2f%%), public inputs unchanged; it needs a new setup, update the baseline with BASELINE_UPDATE=1\n", delta, growth)
+	return nil
+}
@@ -0,0 +1,61 @@
+package sdk
+
+import (
+	"context"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckBaseline(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	dir := t.TempDir()
+	constraints := filepath.Join(dir, "constraints.json")
+	witness := filepath.Join(dir, "witness.json")
+	assert.NoError(os.WriteFile(constraints, []byte("[]"), 0644))
+	assert.NoError(os.WriteFile(witness, []byte("{}"), 0644))
+	t.Setenv("CURVE", "bn254")
+	t.Setenv("CONSTRAINTS_JSON", constraints)
+	t.Setenv("WITNESS_JSON", witness)
+	t.Setenv("BASELINE_PATH", filepath.Join(dir, "ccs_baseline.json"))
+	t.Setenv("MAX_CONSTRAINT_GROWTH", "")
+	newCircuits := func(circuit frontend.Circuit) func([]byte) (frontend.Circuit, frontend.Circuit, error) {
+		return func([]byte) (frontend.Circuit, frontend.Circuit, error) {
+			return circuit, nil, nil
+		}
+	}
+
+	t.Setenv("BASELINE_UPDATE", "0")
+	assert.ErrorContains(CheckBaseline(context.Background(), "kb", newCircuits(&cubicCircuit{})), "no baseline")
+	t.Setenv("BASELINE_UPDATE", "1")
+	assert.NoError(CheckBaseline(context.Background(), "kb", newCircuits(&cubicCircuit{})))
+	t.Setenv("BASELINE_UPDATE", "0")
+	assert.NoError(CheckBaseline(context.Background(), "kb", newCircuits(&cubicCircuit{})))
+	assert.ErrorContains(CheckBaseline(context.Background(), "bb", newCircuits(&cubicCircuit{})), "baseline is for field kb")
+	assert.ErrorContains(CheckBaseline(context.Background(), "kb", newCircuits(&wideCubicCircuit{})), "public inputs changed from 1")
+	t.Setenv("MAX_CONSTRAINT_GROWTH", "-1")
+	assert.ErrorContains(CheckBaseline(context.Background(), "kb", newCircuits(&cubicCircuit{})), "invalid MAX_CONSTRAINT_GROWTH")
+}
+
+func TestCompareBaseline(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	baseline := CircuitBaseline{Field: "kb", Curve: "bn254", Chunks: 1, NbConstraints: 1000, NbPublicInputs: 2, CcsHash: "a"}
+	grown := baseline
+	grown.NbConstraints, grown.CcsHash = 1020, "b"
+	assert.ErrorContains(compareBaseline(&baseline, &grown, 0), "constraints grew from 1000 to 1020 (+2.00%)")
+	assert.ErrorContains(compareBaseline(&baseline, &grown, 1.5), "more than the 1.5% allowed")
+	assert.ErrorIs(compareBaseline(&baseline, &grown, 1.5), ErrCircuitChanged)
+	assert.NoError(compareBaseline(&baseline, &grown, 2))
+
+	shrunk := baseline
+	shrunk.NbConstraints, shrunk.CcsHash = 900, "b"
+	assert.NoError(compareBaseline(&baseline, &shrunk, 0))
+
+	relaid := baseline
+	relaid.PublicInputLayout = "digest-first"
+	assert.ErrorContains(compareBaseline(&baseline, &relaid, 100), "public inputs changed")
+}
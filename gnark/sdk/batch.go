@@ -0,0 +1,131 @@
+package sdk
+
+import (
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/groth16"
+	groth16_bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"github.com/consensys/gnark/backend/witness"
+	"math/big"
+)
+
+// VerifyBatch checks many proofs against the same vk. On BN254 the Groth16 equations
+// are combined with random weights and checked with a single multi-pairing, which is
+// much cheaper than verifying the proofs one by one. If the batch check fails, the
+// proofs are verified individually so the error names the first invalid proof.
+//
+// The batch check does not cover the Pedersen commitments of BSB22: keys with
+// commitment keys, e.g. of circuits compiled with -range-check lookup or decompose,
+// and keys of other curves are always verified one proof at a time, at the cost of
+// one pairing check per proof.
+func VerifyBatch(vk groth16.VerifyingKey, proofs []groth16.Proof, pubWitnesses []witness.Witness) error {
+	if len(proofs) != len(pubWitnesses) {
+		return fmt.Errorf("got %d proofs and %d public witnesses", len(proofs), len(pubWitnesses))
+	}
+	if len(proofs) == 0 {
+		return nil
+	}
+
+	if bnVk, ok := vk.(*groth16_bn254.VerifyingKey); ok && len(bnVk.CommitmentKeys) == 0 {
+		if verifyBatchBn254(bnVk, proofs, pubWitnesses) == nil {
+			return nil
+		}
+	}
+
+	hashToField, err := utils.HashToFieldFromEnv()
+	if err != nil {
+		return err
+	}
+	for i := range proofs {
+		err = groth16.Verify(proofs[i], vk, pubWitnesses[i], backend.WithVerifierHashToFieldFunction(hashToField))
+		if err != nil {
+			return fmt.Errorf("failed to verify proof %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// verifyBatchBn254 checks, for random r_j,
+//
+//	∏ e(r_j·A_j, B_j) · e(-Σr_j·α, β) · e(-Σr_j·L_j, γ) · e(-Σr_j·C_j, δ) == 1
+//
+// where L_j = K_0 + Σ_i x_ji·K_(i+1) is the public input term of proof j.
+func verifyBatchBn254(vk *groth16_bn254.VerifyingKey, proofs []groth16.Proof, pubWitnesses []witness.Witness) error {
+	n := len(proofs)
+	nbPublic := len(vk.G1.K) - 1
+
+	r := make([]fr.Element, n)
+	var rSum fr.Element
+	for j := range r {
+		_, err := r[j].SetRandom()
+		if err != nil {
+			return fmt.Errorf("fail to sample batch weight: %w", err)
+		}
+		rSum.Add(&rSum, &r[j])
+	}
+
+	// pairs (r_j·A_j, B_j), followed by the alpha, gamma and delta terms
+	g1 := make([]bn254.G1Affine, n, n+3)
+	g2 := make([]bn254.G2Affine, n, n+3)
+	cs := make([]bn254.G1Affine, n)
+	kScalars := make([]fr.Element, nbPublic+1)
+	kScalars[0] = rSum
+	for j := range proofs {
+		proof, ok := proofs[j].(*groth16_bn254.Proof)
+		if !ok {
+			return fmt.Errorf("proof %d is not a bn254 proof", j)
+		}
+		if len(proof.Commitments) != 0 {
+			return fmt.Errorf("proof %d has commitments", j)
+		}
+		if !proof.Ar.IsInSubGroup() || !proof.Bs.IsInSubGroup() || !proof.Krs.IsInSubGroup() {
+			return fmt.Errorf("proof %d has points outside of the subgroup", j)
+		}
+		public, ok := pubWitnesses[j].Vector().(fr.Vector)
+		if !ok {
+			return fmt.Errorf("public witness %d is not a bn254 witness", j)
+		}
+		if len(public) != nbPublic {
+			return fmt.Errorf("invalid witness %d size, got %d, expected %d", j, len(public), nbPublic)
+		}
+
+		rBig := r[j].BigInt(new(big.Int))
+		g1[j].ScalarMultiplication(&proof.Ar, rBig)
+		g2[j] = proof.Bs
+		cs[j] = proof.Krs
+		for i := range public {
+			var t fr.Element
+			t.Mul(&r[j], &public[i])
+			kScalars[i+1].Add(&kScalars[i+1], &t)
+		}
+	}
+
+	var alpha, kSum, cSum bn254.G1Affine
+	alpha.ScalarMultiplication(&vk.G1.Alpha, rSum.BigInt(new(big.Int)))
+	_, err := kSum.MultiExp(vk.G1.K, kScalars, ecc.MultiExpConfig{})
+	if err != nil {
+		return err
+	}
+	_, err = cSum.MultiExp(cs, r, ecc.MultiExpConfig{})
+	if err != nil {
+		return err
+	}
+	alpha.Neg(&alpha)
+	kSum.Neg(&kSum)
+	cSum.Neg(&cSum)
+	g1 = append(g1, alpha, kSum, cSum)
+	g2 = append(g2, vk.G2.Beta, vk.G2.Gamma, vk.G2.Delta)
+
+	ok, err := bn254.PairingCheck(g1, g2)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("pairing check failed")
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package sdk
+
+import (
+	"context"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	groth16_bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"testing"
+)
+
+func newBatch(assert *test.Assert, n int) (groth16.VerifyingKey, []groth16.Proof, []witness.Witness) {
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &cubicCircuit{})
+	assert.NoError(err)
+	pk, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	session := NewProvingSession(pk, vk, ccs)
+
+	var proofs []groth16.Proof
+	var pubWitnesses []witness.Witness
+	for x := 1; x <= n; x++ {
+		fullWitness, err := frontend.NewWitness(&cubicCircuit{X: x, Y: x*x*x + x + 5}, ecc.BN254.ScalarField())
+		assert.NoError(err)
+		pubWitness, err := fullWitness.Public()
+		assert.NoError(err)
+		pf, err := session.Prove(context.Background(), fullWitness)
+		assert.NoError(err)
+		proofs = append(proofs, pf)
+		pubWitnesses = append(pubWitnesses, pubWitness)
+	}
+	return vk, proofs, pubWitnesses
+}
+
+func TestVerifyBatch(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	vk, proofs, pubWitnesses := newBatch(assert, 4)
+	assert.NoError(VerifyBatch(vk, proofs, pubWitnesses))
+	assert.NoError(VerifyBatch(vk, nil, nil))
+
+	pubWitnesses[2], pubWitnesses[3] = pubWitnesses[3], pubWitnesses[2]
+	err := VerifyBatch(vk, proofs, pubWitnesses)
+	assert.Error(err)
+	assert.Contains(err.Error(), "proof 2")
+
+	err = VerifyBatch(vk, proofs, pubWitnesses[:3])
+	assert.Error(err)
+}
+
+func TestVerifyBatchInvalidProof(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	vk, proofs, pubWitnesses := newBatch(assert, 4)
+	// replace C of proof 1 by the one of proof 0: every point is still valid, only
+	// the groth16 equation of proof 1 breaks
+	forged := *proofs[1].(*groth16_bn254.Proof)
+	forged.Krs = proofs[0].(*groth16_bn254.Proof).Krs
+	proofs[1] = &forged
+
+	err := VerifyBatch(vk, proofs, pubWitnesses)
+	assert.Error(err)
+	assert.Contains(err.Error(), "proof 1")
+
+	// the batch check alone rejects it too, not only the individual fallback
+	err = verifyBatchBn254(vk.(*groth16_bn254.VerifyingKey), proofs, pubWitnesses)
+	assert.Error(err)
+}
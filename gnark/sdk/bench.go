@@ -0,0 +1,191 @@
+package sdk
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"os"
+	"runtime"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// BenchPhase is the cost of one phase of the proving pipeline.
+type BenchPhase struct {
+	Name string
+	Wall time.Duration
+	// CPU is the user and system time of all threads.
+	CPU time.Duration
+	// PeakRSS is the peak resident set size in bytes during the phase, or since the
+	// process started where the kernel cannot reset it.
+	PeakRSS uint64
+}
+
+// runBench solves, compiles, sets up (if BENCH_SETUP=1, otherwise the keys are read
+// from PK_PATH and VK_PATH), proves and verifies the witness, then prints the cost of
+// each phase as a table.
+func runBench(ctx context.Context, newCircuits func(data []byte) (frontend.Circuit, frontend.Circuit, error)) error {
+	curve, err := utils.CurveFromEnv()
+	if err != nil {
+		return err
+	}
+	data, err := utils.ReadWitnessJson(os.Getenv("WITNESS_JSON"))
+	if err != nil {
+		return fmt.Errorf("fail to read witness file: %w", err)
+	}
+	circuit, assigment, err := newCircuits(data)
+	if err != nil {
+		return err
+	}
+
+	var phases []BenchPhase
+	measure := func(name string, fn func() error) error {
+		phase, err := measurePhase(name, fn)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		phases = append(phases, phase)
+		return nil
+	}
+
+	err = measure("solve", func() error {
+		return isSolved(ctx, curve, circuit, assigment)
+	})
+	if err != nil {
+		return err
+	}
+
+	var ccs constraint.ConstraintSystem
+	err = measure("compile", func() (err error) {
+		ccs, err = compile(ctx, curve, circuit)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	var pk groth16.ProvingKey
+	var vk groth16.VerifyingKey
+	if os.Getenv("BENCH_SETUP") == "1" {
+		err = measure("setup", func() (err error) {
+			pk, vk, err = setup(ctx, ccs)
+			return err
+		})
+	} else {
+		err = measure("load keys", func() error {
+			pk = groth16.NewProvingKey(curve)
+			vk = groth16.NewVerifyingKey(curve)
+			err := utils.ReadProvingKey(os.Getenv("PK_PATH"), pk)
+			if err != nil {
+				return err
+			}
+			return utils.ReadVerifyingKey(os.Getenv("VK_PATH"), vk)
+		})
+	}
+	if err != nil {
+		return err
+	}
+	session := NewProvingSession(pk, vk, ccs)
+
+	fullWitness, err := frontend.NewWitness(assigment, curve.ScalarField())
+	if err != nil {
+		return fmt.Errorf("failed to get full witness: %w", err)
+	}
+	pubWitness, err := fullWitness.Public()
+	if err != nil {
+		return fmt.Errorf("failed to get public witness: %w", err)
+	}
+
+	var pf groth16.Proof
+	err = measure("prove", func() (err error) {
+		pf, err = session.Prove(ctx, fullWitness)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	err = measure("verify", func() error {
+		return session.Verify(pf, pubWitness)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("constraints: %d\n", ccs.GetNbConstraints())
+	return printBench(phases)
+}
+
+func measurePhase(name string, fn func() error) (BenchPhase, error) {
+	resetPeakRSS()
+	cpuStart := cpuTime()
+	start := time.Now()
+	err := fn()
+	if err != nil {
+		return BenchPhase{}, err
+	}
+	return BenchPhase{
+		Name:    name,
+		Wall:    time.Since(start),
+		CPU:     cpuTime() - cpuStart,
+		PeakRSS: peakRSS(),
+	}, nil
+}
+
+func printBench(phases []BenchPhase) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "phase\twall_ms\tcpu_ms\tpeak_rss_mb")
+	for _, p := range phases {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", p.Name, p.Wall.Milliseconds(), p.CPU.Milliseconds(), p.PeakRSS>>20)
+	}
+	return w.Flush()
+}
+
+func cpuTime() time.Duration {
+	var usage syscall.Rusage
+	err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
+
+// resetPeakRSS resets the kernel's high water mark of the process on Linux, so that
+// peakRSS reports the peak of the current phase.
+func resetPeakRSS() {
+	_ = os.WriteFile("/proc/self/clear_refs", []byte("5"), 0)
+}
+
+// peakRSS reads VmHWM on Linux and falls back to the lifetime maximum from getrusage.
+func peakRSS() uint64 {
+	status, err := os.ReadFile("/proc/self/status")
+	if err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(status))
+		for scanner.Scan() {
+			fields := bytes.Fields(scanner.Bytes())
+			if len(fields) >= 2 && string(fields[0]) == "VmHWM:" {
+				kb, err := strconv.ParseUint(string(fields[1]), 10, 64)
+				if err == nil {
+					return kb << 10
+				}
+			}
+		}
+	}
+
+	var usage syscall.Rusage
+	err = syscall.Getrusage(syscall.RUSAGE_SELF, &usage)
+	if err != nil {
+		return 0
+	}
+	// ru_maxrss is in bytes on macOS and in kilobytes elsewhere
+	if runtime.GOOS == "darwin" {
+		return uint64(usage.Maxrss)
+	}
+	return uint64(usage.Maxrss) << 10
+}
@@ -0,0 +1,35 @@
+package sdk
+
+import (
+	"context"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBench(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	witnessPath := filepath.Join(t.TempDir(), "witness.json")
+	assert.NoError(os.WriteFile(witnessPath, []byte("{}"), 0644))
+	t.Setenv("WITNESS_JSON", witnessPath)
+	t.Setenv("BENCH_SETUP", "1")
+	t.Setenv("CURVE", "bn254")
+
+	err := runBench(context.Background(), func([]byte) (frontend.Circuit, frontend.Circuit, error) {
+		return &cubicCircuit{}, &cubicCircuit{X: 3, Y: 35}, nil
+	})
+	assert.NoError(err)
+
+	phase, err := measurePhase("alloc", func() error {
+		buf := make([]byte, 64<<20)
+		for i := range buf {
+			buf[i] = 1
+		}
+		return nil
+	})
+	assert.NoError(err)
+	assert.GreaterOrEqual(phase.PeakRSS, uint64(64<<20))
+}
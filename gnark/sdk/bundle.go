@@ -0,0 +1,190 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"os"
+)
+
+// ExportBundle solves the witness json at WITNESS_JSON and writes the full witness,
+// its vkey hash and the header of the ccs it needs to BUNDLE_PATH, so a machine
+// without the witness json and constraints can prove it with ProveBundle.
+func ExportBundle(ctx context.Context, field string, newCircuits func(data []byte) (frontend.Circuit, frontend.Circuit, error)) error {
+	curve, err := utils.CurveFromEnv()
+	if err != nil {
+		return err
+	}
+	circuit, fullWitness, pubWitness, err := solveWitnessFile(ctx, curve, newCircuits)
+	if err != nil {
+		return err
+	}
+
+	ccsHeader, err := newCcsHeader(curve, field, circuit)
+	if err != nil {
+		return err
+	}
+	public, err := utils.PublicInputs(pubWitness)
+	if err != nil {
+		return err
+	}
+	layout, err := utils.PublicLayoutFromEnv()
+	if err != nil {
+		return err
+	}
+	vkeyHash, _, err := layout.Unpack(public)
+	if err != nil {
+		return err
+	}
+	header := &utils.BundleHeader{
+		Version:  utils.BundleFormatVersion,
+		VkeyHash: vkeyHash.String(),
+		Ccs:      ccsHeader,
+	}
+	err = utils.WriteBundle(os.Getenv("BUNDLE_PATH"), header, fullWitness)
+	if err != nil {
+		return fmt.Errorf("fail to write bundle: %w", err)
+	}
+	fmt.Printf("bundle of program %s written to %s\n", header.VkeyHash, os.Getenv("BUNDLE_PATH"))
+	return nil
+}
+
+// ProveBundle proves the bundle at BUNDLE_PATH with the keys at PK_PATH and VK_PATH and
+// the ccs at CCS_PATH, which must have been written by setup for the bundle's circuit.
+// Nothing is solved or compiled.
+func ProveBundle(ctx context.Context) error {
+	curve, err := utils.CurveFromEnv()
+	if err != nil {
+		return err
+	}
+	header, fullWitness, err := utils.ReadBundle(os.Getenv("BUNDLE_PATH"), curve)
+	if err != nil {
+		return fmt.Errorf("fail to read bundle: %w", err)
+	}
+	pubWitness, err := fullWitness.Public()
+	if err != nil {
+		return fmt.Errorf("failed to get public witness: %w", err)
+	}
+	fmt.Printf("proving bundle of program %s\n", header.VkeyHash)
+	timings := newProofTimings()
+
+	ccsPath := os.Getenv("CCS_PATH")
+	ccsHeader, err := utils.ReadCcsHeader(ccsPath)
+	if err != nil {
+		return fmt.Errorf("fail to read ccs header: %w", err)
+	}
+	if ccsHeader == nil {
+		return fmt.Errorf("ccs at %s has no header, rerun setup to prove bundles", ccsPath)
+	}
+	err = ccsHeader.CheckCompatible(header.Ccs)
+	if err != nil {
+		return fmt.Errorf("ccs at %s does not match the bundle: %w", ccsPath, err)
+	}
+	err = checkBeforeProve(curve, ccsPath)
+	if err != nil {
+		return err
+	}
+	var session *ProvingSession
+	err = addWallTime(&timings.LoadMs, func() (err error) {
+		session, err = LoadProvingSession(curve, os.Getenv("PK_PATH"), os.Getenv("VK_PATH"), ccsPath)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return Prove(ctx, session, os.Getenv("BUNDLE_PATH"), fullWitness, pubWitness, timings)
+}
+
+// ExportWitness solves the witness json at WITNESS_JSON and writes the full gnark
+// witness to WITNESS_BIN_PATH.
+func ExportWitness(ctx context.Context, newCircuits func(data []byte) (frontend.Circuit, frontend.Circuit, error)) error {
+	curve, err := utils.CurveFromEnv()
+	if err != nil {
+		return err
+	}
+	_, fullWitness, pubWitness, err := solveWitnessFile(ctx, curve, newCircuits)
+	if err != nil {
+		return err
+	}
+	err = utils.WriteWitness(os.Getenv("WITNESS_BIN_PATH"), fullWitness)
+	if err != nil {
+		return fmt.Errorf("fail to write witness: %w", err)
+	}
+	fmt.Printf("witness with public inputs %v written to %s\n", pubWitness, os.Getenv("WITNESS_BIN_PATH"))
+	return nil
+}
+
+// ProveWitness proves the full witness written by witness-export at WITNESS_BIN_PATH
+// with the keys at PK_PATH and VK_PATH and the ccs at CCS_PATH, skipping the witness
+// json, solving and witness construction.
+func ProveWitness(ctx context.Context) error {
+	curve, err := utils.CurveFromEnv()
+	if err != nil {
+		return err
+	}
+	fullWitness, err := utils.ReadWitness(os.Getenv("WITNESS_BIN_PATH"), curve)
+	if err != nil {
+		return fmt.Errorf("fail to read witness: %w", err)
+	}
+	pubWitness, err := fullWitness.Public()
+	if err != nil {
+		return fmt.Errorf("failed to get public witness: %w", err)
+	}
+	err = checkBeforeProve(curve, os.Getenv("CCS_PATH"))
+	if err != nil {
+		return err
+	}
+	timings := newProofTimings()
+	var session *ProvingSession
+	err = addWallTime(&timings.LoadMs, func() (err error) {
+		session, err = LoadProvingSession(curve, os.Getenv("PK_PATH"), os.Getenv("VK_PATH"), os.Getenv("CCS_PATH"))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	err = checkWitnessShape(session.ccs, pubWitness)
+	if err != nil {
+		return err
+	}
+	return Prove(ctx, session, os.Getenv("WITNESS_BIN_PATH"), fullWitness, pubWitness, timings)
+}
+
+// checkWitnessShape fails if pubWitness does not have the public inputs of ccs, as
+// gnark only checks the total size of the witness.
+func checkWitnessShape(ccs constraint.ConstraintSystem, pubWitness witness.Witness) error {
+	public, err := utils.PublicInputs(pubWitness)
+	if err != nil {
+		return err
+	}
+	if len(public) != ccs.GetNbPublicVariables()-1 {
+		return fmt.Errorf("witness has %d public inputs, ccs expects %d", len(public), ccs.GetNbPublicVariables()-1)
+	}
+	return nil
+}
+
+// solveWitnessFile reads the witness json at WITNESS_JSON, checks it solves its circuit
+// and returns the circuit and witnesses.
+func solveWitnessFile(ctx context.Context, curve ecc.ID, newCircuits func(data []byte) (frontend.Circuit, frontend.Circuit, error)) (circuit frontend.Circuit, fullWitness, pubWitness witness.Witness, err error) {
+	data, err := utils.ReadWitnessJson(os.Getenv("WITNESS_JSON"))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("fail to read witness file: %w", err)
+	}
+	circuit, assigment, err := newCircuits(data)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	err = isSolved(ctx, curve, circuit, assigment)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to solve: %w", err)
+	}
+	fullWitness, pubWitness, err = newWitnesses(curve, assigment)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get full witness: %w", err)
+	}
+	return circuit, fullWitness, pubWitness, nil
+}
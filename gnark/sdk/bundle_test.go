@@ -0,0 +1,95 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type wideCubicCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+	Z frontend.Variable `gnark:",public"`
+}
+
+func (c *wideCubicCircuit) Define(api frontend.API) error {
+	x3 := api.Mul(c.X, c.X, c.X)
+	api.AssertIsEqual(c.Y, api.Add(x3, c.X, 5))
+	api.AssertIsEqual(c.Z, c.X)
+	return nil
+}
+
+func TestProveWitness(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &cubicCircuit{})
+	assert.NoError(err)
+	pk, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+
+	dir := t.TempDir()
+	t.Setenv("CURVE", "bn254")
+	t.Setenv("PK_PATH", filepath.Join(dir, "vm_pk"))
+	t.Setenv("VK_PATH", filepath.Join(dir, "vm_vk"))
+	t.Setenv("CCS_PATH", filepath.Join(dir, "vm_ccs"))
+	t.Setenv("WITNESS_BIN_PATH", filepath.Join(dir, "witness.bin"))
+	t.Setenv("PROOF_PATH", filepath.Join(dir, "proof.data"))
+	assert.NoError(utils.WriteProvingKey(os.Getenv("PK_PATH"), pk))
+	assert.NoError(utils.WriteVerifyingKey(os.Getenv("VK_PATH"), vk))
+	assert.NoError(utils.WriteCcs(os.Getenv("CCS_PATH"), ccs))
+
+	fullWitness, err := frontend.NewWitness(&cubicCircuit{X: 3, Y: 35}, ecc.BN254.ScalarField())
+	assert.NoError(err)
+	assert.NoError(utils.WriteWitness(os.Getenv("WITNESS_BIN_PATH"), fullWitness))
+	assert.NoError(ProveWitness(context.Background()))
+	_, err = os.Stat(os.Getenv("PROOF_PATH"))
+	assert.NoError(err)
+
+	// the manifest binds the proof to its witness and keys
+	manifest, err := utils.ReadProofManifest(os.Getenv("PROOF_PATH") + utils.ManifestExt)
+	assert.NoError(err)
+	assert.Equal(os.Getenv("WITNESS_BIN_PATH"), manifest.Witness.Path)
+	assert.Equal(os.Getenv("CCS_PATH"), manifest.Ccs.Path)
+	assert.Equal([]string{"35"}, manifest.PublicInputs)
+	assert.Equal("bn254", manifest.Curve)
+	assert.Nil(manifest.Signature)
+	var out bytes.Buffer
+	assert.NoError(CheckManifest(&out))
+	assert.Contains(out.String(), "match")
+
+	// the timings report the phases that ran and the size of the circuit
+	data, err := os.ReadFile(filepath.Join(dir, TimingsName))
+	assert.NoError(err)
+	var timings ProofTimings
+	assert.NoError(json.Unmarshal(data, &timings))
+	assert.Equal(ccs.GetNbConstraints(), timings.Constraints)
+	assert.Equal(2, timings.PublicVariables)
+	assert.Zero(timings.SolveMs)
+	assert.Zero(timings.CompileMs)
+	assert.GreaterOrEqual(timings.TotalMs, timings.LoadMs+timings.ProveMs+timings.VerifyMs)
+	assert.Contains(string(data), `"prove_ms"`)
+	assert.NotContains(string(data), `"solve_ms"`)
+	t.Setenv("PROOF_TIMINGS", "0")
+	assert.NoError(os.Remove(filepath.Join(dir, TimingsName)))
+	assert.NoError(ProveWitness(context.Background()))
+	_, err = os.Stat(filepath.Join(dir, TimingsName))
+	assert.True(os.IsNotExist(err))
+
+	// a witness of another circuit is rejected before proving
+	wide, err := frontend.NewWitness(&wideCubicCircuit{X: 3, Y: 35, Z: 3}, ecc.BN254.ScalarField())
+	assert.NoError(err)
+	assert.NoError(utils.WriteWitness(os.Getenv("WITNESS_BIN_PATH"), wide))
+	err = ProveWitness(context.Background())
+	assert.Error(err)
+	assert.Contains(err.Error(), "witness has 2 public inputs, ccs expects 1")
+	assert.ErrorIs(CheckManifest(&out), ErrManifestMismatch, "the witness changed")
+}
@@ -0,0 +1,75 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"os"
+)
+
+// PrintCcsHash compiles the circuit of the witness at WITNESS_JSON with the current
+// options and prints the hash of its constraints. It fails if the hash differs from
+// EXPECTED_CCS_HASH, when set, or from the hash setup recorded in the ccs at CCS_PATH,
+// when there is one: keys of that setup do not prove this circuit.
+func PrintCcsHash(ctx context.Context, newCircuits func(data []byte) (frontend.Circuit, frontend.Circuit, error)) error {
+	_, _, ccs, err := compileWitnessCircuit(ctx, newCircuits)
+	if err != nil {
+		return err
+	}
+	hash, err := utils.CcsHash(ccs)
+	if err != nil {
+		return fmt.Errorf("fail to hash ccs: %w", err)
+	}
+	fmt.Printf("ccs: %d constraints, hash %s\n", ccs.GetNbConstraints(), hash)
+
+	if expected := os.Getenv("EXPECTED_CCS_HASH"); expected != "" {
+		if hash != expected {
+			return fmt.Errorf("%w: ccs hash %s, expected %s", ErrCircuitChanged, hash, expected)
+		}
+		fmt.Println("ccs hash matches the expected hash")
+	}
+
+	ccsPath := os.Getenv("CCS_PATH")
+	header, err := utils.ReadCcsHeader(ccsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("fail to read ccs header: %w", err)
+	}
+	if header == nil || header.CcsHash == "" {
+		fmt.Printf("ccs at %s records no hash, rerun setup to record it\n", ccsPath)
+		return nil
+	}
+	if hash != header.CcsHash {
+		return fmt.Errorf("%w: ccs hash %s, setup of %s compiled %s", ErrCircuitChanged, hash, ccsPath, header.CcsHash)
+	}
+	fmt.Printf("ccs hash matches the setup of %s\n", ccsPath)
+	return nil
+}
+
+// compileWitnessCircuit compiles the circuit of the witness at WITNESS_JSON with the
+// current options.
+func compileWitnessCircuit(ctx context.Context, newCircuits func(data []byte) (frontend.Circuit, frontend.Circuit, error)) (ecc.ID, frontend.Circuit, constraint.ConstraintSystem, error) {
+	curve, err := utils.CurveFromEnv()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	data, err := utils.ReadWitnessJson(os.Getenv("WITNESS_JSON"))
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("fail to read witness file: %w", err)
+	}
+	circuit, _, err := newCircuits(data)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	ccs, err := compile(ctx, curve, circuit)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("fail to compile frontend: %w", err)
+	}
+	return curve, circuit, ccs, nil
+}
@@ -0,0 +1,54 @@
+package sdk
+
+import (
+	"context"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrintCcsHash(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	dir := t.TempDir()
+	constraints := filepath.Join(dir, "constraints.json")
+	witness := filepath.Join(dir, "witness.json")
+	assert.NoError(os.WriteFile(constraints, []byte("[]"), 0644))
+	assert.NoError(os.WriteFile(witness, []byte("{}"), 0644))
+	t.Setenv("CURVE", "bn254")
+	t.Setenv("CONSTRAINTS_JSON", constraints)
+	t.Setenv("WITNESS_JSON", witness)
+	t.Setenv("CCS_PATH", filepath.Join(dir, "vm_ccs"))
+	t.Setenv("EXPECTED_CCS_HASH", "")
+	newCircuits := func(circuit frontend.Circuit) func([]byte) (frontend.Circuit, frontend.Circuit, error) {
+		return func([]byte) (frontend.Circuit, frontend.Circuit, error) {
+			return circuit, nil, nil
+		}
+	}
+
+	// without a ccs file the hash is only printed
+	assert.NoError(PrintCcsHash(context.Background(), newCircuits(&cubicCircuit{})))
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &cubicCircuit{})
+	assert.NoError(err)
+	assert.NoError(writeCcs(utils.WriteArtifact, ecc.BN254, "kb", &cubicCircuit{}, ccs))
+	header, err := utils.ReadCcsHeader(os.Getenv("CCS_PATH"))
+	assert.NoError(err)
+	hash, err := utils.CcsHash(ccs)
+	assert.NoError(err)
+	assert.Equal(hash, header.CcsHash)
+
+	assert.NoError(PrintCcsHash(context.Background(), newCircuits(&cubicCircuit{})))
+	err = PrintCcsHash(context.Background(), newCircuits(&wideCubicCircuit{}))
+	assert.ErrorContains(err, "setup of "+os.Getenv("CCS_PATH")+" compiled "+hash)
+
+	t.Setenv("EXPECTED_CCS_HASH", hash)
+	assert.NoError(PrintCcsHash(context.Background(), newCircuits(&cubicCircuit{})))
+	t.Setenv("EXPECTED_CCS_HASH", "00")
+	assert.ErrorContains(PrintCcsHash(context.Background(), newCircuits(&cubicCircuit{})), "expected 00")
+}
@@ -0,0 +1,103 @@
+package sdk
+
+import (
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/brevis-network/pico/gnark/verifier_core"
+	"github.com/consensys/gnark-crypto/ecc"
+	"io"
+	"math/big"
+	"os"
+)
+
+// CheckWitness checks the witness of WITNESS_JSON against the constraints outside the
+// circuit: it evaluates the constraints over every chunk and compares the public
+// values they commit to with the witness's vkey hash and committed values digest. It
+// takes seconds where solving takes minutes, and fails on what would fail the solve.
+func CheckWitness(field string, nativeField verifier_core.NativeField) error {
+	data, err := utils.ReadWitnessJson(os.Getenv("WITNESS_JSON"))
+	if err != nil {
+		return fmt.Errorf("fail to read witness file: %w", err)
+	}
+	passed, err := checkWitness(os.Stdout, field, nativeField, data)
+	if err != nil {
+		return err
+	}
+	if !passed {
+		return fmt.Errorf("%w: witness check failed", ErrUnsatisfied)
+	}
+	return nil
+}
+
+// checkWitness writes the report of the checks of a witness json to w. It fails if
+// the witness or the constraints cannot be read, and returns whether every check
+// passed otherwise.
+func checkWitness(w io.Writer, field string, nativeField verifier_core.NativeField, data []byte) (bool, error) {
+	single, multi, err := utils.ParseWitness(data)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse witness json: %w", err)
+	}
+	chunks := []utils.WitnessInput{}
+	version := 0
+	if single != nil {
+		chunks = append(chunks, *single)
+		version = single.FormatVersion()
+	} else {
+		chunks = multi.Chunks
+		version = multi.FormatVersion()
+	}
+	file, err := utils.LoadConstraints(chunks[0].FriConfig)
+	if err != nil {
+		return false, fmt.Errorf("fail to load constraints: %w", err)
+	}
+	fmt.Fprintf(w, "witness version %d, %d chunk(s), %d constraints\n", version, len(chunks), len(file.Constraints))
+
+	passed := true
+	report := func(name string, err error) {
+		if err != nil {
+			passed = false
+			fmt.Fprintf(w, "%-40s FAIL: %v\n", name, err)
+			return
+		}
+		fmt.Fprintf(w, "%-40s pass\n", name)
+	}
+
+	_, err = circuitVersions[field].Select(version)
+	report("witness version", err)
+	if multi != nil {
+		report("chunks", multi.Validate())
+	}
+	for i, chunk := range chunks {
+		prefix := ""
+		if multi != nil {
+			prefix = fmt.Sprintf("chunk %d: ", i)
+		}
+		commitments, err := nativeField.Evaluate(file, chunk)
+		report(prefix+"constraints", err)
+		if err != nil {
+			continue
+		}
+		report(prefix+"vkey hash", checkCommitment(chunk.VkeyHash, commitments.VkeyHash))
+		report(prefix+"committed values digest", checkCommitment(chunk.CommittedValuesDigest, commitments.CommittedValuesDigest))
+	}
+
+	if passed {
+		fmt.Fprintln(w, "witness check passed")
+	} else {
+		fmt.Fprintln(w, "witness check failed")
+	}
+	return passed, nil
+}
+
+// checkCommitment compares a public value of the witness with the value the
+// constraints commit to, as field elements like the circuit.
+func checkCommitment(witness string, committed *big.Int) error {
+	value, ok := new(big.Int).SetString(witness, 0)
+	if !ok {
+		return fmt.Errorf("invalid witness value %q", witness)
+	}
+	if value.Mod(value, ecc.BN254.ScalarField()).Cmp(committed) != 0 {
+		return fmt.Errorf("witness has %s, the constraints commit to %s", witness, committed)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package sdk
+
+import (
+	"bytes"
+	"github.com/brevis-network/pico/gnark/koalabear_verifier"
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckWitness(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	constraintsFile := filepath.Join(t.TempDir(), "constraints.json")
+	assert.NoError(os.WriteFile(constraintsFile, []byte(`[
+		{"opcode": "WitnessF", "args": [["a"], ["0"]]},
+		{"opcode": "MulF", "args": [["a"], ["a"], ["a"]]},
+		{"opcode": "CircuitFelt2Var", "args": [["vk"], ["a"]]},
+		{"opcode": "CommitVkeyHash", "args": [["vk"]]},
+		{"opcode": "WitnessV", "args": [["digest"], ["0"]]},
+		{"opcode": "CommitCommitedValuesDigest", "args": [["digest"]]}
+	]`), 0644))
+	t.Setenv("CONSTRAINTS_JSON", constraintsFile)
+
+	var out bytes.Buffer
+	passed, err := checkWitness(&out, "kb", koalabear_verifier.NativeField, []byte(`{"vars": ["5"], "felts": ["3"], "vkey_hash": "0x9", "committed_values_digest": "5"}`))
+	assert.NoError(err)
+	assert.True(passed, out.String())
+	assert.Contains(out.String(), "witness check passed")
+
+	out.Reset()
+	passed, err = checkWitness(&out, "kb", koalabear_verifier.NativeField, []byte(`{"chunks": [
+		{"vars": ["5"], "felts": ["3"], "vkey_hash": "9", "committed_values_digest": "5"},
+		{"vars": ["6"], "felts": ["4"], "vkey_hash": "9", "committed_values_digest": "6"}
+	]}`))
+	assert.NoError(err)
+	assert.False(passed)
+	assert.Contains(out.String(), "chunk 0: vkey hash")
+	assert.Regexp("chunk 1: vkey hash +FAIL: witness has 9, the constraints commit to 16", out.String())
+	assert.Regexp("chunk 1: committed values digest +pass", out.String())
+	assert.Contains(out.String(), "witness check failed")
+
+	// felts are 31 bits in the circuit
+	out.Reset()
+	passed, err = checkWitness(&out, "kb", koalabear_verifier.NativeField, []byte(`{"version": 2, "vars": ["5"], "felts": ["2147483648"], "vkey_hash": "9", "committed_values_digest": "5"}`))
+	assert.NoError(err)
+	assert.False(passed)
+	assert.Regexp("witness version +FAIL: unsupported witness version 2", out.String())
+	assert.Regexp(`constraints +FAIL: felt 0: invalid felt "2147483648"`, out.String())
+}
@@ -1,26 +1,27 @@
 package sdk
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/brevis-network/pico/gnark/onchain"
 	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/brevis-network/pico/gnark/verifier_core"
 	"github.com/consensys/gnark-crypto/ecc"
-	"github.com/consensys/gnark/backend"
 	"github.com/consensys/gnark/backend/groth16"
 	groth16_bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"github.com/consensys/gnark/backend/solidity"
 	"github.com/consensys/gnark/backend/witness"
-	bn254cs "github.com/consensys/gnark/constraint/bn254"
-	"golang.org/x/crypto/sha3"
-	"io/ioutil"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/debug"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"io"
 	"os"
-	"sync"
-)
-
-var (
-	Pk  = groth16.NewProvingKey(ecc.BN254)
-	Vk  = groth16.NewVerifyingKey(ecc.BN254)
-	Ccs = new(bn254cs.R1CS)
-
-	loadLock sync.WaitGroup
+	"strconv"
 )
 
 type PicoGroth16Proof struct {
@@ -29,49 +30,556 @@ type PicoGroth16Proof struct {
 	Proof                 string // hex
 }
 
-func ExportSolidify() error {
-	err := utils.ReadVerifyingKey(os.Getenv("VK_PATH"), Vk)
+// ExportSolidify writes the solidity verifier of the vk at VK_PATH to SOLIDITY_PATH,
+// identified by the metadata constants of the wrapper of field, and the contracts
+// exported with it.
+func ExportSolidify(ctx context.Context, field string) error {
+	curve, err := utils.CurveFromEnv()
 	if err != nil {
-		return fmt.Errorf("failed to read verifiing key: %v", err)
+		return err
+	}
+	vk := groth16.NewVerifyingKey(curve)
+	err = utils.ReadVerifyingKey(os.Getenv("VK_PATH"), vk)
+	if err != nil {
+		return fmt.Errorf("failed to read verifiing key: %w", err)
 	}
 
-	f, err := os.Create(os.Getenv("SOLIDITY_PATH"))
-	defer f.Close()
+	err = ctx.Err()
+	if err != nil {
+		return err
+	}
+	var opts []solidity.ExportOption
+	if keys, _ := utils.CommitmentKeys(vk); len(keys) > 0 {
+		// the contract hashes the commitment like the prover
+		if utils.HashToFieldName() == utils.HashToFieldPoseidon2 {
+			return fmt.Errorf("the solidity verifier only hashes commitments with keccak256 or sha256, not %s", utils.HashToFieldName())
+		}
+		hashToField, err := utils.HashToFieldFromEnv()
+		if err != nil {
+			return err
+		}
+		opts = append(opts, solidity.WithHashToFieldFunction(hashToField))
+	}
+	var verifier bytes.Buffer
+	err = vk.ExportSolidity(&verifier, opts...)
+	if err != nil {
+		return fmt.Errorf("fail to export solidity: %w", err)
+	}
+	metadata, err := onchain.NewVerifierMetadata(field, vk)
+	if err != nil {
+		return err
+	}
+	solidity, err := metadata.Embed(verifier.Bytes(), "Verifier")
+	if err != nil {
+		return err
+	}
+	err = utils.WriteArtifact(os.Getenv("SOLIDITY_PATH"), func(w io.Writer) error {
+		_, err := w.Write(solidity)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("fail to solidify file: %v", err)
+		return fmt.Errorf("fail to export solidity: %w", err)
+	}
+	if path := os.Getenv("BATCH_VERIFIER_SOL_PATH"); path != "" {
+		err = utils.WriteArtifact(path, func(w io.Writer) error {
+			return utils.WriteBatchVerifierSolidity(w, vk)
+		})
+		if err != nil {
+			return fmt.Errorf("fail to export batch verifier solidity: %w", err)
+		}
+	}
+	if os.Getenv("FOUNDRY_TEST_PATH") != "" {
+		err = ExportFoundryTest()
+		if err != nil {
+			return err
+		}
 	}
 
-	err = Vk.ExportSolidity(f)
+	// single chunk wrappers get a library packing their public inputs
+	layout, err := utils.PublicLayoutFromEnv()
+	if err != nil {
+		return err
+	}
+	if vk.NbPublicWitness() != layout.NbPublicInputs() || os.Getenv("PUBLIC_INPUTS_SOL_PATH") == "" {
+		return nil
+	}
+	err = utils.WriteArtifact(os.Getenv("PUBLIC_INPUTS_SOL_PATH"), layout.WriteSolidity)
+	if err != nil {
+		return fmt.Errorf("fail to export public inputs solidity: %w", err)
+	}
+	// IPicoVerifier takes the digest, not the public values the wrapper exposes
+	if layout.PublicValues > 0 {
+		return nil
+	}
+	return exportPicoVerifierAdapter(vk, layout)
+}
+
+// exportPicoVerifierAdapter writes the IPicoVerifier interface to
+// PICO_INTERFACE_SOL_PATH and its adapter of the verifier at SOLIDITY_PATH, packing
+// the public inputs with the library at PUBLIC_INPUTS_SOL_PATH, to
+// PICO_ADAPTER_SOL_PATH.
+func exportPicoVerifierAdapter(vk groth16.VerifyingKey, layout utils.PublicLayout) error {
+	interfacePath, adapterPath := os.Getenv("PICO_INTERFACE_SOL_PATH"), os.Getenv("PICO_ADAPTER_SOL_PATH")
+	if interfacePath == "" || adapterPath == "" {
+		return nil
+	}
+	err := utils.WriteArtifact(interfacePath, onchain.WritePicoVerifierInterface)
+	if err != nil {
+		return fmt.Errorf("fail to export verifier interface solidity: %w", err)
+	}
+	err = utils.WriteArtifact(adapterPath, func(w io.Writer) error {
+		return onchain.WritePicoVerifierAdapter(w, solidityImport(adapterPath, interfacePath), solidityImport(adapterPath, os.Getenv("SOLIDITY_PATH")),
+			solidityImport(adapterPath, os.Getenv("PUBLIC_INPUTS_SOL_PATH")), onchain.HasCommitment(vk), layout.NbPublicInputs())
+	})
+	if err != nil {
+		return fmt.Errorf("fail to export verifier adapter solidity: %w", err)
+	}
+	return nil
+}
+
+// ExportCommitmentKey writes the commitment keys of the vk at VK_PATH as json to
+// COMMITMENT_KEY_PATH and as Solidity constants to COMMITMENT_KEY_SOL_PATH.
+func ExportCommitmentKey() error {
+	curve, err := utils.CurveFromEnv()
+	if err != nil {
+		return err
+	}
+	vk := groth16.NewVerifyingKey(curve)
+	err = utils.ReadVerifyingKey(os.Getenv("VK_PATH"), vk)
+	if err != nil {
+		return fmt.Errorf("failed to read verifying key: %w", err)
+	}
+	keys, err := utils.CommitmentKeys(vk)
+	if err != nil {
+		return err
+	}
+	// gnark's solidity verifier supports a single commitment too
+	if len(keys) != 1 {
+		return fmt.Errorf("vk has %d commitments, expected 1, set up with -range-check lookup", len(keys))
+	}
+
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = utils.WriteArtifact(os.Getenv("COMMITMENT_KEY_PATH"), func(w io.Writer) error {
+		_, err := w.Write(append(data, '\n'))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("fail to write commitment key: %w", err)
+	}
+	err = utils.WriteArtifact(os.Getenv("COMMITMENT_KEY_SOL_PATH"), func(w io.Writer) error {
+		return utils.WriteCommitmentKeySolidity(w, keys[0])
+	})
 	if err != nil {
-		return fmt.Errorf("fail to export solidity: %v", err)
+		return fmt.Errorf("fail to write commitment key solidity: %w", err)
 	}
+	fmt.Printf("commitment key written to %s and %s\n", os.Getenv("COMMITMENT_KEY_PATH"), os.Getenv("COMMITMENT_KEY_SOL_PATH"))
 	return nil
 }
 
-func Prove(fullWitness, pubWitness witness.Witness) error {
-	pf, err := groth16.Prove(Ccs, Pk, fullWitness, backend.WithProverHashToFieldFunction(sha3.NewLegacyKeccak256()))
+// checkBeforeProve fails before the keys at PK_PATH and ccsPath are loaded if they do
+// not fit in MAX_MEMORY or the prover key of PROVER_KEY_FILE can not sign the proofs.
+func checkBeforeProve(curve ecc.ID, ccsPath string) error {
+	err := checkMaxMemory(curve, ccsPath)
+	if err != nil {
+		return err
+	}
+	_, err = utils.ProverKeyFromEnv()
+	return err
+}
+
+// formatProof renders the proof in the format of PROOF_FORMAT, warning when the legacy
+// format leaves out the commitment of the proof.
+func formatProof(pf groth16.Proof, pubWitness witness.Witness) ([]byte, error) {
+	format := os.Getenv("PROOF_FORMAT")
+	res, err := utils.FormatProof(format, pf, pubWitness)
 	if err != nil {
-		return fmt.Errorf("failed to prove: %v", err)
+		return nil, err
+	}
+	if format == "" || format == utils.ProofFormatLegacy {
+		_, _, _, commitment, _, err := utils.ExportProof(pf)
+		if err == nil && commitment[0] != nil {
+			fmt.Println("the legacy proof format leaves out the proof's commitment, use the json proof to verify it")
+		}
+	}
+	return res, nil
+}
+
+// writeProofSignature writes the detached signature of the proof file at proofPath
+// when PROVER_KEY_FILE is set and the proof format does not carry it.
+func writeProofSignature(proofPath string, pf groth16.Proof, pubWitness witness.Witness) error {
+	signature, err := utils.FormatProofSignature(os.Getenv("PROOF_FORMAT"), pf, pubWitness)
+	if err != nil {
+		return fmt.Errorf("fail to sign proof: %w", err)
+	}
+	if signature == nil {
+		return nil
 	}
+	return utils.WriteArtifact(proofPath+utils.SignatureExt, func(w io.Writer) error {
+		_, err := w.Write(signature)
+		return err
+	})
+}
 
-	err = groth16.Verify(pf, Vk, pubWitness, backend.WithVerifierHashToFieldFunction(sha3.NewLegacyKeccak256()))
+// writeProofManifest writes the manifest of the proof at proofPath, proved by session
+// from the witness file at witnessPath, unless PROOF_MANIFEST=0.
+func writeProofManifest(session *ProvingSession, witnessPath, proofPath string, pubWitness witness.Witness) error {
+	if os.Getenv("PROOF_MANIFEST") == "0" {
+		return nil
+	}
+	inputs, err := utils.PublicInputs(pubWitness)
+	if err != nil {
+		return err
+	}
+	publicInputs := make([]string, len(inputs))
+	for i, input := range inputs {
+		publicInputs[i] = input.String()
+	}
+	manifest, err := utils.NewProofManifest(session.Curve(), witnessPath, os.Getenv("VK_PATH"), session.CcsPath(), proofPath, publicInputs)
 	if err != nil {
-		return fmt.Errorf("failed to verify proof: %v", err)
+		return fmt.Errorf("fail to write manifest: %w", err)
 	}
+	return utils.WriteProofManifest(manifest)
+}
 
-	res, err := utils.GetAggOnChainProof(pf, pubWitness)
+// Prove proves fullWitness, read from the file at witnessPath, and writes the proof to
+// PROOF_PATH with its signature, manifest and timings, those of the phases before the
+// proof taken from timings, nil if there were none.
+func Prove(ctx context.Context, session *ProvingSession, witnessPath string, fullWitness, pubWitness witness.Witness, timings *ProofTimings) error {
+	if timings == nil {
+		timings = newProofTimings()
+	}
+	var pf groth16.Proof
+	err := addWallTime(&timings.ProveMs, func() (err error) {
+		pf, err = session.Prove(ctx, fullWitness)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	pf, err = rerandomize(session, pf)
 	if err != nil {
-		return fmt.Errorf("failed to get OnChainProof: %v\n", err)
+		return err
 	}
 
-	err = ioutil.WriteFile(os.Getenv("PROOF_PATH"), []byte(res), 0644)
+	err = addWallTime(&timings.VerifyMs, func() error {
+		return session.Verify(pf, pubWitness)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to write res, err: %v", err)
+		return err
+	}
+
+	res, err := formatProof(pf, pubWitness)
+	if err != nil {
+		return fmt.Errorf("failed to get OnChainProof: %w\n", err)
+	}
+
+	writeProof := func(w io.Writer) error {
+		_, err := w.Write(res)
+		return err
+	}
+	err = utils.WriteArtifact(os.Getenv("PROOF_PATH"), writeProof)
+	if err != nil {
+		return fmt.Errorf("failed to write res, err: %w", err)
+	}
+	// the rust sdk reads the plain proof file, archives get a compressed copy next to it
+	if os.Getenv("PROOF_COMPRESSION") == utils.CompressionGzip {
+		err = utils.WriteArtifact(os.Getenv("PROOF_PATH")+utils.GzipExt, utils.WithCompression(utils.CompressionGzip, writeProof))
+		if err != nil {
+			return fmt.Errorf("failed to write compressed res, err: %w", err)
+		}
+	}
+	err = writeProofSignature(os.Getenv("PROOF_PATH"), pf, pubWitness)
+	if err != nil {
+		return err
+	}
+	err = writeProofManifest(session, witnessPath, os.Getenv("PROOF_PATH"), pubWitness)
+	if err != nil {
+		return err
+	}
+	public, err := utils.PublicInputs(pubWitness)
+	if err != nil {
+		return err
+	}
+	timings.finish(session.ccs, public)
+	err = writeProofTimings(os.Getenv("PROOF_PATH"), timings)
+	if err != nil {
+		return fmt.Errorf("fail to write timings: %w", err)
 	}
 	fmt.Println("proof written successfully")
 
-	bn254Proof := pf.(*groth16_bn254.Proof)
-	fmt.Printf("bn254Proof Commitments: %v \n", bn254Proof.Commitments)
-	fmt.Printf("bn254Proof CommitmentPok: %v \n", bn254Proof.CommitmentPok)
+	if bn254Proof, ok := pf.(*groth16_bn254.Proof); ok {
+		fmt.Printf("bn254Proof Commitments: %v \n", bn254Proof.Commitments)
+		fmt.Printf("bn254Proof CommitmentPok: %v \n", bn254Proof.CommitmentPok)
+	}
+	return nil
+}
+
+// rerandomize re-randomizes proof with the vk of session if PROOF_RERANDOMIZE=1, before
+// it is verified and written.
+func rerandomize(session *ProvingSession, proof groth16.Proof) (groth16.Proof, error) {
+	if os.Getenv("PROOF_RERANDOMIZE") != "1" {
+		return proof, nil
+	}
+	res, err := utils.RerandomizeProof(proof, session.vk)
+	if err != nil {
+		return nil, fmt.Errorf("fail to rerandomize proof: %w", err)
+	}
+	return res, nil
+}
+
+// writeCcs persists ccs, compiled from circuit, with a header describing what it was
+// built from through store, unless CCS_WRITE=0.
+func writeCcs(store utils.ArtifactWriter, curve ecc.ID, field string, circuit frontend.Circuit, ccs constraint.ConstraintSystem) error {
+	if os.Getenv("CCS_WRITE") == "0" {
+		return nil
+	}
+	header, err := newCcsHeader(curve, field, circuit)
+	if err != nil {
+		return err
+	}
+	header.CcsHash, err = utils.CcsHash(ccs)
+	if err != nil {
+		return err
+	}
+	return utils.WriteCcsArtifact(store, os.Getenv("CCS_PATH"), header, ccs)
+}
+
+// loadOrCompileCcs reads the ccs written by setup instead of compiling the circuit
+// again, unless CCS_READ=0, loaded telling which. Missing files and files written
+// without header fall back to compiling; a ccs built from other constraints or options
+// is an error.
+func loadOrCompileCcs(ctx context.Context, curve ecc.ID, field string, circuit frontend.Circuit) (ccs constraint.ConstraintSystem, loaded bool, err error) {
+	ccsPath := os.Getenv("CCS_PATH")
+	if solveDebug() && debug.Debug {
+		fmt.Println("compiling the ccs with debug info")
+	} else if os.Getenv("CCS_READ") != "0" && ccsPath != "" {
+		ccs, err := readCompatibleCcs(curve, field, ccsPath, circuit)
+		if err != nil {
+			return nil, false, err
+		}
+		if ccs != nil {
+			fmt.Printf("ccs loaded from %s: %d \n", ccsPath, ccs.GetNbConstraints())
+			return ccs, true, nil
+		}
+	}
+
+	ccs, err = compile(ctx, curve, circuit)
+	if err != nil {
+		return nil, false, err
+	}
+	err = printCcs(ccs)
+	if err != nil {
+		return nil, false, err
+	}
+	return ccs, false, nil
+}
+
+// writeSetupOutputs stages the keys and the ccs and only moves them into place once
+// all of them are written, so an interrupted setup leaves the previous outputs as
+// they were instead of a new pk next to an old vk.
+func writeSetupOutputs(curve ecc.ID, field string, circuit frontend.Circuit, pk groth16.ProvingKey, vk groth16.VerifyingKey, ccs constraint.ConstraintSystem) error {
+	staged := &utils.StagedWrites{}
+	defer staged.Abort()
+
+	err := utils.WriteKeyPair(staged.Write, os.Getenv("PK_PATH"), os.Getenv("VK_PATH"), pk, vk)
+	if err != nil {
+		return err
+	}
+	err = writeCcs(staged.Write, curve, field, circuit, ccs)
+	if err != nil {
+		return fmt.Errorf("fail to write ccs: %w", err)
+	}
+	return staged.Commit()
+}
+
+func setup(ctx context.Context, ccs constraint.ConstraintSystem) (groth16.ProvingKey, groth16.VerifyingKey, error) {
+	type keys struct {
+		pk groth16.ProvingKey
+		vk groth16.VerifyingKey
+	}
+	k, err := runWithContext(ctx, func() (keys, error) {
+		pk, vk, err := groth16.Setup(ccs)
+		return keys{pk, vk}, err
+	})
+	return k.pk, k.vk, err
+}
+
+// ErrUnsatisfied is returned when a witness does not satisfy the circuit.
+var ErrUnsatisfied = errors.New("witness does not satisfy the circuit")
+
+// isSolved solves assigment with the test engine, describing the unsatisfied
+// constraint in debug mode.
+func isSolved(ctx context.Context, curve ecc.ID, circuit, assigment frontend.Circuit) error {
+	_, err := runWithContext(ctx, func() (struct{}, error) {
+		return struct{}{}, test.IsSolved(circuit, assigment, curve.ScalarField())
+	})
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	if solveDebug() {
+		err = diagnoseUnsolved(ctx, curve, circuit, assigment, err)
+	}
+	return fmt.Errorf("%w: %w", ErrUnsatisfied, err)
+}
+
+// CompileOptions returns the gnark compile options set by the environment.
+// COMPILE_CAPACITY pre-sizes the constraint system, sparing the reallocations of the
+// multi-million constraint verifier; it does not change the circuit.
+// COMPILE_COMPRESS_THRESHOLD overrides the length of the linear expressions gnark
+// compresses (300), which changes the circuit and is recorded in the ccs header.
+// Groth16 needs the r1cs builder, so the builder is not an option.
+func CompileOptions() ([]frontend.CompileOption, error) {
+	var opts []frontend.CompileOption
+	if v := os.Getenv("COMPILE_CAPACITY"); v != "" {
+		capacity, err := strconv.Atoi(v)
+		if err != nil || capacity < 0 {
+			return nil, fmt.Errorf("invalid COMPILE_CAPACITY %q", v)
+		}
+		if capacity > 0 {
+			opts = append(opts, frontend.WithCapacity(capacity))
+		}
+	}
+	if v := os.Getenv("COMPILE_COMPRESS_THRESHOLD"); v != "" {
+		threshold, err := strconv.Atoi(v)
+		if err != nil || threshold < 0 {
+			return nil, fmt.Errorf("invalid COMPILE_COMPRESS_THRESHOLD %q", v)
+		}
+		if threshold > 0 {
+			opts = append(opts, frontend.WithCompressThreshold(threshold))
+		}
+	}
+	return opts, nil
+}
+
+func compile(ctx context.Context, curve ecc.ID, circuit frontend.Circuit) (constraint.ConstraintSystem, error) {
+	opts, err := CompileOptions()
+	if err != nil {
+		return nil, err
+	}
+	return runWithContext(ctx, func() (constraint.ConstraintSystem, error) {
+		return frontend.Compile(curve.ScalarField(), r1cs.NewBuilder, circuit, opts...)
+	})
+}
+
+// printCcs prints the size and the hash of a compiled ccs, which setups and proves on
+// different machines compare to check they compiled the same circuit.
+func printCcs(ccs constraint.ConstraintSystem) error {
+	hash, err := utils.CcsHash(ccs)
+	if err != nil {
+		return fmt.Errorf("fail to hash ccs: %w", err)
+	}
+	fmt.Printf("ccs: %d \n", ccs.GetNbConstraints())
+	fmt.Printf("ccs hash: %s\n", hash)
 	return nil
 }
+
+// chunkCount is the number of chunk proofs circuit verifies.
+func chunkCount(circuit frontend.Circuit) int {
+	if multi, ok := circuit.(interface{ NbChunks() int }); ok {
+		return multi.NbChunks()
+	}
+	return 1
+}
+
+// newCcsHeader is the header of the ccs of circuit, built for curve and field.
+func newCcsHeader(curve ecc.ID, field string, circuit frontend.Circuit) (*utils.CcsHeader, error) {
+	header, err := utils.NewCcsHeader(curve, field, utils.ConstraintsPath(), chunkCount(circuit))
+	if err != nil {
+		return nil, err
+	}
+	if aggregate, ok := circuit.(interface{ VkeyTreeSize() int }); ok {
+		header.Aggregate(aggregate.VkeyTreeSize())
+	}
+	return header, nil
+}
+
+// readCompatibleCcs returns nil without error if there is no usable ccs file.
+func readCompatibleCcs(curve ecc.ID, field, ccsPath string, circuit frontend.Circuit) (constraint.ConstraintSystem, error) {
+	header, err := utils.ReadCcsHeader(ccsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("no ccs at %s, compiling the circuit\n", ccsPath)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fail to read ccs header: %w", err)
+	}
+	if header == nil {
+		fmt.Printf("ccs at %s has no header, compiling the circuit\n", ccsPath)
+		return nil, nil
+	}
+
+	expected, err := newCcsHeader(curve, field, circuit)
+	if err != nil {
+		return nil, err
+	}
+	err = header.CheckCompatible(expected)
+	if err != nil {
+		return nil, fmt.Errorf("ccs at %s is stale, rerun setup or pass -read-ccs=false: %w", ccsPath, err)
+	}
+
+	ccs := groth16.NewCS(curve)
+	err = utils.ReadCcs(ccsPath, ccs)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read ccs: %w", err)
+	}
+	return ccs, nil
+}
+
+// NewWitness assigns inputs to the verifier circuit of the given field ("kb" or "bb")
+// and returns the full and public witnesses over curve's scalar field.
+func NewWitness(curve ecc.ID, field string, inputs utils.WitnessInput) (fullWitness witness.Witness, pubWitness witness.Witness, err error) {
+	_, assigment, err := buildCircuits(field, &inputs, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newWitnesses(curve, assigment)
+}
+
+// NewMultiWitness is NewWitness for the circuit wrapping all chunks of inputs.
+func NewMultiWitness(curve ecc.ID, field string, inputs utils.MultiWitnessInput) (fullWitness witness.Witness, pubWitness witness.Witness, err error) {
+	_, assigment, err := buildCircuits(field, nil, &inputs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newWitnesses(curve, assigment)
+}
+
+// publicLayout is the public input layout of PUBLIC_INPUT_*, which multi-chunk and
+// aggregated wrappers only support in its default.
+func publicLayout(chunks int, aggregated bool) (utils.PublicLayout, error) {
+	layout, err := utils.PublicLayoutFromEnv()
+	if err != nil {
+		return layout, err
+	}
+	if (chunks > 1 || aggregated) && !layout.IsDefault() {
+		return layout, fmt.Errorf("public input layout %s only applies to single chunk witnesses of one program", layout)
+	}
+	return layout, nil
+}
+
+// circuitOptions is the circuit variant selected by GROTH16, RANGE_CHECK,
+// POSEIDON2_CROSS_CHECK and POSEIDON2_CONSTANT_FOLDING, the settings recorded in ccs
+// headers.
+func circuitOptions() verifier_core.Options {
+	return verifier_core.Options{
+		LookupRangeCheck:         os.Getenv("GROTH16") != "1" || os.Getenv("RANGE_CHECK") == "lookup" || os.Getenv("RANGE_CHECK") == "decompose",
+		LookupDecomposition:      os.Getenv("RANGE_CHECK") == "decompose",
+		Poseidon2CrossCheck:      os.Getenv("POSEIDON2_CROSS_CHECK") == "1",
+		Poseidon2ConstantFolding: os.Getenv("POSEIDON2_CONSTANT_FOLDING") == "1",
+	}
+}
+
+func newWitnesses(curve ecc.ID, assigment frontend.Circuit) (fullWitness witness.Witness, pubWitness witness.Witness, err error) {
+	fullWitness, err = frontend.NewWitness(assigment, curve.ScalarField())
+	if err != nil {
+		return nil, nil, err
+	}
+	pubWitness, err = fullWitness.Public()
+	if err != nil {
+		return nil, nil, err
+	}
+	return fullWitness, pubWitness, nil
+}
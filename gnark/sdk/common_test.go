@@ -0,0 +1,82 @@
+package sdk
+
+import (
+	"context"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteSetupOutputs(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	dir := t.TempDir()
+	constraints := filepath.Join(dir, "constraints.json")
+	assert.NoError(os.WriteFile(constraints, []byte("[]"), 0644))
+	t.Setenv("CONSTRAINTS_JSON", constraints)
+	t.Setenv("PK_PATH", filepath.Join(dir, "vm_pk"))
+	t.Setenv("VK_PATH", filepath.Join(dir, "vm_vk"))
+	t.Setenv("CCS_PATH", filepath.Join(dir, "vm_ccs"))
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &cubicCircuit{})
+	assert.NoError(err)
+	pk, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	assert.NoError(writeSetupOutputs(ecc.BN254, "kb", &cubicCircuit{}, pk, vk, ccs))
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(err)
+	assert.Len(entries, 4, "constraints, pk, vk and ccs without leftover temporary files")
+	_, err = LoadProvingSession(ecc.BN254, os.Getenv("PK_PATH"), os.Getenv("VK_PATH"), os.Getenv("CCS_PATH"))
+	assert.NoError(err)
+
+	// a vk of another setup next to the pk is caught from the pk header, before the pk
+	// is loaded
+	_, otherVk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	assert.NoError(utils.WriteVerifyingKey(os.Getenv("VK_PATH"), otherVk))
+	_, err = LoadProvingSession(ecc.BN254, os.Getenv("PK_PATH"), os.Getenv("VK_PATH"), os.Getenv("CCS_PATH"))
+	assert.Error(err)
+	assert.Contains(err.Error(), "fingerprint")
+}
+
+func TestCompileOptions(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	t.Setenv("COMPILE_CAPACITY", "")
+	t.Setenv("COMPILE_COMPRESS_THRESHOLD", "0")
+	opts, err := CompileOptions()
+	assert.NoError(err)
+	assert.Empty(opts)
+	plain, err := compile(context.Background(), ecc.BN254, &cubicCircuit{})
+	assert.NoError(err)
+
+	// capacity does not change the circuit
+	t.Setenv("COMPILE_CAPACITY", "1000")
+	t.Setenv("COMPILE_COMPRESS_THRESHOLD", "1")
+	opts, err = CompileOptions()
+	assert.NoError(err)
+	assert.Len(opts, 2)
+	t.Setenv("COMPILE_COMPRESS_THRESHOLD", "")
+	sized, err := compile(context.Background(), ecc.BN254, &cubicCircuit{})
+	assert.NoError(err)
+	plainHash, err := utils.CcsHash(plain)
+	assert.NoError(err)
+	sizedHash, err := utils.CcsHash(sized)
+	assert.NoError(err)
+	assert.Equal(plainHash, sizedHash)
+
+	t.Setenv("COMPILE_CAPACITY", "-1")
+	_, err = CompileOptions()
+	assert.ErrorContains(err, "invalid COMPILE_CAPACITY")
+	t.Setenv("COMPILE_CAPACITY", "")
+	t.Setenv("COMPILE_COMPRESS_THRESHOLD", "many")
+	_, err = CompileOptions()
+	assert.ErrorContains(err, "invalid COMPILE_COMPRESS_THRESHOLD")
+}
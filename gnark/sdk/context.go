@@ -0,0 +1,48 @@
+package sdk
+
+import (
+	"context"
+	"sync"
+)
+
+// runWithContext runs fn and returns its result, or ctx's error as soon as ctx is done.
+// gnark's compile, setup and prove cannot be interrupted, so a cancelled fn keeps
+// running in the background until it finishes and its result is dropped; callers that
+// need the memory back right away have to exit the process.
+func runWithContext[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	return runTracked(ctx, nil, fn)
+}
+
+// runTracked is runWithContext counting fn in running, if not nil, until fn returns,
+// so callers can wait for work abandoned on cancellation.
+func runTracked[T any](ctx context.Context, running *sync.WaitGroup, fn func() (T, error)) (T, error) {
+	err := ctx.Err()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	if running != nil {
+		running.Add(1)
+	}
+	go func() {
+		if running != nil {
+			defer running.Done()
+		}
+		value, err := fn()
+		done <- result{value, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.value, res.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,57 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunTrackedWaitsForAbandonedWork(t *testing.T) {
+	var running sync.WaitGroup
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	errs := make(chan error, 1)
+	go func() {
+		_, err := runTracked(ctx, &running, func() (int, error) {
+			close(started)
+			<-unblock
+			return 1, nil
+		})
+		errs <- err
+	}()
+	<-started
+	cancel()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	waited := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		t.Fatal("Wait returned while the abandoned work is still running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(unblock)
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait did not return once the work finished")
+	}
+
+	// nothing is counted when ctx is already done
+	_, err := runTracked(ctx, &running, func() (int, error) {
+		t.Fatal("fn ran on a cancelled context")
+		return 0, nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	running.Wait()
+}
@@ -0,0 +1,104 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/constraint"
+	cs_bls12381 "github.com/consensys/gnark/constraint/bls12-381"
+	cs_bn254 "github.com/consensys/gnark/constraint/bn254"
+	cs_bw6761 "github.com/consensys/gnark/constraint/bw6-761"
+	"github.com/consensys/gnark/frontend"
+	"os"
+	"strings"
+)
+
+// solveDebug reports whether SOLVE_DEBUG asks to diagnose solver failures.
+func solveDebug() bool {
+	return os.Getenv("SOLVE_DEBUG") == "1"
+}
+
+// r1csOfAnyCurve is the R1CS of any curve.
+type r1csOfAnyCurve interface {
+	constraint.Resolver
+	GetR1Cs() []constraint.R1C
+}
+
+// unsatisfiedConstraint returns the id of the constraint err reports unsatisfied, if
+// it is an unsatisfied constraint error of the solver of one of the supported curves.
+func unsatisfiedConstraint(err error) (cid int, ok bool) {
+	var bn254 *cs_bn254.UnsatisfiedConstraintError
+	var bls12381 *cs_bls12381.UnsatisfiedConstraintError
+	var bw6761 *cs_bw6761.UnsatisfiedConstraintError
+	switch {
+	case errors.As(err, &bn254):
+		return bn254.CID, true
+	case errors.As(err, &bls12381):
+		return bls12381.CID, true
+	case errors.As(err, &bw6761):
+		return bw6761.CID, true
+	default:
+		return 0, false
+	}
+}
+
+// describeUnsatisfied adds to an unsatisfied constraint error of the solver the
+// constraint and the witness variables it involves, by index in the full witness and
+// name. The error carries the gadget of the constraint if the ccs was compiled with
+// the debug build tag.
+func describeUnsatisfied(ccs constraint.ConstraintSystem, err error) error {
+	cid, unsatisfied := unsatisfiedConstraint(err)
+	r1cs, ok := ccs.(r1csOfAnyCurve)
+	if !unsatisfied || !ok {
+		return err
+	}
+	constraints := r1cs.GetR1Cs()
+	if cid < 0 || cid >= len(constraints) {
+		return err
+	}
+	r1c := constraints[cid]
+
+	// the full witness holds the public variables but the constant one wire, then the
+	// secret ones; the other wires are internal
+	nbWitness := ccs.GetNbPublicVariables() + ccs.GetNbSecretVariables()
+	seen := make(map[uint32]bool)
+	var involved []string
+	for _, l := range []constraint.LinearExpression{r1c.L, r1c.R, r1c.O} {
+		for _, t := range l {
+			if t.VID == 0 || int(t.VID) >= nbWitness || seen[t.VID] {
+				continue
+			}
+			seen[t.VID] = true
+			kind := "secret"
+			if int(t.VID) < ccs.GetNbPublicVariables() {
+				kind = "public"
+			}
+			involved = append(involved, fmt.Sprintf("  witness[%d] %s %s", t.VID-1, kind, r1cs.VariableToString(int(t.VID))))
+		}
+	}
+	if len(involved) == 0 {
+		return fmt.Errorf("%w\nconstraint: %s\ninvolves no witness variable, only internal wires", err, r1c.String(r1cs))
+	}
+	return fmt.Errorf("%w\nconstraint: %s\ninvolves:\n%s", err, r1c.String(r1cs), strings.Join(involved, "\n"))
+}
+
+// diagnoseUnsolved adds describeUnsatisfied to err, the failure of the test engine to
+// solve assigment, which names the failing assertion but not the constraint. It
+// compiles circuit and solves assigment on the ccs to find the constraint; err is
+// returned as is if that finds no unsatisfied constraint.
+func diagnoseUnsolved(ctx context.Context, curve ecc.ID, circuit, assigment frontend.Circuit, err error) error {
+	ccs, compileErr := compile(ctx, curve, circuit)
+	if compileErr != nil {
+		return err
+	}
+	fullWitness, witnessErr := frontend.NewWitness(assigment, curve.ScalarField())
+	if witnessErr != nil {
+		return err
+	}
+	_, solveErr := ccs.Solve(fullWitness)
+	if _, unsatisfied := unsatisfiedConstraint(solveErr); !unsatisfied {
+		return err
+	}
+	return fmt.Errorf("%v\n%w", err, describeUnsatisfied(ccs, solveErr))
+}
@@ -0,0 +1,67 @@
+package sdk
+
+import (
+	"context"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type gadgetCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func assertCube(api frontend.API, x, y frontend.Variable) {
+	api.AssertIsEqual(y, api.Mul(x, x, x))
+}
+
+func (c *gadgetCircuit) Define(api frontend.API) error {
+	assertCube(api, c.X, c.Y)
+	return nil
+}
+
+func TestDescribeUnsatisfied(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	t.Setenv("SOLVE_DEBUG", "1")
+	for _, curve := range utils.SupportedCurves {
+		ccs, err := frontend.Compile(curve.ScalarField(), r1cs.NewBuilder, &gadgetCircuit{})
+		assert.NoError(err)
+		pk, vk, err := groth16.Setup(ccs)
+		assert.NoError(err)
+		fullWitness, err := frontend.NewWitness(&gadgetCircuit{X: 2, Y: 9}, curve.ScalarField())
+		assert.NoError(err)
+
+		_, err = NewProvingSession(pk, vk, ccs).Prove(context.Background(), fullWitness)
+		assert.Error(err)
+		assert.Contains(err.Error(), "is not satisfied", curve)
+		assert.Contains(err.Error(), "witness[0] public Y", curve)
+	}
+}
+
+func TestDescribeUnsolved(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	witnessFile := filepath.Join(t.TempDir(), "groth16_witness.json")
+	assert.NoError(os.WriteFile(witnessFile, []byte("{}"), 0644))
+	t.Setenv("WITNESS_JSON", witnessFile)
+	field := fieldSdk{name: "kb", newCircuits: func([]byte) (frontend.Circuit, frontend.Circuit, error) {
+		return &gadgetCircuit{}, &gadgetCircuit{X: 2, Y: 9}, nil
+	}}
+
+	_, _, err := field.solve(context.Background())
+	assert.Error(err)
+	assert.NotContains(err.Error(), "witness[0] public Y")
+
+	t.Setenv("SOLVE_DEBUG", "1")
+	_, _, err = field.solve(context.Background())
+	assert.Error(err)
+	assert.Contains(err.Error(), "is not satisfied")
+	assert.Contains(err.Error(), "witness[0] public Y")
+}
@@ -0,0 +1,130 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/onchain"
+	"github.com/brevis-network/pico/gnark/publicvalues"
+	"github.com/brevis-network/pico/gnark/utils"
+	"io"
+	"os"
+	"strings"
+)
+
+// The files ExportEvmInputs writes in OUTPUT_DIR.
+const (
+	EvmInputsName   = "inputs.json"
+	EvmCalldataName = "calldata.hex"
+)
+
+// EvmInputs is the inputs.json the Rust SDK's prove_evm writes, the arguments of the
+// verifyPicoProof function of the pico example contracts.
+type EvmInputs struct {
+	// RiscvVKey is the program vkey hash as bytes32.
+	RiscvVKey string `json:"riscvVKey"`
+	// Proof is the 8 words of the proof points, without the commitment.
+	Proof []string `json:"proof"`
+	// PublicValues are the 0x prefixed hex public values, empty if unknown.
+	PublicValues string `json:"publicValues,omitempty"`
+}
+
+// proveEvm runs what the Rust SDK's prove_evm runs on the witness of WITNESS_JSON:
+// setup with EVM_SETUP=1, then prove, then it exports the solidity verifier and the
+// inputs of the contracts to OUTPUT_DIR.
+func (f fieldSdk) proveEvm(ctx context.Context) error {
+	outputDir := os.Getenv("OUTPUT_DIR")
+	if !strings.Contains(outputDir, "://") {
+		err := os.MkdirAll(outputDir, 0755)
+		if err != nil {
+			return err
+		}
+	}
+	if os.Getenv("EVM_SETUP") == "1" {
+		err := f.setup(ctx)
+		if err != nil {
+			return fmt.Errorf("fail to setup: %w", err)
+		}
+	}
+	err := f.prove(ctx)
+	if err != nil {
+		return fmt.Errorf("fail to prove: %w", err)
+	}
+	// after prove, so the foundry test gets the proof
+	err = ExportSolidify(ctx, f.name)
+	if err != nil {
+		return fmt.Errorf("fail to export solidity: %w", err)
+	}
+	return ExportEvmInputs()
+}
+
+// ExportEvmInputs writes inputs.json and calldata.hex, the hex of the abi encoded
+// verifyProof call of the verifier contract, of the proof at PROOF_PATH to OUTPUT_DIR.
+// The public values are those of the witness at WITNESS_JSON, or else of the pv_file
+// next to it, see utils.ReadWitnessJson, and must hash to the digest the proof commits
+// to.
+func ExportEvmInputs() error {
+	calldata, err := ReadProofCalldata()
+	if err != nil {
+		return err
+	}
+	witnessPath := os.Getenv("WITNESS_JSON")
+	data, err := utils.ReadWitnessJson(witnessPath)
+	if err != nil {
+		return fmt.Errorf("fail to read witness: %w", err)
+	}
+	single, multi, err := utils.ParseWitness(data)
+	if err != nil {
+		return fmt.Errorf("fail to parse witness: %w", err)
+	}
+	if single == nil {
+		return fmt.Errorf("witness has %d chunks, the pico contracts verify single chunk proofs", len(multi.Chunks))
+	}
+	vkey, err := onchain.Bytes32(single.VkeyHash)
+	if err != nil {
+		return fmt.Errorf("invalid vkey hash: %w", err)
+	}
+	inputs := EvmInputs{RiscvVKey: vkey, PublicValues: single.PublicValues}
+	for _, w := range calldata.Proof {
+		inputs.Proof = append(inputs.Proof, fmt.Sprintf("0x%064x", w))
+	}
+
+	if inputs.PublicValues != "" {
+		values, err := utils.DecodePublicValues(inputs.PublicValues)
+		if err != nil {
+			return err
+		}
+		layout, err := utils.PublicLayoutFromEnv()
+		if err != nil {
+			return err
+		}
+		err = publicvalues.Check(values, layout, calldata.Input)
+		if err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("no %s next to %s, inputs.json has no public values\n", PicoDirPublicValues, witnessPath)
+	}
+
+	payload, err := json.MarshalIndent(inputs, "", "  ")
+	if err != nil {
+		return err
+	}
+	outputDir := strings.TrimSuffix(os.Getenv("OUTPUT_DIR"), "/")
+	err = utils.WriteArtifact(outputDir+"/"+EvmInputsName, func(w io.Writer) error {
+		_, err := w.Write(append(payload, '\n'))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("fail to write %s: %w", EvmInputsName, err)
+	}
+	err = utils.WriteArtifact(outputDir+"/"+EvmCalldataName, func(w io.Writer) error {
+		_, err := fmt.Fprintln(w, onchain.EncodeHex(calldata.Encode()))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("fail to write %s: %w", EvmCalldataName, err)
+	}
+	fmt.Printf("%s and the calldata of %s written to %s\n", EvmInputsName, calldata.Signature(), outputDir)
+	return nil
+}
@@ -0,0 +1,84 @@
+package sdk
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/onchain"
+	"github.com/brevis-network/pico/gnark/publicvalues"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportEvmInputs(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	dir := t.TempDir()
+	t.Setenv("CURVE", "bn254")
+	t.Setenv("VK_PATH", filepath.Join(dir, "vm_vk"))
+	t.Setenv("WITNESS_JSON", filepath.Join(dir, "pico", PicoDirWitness))
+	t.Setenv("PROOF_PATH", filepath.Join(dir, "evm", "proof.json"))
+	t.Setenv("OUTPUT_DIR", filepath.Join(dir, "evm"))
+	assert.NoError(os.MkdirAll(filepath.Join(dir, "pico"), 0755))
+	assert.NoError(os.MkdirAll(filepath.Join(dir, "evm"), 0755))
+
+	// the circuit commits to x+1 and x^2, x is chosen so x+1 is the digest of the values
+	publicValues := []byte("public values of the program")
+	digest := publicvalues.Digest(publicValues)
+	x := new(big.Int).Sub(digest, big.NewInt(1))
+	vkeyHash := new(big.Int).Mod(new(big.Int).Mul(x, x), ecc.BN254.ScalarField())
+	assert.NoError(os.WriteFile(os.Getenv("WITNESS_JSON"), []byte(fmt.Sprintf(`{"vars": [], "felts": [], "vkey_hash": "%s", "committed_values_digest": "%s"}`, vkeyHash, digest)), 0644))
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &publicValuesCircuit{})
+	assert.NoError(err)
+	pk, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	assert.NoError(utils.WriteVerifyingKey(os.Getenv("VK_PATH"), vk))
+	fullWitness, err := frontend.NewWitness(&publicValuesCircuit{X: x, VkeyHash: vkeyHash, CommittedValuesDigest: digest}, ecc.BN254.ScalarField())
+	assert.NoError(err)
+	pubWitness, err := fullWitness.Public()
+	assert.NoError(err)
+	proof, err := groth16.Prove(ccs, pk, fullWitness)
+	assert.NoError(err)
+	data, err := utils.FormatProof(utils.ProofFormatJson, proof, pubWitness)
+	assert.NoError(err)
+	assert.NoError(os.WriteFile(os.Getenv("PROOF_PATH"), data, 0644))
+
+	// without pv_file the public values are unknown
+	assert.NoError(ExportEvmInputs())
+	var inputs EvmInputs
+	data, err = os.ReadFile(filepath.Join(dir, "evm", EvmInputsName))
+	assert.NoError(err)
+	assert.NoError(json.Unmarshal(data, &inputs))
+	assert.Equal(fmt.Sprintf("0x%064x", vkeyHash), inputs.RiscvVKey)
+	assert.Len(inputs.Proof, 8)
+	assert.Empty(inputs.PublicValues)
+
+	pvPath := filepath.Join(dir, "pico", PicoDirPublicValues)
+	assert.NoError(os.WriteFile(pvPath, []byte(hex.EncodeToString(publicValues)), 0644))
+	assert.NoError(ExportEvmInputs())
+	data, err = os.ReadFile(filepath.Join(dir, "evm", EvmInputsName))
+	assert.NoError(err)
+	assert.NoError(json.Unmarshal(data, &inputs))
+	assert.Equal("0x"+hex.EncodeToString(publicValues), inputs.PublicValues)
+
+	calldata, err := onchain.NewProofCalldata(proof, pubWitness)
+	assert.NoError(err)
+	data, err = os.ReadFile(filepath.Join(dir, "evm", EvmCalldataName))
+	assert.NoError(err)
+	assert.Equal(onchain.EncodeHex(calldata.Encode()), strings.TrimSpace(string(data)))
+	assert.Equal(fmt.Sprintf("0x%064x", calldata.Proof[7]), inputs.Proof[7])
+
+	// values the proof does not commit to are rejected
+	assert.NoError(os.WriteFile(pvPath, []byte(hex.EncodeToString([]byte("other values"))), 0644))
+	assert.ErrorContains(ExportEvmInputs(), "the proof commits to")
+}
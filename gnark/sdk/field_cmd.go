@@ -0,0 +1,307 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/brevis-network/pico/gnark/verifier_core"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"os"
+	"sync"
+)
+
+// fieldSdk is what the commands of the sdk differ in between the fields of the
+// wrapped proofs. Commands that do not build the circuit are dispatched once by the
+// cli, see sdk/main.
+type fieldSdk struct {
+	// name is the field as recorded in headers and bundles, "kb" or "bb".
+	name        string
+	newCircuits func(data []byte) (circuit frontend.Circuit, assigment frontend.Circuit, err error)
+	native      verifier_core.NativeField
+}
+
+// run runs cmd, one of the commands that build the circuit of the field.
+func (f fieldSdk) run(ctx context.Context, cmd string) (err error) {
+	switch cmd {
+	case "prove":
+		err = f.prove(ctx)
+		if err != nil {
+			return fmt.Errorf("fail to prove: %w\n", err)
+		}
+	case "setup":
+		err = f.setup(ctx)
+		if err != nil {
+			return fmt.Errorf("fail to setup: %w\n", err)
+		}
+		err = ExportSolidify(ctx, f.name)
+		if err != nil {
+			return fmt.Errorf("fail to export solidity: %w\n", err)
+		}
+	case "solve":
+		_, _, err = f.solve(ctx)
+		if err != nil {
+			return fmt.Errorf("fail to solve: %w\n", err)
+		}
+	case "setupAndProve":
+		err = f.setup(ctx)
+		if err != nil {
+			return fmt.Errorf("fail to setup: %w\n", err)
+		}
+		err = f.prove(ctx)
+		if err != nil {
+			return fmt.Errorf("fail to prove: %w\n", err)
+		}
+		// after prove, so the foundry test gets the proof
+		err = ExportSolidify(ctx, f.name)
+		if err != nil {
+			return fmt.Errorf("fail to export solidity: %w\n", err)
+		}
+	case "proveEvm":
+		err = f.proveEvm(ctx)
+		if err != nil {
+			return fmt.Errorf("fail to prove for evm: %w\n", err)
+		}
+	case "bench":
+		err = runBench(ctx, f.newCircuits)
+		if err != nil {
+			return fmt.Errorf("fail to bench: %w\n", err)
+		}
+	case "bundle":
+		err = ExportBundle(ctx, f.name, f.newCircuits)
+		if err != nil {
+			return fmt.Errorf("fail to export bundle: %w\n", err)
+		}
+	case "check-witness":
+		err = CheckWitness(f.name, f.native)
+		if err != nil {
+			return fmt.Errorf("fail to check witness: %w\n", err)
+		}
+	case "witness-export":
+		err = ExportWitness(ctx, f.newCircuits)
+		if err != nil {
+			return fmt.Errorf("fail to export witness: %w\n", err)
+		}
+	case "proveDir":
+		err = ProveDir(ctx, f.name)
+		if err != nil {
+			return fmt.Errorf("fail to prove directory: %w\n", err)
+		}
+	case "watchDir":
+		err = WatchDir(ctx, f.name)
+		if err != nil {
+			return fmt.Errorf("fail to watch directory: %w\n", err)
+		}
+	case "ccs-hash":
+		err = PrintCcsHash(ctx, f.newCircuits)
+		if err != nil {
+			return fmt.Errorf("fail to hash ccs: %w\n", err)
+		}
+	case "checkBaseline":
+		err = CheckBaseline(ctx, f.name, f.newCircuits)
+		if err != nil {
+			return fmt.Errorf("fail to check baseline: %w\n", err)
+		}
+	case "exportR1cs":
+		err = ExportR1cs(ctx, f.name, f.newCircuits)
+		if err != nil {
+			return fmt.Errorf("fail to export r1cs: %w\n", err)
+		}
+	default:
+		return fmt.Errorf("unknown command: %s", cmd)
+	}
+	return
+}
+
+// solve builds the circuit of the witness of WITNESS_JSON and checks it is solved.
+func (f fieldSdk) solve(ctx context.Context) (circuit frontend.Circuit, assigment frontend.Circuit, err error) {
+	curve, err := utils.CurveFromEnv()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	witnessFile := os.Getenv("WITNESS_JSON")
+
+	data, err := utils.ReadWitnessJson(witnessFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fail to read witness file: %w\n", err)
+	}
+
+	circuit, assigment, err = f.newCircuits(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = isSolved(ctx, curve, circuit, assigment)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to solve: %w\n", err)
+	}
+	fmt.Println("solved with success")
+
+	return circuit, assigment, nil
+}
+
+// setup runs the groth16 setup of the circuit of WITNESS_JSON and writes the keys.
+func (f fieldSdk) setup(ctx context.Context) error {
+	curve, err := utils.CurveFromEnv()
+	if err != nil {
+		return err
+	}
+	circuit, assigment, err := f.solve(ctx)
+	if err != nil {
+		return fmt.Errorf("fail to solve: %w\n", err)
+	}
+	fullWitness, err := frontend.NewWitness(assigment, curve.ScalarField())
+	if err != nil {
+		return fmt.Errorf("fail to gen full witness: %w", err)
+	}
+	pubWitness, err := fullWitness.Public()
+	if err != nil {
+		return fmt.Errorf("fail to gen public witness: %w", err)
+	}
+	//fmt.Printf("fullWitness: %v \n", pubWitness)
+
+	ccs, err := compile(ctx, curve, circuit)
+	if err != nil {
+		return fmt.Errorf("fail to compile frontend: %w", err)
+	}
+	err = printCcs(ccs)
+	if err != nil {
+		return err
+	}
+
+	pk, vk, err := setup(ctx, ccs)
+	if err != nil {
+		return fmt.Errorf("fail to setup groth16: %w", err)
+	}
+	session := NewProvingSession(pk, vk, ccs)
+
+	pf, err := session.Prove(ctx, fullWitness)
+	if err != nil {
+		return fmt.Errorf("fail to prove groth16: %w", err)
+	}
+
+	err = session.Verify(pf, pubWitness)
+	if err != nil {
+		return fmt.Errorf("fail to verify: %w", err)
+	}
+
+	return writeSetupOutputs(curve, f.name, circuit, pk, vk, ccs)
+}
+
+// prove proves the witness of WITNESS_JSON with the keys written by setup.
+func (f fieldSdk) prove(ctx context.Context) error {
+	curve, err := utils.CurveFromEnv()
+	if err != nil {
+		return err
+	}
+	err = checkBeforeProve(curve, os.Getenv("CCS_PATH"))
+	if err != nil {
+		return err
+	}
+	sampler := StartMemorySampler(memorySampleInterval, os.Getenv("CGROUP_MEMORY") == "1")
+	defer func() {
+		fmt.Println(sampler.Stop())
+	}()
+	timings := newProofTimings()
+
+	var loadLock sync.WaitGroup
+	loadLock.Add(2) // 1 for load pk, 1 for compile ccs
+
+	pk := groth16.NewProvingKey(curve)
+	vk := groth16.NewVerifyingKey(curve)
+	var ccs constraint.ConstraintSystem
+	var ccsLoaded bool
+
+	var reafProveKeyErr, compileCcsErr error
+	var pkMs, vkMs, ccsMs int64
+	go func() {
+		defer loadLock.Done()
+		reafProveKeyErr = addWallTime(&pkMs, func() error {
+			return utils.ReadProvingKey(os.Getenv("PK_PATH"), pk)
+		})
+	}()
+
+	err = addWallTime(&vkMs, func() error {
+		return utils.ReadVerifyingKey(os.Getenv("VK_PATH"), vk)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read verifing key: %w", err)
+	}
+	checked, err := utils.CheckKeyFingerprint(os.Getenv("PK_PATH"), vk)
+	if err != nil {
+		return fmt.Errorf("key mismatch: %w", err)
+	}
+	if !checked {
+		fmt.Printf("no vk fingerprint found next to %s, skipping fast key check\n", os.Getenv("PK_PATH"))
+	}
+
+	witnessFile := os.Getenv("WITNESS_JSON")
+
+	data, err := utils.ReadWitnessJson(witnessFile)
+	if err != nil {
+		return fmt.Errorf("fail to read witness file: %w\n", err)
+	}
+
+	var circuit, assigment frontend.Circuit
+	err = addWallTime(&timings.SolveMs, func() (err error) {
+		circuit, assigment, err = f.newCircuits(data)
+		if err != nil {
+			return err
+		}
+		err = isSolved(ctx, curve, circuit, assigment)
+		if err != nil {
+			return fmt.Errorf("failed to solve: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fullWitness, err := frontend.NewWitness(assigment, curve.ScalarField())
+	if err != nil {
+		return fmt.Errorf("failed to get full witness: %w", err)
+	}
+	pubWitness, err := fullWitness.Public()
+	if err != nil {
+		return fmt.Errorf("failed to get public witness: %w", err)
+	}
+	fmt.Printf("fullWitness: %v \n", pubWitness)
+
+	go func() {
+		defer loadLock.Done()
+		compileCcsErr = addWallTime(&ccsMs, func() (err error) {
+			ccs, ccsLoaded, err = loadOrCompileCcs(ctx, curve, f.name, circuit)
+			return err
+		})
+	}()
+
+	loadLock.Wait()
+	timings.LoadMs = max(pkMs, vkMs)
+	if ccsLoaded {
+		timings.LoadMs = max(timings.LoadMs, ccsMs)
+	} else {
+		timings.CompileMs = ccsMs
+	}
+
+	if compileCcsErr != nil {
+		return fmt.Errorf("fail to compile compiler: %w", compileCcsErr)
+	}
+	if reafProveKeyErr != nil {
+		return fmt.Errorf("fail to read reproving key: %w", reafProveKeyErr)
+	}
+	err = utils.CheckKeyPair(pk, vk)
+	if err != nil {
+		return fmt.Errorf("key mismatch: %w", err)
+	}
+
+	session := NewProvingSession(pk, vk, ccs)
+	if ccsLoaded {
+		session.ccsPath = os.Getenv("CCS_PATH")
+	}
+	err = Prove(ctx, session, os.Getenv("WITNESS_JSON"), fullWitness, pubWitness, timings)
+
+	return err
+}
@@ -0,0 +1,17 @@
+package sdk
+
+import (
+	"context"
+	"github.com/consensys/gnark/test"
+	"testing"
+)
+
+func TestFieldCmd(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// commands that do not build the circuit are dispatched once by the cli
+	for _, cmd := range []string{"preflight", "submit", "proveBundle", "commitmentKey", "exportSolidity", "proveWitness", "registration"} {
+		assert.ErrorContains(KoalaBearCmd(context.Background(), cmd), "unknown command: "+cmd)
+		assert.ErrorContains(BabyBearCmd(context.Background(), cmd), "unknown command: "+cmd)
+	}
+}
@@ -0,0 +1,42 @@
+package sdk
+
+import (
+	"github.com/brevis-network/pico/gnark/babybear"
+	"github.com/brevis-network/pico/gnark/decompose"
+	"github.com/brevis-network/pico/gnark/koalabear"
+	"github.com/brevis-network/pico/gnark/poseidon2"
+	"github.com/consensys/gnark/constraint/solver"
+	"sync"
+)
+
+var (
+	customHintsMu sync.Mutex
+	customHints   []solver.Hint
+)
+
+// RegisterHints registers the solver hints of a circuit embedding the Pico verifier
+// gadget next to those of the gadget, so a ProvingSession, or any gnark prover,
+// solves the combined circuit. Hints are identified by their function name, so they
+// must be named functions, registered before solving, typically from an init.
+func RegisterHints(hints ...solver.Hint) {
+	customHintsMu.Lock()
+	defer customHintsMu.Unlock()
+	for _, hint := range hints {
+		if hint == nil {
+			panic("sdk: nil hint")
+		}
+	}
+	solver.RegisterHint(hints...)
+	customHints = append(customHints, hints...)
+}
+
+// Hints returns the solver hints of the verifier gadgets of both fields followed by
+// those registered with RegisterHints.
+func Hints() []solver.Hint {
+	hints := append(koalabear.Hints(), babybear.Hints()...)
+	hints = append(hints, decompose.Hints()...)
+	hints = append(hints, poseidon2.CrossCheckKoalaBearHint, poseidon2.CrossCheckBabyBearHint)
+	customHintsMu.Lock()
+	defer customHintsMu.Unlock()
+	return append(hints, customHints...)
+}
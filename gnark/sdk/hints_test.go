@@ -0,0 +1,58 @@
+package sdk
+
+import (
+	"context"
+	"github.com/brevis-network/pico/gnark/koalabear"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"math/big"
+	"testing"
+)
+
+// halveHint is a custom hint of a circuit combined with the verifier gadget.
+func halveHint(_ *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	outputs[0].Rsh(inputs[0], 1)
+	return nil
+}
+
+type halveCircuit struct {
+	X frontend.Variable `gnark:",public"`
+}
+
+func (c *halveCircuit) Define(api frontend.API) error {
+	half, err := api.Compiler().NewHint(halveHint, 1, c.X)
+	if err != nil {
+		return err
+	}
+	api.AssertIsEqual(api.Add(half[0], half[0]), c.X)
+	return nil
+}
+
+func TestRegisterHints(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	RegisterHints(halveHint)
+	ids := make(map[solver.HintID]bool)
+	for _, hint := range Hints() {
+		ids[solver.GetHintID(hint)] = true
+	}
+	assert.True(ids[solver.GetHintID(koalabear.InvFHint)])
+	assert.True(ids[solver.GetHintID(halveHint)])
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &halveCircuit{})
+	assert.NoError(err)
+	pk, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	fullWitness, err := frontend.NewWitness(&halveCircuit{X: 42}, ecc.BN254.ScalarField())
+	assert.NoError(err)
+	session := NewProvingSession(pk, vk, ccs)
+	pf, err := session.Prove(context.Background(), fullWitness)
+	assert.NoError(err)
+	pubWitness, err := fullWitness.Public()
+	assert.NoError(err)
+	assert.NoError(session.Verify(pf, pubWitness))
+}
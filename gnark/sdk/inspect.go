@@ -0,0 +1,362 @@
+package sdk
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/brevis-network/pico/gnark/verify"
+	"github.com/consensys/gnark-crypto/ecc"
+	"io"
+	"os"
+)
+
+// Inspection describes the artifacts a command reads, as printed by inspect. A file
+// that cannot be read has its error instead of its description.
+type Inspection struct {
+	Pk          *ArtifactInspection `json:"pk"`
+	Vk          *ArtifactInspection `json:"vk"`
+	Ccs         *ArtifactInspection `json:"ccs"`
+	Witness     *ArtifactInspection `json:"witness"`
+	Constraints *ArtifactInspection `json:"constraints"`
+}
+
+// ArtifactInspection describes one artifact.
+type ArtifactInspection struct {
+	Path  string `json:"path"`
+	Info  any    `json:"info,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// WitnessInfo summarizes a witness json.
+type WitnessInfo struct {
+	Version                int              `json:"version"`
+	Chunks                 int              `json:"chunks"`
+	Vars                   int              `json:"vars"`
+	Felts                  int              `json:"felts"`
+	Exts                   int              `json:"exts"`
+	VkeyHash               string           `json:"vkey_hash"`
+	CommittedValuesDigests []string         `json:"committed_values_digests"`
+	FriConfig              *utils.FriConfig `json:"fri_config,omitempty"`
+	// AllowedVkeys are those of an aggregated witness, whose public inputs start with
+	// the root of their tree.
+	AllowedVkeys []string `json:"allowed_vkeys,omitempty"`
+}
+
+// ConstraintsInfo summarizes a constraints json. Hash is the constraints hash of the
+// ccs header of a ccs compiled from the file.
+type ConstraintsInfo struct {
+	Constraints int              `json:"constraints"`
+	Hash        string           `json:"hash"`
+	FriConfig   *utils.FriConfig `json:"fri_config,omitempty"`
+}
+
+// Inspect writes as json what the key, ccs, witness and constraints files of the
+// environment hold: the headers of the keys and ccs, and summaries of the witness and
+// constraints. Keys and ccs are not deserialized, so it returns at once.
+func Inspect(w io.Writer) error {
+	inspection := Inspection{
+		Pk:          inspectArtifact(os.Getenv("PK_PATH"), inspectKey),
+		Vk:          inspectArtifact(os.Getenv("VK_PATH"), inspectKey),
+		Ccs:         inspectArtifact(os.Getenv("CCS_PATH"), inspectCcs),
+		Witness:     inspectArtifact(os.Getenv("WITNESS_JSON"), inspectWitness),
+		Constraints: inspectArtifact(utils.ConstraintsPath(), inspectConstraints),
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(inspection)
+}
+
+func inspectArtifact(path string, inspect func(path string) (any, error)) *ArtifactInspection {
+	info, err := inspect(path)
+	if err != nil {
+		return &ArtifactInspection{Path: path, Error: err.Error()}
+	}
+	return &ArtifactInspection{Path: path, Info: info}
+}
+
+func inspectKey(path string) (any, error) {
+	header, err := utils.ReadKeyHeader(path)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, fmt.Errorf("no key header, written before key headers")
+	}
+	return header, nil
+}
+
+func inspectCcs(path string) (any, error) {
+	header, err := utils.ReadCcsHeader(path)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, fmt.Errorf("no ccs header, written before ccs headers")
+	}
+	return header, nil
+}
+
+func inspectWitness(path string) (any, error) {
+	data, err := utils.ReadArtifact(path)
+	if err != nil {
+		return nil, err
+	}
+	return witnessInfo(data)
+}
+
+func witnessInfo(data []byte) (*WitnessInfo, error) {
+	single, multi, err := utils.ParseWitness(data)
+	if err != nil {
+		return nil, err
+	}
+	chunks := []utils.WitnessInput{}
+	info := &WitnessInfo{}
+	if single != nil {
+		chunks = append(chunks, *single)
+		info.Version = single.FormatVersion()
+	} else {
+		chunks = multi.Chunks
+		info.Version = multi.FormatVersion()
+		info.AllowedVkeys = multi.AllowedVkeys
+	}
+	first := chunks[0]
+	info.Chunks = len(chunks)
+	info.Vars, info.Felts, info.Exts = len(first.Vars), len(first.Felts), len(first.Exts)
+	info.VkeyHash = first.VkeyHash
+	info.FriConfig = first.FriConfig
+	for _, chunk := range chunks {
+		info.CommittedValuesDigests = append(info.CommittedValuesDigests, chunk.CommittedValuesDigest)
+	}
+	return info, nil
+}
+
+func inspectConstraints(path string) (any, error) {
+	data, err := utils.ReadArtifact(path)
+	if err != nil {
+		return nil, err
+	}
+	return constraintsInfo(data)
+}
+
+func constraintsInfo(data []byte) (*ConstraintsInfo, error) {
+	file, err := utils.ParseConstraints(data)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(data)
+	return &ConstraintsInfo{
+		Constraints: len(file.Constraints),
+		Hash:        hex.EncodeToString(hash[:]),
+		FriConfig:   file.FriConfig,
+	}, nil
+}
+
+// FileInspection describes an artifact file of any kind, as printed by inspect <file>.
+type FileInspection struct {
+	utils.ArtifactDigest
+	// Kind is pk, vk, ccs, bundle, witness-bin (a gnark binary witness), proof, witness
+	// (a witness json) or constraints.
+	Kind  string `json:"kind"`
+	Curve string `json:"curve,omitempty"`
+	// GnarkVersion and FormatVersion are the versions of gnark and of the pico format
+	// that wrote the file, when it records them.
+	GnarkVersion  string `json:"gnark_version,omitempty"`
+	FormatVersion int    `json:"format_version,omitempty"`
+	// NbPublicInputs is the number of public inputs of the circuit, unknown for pks.
+	NbPublicInputs int `json:"nb_public_inputs,omitempty"`
+	// Info is the header of a key, ccs or bundle, or the summary of another file.
+	Info any `json:"info,omitempty"`
+}
+
+// ProofInfo summarizes a proof file.
+type ProofInfo struct {
+	Format       string   `json:"format"`
+	HashToField  string   `json:"hash_to_field,omitempty"`
+	Commitments  int      `json:"commitments"`
+	PublicInputs []string `json:"public_inputs,omitempty"`
+	Signer       string   `json:"signer,omitempty"`
+}
+
+// InspectFile identifies the artifact at path from its content, whatever its name, and
+// writes as json its kind, size, sha256, curve, versions and public input count. Keys
+// and ccs are identified by their header and not deserialized, except vks, whose public
+// inputs are counted. Keys, ccs and binary witnesses written without header are only
+// recognized as BN254 vks and as gnark witnesses of the curve of CURVE.
+func InspectFile(w io.Writer, path string) error {
+	digest, err := utils.DigestArtifact(path)
+	if err != nil {
+		return err
+	}
+	inspection := &FileInspection{ArtifactDigest: *digest}
+	err = identifyArtifact(path, inspection)
+	if err != nil {
+		return fmt.Errorf("fail to inspect %s: %w", path, err)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(inspection)
+}
+
+func identifyArtifact(path string, inspection *FileInspection) error {
+	keyHeader, err := utils.ReadKeyHeader(path)
+	if err != nil {
+		return err
+	}
+	if keyHeader != nil {
+		inspection.Kind, inspection.Curve, inspection.Info = keyHeader.Kind, keyHeader.Curve, keyHeader
+		inspection.GnarkVersion, inspection.FormatVersion = keyHeader.GnarkVersion, keyHeader.Version
+		if keyHeader.Kind == "vk" && keyHeader.Curve == ecc.BN254.String() {
+			vk, err := readVerifyingKey(path)
+			if err != nil {
+				return err
+			}
+			inspection.NbPublicInputs = vk.NbPublicInputs()
+		}
+		return nil
+	}
+	ccsHeader, err := utils.ReadCcsHeader(path)
+	if err != nil {
+		return err
+	}
+	if ccsHeader != nil {
+		inspection.Kind, inspection.Curve, inspection.Info = "ccs", ccsHeader.Curve, ccsHeader
+		inspection.GnarkVersion, inspection.FormatVersion = ccsHeader.GnarkVersion, ccsHeader.Version
+		inspection.NbPublicInputs = ccsHeader.NbPublicInputs
+		return nil
+	}
+	bundleHeader, err := utils.ReadBundleHeader(path)
+	if err != nil {
+		return err
+	}
+	if bundleHeader != nil {
+		inspection.Kind, inspection.Info, inspection.FormatVersion = "bundle", bundleHeader, bundleHeader.Version
+		if bundleHeader.Ccs != nil {
+			inspection.Curve, inspection.GnarkVersion = bundleHeader.Ccs.Curve, bundleHeader.Ccs.GnarkVersion
+			inspection.NbPublicInputs = bundleHeader.Ccs.NbPublicInputs
+		}
+		return nil
+	}
+
+	data, err := utils.ReadProofArtifact(path)
+	if err != nil {
+		return err
+	}
+	text := bytes.TrimSpace(data)
+	switch {
+	case len(text) > 0 && (text[0] == '{' || text[0] == '['):
+		return identifyJson(text, inspection)
+	case bytes.HasPrefix(text, []byte("0x")) && bytes.Contains(text, []byte(",")):
+		return identifyProof(data, utils.ProofFormatLegacy, inspection)
+	case bytes.HasPrefix(text, []byte("0x")):
+		// gateway proofs are abi encoded, their public inputs are not read back
+		inspection.Kind, inspection.Curve, inspection.Info = "proof", ecc.BN254.String(), &ProofInfo{Format: utils.ProofFormatGateway}
+		return nil
+	}
+	// binary files without header: a legacy vk or a gnark witness
+	vk, err := readVerifyingKey(path)
+	if err == nil {
+		inspection.Kind, inspection.Curve, inspection.NbPublicInputs = "vk", ecc.BN254.String(), vk.NbPublicInputs()
+		return nil
+	}
+	curve, err := utils.CurveFromEnv()
+	if err != nil {
+		return err
+	}
+	nbPublic, ok := gnarkWitnessPublic(data, curve)
+	if !ok {
+		return fmt.Errorf("unrecognized file, neither a key, ccs, bundle, witness, proof nor constraints")
+	}
+	inspection.Kind, inspection.Curve, inspection.NbPublicInputs = "witness-bin", curve.String(), nbPublic
+	return nil
+}
+
+// identifyJson identifies a json proof, witness or constraints file by its fields.
+func identifyJson(data []byte, inspection *FileInspection) error {
+	if data[0] == '[' {
+		return identifyConstraints(data, inspection)
+	}
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return fmt.Errorf("invalid json: %w", err)
+	}
+	switch {
+	case fields["proof"] != nil:
+		return identifyProof(data, utils.ProofFormatJson, inspection)
+	case fields["constraints"] != nil:
+		return identifyConstraints(data, inspection)
+	}
+	info, err := witnessInfo(data)
+	if err != nil {
+		return fmt.Errorf("unrecognized json, neither a proof, witness nor constraints: %w", err)
+	}
+	inspection.Kind, inspection.Info, inspection.FormatVersion = "witness", info, info.Version
+	// the wrapper exposes the vkey hash, or the two limbs of the root of the allowed
+	// vkeys, and one digest per chunk, laid out by the public input layout for a single
+	// chunk
+	inspection.NbPublicInputs = 1 + info.Chunks
+	layout, err := utils.PublicLayoutFromEnv()
+	switch {
+	case len(info.AllowedVkeys) > 0:
+		inspection.NbPublicInputs = 2 + info.Chunks
+	case err == nil && info.Chunks == 1 && !layout.IsDefault():
+		inspection.NbPublicInputs = layout.NbPublicInputs()
+	}
+	return nil
+}
+
+func identifyConstraints(data []byte, inspection *FileInspection) error {
+	info, err := constraintsInfo(data)
+	if err != nil {
+		return err
+	}
+	inspection.Kind, inspection.Info = "constraints", info
+	return nil
+}
+
+func identifyProof(data []byte, format string, inspection *FileInspection) error {
+	proof, err := verify.ParseProof(data)
+	if err != nil {
+		return err
+	}
+	info := &ProofInfo{Format: format, HashToField: proof.HashToField, Commitments: len(proof.Commitments)}
+	for _, input := range proof.PublicInputs {
+		info.PublicInputs = append(info.PublicInputs, input.String())
+	}
+	if proof.Signature != nil {
+		info.Signer = proof.Signature.Signer
+	}
+	inspection.Kind, inspection.Curve, inspection.Info = "proof", ecc.BN254.String(), info
+	inspection.NbPublicInputs = len(proof.PublicInputs)
+	return nil
+}
+
+func readVerifyingKey(path string) (*verify.VerifyingKey, error) {
+	f, err := utils.OpenKeyArtifact(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return verify.ReadVerifyingKey(f)
+}
+
+// gnarkWitnessPublic returns the number of public inputs of data if it is a full
+// witness over the scalar field of curve in gnark's binary encoding: the numbers of
+// public and secret values, then the vector of their elements, prefixed by its length.
+func gnarkWitnessPublic(data []byte, curve ecc.ID) (int, bool) {
+	if len(data) < 12 {
+		return 0, false
+	}
+	nbPublic := binary.BigEndian.Uint32(data[0:4])
+	nbSecret := binary.BigEndian.Uint32(data[4:8])
+	n := binary.BigEndian.Uint32(data[8:12])
+	size := uint64((curve.ScalarField().BitLen() + 7) / 8)
+	if uint64(nbPublic)+uint64(nbSecret) != uint64(n) || uint64(len(data)) != 12+size*uint64(n) {
+		return 0, false
+	}
+	return int(nbPublic), true
+}
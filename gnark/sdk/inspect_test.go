@@ -0,0 +1,123 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &cubicCircuit{})
+	assert.NoError(err)
+	_, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	dir := t.TempDir()
+	assert.NoError(utils.WriteVerifyingKey(filepath.Join(dir, "vm_vk"), vk))
+	assert.NoError(os.WriteFile(filepath.Join(dir, "witness.json"), []byte(`{"chunks": [
+		{"vars": ["7"], "felts": ["1", "2"], "vkey_hash": "7", "committed_values_digest": "100"},
+		{"vars": ["7"], "felts": ["1", "3"], "vkey_hash": "7", "committed_values_digest": "200"}
+	]}`), 0644))
+	assert.NoError(os.WriteFile(filepath.Join(dir, "constraints.json"), []byte(`[{"opcode": "WitnessV", "args": [["vk"], ["0"]]}]`), 0644))
+	t.Setenv("PK_PATH", filepath.Join(dir, "vm_pk"))
+	t.Setenv("VK_PATH", filepath.Join(dir, "vm_vk"))
+	t.Setenv("CCS_PATH", filepath.Join(dir, "vm_ccs"))
+	t.Setenv("WITNESS_JSON", filepath.Join(dir, "witness.json"))
+	t.Setenv("CONSTRAINTS_JSON", filepath.Join(dir, "constraints.json"))
+
+	var out bytes.Buffer
+	assert.NoError(Inspect(&out))
+	var inspection struct {
+		Pk, Vk, Ccs ArtifactInspection
+		Witness     struct {
+			Info WitnessInfo
+		}
+		Constraints struct {
+			Info ConstraintsInfo
+		}
+	}
+	assert.NoError(json.Unmarshal(out.Bytes(), &inspection), out.String())
+	assert.Contains(inspection.Pk.Error, "no such file")
+	assert.Equal("vk", inspection.Vk.Info.(map[string]any)["kind"])
+	assert.Contains(inspection.Ccs.Error, "no such file")
+	assert.Equal(WitnessInfo{Version: 1, Chunks: 2, Vars: 1, Felts: 2, VkeyHash: "7", CommittedValuesDigests: []string{"100", "200"}}, inspection.Witness.Info)
+	assert.Equal(1, inspection.Constraints.Info.Constraints)
+	assert.Len(inspection.Constraints.Info.Hash, 64)
+}
+
+func TestInspectFile(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &wideCubicCircuit{})
+	assert.NoError(err)
+	pk, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	fullWitness, err := frontend.NewWitness(&wideCubicCircuit{X: 3, Y: 35, Z: 3}, ecc.BN254.ScalarField())
+	assert.NoError(err)
+	pubWitness, err := fullWitness.Public()
+	assert.NoError(err)
+	proof, err := groth16.Prove(ccs, pk, fullWitness)
+	assert.NoError(err)
+	legacyProof, err := utils.FormatProof(utils.ProofFormatLegacy, proof, pubWitness)
+	assert.NoError(err)
+	jsonProof, err := utils.FormatProof(utils.ProofFormatJson, proof, pubWitness)
+	assert.NoError(err)
+	header := &utils.CcsHeader{Version: utils.CcsFormatVersion, GnarkVersion: utils.GnarkVersion(), Curve: "bn254", Field: "kb", Chunks: 1, NbPublicInputs: 2}
+
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+	t.Setenv("CURVE", "bn254")
+	assert.NoError(utils.WriteKeyPair(utils.WriteArtifact, path("vm_pk"), path("vm_vk"), pk, vk))
+	assert.NoError(utils.WriteCcsArtifact(utils.WriteArtifact, path("vm_ccs"), header, ccs))
+	assert.NoError(utils.WriteWitness(path("witness.bin"), fullWitness))
+	assert.NoError(os.WriteFile(path("proof.data"), legacyProof, 0644))
+	assert.NoError(os.WriteFile(path("proof.json"), jsonProof, 0644))
+	assert.NoError(os.WriteFile(path("witness.json"), []byte(`{"vars": ["7"], "felts": ["1", "2"], "vkey_hash": "7", "committed_values_digest": "100"}`), 0644))
+	assert.NoError(os.WriteFile(path("constraints.json"), []byte(`[{"opcode": "WitnessV", "args": [["vk"], ["0"]]}]`), 0644))
+	var legacyVk bytes.Buffer
+	_, err = vk.WriteTo(&legacyVk)
+	assert.NoError(err)
+	assert.NoError(os.WriteFile(path("legacy_vk"), legacyVk.Bytes(), 0644))
+	assert.NoError(os.WriteFile(path("other"), []byte("not an artifact"), 0644))
+
+	for _, c := range []struct {
+		name, kind     string
+		nbPublicInputs int
+	}{
+		{"vm_pk", "pk", 0},
+		{"vm_vk", "vk", 2},
+		{"legacy_vk", "vk", 2},
+		{"vm_ccs", "ccs", 2},
+		{"witness.bin", "witness-bin", 2},
+		{"proof.data", "proof", 2},
+		{"proof.json", "proof", 2},
+		{"witness.json", "witness", 2},
+		{"constraints.json", "constraints", 0},
+	} {
+		var out bytes.Buffer
+		assert.NoError(InspectFile(&out, path(c.name)), c.name)
+		var inspection FileInspection
+		assert.NoError(json.Unmarshal(out.Bytes(), &inspection), out.String())
+		assert.Equal(c.kind, inspection.Kind, c.name)
+		assert.Equal(c.nbPublicInputs, inspection.NbPublicInputs, c.name)
+		assert.Len(inspection.Sha256, 64, c.name)
+		assert.NotZero(inspection.Size, c.name)
+		if c.kind != "witness" && c.kind != "constraints" {
+			assert.Equal("bn254", inspection.Curve, c.name)
+		}
+		if c.name == "vm_pk" || c.name == "vm_ccs" {
+			assert.Equal(utils.GnarkVersion(), inspection.GnarkVersion, c.name)
+		}
+	}
+	assert.ErrorContains(InspectFile(&bytes.Buffer{}, path("other")), "unrecognized file")
+	assert.Error(InspectFile(&bytes.Buffer{}, path("missing")))
+}
@@ -0,0 +1,234 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// IntakeSummaryName is the name of the summary ProveDir writes in the output directory.
+const IntakeSummaryName = "summary.json"
+
+// IntakeResult is the outcome of one witness of ProveDir. Paths are relative to the
+// input and output directories.
+type IntakeResult struct {
+	Witness    string `json:"witness"`
+	Proof      string `json:"proof,omitempty"`
+	Succeeded  bool   `json:"succeeded"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// IntakeSummary is the summary json of ProveDir.
+type IntakeSummary struct {
+	InputDir  string         `json:"input_dir"`
+	OutputDir string         `json:"output_dir"`
+	Succeeded int            `json:"succeeded"`
+	Failed    int            `json:"failed"`
+	Results   []IntakeResult `json:"results"`
+}
+
+// ProveDir proves every witness file under INPUT_DIR whose name matches
+// WITNESS_PATTERN with the keys at PK_PATH, VK_PATH and CCS_PATH, which are loaded
+// once. The proof of <dir>/<witness> is written to <dir>/proof.data, or proof.json
+// with PROOF_FORMAT=json, under OUTPUT_DIR, the layout of the per-proof directories
+// of the rust prover. A failed witness does not stop the batch; the outcome of every
+// witness is written to summary.json in OUTPUT_DIR, and ProveDir fails if any did.
+func ProveDir(ctx context.Context, field string) error {
+	inputDir, outputDir := os.Getenv("INPUT_DIR"), os.Getenv("OUTPUT_DIR")
+	witnesses, err := findWitnesses(inputDir, os.Getenv("WITNESS_PATTERN"))
+	if err != nil {
+		return err
+	}
+	if len(witnesses) == 0 {
+		return fmt.Errorf("no witness matching %q in %s", os.Getenv("WITNESS_PATTERN"), inputDir)
+	}
+	curve, err := utils.CurveFromEnv()
+	if err != nil {
+		return err
+	}
+	err = checkBeforeProve(curve, os.Getenv("CCS_PATH"))
+	if err != nil {
+		return err
+	}
+	session, err := LoadProvingSession(curve, os.Getenv("PK_PATH"), os.Getenv("VK_PATH"), os.Getenv("CCS_PATH"))
+	if err != nil {
+		return err
+	}
+	proofName := "proof.data"
+	if os.Getenv("PROOF_FORMAT") == utils.ProofFormatJson {
+		proofName = "proof.json"
+	}
+
+	summary := IntakeSummary{InputDir: inputDir, OutputDir: outputDir, Results: []IntakeResult{}}
+	for i, rel := range witnesses {
+		if ctx.Err() != nil {
+			break
+		}
+		result := IntakeResult{Witness: rel, Proof: filepath.Join(filepath.Dir(rel), proofName)}
+		start := time.Now()
+		err := proveIntakeWitness(ctx, session, field, filepath.Join(inputDir, rel), filepath.Join(outputDir, result.Proof))
+		result.DurationMs = time.Since(start).Milliseconds()
+		if err != nil {
+			result.Proof = ""
+			result.Error = err.Error()
+			summary.Failed++
+			fmt.Printf("[%d/%d] %s failed: %v\n", i+1, len(witnesses), rel, err)
+		} else {
+			result.Succeeded = true
+			summary.Succeeded++
+			fmt.Printf("[%d/%d] %s proved in %v\n", i+1, len(witnesses), rel, time.Duration(result.DurationMs)*time.Millisecond)
+		}
+		summary.Results = append(summary.Results, result)
+	}
+
+	err = writeIntakeSummary(filepath.Join(outputDir, IntakeSummaryName), &summary)
+	if err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if summary.Failed > 0 {
+		return fmt.Errorf("%d of %d witnesses failed, see %s", summary.Failed, len(witnesses), filepath.Join(outputDir, IntakeSummaryName))
+	}
+	return nil
+}
+
+// findWitnesses returns the paths, relative to dir and sorted, of the files matching
+// pattern. Proofs are named after their directory, so a directory may hold one.
+func findWitnesses(dir, pattern string) ([]string, error) {
+	_, err := filepath.Match(pattern, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid witness pattern %q: %w", pattern, err)
+	}
+	var witnesses []string
+	dirs := make(map[string]string)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if ok, _ := filepath.Match(pattern, d.Name()); !ok {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if other, ok := dirs[filepath.Dir(rel)]; ok {
+			return fmt.Errorf("witnesses %s and %s are in the same directory, narrow the witness pattern", other, rel)
+		}
+		dirs[filepath.Dir(rel)] = rel
+		witnesses = append(witnesses, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fail to scan %s: %w", dir, err)
+	}
+	sort.Strings(witnesses)
+	return witnesses, nil
+}
+
+func proveIntakeWitness(ctx context.Context, session *ProvingSession, field, witnessPath, proofPath string) error {
+	data, err := utils.ReadWitnessJson(witnessPath)
+	if err != nil {
+		return fmt.Errorf("fail to read witness: %w", err)
+	}
+	single, multi, err := utils.ParseWitness(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse witness json: %w", err)
+	}
+	var fullWitness, pubWitness witness.Witness
+	if multi != nil {
+		fullWitness, pubWitness, err = NewMultiWitness(session.Curve(), field, *multi)
+	} else {
+		fullWitness, pubWitness, err = NewWitness(session.Curve(), field, *single)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get witness: %w", err)
+	}
+	err = checkWitnessShape(session.ccs, pubWitness)
+	if err != nil {
+		return err
+	}
+
+	// the keys are loaded once for all the witnesses
+	timings := newProofTimings()
+	var pf groth16.Proof
+	err = addWallTime(&timings.ProveMs, func() (err error) {
+		pf, err = session.Prove(ctx, fullWitness)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("fail to prove: %w", err)
+	}
+	pf, err = rerandomize(session, pf)
+	if err != nil {
+		return err
+	}
+	err = addWallTime(&timings.VerifyMs, func() error {
+		return session.Verify(pf, pubWitness)
+	})
+	if err != nil {
+		return fmt.Errorf("fail to verify: %w", err)
+	}
+	res, err := formatProof(pf, pubWitness)
+	if err != nil {
+		return fmt.Errorf("failed to format proof: %w", err)
+	}
+	err = os.MkdirAll(filepath.Dir(proofPath), 0755)
+	if err != nil {
+		return err
+	}
+	err = utils.WriteBytesAtomic(proofPath, res)
+	if err != nil {
+		return err
+	}
+	err = writeProofSignature(proofPath, pf, pubWitness)
+	if err != nil {
+		return err
+	}
+	err = writeProofManifest(session, witnessPath, proofPath, pubWitness)
+	if err != nil {
+		return err
+	}
+	public, err := utils.PublicInputs(pubWitness)
+	if err != nil {
+		return err
+	}
+	timings.finish(session.ccs, public)
+	err = writeProofTimings(proofPath, timings)
+	if err != nil {
+		return fmt.Errorf("fail to write timings: %w", err)
+	}
+	return nil
+}
+
+func writeIntakeSummary(path string, summary *IntakeSummary) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	err = utils.WriteFileAtomic(path, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(summary)
+	})
+	if err != nil {
+		return fmt.Errorf("fail to write summary: %w", err)
+	}
+	fmt.Printf("%d proved, %d failed, summary written to %s\n", summary.Succeeded, summary.Failed, path)
+	return nil
+}
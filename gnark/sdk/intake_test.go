@@ -0,0 +1,80 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindWitnesses(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	dir := t.TempDir()
+	for _, name := range []string{"b/groth16_witness.json", "a/groth16_witness.json", "a/nested/groth16_witness.json", "a/proof.data"} {
+		assert.NoError(os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755))
+		assert.NoError(os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0644))
+	}
+	witnesses, err := findWitnesses(dir, "groth16_witness.json")
+	assert.NoError(err)
+	assert.Equal([]string{"a/groth16_witness.json", "a/nested/groth16_witness.json", "b/groth16_witness.json"}, witnesses)
+
+	// proofs are named after their directory
+	assert.NoError(os.WriteFile(filepath.Join(dir, "a", "other_witness.json"), []byte("{}"), 0644))
+	_, err = findWitnesses(dir, "*.json")
+	assert.ErrorContains(err, "same directory")
+	_, err = findWitnesses(dir, "[")
+	assert.ErrorContains(err, "invalid witness pattern")
+}
+
+func TestProveDirSummary(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &cubicCircuit{})
+	assert.NoError(err)
+	pk, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+
+	dir := t.TempDir()
+	input, output := filepath.Join(dir, "in"), filepath.Join(dir, "out")
+	t.Setenv("CURVE", "bn254")
+	t.Setenv("PK_PATH", filepath.Join(dir, "vm_pk"))
+	t.Setenv("VK_PATH", filepath.Join(dir, "vm_vk"))
+	t.Setenv("CCS_PATH", filepath.Join(dir, "vm_ccs"))
+	t.Setenv("INPUT_DIR", input)
+	t.Setenv("OUTPUT_DIR", output)
+	t.Setenv("WITNESS_PATTERN", "groth16_witness.json")
+	assert.NoError(utils.WriteKeyPair(utils.WriteArtifact, os.Getenv("PK_PATH"), os.Getenv("VK_PATH"), pk, vk))
+	assert.NoError(utils.WriteCcs(os.Getenv("CCS_PATH"), ccs))
+
+	assert.ErrorContains(ProveDir(context.Background(), "kb"), "fail to scan")
+	assert.NoError(os.MkdirAll(input, 0755))
+	assert.ErrorContains(ProveDir(context.Background(), "kb"), "no witness")
+
+	// failed witnesses are recorded and the others still attempted
+	for name, data := range map[string]string{"proof1": "not json", "proof2": `{"vkey_hash": "0x1", "committed_values_digest": "0x2"}`} {
+		assert.NoError(os.MkdirAll(filepath.Join(input, name), 0755))
+		assert.NoError(os.WriteFile(filepath.Join(input, name, "groth16_witness.json"), []byte(data), 0644))
+	}
+	assert.ErrorContains(ProveDir(context.Background(), "kb"), "2 of 2 witnesses failed")
+
+	data, err := os.ReadFile(filepath.Join(output, IntakeSummaryName))
+	assert.NoError(err)
+	var summary IntakeSummary
+	assert.NoError(json.Unmarshal(data, &summary))
+	assert.Equal(0, summary.Succeeded)
+	assert.Equal(2, summary.Failed)
+	assert.Len(summary.Results, 2)
+	assert.Equal("proof1/groth16_witness.json", summary.Results[0].Witness)
+	assert.Contains(summary.Results[0].Error, "failed to parse witness json")
+	assert.Equal("proof2/groth16_witness.json", summary.Results[1].Witness)
+	assert.Contains(summary.Results[1].Error, "witness has 2 public inputs, ccs expects 1")
+	assert.Empty(summary.Results[1].Proof)
+}
@@ -0,0 +1,309 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/constraint"
+	"io/fs"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"sync"
+)
+
+// ErrInsufficientMemory reports keys that do not fit in the memory budget together
+// with a proof on them.
+var ErrInsufficientMemory = errors.New("insufficient memory")
+
+// LoadStrategy is how a KeyLoader holds the keys in memory. gnark deserializes keys
+// into its own structures, so there is no strategy mapping the key files instead.
+type LoadStrategy int
+
+const (
+	// LoadResident loads every key set once and keeps it.
+	LoadResident LoadStrategy = iota
+	// LoadOnDemand loads a key set when a proof needs it, unloading the least recently
+	// used key sets not proving anything to stay within the budget.
+	LoadOnDemand
+)
+
+func (s LoadStrategy) String() string {
+	if s == LoadOnDemand {
+		return "on-demand"
+	}
+	return "resident"
+}
+
+// KeySource locates a key set, on disk or in a bucket. An empty VkPath makes a
+// prove-only session.
+type KeySource struct {
+	Curve   ecc.ID
+	PkPath  string
+	VkPath  string
+	CcsPath string
+}
+
+// EstimateMemory estimates the memory of the keys once loaded from the size of their
+// files: the compressed points of a pk take twice their size in memory, raw points
+// and the ccs about their size. A missing ccs, which prove compiles instead, is not
+// counted.
+func (s KeySource) EstimateMemory() (uint64, error) {
+	pkSize, err := utils.ArtifactSize(s.PkPath)
+	if err != nil {
+		return 0, err
+	}
+	ccsSize, err := utils.ArtifactSize(s.CcsPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		ccsSize, err = 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	header, err := utils.ReadKeyHeader(s.PkPath)
+	if err != nil {
+		return 0, err
+	}
+	if header == nil || !header.Raw {
+		pkSize *= 2
+	}
+	return uint64(pkSize + ccsSize), nil
+}
+
+// KeyLoader loads the key sets of a prover within a memory budget, estimating the peak
+// memory of a proof as the memory of its keys. Every key set is LoadResident if they
+// all fit with a proof, LoadOnDemand otherwise. Sessions of key sets not fitting with
+// a proof on their own fail their proofs with ErrInsufficientMemory.
+type KeyLoader struct {
+	maxMemory uint64
+	strategy  LoadStrategy
+	estimates map[KeySource]uint64
+	// largest is the largest estimate of the key sets that fit.
+	largest uint64
+	cache   *keyCache
+}
+
+// NewKeyLoader plans the loading of sources in maxMemory bytes, 0 loading every key
+// set resident without estimating it.
+func NewKeyLoader(maxMemory uint64, sources []KeySource) (*KeyLoader, error) {
+	l := &KeyLoader{maxMemory: maxMemory, estimates: make(map[KeySource]uint64)}
+	if maxMemory == 0 {
+		return l, nil
+	}
+	var total uint64
+	for _, source := range sources {
+		estimate, err := source.EstimateMemory()
+		if err != nil {
+			return nil, fmt.Errorf("fail to estimate the memory of %s: %w", source.PkPath, err)
+		}
+		l.estimates[source] = estimate
+		if l.fits(estimate) {
+			total += estimate
+			l.largest = max(l.largest, estimate)
+		}
+	}
+	if total+l.largest > maxMemory {
+		l.strategy = LoadOnDemand
+		l.cache = newKeyCache(maxMemory - l.largest)
+	}
+	return l, nil
+}
+
+func (l *KeyLoader) Strategy() LoadStrategy {
+	return l.strategy
+}
+
+// ProofEstimate is the estimated peak memory of a proof on the largest key set, 0
+// without a budget.
+func (l *KeyLoader) ProofEstimate() uint64 {
+	return l.largest
+}
+
+func (l *KeyLoader) String() string {
+	if l.maxMemory == 0 {
+		return l.strategy.String()
+	}
+	return fmt.Sprintf("%s in %d MB", l.strategy, l.maxMemory>>20)
+}
+
+// fits reports whether keys of estimate bytes fit with a proof on them.
+func (l *KeyLoader) fits(estimate uint64) bool {
+	return l.maxMemory == 0 || 2*estimate <= l.maxMemory
+}
+
+// Check returns ErrInsufficientMemory if the keys of source, one of those the loader
+// was planned for, do not fit with a proof.
+func (l *KeyLoader) Check(source KeySource) error {
+	estimate := l.estimates[source]
+	if l.fits(estimate) {
+		return nil
+	}
+	return fmt.Errorf("%w: keys %s need about %d MB and as much to prove, the memory budget is %d MB", ErrInsufficientMemory, source.PkPath, estimate>>20, l.maxMemory>>20)
+}
+
+// checkMaxMemory fails before the keys at PK_PATH and ccsPath are loaded if they do
+// not fit with a proof in MAX_MEMORY MB, unset or 0 for no limit.
+func checkMaxMemory(curve ecc.ID, ccsPath string) error {
+	maxMemory, err := strconv.ParseUint(os.Getenv("MAX_MEMORY"), 10, 64)
+	if err != nil || maxMemory == 0 {
+		return nil
+	}
+	source := KeySource{Curve: curve, PkPath: os.Getenv("PK_PATH"), CcsPath: ccsPath}
+	loader, err := NewKeyLoader(maxMemory<<20, []KeySource{source})
+	if err != nil {
+		return err
+	}
+	return loader.Check(source)
+}
+
+// Load returns the session of source, with its keys read now if resident or for its
+// proofs otherwise. The vk, small, is always read now.
+func (l *KeyLoader) Load(source KeySource) (*ProvingSession, error) {
+	estimate := l.estimates[source]
+	if l.strategy == LoadResident && l.fits(estimate) {
+		return LoadProvingSession(source.Curve, source.PkPath, source.VkPath, source.CcsPath)
+	}
+
+	session := &ProvingSession{curve: source.Curve, keys: &sessionKeys{source: source, estimate: estimate, cache: l.cache}}
+	session.keys.refused = l.Check(source)
+	if source.VkPath != "" {
+		vk := groth16.NewVerifyingKey(source.Curve)
+		err := utils.ReadVerifyingKey(source.VkPath, vk)
+		if err != nil {
+			return nil, fmt.Errorf("fail to read verifying key: %w", err)
+		}
+		_, err = utils.CheckKeyFingerprint(source.PkPath, vk)
+		if err != nil {
+			return nil, fmt.Errorf("key mismatch: %w", err)
+		}
+		session.vk = vk
+	}
+	return session, nil
+}
+
+// sessionKeys are the pk and ccs of an on-demand session, loaded while in keyCache.
+type sessionKeys struct {
+	source   KeySource
+	estimate uint64
+	cache    *keyCache
+	// refused is returned to every proof of keys that never fit.
+	refused error
+
+	// guarded by cache.mu
+	pk      groth16.ProvingKey
+	ccs     constraint.ConstraintSystem
+	loading bool
+	users   int
+}
+
+// keyCache holds the keys of on-demand sessions within a budget, shared by every
+// session of a KeyLoader.
+type keyCache struct {
+	budget uint64
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	// loaded are the loaded or loading keys, least recently used first.
+	loaded   []*sessionKeys
+	resident uint64
+}
+
+func newKeyCache(budget uint64) *keyCache {
+	c := &keyCache{budget: budget}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// acquire returns the loaded pk and ccs of k, loading them if needed once the keys
+// not in use have been unloaded to make room. The caller must release k once its
+// proof is done.
+func (c *keyCache) acquire(k *sessionKeys, vk groth16.VerifyingKey) (groth16.ProvingKey, constraint.ConstraintSystem, error) {
+	c.mu.Lock()
+	for {
+		if k.pk != nil {
+			k.users++
+			c.touch(k)
+			c.mu.Unlock()
+			return k.pk, k.ccs, nil
+		}
+		if !k.loading && c.makeRoom(k.estimate) {
+			break
+		}
+		c.cond.Wait()
+	}
+	k.loading = true
+	c.resident += k.estimate
+	c.loaded = append(c.loaded, k)
+	c.mu.Unlock()
+
+	loaded, err := LoadProvingSession(k.source.Curve, k.source.PkPath, "", k.source.CcsPath)
+	if err == nil && vk != nil {
+		err = utils.CheckKeyPair(loaded.pk, vk)
+		if err != nil {
+			err = fmt.Errorf("key mismatch: %w", err)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.cond.Broadcast()
+	k.loading = false
+	if err != nil {
+		c.unload(k)
+		return nil, nil, err
+	}
+	k.pk, k.ccs = loaded.pk, loaded.ccs
+	k.users++
+	return k.pk, k.ccs, nil
+}
+
+func (c *keyCache) release(k *sessionKeys) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k.users--
+	c.cond.Broadcast()
+}
+
+// makeRoom unloads the least recently used keys not in use until need more bytes fit
+// in the budget, and reports whether they do. The caller holds mu.
+func (c *keyCache) makeRoom(need uint64) bool {
+	freed := false
+	for _, k := range append([]*sessionKeys{}, c.loaded...) {
+		if c.resident+need <= c.budget {
+			break
+		}
+		if k.users == 0 && !k.loading {
+			c.unload(k)
+			freed = true
+		}
+	}
+	if freed {
+		// the keys are gigabytes, give them back before loading others
+		debug.FreeOSMemory()
+	}
+	return c.resident+need <= c.budget
+}
+
+// unload drops the keys of k. The caller holds mu.
+func (c *keyCache) unload(k *sessionKeys) {
+	for i, loaded := range c.loaded {
+		if loaded == k {
+			c.loaded = append(c.loaded[:i], c.loaded[i+1:]...)
+			c.resident -= k.estimate
+			break
+		}
+	}
+	k.pk, k.ccs = nil, nil
+}
+
+// touch marks k as the most recently used. The caller holds mu.
+func (c *keyCache) touch(k *sessionKeys) {
+	for i, loaded := range c.loaded {
+		if loaded == k {
+			c.loaded = append(append(c.loaded[:i], c.loaded[i+1:]...), k)
+			return
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package sdk
+
+import (
+	"context"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeyLoader(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// a compressed pk of 100 bytes and a ccs of 50 take about 250 bytes
+	dir := t.TempDir()
+	source := func(name string) KeySource {
+		s := KeySource{Curve: ecc.BN254, PkPath: filepath.Join(dir, name+"_pk"), CcsPath: filepath.Join(dir, name+"_ccs")}
+		assert.NoError(os.WriteFile(s.PkPath, make([]byte, 100), 0o644))
+		assert.NoError(os.WriteFile(s.CcsPath, make([]byte, 50), 0o644))
+		return s
+	}
+	a, b := source("a"), source("b")
+	estimate, err := a.EstimateMemory()
+	assert.NoError(err)
+	assert.Equal(uint64(250), estimate)
+
+	loader, err := NewKeyLoader(1000, []KeySource{a, b})
+	assert.NoError(err)
+	assert.Equal(LoadResident, loader.Strategy(), "both keys and a proof fit")
+	assert.Equal(uint64(250), loader.ProofEstimate())
+
+	loader, err = NewKeyLoader(600, []KeySource{a, b})
+	assert.NoError(err)
+	assert.Equal(LoadOnDemand, loader.Strategy())
+	session, err := loader.Load(a)
+	assert.NoError(err)
+	assert.NoError(session.CheckMemory())
+	assert.Nil(session.Ccs(), "keys are loaded by the proofs")
+	assert.Equal(ecc.BN254, session.Curve())
+
+	// no key set fits with its proof
+	loader, err = NewKeyLoader(400, []KeySource{a, b})
+	assert.NoError(err)
+	session, err = loader.Load(b)
+	assert.NoError(err)
+	assert.ErrorIs(session.CheckMemory(), ErrInsufficientMemory)
+	_, err = session.Prove(context.Background(), nil)
+	assert.ErrorIs(err, ErrInsufficientMemory)
+
+	// a pk of 1 MB takes 2 MB, and as much to prove
+	pkPath := filepath.Join(dir, "large_pk")
+	assert.NoError(os.WriteFile(pkPath, make([]byte, 1<<20), 0o644))
+	t.Setenv("PK_PATH", pkPath)
+	t.Setenv("MAX_MEMORY", "3")
+	assert.ErrorIs(checkMaxMemory(ecc.BN254, a.CcsPath), ErrInsufficientMemory)
+	t.Setenv("MAX_MEMORY", "5")
+	assert.NoError(checkMaxMemory(ecc.BN254, filepath.Join(dir, "compiled_ccs")), "a missing ccs is compiled")
+	t.Setenv("MAX_MEMORY", "0")
+	assert.NoError(checkMaxMemory(ecc.BN254, a.CcsPath))
+}
+
+func TestKeyCache(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	c := newKeyCache(300)
+	loaded := func(estimate uint64) *sessionKeys {
+		k := &sessionKeys{estimate: estimate, cache: c, pk: groth16.NewProvingKey(ecc.BN254)}
+		c.loaded = append(c.loaded, k)
+		c.resident += estimate
+		return k
+	}
+	a, b := loaded(100), loaded(100)
+	c.touch(a)
+
+	// the least recently used keys are unloaded first
+	assert.True(c.makeRoom(200))
+	assert.Nil(b.pk)
+	assert.NotNil(a.pk)
+	assert.Equal(uint64(100), c.resident)
+
+	// keys proving are kept
+	a.users = 1
+	assert.False(c.makeRoom(250))
+	assert.NotNil(a.pk)
+	c.release(a)
+	assert.True(c.makeRoom(250))
+	assert.Nil(a.pk)
+	assert.Equal(uint64(0), c.resident)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/sdk"
+	"os"
+)
+
+// command is a subcommand of the cli.
+type command struct {
+	name string
+	// legacy is the -cmd value of the command in the flag-only invocation.
+	legacy  string
+	usage   string
+	options []string
+	run     func(ctx context.Context, field string) error
+	// arg is the option set by the single positional argument the command takes, if any.
+	arg string
+	// defaults replace the defaults of options for the command; paths under ./output/
+	// are in -output-dir.
+	defaults map[string]string
+}
+
+// Options shared by several commands.
+var (
+	circuitOptions = []string{"field", "curve", "timeout", "witness", "constraints", "pico-dir", "groth16", "range-check",
+		"poseidon2-cross-check", "poseidon2-constant-folding", "public-input-order", "public-input-digest-bits", "public-input-packing", "public-input-values", "public-input-digest-hash", "public-input-vkey", "compile-capacity", "compile-compress-threshold", "debug"}
+	decryptOptions  = []string{"key-passphrase-file", "key-identity"}
+	keyOptions      = concat([]string{"pk", "vk", "ccs", "fast-keys"}, decryptOptions)
+	proofOptions    = []string{"proof", "proof-format", "compress", "rerandomize", "hash-to-field", "cgroup-memory", "max-memory", "prover-key-file", "prover-key-scheme", "write-manifest", "write-timings"}
+	solidityOptions = []string{"sol", "public-inputs-sol", "verifier-interface-sol", "verifier-adapter-sol", "batch-verifier-sol", "hash-to-field", "foundry-test", "proof"}
+	onchainOptions  = concat([]string{"curve", "timeout", "vk", "proof", "rpc", "verifier", "chain-id"}, decryptOptions)
+	submitOptions   = []string{"private-key-file", "keystore", "keystore-password-file"}
+)
+
+var commands = []*command{
+	{name: "setup", legacy: "setup", usage: "solve the witness, run the groth16 setup and write the keys, ccs and solidity verifier",
+		options: concat(circuitOptions, keyOptions, []string{"write-ccs", "key-recipient"}, solidityOptions), run: fieldCommand("setup")},
+	{name: "prove", legacy: "prove", usage: "prove the witness with the keys and ccs written by setup",
+		options: concat(circuitOptions, keyOptions, []string{"read-ccs"}, proofOptions), run: fieldCommand("prove")},
+	{name: "setup-and-prove", legacy: "setupAndProve", usage: "setup, then prove the witness",
+		options: concat(circuitOptions, keyOptions, []string{"write-ccs", "key-recipient"}, solidityOptions, proofOptions), run: fieldCommand("setupAndProve")},
+	{name: "verify", legacy: "verify", usage: "verify the proof file with the vk, without the pk or the circuit",
+		options: concat([]string{"vk", "proof", "hash-to-field", "expect-prover"}, decryptOptions), run: func(context.Context, string) error { return sdk.VerifyProof() }},
+	{name: "check-manifest", legacy: "check-manifest", usage: "check the witness, keys and proof listed in the manifest of a proof still have the sha256 it recorded",
+		options: []string{"proof", "manifest"}, run: func(context.Context, string) error { return sdk.CheckManifest(os.Stdout) }},
+	{name: "solve", legacy: "solve", usage: "solve the witness without proving",
+		options: circuitOptions, run: fieldCommand("solve")},
+	{name: "check-witness", legacy: "check-witness", usage: "check the witness against the constraints outside the circuit, in seconds",
+		options: []string{"field", "witness", "constraints", "pico-dir"}, run: fieldCommand("check-witness")},
+	{name: "ccs-hash", legacy: "ccs-hash", usage: "compile the circuit and print the hash of its constraints, to compare with the setup",
+		options: concat(circuitOptions, []string{"ccs", "expect-ccs-hash"}), run: fieldCommand("ccs-hash")},
+	{name: "check-baseline", legacy: "checkBaseline", usage: "compile the circuit and fail if its public inputs changed or its constraints grew since the committed baseline",
+		options: concat(circuitOptions, []string{"baseline", "update-baseline", "max-constraint-growth"}), run: fieldCommand("checkBaseline")},
+	{name: "export-r1cs", legacy: "exportR1cs", usage: "write the constraint system in the .r1cs format of circom and snarkjs, and optionally the solved witness in .wtns, for external tooling",
+		options: concat(circuitOptions, []string{"ccs", "read-ccs", "r1cs", "wtns", "pk", "fast-keys", "hash-to-field"}, decryptOptions), run: fieldCommand("exportR1cs")},
+	{name: "export-solidity", legacy: "exportSolidity", usage: "write the solidity verifier of the vk",
+		options: concat([]string{"curve", "vk", "public-input-order", "public-input-digest-bits", "public-input-packing", "public-input-values", "public-input-digest-hash", "public-input-vkey"}, decryptOptions, solidityOptions), run: sdkCommand("export solidity", sdk.ExportSolidify)},
+	{name: "prove-evm", legacy: "proveEvm", usage: "prove the witness as the rust sdk's prove_evm, optionally after setup, and write the json proof, solidity verifier, inputs.json and calldata into -output-dir",
+		options: concat(circuitOptions, keyOptions, []string{"write-ccs", "key-recipient", "evm-setup", "output-dir"}, solidityOptions, proofOptions), run: fieldCommand("proveEvm"),
+		defaults: map[string]string{"output-dir": "./data/evm", "proof": "./output/proof.json", "proof-format": "json", "sol": "./output/Groth16Verifier.sol",
+			"public-inputs-sol": "./output/PicoPublicInputs.sol", "verifier-interface-sol": "./output/IPicoVerifier.sol", "verifier-adapter-sol": "./output/PicoVerifierAdapter.sol"}},
+	{name: "bench", legacy: "bench", usage: "measure solve, compile, setup or key loading and prove of the witness",
+		options: concat(circuitOptions, keyOptions, []string{"read-ccs", "bench-setup", "cgroup-memory", "hash-to-field"}), run: fieldCommand("bench")},
+	{name: "inspect", legacy: "inspect", usage: "print the headers of the keys and ccs and summaries of the witness and constraints as json, or with a file argument what that file is: its kind, curve, size, sha256, versions and public input count",
+		options: concat(keyOptions, []string{"witness", "constraints", "curve", "inspect-file"}), arg: "inspect-file", run: inspect},
+	{name: "preflight", legacy: "preflight", usage: "simulate the verifyProof call of the proof file on the deployed verifier",
+		options: concat(onchainOptions, []string{"hash-to-field"}), run: sdkCommand("preflight", func(ctx context.Context, _ string) error { return sdk.Preflight(ctx) })},
+	{name: "evm-check", legacy: "evmCheck", usage: "deploy the solidity verifier to a simulated go-ethereum chain and verify the proof file with it",
+		options: concat([]string{"curve", "timeout", "vk", "proof", "sol", "solc", "verifier-bytecode", "hash-to-field"}, decryptOptions), run: sdkCommand("check proof on simulated evm", evmCheck)},
+	{name: "submit", legacy: "submit", usage: "send the verifyProof transaction of the proof file and wait for it",
+		options: concat(onchainOptions, submitOptions, []string{"hash-to-field"}), run: sdkCommand("submit", func(ctx context.Context, _ string) error { return sdk.Submit(ctx) })},
+	{name: "registration", legacy: "registration", usage: "write the brevis gateway registration payload of the program",
+		options: concat([]string{"field", "curve", "witness", "pico-dir", "vk", "verifier", "chain-id", "registration"}, decryptOptions), run: sdkCommand("export registration", func(_ context.Context, field string) error { return sdk.ExportRegistration(field) })},
+	{name: "vk-hash", legacy: "vk-hash", usage: "print the keccak256 of the vk and of the vk file, and the bytes32 of -program-vkey, as the gateway contracts compare them",
+		options: concat([]string{"curve", "vk", "fast-keys", "program-vkey"}, decryptOptions), run: func(context.Context, string) error { return sdk.PrintVkHash(os.Stdout) }},
+	{name: "bundle", legacy: "bundle", usage: "solve the witness and write a prove bundle, for proving on another machine",
+		options: concat(circuitOptions, []string{"bundle"}), run: fieldCommand("bundle")},
+	{name: "prove-bundle", legacy: "proveBundle", usage: "prove a bundle written by bundle",
+		options: concat([]string{"field", "curve", "timeout", "bundle", "debug"}, keyOptions, proofOptions), run: sdkCommand("prove bundle", func(ctx context.Context, _ string) error { return sdk.ProveBundle(ctx) })},
+	{name: "prove-dir", legacy: "proveDir", usage: "prove every witness file under -input-dir into a mirrored tree under -output-dir, with a summary json",
+		options: concat(circuitOptions, keyOptions, []string{"proof-format", "rerandomize", "hash-to-field", "max-memory", "prover-key-file", "prover-key-scheme", "write-manifest", "write-timings", "input-dir", "output-dir", "witness-pattern"}), run: fieldCommand("proveDir")},
+	{name: "watch-dir", legacy: "watchDir", usage: "prove the witness files dropped under -input-dir into the mirrored tree under -output-dir, polling until interrupted",
+		options: concat(circuitOptions, keyOptions, []string{"proof-format", "rerandomize", "hash-to-field", "max-memory", "prover-key-file", "prover-key-scheme", "write-manifest", "write-timings", "input-dir", "output-dir", "witness-pattern", "watch-interval"}), run: fieldCommand("watchDir")},
+	{name: "witness-export", legacy: "witness-export", usage: "solve the witness and write the gnark binary witness",
+		options: concat(circuitOptions, []string{"witness-bin"}), run: fieldCommand("witness-export")},
+	{name: "prove-witness", legacy: "proveWitness", usage: "prove a gnark binary witness written by witness-export",
+		options: concat([]string{"field", "curve", "timeout", "witness-bin", "debug"}, keyOptions, proofOptions), run: sdkCommand("prove witness", func(ctx context.Context, _ string) error { return sdk.ProveWitness(ctx) })},
+	{name: "commitment-key", legacy: "commitmentKey", usage: "write the pedersen commitment key of the vk",
+		options: concat([]string{"field", "curve", "vk", "commitment-key", "commitment-key-sol"}, decryptOptions), run: sdkCommand("export commitment key", func(context.Context, string) error { return sdk.ExportCommitmentKey() })},
+}
+
+// sdkCommand runs a command that works the same for every field, once, with the
+// error of the field commands.
+func sdkCommand(failure string, run func(ctx context.Context, field string) error) func(ctx context.Context, field string) error {
+	return func(ctx context.Context, field string) error {
+		err := run(ctx, field)
+		if err != nil {
+			return fmt.Errorf("fail to %s: %w", failure, err)
+		}
+		return nil
+	}
+}
+
+// fieldCommand runs cmd, a command building the circuit, with the sdk of field.
+func fieldCommand(cmd string) func(ctx context.Context, field string) error {
+	return func(ctx context.Context, field string) error {
+		switch field {
+		case "bb":
+			err := sdk.BabyBearCmd(ctx, cmd)
+			if err != nil {
+				return fmt.Errorf("failed to babybear: %w", err)
+			}
+		case "kb":
+			err := sdk.KoalaBearCmd(ctx, cmd)
+			if err != nil {
+				return fmt.Errorf("failed to koalabear: %w", err)
+			}
+		default:
+			return fmt.Errorf("field %s not supported", field)
+		}
+		return nil
+	}
+}
+
+// inspect inspects the file of -inspect-file, or the files of the other options.
+func inspect(context.Context, string) error {
+	if os.Getenv("INSPECT_PATH") != "" {
+		return sdk.InspectFile(os.Stdout, os.Getenv("INSPECT_PATH"))
+	}
+	return sdk.Inspect(os.Stdout)
+}
+
+// findCommand returns the command named name, or with the legacy -cmd value name.
+func findCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name || c.legacy == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func concat(lists ...[]string) []string {
+	var res []string
+	for _, list := range lists {
+		res = append(res, list...)
+	}
+	return res
+}
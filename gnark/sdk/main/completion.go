@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/server"
+	"io"
+	"strings"
+)
+
+// commandFlags returns the names of the commands, with serve, and of their flags.
+func commandFlags() (names []string, flags map[string][]string) {
+	flags = make(map[string][]string)
+	for _, c := range commands {
+		names = append(names, c.name)
+		fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+		newOptionSet(fs, c.options)
+		fs.VisitAll(func(f *flag.Flag) {
+			flags[c.name] = append(flags[c.name], f.Name)
+		})
+	}
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	server.RegisterFlags(fs)
+	fs.VisitAll(func(f *flag.Flag) {
+		flags["serve"] = append(flags["serve"], f.Name)
+	})
+	names = append(names, "serve", "completion", "help")
+	return names, flags
+}
+
+// writeCompletion writes the completion script of shell, completing the commands and
+// the flags of each command.
+func writeCompletion(w io.Writer, shell string) error {
+	names, flags := commandFlags()
+	switch shell {
+	case "bash", "zsh":
+		if shell == "zsh" {
+			fmt.Fprintln(w, "autoload -U +X bashcompinit && bashcompinit")
+		}
+		fmt.Fprintf(w, "_pico_gnark() {\n")
+		fmt.Fprintf(w, "  local cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+		fmt.Fprintf(w, "  if [ \"$COMP_CWORD\" -eq 1 ]; then\n")
+		fmt.Fprintf(w, "    COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(names, " "))
+		fmt.Fprintf(w, "    return\n  fi\n")
+		fmt.Fprintf(w, "  case \"${COMP_WORDS[1]}\" in\n")
+		fmt.Fprintf(w, "    completion) COMPREPLY=($(compgen -W \"bash zsh fish\" -- \"$cur\")) ;;\n")
+		for _, c := range names {
+			if len(flags[c]) == 0 {
+				continue
+			}
+			fmt.Fprintf(w, "    %s) [[ \"$cur\" == -* ]] && COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", c, "-"+strings.Join(flags[c], " -"))
+		}
+		fmt.Fprintf(w, "  esac\n}\n")
+		fmt.Fprintf(w, "complete -o default -F _pico_gnark %s\n", name)
+	case "fish":
+		fmt.Fprintf(w, "complete -c %s -n __fish_use_subcommand -f -a %q\n", name, strings.Join(names, " "))
+		fmt.Fprintf(w, "complete -c %s -n '__fish_seen_subcommand_from completion' -f -a 'bash zsh fish'\n", name)
+		for _, c := range names {
+			for _, f := range flags[c] {
+				fmt.Fprintf(w, "complete -c %s -n '__fish_seen_subcommand_from %s' -o %s\n", name, c, f)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported shell %s, expected bash, zsh or fish", shell)
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build evmcheck
+
+package main
+
+import (
+	"context"
+	"github.com/brevis-network/pico/gnark/evmcheck"
+)
+
+// evmCheck runs evm-check, linked only in binaries built with the evmcheck tag.
+func evmCheck(ctx context.Context, _ string) error {
+	return evmcheck.Check(ctx)
+}
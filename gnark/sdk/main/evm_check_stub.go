@@ -0,0 +1,14 @@
+//go:build !evmcheck
+
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// evmCheck reports that the binary was built without the go-ethereum simulated
+// backend of evm-check, which the prover does not need.
+func evmCheck(context.Context, string) error {
+	return fmt.Errorf("evm-check is not in this binary, build it with go build -tags evmcheck ./sdk/main")
+}
@@ -1,93 +1,168 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
-	"github.com/brevis-network/pico/gnark/sdk"
+	"github.com/brevis-network/pico/gnark/server"
+	"github.com/brevis-network/pico/gnark/utils"
 )
 
-var (
-	cmd             = flag.String("cmd", "prove", "cmd to choose: prove(default)/setup/solve")
-	pkPath          = flag.String("pk", "./data/vm_pk", "path of proving key")
-	ccsPath         = flag.String("ccs", "./data/vm_ccs", "path of ccs")
-	vkPath          = flag.String("vk", "./data/vm_vk", "path of verifying key")
-	useGroth16      = flag.Bool("groth16", true, "use groth16")
-	witnessFile     = flag.String("witness", "./data/groth16_witness.json", "path of witness json file")
-	constraintsFile = flag.String("constraints", "./data/constraints.json", "path of constraint json file")
-	proofPath       = flag.String("proof", "./data/proof.data", "path of proof file")
-	solidifyPath    = flag.String("sol", "./data/Groth16Verifier.sol", "path of solidify file")
-	field           = flag.String("field", "kb", "field for proving, support bb and kb")
-)
+const name = "pico-gnark"
 
 func main() {
-	flag.Parse()
-	if *useGroth16 {
-		err := os.Setenv("GROTH16", "1")
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		err := runSubcommand(os.Args[1], os.Args[2:])
 		if err != nil {
-			fmt.Printf("failed to set env var: %v\n", err)
-			return
+			exit(err)
 		}
-	}
-	err := os.Setenv("PK_PATH", *pkPath)
-	if err != nil {
-		fmt.Printf("failed to set pk env var: %v\n", err)
 		return
 	}
+	legacyMain()
+}
 
-	err = os.Setenv("CCS_PATH", *ccsPath)
-	if err != nil {
-		fmt.Printf("failed to set ccs env var: %v\n", err)
-		return
+// legacyMain runs the flag-only invocation, `-cmd <command>` with every flag, which
+// scripts and the rust sdk call.
+func legacyMain() {
+	cmd := flag.String("cmd", "prove", "cmd to choose: prove(default)/setup/solve/proveEvm/bench/preflight/evmCheck/submit/registration/bundle/proveBundle/proveDir/watchDir/witness-export/proveWitness/check-witness/ccs-hash/checkBaseline/exportR1cs/verify/inspect/commitmentKey/vk-hash")
+	set := newOptionSet(flag.CommandLine, nil)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s <command> [flags], or %s -cmd <command> [flags]\n\n", name, name)
+		printCommands(flag.CommandLine.Output())
+		fmt.Fprintf(flag.CommandLine.Output(), "\nflags of -cmd:\n")
+		flag.PrintDefaults()
 	}
+	flag.Parse()
 
-	err = os.Setenv("VK_PATH", *vkPath)
+	c := findCommand(*cmd)
+	if c == nil {
+		exit(&usageError{fmt.Errorf("unknown command: %s", *cmd)})
+	}
+	set.setDefaults(c.defaults)
+	var argErr error
+	// arguments after the flags were ignored before commands took one
+	if c.arg != "" {
+		argErr = setArg(c, flag.CommandLine)
+	}
+	err := execute(c, set, argErr)
 	if err != nil {
-		fmt.Printf("failed to set vk env var: %v\n", err)
-		return
+		exit(err)
 	}
+}
 
-	err = os.Setenv("WITNESS_JSON", *witnessFile)
-	if err != nil {
-		fmt.Printf("failed to set witness env var: %v\n", err)
-		return
+// runSubcommand runs `pico-gnark <name> [args]`.
+func runSubcommand(name string, args []string) error {
+	switch name {
+	case "help":
+		printUsage(os.Stdout)
+		return nil
+	case "completion":
+		if len(args) != 1 {
+			return &usageError{fmt.Errorf("usage: %s completion bash|zsh|fish", os.Args[0])}
+		}
+		return writeCompletion(os.Stdout, args[0])
+	case "serve":
+		fs := flag.NewFlagSet("serve", flag.ExitOnError)
+		server.RegisterFlags(fs)
+		_ = fs.Parse(args)
+		server.Serve()
+		return nil
 	}
 
-	err = os.Setenv("CONSTRAINTS_JSON", *constraintsFile)
-	if err != nil {
-		fmt.Printf("failed to set constrains env var: %v\n", err)
-		return
+	c := findCommand(name)
+	if c == nil {
+		printUsage(os.Stderr)
+		return &usageError{fmt.Errorf("unknown command: %s", name)}
+	}
+	fs := flag.NewFlagSet(c.name, flag.ExitOnError)
+	set := newOptionSet(fs, c.options)
+	set.setDefaults(c.defaults)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s %s [flags]\n\n%s\n\nflags:\n", os.Args[0], c.name, c.usage)
+		fs.PrintDefaults()
 	}
+	_ = fs.Parse(args)
+	return execute(c, set, setArg(c, fs))
+}
 
-	err = os.Setenv("PROOF_PATH", *proofPath)
-	if err != nil {
-		fmt.Printf("failed to set proof path env var: %v\n", err)
-		return
+// setArg sets the option of the positional argument of c, the only argument fs may
+// have left after its flags.
+func setArg(c *command, fs *flag.FlagSet) error {
+	if fs.NArg() == 0 {
+		return nil
+	}
+	if fs.NArg() > 1 || c.arg == "" {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	return fs.Set(c.arg, fs.Arg(0))
+}
+
+// execute runs c, reporting its result as json with -output json. argErr is the error
+// of its positional argument, reported like those of its options.
+func execute(c *command, set *optionSet, argErr error) error {
+	if set.jsonOutput() {
+		return executeJSON(c, set, argErr)
 	}
+	return run(c, set, argErr)
+}
 
-	err = os.Setenv("SOLIDITY_PATH", *solidifyPath)
+// run sets the env vars of the options and runs c, until it returns, times out or is
+// interrupted.
+func run(c *command, set *optionSet, argErr error) error {
+	if argErr != nil {
+		return &usageError{argErr}
+	}
+	err := set.apply()
 	if err != nil {
-		fmt.Printf("failed to set solidify path env var: %v\n", err)
-		return
+		return &usageError{err}
 	}
 
-	switch *field {
-	case "bb":
-		err = sdk.BabyBearCmd(*cmd)
-		if err != nil {
-			fmt.Printf("failed to babybear: %v\n", err)
-			return
-		}
-	case "kb":
-		err = sdk.KoalaBearCmd(*cmd)
-		if err != nil {
-			fmt.Printf("failed to koalabear: %v\n", err)
-			return
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	timeout := set.duration("timeout")
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.run(ctx, set.get("field"))
+	}()
+
+	select {
+	case err = <-done:
+		return err
+	case <-ctx.Done():
+		// gnark cannot interrupt a compile or an MSM, so the command is abandoned: main
+		// exits, releasing its memory, and only the partial output files need to be
+		// cleaned up.
+		utils.RemovePartialFiles()
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("%s timed out after %v: %w", c.name, timeout, ctx.Err())
 		}
-	default:
-		fmt.Printf("field %s not supported\n", *field)
-		return
+		return fmt.Errorf("%s interrupted: %w", c.name, ctx.Err())
 	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "usage: %s <command> [flags]\n\n", name)
+	printCommands(w)
+	fmt.Fprintf(w, "\nrun %s <command> -h for the flags of a command\n", name)
+}
 
+func printCommands(w io.Writer) {
+	fmt.Fprintln(w, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-16s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintf(w, "  %-16s %s\n", "serve", "run the prover http server")
+	fmt.Fprintf(w, "  %-16s %s\n", "completion", "write the completion script of bash, zsh or fish")
 }
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/onchain"
+	"github.com/brevis-network/pico/gnark/sdk"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/brevis-network/pico/gnark/verify"
+	"github.com/consensys/gnark/test"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommands(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	names := map[string]bool{}
+	for _, c := range commands {
+		assert.False(names[c.name], c.name)
+		names[c.name] = true
+		if c.legacy != c.name {
+			assert.False(names[c.legacy], c.legacy)
+			names[c.legacy] = true
+		}
+		for _, name := range c.options {
+			found := false
+			for _, o := range options {
+				found = found || o.name == name
+			}
+			assert.True(found, "unknown option %s of %s", name, c.name)
+		}
+		if c.arg != "" {
+			assert.Contains(c.options, c.arg, "argument of %s", c.name)
+		}
+	}
+	assert.Equal("setup-and-prove", findCommand("setupAndProve").name)
+	assert.Nil(findCommand("nope"))
+}
+
+func TestSetArg(t *testing.T) {
+	assert := test.NewAssert(t)
+	unsetOptionEnv(t)
+
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	set := newOptionSet(fs, findCommand("inspect").options)
+	assert.NoError(fs.Parse([]string{"-curve", "bn254", "./data/vm_vk"}))
+	assert.NoError(setArg(findCommand("inspect"), fs))
+	assert.NoError(set.apply())
+	assert.Equal("./data/vm_vk", os.Getenv("INSPECT_PATH"))
+
+	assert.NoError(fs.Parse([]string{"a", "b"}))
+	assert.ErrorContains(setArg(findCommand("inspect"), fs), "unexpected arguments")
+	fs = flag.NewFlagSet("verify", flag.ContinueOnError)
+	newOptionSet(fs, findCommand("verify").options)
+	assert.NoError(fs.Parse([]string{"./data/proof.data"}))
+	assert.ErrorContains(setArg(findCommand("verify"), fs), "unexpected arguments")
+}
+
+// unsetOptionEnv unsets the env vars of the options for the test.
+func unsetOptionEnv(t *testing.T) {
+	for _, o := range options {
+		if o.env != "" {
+			t.Setenv(o.env, "")
+			os.Unsetenv(o.env)
+		}
+	}
+}
+
+func TestOptionSet(t *testing.T) {
+	assert := test.NewAssert(t)
+	unsetOptionEnv(t)
+
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	set := newOptionSet(fs, findCommand("verify").options)
+	assert.NoError(fs.Parse([]string{"-vk", "/keys/vk"}))
+	assert.Nil(fs.Lookup("pk"))
+	assert.NoError(set.apply())
+
+	// options the command does not take are set to their defaults
+	assert.Equal("/keys/vk", os.Getenv("VK_PATH"))
+	assert.Equal("./data/vm_pk", os.Getenv("PK_PATH"))
+	assert.Equal("1", os.Getenv("GROTH16"))
+	assert.Equal("1", os.Getenv("CCS_READ"))
+	_, ok := os.LookupEnv("POSEIDON2_CROSS_CHECK")
+	assert.False(ok)
+
+	unsetOptionEnv(t)
+	fs = flag.NewFlagSet("solve", flag.ContinueOnError)
+	set = newOptionSet(fs, findCommand("solve").options)
+	assert.NoError(fs.Parse([]string{"-range-check", "none"}))
+	assert.ErrorContains(set.apply(), "unknown range check mode: none")
+}
+
+func TestOptionPrecedence(t *testing.T) {
+	assert := test.NewAssert(t)
+	unsetOptionEnv(t)
+
+	config := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(os.WriteFile(config, []byte(`{"pk": "/config/pk", "vk": "/config/vk", "ccs": "/config/ccs", "groth16": false, "public-input-digest-bits": 248}`), 0644))
+	t.Setenv("PK_PATH", "/env/pk")
+	t.Setenv("CCS_READ", "0")
+
+	fs := flag.NewFlagSet("prove", flag.ContinueOnError)
+	set := newOptionSet(fs, findCommand("prove").options)
+	var warnings bytes.Buffer
+	set.warn = &warnings
+	assert.NoError(fs.Parse([]string{"-config", config, "-vk", "/flag/vk"}))
+	assert.NoError(set.apply())
+
+	// flags, then env vars, then the config file, then defaults
+	assert.Equal("/flag/vk", os.Getenv("VK_PATH"))
+	assert.Equal("/env/pk", os.Getenv("PK_PATH"))
+	assert.Equal("/config/ccs", os.Getenv("CCS_PATH"))
+	assert.Equal("0", os.Getenv("CCS_READ"))
+	assert.Equal("248", os.Getenv("PUBLIC_INPUT_DIGEST_BITS"))
+	assert.Equal("./data/groth16_witness.json", os.Getenv("WITNESS_JSON"))
+	_, ok := os.LookupEnv("GROTH16")
+	assert.False(ok)
+	assert.Contains(warnings.String(), `warning: env var PK_PATH is deprecated, use -pk or "pk" in the config file`)
+	assert.Contains(warnings.String(), "CCS_READ")
+	assert.NotContains(warnings.String(), "CCS_PATH")
+
+	unsetOptionEnv(t)
+	assert.NoError(os.WriteFile(config, []byte(`{"proving-key": "/config/pk"}`), 0644))
+	assert.ErrorContains(set.apply(), `unknown option "proving-key"`)
+	assert.NoError(os.WriteFile(config, []byte(`{"groth16": "yes"}`), 0644))
+	assert.ErrorContains(set.apply(), `invalid "groth16"`)
+	t.Setenv("PUBLIC_INPUT_DIGEST_BITS", "all")
+	assert.NoError(os.WriteFile(config, []byte(`{}`), 0644))
+	assert.ErrorContains(set.apply(), "invalid env var PUBLIC_INPUT_DIGEST_BITS")
+}
+
+func TestOptionLayout(t *testing.T) {
+	assert := test.NewAssert(t)
+	unsetOptionEnv(t)
+
+	fs := flag.NewFlagSet("prove", flag.ContinueOnError)
+	set := newOptionSet(fs, findCommand("prove").options)
+	assert.NoError(fs.Parse([]string{"-layout", "sp1", "-data-dir", "/sp1/build/", "-pk", "/keys/pk"}))
+	assert.NoError(set.apply())
+
+	// explicit paths win over the layout
+	assert.Equal("/keys/pk", os.Getenv("PK_PATH"))
+	assert.Equal("/sp1/build/groth16_vk.bin", os.Getenv("VK_PATH"))
+	assert.Equal("/sp1/build/groth16_circuit.bin", os.Getenv("CCS_PATH"))
+	assert.Equal("/sp1/build/groth16_witness.json", os.Getenv("WITNESS_JSON"))
+	assert.Equal("/sp1/build/constraints.json", os.Getenv("CONSTRAINTS_JSON"))
+	assert.Equal("/sp1/build/proof.data", os.Getenv("PROOF_PATH"))
+
+	unsetOptionEnv(t)
+	fs = flag.NewFlagSet("prove", flag.ContinueOnError)
+	set = newOptionSet(fs, findCommand("prove").options)
+	assert.NoError(fs.Parse([]string{"-data-dir", "/pico"}))
+	assert.NoError(set.apply())
+	assert.Equal("/pico/vm_pk", os.Getenv("PK_PATH"))
+
+	unsetOptionEnv(t)
+	fs = flag.NewFlagSet("prove", flag.ContinueOnError)
+	set = newOptionSet(fs, findCommand("prove").options)
+	assert.NoError(fs.Parse([]string{"-layout", "risc0"}))
+	assert.ErrorContains(set.apply(), "unknown artifact layout: risc0")
+}
+
+func TestOptionPicoDir(t *testing.T) {
+	assert := test.NewAssert(t)
+	unsetOptionEnv(t)
+
+	dir := t.TempDir()
+	assert.NoError(os.MkdirAll(filepath.Join(dir, "evm"), 0755))
+	assert.NoError(os.WriteFile(filepath.Join(dir, "evm", "groth16_witness.json"), []byte(`{"vars": ["5"], "felts": ["2000000"], "vkey_hash": "105317657", "committed_values_digest": "5"}`), 0644))
+	assert.NoError(os.WriteFile(filepath.Join(dir, "evm", "constraints.json"), []byte(`[
+		{"opcode": "WitnessF", "args": [["a"], ["0"]]},
+		{"opcode": "MulF", "args": [["a2"], ["a"], ["a"]]},
+		{"opcode": "MulF", "args": [["a3"], ["a2"], ["a"]]},
+		{"opcode": "CircuitFelt2Var", "args": [["vk"], ["a3"]]},
+		{"opcode": "CommitVkeyHash", "args": [["vk"]]},
+		{"opcode": "WitnessV", "args": [["digest"], ["0"]]},
+		{"opcode": "CommitCommitedValuesDigest", "args": [["digest"]]}
+	]`), 0644))
+
+	fs := flag.NewFlagSet("solve", flag.ContinueOnError)
+	set := newOptionSet(fs, findCommand("solve").options)
+	assert.NoError(fs.Parse([]string{"-pico-dir", dir}))
+	assert.NoError(set.apply())
+	assert.Equal(filepath.Join(dir, "evm", "groth16_witness.json"), os.Getenv("WITNESS_JSON"))
+	assert.Equal(filepath.Join(dir, "evm", "constraints.json"), os.Getenv("CONSTRAINTS_JSON"))
+	assert.Equal("bb", set.get("field"))
+
+	// explicit options win over the pico dir
+	unsetOptionEnv(t)
+	fs = flag.NewFlagSet("solve", flag.ContinueOnError)
+	set = newOptionSet(fs, findCommand("solve").options)
+	assert.NoError(fs.Parse([]string{"-pico-dir", dir, "-field", "kb", "-constraints", "/other/constraints.json"}))
+	assert.NoError(set.apply())
+	assert.Equal("kb", set.get("field"))
+	assert.Equal("/other/constraints.json", os.Getenv("CONSTRAINTS_JSON"))
+
+	fs = flag.NewFlagSet("solve", flag.ContinueOnError)
+	set = newOptionSet(fs, findCommand("solve").options)
+	assert.NoError(fs.Parse([]string{"-pico-dir", filepath.Join(dir, "missing")}))
+	assert.ErrorContains(set.apply(), "fail to scan pico dir")
+}
+
+func TestCompletion(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var out bytes.Buffer
+		assert.NoError(writeCompletion(&out, shell))
+		assert.Contains(out.String(), "check-witness")
+		assert.Contains(out.String(), "httpport")
+	}
+	var out bytes.Buffer
+	assert.NoError(writeCompletion(&out, "bash"))
+	assert.Contains(out.String(), `verify) [[ "$cur" == -* ]] && COMPREPLY=($(compgen -W "-config -data-dir -expect-prover -hash-to-field -key-identity -key-passphrase-file -layout -output -proof -vk" -- "$cur")) ;;`)
+	assert.Error(writeCompletion(&out, "tcsh"))
+}
+
+func TestOptionCommandDefaults(t *testing.T) {
+	assert := test.NewAssert(t)
+	unsetOptionEnv(t)
+
+	c := findCommand("prove-evm")
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	set := newOptionSet(fs, c.options)
+	set.setDefaults(c.defaults)
+	assert.Equal("./output/proof.json", fs.Lookup("proof").DefValue)
+	assert.NoError(fs.Parse([]string{"-data-dir", "/pico", "-sol", "/contracts/Verifier.sol"}))
+	assert.NoError(set.apply())
+	assert.Equal("/pico/evm", os.Getenv("OUTPUT_DIR"))
+	assert.Equal("/pico/evm/proof.json", os.Getenv("PROOF_PATH"))
+	assert.Equal("json", os.Getenv("PROOF_FORMAT"))
+	assert.Equal("/contracts/Verifier.sol", os.Getenv("SOLIDITY_PATH"))
+	assert.Equal("/pico/evm/IPicoVerifier.sol", os.Getenv("PICO_INTERFACE_SOL_PATH"))
+	assert.Equal("/pico/vm_pk", os.Getenv("PK_PATH"))
+
+	unsetOptionEnv(t)
+	fs = flag.NewFlagSet(c.name, flag.ContinueOnError)
+	set = newOptionSet(fs, c.options)
+	set.setDefaults(c.defaults)
+	assert.NoError(fs.Parse([]string{"-output-dir", "/out/"}))
+	assert.NoError(set.apply())
+	assert.Equal("/out/proof.json", os.Getenv("PROOF_PATH"))
+	assert.Equal("/out/Groth16Verifier.sol", os.Getenv("SOLIDITY_PATH"))
+
+	// other commands keep the option defaults
+	unsetOptionEnv(t)
+	fs = flag.NewFlagSet("prove", flag.ContinueOnError)
+	set = newOptionSet(fs, findCommand("prove").options)
+	assert.NoError(fs.Parse(nil))
+	assert.NoError(set.apply())
+	assert.Equal("./data/proof.data", os.Getenv("PROOF_PATH"))
+}
+
+func TestClassify(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	for _, tc := range []struct {
+		err   error
+		class string
+		code  int
+	}{
+		{errors.New("boom"), "failure", exitFailure},
+		{&usageError{errors.New("unexpected arguments")}, "usage", exitUsage},
+		{&usageError{fmt.Errorf("fail to read config: %w", fs.ErrNotExist)}, "input", exitInput},
+		{fmt.Errorf("fail to solve: %w", fmt.Errorf("%w: %w", sdk.ErrUnsatisfied, errors.New("assertion failed"))), "unsatisfied", exitUnsatisfied},
+		{fmt.Errorf("fail to verify: %w", verify.ErrInvalidProof), "rejected", exitRejected},
+		{fmt.Errorf("call failed: %w", &onchain.RevertError{}), "rejected", exitRejected},
+		{sdk.ErrInsufficientMemory, "resources", exitResources},
+		{fmt.Errorf("prove timed out after 1s: %w", context.DeadlineExceeded), "timeout", exitTimeout},
+		{&reportedError{context.Canceled}, "interrupted", exitInterrupted},
+	} {
+		class, code := classify(tc.err)
+		assert.Equal(tc.class, class, tc.err.Error())
+		assert.Equal(tc.code, code, tc.err.Error())
+	}
+}
+
+func TestExecuteJSON(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	execJSON := func(c *command, args ...string) (commandResult, error) {
+		unsetOptionEnv(t)
+		fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+		set := newOptionSet(fs, c.options)
+		assert.NoError(fs.Parse(append([]string{"-output", "json"}, args...)))
+		r, w, err := os.Pipe()
+		assert.NoError(err)
+		stdout := os.Stdout
+		os.Stdout = w
+		err = execute(c, set, setArg(c, fs))
+		os.Stdout = stdout
+		w.Close()
+		var res commandResult
+		assert.NoError(json.NewDecoder(r).Decode(&res))
+		return res, err
+	}
+
+	path := filepath.Join(t.TempDir(), "out.txt")
+	c := &command{name: "write", run: func(context.Context, string) error {
+		fmt.Println("writing")
+		return utils.WriteBytesAtomic(path, []byte("ok"))
+	}}
+	res, err := execJSON(c)
+	assert.NoError(err)
+	assert.True(res.OK)
+	assert.Equal(0, res.ExitCode)
+	assert.Equal([]string{"writing"}, res.Output)
+	assert.Equal([]string{path}, res.Files)
+
+	c = &command{name: "inspect", run: func(context.Context, string) error {
+		fmt.Println(`{"kind": "vk"}`)
+		return nil
+	}}
+	res, err = execJSON(c)
+	assert.NoError(err)
+	assert.JSONEq(`{"kind": "vk"}`, string(res.Result))
+	assert.Empty(res.Output)
+
+	c = &command{name: "check", run: func(context.Context, string) error {
+		return fmt.Errorf("%w: witness check failed", sdk.ErrUnsatisfied)
+	}}
+	res, err = execJSON(c)
+	var reported *reportedError
+	assert.True(errors.As(err, &reported), "the error is not printed again")
+	assert.False(res.OK)
+	assert.Equal(exitUnsatisfied, res.ExitCode)
+	assert.Equal("unsatisfied", res.Error.Class)
+	assert.Contains(res.Error.Message, "witness check failed")
+
+	res, err = execJSON(c, "unexpected")
+	assert.Error(err)
+	assert.Equal("usage", res.Error.Class)
+	assert.Equal(exitUsage, res.ExitCode)
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/sdk"
+	"github.com/brevis-network/pico/gnark/utils"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type optionKind int
+
+const (
+	stringOption optionKind = iota
+	boolOption
+	intOption
+	durationOption
+)
+
+// option is a flag of the cli and the env var passing it to the sdk.
+type option struct {
+	name  string
+	kind  optionKind
+	value string
+	usage string
+	// env is set to the value of the option, or to its default when the command does
+	// not take it; options without env are read by main.
+	env string
+	// sparse bool options only set env when true, as circuit options recorded in ccs
+	// headers written before the subcommands.
+	sparse bool
+	check  func(value string) error
+}
+
+var options = []*option{
+	{name: "field", value: "kb", usage: "field for proving, support bb and kb", check: oneOf("field", "kb", "bb")},
+	{name: "curve", value: "bn254", usage: "curve of the groth16 wrapper, only bn254 is supported", env: "CURVE"},
+	{name: "data-dir", value: "./data", usage: "directory of the artifacts whose path is not set"},
+	{name: "layout", value: "pico", usage: "file names of the artifacts in -data-dir: pico/sp1 (groth16_pk.bin, groth16_vk.bin and groth16_circuit.bin of sp1's gnark wrapper)", check: oneOf("artifact layout", "pico", "sp1")},
+	{name: "output", value: "text", usage: "what the command prints on stdout: text/json (one json object with its outcome, error class, exit code, the files it wrote and its text output, the gnark logs going to stderr)", check: oneOf("output format", "text", "json")},
+	{name: "timeout", kind: durationOption, value: "0s", usage: "abort the command after this duration, e.g. 30m (0 for no timeout)"},
+	{name: "pk", value: "./data/vm_pk", usage: "path of proving key", env: "PK_PATH"},
+	{name: "ccs", value: "./data/vm_ccs", usage: "path of ccs", env: "CCS_PATH"},
+	{name: "expect-ccs-hash", usage: "hash of the ccs compiled by setup, ccs-hash fails if the circuit compiles to another", env: "EXPECTED_CCS_HASH"},
+	{name: "baseline", value: "./data/ccs_baseline.json", usage: "path of the circuit baseline json compared by check-baseline, to commit next to the verifier contract", env: "BASELINE_PATH"},
+	{name: "update-baseline", kind: boolOption, value: "false", usage: "write the baseline of the current circuit instead of comparing with it", env: "BASELINE_UPDATE"},
+	{name: "max-constraint-growth", value: "0", usage: "percentage of constraints check-baseline allows the circuit to grow by", env: "MAX_CONSTRAINT_GROWTH"},
+	{name: "debug", kind: boolOption, value: "false", usage: "report the constraint and the witness variables of an unsatisfied constraint when solving or proving; a binary built with -tags debug also compiles the ccs with the gadget of each constraint", env: "SOLVE_DEBUG"},
+	{name: "r1cs", value: "./data/circuit.r1cs", usage: "path of the .r1cs file written by export-r1cs", env: "R1CS_PATH"},
+	{name: "wtns", usage: "path of the .wtns file of the solved witness also written by export-r1cs, with the commitments of -pk if the circuit has some (none if empty)", env: "WTNS_PATH"},
+	{name: "read-ccs", kind: boolOption, value: "true", usage: "prove with the ccs written by setup instead of compiling the circuit, if it matches the constraints", env: "CCS_READ"},
+	{name: "write-ccs", kind: boolOption, value: "true", usage: "write the compiled ccs during setup", env: "CCS_WRITE"},
+	{name: "vk", value: "./data/vm_vk", usage: "path of verifying key", env: "VK_PATH"},
+	{name: "key-passphrase-file", usage: "file holding the passphrase the keys are encrypted with at setup and decrypted with when read (plaintext keys if empty)", env: "KEY_PASSPHRASE_FILE"},
+	{name: "key-recipient", usage: "comma separated age public keys (age1...) setup encrypts the keys to, instead of a passphrase", env: "KEY_RECIPIENT"},
+	{name: "key-identity", usage: "age identity file, as written by age-keygen, decrypting keys encrypted to its public key", env: "KEY_IDENTITY_FILE"},
+	{name: "fast-keys", kind: boolOption, value: "false", usage: "write the keys uncompressed and read them without checking their points, several times faster; only for keys from a trusted setup", env: "FAST_KEYS"},
+	{name: "groth16", kind: boolOption, value: "true", usage: "use groth16", env: "GROTH16", sparse: true},
+	{name: "pico-dir", usage: "EVM output directory of the rust prover, -witness, -constraints and -field default to the groth16_witness.json and constraints.json found in it and the field of their proof"},
+	{name: "witness", value: "./data/groth16_witness.json", usage: "path of witness json file", env: "WITNESS_JSON"},
+	{name: "constraints", value: "./data/constraints.json", usage: "path of constraint json file", env: "CONSTRAINTS_JSON"},
+	{name: "proof", value: "./data/proof.data", usage: "path of proof file", env: "PROOF_PATH"},
+	{name: "proof-format", value: "legacy", usage: "format of proof file: legacy(comma separated hex, read by the rust sdk)/json/gateway (abi encoded for the brevis gateway, not read back by verify, preflight or submit)", env: "PROOF_FORMAT"},
+	{name: "rerandomize", kind: boolOption, value: "false", usage: "re-randomize the proof before writing it, so proofs of the same witness can not be linked", env: "PROOF_RERANDOMIZE"},
+	{name: "compress", value: "none", usage: "also write a compressed copy of the proof file, next to it: none/gzip (.gz)", env: "PROOF_COMPRESSION", check: utils.CheckCompression},
+	{name: "write-manifest", kind: boolOption, value: "true", usage: "write the manifest of the proof next to it, the sha256 of the witness, vk, ccs and proof and the tool versions", env: "PROOF_MANIFEST"},
+	{name: "write-timings", kind: boolOption, value: "true", usage: "write timings.json in the directory of the proof, the durations of solving, compiling, loading keys, proving and verifying and the constraint counts", env: "PROOF_TIMINGS"},
+	{name: "inspect-file", usage: "artifact file inspect identifies, a pk, vk, ccs, bundle, witness, proof or constraints file, also taken as the argument of inspect (the files of the other options if empty)", env: "INSPECT_PATH"},
+	{name: "manifest", usage: "path of the manifest check-manifest checks (the one next to -proof if empty)", env: "MANIFEST_PATH"},
+	{name: "prover-key-file", usage: "identity key file the proofs are signed with, in the json proof or a .sig file next to it, so their consumers can tell which prover produced them (unsigned if empty)", env: "PROVER_KEY_FILE"},
+	{name: "prover-key-scheme", value: "ed25519", usage: "scheme of -prover-key-file: ed25519 (pkcs8 pem or hex seed)/secp256k1 (hex private key, signer is its ethereum address)", env: "PROVER_KEY_SCHEME", check: oneOf("prover key scheme", "ed25519", "secp256k1")},
+	{name: "expect-prover", usage: "comma separated signers, ed25519 public keys or ethereum addresses, one of which must have signed the proof (any or none if empty)", env: "EXPECTED_PROVER"},
+	{name: "input-dir", value: "./data/witnesses", usage: "directory scanned by prove-dir and watch-dir for witness files", env: "INPUT_DIR"},
+	{name: "output-dir", value: "./data/proofs", usage: "directory of the proofs of prove-dir, mirroring -input-dir, and of its summary.json; of the files of prove-evm", env: "OUTPUT_DIR"},
+	{name: "evm-setup", kind: boolOption, value: "false", usage: "run the groth16 setup in prove-evm before proving, writing the keys, as --setup of the rust cli (the keys of -pk, -vk and -ccs are used otherwise)", env: "EVM_SETUP"},
+	{name: "witness-pattern", value: "groth16_witness.json", usage: "name pattern of the witness files of prove-dir, e.g. *.json; one per directory", env: "WITNESS_PATTERN"},
+	{name: "watch-interval", kind: durationOption, value: "2s", usage: "interval at which watch-dir polls -input-dir for new witness files", env: "WATCH_INTERVAL"},
+	{name: "bundle", value: "./data/prove_bundle.bin", usage: "path of the prove bundle written by bundle and proved by prove-bundle", env: "BUNDLE_PATH"},
+	{name: "witness-bin", value: "./data/witness.bin", usage: "path of the gnark binary witness written by witness-export and proved by prove-witness", env: "WITNESS_BIN_PATH"},
+	{name: "program-vkey", usage: "program vkey hash of a pico program printed as bytes32 by vk-hash, decimal as in the witness or 0x prefixed hex", env: "PROGRAM_VKEY"},
+	{name: "registration", value: "./data/registration.json", usage: "path of the brevis gateway registration payload written by registration", env: "REGISTRATION_PATH"},
+	{name: "commitment-key", value: "./data/commitment_key.json", usage: "path of the pedersen commitment key json written by commitment-key", env: "COMMITMENT_KEY_PATH"},
+	{name: "commitment-key-sol", value: "./data/PicoCommitmentKey.sol", usage: "path of the pedersen commitment key solidity constants written by commitment-key", env: "COMMITMENT_KEY_SOL_PATH"},
+	{name: "sol", value: "./data/Groth16Verifier.sol", usage: "path of solidify file", env: "SOLIDITY_PATH"},
+	{name: "batch-verifier-sol", usage: "path of the solidity contract verifying many proofs in one call, written with the verifier for keys without commitment (none if empty)", env: "BATCH_VERIFIER_SOL_PATH"},
+	{name: "foundry-test", usage: "path of the foundry test of the solidity verifier written with it, from the proof at -proof if it verifies with -vk (none if empty)", env: "FOUNDRY_TEST_PATH"},
+	{name: "solc", value: "solc", usage: "solc binary compiling the solidity verifier for evm-check", env: "SOLC_PATH"},
+	{name: "verifier-bytecode", usage: "file of the hex creation bytecode of the verifier contract deployed by evm-check, instead of compiling -sol", env: "VERIFIER_BYTECODE_PATH"},
+	{name: "hash-to-field", value: "keccak256", usage: "hash of the proof commitment to the field, prover and verifier must agree: keccak256/sha256/poseidon2 (no solidity verifier)", env: "HASH_TO_FIELD"},
+	{name: "public-input-order", value: "vkey-first", usage: "order of the vkey hash and committed values digest in the public inputs: vkey-first/digest-first", env: "PUBLIC_INPUT_ORDER"},
+	{name: "compile-capacity", kind: intOption, value: "0", usage: "constraints to allocate before compiling, e.g. the count printed by setup, to compile faster (0 grows as needed)", env: "COMPILE_CAPACITY"},
+	{name: "compile-compress-threshold", kind: intOption, value: "0", usage: "length above which linear expressions are compressed while compiling (0 for gnark's 300); changes the circuit, so setup and prove must agree", env: "COMPILE_COMPRESS_THRESHOLD"},
+	{name: "public-input-digest-bits", kind: intOption, value: "0", usage: "truncate the committed values digest public input to its low bits (0 keeps the field element)", env: "PUBLIC_INPUT_DIGEST_BITS"},
+	{name: "public-input-packing", value: "field", usage: "packing of the public inputs: field (one input per value)/limbs (two 128 bit limbs per value, high first)", env: "PUBLIC_INPUT_PACKING"},
+	{name: "public-input-values", usage: "expose the public values, exactly N 32 byte words given as public_values in the witness, after the vkey hash and digest: words=N (two 128 bit limbs per word)/root=N (two limbs of the root of their sha256 merkle tree); none if empty", env: "PUBLIC_INPUT_VALUES"},
+	{name: "public-input-digest-hash", value: "sha256", usage: "hash of the public values the digest public input is, recomputed in the circuit from those of -public-input-values: sha256 (the committed values digest)/keccak256 (checked against the committed values digest in the circuit, hashed by keccak256 in solidity)", env: "PUBLIC_INPUT_DIGEST_HASH", check: oneOf("public input digest hash", "sha256", "keccak256")},
+	{name: "public-input-vkey", usage: "vkey hash of the only program the circuit verifies, a constant of the circuit instead of a public input, so that a proof of another program is rejected by the circuit and the verifier contract; any program if empty", env: "PUBLIC_INPUT_VKEY"},
+	{name: "public-inputs-sol", value: "./data/PicoPublicInputs.sol", usage: "path of the solidity library packing the public inputs, written with the verifier", env: "PUBLIC_INPUTS_SOL_PATH"},
+	{name: "verifier-interface-sol", value: "./data/IPicoVerifier.sol", usage: "path of the IPicoVerifier solidity interface, the stable abi of pico verifiers, written with the verifier", env: "PICO_INTERFACE_SOL_PATH"},
+	{name: "verifier-adapter-sol", value: "./data/PicoVerifierAdapter.sol", usage: "path of the solidity adapter implementing IPicoVerifier with the verifier, written with it", env: "PICO_ADAPTER_SOL_PATH"},
+	{name: "range-check", value: "bits", usage: "range checks of the groth16 circuit: bits/lookup (fewer constraints, adds a commitment to the proof)/decompose (lookup, also splitting the packed public inputs with lookups)", env: "RANGE_CHECK", check: oneOf("range check mode", "bits", "lookup", "decompose")},
+	{name: "poseidon2-cross-check", kind: boolOption, value: "false", usage: "check every koalabear and babybear poseidon2 permutation against the native go implementation, for solve only as it changes the circuit", env: "POSEIDON2_CROSS_CHECK", sparse: true},
+	{name: "poseidon2-constant-folding", kind: boolOption, value: "false", usage: "compute the koalabear and babybear poseidon2 permutations of constant states while compiling instead of constraining them (fewer constraints, needs its own setup)", env: "POSEIDON2_CONSTANT_FOLDING", sparse: true},
+	{name: "bench-setup", kind: boolOption, value: "false", usage: "run and measure setup in bench instead of loading the keys", env: "BENCH_SETUP"},
+	{name: "max-memory", kind: intOption, value: "0", usage: "memory in MB the keys and a proof may use, proving fails before loading keys that do not fit with a proof (0 for no limit)", env: "MAX_MEMORY"},
+	{name: "cgroup-memory", kind: boolOption, value: "false", usage: "also report the peak memory usage of the process's cgroup after prove", env: "CGROUP_MEMORY"},
+	{name: "rpc", usage: "ethereum json-rpc url for preflight and submit", env: "RPC_URL"},
+	{name: "verifier", usage: "address of the deployed verifier contract for preflight and submit", env: "VERIFIER_ADDRESS"},
+	{name: "private-key-file", usage: "file holding the hex private key signing submit (PRIVATE_KEY is used otherwise)", env: "PRIVATE_KEY_FILE"},
+	{name: "keystore", usage: "keystore v3 file of the account signing submit", env: "KEYSTORE_PATH"},
+	{name: "keystore-password-file", usage: "file holding the password of -keystore", env: "KEYSTORE_PASSWORD_FILE"},
+	{name: "chain-id", usage: "expected chain id of -rpc, submit fails on another chain", env: "CHAIN_ID"},
+}
+
+// globalOptions are defined for every command.
+var globalOptions = []string{"data-dir", "layout", "output"}
+
+// sp1Artifacts are the file names of the artifacts of sp1's gnark wrapper, by option
+// name. Its witness, constraints and solidity verifier have the pico names.
+var sp1Artifacts = map[string]string{
+	"pk":  "groth16_pk.bin",
+	"vk":  "groth16_vk.bin",
+	"ccs": "groth16_circuit.bin",
+}
+
+// optionSet resolves the options of a command: from its flags, then from the env
+// vars of the options, deprecated, then from the config file, then their defaults.
+type optionSet struct {
+	fs     *flag.FlagSet
+	values map[string]string
+	// defaults replace the defaults of options, see command.defaults.
+	defaults map[string]string
+	// warn receives the deprecation warnings of the env vars.
+	warn io.Writer
+}
+
+// newOptionSet defines the named options and -config on fs, all options if names is
+// nil.
+func newOptionSet(fs *flag.FlagSet, names []string) *optionSet {
+	for _, o := range options {
+		if names != nil && !contains(names, o.name) && !contains(globalOptions, o.name) {
+			continue
+		}
+		switch o.kind {
+		case boolOption:
+			fs.Bool(o.name, o.value == "true", o.usage)
+		case intOption:
+			value, _ := strconv.Atoi(o.value)
+			fs.Int(o.name, value, o.usage)
+		case durationOption:
+			value, _ := time.ParseDuration(o.value)
+			fs.Duration(o.name, value, o.usage)
+		default:
+			fs.String(o.name, o.value, o.usage)
+		}
+	}
+	fs.String("config", "", "path of a json config file of option values by flag name, e.g. {\"pk\": \"/keys/vm_pk\", \"groth16\": true}; flags and env vars take precedence")
+	return &optionSet{fs: fs, warn: os.Stderr}
+}
+
+// setDefaults replaces the defaults of options with those of a command, also in the
+// help of its flags.
+func (s *optionSet) setDefaults(defaults map[string]string) {
+	s.defaults = defaults
+	for name, value := range defaults {
+		if f := s.fs.Lookup(name); f != nil {
+			f.DefValue = value
+		}
+	}
+}
+
+// defaultValue returns the default of o for the command.
+func (s *optionSet) defaultValue(o *option) string {
+	if value, ok := s.defaults[o.name]; ok {
+		return value
+	}
+	return o.value
+}
+
+// jsonOutput reports whether the command prints its result as json, also when its
+// options did not resolve.
+func (s *optionSet) jsonOutput() bool {
+	if value, ok := s.values["output"]; ok {
+		return value == "json"
+	}
+	f := s.fs.Lookup("output")
+	return f != nil && f.Value.String() == "json"
+}
+
+// get returns the value of an option, once resolved.
+func (s *optionSet) get(name string) string {
+	return s.values[name]
+}
+
+func (s *optionSet) duration(name string) time.Duration {
+	d, _ := time.ParseDuration(s.get(name))
+	return d
+}
+
+// resolve sets the value of every option. Env vars of options are the configuration
+// of the flag-only cli, they are used with a warning.
+func (s *optionSet) resolve() error {
+	set := make(map[string]bool)
+	s.fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	config, err := readConfig(s.fs.Lookup("config").Value.String())
+	if err != nil {
+		return err
+	}
+
+	s.values = make(map[string]string)
+	explicit := make(map[string]bool)
+	for _, o := range options {
+		value, fromConfig := config[o.name]
+		if !fromConfig {
+			value = s.defaultValue(o)
+		}
+		explicit[o.name] = fromConfig || set[o.name]
+		if env, ok := os.LookupEnv(o.env); ok && o.env != "" {
+			value, err = envValue(o, env)
+			if err != nil {
+				return err
+			}
+			explicit[o.name] = true
+			fmt.Fprintf(s.warn, "warning: env var %s is deprecated, use -%s or %q in the config file\n", o.env, o.name, o.name)
+		}
+		if set[o.name] {
+			value = s.fs.Lookup(o.name).Value.String()
+		}
+		s.values[o.name] = value
+	}
+
+	// the artifacts without an explicit path are in the data dir, named by the layout
+	dir := strings.TrimSuffix(s.get("data-dir"), "/")
+	if dir == "" {
+		dir = "."
+	}
+	for _, o := range options {
+		if explicit[o.name] || !strings.HasPrefix(s.defaultValue(o), "./data/") {
+			continue
+		}
+		file := strings.TrimPrefix(s.defaultValue(o), "./data/")
+		if s.get("layout") == "sp1" && sp1Artifacts[o.name] != "" {
+			file = sp1Artifacts[o.name]
+		}
+		s.values[o.name] = dir + "/" + file
+	}
+	// then the files a command writes in its output dir
+	for _, o := range options {
+		if !explicit[o.name] && strings.HasPrefix(s.defaultValue(o), "./output/") {
+			s.values[o.name] = strings.TrimSuffix(s.get("output-dir"), "/") + "/" + strings.TrimPrefix(s.defaultValue(o), "./output/")
+		}
+	}
+	if s.get("pico-dir") != "" {
+		return s.resolvePicoDir(explicit)
+	}
+	return nil
+}
+
+// resolvePicoDir sets the witness, constraints and field not set explicitly to those
+// of the proof in -pico-dir.
+func (s *optionSet) resolvePicoDir(explicit map[string]bool) error {
+	dir, err := sdk.LocatePicoDir(s.get("pico-dir"))
+	if err != nil {
+		return err
+	}
+	if !explicit["witness"] {
+		s.values["witness"] = dir.Witness
+	}
+	if !explicit["constraints"] {
+		s.values["constraints"] = dir.Constraints
+	}
+	if !explicit["field"] {
+		field, err := sdk.DetectField(s.get("witness"), s.get("constraints"))
+		if err != nil {
+			return fmt.Errorf("fail to detect the field of %s: %w", s.get("witness"), err)
+		}
+		s.values["field"] = field
+	}
+	return nil
+}
+
+// apply resolves and checks the options and sets their env vars for the sdk.
+func (s *optionSet) apply() error {
+	err := s.resolve()
+	if err != nil {
+		return err
+	}
+	for _, o := range options {
+		value := s.get(o.name)
+		if o.check != nil {
+			err := o.check(value)
+			if err != nil {
+				return err
+			}
+		}
+		if o.env == "" {
+			continue
+		}
+		if o.kind == boolOption {
+			if value == "false" && o.sparse {
+				err = os.Unsetenv(o.env)
+				if err != nil {
+					return fmt.Errorf("failed to unset %s env var: %w", o.env, err)
+				}
+				continue
+			}
+			value = boolEnv(value == "true")
+		}
+		err := os.Setenv(o.env, value)
+		if err != nil {
+			return fmt.Errorf("failed to set %s env var: %w", o.env, err)
+		}
+	}
+	return nil
+}
+
+// readConfig reads a json config file of option values by flag name, none if path is
+// empty.
+func readConfig(path string) (map[string]string, error) {
+	config := make(map[string]string)
+	if path == "" {
+		return config, nil
+	}
+	data, err := utils.ReadArtifact(path)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read config file: %w", err)
+	}
+	var values map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err = decoder.Decode(&values)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse config file %s: %w", path, err)
+	}
+	for name, v := range values {
+		o := findOption(name)
+		if o == nil {
+			return nil, fmt.Errorf("unknown option %q in config file %s", name, path)
+		}
+		value := fmt.Sprint(v)
+		err = validateValue(o, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %q in config file %s: %w", name, path, err)
+		}
+		config[name] = value
+	}
+	return config, nil
+}
+
+// envValue converts the env var value of o, "1" and "0" for bool options, to the text
+// of its flag.
+func envValue(o *option, env string) (string, error) {
+	if o.kind == boolOption {
+		switch env {
+		case "1":
+			return "true", nil
+		case "0", "":
+			return "false", nil
+		}
+	}
+	err := validateValue(o, env)
+	if err != nil {
+		return "", fmt.Errorf("invalid env var %s: %w", o.env, err)
+	}
+	return env, nil
+}
+
+func validateValue(o *option, value string) error {
+	var err error
+	switch o.kind {
+	case boolOption:
+		_, err = strconv.ParseBool(value)
+	case intOption:
+		_, err = strconv.Atoi(value)
+	case durationOption:
+		_, err = time.ParseDuration(value)
+	}
+	return err
+}
+
+func findOption(name string) *option {
+	for _, o := range options {
+		if o.name == name {
+			return o
+		}
+	}
+	return nil
+}
+
+func oneOf(name string, values ...string) func(string) error {
+	return func(value string) error {
+		for _, v := range values {
+			if value == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown %s: %s", name, value)
+	}
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func boolEnv(v bool) string {
+	if v {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/onchain"
+	"github.com/brevis-network/pico/gnark/sdk"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/brevis-network/pico/gnark/verify"
+	"github.com/consensys/gnark/logger"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+)
+
+// Exit codes of the cli by class of failure, so that scripts tell a witness the
+// circuit rejects from a missing file or a timeout without parsing the error.
+const (
+	exitFailure     = 1
+	exitUsage       = 2
+	exitInput       = 3
+	exitUnsatisfied = 4
+	exitRejected    = 5
+	exitResources   = 6
+	exitTimeout     = 7
+	// exitInterrupted is the status of shells for a process killed by SIGINT.
+	exitInterrupted = 130
+)
+
+// usageError is an invalid invocation: unexpected arguments or option values.
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string { return e.err.Error() }
+func (e *usageError) Unwrap() error { return e.err }
+
+// reportedError is an error already printed in the json result of the command, main
+// only exits with the code of its class.
+type reportedError struct {
+	err error
+}
+
+func (e *reportedError) Error() string { return e.err.Error() }
+func (e *reportedError) Unwrap() error { return e.err }
+
+// classify returns the class of err and the exit code of the class. The causes are
+// checked before usageError, so a missing file given as an option is an input error.
+func classify(err error) (string, int) {
+	var revert *onchain.RevertError
+	var usage *usageError
+	switch {
+	case err == nil:
+		return "", 0
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout", exitTimeout
+	case errors.Is(err, context.Canceled):
+		return "interrupted", exitInterrupted
+	case errors.Is(err, sdk.ErrUnsatisfied):
+		return "unsatisfied", exitUnsatisfied
+	case errors.Is(err, verify.ErrInvalidProof), errors.Is(err, verify.ErrInvalidSignature),
+		errors.Is(err, sdk.ErrManifestMismatch), errors.Is(err, sdk.ErrCircuitChanged), errors.As(err, &revert):
+		return "rejected", exitRejected
+	case errors.Is(err, sdk.ErrInsufficientMemory):
+		return "resources", exitResources
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, sdk.ErrInvalidWitness), errors.Is(err, sdk.ErrUnknownProgram):
+		return "input", exitInput
+	case errors.As(err, &usage):
+		return "usage", exitUsage
+	default:
+		return "failure", exitFailure
+	}
+}
+
+// exit prints err, unless its json result did, and exits with the code of its class.
+func exit(err error) {
+	var reported *reportedError
+	if !errors.As(err, &reported) {
+		fmt.Println(err)
+	}
+	_, code := classify(err)
+	os.Exit(code)
+}
+
+// commandResult is what -output json prints on stdout instead of the output of the
+// command.
+type commandResult struct {
+	Command    string `json:"command"`
+	OK         bool   `json:"ok"`
+	ExitCode   int    `json:"exit_code"`
+	DurationMs int64  `json:"duration_ms"`
+	// Files are the artifacts the command wrote, in order.
+	Files []string `json:"files,omitempty"`
+	// Result is what the command printed if it is json, as inspect does.
+	Result json.RawMessage `json:"result,omitempty"`
+	// Output is the text the command printed otherwise, by line.
+	Output []string      `json:"output,omitempty"`
+	Error  *commandError `json:"error,omitempty"`
+}
+
+type commandError struct {
+	// Class is the class of the exit code: usage, input, unsatisfied, rejected,
+	// resources, timeout, interrupted or failure.
+	Class   string `json:"class"`
+	Message string `json:"message"`
+}
+
+// newCommandResult is the result of the command named name, which returned err after
+// printing output.
+func newCommandResult(name string, err error, output string, duration time.Duration) commandResult {
+	class, code := classify(err)
+	res := commandResult{
+		Command:    name,
+		OK:         err == nil,
+		ExitCode:   code,
+		DurationMs: duration.Milliseconds(),
+		Files:      utils.WrittenFiles(),
+	}
+	output = strings.TrimSpace(output)
+	switch {
+	case strings.HasPrefix(output, "{") && json.Valid([]byte(output)):
+		res.Result = json.RawMessage(output)
+	case output != "":
+		res.Output = strings.Split(output, "\n")
+	}
+	if err != nil {
+		res.Error = &commandError{Class: class, Message: err.Error()}
+	}
+	return res
+}
+
+// executeJSON runs c like execute with its output captured, then prints its
+// commandResult on stdout. The gnark logs go to stderr meanwhile.
+func executeJSON(c *command, set *optionSet, argErr error) error {
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	var output bytes.Buffer
+	copied := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(&output, r)
+		close(copied)
+	}()
+	os.Stdout = w
+	logger.SetOutput(os.Stderr)
+	utils.RecordWrittenFiles()
+
+	start := time.Now()
+	err = run(c, set, argErr)
+	duration := time.Since(start)
+	os.Stdout = stdout
+	w.Close()
+	<-copied
+	r.Close()
+
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	encodeErr := encoder.Encode(newCommandResult(c.name, err, output.String(), duration))
+	if err != nil {
+		return &reportedError{err}
+	}
+	return encodeErr
+}
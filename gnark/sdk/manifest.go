@@ -0,0 +1,36 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrManifestMismatch is returned when the artifacts of a manifest changed since it
+// was written.
+var ErrManifestMismatch = errors.New("artifacts do not match the manifest")
+
+// CheckManifest hashes the artifacts listed in the manifest at MANIFEST_PATH, by
+// default the one written next to the proof at PROOF_PATH, and fails if any of them is
+// missing or changed.
+func CheckManifest(w io.Writer) error {
+	path := os.Getenv("MANIFEST_PATH")
+	if path == "" {
+		path = os.Getenv("PROOF_PATH") + utils.ManifestExt
+	}
+	manifest, err := utils.ReadProofManifest(path)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "proof %s written %s by pico %s, gnark %s, %s\n", manifest.Proof.Path, manifest.CreatedAt.Format("2006-01-02 15:04:05 MST"),
+		manifest.Versions.Pico, manifest.Versions.Gnark, manifest.Versions.Go)
+	mismatches := manifest.Check()
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%w %s:\n  %s", ErrManifestMismatch, path, strings.Join(mismatches, "\n  "))
+	}
+	fmt.Fprintf(w, "witness, keys and proof match %s\n", path)
+	return nil
+}
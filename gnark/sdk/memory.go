@@ -0,0 +1,130 @@
+package sdk
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// memorySampleInterval is how often prove samples memory usage.
+const memorySampleInterval = 500 * time.Millisecond
+
+// MemoryPeak holds the highest memory usage seen by a MemorySampler, in bytes.
+type MemoryPeak struct {
+	// GoHeap is the heap memory in use by Go objects.
+	GoHeap uint64
+	// GoSys is the memory the Go runtime obtained from the OS.
+	GoSys uint64
+	// RSS is the resident set size of the process, including memory allocated by the
+	// native field libraries.
+	RSS uint64
+	// Cgroup is the usage of the process's cgroup, 0 unless cgroup sampling is enabled.
+	Cgroup uint64
+}
+
+func (p MemoryPeak) String() string {
+	s := fmt.Sprintf("peak memory: go heap %d MB, go sys %d MB, rss %d MB", p.GoHeap>>20, p.GoSys>>20, p.RSS>>20)
+	if p.Cgroup > 0 {
+		s += fmt.Sprintf(", cgroup %d MB", p.Cgroup>>20)
+	}
+	return s
+}
+
+// MemorySampler periodically samples memory usage and keeps the peaks.
+type MemorySampler struct {
+	cgroup bool
+	stop   chan struct{}
+	done   sync.WaitGroup
+
+	mu   sync.Mutex
+	peak MemoryPeak
+}
+
+// StartMemorySampler samples memory every interval until Stop is called. With cgroup
+// set, the usage of the process's cgroup (v2 or v1) is sampled too, which is what
+// container memory limits apply to.
+func StartMemorySampler(interval time.Duration, cgroup bool) *MemorySampler {
+	s := &MemorySampler{cgroup: cgroup, stop: make(chan struct{})}
+	s.sample()
+	s.done.Add(1)
+	go func() {
+		defer s.done.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.sample()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	return s
+}
+
+// Stop ends sampling and returns the peaks.
+func (s *MemorySampler) Stop() MemoryPeak {
+	close(s.stop)
+	s.done.Wait()
+	s.sample()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// the kernel's high water mark also covers spikes between two samples
+	s.peak.RSS = max(s.peak.RSS, peakRSS())
+	return s.peak
+}
+
+func (s *MemorySampler) sample() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	rss := CurrentRSS()
+	var cgroup uint64
+	if s.cgroup {
+		cgroup = cgroupMemory()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.peak.GoHeap = max(s.peak.GoHeap, stats.HeapInuse)
+	s.peak.GoSys = max(s.peak.GoSys, stats.Sys)
+	s.peak.RSS = max(s.peak.RSS, rss)
+	s.peak.Cgroup = max(s.peak.Cgroup, cgroup)
+}
+
+// CurrentRSS reads the resident set size of the process from /proc, 0 where it is
+// not available.
+func CurrentRSS() uint64 {
+	statm, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0
+	}
+	fields := bytes.Fields(statm)
+	if len(fields) < 2 {
+		return 0
+	}
+	pages, err := strconv.ParseUint(string(fields[1]), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return pages * uint64(os.Getpagesize())
+}
+
+func cgroupMemory() uint64 {
+	for _, path := range []string{"/sys/fs/cgroup/memory.current", "/sys/fs/cgroup/memory/memory.usage_in_bytes"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		usage, err := strconv.ParseUint(string(bytes.TrimSpace(data)), 10, 64)
+		if err == nil {
+			return usage
+		}
+	}
+	return 0
+}
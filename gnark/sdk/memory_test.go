@@ -0,0 +1,26 @@
+package sdk
+
+import (
+	"github.com/consensys/gnark/test"
+	"testing"
+	"time"
+)
+
+var memorySink []byte
+
+func TestMemorySampler(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	sampler := StartMemorySampler(time.Millisecond, true)
+	memorySink = make([]byte, 32<<20)
+	for i := range memorySink {
+		memorySink[i] = 1
+	}
+	time.Sleep(10 * time.Millisecond)
+	peak := sampler.Stop()
+	memorySink = nil
+
+	assert.GreaterOrEqual(peak.GoHeap, uint64(32<<20))
+	assert.GreaterOrEqual(peak.GoSys, peak.GoHeap)
+	assert.Contains(peak.String(), "peak memory")
+}
@@ -0,0 +1,274 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/onchain"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark/backend/groth16"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Preflight simulates verifyProof of the verifier contract at VERIFIER_ADDRESS with
+// the proof at PROOF_PATH through the node at RPC_URL, so a proof the deployed
+// contract rejects is caught before a transaction is paid for.
+func Preflight(ctx context.Context) error {
+	address := os.Getenv("VERIFIER_ADDRESS")
+	err := onchain.CheckAddress(address)
+	if err != nil {
+		return err
+	}
+	calldata, err := ReadProofCalldata()
+	if err != nil {
+		return err
+	}
+
+	client, err := onchain.Dial(os.Getenv("RPC_URL"))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	_, err = client.Call(ctx, onchain.CallMsg{To: address, Data: calldata.Encode()})
+	var revert *onchain.RevertError
+	if errors.As(err, &revert) {
+		return fmt.Errorf("verifier contract %s rejects the proof: %v", address, revert)
+	}
+	if err != nil {
+		return fmt.Errorf("fail to call verifier contract: %w", err)
+	}
+	fmt.Printf("verifier contract %s accepts the proof\n", address)
+	return nil
+}
+
+// Submit sends verifyProof of the proof at PROOF_PATH to the verifier contract at
+// VERIFIER_ADDRESS, signed with the key of loadSubmitKey, and waits until it is mined.
+// The call is simulated first so a rejected proof costs no gas.
+func Submit(ctx context.Context) error {
+	address := os.Getenv("VERIFIER_ADDRESS")
+	err := onchain.CheckAddress(address)
+	if err != nil {
+		return err
+	}
+	key, err := loadSubmitKey()
+	if err != nil {
+		return err
+	}
+	calldata, err := ReadProofCalldata()
+	if err != nil {
+		return err
+	}
+	client, err := onchain.Dial(os.Getenv("RPC_URL"))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	msg := onchain.CallMsg{From: key.Address, To: address, Data: calldata.Encode()}
+
+	_, err = client.Call(ctx, msg)
+	var revert *onchain.RevertError
+	if errors.As(err, &revert) {
+		return fmt.Errorf("verifier contract %s rejects the proof: %v", address, revert)
+	}
+	if err != nil {
+		return fmt.Errorf("fail to call verifier contract: %w", err)
+	}
+
+	var hash string
+	// another transaction of the account may take the nonce in between, retry with the next one
+	for attempt := 0; ; attempt++ {
+		tx, err := client.BuildTx(ctx, msg)
+		if err != nil {
+			return fmt.Errorf("fail to build transaction: %w", err)
+		}
+		if expected := os.Getenv("CHAIN_ID"); expected != "" && tx.ChainId().String() != expected {
+			return fmt.Errorf("rpc is on chain %s, expected chain %s", tx.ChainId(), expected)
+		}
+		tx, err = key.SignTx(tx)
+		if err != nil {
+			return err
+		}
+		hash, err = client.SendTransaction(ctx, tx)
+		if err != nil {
+			// retry only if the nonce was taken, whatever wording the node uses
+			pending, nonceErr := client.PendingNonce(ctx, key.Address)
+			if nonceErr == nil && pending > tx.Nonce() && attempt < 2 {
+				fmt.Printf("nonce %d was taken by another transaction, retry with nonce %d\n", tx.Nonce(), pending)
+				continue
+			}
+			return fmt.Errorf("fail to send transaction: %w", err)
+		}
+		fmt.Printf("sent transaction %s from %s, nonce %d, gas limit %d\n", hash, key.Address, tx.Nonce(), tx.Gas())
+		break
+	}
+
+	receipt, err := client.WaitReceipt(ctx, hash)
+	if err != nil {
+		return fmt.Errorf("fail to get receipt of transaction %s: %w", hash, err)
+	}
+	if !receipt.Succeeded {
+		return fmt.Errorf("transaction %s reverted in block %d", hash, receipt.BlockNumber)
+	}
+	fmt.Printf("proof verified on chain in block %d, gas used %d\n", receipt.BlockNumber, receipt.GasUsed)
+	return nil
+}
+
+// ExportFoundryTest writes to FOUNDRY_TEST_PATH a Foundry test of the verifier
+// contract at SOLIDITY_PATH, checking it accepts the proof at PROOF_PATH and rejects
+// tampered copies. The test is skipped without a proof verifying with the vk at
+// VK_PATH, such as the proof of previous keys left by an earlier setup.
+func ExportFoundryTest() error {
+	testPath, proofPath := os.Getenv("FOUNDRY_TEST_PATH"), os.Getenv("PROOF_PATH")
+	f, err := utils.OpenArtifact(proofPath)
+	if err != nil {
+		fmt.Printf("no proof at %s, skipping foundry test; export the solidity again once proved\n", proofPath)
+		return nil
+	}
+	f.Close()
+	_, err = verifyProofFile()
+	if err != nil {
+		fmt.Printf("proof at %s does not verify with the vk, skipping foundry test; export the solidity again once proved: %v\n", proofPath, err)
+		return nil
+	}
+	calldata, err := ReadProofCalldata()
+	if err != nil {
+		return err
+	}
+
+	// the test/ directory of a foundry project may not exist yet
+	if !strings.Contains(testPath, "://") {
+		err = os.MkdirAll(filepath.Dir(testPath), 0755)
+		if err != nil {
+			return err
+		}
+	}
+	err = utils.WriteArtifact(testPath, func(w io.Writer) error {
+		return onchain.WriteFoundryTest(w, solidityImport(testPath, os.Getenv("SOLIDITY_PATH")), calldata)
+	})
+	if err != nil {
+		return fmt.Errorf("fail to write foundry test: %w", err)
+	}
+	fmt.Printf("foundry test written to %s\n", testPath)
+	return nil
+}
+
+// solidityImport returns the import of the contract at solPath from the file at
+// from: relative for local paths, else the base name of the contract.
+func solidityImport(from, solPath string) string {
+	if !strings.Contains(from, "://") && !strings.Contains(solPath, "://") {
+		rel, err := filepath.Rel(filepath.Dir(from), solPath)
+		if err == nil && strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+		if err == nil {
+			return "./" + filepath.ToSlash(rel)
+		}
+	}
+	return "./" + path.Base(solPath)
+}
+
+// loadSubmitKey loads the signing key from the keystore at KEYSTORE_PATH, unlocked
+// with the password in KEYSTORE_PASSWORD_FILE, or else the hex key in the file at
+// PRIVATE_KEY_FILE or in PRIVATE_KEY.
+func loadSubmitKey() (*onchain.Key, error) {
+	if path := os.Getenv("KEYSTORE_PATH"); path != "" {
+		keystore, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("fail to read keystore: %w", err)
+		}
+		password, err := os.ReadFile(os.Getenv("KEYSTORE_PASSWORD_FILE"))
+		if err != nil {
+			return nil, fmt.Errorf("fail to read keystore password: %w", err)
+		}
+		return onchain.DecryptKeystore(keystore, strings.TrimRight(string(password), "\r\n"))
+	}
+	if path := os.Getenv("PRIVATE_KEY_FILE"); path != "" {
+		secret, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("fail to read private key: %w", err)
+		}
+		return onchain.ParseKey(string(secret))
+	}
+	if secret := os.Getenv("PRIVATE_KEY"); secret != "" {
+		return onchain.ParseKey(secret)
+	}
+	return nil, fmt.Errorf("no signing key, pass -keystore or -private-key-file, or set PRIVATE_KEY")
+}
+
+// ReadProofCalldata reads the proof at PROOF_PATH, using the vk at VK_PATH to tell
+// whether it has a commitment.
+func ReadProofCalldata() (*onchain.ProofCalldata, error) {
+	curve, err := utils.CurveFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	vk := groth16.NewVerifyingKey(curve)
+	err = utils.ReadVerifyingKey(os.Getenv("VK_PATH"), vk)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read verifying key: %w", err)
+	}
+	data, err := utils.ReadProofArtifact(os.Getenv("PROOF_PATH"))
+	if err != nil {
+		return nil, fmt.Errorf("fail to read proof: %w", err)
+	}
+	return onchain.ParseProofFile(data, onchain.HasCommitment(vk))
+}
+
+// ExportRegistration writes the payload registering the program of the witness at
+// WITNESS_JSON with the Brevis gateway to REGISTRATION_PATH: its vkey, the verifier
+// contract at VERIFIER_ADDRESS and the wrapper circuit of the vk at VK_PATH.
+func ExportRegistration(field string) error {
+	data, err := utils.ReadWitnessJson(os.Getenv("WITNESS_JSON"))
+	if err != nil {
+		return fmt.Errorf("fail to read witness: %w", err)
+	}
+	single, multi, err := utils.ParseWitness(data)
+	if err != nil {
+		return fmt.Errorf("fail to parse witness: %w", err)
+	}
+	vkeyHash := ""
+	if single != nil {
+		vkeyHash = single.VkeyHash
+	} else {
+		err = multi.Validate()
+		if err != nil {
+			return err
+		}
+		vkeyHash = multi.Chunks[0].VkeyHash
+	}
+
+	curve, err := utils.CurveFromEnv()
+	if err != nil {
+		return err
+	}
+	vk := groth16.NewVerifyingKey(curve)
+	err = utils.ReadVerifyingKey(os.Getenv("VK_PATH"), vk)
+	if err != nil {
+		return fmt.Errorf("failed to read verifying key: %w", err)
+	}
+	registration, err := onchain.NewRegistration(vkeyHash, os.Getenv("VERIFIER_ADDRESS"), vk)
+	if err != nil {
+		return err
+	}
+	registration.ChainID = os.Getenv("CHAIN_ID")
+	registration.Circuit.Field = field
+
+	payload, err := json.MarshalIndent(registration, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = utils.WriteArtifact(os.Getenv("REGISTRATION_PATH"), func(w io.Writer) error {
+		_, err := w.Write(append(payload, '\n'))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("fail to write registration: %w", err)
+	}
+	fmt.Printf("registration of program %s written to %s\n", registration.ProgramVkey, os.Getenv("REGISTRATION_PATH"))
+	return nil
+}
@@ -0,0 +1,59 @@
+package sdk
+
+import (
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportFoundryTest(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	dir := t.TempDir()
+	t.Setenv("CURVE", "bn254")
+	t.Setenv("VK_PATH", filepath.Join(dir, "vm_vk"))
+	t.Setenv("PROOF_PATH", filepath.Join(dir, "proof.data"))
+	t.Setenv("SOLIDITY_PATH", filepath.Join(dir, "Groth16Verifier.sol"))
+	t.Setenv("FOUNDRY_TEST_PATH", filepath.Join(dir, "test", "Groth16Verifier.t.sol"))
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &publicValuesCircuit{})
+	assert.NoError(err)
+	pk, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	_, otherVk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	assert.NoError(utils.WriteVerifyingKey(os.Getenv("VK_PATH"), otherVk))
+
+	// without a proof the test is skipped
+	assert.NoError(ExportFoundryTest())
+	_, err = os.Stat(os.Getenv("FOUNDRY_TEST_PATH"))
+	assert.True(os.IsNotExist(err))
+
+	fullWitness, err := frontend.NewWitness(&publicValuesCircuit{X: 3, VkeyHash: 9, CommittedValuesDigest: 4}, ecc.BN254.ScalarField())
+	assert.NoError(err)
+	pubWitness, err := fullWitness.Public()
+	assert.NoError(err)
+	proof, err := groth16.Prove(ccs, pk, fullWitness)
+	assert.NoError(err)
+	data, err := utils.FormatProof(utils.ProofFormatJson, proof, pubWitness)
+	assert.NoError(err)
+	assert.NoError(os.WriteFile(os.Getenv("PROOF_PATH"), data, 0644))
+
+	// nor with the proof of other keys
+	assert.NoError(ExportFoundryTest())
+	_, err = os.Stat(os.Getenv("FOUNDRY_TEST_PATH"))
+	assert.True(os.IsNotExist(err))
+
+	assert.NoError(utils.WriteVerifyingKey(os.Getenv("VK_PATH"), vk))
+	assert.NoError(ExportFoundryTest())
+	data, err = os.ReadFile(os.Getenv("FOUNDRY_TEST_PATH"))
+	assert.NoError(err)
+	assert.Contains(string(data), `import {Verifier} from "../Groth16Verifier.sol";`)
+	assert.Contains(string(data), "values[0] = 9;\n        values[1] = 4;")
+}
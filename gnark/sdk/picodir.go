@@ -0,0 +1,109 @@
+package sdk
+
+import (
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// The files of the EVM output directory of the Rust prover that the wrapper reads.
+const (
+	PicoDirWitness     = "groth16_witness.json"
+	PicoDirConstraints = "constraints.json"
+	// PicoDirPublicValues holds the hex public values of the proof, without 0x.
+	PicoDirPublicValues = utils.PublicValuesFile
+)
+
+// PicoDir is what LocatePicoDir found in an EVM output directory of the Rust prover.
+type PicoDir struct {
+	Witness     string
+	Constraints string
+}
+
+// LocatePicoDir finds the witness and constraints the Rust prover wrote in dir or one of
+// its subdirectories. The directory must hold a single witness; its constraints are
+// those next to it, or else the only constraints of the directory.
+func LocatePicoDir(dir string) (*PicoDir, error) {
+	var witnesses, constraints []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+		case d.Name() == PicoDirWitness:
+			witnesses = append(witnesses, path)
+		case d.Name() == PicoDirConstraints:
+			constraints = append(constraints, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fail to scan pico dir %s: %w", dir, err)
+	}
+	if len(witnesses) != 1 {
+		return nil, fmt.Errorf("pico dir %s has %d %s files, expected 1: %s", dir, len(witnesses), PicoDirWitness, strings.Join(witnesses, ", "))
+	}
+	res := &PicoDir{Witness: witnesses[0]}
+	for _, path := range constraints {
+		if filepath.Dir(path) == filepath.Dir(res.Witness) {
+			res.Constraints = path
+		}
+	}
+	if res.Constraints == "" {
+		if len(constraints) != 1 {
+			return nil, fmt.Errorf("pico dir %s has no %s next to %s and %d elsewhere, expected 1", dir, PicoDirConstraints, res.Witness, len(constraints))
+		}
+		res.Constraints = constraints[0]
+	}
+	return res, nil
+}
+
+// DetectField returns the field, "kb" or "bb", of the proof whose witness and
+// constraints are at witnessPath and constraintsPath. That is the field of the
+// Poseidon2 permutation the constraints use, e.g. PermuteKoalaBear. Constraints without
+// one are evaluated outside the circuit, as check-witness does, and the field is the
+// one over which they accept the first chunk and commit to its vkey hash.
+func DetectField(witnessPath, constraintsPath string) (string, error) {
+	file, err := utils.ReadConstraints(constraintsPath)
+	if err != nil {
+		return "", err
+	}
+	fieldSdks := []fieldSdk{koalaBearSdk, babyBearSdk}
+	for _, cs := range file.Constraints {
+		for _, f := range fieldSdks {
+			if cs.Opcode == f.native.PermuteOpcode {
+				return f.name, nil
+			}
+		}
+	}
+
+	data, err := utils.ReadArtifact(witnessPath)
+	if err != nil {
+		return "", fmt.Errorf("fail to read witness file: %w", err)
+	}
+	single, multi, err := utils.ParseWitness(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse witness json: %w", err)
+	}
+	chunk := single
+	if multi != nil {
+		if len(multi.Chunks) == 0 {
+			return "", fmt.Errorf("witness has no chunk")
+		}
+		chunk = &multi.Chunks[0]
+	}
+	var fields []string
+	for _, f := range fieldSdks {
+		commitments, err := f.native.Evaluate(file, *chunk)
+		if err == nil && checkCommitment(chunk.VkeyHash, commitments.VkeyHash) == nil {
+			fields = append(fields, f.name)
+		}
+	}
+	if len(fields) != 1 {
+		return "", fmt.Errorf("the constraints accept the witness over %d fields, set -field", len(fields))
+	}
+	return fields[0], nil
+}
@@ -0,0 +1,68 @@
+package sdk
+
+import (
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocatePicoDir(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	dir := t.TempDir()
+	write := func(name, data string) {
+		assert.NoError(os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+		assert.NoError(os.WriteFile(filepath.Join(dir, name), []byte(data), 0644))
+	}
+	_, err := LocatePicoDir(dir)
+	assert.ErrorContains(err, "has 0 groth16_witness.json files")
+
+	// the cube of the felt commits to the vkey hash, which differs between the fields
+	constraints := `[
+		{"opcode": "WitnessF", "args": [["a"], ["0"]]},
+		{"opcode": "MulF", "args": [["a2"], ["a"], ["a"]]},
+		{"opcode": "MulF", "args": [["a3"], ["a2"], ["a"]]},
+		{"opcode": "CircuitFelt2Var", "args": [["vk"], ["a3"]]},
+		{"opcode": "CommitVkeyHash", "args": [["vk"]]},
+		{"opcode": "WitnessV", "args": [["digest"], ["0"]]},
+		{"opcode": "CommitCommitedValuesDigest", "args": [["digest"]]}
+	]`
+	write("evm/constraints.json", constraints)
+	_, err = LocatePicoDir(dir)
+	assert.ErrorContains(err, "has 0 groth16_witness.json files")
+	write("evm/kb/groth16_witness.json", `{"vars": ["5"], "felts": ["2000000"], "vkey_hash": "374668997", "committed_values_digest": "5"}`)
+	located, err := LocatePicoDir(dir)
+	assert.NoError(err)
+	assert.Equal(&PicoDir{Witness: filepath.Join(dir, "evm/kb/groth16_witness.json"), Constraints: filepath.Join(dir, "evm/constraints.json")}, located)
+	field, err := DetectField(located.Witness, located.Constraints)
+	assert.NoError(err)
+	assert.Equal("kb", field)
+
+	// the constraints next to the witness are preferred
+	write("evm/kb/constraints.json", constraints)
+	located, err = LocatePicoDir(dir)
+	assert.NoError(err)
+	assert.Equal(filepath.Join(dir, "evm/kb/constraints.json"), located.Constraints)
+
+	write("evm/kb/groth16_witness.json", `{"vars": ["5"], "felts": ["2000000"], "vkey_hash": "105317657", "committed_values_digest": "5"}`)
+	field, err = DetectField(located.Witness, located.Constraints)
+	assert.NoError(err)
+	assert.Equal("bb", field)
+	write("evm/kb/groth16_witness.json", `{"vars": ["5"], "felts": ["2000000"], "vkey_hash": "7", "committed_values_digest": "5"}`)
+	_, err = DetectField(located.Witness, located.Constraints)
+	assert.ErrorContains(err, "over 0 fields")
+
+	// the permutation of the constraints names the field without evaluating them
+	write("evm/kb/constraints.json", `[
+		{"opcode": "WitnessF", "args": [["a"], ["0"]]},
+		{"opcode": "PermuteBabyBear", "args": [["a"], ["a"], ["a"], ["a"], ["a"], ["a"], ["a"], ["a"], ["a"], ["a"], ["a"], ["a"], ["a"], ["a"], ["a"], ["a"]]}
+	]`)
+	field, err = DetectField(located.Witness, located.Constraints)
+	assert.NoError(err)
+	assert.Equal("bb", field)
+
+	write("evm/bb/groth16_witness.json", `{}`)
+	_, err = LocatePicoDir(dir)
+	assert.ErrorContains(err, "has 2 groth16_witness.json files")
+}
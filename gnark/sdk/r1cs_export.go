@@ -0,0 +1,145 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend/groth16"
+	groth16_bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"github.com/consensys/gnark/constraint"
+	cs_bn254 "github.com/consensys/gnark/constraint/bn254"
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+	fcs "github.com/consensys/gnark/frontend/cs"
+	"io"
+	"math/big"
+	"os"
+)
+
+// ExportR1cs writes the constraint system of the circuit of the witness at
+// WITNESS_JSON to R1CS_PATH in the .r1cs format of circom and snarkjs, for external
+// tooling. It exports the ccs of setup at CCS_PATH when it matches, else compiles it.
+// With WTNS_PATH, it also writes there the solved witness in the .wtns format of
+// snarkjs.
+func ExportR1cs(ctx context.Context, field string, newCircuits func(data []byte) (frontend.Circuit, frontend.Circuit, error)) error {
+	curve, err := utils.CurveFromEnv()
+	if err != nil {
+		return err
+	}
+	data, err := utils.ReadWitnessJson(os.Getenv("WITNESS_JSON"))
+	if err != nil {
+		return fmt.Errorf("fail to read witness file: %w", err)
+	}
+	circuit, assignment, err := newCircuits(data)
+	if err != nil {
+		return err
+	}
+	ccs, _, err := loadOrCompileCcs(ctx, curve, field, circuit)
+	if err != nil {
+		return err
+	}
+
+	path := os.Getenv("R1CS_PATH")
+	err = utils.WriteFileAtomic(path, func(w io.Writer) error {
+		return utils.WriteSnarkjsR1cs(w, ccs)
+	})
+	if err != nil {
+		return fmt.Errorf("fail to write r1cs: %w", err)
+	}
+	fmt.Printf("r1cs written to %s: %d constraints, %d public inputs\n", path, ccs.GetNbConstraints(), ccs.GetNbPublicVariables()-1)
+	if commitments, ok := ccs.GetCommitments().(constraint.Groth16Commitments); ok && len(commitments) > 0 {
+		fmt.Printf("the circuit has %d groth16 commitments, exported as unconstrained internal wires: the r1cs alone does not bind them\n", len(commitments))
+	}
+
+	if path := os.Getenv("WTNS_PATH"); path != "" {
+		wires, err := solveWires(curve, ccs, assignment)
+		if err != nil {
+			return err
+		}
+		err = utils.WriteFileAtomic(path, func(w io.Writer) error {
+			return utils.WriteSnarkjsWtns(w, wires)
+		})
+		if err != nil {
+			return fmt.Errorf("fail to write wtns: %w", err)
+		}
+		fmt.Printf("wtns written to %s: %d wires\n", path, len(wires))
+	}
+	return nil
+}
+
+// solveWires solves ccs for assignment and returns the values of all its wires. The
+// Groth16 commitments are computed as the prover does, with the commitment keys of
+// the pk at PK_PATH and HASH_TO_FIELD, so the wires are those of the proofs of that pk.
+func solveWires(curve ecc.ID, ccs constraint.ConstraintSystem, assignment frontend.Circuit) (fr.Vector, error) {
+	fullWitness, err := frontend.NewWitness(assignment, ccs.Field())
+	if err != nil {
+		return nil, fmt.Errorf("fail to get witness: %w", err)
+	}
+	var opts []solver.Option
+	commitments, _ := ccs.GetCommitments().(constraint.Groth16Commitments)
+	if len(commitments) > 0 {
+		pk := groth16.NewProvingKey(curve)
+		err = utils.ReadProvingKey(os.Getenv("PK_PATH"), pk)
+		if err != nil {
+			return nil, fmt.Errorf("fail to read proving key for the commitments of the witness: %w", err)
+		}
+		bnPk, ok := pk.(*groth16_bn254.ProvingKey)
+		if !ok || len(bnPk.CommitmentKeys) != len(commitments) {
+			return nil, fmt.Errorf("proving key at %s does not match the commitments of the circuit", os.Getenv("PK_PATH"))
+		}
+		hint, err := commitmentHint(bnPk, commitments)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, solver.OverrideHint(solver.GetHintID(fcs.Bsb22CommitmentComputePlaceholder), hint))
+	}
+
+	solution, err := ccs.Solve(fullWitness, opts...)
+	if err != nil {
+		if solveDebug() {
+			err = describeUnsatisfied(ccs, err)
+		}
+		return nil, fmt.Errorf("fail to solve: %w", err)
+	}
+	r1csSolution, ok := solution.(*cs_bn254.R1CSSolution)
+	if !ok {
+		return nil, fmt.Errorf("unsupported solution %T", solution)
+	}
+	return r1csSolution.W, nil
+}
+
+// commitmentHint returns the hint of the Groth16 prover of gnark computing the
+// commitments: the hash to the field of the pedersen commitment of the committed wires.
+func commitmentHint(pk *groth16_bn254.ProvingKey, commitments constraint.Groth16Commitments) (solver.Hint, error) {
+	hashToField, err := utils.HashToFieldFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return func(_ *big.Int, in []*big.Int, out []*big.Int) error {
+		i := int(in[0].Int64())
+		in = in[1:]
+		hashed := in[:len(commitments[i].PublicAndCommitmentCommitted)]
+		committed := make([]fr.Element, len(in)-len(hashed))
+		for j, v := range in[len(hashed):] {
+			committed[j].SetBigInt(v)
+		}
+		commitment, err := pk.CommitmentKeys[i].Commit(committed)
+		if err != nil {
+			return err
+		}
+
+		hashToField.Write(constraint.SerializeCommitment(commitment.Marshal(), hashed, (fr.Bits-1)/8+1))
+		digest := hashToField.Sum(nil)
+		hashToField.Reset()
+		n := fr.Bytes
+		if hashToField.Size() < fr.Bytes {
+			n = hashToField.Size()
+		}
+		var res fr.Element
+		res.SetBytes(digest[:n])
+		res.BigInt(out[0])
+		return nil
+	}, nil
+}
@@ -0,0 +1,72 @@
+package sdk
+
+import (
+	"context"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/std/rangecheck"
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportR1cs(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	dir := t.TempDir()
+	witness := filepath.Join(dir, "witness.json")
+	assert.NoError(os.WriteFile(witness, []byte("{}"), 0644))
+	t.Setenv("CURVE", "bn254")
+	t.Setenv("WITNESS_JSON", witness)
+	t.Setenv("CCS_READ", "0")
+	t.Setenv("R1CS_PATH", filepath.Join(dir, "circuit.r1cs"))
+	t.Setenv("WTNS_PATH", filepath.Join(dir, "witness.wtns"))
+
+	err := ExportR1cs(context.Background(), "kb", func([]byte) (frontend.Circuit, frontend.Circuit, error) {
+		return &cubicCircuit{}, &cubicCircuit{X: 2, Y: 15}, nil
+	})
+	assert.NoError(err)
+	data, err := os.ReadFile(os.Getenv("R1CS_PATH"))
+	assert.NoError(err)
+	assert.Equal("r1cs", string(data[:4]))
+	data, err = os.ReadFile(os.Getenv("WTNS_PATH"))
+	assert.NoError(err)
+	assert.Equal("wtns", string(data[:4]))
+}
+
+type rangeCheckedCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (c *rangeCheckedCircuit) Define(api frontend.API) error {
+	rangecheck.New(api).Check(c.X, 8)
+	api.AssertIsEqual(c.Y, api.Mul(c.X, c.X))
+	return nil
+}
+
+func TestSolveWiresCommitments(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &rangeCheckedCircuit{})
+	assert.NoError(err)
+	pk, _, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	assignment := &rangeCheckedCircuit{X: 5, Y: 25}
+
+	// the commitments need the keys of the pk
+	t.Setenv("PK_PATH", filepath.Join(t.TempDir(), "vm_pk"))
+	_, err = solveWires(ecc.BN254, ccs, assignment)
+	assert.ErrorContains(err, "fail to read proving key")
+
+	assert.NoError(utils.WriteProvingKey(os.Getenv("PK_PATH"), pk))
+	wires, err := solveWires(ecc.BN254, ccs, assignment)
+	assert.NoError(err)
+	assert.Equal(ccs.GetNbPublicVariables()+ccs.GetNbSecretVariables()+ccs.GetNbInternalVariables(), len(wires))
+	assert.Equal(uint64(25), wires[1].Uint64())
+	assert.Equal(uint64(5), wires[2].Uint64())
+}
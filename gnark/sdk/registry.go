@@ -0,0 +1,292 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
+	"math/big"
+	"sync"
+)
+
+var ErrUnknownProgram = errors.New("unknown program vkey hash")
+
+// ErrInvalidWitness reports a witness that does not fit the keys it was sent to.
+var ErrInvalidWitness = errors.New("invalid witness")
+
+// ProgramKeys is a single registry entry: the field configuration the program's
+// wrapper circuit was built for and the session holding its keys.
+type ProgramKeys struct {
+	VkeyHash string
+	Field    string
+	Session  *ProvingSession
+}
+
+// KeyRegistry maps Pico program vkey hashes to their proving artifacts, so one prover
+// instance can serve several programs and field configurations.
+type KeyRegistry struct {
+	mu       sync.RWMutex
+	programs map[string]*ProgramKeys
+	fallback *ProgramKeys
+}
+
+// RegistryEntry is one element of the JSON manifest read by LoadKeyRegistry.
+type RegistryEntry struct {
+	VkeyHash string `json:"vkey_hash"`
+	Field    string `json:"field"`
+	Curve    string `json:"curve"`
+	PkPath   string `json:"pk"`
+	VkPath   string `json:"vk"`
+	CcsPath  string `json:"ccs"`
+}
+
+func NewKeyRegistry() *KeyRegistry {
+	return &KeyRegistry{
+		programs: make(map[string]*ProgramKeys),
+	}
+}
+
+// LoadKeyRegistry reads a JSON array of RegistryEntry from manifestPath and loads
+// every referenced key set.
+func LoadKeyRegistry(manifestPath string) (*KeyRegistry, error) {
+	entries, err := ReadRegistryManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	loader, err := NewKeyLoader(0, nil)
+	if err != nil {
+		return nil, err
+	}
+	return LoadRegistryEntries(entries, loader)
+}
+
+// ReadRegistryManifest reads the JSON array of RegistryEntry at manifestPath.
+func ReadRegistryManifest(manifestPath string) ([]RegistryEntry, error) {
+	data, err := utils.ReadArtifact(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read registry manifest: %w", err)
+	}
+
+	var entries []RegistryEntry
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse registry manifest: %w", err)
+	}
+	return entries, nil
+}
+
+// Source is the key set of the entry.
+func (e RegistryEntry) Source() (KeySource, error) {
+	curve, err := utils.ParseCurve(e.Curve)
+	if err != nil {
+		return KeySource{}, fmt.Errorf("invalid curve for vkey hash %s: %w", e.VkeyHash, err)
+	}
+	return KeySource{Curve: curve, PkPath: e.PkPath, VkPath: e.VkPath, CcsPath: e.CcsPath}, nil
+}
+
+// LoadRegistryEntries loads the key sets of entries with loader.
+func LoadRegistryEntries(entries []RegistryEntry, loader *KeyLoader) (*KeyRegistry, error) {
+	registry := NewKeyRegistry()
+	for _, entry := range entries {
+		source, err := entry.Source()
+		if err != nil {
+			return nil, err
+		}
+		session, err := loader.Load(source)
+		if err != nil {
+			return nil, fmt.Errorf("fail to load keys for vkey hash %s: %w", entry.VkeyHash, err)
+		}
+		err = registry.Register(entry.VkeyHash, entry.Field, session)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// NormalizeVkeyHash parses a decimal or 0x-prefixed hex vkey hash and returns its
+// canonical decimal form, which is how the Rust side writes it into the witness.
+func NormalizeVkeyHash(vkeyHash string) (string, error) {
+	v, ok := new(big.Int).SetString(vkeyHash, 0)
+	if !ok {
+		return "", fmt.Errorf("invalid vkey hash: %q", vkeyHash)
+	}
+	return v.String(), nil
+}
+
+func (r *KeyRegistry) Register(vkeyHash, field string, session *ProvingSession) error {
+	hash, err := NormalizeVkeyHash(vkeyHash)
+	if err != nil {
+		return err
+	}
+	if field != "kb" && field != "bb" {
+		return fmt.Errorf("invalid field %s for vkey hash %s", field, hash)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.programs[hash]; ok {
+		return fmt.Errorf("vkey hash %s already registered", hash)
+	}
+	r.programs[hash] = &ProgramKeys{VkeyHash: hash, Field: field, Session: session}
+	return nil
+}
+
+// SetFallback registers keys that serve any vkey hash not explicitly registered. It
+// keeps single-program deployments working without a manifest.
+func (r *KeyRegistry) SetFallback(field string, session *ProvingSession) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallback = &ProgramKeys{Field: field, Session: session}
+}
+
+// Lookup returns the keys registered for vkeyHash, or ErrUnknownProgram.
+func (r *KeyRegistry) Lookup(vkeyHash string) (*ProgramKeys, error) {
+	hash, err := NormalizeVkeyHash(vkeyHash)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if program, ok := r.programs[hash]; ok {
+		return program, nil
+	}
+	if r.fallback != nil {
+		return r.fallback, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownProgram, hash)
+}
+
+// Wait blocks until no proof is computing on any of the registered keys, see
+// ProvingSession.Wait.
+func (r *KeyRegistry) Wait() {
+	r.mu.RLock()
+	sessions := make([]*ProvingSession, 0, len(r.programs)+1)
+	for _, program := range r.programs {
+		sessions = append(sessions, program.Session)
+	}
+	if r.fallback != nil {
+		sessions = append(sessions, r.fallback.Session)
+	}
+	r.mu.RUnlock()
+
+	for _, session := range sessions {
+		session.Wait()
+	}
+}
+
+// Prove picks the keys matching the witness's vkey hash and proves it.
+func (r *KeyRegistry) Prove(ctx context.Context, inputs utils.WitnessInput) (groth16.Proof, witness.Witness, error) {
+	return r.prove(ctx, inputs.VkeyHash, 1, false, func(program *ProgramKeys) (witness.Witness, witness.Witness, error) {
+		return NewWitness(program.Session.Curve(), program.Field, inputs)
+	})
+}
+
+// ProveMulti is Prove for a witness wrapping several chunk proofs. The program's keys
+// must have been set up for the same number of chunks. The keys of an aggregated
+// witness are those registered for the vkey hash of its first chunk.
+func (r *KeyRegistry) ProveMulti(ctx context.Context, inputs utils.MultiWitnessInput) (groth16.Proof, witness.Witness, error) {
+	err := inputs.Validate()
+	if err != nil {
+		return nil, nil, err
+	}
+	return r.prove(ctx, inputs.Chunks[0].VkeyHash, len(inputs.Chunks), inputs.Aggregated(), func(program *ProgramKeys) (witness.Witness, witness.Witness, error) {
+		return NewMultiWitness(program.Session.Curve(), program.Field, inputs)
+	})
+}
+
+// ProveWitness parses a witness json, single or multi-chunk, and proves it.
+func (r *KeyRegistry) ProveWitness(ctx context.Context, data []byte) (groth16.Proof, witness.Witness, error) {
+	single, multi, err := utils.ParseWitness(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidWitness, err)
+	}
+	if multi != nil {
+		return r.ProveMulti(ctx, *multi)
+	}
+	return r.Prove(ctx, *single)
+}
+
+// AssignWitness is the first half of ProveWitness: it parses a witness json and
+// assigns the full and public witnesses of the program matching its vkey hash, whose
+// session proves them.
+func (r *KeyRegistry) AssignWitness(data []byte) (*ProgramKeys, witness.Witness, witness.Witness, error) {
+	single, multi, err := utils.ParseWitness(data)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: %v", ErrInvalidWitness, err)
+	}
+	if multi != nil {
+		err = multi.Validate()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return r.assign(multi.Chunks[0].VkeyHash, len(multi.Chunks), multi.Aggregated(), func(program *ProgramKeys) (witness.Witness, witness.Witness, error) {
+			return NewMultiWitness(program.Session.Curve(), program.Field, *multi)
+		})
+	}
+	return r.assign(single.VkeyHash, 1, false, func(program *ProgramKeys) (witness.Witness, witness.Witness, error) {
+		return NewWitness(program.Session.Curve(), program.Field, *single)
+	})
+}
+
+func (r *KeyRegistry) prove(ctx context.Context, vkeyHash string, chunks int, aggregated bool, newWitness func(program *ProgramKeys) (witness.Witness, witness.Witness, error)) (groth16.Proof, witness.Witness, error) {
+	program, fullWitness, pubWitness, err := r.assign(vkeyHash, chunks, aggregated, newWitness)
+	if err != nil {
+		return nil, nil, err
+	}
+	pf, err := program.Session.Prove(ctx, fullWitness)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pf, pubWitness, nil
+}
+
+func (r *KeyRegistry) assign(vkeyHash string, chunks int, aggregated bool, newWitness func(program *ProgramKeys) (witness.Witness, witness.Witness, error)) (*ProgramKeys, witness.Witness, witness.Witness, error) {
+	program, err := r.Lookup(vkeyHash)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	err = program.Session.CheckMemory()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// the public variables are the constant one, the vkey hash, or the two limbs of the
+	// root of the allowed vkeys, and one digest per chunk, laid out as PUBLIC_INPUT_* for
+	// a single chunk
+	layout, err := publicLayout(chunks, aggregated)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: %v", ErrInvalidWitness, err)
+	}
+	if hash, ok := new(big.Int).SetString(vkeyHash, 0); ok {
+		err = layout.CheckVkeyHash(hash)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("%w: %v", ErrInvalidWitness, err)
+		}
+	}
+	nbPublic := chunks + 1
+	switch {
+	case aggregated:
+		nbPublic = chunks + 2
+	case chunks == 1:
+		nbPublic = layout.NbPublicInputs()
+	}
+	if ccs := program.Session.Ccs(); ccs != nil && ccs.GetNbPublicVariables()-1 != nbPublic {
+		if aggregated {
+			return nil, nil, nil, fmt.Errorf("%w: keys for vkey hash %s have %d public inputs, witness aggregating %d chunks has %d", ErrInvalidWitness, vkeyHash, ccs.GetNbPublicVariables()-1, chunks, nbPublic)
+		}
+		if !layout.IsDefault() {
+			return nil, nil, nil, fmt.Errorf("%w: keys for vkey hash %s have %d public inputs, layout %s has %d", ErrInvalidWitness, vkeyHash, ccs.GetNbPublicVariables()-1, layout, nbPublic)
+		}
+		return nil, nil, nil, fmt.Errorf("%w: keys for vkey hash %s verify %d chunks, witness has %d", ErrInvalidWitness, vkeyHash, ccs.GetNbPublicVariables()-2, chunks)
+	}
+
+	fullWitness, pubWitness, err := newWitness(program)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get witness: %w", err)
+	}
+	return program, fullWitness, pubWitness, nil
+}
@@ -0,0 +1,78 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"strings"
+	"testing"
+)
+
+func TestKeyRegistryLookup(t *testing.T) {
+	registry := NewKeyRegistry()
+	kb := NewProvingSession(nil, nil, nil)
+	bb := NewProvingSession(nil, nil, nil)
+
+	if err := registry.Register("0x10", "kb", kb); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register("32", "bb", bb); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register("16", "kb", kb); err == nil {
+		t.Fatal("expected duplicate registration to fail")
+	}
+
+	program, err := registry.Lookup("16")
+	if err != nil || program.Session != kb || program.Field != "kb" {
+		t.Fatalf("unexpected lookup result: %+v, %v", program, err)
+	}
+	program, err = registry.Lookup("0x20")
+	if err != nil || program.Session != bb || program.Field != "bb" {
+		t.Fatalf("unexpected lookup result: %+v, %v", program, err)
+	}
+
+	_, err = registry.Lookup("7")
+	if !errors.Is(err, ErrUnknownProgram) {
+		t.Fatalf("expected ErrUnknownProgram, got %v", err)
+	}
+
+	registry.SetFallback("kb", kb)
+	program, err = registry.Lookup("7")
+	if err != nil || program.Session != kb {
+		t.Fatalf("expected fallback keys, got %+v, %v", program, err)
+	}
+}
+
+func TestKeyRegistryChunkCount(t *testing.T) {
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &cubicCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := NewKeyRegistry()
+	registry.SetFallback("kb", NewProvingSession(nil, nil, ccs))
+
+	// the cubic circuit has a single public input, so it verifies no chunk at all
+	twoChunks := []byte(`{"chunks": [
+		{"vars": ["7", "100"], "vkey_hash": "7", "committed_values_digest": "100"},
+		{"vars": ["7", "200"], "vkey_hash": "7", "committed_values_digest": "200"}
+	]}`)
+	_, _, err = registry.ProveWitness(context.Background(), twoChunks)
+	if !errors.Is(err, ErrInvalidWitness) || !strings.Contains(err.Error(), "verify 0 chunks, witness has 2") {
+		t.Fatalf("expected chunk count mismatch, got %v", err)
+	}
+	_, _, _, err = registry.AssignWitness(twoChunks)
+	if !errors.Is(err, ErrInvalidWitness) || !strings.Contains(err.Error(), "verify 0 chunks, witness has 2") {
+		t.Fatalf("expected chunk count mismatch when assigning, got %v", err)
+	}
+
+	_, _, err = registry.ProveWitness(context.Background(), []byte(`{"chunks": [
+		{"vars": ["7"], "vkey_hash": "7", "committed_values_digest": "100"},
+		{"vars": ["7"], "vkey_hash": "7", "committed_values_digest": "100"}
+	]}`))
+	if err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Fatalf("expected duplicate chunk error, got %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"sync"
+)
+
+// ProvingSession bundles the artifacts needed to produce and check Groth16 proofs.
+// The keys and constraint system are never mutated once the session is built, so a
+// single session can serve Prove calls from many goroutines at the same time.
+type ProvingSession struct {
+	pk    groth16.ProvingKey
+	vk    groth16.VerifyingKey
+	ccs   constraint.ConstraintSystem
+	curve ecc.ID
+	// ccsPath is the file the ccs was read from, empty if it was compiled.
+	ccsPath string
+	// keys loads the pk and ccs of the proofs instead, for the sessions of a KeyLoader
+	// loading on demand.
+	keys *sessionKeys
+
+	// running counts the proofs still computing, including those abandoned by a
+	// cancelled Prove.
+	running sync.WaitGroup
+}
+
+// NewProvingSession wraps already loaded artifacts. vk may be nil for prove-only
+// sessions, in which case Verify always fails.
+func NewProvingSession(pk groth16.ProvingKey, vk groth16.VerifyingKey, ccs constraint.ConstraintSystem) *ProvingSession {
+	s := &ProvingSession{
+		pk:  pk,
+		vk:  vk,
+		ccs: ccs,
+	}
+	if pk != nil {
+		s.curve = pk.CurveID()
+	}
+	return s
+}
+
+// LoadProvingSession reads the curve's pk, vk and ccs from disk in parallel. An empty
+// vkPath produces a prove-only session.
+func LoadProvingSession(curve ecc.ID, pkPath, vkPath, ccsPath string) (*ProvingSession, error) {
+	pk := groth16.NewProvingKey(curve)
+	ccs := groth16.NewCS(curve)
+	var vk groth16.VerifyingKey
+
+	var wg sync.WaitGroup
+	var pkErr, vkErr, ccsErr error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		pkErr = utils.ReadProvingKey(pkPath, pk)
+	}()
+	go func() {
+		defer wg.Done()
+		ccsErr = utils.ReadCcs(ccsPath, ccs)
+	}()
+	if vkPath != "" {
+		vk = groth16.NewVerifyingKey(curve)
+		vkErr = utils.ReadVerifyingKey(vkPath, vk)
+		if vkErr == nil {
+			_, vkErr = utils.CheckKeyFingerprint(pkPath, vk)
+		}
+	}
+	wg.Wait()
+
+	if pkErr != nil {
+		return nil, fmt.Errorf("fail to read proving key: %w", pkErr)
+	}
+	if vkErr != nil {
+		return nil, fmt.Errorf("fail to read verifying key: %w", vkErr)
+	}
+	if ccsErr != nil {
+		return nil, fmt.Errorf("fail to read ccs: %w", ccsErr)
+	}
+	if vk != nil {
+		err := utils.CheckKeyPair(pk, vk)
+		if err != nil {
+			return nil, fmt.Errorf("key mismatch: %w", err)
+		}
+	}
+	session := NewProvingSession(pk, vk, ccs)
+	session.ccsPath = ccsPath
+	return session, nil
+}
+
+// Prove generates a Groth16 proof for fullWitness, returning early with ctx's error
+// if ctx is done first. The proof keeps computing until it finishes, see Wait. It is
+// safe for concurrent use.
+func (s *ProvingSession) Prove(ctx context.Context, fullWitness witness.Witness) (groth16.Proof, error) {
+	hashToField, err := utils.HashToFieldFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if s.keys != nil && s.keys.refused != nil {
+		return nil, s.keys.refused
+	}
+	pf, err := runTracked(ctx, &s.running, func() (groth16.Proof, error) {
+		pk, ccs, err := s.acquireKeys()
+		if err != nil {
+			return nil, err
+		}
+		defer s.releaseKeys()
+		pf, err := groth16.Prove(ccs, pk, fullWitness, backend.WithProverHashToFieldFunction(hashToField))
+		if err != nil && solveDebug() {
+			err = describeUnsatisfied(ccs, err)
+		}
+		return pf, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to prove: %w", err)
+	}
+	return pf, nil
+}
+
+// acquireKeys returns the pk and ccs of a proof, loaded if the session loads them on
+// demand, in which case releaseKeys must be called once the proof is done.
+func (s *ProvingSession) acquireKeys() (groth16.ProvingKey, constraint.ConstraintSystem, error) {
+	if s.keys == nil {
+		return s.pk, s.ccs, nil
+	}
+	return s.keys.cache.acquire(s.keys, s.vk)
+}
+
+func (s *ProvingSession) releaseKeys() {
+	if s.keys != nil {
+		s.keys.cache.release(s.keys)
+	}
+}
+
+// CheckMemory returns ErrInsufficientMemory if the proofs of the session are refused
+// because its keys do not fit in the memory budget of its KeyLoader.
+func (s *ProvingSession) CheckMemory() error {
+	if s.keys != nil {
+		return s.keys.refused
+	}
+	return nil
+}
+
+// Wait blocks until every proof started by Prove has finished computing, including
+// those whose caller returned early on cancellation. The keys are in use until then.
+func (s *ProvingSession) Wait() {
+	s.running.Wait()
+}
+
+// Verify checks proof against the session's verifying key. It is safe for concurrent use.
+func (s *ProvingSession) Verify(proof groth16.Proof, pubWitness witness.Witness) error {
+	if s.vk == nil {
+		return fmt.Errorf("session has no verifying key")
+	}
+	hashToField, err := utils.HashToFieldFromEnv()
+	if err != nil {
+		return err
+	}
+	err = groth16.Verify(proof, s.vk, pubWitness, backend.WithVerifierHashToFieldFunction(hashToField))
+	if err != nil {
+		return fmt.Errorf("failed to verify proof: %w", err)
+	}
+	return nil
+}
+
+// Curve is the curve the session's keys were generated on.
+func (s *ProvingSession) Curve() ecc.ID {
+	return s.curve
+}
+
+// ProvingKey is nil for a session loading its keys on demand, as is Ccs while they are
+// not loaded.
+func (s *ProvingSession) ProvingKey() groth16.ProvingKey {
+	return s.pk
+}
+
+func (s *ProvingSession) VerifyingKey() groth16.VerifyingKey {
+	return s.vk
+}
+
+// CcsPath is the file the ccs of the session is read from, empty if it was compiled.
+func (s *ProvingSession) CcsPath() string {
+	if s.keys != nil {
+		return s.keys.source.CcsPath
+	}
+	return s.ccsPath
+}
+
+func (s *ProvingSession) Ccs() constraint.ConstraintSystem {
+	if s.keys != nil && s.keys.cache != nil {
+		s.keys.cache.mu.Lock()
+		defer s.keys.cache.mu.Unlock()
+		return s.keys.ccs
+	}
+	return s.ccs
+}
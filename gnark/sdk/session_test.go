@@ -0,0 +1,81 @@
+package sdk
+
+import (
+	"context"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"sync"
+	"testing"
+)
+
+type cubicCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (c *cubicCircuit) Define(api frontend.API) error {
+	x3 := api.Mul(c.X, c.X, c.X)
+	api.AssertIsEqual(c.Y, api.Add(x3, c.X, 5))
+	return nil
+}
+
+func TestProvingSessionConcurrentProve(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &cubicCircuit{})
+	assert.NoError(err)
+	pk, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	session := NewProvingSession(pk, vk, ccs)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := 0; i < len(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			x := i + 1
+			fullWitness, err := frontend.NewWitness(&cubicCircuit{X: x, Y: x*x*x + x + 5}, ecc.BN254.ScalarField())
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			pubWitness, err := fullWitness.Public()
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			pf, err := session.Prove(context.Background(), fullWitness)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			errs[i] = session.Verify(pf, pubWitness)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.NoError(err)
+	}
+}
+
+func TestProveCancelled(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &cubicCircuit{})
+	assert.NoError(err)
+	pk, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	session := NewProvingSession(pk, vk, ccs)
+
+	fullWitness, err := frontend.NewWitness(&cubicCircuit{X: 3, Y: 35}, ecc.BN254.ScalarField())
+	assert.NoError(err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = session.Prove(ctx, fullWitness)
+	assert.ErrorIs(err, context.Canceled)
+}
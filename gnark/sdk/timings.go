@@ -0,0 +1,96 @@
+package sdk
+
+import (
+	"encoding/json"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark/constraint"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+// TimingsName is the name of the timings report written in the directory of a proof.
+const TimingsName = "timings.json"
+
+// ProofTimings is the timings report of a proof: the wall time of the phases that ran
+// for it, in milliseconds, and the size of its circuit, so the cost of proving can be
+// tracked across program versions. A phase that did not run for the proof is left
+// out, such as solving for a bundle, or loading the keys for the proofs of a
+// directory, which share the keys loaded once. Loading the keys and compiling the
+// circuit run concurrently, so their durations may overlap.
+type ProofTimings struct {
+	CreatedAt time.Time `json:"created_at"`
+	Curve     string    `json:"curve"`
+	// VkeyHash is the program of the proof, in decimal.
+	VkeyHash  string `json:"vkey_hash,omitempty"`
+	SolveMs   int64  `json:"solve_ms,omitempty"`
+	CompileMs int64  `json:"compile_ms,omitempty"`
+	// LoadMs covers reading the keys, and the ccs unless it was compiled.
+	LoadMs   int64 `json:"load_ms,omitempty"`
+	ProveMs  int64 `json:"prove_ms"`
+	VerifyMs int64 `json:"verify_ms"`
+	// TotalMs is the wall time from the first phase to the proof being verified.
+	TotalMs           int64 `json:"total_ms"`
+	Constraints       int   `json:"constraints,omitempty"`
+	PublicVariables   int   `json:"public_variables,omitempty"`
+	SecretVariables   int   `json:"secret_variables,omitempty"`
+	InternalVariables int   `json:"internal_variables,omitempty"`
+
+	start time.Time
+}
+
+// newProofTimings starts the report of a proof at the current time.
+func newProofTimings() *ProofTimings {
+	return &ProofTimings{start: time.Now()}
+}
+
+// addWallTime runs fn and adds its wall time to *ms.
+func addWallTime(ms *int64, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	*ms += time.Since(start).Milliseconds()
+	return err
+}
+
+// finish records the total time, the size of ccs, which may be nil for sessions
+// loading it on demand, and the vkey hash of public, if its layout is known.
+func (t *ProofTimings) finish(ccs constraint.ConstraintSystem, public []*big.Int) {
+	t.CreatedAt = time.Now().UTC()
+	t.TotalMs = t.CreatedAt.Sub(t.start).Milliseconds()
+	layout, err := utils.PublicLayoutFromEnv()
+	if err == nil {
+		vkeyHash, _, err := layout.Unpack(public)
+		if err == nil {
+			t.VkeyHash = vkeyHash.String()
+		}
+	}
+	if ccs != nil {
+		t.Constraints = ccs.GetNbConstraints()
+		t.PublicVariables = ccs.GetNbPublicVariables()
+		t.SecretVariables = ccs.GetNbSecretVariables()
+		t.InternalVariables = ccs.GetNbInternalVariables()
+	}
+}
+
+// timingsPath is the path of the timings report of the proof at proofPath, a file
+// path or the url of an object.
+func timingsPath(proofPath string) string {
+	return proofPath[:strings.LastIndex(proofPath, "/")+1] + TimingsName
+}
+
+// writeProofTimings writes t next to the proof at proofPath, unless PROOF_TIMINGS=0.
+func writeProofTimings(proofPath string, t *ProofTimings) error {
+	if os.Getenv("PROOF_TIMINGS") == "0" {
+		return nil
+	}
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteArtifact(timingsPath(proofPath), func(w io.Writer) error {
+		_, err := w.Write(append(data, '\n'))
+		return err
+	})
+}
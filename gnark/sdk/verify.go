@@ -0,0 +1,104 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/brevis-network/pico/gnark/verify"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// VerifyProof checks the proof of PROOF_PATH against the vk of VK_PATH with the
+// standalone verifier of package verify, without the pk or the circuit. Legacy proof
+// files do not record the hash to field, HASH_TO_FIELD is used for them.
+func VerifyProof() error {
+	proof, err := verifyProofFile()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("proof verified, %d public inputs:\n", len(proof.PublicInputs))
+	for _, input := range proof.PublicInputs {
+		fmt.Printf("  %s\n", input.String())
+	}
+	if proof.Signature != nil {
+		fmt.Printf("signed by %s prover %s\n", proof.Signature.Scheme, proof.Signature.Signer)
+	}
+	return nil
+}
+
+// verifyProofFile checks the proof of PROOF_PATH against the vk of VK_PATH and
+// returns it.
+func verifyProofFile() (*verify.Proof, error) {
+	vkFile, err := utils.OpenKeyArtifact(os.Getenv("VK_PATH"))
+	if err != nil {
+		return nil, fmt.Errorf("fail to open vk: %w", err)
+	}
+	defer vkFile.Close()
+	vk, err := verify.ReadVerifyingKey(vkFile)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read vk: %w", err)
+	}
+
+	data, err := utils.ReadArtifact(os.Getenv("PROOF_PATH"))
+	if err != nil {
+		return nil, fmt.Errorf("fail to read proof: %w", err)
+	}
+	proof, err := verify.ParseProof(data)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse proof: %w", err)
+	}
+	if proof.HashToField == "" {
+		proof.HashToField = utils.HashToFieldName()
+	}
+	err = verify.Verify(vk, proof)
+	if err != nil {
+		return nil, err
+	}
+	err = checkProofSignature(proof)
+	if err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+// checkProofSignature checks the prover signature of proof, embedded in a json proof
+// or next to the proof file of PROOF_PATH, and with EXPECTED_PROVER, comma separated
+// signers, that it is signed by one of them.
+func checkProofSignature(proof *verify.Proof) error {
+	if proof.Signature == nil {
+		data, err := utils.ReadArtifact(os.Getenv("PROOF_PATH") + utils.SignatureExt)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("fail to read proof signature: %w", err)
+		}
+		if err == nil {
+			proof.Signature = new(verify.ProofSignature)
+			err = json.Unmarshal(data, proof.Signature)
+			if err != nil {
+				return fmt.Errorf("fail to parse proof signature: %w", err)
+			}
+		}
+	}
+	if proof.Signature != nil {
+		err := verify.VerifySignature(proof, proof.Signature)
+		if err != nil {
+			return err
+		}
+	}
+
+	expected := os.Getenv("EXPECTED_PROVER")
+	if expected == "" {
+		return nil
+	}
+	if proof.Signature == nil {
+		return fmt.Errorf("%w: proof is not signed, expected a signature of %s", verify.ErrInvalidSignature, expected)
+	}
+	for _, signer := range strings.Split(expected, ",") {
+		if strings.EqualFold(strings.TrimSpace(signer), proof.Signature.Signer) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: proof signed by %s, expected %s", verify.ErrInvalidSignature, proof.Signature.Signer, expected)
+}
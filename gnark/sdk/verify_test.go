@@ -0,0 +1,73 @@
+package sdk
+
+import (
+	"context"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/brevis-network/pico/gnark/verify"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// publicValuesCircuit has the two public inputs of the wrapper.
+type publicValuesCircuit struct {
+	X                     frontend.Variable
+	VkeyHash              frontend.Variable `gnark:",public"`
+	CommittedValuesDigest frontend.Variable `gnark:",public"`
+}
+
+func (c *publicValuesCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(c.VkeyHash, api.Mul(c.X, c.X))
+	api.AssertIsEqual(c.CommittedValuesDigest, api.Add(c.X, 1))
+	return nil
+}
+
+func TestVerifyProof(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &publicValuesCircuit{})
+	assert.NoError(err)
+	pk, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	fullWitness, err := frontend.NewWitness(&publicValuesCircuit{X: 3, VkeyHash: 9, CommittedValuesDigest: 4}, ecc.BN254.ScalarField())
+	assert.NoError(err)
+	pubWitness, err := fullWitness.Public()
+	assert.NoError(err)
+	pf, err := NewProvingSession(pk, vk, ccs).Prove(context.Background(), fullWitness)
+	assert.NoError(err)
+
+	dir := t.TempDir()
+	vkPath, proofPath := filepath.Join(dir, "vm_vk"), filepath.Join(dir, "proof.data")
+	assert.NoError(utils.WriteVerifyingKey(vkPath, vk))
+	data, err := utils.FormatProof(utils.ProofFormatLegacy, pf, pubWitness)
+	assert.NoError(err)
+	assert.NoError(os.WriteFile(proofPath, data, 0644))
+	t.Setenv("VK_PATH", vkPath)
+	t.Setenv("PROOF_PATH", proofPath)
+	assert.NoError(VerifyProof())
+	t.Setenv("EXPECTED_PROVER", "0x2c7536e3605d9c16a7a3d7b1898e529396a65c23")
+	assert.ErrorIs(VerifyProof(), verify.ErrInvalidSignature, "unsigned")
+
+	// the signature of a legacy proof is in a file next to it
+	keyPath := filepath.Join(dir, "prover_key")
+	assert.NoError(os.WriteFile(keyPath, []byte("0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"), 0600))
+	t.Setenv("PROVER_KEY_FILE", keyPath)
+	t.Setenv("PROVER_KEY_SCHEME", utils.SignatureSecp256k1)
+	assert.NoError(writeProofSignature(proofPath, pf, pubWitness))
+	assert.NoError(VerifyProof())
+	t.Setenv("EXPECTED_PROVER", "0xd75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a")
+	assert.ErrorIs(VerifyProof(), verify.ErrInvalidSignature, "signed by another prover")
+	t.Setenv("EXPECTED_PROVER", "")
+
+	// the legacy format ends with the public inputs, 9 and 4
+	text := strings.TrimSpace(string(data))
+	assert.True(strings.HasSuffix(text, "4"), text)
+	assert.NoError(os.WriteFile(proofPath, []byte(text[:len(text)-1]+"5"), 0644))
+	assert.Error(VerifyProof())
+}
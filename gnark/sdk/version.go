@@ -0,0 +1,39 @@
+package sdk
+
+import (
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark/frontend"
+)
+
+// circuitBuilder builds the circuit and assignment of a parsed witness, single or
+// multi-chunk as returned by utils.ParseWitness.
+type circuitBuilder func(single *utils.WitnessInput, multi *utils.MultiWitnessInput) (circuit frontend.Circuit, assigment frontend.Circuit, err error)
+
+// circuitVersions are the circuit constructions of the witness versions this build
+// proves, per field. A circuit shape change adds an entry for the new
+// utils.WitnessVersion and keeps the previous ones.
+var circuitVersions = map[string]utils.CircuitVersions[circuitBuilder]{
+	"kb": {1: newKoalaBearCircuitsV1},
+	"bb": {1: newBabyBearCircuitsV1},
+}
+
+// buildCircuits builds the circuits of a parsed witness with the construction of its
+// format version.
+func buildCircuits(field string, single *utils.WitnessInput, multi *utils.MultiWitnessInput) (circuit frontend.Circuit, assigment frontend.Circuit, err error) {
+	versions, ok := circuitVersions[field]
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid field: %s", field)
+	}
+	var version int
+	if single != nil {
+		version = single.FormatVersion()
+	} else {
+		version = multi.FormatVersion()
+	}
+	build, err := versions.Select(version)
+	if err != nil {
+		return nil, nil, err
+	}
+	return build(single, multi)
+}
@@ -0,0 +1,25 @@
+package sdk
+
+import (
+	"github.com/brevis-network/pico/gnark/babybear_verifier"
+	"github.com/brevis-network/pico/gnark/koalabear_verifier"
+	"github.com/consensys/gnark/test"
+	"testing"
+)
+
+func TestBuildCircuitsVersion(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// witnesses without version are version 1
+	circuit, _, err := newKoalaBearCircuits([]byte(`{"vars": ["1"], "vkey_hash": "1", "committed_values_digest": "2"}`))
+	assert.NoError(err)
+	assert.IsType(&koalabear_verifier.Circuit{}, circuit)
+	circuit, _, err = newBabyBearCircuits([]byte(`{"version": 1, "vars": ["1"], "vkey_hash": "1", "committed_values_digest": "2"}`))
+	assert.NoError(err)
+	assert.IsType(&babybear_verifier.Circuit{}, circuit)
+
+	_, _, err = newKoalaBearCircuits([]byte(`{"version": 2, "vkey_hash": "1", "committed_values_digest": "2"}`))
+	assert.ErrorContains(err, "unsupported witness version 2, this build supports [1]")
+	_, _, err = newBabyBearCircuits([]byte(`{"chunks": [{"version": 9, "vkey_hash": "1", "committed_values_digest": "2"}]}`))
+	assert.ErrorContains(err, "unsupported witness version 9")
+}
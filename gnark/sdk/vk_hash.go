@@ -0,0 +1,61 @@
+package sdk
+
+import (
+	"fmt"
+	"github.com/brevis-network/pico/gnark/onchain"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark/backend/groth16"
+	"io"
+	"math/big"
+	"os"
+)
+
+// PrintVkHash writes the keccak256 of the vk at VK_PATH, as the PICO_VK_HASH constant
+// of the exported verifier, and of the vk file, decrypted, as bytes32 the gateway
+// contracts compare. With PROGRAM_VKEY, the program vkey hash of a Pico program,
+// decimal as in the witness or 0x prefixed hex, it also writes that hash as the
+// bytes32 vkeyHash of IPicoVerifier.verify.
+func PrintVkHash(w io.Writer) error {
+	curve, err := utils.CurveFromEnv()
+	if err != nil {
+		return err
+	}
+	vk := groth16.NewVerifyingKey(curve)
+	err = utils.ReadVerifyingKey(os.Getenv("VK_PATH"), vk)
+	if err != nil {
+		return fmt.Errorf("failed to read verifying key: %w", err)
+	}
+	vkHash, err := onchain.VkHash(vk)
+	if err != nil {
+		return err
+	}
+	// the hash of an encrypted vk is of its plaintext, as served to the gateway
+	vkFile, err := utils.OpenKeyArtifact(os.Getenv("VK_PATH"))
+	if err != nil {
+		return err
+	}
+	defer vkFile.Close()
+	data, err := io.ReadAll(vkFile)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "vk keccak256: %s\n", vkHash)
+	fmt.Fprintf(w, "vk file keccak256: %s\n", onchain.EncodeHex(onchain.Keccak256(data)))
+
+	programVkey := os.Getenv("PROGRAM_VKEY")
+	if programVkey == "" {
+		return nil
+	}
+	vkeyHash, err := onchain.Bytes32(programVkey)
+	if err != nil {
+		return fmt.Errorf("invalid program vkey hash: %w", err)
+	}
+	// the wrapper takes the vkey hash as a public input, which the verifier rejects
+	// outside the scalar field
+	n, _ := new(big.Int).SetString(programVkey, 0)
+	if n.Cmp(curve.ScalarField()) >= 0 {
+		return fmt.Errorf("program vkey hash %s is not in the %s scalar field", vkeyHash, curve)
+	}
+	fmt.Fprintf(w, "program vkey hash: %s\n", vkeyHash)
+	return nil
+}
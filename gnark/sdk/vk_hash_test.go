@@ -0,0 +1,45 @@
+package sdk
+
+import (
+	"bytes"
+	"github.com/brevis-network/pico/gnark/onchain"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrintVkHash(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &cubicCircuit{})
+	assert.NoError(err)
+	_, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	path := filepath.Join(t.TempDir(), "vm_vk")
+	assert.NoError(utils.WriteVerifyingKey(path, vk))
+	t.Setenv("VK_PATH", path)
+
+	var out bytes.Buffer
+	assert.NoError(PrintVkHash(&out))
+	vkHash, err := onchain.VkHash(vk)
+	assert.NoError(err)
+	data, err := os.ReadFile(path)
+	assert.NoError(err)
+	assert.Equal("vk keccak256: "+vkHash+"\nvk file keccak256: "+onchain.EncodeHex(onchain.Keccak256(data))+"\n", out.String())
+
+	t.Setenv("PROGRAM_VKEY", "255")
+	out.Reset()
+	assert.NoError(PrintVkHash(&out))
+	assert.Contains(out.String(), "program vkey hash: 0x00000000000000000000000000000000000000000000000000000000000000ff\n")
+
+	t.Setenv("PROGRAM_VKEY", ecc.BN254.ScalarField().String())
+	assert.ErrorContains(PrintVkHash(&out), "not in the bn254 scalar field")
+	t.Setenv("PROGRAM_VKEY", "vk")
+	assert.ErrorContains(PrintVkHash(&out), "invalid program vkey hash")
+}
@@ -0,0 +1,143 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaultWatchInterval is the polling interval of WatchDir without WATCH_INTERVAL.
+const defaultWatchInterval = 2 * time.Second
+
+// fileStamp identifies a version of a witness file.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// dirWatcher is the state of WatchDir between polls.
+type dirWatcher struct {
+	session             *ProvingSession
+	field               string
+	inputDir, outputDir string
+	pattern, proofName  string
+	// seen holds the witnesses of the previous poll not proved yet, proved once they
+	// did not change over a poll, failed those that failed to prove, retried when
+	// they change.
+	seen, failed map[string]fileStamp
+}
+
+// WatchDir proves the witness files the rust prover drops under INPUT_DIR, as
+// ProveDir, polling it every WATCH_INTERVAL (2s by default) until ctx is done. A
+// witness is proved once its size and modification time did not change over a poll,
+// so files still being written are not read, and only if its proof is not in
+// OUTPUT_DIR yet, so a restarted watch resumes where it stopped. A failed witness is
+// retried when its file changes.
+func WatchDir(ctx context.Context, field string) error {
+	interval := defaultWatchInterval
+	if v := os.Getenv("WATCH_INTERVAL"); v != "" {
+		var err error
+		interval, err = time.ParseDuration(v)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("invalid WATCH_INTERVAL %q", v)
+		}
+	}
+	inputDir := os.Getenv("INPUT_DIR")
+	_, err := findWitnesses(inputDir, os.Getenv("WITNESS_PATTERN"))
+	if err != nil {
+		return err
+	}
+	curve, err := utils.CurveFromEnv()
+	if err != nil {
+		return err
+	}
+	err = checkBeforeProve(curve, os.Getenv("CCS_PATH"))
+	if err != nil {
+		return err
+	}
+	session, err := LoadProvingSession(curve, os.Getenv("PK_PATH"), os.Getenv("VK_PATH"), os.Getenv("CCS_PATH"))
+	if err != nil {
+		return err
+	}
+	w := newDirWatcher(session, field, inputDir, os.Getenv("OUTPUT_DIR"), os.Getenv("WITNESS_PATTERN"))
+
+	fmt.Printf("watching %s for %s every %v\n", inputDir, w.pattern, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		w.poll(ctx)
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.Canceled {
+				return nil
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func newDirWatcher(session *ProvingSession, field, inputDir, outputDir, pattern string) *dirWatcher {
+	proofName := "proof.data"
+	if os.Getenv("PROOF_FORMAT") == utils.ProofFormatJson {
+		proofName = "proof.json"
+	}
+	return &dirWatcher{
+		session:   session,
+		field:     field,
+		inputDir:  inputDir,
+		outputDir: outputDir,
+		pattern:   pattern,
+		proofName: proofName,
+		seen:      make(map[string]fileStamp),
+		failed:    make(map[string]fileStamp),
+	}
+}
+
+// poll proves the witnesses that are ready, and returns how many it proved.
+func (w *dirWatcher) poll(ctx context.Context) int {
+	witnesses, err := findWitnesses(w.inputDir, w.pattern)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return 0
+	}
+	proved := 0
+	seen := make(map[string]fileStamp)
+	for _, rel := range witnesses {
+		if ctx.Err() != nil {
+			break
+		}
+		proof := filepath.Join(filepath.Dir(rel), w.proofName)
+		if _, err := os.Stat(filepath.Join(w.outputDir, proof)); err == nil {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(w.inputDir, rel))
+		if err != nil {
+			continue
+		}
+		stamp := fileStamp{size: info.Size(), modTime: info.ModTime()}
+		if stamp == w.failed[rel] {
+			continue
+		}
+		if stamp != w.seen[rel] {
+			seen[rel] = stamp
+			continue
+		}
+
+		start := time.Now()
+		err = proveIntakeWitness(ctx, w.session, w.field, filepath.Join(w.inputDir, rel), filepath.Join(w.outputDir, proof))
+		if err != nil {
+			w.failed[rel] = stamp
+			fmt.Printf("%s failed: %v\n", rel, err)
+			continue
+		}
+		delete(w.failed, rel)
+		proved++
+		fmt.Printf("%s proved in %v, written to %s\n", rel, time.Since(start).Round(time.Millisecond), proof)
+	}
+	w.seen = seen
+	return proved
+}
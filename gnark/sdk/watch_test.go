@@ -0,0 +1,44 @@
+package sdk
+
+import (
+	"context"
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDirWatcherPoll(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	dir := t.TempDir()
+	input, output := filepath.Join(dir, "in"), filepath.Join(dir, "out")
+	w := newDirWatcher(NewProvingSession(nil, nil, nil), "kb", input, output, "groth16_witness.json")
+	ctx := context.Background()
+	write := func(name, data string, modTime time.Time) {
+		path := filepath.Join(input, name, "groth16_witness.json")
+		assert.NoError(os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(os.WriteFile(path, []byte(data), 0644))
+		assert.NoError(os.Chtimes(path, modTime, modTime))
+	}
+
+	// a witness is attempted once unchanged over a poll
+	now := time.Now()
+	write("proof1", "not json", now)
+	assert.Equal(0, w.poll(ctx))
+	assert.Empty(w.failed)
+	assert.Equal(0, w.poll(ctx))
+	assert.Contains(w.failed, "proof1/groth16_witness.json")
+
+	// a failed witness is retried when it changes
+	write("proof1", "still not json", now.Add(time.Second))
+	w.poll(ctx)
+	assert.Contains(w.seen, "proof1/groth16_witness.json")
+
+	// witnesses already proved are skipped
+	assert.NoError(os.MkdirAll(filepath.Join(output, "proof1"), 0755))
+	assert.NoError(os.WriteFile(filepath.Join(output, "proof1", "proof.data"), []byte("proof"), 0644))
+	w.poll(ctx)
+	assert.Empty(w.seen)
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/brevis-network/pico/gnark/server/audit"
+	"github.com/brevis-network/pico/gnark/server/jobs"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/celer-network/goutils/log"
+	"github.com/labstack/echo"
+	"net/http"
+	"time"
+)
+
+// auditLog records every proof served, nil without -audit-log.
+var auditLog *audit.Log
+
+// recordProve records the outcome of a proof requested on /prove, coalesced if the
+// request waited for the proof of another.
+func recordProve(c echo.Context, t *tenant, payload []byte, duration time.Duration, signature *utils.ProofSignature, coalesced bool, proveErr error) {
+	if auditLog == nil {
+		return
+	}
+	client, _ := c.Get(clientKey).(string)
+	entry := audit.Entry{
+		Kind:        audit.KindProve,
+		Tenant:      t.config.Name,
+		Client:      client,
+		RemoteAddr:  c.Request().RemoteAddr,
+		WitnessHash: audit.WitnessHash(payload),
+		VkeyHash:    witnessVkeyHash(payload),
+		DurationMs:  duration.Milliseconds(),
+		Coalesced:   coalesced,
+	}
+	if proveErr != nil {
+		entry.Result, entry.Error = audit.Failed, proveErr.Error()
+	}
+	record(entry, signature)
+}
+
+// recordJob records the outcome of a finished job.
+func recordJob(job jobs.Job) {
+	if auditLog == nil {
+		return
+	}
+	entry := audit.Entry{
+		Kind:        audit.KindJob,
+		Job:         job.ID,
+		Tenant:      job.Tenant,
+		Client:      job.Client,
+		WitnessHash: job.WitnessHash,
+		VkeyHash:    job.VkeyHash,
+		DurationMs:  job.Duration().Milliseconds(),
+		Attempts:    job.Attempts,
+		Coalesced:   job.CoalescedWith != "",
+	}
+	if job.Status == jobs.Failed {
+		entry.Result, entry.Error = audit.Failed, job.Error
+	}
+	record(entry, job.Signature)
+}
+
+// record appends entry, succeeded unless it has a result, with the signer of its proof.
+func record(entry audit.Entry, signature *utils.ProofSignature) {
+	if entry.Result == "" {
+		entry.Result = audit.Succeeded
+	}
+	if signature != nil {
+		entry.Signer = signature.Signer
+	}
+	// the proof is served even if it can not be recorded, the operator is alerted
+	err := auditLog.Record(entry)
+	if err != nil {
+		log.Errorf("fail to record audit entry, err: %v", err)
+	}
+}
+
+// witnessVkeyHash returns the vkey hash of a witness json, single or multi-chunk,
+// without decoding its values.
+func witnessVkeyHash(data []byte) string {
+	var witness struct {
+		VkeyHash string `json:"vkey_hash"`
+		Chunks   []struct {
+			VkeyHash string `json:"vkey_hash"`
+		} `json:"chunks"`
+	}
+	if json.Unmarshal(data, &witness) != nil {
+		return ""
+	}
+	if len(witness.Chunks) > 0 {
+		return witness.Chunks[0].VkeyHash
+	}
+	return witness.VkeyHash
+}
+
+// ExportAudit answers with the audit log as json lines, optionally filtered by
+// ?since= and ?until=, RFC 3339 times, ?tenant= and ?client=.
+func ExportAudit(c echo.Context) error {
+	if auditLog == nil {
+		return c.String(http.StatusNotFound, "no audit log, start the server with -audit-log")
+	}
+	filter := audit.Filter{Tenant: c.QueryParam("tenant"), Client: c.QueryParam("client")}
+	for param, at := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		s := c.QueryParam(param)
+		if s == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return c.String(http.StatusBadRequest, "invalid "+param+", expected an RFC 3339 time")
+		}
+		*at = t
+	}
+	c.Response().Header().Set(echo.HeaderContentType, "application/x-ndjson")
+	c.Response().WriteHeader(http.StatusOK)
+	return auditLog.Export(c.Response(), filter)
+}
@@ -0,0 +1,204 @@
+// Package audit keeps an append-only log of the proving activity of the prover
+// service, so the operators of shared proving infrastructure can tell who proved what,
+// when, and with which outcome.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Kinds of requests recorded.
+const (
+	// KindProve is a proof requested on /prove and answered in the request.
+	KindProve = "prove"
+	// KindJob is a proof job submitted to /jobs, recorded once finished.
+	KindJob = "job"
+)
+
+// Results of the requests recorded.
+const (
+	Succeeded = "succeeded"
+	Failed    = "failed"
+)
+
+// Entry is one line of the audit log.
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"`
+	Job    string    `json:"job,omitempty"`
+	Tenant string    `json:"tenant,omitempty"`
+	// Client is the name of the api key of the request, empty without api keys.
+	Client string `json:"client,omitempty"`
+	// RemoteAddr is the address the request came from.
+	RemoteAddr  string `json:"remote_addr,omitempty"`
+	WitnessHash string `json:"witness_hash"`
+	VkeyHash    string `json:"vkey_hash,omitempty"`
+	// DurationMs is the time the proof took, for a job its last attempt.
+	DurationMs int64  `json:"duration_ms"`
+	Attempts   int    `json:"attempts,omitempty"`
+	Result     string `json:"result"`
+	Error      string `json:"error,omitempty"`
+	// Signer is the prover key that signed the proof, if the server has one.
+	Signer string `json:"signer,omitempty"`
+	// Coalesced is set when the proof was not proved for this request but shared from
+	// one of the same witness in flight.
+	Coalesced bool `json:"coalesced,omitempty"`
+}
+
+// WitnessHash is the 0x prefixed hex sha256 of a witness as submitted, identifying it
+// in the log without storing it.
+func WitnessHash(witness []byte) string {
+	hash := sha256.Sum256(witness)
+	return "0x" + hex.EncodeToString(hash[:])
+}
+
+// Log appends entries as json lines to a file. It only ever appends: an entry written
+// is never rewritten, and every entry is synced before Record returns. A nil Log
+// records nothing.
+type Log struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// Open opens the log at path for appending, creating it and its directory if needed.
+// A last line cut short by a crash, whose Record never returned, is dropped so the
+// next entry starts on its own line.
+func Open(path string) (*Log, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("fail to open audit log: %v", err)
+	}
+	err = dropPartialLine(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("fail to open audit log: %v", err)
+	}
+	return &Log{path: path, f: f}, nil
+}
+
+// dropPartialLine truncates f after its last newline.
+func dropPartialLine(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	buf := make([]byte, 4096)
+	for end := size; end > 0; {
+		start := max(end-int64(len(buf)), 0)
+		n, err := f.ReadAt(buf[:end-start], start)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		for i := n - 1; i >= 0; i-- {
+			if buf[i] == '\n' {
+				if start+int64(i)+1 == size {
+					return nil
+				}
+				return f.Truncate(start + int64(i) + 1)
+			}
+		}
+		end = start
+	}
+	if size == 0 {
+		return nil
+	}
+	return f.Truncate(0)
+}
+
+// Record appends e, stamped with the current time if it has none.
+func (l *Log) Record(e Entry) error {
+	if l == nil {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.f.Write(line)
+	if err != nil {
+		return fmt.Errorf("fail to write audit log: %v", err)
+	}
+	return l.f.Sync()
+}
+
+// Close closes the log file.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+// Filter selects the entries of Export, zero fields select all.
+type Filter struct {
+	Since  time.Time
+	Until  time.Time
+	Tenant string
+	Client string
+}
+
+func (f Filter) match(e *Entry) bool {
+	return (f.Since.IsZero() || !e.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || e.Time.Before(f.Until)) &&
+		(f.Tenant == "" || e.Tenant == f.Tenant) &&
+		(f.Client == "" || e.Client == f.Client)
+}
+
+// Export writes the entries of the log matching filter to w as json lines, oldest
+// first. An entry still being written is left out.
+func (l *Log) Export(w io.Writer, filter Filter) error {
+	f, err := os.Open(l.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var e Entry
+		err = json.Unmarshal(line, &e)
+		if err != nil {
+			return fmt.Errorf("fail to parse audit log: %v", err)
+		}
+		if !filter.match(&e) {
+			continue
+		}
+		_, err = w.Write(line)
+		if err != nil {
+			return err
+		}
+	}
+}
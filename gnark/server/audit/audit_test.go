@@ -0,0 +1,65 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLog(t *testing.T) {
+	assert := test.NewAssert(t)
+	path := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+
+	log, err := Open(path)
+	assert.NoError(err)
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	entries := []Entry{
+		{Time: start, Kind: KindProve, Client: "alice", WitnessHash: WitnessHash([]byte("w1")), Result: Succeeded},
+		{Time: start.Add(time.Hour), Kind: KindJob, Job: "j1", Tenant: "acme", Client: "bob", WitnessHash: WitnessHash([]byte("w2")), Result: Failed, Error: "unsatisfied constraint"},
+	}
+	for _, e := range entries {
+		assert.NoError(log.Record(e))
+	}
+	assert.NoError(log.Close())
+
+	// a crash while writing leaves a partial line, dropped when reopened
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	assert.NoError(err)
+	_, err = f.WriteString(`{"time":"2026-01-02T`)
+	assert.NoError(err)
+	assert.NoError(f.Close())
+	log, err = Open(path)
+	assert.NoError(err)
+	defer log.Close()
+	entries = append(entries, Entry{Time: start.Add(2 * time.Hour), Kind: KindProve, Client: "alice", WitnessHash: WitnessHash([]byte("w3")), Result: Succeeded})
+	assert.NoError(log.Record(entries[2]))
+
+	export := func(filter Filter) []Entry {
+		var buf bytes.Buffer
+		assert.NoError(log.Export(&buf, filter))
+		var exported []Entry
+		for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+			if line == "" {
+				continue
+			}
+			var e Entry
+			assert.NoError(json.Unmarshal([]byte(line), &e))
+			exported = append(exported, e)
+		}
+		return exported
+	}
+	assert.Equal(entries, export(Filter{}))
+	assert.Equal([]Entry{entries[0], entries[2]}, export(Filter{Client: "alice"}))
+	assert.Equal(entries[1:2], export(Filter{Tenant: "acme"}))
+	assert.Equal(entries[1:], export(Filter{Since: start.Add(time.Hour)}))
+	assert.Equal(entries[:1], export(Filter{Until: start.Add(time.Hour)}))
+
+	var nilLog *Log
+	assert.NoError(nilLog.Record(entries[0]))
+	assert.Equal("0xe3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", WitnessHash(nil))
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"github.com/labstack/echo"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clientKey is the context key holding the name of the authenticated client.
+const clientKey = "client"
+
+type apiKey struct {
+	client string
+	key    []byte
+}
+
+// loadAPIKeys reads one key per line, optionally preceded by the client name and a
+// space. Empty lines and lines starting with # are skipped.
+//
+// Unnamed keys are named after a hash of the key, see apiKeyName, so that the client of
+// a key, which owns its jobs, does not change when the file is edited.
+func loadAPIKeys(path string) ([]apiKey, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var keys []apiKey
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		switch len(fields) {
+		case 1:
+			keys = append(keys, apiKey{client: apiKeyName(fields[0]), key: []byte(fields[0])})
+		case 2:
+			keys = append(keys, apiKey{client: fields[0], key: []byte(fields[1])})
+		default:
+			return nil, fmt.Errorf("invalid api key at line %d", line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no api key in %s", path)
+	}
+	return keys, nil
+}
+
+// apiKeyName names an unnamed key after the first 8 bytes of its sha256, which does not
+// reveal the key.
+func apiKeyName(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return "key-" + hex.EncodeToString(hash[:8])
+}
+
+// requireAPIKey accepts requests carrying one of keys as "Authorization: Bearer <key>"
+// or "X-API-Key: <key>", and records the client name for rate limiting.
+func requireAPIKey(keys []apiKey) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			presented := c.Request().Header.Get("X-API-Key")
+			if auth := c.Request().Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+				presented = strings.TrimPrefix(auth, "Bearer ")
+			}
+			client := ""
+			// compare with every key so the time taken does not reveal which one matched
+			for _, k := range keys {
+				if subtle.ConstantTimeCompare([]byte(presented), k.key) == 1 {
+					client = k.client
+				}
+			}
+			if client == "" {
+				return c.String(http.StatusUnauthorized, "missing or invalid api key")
+			}
+			c.Set(clientKey, client)
+			return next(c)
+		}
+	}
+}
+
+// rateLimiter is a token bucket per client: each client may send burst requests at
+// once and perMinute requests per minute on average.
+type rateLimiter struct {
+	perMinute float64
+	burst     float64
+	// trustProxy takes the client IP of anonymous requests from X-Forwarded-For or
+	// X-Real-IP, which any client can set unless a proxy in front overwrites them.
+	trustProxy bool
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(perMinute float64, burst int, trustProxy bool) *rateLimiter {
+	return &rateLimiter{
+		perMinute:  perMinute,
+		burst:      math.Max(float64(burst), 1),
+		trustProxy: trustProxy,
+		buckets:    make(map[string]*bucket),
+	}
+}
+
+// refillTime is how long an empty bucket takes to fill up again.
+func (l *rateLimiter) refillTime() time.Duration {
+	return time.Duration(l.burst / l.perMinute * float64(time.Minute))
+}
+
+// allow takes a token from client's bucket, or returns how long to wait for one.
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(now)
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Minutes()*l.perMinute)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.perMinute * float64(time.Minute))
+	return false, wait
+}
+
+// prune drops the buckets idle long enough to be full again, which behave like the
+// fresh bucket allow creates, so the map only holds recently seen clients. It scans
+// the map at most once per refill time.
+func (l *rateLimiter) prune(now time.Time) {
+	idle := l.refillTime()
+	if now.Sub(l.lastPrune) < idle {
+		return
+	}
+	l.lastPrune = now
+	for client, b := range l.buckets {
+		if now.Sub(b.last) >= idle {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// clientID names the sender of c: its api key name, or its IP without api keys.
+func (l *rateLimiter) clientID(c echo.Context) string {
+	client, _ := c.Get(clientKey).(string)
+	if client != "" {
+		return "key:" + client
+	}
+	if l.trustProxy {
+		return "ip:" + c.RealIP()
+	}
+	host, _, err := net.SplitHostPort(c.Request().RemoteAddr)
+	if err != nil {
+		host = c.Request().RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// middleware limits requests per authenticated client, or per IP without api keys.
+func (l *rateLimiter) middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ok, wait := l.allow(l.clientID(c), time.Now())
+		if !ok {
+			c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			return c.String(http.StatusTooManyRequests, "rate limit exceeded")
+		}
+		return next(c)
+	}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"github.com/consensys/gnark/test"
+	"github.com/labstack/echo"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadAPIKeys(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	path := filepath.Join(t.TempDir(), "keys.txt")
+	assert.NoError(os.WriteFile(path, []byte("# clients\nalice secret-a\n\nsecret-b\n"), 0600))
+	keys, err := loadAPIKeys(path)
+	assert.NoError(err)
+	assert.Len(keys, 2)
+	assert.Equal("alice", keys[0].client)
+	assert.Equal(apiKeyName("secret-b"), keys[1].client)
+	assert.Equal("key-", keys[1].client[:4])
+
+	// the name of an unnamed key does not depend on its line
+	assert.NoError(os.WriteFile(path, []byte("secret-b\nalice secret-a\n"), 0600))
+	reordered, err := loadAPIKeys(path)
+	assert.NoError(err)
+	assert.Equal(keys[1].client, reordered[0].client)
+
+	assert.NoError(os.WriteFile(path, []byte("a b c\n"), 0600))
+	_, err = loadAPIKeys(path)
+	assert.Error(err)
+	assert.NoError(os.WriteFile(path, []byte("# none\n"), 0600))
+	_, err = loadAPIKeys(path)
+	assert.Error(err)
+}
+
+// serve runs a request through middlewares to a handler answering with the client.
+func serve(req *http.Request, middlewares ...echo.MiddlewareFunc) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.POST("/prove", func(c echo.Context) error {
+		client, _ := c.Get(clientKey).(string)
+		return c.String(http.StatusOK, client)
+	}, middlewares...)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRequireAPIKey(t *testing.T) {
+	assert := test.NewAssert(t)
+	auth := requireAPIKey([]apiKey{{client: "alice", key: []byte("secret-a")}, {client: "bob", key: []byte("secret-b")}})
+
+	req := httptest.NewRequest(http.MethodPost, "/prove", nil)
+	req.Header.Set("Authorization", "Bearer secret-b")
+	rec := serve(req, auth)
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Equal("bob", rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodPost, "/prove", nil)
+	req.Header.Set("X-API-Key", "secret-a")
+	rec = serve(req, auth)
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Equal("alice", rec.Body.String())
+
+	for _, key := range []string{"", "secret", "secret-a "} {
+		req = httptest.NewRequest(http.MethodPost, "/prove", nil)
+		req.Header.Set("X-API-Key", key)
+		assert.Equal(http.StatusUnauthorized, serve(req, auth).Code, key)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	assert := test.NewAssert(t)
+	limiter := newRateLimiter(60, 2, false)
+	now := time.Now()
+
+	ok, _ := limiter.allow("a", now)
+	assert.True(ok)
+	ok, _ = limiter.allow("a", now)
+	assert.True(ok)
+	ok, wait := limiter.allow("a", now)
+	assert.False(ok)
+	assert.Equal(time.Second, wait)
+	ok, _ = limiter.allow("b", now)
+	assert.True(ok, "clients have their own bucket")
+
+	ok, _ = limiter.allow("a", now.Add(time.Second))
+	assert.True(ok, "a token is back after a second")
+
+	// idle clients are dropped once their bucket is full again
+	ok, _ = limiter.allow("c", now.Add(time.Hour))
+	assert.True(ok)
+	assert.Len(limiter.buckets, 1)
+}
+
+func TestRateLimiterClientID(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	request := func(forwarded string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/prove", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", forwarded)
+		return req
+	}
+
+	// without a trusted proxy a spoofed X-Forwarded-For does not get a fresh bucket
+	limiter := newRateLimiter(1, 1, false)
+	assert.Equal(http.StatusOK, serve(request("1.1.1.1"), limiter.middleware).Code)
+	rec := serve(request("2.2.2.2"), limiter.middleware)
+	assert.Equal(http.StatusTooManyRequests, rec.Code)
+	assert.Equal("60", rec.Header().Get("Retry-After"))
+
+	limiter = newRateLimiter(1, 1, true)
+	assert.Equal(http.StatusOK, serve(request("1.1.1.1"), limiter.middleware).Code)
+	assert.Equal(http.StatusOK, serve(request("2.2.2.2"), limiter.middleware).Code)
+
+	// authenticated clients are told by their key, whatever their address
+	limiter = newRateLimiter(1, 1, false)
+	auth := requireAPIKey([]apiKey{{client: "alice", key: []byte("a")}, {client: "bob", key: []byte("b")}})
+	for _, key := range []string{"a", "b"} {
+		req := request("")
+		req.Header.Set("X-API-Key", key)
+		assert.Equal(http.StatusOK, serve(req, auth, limiter.middleware).Code, key)
+	}
+}
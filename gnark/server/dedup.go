@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/brevis-network/pico/gnark/server/audit"
+	"github.com/brevis-network/pico/gnark/utils"
+	"sync"
+)
+
+var errTenantBusy = errors.New("tenant busy")
+
+// inflightProof is a proof on /prove that requests of the same witness wait for
+// instead of proving it again.
+type inflightProof struct {
+	done      chan struct{}
+	res       *utils.OnChainProof
+	signature *utils.ProofSignature
+	err       error
+}
+
+var (
+	inflightMu     sync.Mutex
+	inflightProofs = map[string]*inflightProof{}
+)
+
+// inflightKey identifies a witness of a tenant, the same bytes proved with the same
+// keys give the same proof.
+func inflightKey(t *tenant, payload []byte) string {
+	return t.config.Name + "\x00" + audit.WitnessHash(payload)
+}
+
+// joinProof returns the proof in flight for key, leader if there was none and the
+// caller has to prove it and then call finishProof.
+func joinProof(key string) (p *inflightProof, leader bool) {
+	inflightMu.Lock()
+	defer inflightMu.Unlock()
+	p, ok := inflightProofs[key]
+	if ok {
+		return p, false
+	}
+	p = &inflightProof{done: make(chan struct{})}
+	inflightProofs[key] = p
+	return p, true
+}
+
+// finishProof hands the outcome of p to its followers, a later request proves again.
+func finishProof(key string, p *inflightProof) {
+	inflightMu.Lock()
+	delete(inflightProofs, key)
+	inflightMu.Unlock()
+	close(p.done)
+}
+
+// proveShared proves payload in a slot of t, for the leader of a proof in flight.
+// gnark can not stop a running proof, so the proof is not cancelled with the request:
+// it keeps its slot on the keys until done, and its followers still get it.
+func proveShared(ctx context.Context, t *tenant, payload []byte) (*utils.OnChainProof, *utils.ProofSignature, error) {
+	if !t.tryAcquire() {
+		return nil, nil, errTenantBusy
+	}
+	defer t.release()
+	pf, pubWitness, err := t.prove(context.WithoutCancel(ctx), payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signedOnChainProof(pf, pubWitness)
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"errors"
+	"github.com/consensys/gnark/test"
+	"testing"
+)
+
+func TestJoinProof(t *testing.T) {
+	assert := test.NewAssert(t)
+	acme, beta := newTenant(TenantConfig{Name: "acme"}), newTenant(TenantConfig{Name: "beta"})
+	payload := []byte(`{"vkey_hash":"0x1"}`)
+
+	key := inflightKey(acme, payload)
+	assert.NotEqual(key, inflightKey(beta, payload))
+	leader, ok := joinProof(key)
+	assert.True(ok)
+	follower, ok := joinProof(key)
+	assert.False(ok)
+	assert.True(leader == follower)
+
+	leader.err = errors.New("unsatisfied constraint")
+	finishProof(key, leader)
+	<-follower.done
+	assert.Equal(leader.err, follower.err)
+
+	// the next request proves again
+	next, ok := joinProof(key)
+	assert.True(ok)
+	assert.False(next == leader)
+	finishProof(key, next)
+}
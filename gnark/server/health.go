@@ -0,0 +1,28 @@
+package server
+
+import (
+	"github.com/labstack/echo"
+	"net/http"
+	"sync/atomic"
+)
+
+// accepting is set once the keys are loaded and cleared when the server starts
+// shutting down.
+var accepting atomic.Bool
+
+// Healthz reports that the process is up, whether or not it can prove yet.
+func Healthz(c echo.Context) error {
+	return c.String(http.StatusOK, "ok")
+}
+
+// Readyz reports whether the keys are loaded and proofs are accepted, so traffic is
+// only routed to the prover once the slow key loading has finished.
+func Readyz(c echo.Context) error {
+	if !keysLoaded() {
+		return c.String(http.StatusServiceUnavailable, "loading keys")
+	}
+	if !accepting.Load() {
+		return c.String(http.StatusServiceUnavailable, "shutting down")
+	}
+	return c.String(http.StatusOK, "ready")
+}
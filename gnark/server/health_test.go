@@ -0,0 +1,42 @@
+package server
+
+import (
+	"github.com/brevis-network/pico/gnark/sdk"
+	"github.com/consensys/gnark/test"
+	"github.com/labstack/echo"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthChecks(t *testing.T) {
+	assert := test.NewAssert(t)
+	useKeys(t, func() (map[string]*sdk.KeyRegistry, error) {
+		return map[string]*sdk.KeyRegistry{"": sdk.NewKeyRegistry()}, nil
+	})
+	e := echo.New()
+	e.GET("/healthz", Healthz)
+	e.GET("/readyz", Readyz)
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	// while the keys load the process is alive but not ready
+	assert.Equal(http.StatusOK, get("/healthz").Code)
+	rec := get("/readyz")
+	assert.Equal(http.StatusServiceUnavailable, rec.Code)
+	assert.Equal("loading keys", rec.Body.String())
+
+	assert.NoError(reloadKeys())
+	accepting.Store(true)
+	assert.Equal(http.StatusOK, get("/readyz").Code)
+
+	// shutting down
+	accepting.Store(false)
+	rec = get("/readyz")
+	assert.Equal(http.StatusServiceUnavailable, rec.Code)
+	assert.Equal("shutting down", rec.Body.String())
+	assert.Equal(http.StatusOK, get("/healthz").Code)
+}
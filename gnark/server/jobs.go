@@ -0,0 +1,368 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/sdk"
+	"github.com/brevis-network/pico/gnark/server/jobs"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/celer-network/goutils/log"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/labstack/echo"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxRetryBackoff bounds the delay before retrying a job.
+const maxRetryBackoff = 30 * time.Minute
+
+var (
+	jobStore *jobs.Store
+	jobQueue = newQueue()
+	workers  sync.WaitGroup
+)
+
+// queue is an unbounded FIFO of job ids; the jobs themselves live in jobStore.
+type queue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	ids    []string
+	closed bool
+}
+
+func newQueue() *queue {
+	q := &queue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *queue) push(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.ids = append(q.ids, id)
+	q.cond.Signal()
+}
+
+// pop blocks until an id is queued, ok is false once the queue is closed.
+func (q *queue) pop() (id string, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.ids) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return "", false
+	}
+	id, q.ids = q.ids[0], q.ids[1:]
+	return id, true
+}
+
+// close stops the workers once their current job is done; queued jobs stay queued in
+// the store for the next start.
+func (q *queue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// queueAt pushes job id to the queue at the time at.
+func queueAt(id string, at *time.Time) {
+	if at == nil || !time.Now().Before(*at) {
+		jobQueue.push(id)
+		return
+	}
+	time.AfterFunc(time.Until(*at), func() {
+		jobQueue.push(id)
+	})
+}
+
+// startWorkers queues the jobs left over from the last run and starts n workers.
+func startWorkers(n int) {
+	pending := jobStore.Queued()
+	if len(pending) > 0 {
+		log.Infof("resuming %d queued jobs", len(pending))
+	}
+	for _, id := range pending {
+		job, _ := jobStore.Get(id)
+		queueAt(id, job.RetryAt)
+	}
+	for i := 0; i < n; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for {
+				id, ok := jobQueue.pop()
+				if !ok {
+					return
+				}
+				memory := jobMemoryGovernor.acquire()
+				runJob(id)
+				jobMemoryGovernor.release(memory)
+			}
+		}()
+	}
+}
+
+// runJob proves a queued job. A transient failure queues it again after a backoff
+// doubling with every attempt, until -job-retries.
+func runJob(id string) {
+	job, err := jobStore.Start(id)
+	if err != nil {
+		log.Errorf("fail to start job %s, err: %v", id, err)
+		return
+	}
+	proof, signature, proveErr := proveJob(job)
+	if jobs.IsTransient(proveErr) && job.Attempts <= *jobRetries {
+		delay := retryBackoff(job.Attempts)
+		job, err = jobStore.Retry(id, proveErr, time.Now().Add(delay))
+		if err != nil {
+			log.Errorf("fail to record job %s, err: %v", id, err)
+			return
+		}
+		log.Warnf("job %s attempt %d failed, retrying in %v, err: %v", id, job.Attempts, delay, proveErr)
+		queueAt(id, job.RetryAt)
+		return
+	}
+	job, err = jobStore.Finish(id, proof, signature, proveErr)
+	if err != nil {
+		log.Errorf("fail to record job %s, err: %v", id, err)
+		return
+	}
+	recordJob(job)
+	log.Infof("job %s %s in %v", id, job.Status, job.Duration())
+	for _, coalesced := range jobStore.Coalesced(id) {
+		if coalesced.FinishedAt != nil && coalesced.FinishedAt.Equal(*job.FinishedAt) {
+			recordJob(coalesced)
+		}
+	}
+}
+
+// retryBackoff is the delay before retrying a job after its attempt failed.
+func retryBackoff(attempt int) time.Duration {
+	delay := *jobRetryBackoff
+	for i := 1; i < attempt && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryBackoff {
+		delay = maxRetryBackoff
+	}
+	return delay
+}
+
+// proveJob proves a running job, skipping the phases checkpointed by its previous
+// attempts, and signs its proof with the prover key if there is one. Failures that
+// may not happen again are marked transient.
+func proveJob(job jobs.Job) (string, *utils.ProofSignature, error) {
+	t := tenants[job.Tenant]
+	if t == nil {
+		return "", nil, fmt.Errorf("unknown tenant %s", job.Tenant)
+	}
+	keys := acquireKeys()
+	if keys == nil {
+		return "", nil, jobs.MarkTransient(errNotReady)
+	}
+	defer keys.release()
+	registry := keys.registries[t.config.Name]
+	if registry == nil {
+		return "", nil, fmt.Errorf("no keys loaded for tenant %s", t.config.Name)
+	}
+
+	t.metrics.start()
+	start := time.Now()
+	defer clearJobPhase(job.ID)
+	pf, pubWitness, err := proveJobPhases(job, registry)
+	t.metrics.finish(time.Since(start), err)
+	if err != nil {
+		return "", nil, err
+	}
+	setJobPhase(job, phaseSign)
+	res, signature, err := signedOnChainProof(pf, pubWitness)
+	if err != nil {
+		return "", nil, err
+	}
+	return res.Legacy(), signature, nil
+}
+
+func proveJobPhases(job jobs.Job, registry *sdk.KeyRegistry) (groth16.Proof, witness.Witness, error) {
+	setJobPhase(job, phaseWitness)
+	program, fullWitness, err := jobWitness(job, registry)
+	if err != nil {
+		return nil, nil, err
+	}
+	pubWitness, err := fullWitness.Public()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := jobStore.ReadCheckpoint(job.ID, jobs.PhaseProof)
+	if err != nil {
+		return nil, nil, jobs.MarkTransient(fmt.Errorf("fail to read proof checkpoint: %v", err))
+	}
+	if data != nil {
+		pf := groth16.NewProof(program.Session.Curve())
+		_, err = pf.ReadFrom(bytes.NewReader(data))
+		if err == nil {
+			return pf, pubWitness, nil
+		}
+		log.Warnf("fail to parse proof checkpoint of job %s, proving again, err: %v", job.ID, err)
+	}
+	setJobPhase(job, phaseProve)
+	start := time.Now()
+	pf, err := program.Session.Prove(context.Background(), fullWitness)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fail to prove groth16: %v", err)
+	}
+	recordProveDuration(job.VkeyHash, time.Since(start))
+	var buf bytes.Buffer
+	_, err = pf.WriteTo(&buf)
+	if err == nil {
+		err = jobStore.Checkpoint(job.ID, jobs.PhaseProof, buf.Bytes())
+	}
+	if err != nil {
+		log.Warnf("fail to checkpoint proof of job %s, err: %v", job.ID, err)
+	}
+	return pf, pubWitness, nil
+}
+
+// jobWitness assigns the witness of job, or reads it from the checkpoint of a
+// previous attempt.
+func jobWitness(job jobs.Job, registry *sdk.KeyRegistry) (*sdk.ProgramKeys, witness.Witness, error) {
+	data, err := jobStore.ReadCheckpoint(job.ID, jobs.PhaseWitness)
+	if err != nil {
+		return nil, nil, jobs.MarkTransient(fmt.Errorf("fail to read witness checkpoint: %v", err))
+	}
+	if data != nil {
+		program, err := registry.Lookup(job.VkeyHash)
+		if err != nil {
+			return nil, nil, err
+		}
+		fullWitness, err := witness.New(program.Session.Curve().ScalarField())
+		if err == nil {
+			err = fullWitness.UnmarshalBinary(data)
+		}
+		if err == nil {
+			return program, fullWitness, nil
+		}
+		log.Warnf("fail to parse witness checkpoint of job %s, assigning it again, err: %v", job.ID, err)
+	}
+
+	data, err = jobStore.Witness(job.ID)
+	if err != nil {
+		return nil, nil, jobs.MarkTransient(fmt.Errorf("fail to read witness: %v", err))
+	}
+	program, fullWitness, _, err := registry.AssignWitness(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err = fullWitness.MarshalBinary()
+	if err == nil {
+		err = jobStore.Checkpoint(job.ID, jobs.PhaseWitness, data)
+	}
+	if err != nil {
+		log.Warnf("fail to checkpoint witness of job %s, err: %v", job.ID, err)
+	}
+	return program, fullWitness, nil
+}
+
+// CreateJob queues the witness in the request body and answers with the job.
+func CreateJob(c echo.Context) error {
+	if !accepting.Load() {
+		return c.String(http.StatusServiceUnavailable, "prover not ready")
+	}
+	data, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	single, multi, err := utils.ParseWitness(data)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "invalid witness: "+err.Error())
+	}
+	vkeyHash := ""
+	if single != nil {
+		vkeyHash = single.VkeyHash
+	} else {
+		err = multi.Validate()
+		if err != nil {
+			return c.String(http.StatusBadRequest, "invalid witness: "+err.Error())
+		}
+		vkeyHash = multi.Chunks[0].VkeyHash
+	}
+
+	t := requestTenant(c)
+	err = t.checkMemory(vkeyHash)
+	if err != nil {
+		return c.String(http.StatusInsufficientStorage, err.Error())
+	}
+	if t.config.MaxQueued > 0 && len(jobStore.List(t.config.Name, "", jobs.Queued, 0)) >= t.config.MaxQueued {
+		return c.String(http.StatusTooManyRequests, fmt.Sprintf("tenant %s has %d queued jobs", t.config.Name, t.config.MaxQueued))
+	}
+	client, _ := c.Get(clientKey).(string)
+	job, err := jobStore.Create(data, vkeyHash, t.config.Name, client)
+	if err != nil {
+		return fmt.Errorf("fail to create job: %v", err)
+	}
+	if job.CoalescedWith != "" {
+		log.Infof("job %s coalesced with job %s of the same witness", job.ID, job.CoalescedWith)
+		return c.JSON(http.StatusAccepted, job)
+	}
+	jobQueue.push(job.ID)
+	return c.JSON(http.StatusAccepted, job)
+}
+
+// ownJob returns job id if it belongs to the client and tenant of the request. Jobs
+// of other clients or tenants are reported as unknown so their ids do not leak.
+func ownJob(c echo.Context, id string) (jobs.Job, bool) {
+	job, ok := jobStore.Get(id)
+	if !ok {
+		return jobs.Job{}, false
+	}
+	client, _ := c.Get(clientKey).(string)
+	return job, job.Client == client && job.Tenant == requestTenant(c).config.Name
+}
+
+// GetJob answers with the job, including its proof once it succeeded.
+func GetJob(c echo.Context) error {
+	job, ok := ownJob(c, c.Param("id"))
+	if !ok {
+		return c.String(http.StatusNotFound, "unknown job")
+	}
+	return c.JSON(http.StatusOK, job)
+}
+
+// ListJobs answers with the job history of the client, newest first, optionally
+// filtered by ?status= and truncated to ?limit= (100 by default).
+func ListJobs(c echo.Context) error {
+	limit := 100
+	if s := c.QueryParam("limit"); s != "" {
+		var err error
+		limit, err = strconv.Atoi(s)
+		if err != nil {
+			return c.String(http.StatusBadRequest, "invalid limit")
+		}
+	}
+	client, _ := c.Get(clientKey).(string)
+	return c.JSON(http.StatusOK, jobStore.List(requestTenant(c).config.Name, client, jobs.Status(c.QueryParam("status")), limit))
+}
+
+// RequeueJob queues a failed job again.
+func RequeueJob(c echo.Context) error {
+	if !accepting.Load() {
+		return c.String(http.StatusServiceUnavailable, "prover not ready")
+	}
+	_, ok := ownJob(c, c.Param("id"))
+	if !ok {
+		return c.String(http.StatusNotFound, "unknown job")
+	}
+	job, err := jobStore.Requeue(c.Param("id"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	jobQueue.push(job.ID)
+	return c.JSON(http.StatusOK, job)
+}
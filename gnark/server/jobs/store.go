@@ -0,0 +1,493 @@
+// Package jobs persists the proof jobs of the prover service, so queued and running
+// jobs survive a restart and finished ones remain queryable.
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/server/audit"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/celer-network/goutils/log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	Queued    Status = "queued"
+	Running   Status = "running"
+	Succeeded Status = "succeeded"
+	Failed    Status = "failed"
+)
+
+// Failure classifies the error of a job: transient failures, as i/o errors or keys
+// still loading, may not happen again and are retried, deterministic ones, as a
+// witness not satisfying the circuit, are not.
+type Failure string
+
+const (
+	Transient     Failure = "transient"
+	Deterministic Failure = "deterministic"
+)
+
+// Phases of a job, checkpointed once done so a retried job skips them.
+const (
+	// PhaseWitness is the assigned full witness, in the gnark binary format.
+	PhaseWitness = "witness"
+	// PhaseProof is the groth16 proof, in the gnark binary format.
+	PhaseProof = "proof"
+)
+
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string { return e.err.Error() }
+
+func (e *transientError) Unwrap() error { return e.err }
+
+// MarkTransient marks err as a transient failure.
+func MarkTransient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transientError{err}
+}
+
+// IsTransient reports whether err was marked by MarkTransient.
+func IsTransient(err error) bool {
+	var t *transientError
+	return errors.As(err, &t)
+}
+
+// Job is the record of one proof request.
+type Job struct {
+	ID       string `json:"id"`
+	Tenant   string `json:"tenant,omitempty"`
+	Client   string `json:"client,omitempty"`
+	VkeyHash string `json:"vkey_hash"`
+	// WitnessHash identifies the witness of the job, see audit.WitnessHash.
+	WitnessHash string `json:"witness_hash,omitempty"`
+	// CoalescedWith is the job of the same tenant and witness, queued or running when
+	// this one was created, that proves for both: this job stays queued until it
+	// finishes and then gets its outcome.
+	CoalescedWith string `json:"coalesced_with,omitempty"`
+	Status        Status `json:"status"`
+	// Attempts counts how often the job was started, including runs interrupted by a
+	// restart.
+	Attempts int     `json:"attempts"`
+	Error    string  `json:"error,omitempty"`
+	Failure  Failure `json:"failure,omitempty"`
+	// Checkpoints are the phases done by previous attempts.
+	Checkpoints []string `json:"checkpoints,omitempty"`
+	// RetryAt is when a job queued again after a transient failure is started.
+	RetryAt *time.Time `json:"retry_at,omitempty"`
+	Proof   string     `json:"proof,omitempty"`
+	// Signature is the signature of Proof by the prover key of the server, if it has
+	// one.
+	Signature  *utils.ProofSignature `json:"signature,omitempty"`
+	CreatedAt  time.Time             `json:"created_at"`
+	StartedAt  *time.Time            `json:"started_at,omitempty"`
+	FinishedAt *time.Time            `json:"finished_at,omitempty"`
+}
+
+// Duration is the time the last run took, 0 for jobs that have not finished.
+func (j *Job) Duration() time.Duration {
+	if j.StartedAt == nil || j.FinishedAt == nil {
+		return 0
+	}
+	return j.FinishedAt.Sub(*j.StartedAt)
+}
+
+// Store keeps one json record and the witness of every job in a directory. Records
+// are rewritten atomically on every status change, so a crash never corrupts them.
+type Store struct {
+	dir string
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// OpenStore loads the jobs in dir, creating it if needed. Jobs that were running when
+// the process stopped are queued again, and the jobs coalesced with them keep waiting.
+// A job file that cannot be read or parsed is renamed with a .corrupt suffix and
+// logged rather than failing the start, so one damaged record does not take the
+// service down with it.
+func OpenStore(dir string) (*Store, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{dir: dir, jobs: make(map[string]*Job)}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.job.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		job, err := readJob(path)
+		if err != nil {
+			log.Warnf("quarantining job file %s, err: %v", path, err)
+			err = os.Rename(path, path+".corrupt")
+			if err != nil {
+				return nil, fmt.Errorf("fail to quarantine job %s: %v", path, err)
+			}
+			continue
+		}
+		if job.Status == Running {
+			job.Status = Queued
+			job.StartedAt = nil
+			err = s.write(job)
+			if err != nil {
+				return nil, err
+			}
+		}
+		s.jobs[job.ID] = job
+	}
+
+	// a job coalesced with a job that finished before the crash gets its outcome, one
+	// coalesced with a quarantined job proves on its own
+	for _, job := range s.jobs {
+		if job.CoalescedWith == "" || job.Status != Queued {
+			continue
+		}
+		primary, ok := s.jobs[job.CoalescedWith]
+		if !ok {
+			job.CoalescedWith = ""
+			err = s.write(job)
+		} else if primary.Status == Succeeded || primary.Status == Failed {
+			err = s.fanOut(primary)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// readJob reads the job record at path, checking it is the record of the job its
+// name says.
+func readJob(path string) (*Job, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var job Job
+	err = json.Unmarshal(data, &job)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse job: %v", err)
+	}
+	if job.ID == "" || filepath.Base(path) != job.ID+".job.json" {
+		return nil, fmt.Errorf("job file holds job %q", job.ID)
+	}
+	return &job, nil
+}
+
+// Create stores witness and queues a new job for it, coalesced with a queued or running
+// job of the same tenant and witness if there is one.
+func (s *Store) Create(witness []byte, vkeyHash, tenant, client string) (Job, error) {
+	id, err := newID()
+	if err != nil {
+		return Job{}, err
+	}
+	job := &Job{
+		ID:          id,
+		Tenant:      tenant,
+		Client:      client,
+		VkeyHash:    vkeyHash,
+		WitnessHash: audit.WitnessHash(witness),
+		Status:      Queued,
+		CreatedAt:   time.Now().UTC(),
+	}
+	err = utils.WriteBytesAtomic(s.witnessPath(id), witness)
+	if err != nil {
+		return Job{}, fmt.Errorf("fail to write witness: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, other := range s.jobs {
+		if other.Tenant == tenant && other.WitnessHash == job.WitnessHash && other.CoalescedWith == "" && (other.Status == Queued || other.Status == Running) {
+			job.CoalescedWith = other.ID
+			break
+		}
+	}
+	err = s.write(job)
+	if err != nil {
+		return Job{}, err
+	}
+	s.jobs[id] = job
+	return *job, nil
+}
+
+// Witness reads the witness of job id.
+func (s *Store) Witness(id string) ([]byte, error) {
+	return os.ReadFile(s.witnessPath(id))
+}
+
+// Get returns a copy of job id.
+func (s *Store) Get(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// List returns the jobs of client of tenant with status, newest first. An empty status
+// matches every status, an empty tenant or client every tenant or client. limit <= 0
+// returns all of them.
+func (s *Store) List(tenant, client string, status Status, limit int) []Job {
+	s.mu.Lock()
+	var jobs []Job
+	for _, job := range s.jobs {
+		if (tenant == "" || job.Tenant == tenant) && (client == "" || job.Client == client) && (status == "" || job.Status == status) {
+			jobs = append(jobs, *job)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+	if limit > 0 && len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs
+}
+
+// Queued returns the ids of the queued jobs to prove, oldest first, leaving out the
+// coalesced jobs.
+func (s *Store) Queued() []string {
+	jobs := s.List("", "", Queued, 0)
+	var ids []string
+	for i := len(jobs) - 1; i >= 0; i-- {
+		if jobs[i].CoalescedWith == "" {
+			ids = append(ids, jobs[i].ID)
+		}
+	}
+	return ids
+}
+
+// Coalesced returns the jobs coalesced with job id.
+func (s *Store) Coalesced(id string) []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var jobs []Job
+	for _, job := range s.jobs {
+		if job.CoalescedWith == id {
+			jobs = append(jobs, *job)
+		}
+	}
+	return jobs
+}
+
+// Start marks a queued job as running.
+func (s *Store) Start(id string) (Job, error) {
+	return s.update(id, func(job *Job) error {
+		if job.Status != Queued {
+			return fmt.Errorf("job %s is %s, not queued", id, job.Status)
+		}
+		now := time.Now().UTC()
+		job.Status = Running
+		job.Attempts++
+		job.StartedAt = &now
+		job.FinishedAt = nil
+		job.RetryAt = nil
+		job.Error = ""
+		job.Failure = ""
+		return nil
+	})
+}
+
+// Checkpoint stores the output of phase of a running job.
+func (s *Store) Checkpoint(id, phase string, data []byte) error {
+	err := utils.WriteBytesAtomic(s.checkpointPath(id, phase), data)
+	if err != nil {
+		return fmt.Errorf("fail to write %s checkpoint: %v", phase, err)
+	}
+	_, err = s.update(id, func(job *Job) error {
+		if !hasPhase(job.Checkpoints, phase) {
+			job.Checkpoints = append(job.Checkpoints, phase)
+		}
+		return nil
+	})
+	return err
+}
+
+// ReadCheckpoint reads the output of phase of job id, nil if the phase is not done.
+func (s *Store) ReadCheckpoint(id, phase string) ([]byte, error) {
+	job, ok := s.Get(id)
+	if !ok || !hasPhase(job.Checkpoints, phase) {
+		return nil, nil
+	}
+	return os.ReadFile(s.checkpointPath(id, phase))
+}
+
+// Retry queues a running job again after the transient failure retryErr, to be
+// started at at.
+func (s *Store) Retry(id string, retryErr error, at time.Time) (Job, error) {
+	return s.update(id, func(job *Job) error {
+		if job.Status != Running {
+			return fmt.Errorf("job %s is %s, not running", id, job.Status)
+		}
+		at = at.UTC()
+		job.Status = Queued
+		job.Error = retryErr.Error()
+		job.Failure = Transient
+		job.StartedAt = nil
+		job.RetryAt = &at
+		return nil
+	})
+}
+
+// Finish records the outcome of a running job, with the signature of its proof if
+// signed, and gives it to the jobs coalesced with it. The witness and checkpoints of a
+// successful job are deleted, failed jobs keep them so they can be queued again.
+func (s *Store) Finish(id, proof string, signature *utils.ProofSignature, proveErr error) (Job, error) {
+	job, err := s.update(id, func(job *Job) error {
+		now := time.Now().UTC()
+		job.FinishedAt = &now
+		if proveErr != nil {
+			job.Status = Failed
+			job.Error = proveErr.Error()
+			job.Failure = Deterministic
+			if IsTransient(proveErr) {
+				job.Failure = Transient
+			}
+			return nil
+		}
+		job.Status = Succeeded
+		job.Proof = proof
+		job.Signature = signature
+		job.Checkpoints = nil
+		return nil
+	})
+	if err != nil {
+		return job, err
+	}
+	if proveErr == nil {
+		os.Remove(s.witnessPath(id))
+		s.removeCheckpoints(id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return job, s.fanOut(&job)
+}
+
+// fanOut gives the outcome of the finished job to the queued jobs coalesced with it.
+// s.mu must be held.
+func (s *Store) fanOut(finished *Job) error {
+	for _, job := range s.jobs {
+		if job.CoalescedWith != finished.ID || job.Status != Queued {
+			continue
+		}
+		updated := *job
+		updated.Status = finished.Status
+		updated.Proof = finished.Proof
+		updated.Signature = finished.Signature
+		updated.Error = finished.Error
+		updated.Failure = finished.Failure
+		updated.StartedAt = finished.StartedAt
+		updated.FinishedAt = finished.FinishedAt
+		err := s.write(&updated)
+		if err != nil {
+			return err
+		}
+		*job = updated
+		if updated.Status == Succeeded {
+			os.Remove(s.witnessPath(job.ID))
+		}
+	}
+	return nil
+}
+
+// Requeue queues a failed job again. Its checkpoints are dropped, as the keys may have
+// changed since.
+func (s *Store) Requeue(id string) (Job, error) {
+	job, err := s.update(id, func(job *Job) error {
+		if job.Status != Failed {
+			return fmt.Errorf("job %s is %s, only failed jobs can be queued again", id, job.Status)
+		}
+		job.Status = Queued
+		job.StartedAt = nil
+		job.FinishedAt = nil
+		job.Checkpoints = nil
+		// it failed with the job it was coalesced with, and now proves on its own
+		job.CoalescedWith = ""
+		return nil
+	})
+	if err == nil {
+		s.removeCheckpoints(id)
+	}
+	return job, err
+}
+
+func (s *Store) update(id string, change func(job *Job) error) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, fmt.Errorf("unknown job %s", id)
+	}
+	updated := *job
+	err := change(&updated)
+	if err != nil {
+		return Job{}, err
+	}
+	err = s.write(&updated)
+	if err != nil {
+		return Job{}, err
+	}
+	*job = updated
+	return updated, nil
+}
+
+func (s *Store) write(job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return utils.WriteBytesAtomic(filepath.Join(s.dir, job.ID+".job.json"), data)
+}
+
+func (s *Store) witnessPath(id string) string {
+	return filepath.Join(s.dir, id+".witness.json")
+}
+
+func (s *Store) checkpointPath(id, phase string) string {
+	return filepath.Join(s.dir, id+"."+phase+".bin")
+}
+
+func (s *Store) removeCheckpoints(id string) {
+	for _, phase := range []string{PhaseWitness, PhaseProof} {
+		os.Remove(s.checkpointPath(id, phase))
+	}
+}
+
+func hasPhase(phases []string, phase string) bool {
+	for _, p := range phases {
+		if p == phase {
+			return true
+		}
+	}
+	return false
+}
+
+func newID() (string, error) {
+	var b [16]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
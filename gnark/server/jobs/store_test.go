@@ -0,0 +1,219 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStoreSurvivesRestart(t *testing.T) {
+	assert := test.NewAssert(t)
+	dir := t.TempDir()
+
+	store, err := OpenStore(dir)
+	assert.NoError(err)
+	done, err := store.Create([]byte(`{"vkey_hash":"0x1"}`), "0x1", "", "alice")
+	assert.NoError(err)
+	failed, err := store.Create([]byte(`{"vkey_hash":"0x2"}`), "0x2", "", "alice")
+	assert.NoError(err)
+	running, err := store.Create([]byte(`{"vkey_hash":"0x3"}`), "0x3", "", "bob")
+	assert.NoError(err)
+	queued, err := store.Create([]byte(`{"vkey_hash":"0x4"}`), "0x4", "", "bob")
+	assert.NoError(err)
+
+	for _, id := range []string{done.ID, failed.ID, running.ID} {
+		_, err = store.Start(id)
+		assert.NoError(err)
+	}
+	_, err = store.Finish(done.ID, "0xproof", nil, nil)
+	assert.NoError(err)
+	_, err = store.Finish(failed.ID, "", nil, errors.New("unsatisfied constraint"))
+	assert.NoError(err)
+	_, err = store.Start(done.ID)
+	assert.Error(err)
+
+	// reopen as after a crash: the running job is queued again
+	store, err = OpenStore(dir)
+	assert.NoError(err)
+	assert.Equal([]string{running.ID, queued.ID}, store.Queued())
+
+	job, ok := store.Get(done.ID)
+	assert.True(ok)
+	assert.Equal(Succeeded, job.Status)
+	assert.Equal("0xproof", job.Proof)
+	assert.NotNil(job.FinishedAt)
+	_, err = store.Witness(done.ID)
+	assert.Error(err)
+
+	job, ok = store.Get(running.ID)
+	assert.True(ok)
+	assert.Equal(1, job.Attempts)
+
+	assert.Len(store.List("", "", Failed, 0), 1)
+	assert.Len(store.List("", "", "", 2), 2)
+	assert.Len(store.List("", "bob", "", 0), 2)
+	assert.Len(store.List("", "alice", Failed, 0), 1)
+	assert.Len(store.List("", "bob", Failed, 0), 0)
+
+	// tenants namespace the jobs of the same client
+	tenantJob, err := store.Create([]byte(`{"vkey_hash":"0x5"}`), "0x5", "acme", "alice")
+	assert.NoError(err)
+	listed := store.List("acme", "alice", "", 0)
+	assert.Len(listed, 1)
+	assert.Equal(tenantJob.ID, listed[0].ID)
+	assert.Len(store.List("", "alice", "", 0), 3)
+
+	_, err = store.Requeue(done.ID)
+	assert.Error(err)
+	job, err = store.Requeue(failed.ID)
+	assert.NoError(err)
+	assert.Equal(Queued, job.Status)
+	witness, err := store.Witness(failed.ID)
+	assert.NoError(err)
+	assert.Equal(`{"vkey_hash":"0x2"}`, string(witness))
+}
+
+func TestStoreCoalescesIdenticalWitnesses(t *testing.T) {
+	assert := test.NewAssert(t)
+	dir := t.TempDir()
+	witness := []byte(`{"vkey_hash":"0x1"}`)
+
+	store, err := OpenStore(dir)
+	assert.NoError(err)
+	primary, err := store.Create(witness, "0x1", "acme", "alice")
+	assert.NoError(err)
+	_, err = store.Start(primary.ID)
+	assert.NoError(err)
+	follower, err := store.Create(witness, "0x1", "acme", "bob")
+	assert.NoError(err)
+	assert.Equal(primary.ID, follower.CoalescedWith)
+	// another tenant proves with its own keys
+	other, err := store.Create(witness, "0x1", "", "bob")
+	assert.NoError(err)
+	assert.Equal("", other.CoalescedWith)
+	assert.Equal([]string{other.ID}, store.Queued())
+
+	// a restart keeps the follower waiting for the primary queued again
+	store, err = OpenStore(dir)
+	assert.NoError(err)
+	assert.Equal([]string{primary.ID, other.ID}, store.Queued())
+	_, err = store.Start(primary.ID)
+	assert.NoError(err)
+	_, err = store.Finish(primary.ID, "", nil, errors.New("unsatisfied constraint"))
+	assert.NoError(err)
+	coalesced := store.Coalesced(primary.ID)
+	assert.Len(coalesced, 1)
+	assert.Equal(Failed, coalesced[0].Status)
+	assert.Equal("unsatisfied constraint", coalesced[0].Error)
+
+	// requeued, the follower proves on its own
+	job, err := store.Requeue(follower.ID)
+	assert.NoError(err)
+	assert.Equal("", job.CoalescedWith)
+	assert.Equal([]string{follower.ID, other.ID}, store.Queued())
+	_, err = store.Start(follower.ID)
+	assert.NoError(err)
+
+	// the proof of the primary reaches its follower, and stays with it after a restart
+	late, err := store.Create(witness, "0x1", "acme", "carol")
+	assert.NoError(err)
+	assert.Equal(follower.ID, late.CoalescedWith)
+	_, err = store.Finish(follower.ID, "0xproof", nil, nil)
+	assert.NoError(err)
+	job, ok := store.Get(late.ID)
+	assert.True(ok)
+	assert.Equal(Succeeded, job.Status)
+	assert.Equal("0xproof", job.Proof)
+	_, err = store.Witness(late.ID)
+	assert.Error(err)
+	store, err = OpenStore(dir)
+	assert.NoError(err)
+	job, ok = store.Get(late.ID)
+	assert.True(ok)
+	assert.Equal(Succeeded, job.Status)
+}
+
+func TestStoreCheckpoints(t *testing.T) {
+	assert := test.NewAssert(t)
+	dir := t.TempDir()
+
+	store, err := OpenStore(dir)
+	assert.NoError(err)
+	job, err := store.Create([]byte(`{"vkey_hash":"0x1"}`), "0x1", "", "alice")
+	assert.NoError(err)
+	_, err = store.Start(job.ID)
+	assert.NoError(err)
+	data, err := store.ReadCheckpoint(job.ID, PhaseWitness)
+	assert.NoError(err)
+	assert.Nil(data)
+	assert.NoError(store.Checkpoint(job.ID, PhaseWitness, []byte("witness")))
+
+	// a transient failure queues the job again with its checkpoints, across restarts
+	retryErr := MarkTransient(fmt.Errorf("fail to read witness: %w", os.ErrNotExist))
+	assert.True(IsTransient(fmt.Errorf("job: %w", retryErr)))
+	assert.True(errors.Is(retryErr, os.ErrNotExist))
+	job, err = store.Retry(job.ID, retryErr, time.Now().Add(time.Minute))
+	assert.NoError(err)
+	assert.Equal(Queued, job.Status)
+	assert.Equal(Transient, job.Failure)
+	assert.NotNil(job.RetryAt)
+	store, err = OpenStore(dir)
+	assert.NoError(err)
+	job, _ = store.Get(job.ID)
+	assert.Equal([]string{PhaseWitness}, job.Checkpoints)
+	data, err = store.ReadCheckpoint(job.ID, PhaseWitness)
+	assert.NoError(err)
+	assert.Equal("witness", string(data))
+
+	_, err = store.Start(job.ID)
+	assert.NoError(err)
+	job, err = store.Finish(job.ID, "", nil, errors.New("unsatisfied constraint"))
+	assert.NoError(err)
+	assert.Equal(Deterministic, job.Failure)
+
+	// requeued jobs start over, succeeded jobs drop their checkpoints
+	job, err = store.Requeue(job.ID)
+	assert.NoError(err)
+	assert.Empty(job.Checkpoints)
+	_, err = store.Start(job.ID)
+	assert.NoError(err)
+	assert.NoError(store.Checkpoint(job.ID, PhaseProof, []byte("proof")))
+	job, err = store.Finish(job.ID, "0xproof", nil, nil)
+	assert.NoError(err)
+	assert.Empty(job.Checkpoints)
+	_, err = os.Stat(store.checkpointPath(job.ID, PhaseProof))
+	assert.True(os.IsNotExist(err))
+}
+
+func TestStoreQuarantinesCorruptJobs(t *testing.T) {
+	assert := test.NewAssert(t)
+	dir := t.TempDir()
+
+	store, err := OpenStore(dir)
+	assert.NoError(err)
+	job, err := store.Create([]byte(`{"vkey_hash":"0x1"}`), "0x1", "", "alice")
+	assert.NoError(err)
+	truncated := filepath.Join(dir, "0123.job.json")
+	assert.NoError(os.WriteFile(truncated, []byte(`{"id": "0123", "sta`), 0644))
+	misnamed := filepath.Join(dir, "4567.job.json")
+	assert.NoError(os.WriteFile(misnamed, []byte(`{"id": "89ab", "status": "queued"}`), 0644))
+
+	store, err = OpenStore(dir)
+	assert.NoError(err)
+	assert.Equal([]string{job.ID}, store.Queued())
+	for _, path := range []string{truncated, misnamed} {
+		_, err = os.Stat(path)
+		assert.True(os.IsNotExist(err))
+		_, err = os.Stat(path + ".corrupt")
+		assert.NoError(err)
+	}
+
+	// the quarantined files are not read again
+	store, err = OpenStore(dir)
+	assert.NoError(err)
+	assert.Equal([]string{job.ID}, store.Queued())
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/brevis-network/pico/gnark/server/audit"
+	"github.com/brevis-network/pico/gnark/server/jobs"
+	"github.com/consensys/gnark/test"
+	"github.com/labstack/echo"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJobsOwner(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	var err error
+	jobStore, err = jobs.OpenStore(t.TempDir())
+	assert.NoError(err)
+	alice, err := jobStore.Create([]byte(`{}`), "0x1", "", "alice")
+	assert.NoError(err)
+	bob, err := jobStore.Create([]byte(`{"vkey_hash":"0x1"}`), "0x1", "", "bob")
+	assert.NoError(err)
+	for _, job := range []jobs.Job{alice, bob} {
+		_, err = jobStore.Start(job.ID)
+		assert.NoError(err)
+		_, err = jobStore.Finish(job.ID, "", nil, errors.New("unsatisfied constraint"))
+		assert.NoError(err)
+	}
+	accepting.Store(true)
+	defer accepting.Store(false)
+
+	e := echo.New()
+	auth := []echo.MiddlewareFunc{requireAPIKey([]apiKey{{client: "alice", key: []byte("a")}, {client: "bob", key: []byte("b")}})}
+	limited := append(auth, newRateLimiter(1, 1, false).middleware)
+	registerRoutes(e, auth, limited, nil)
+	do := func(method, path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// polling is not rate limited
+	for i := 0; i < 3; i++ {
+		assert.Equal(http.StatusOK, do(http.MethodGet, "/jobs/"+alice.ID, "a").Code)
+	}
+	assert.Equal(http.StatusNotFound, do(http.MethodGet, "/jobs/"+alice.ID, "b").Code)
+	assert.Equal(http.StatusUnauthorized, do(http.MethodGet, "/jobs/"+alice.ID, "").Code)
+
+	var listed []jobs.Job
+	rec := do(http.MethodGet, "/jobs", "b")
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.NoError(json.Unmarshal(rec.Body.Bytes(), &listed))
+	assert.Len(listed, 1)
+	assert.Equal(bob.ID, listed[0].ID)
+
+	assert.Equal(http.StatusNotFound, do(http.MethodPost, "/jobs/"+alice.ID+"/requeue", "b").Code)
+	assert.Equal(http.StatusOK, do(http.MethodPost, "/jobs/"+alice.ID+"/requeue", "a").Code)
+	assert.Equal(http.StatusTooManyRequests, do(http.MethodPost, "/jobs/"+alice.ID+"/requeue", "a").Code)
+}
+
+func TestRunJobRetries(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	retries, backoff := 1, 10*time.Second
+	jobRetries, jobRetryBackoff = &retries, &backoff
+	assert.Equal(10*time.Second, retryBackoff(1))
+	assert.Equal(20*time.Second, retryBackoff(2))
+	assert.Equal(maxRetryBackoff, retryBackoff(10))
+	currentMu.Lock()
+	loaded := current
+	current = nil
+	currentMu.Unlock()
+	t.Cleanup(func() {
+		currentMu.Lock()
+		current = loaded
+		currentMu.Unlock()
+	})
+
+	var err error
+	jobStore, err = jobs.OpenStore(t.TempDir())
+	assert.NoError(err)
+	auditLog, err = audit.Open(filepath.Join(t.TempDir(), "audit.jsonl"))
+	assert.NoError(err)
+	t.Cleanup(func() {
+		auditLog.Close()
+		auditLog = nil
+	})
+	job, err := jobStore.Create([]byte(`{}`), "0x1", "", "alice")
+	assert.NoError(err)
+
+	// keys still loading is transient: the job is retried, then fails
+	runJob(job.ID)
+	job, _ = jobStore.Get(job.ID)
+	assert.Equal(jobs.Queued, job.Status)
+	assert.Equal(jobs.Transient, job.Failure)
+	assert.Contains(job.Error, "not ready")
+	assert.NotNil(job.RetryAt)
+	runJob(job.ID)
+	job, _ = jobStore.Get(job.ID)
+	assert.Equal(jobs.Failed, job.Status)
+	assert.Equal(jobs.Transient, job.Failure)
+	assert.Equal(2, job.Attempts)
+
+	// the audit log has the finished job, not its retries, exported to admins
+	e := echo.New()
+	registerRoutes(e, nil, nil, []apiKey{{client: "admin", key: []byte("x")}})
+	do := func(path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	assert.Equal(http.StatusUnauthorized, do("/admin/audit", "").Code)
+	assert.Equal(http.StatusBadRequest, do("/admin/audit?since=yesterday", "x").Code)
+	rec := do("/admin/audit?client=alice", "x")
+	assert.Equal(http.StatusOK, rec.Code)
+	var entry audit.Entry
+	assert.NoError(json.Unmarshal(rec.Body.Bytes(), &entry))
+	assert.Equal(audit.KindJob, entry.Kind)
+	assert.Equal(job.ID, entry.Job)
+	assert.Equal(audit.WitnessHash([]byte(`{}`)), entry.WitnessHash)
+	assert.Equal("0x1", entry.VkeyHash)
+	assert.Equal(audit.Failed, entry.Result)
+	assert.Equal(2, entry.Attempts)
+	assert.Equal(0, do("/admin/audit?client=bob", "x").Body.Len())
+}
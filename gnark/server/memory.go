@@ -0,0 +1,167 @@
+package server
+
+import (
+	"github.com/brevis-network/pico/gnark/sdk"
+	"github.com/celer-network/goutils/log"
+	"sync"
+	"time"
+)
+
+// memorySampleInterval is how often the rss of the running jobs is sampled.
+const memorySampleInterval = 500 * time.Millisecond
+
+// memoryGovernor limits the jobs proved at once to what fits in -max-memory: the
+// memory left by the loaded keys divided by the peak memory of a proof. The peak is
+// measured on the jobs proved since the keys were loaded, the largest one counting,
+// and estimated as the memory of the keys before the first. Jobs proved together
+// inflate each other's measurement, which only lowers the limit. /prove requests are
+// bounded by the tenant quotas instead.
+type memoryGovernor struct {
+	// budget is in bytes, 0 for no limit.
+	budget     uint64
+	maxWorkers int
+	// rss reads the resident memory of the process.
+	rss func() uint64
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	running map[*jobMemory]bool
+	// baseline is the rss with the keys loaded and no job running.
+	baseline uint64
+	perProof uint64
+	// estimate is the peak memory of a proof estimated from the size of the keys, for
+	// keys loaded on demand whose memory is not in the baseline.
+	estimate uint64
+	measured bool
+	limit    int
+}
+
+// jobMemory is the rss of the process when a job started and the highest since.
+type jobMemory struct {
+	start, peak uint64
+}
+
+func newMemoryGovernor(budget uint64, maxWorkers int, rss func() uint64) *memoryGovernor {
+	g := &memoryGovernor{budget: budget, maxWorkers: maxWorkers, rss: rss, running: make(map[*jobMemory]bool)}
+	g.cond = sync.NewCond(&g.mu)
+	g.limit = g.computeLimit()
+	return g
+}
+
+// computeLimit is the number of jobs fitting in the budget, at least one so jobs are
+// still proved when a single proof does not fit.
+func (g *memoryGovernor) computeLimit() int {
+	if g.budget == 0 || g.perProof == 0 {
+		return g.maxWorkers
+	}
+	var free uint64
+	if g.budget > g.baseline {
+		free = g.budget - g.baseline
+	}
+	return max(1, min(g.maxWorkers, int(free/g.perProof)))
+}
+
+// update recomputes the limit and wakes up the waiting jobs. The caller holds mu.
+func (g *memoryGovernor) update() {
+	limit := g.computeLimit()
+	if limit != g.limit {
+		log.Infof("memory budget %d MB fits %d concurrent jobs: keys %d MB, %d MB per proof", g.budget>>20, limit, g.baseline>>20, g.perProof>>20)
+		g.limit = limit
+	}
+	g.cond.Broadcast()
+}
+
+func (g *memoryGovernor) currentLimit() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.limit
+}
+
+// keysLoaded resets the measurements for keys that grew the rss from before to after.
+func (g *memoryGovernor) keysLoaded(before, after uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.baseline = after
+	g.perProof = g.estimate
+	if after > before {
+		g.perProof = max(g.perProof, after-before)
+	}
+	g.measured = false
+	g.update()
+}
+
+// estimateProof sets the estimate of the keys about to be loaded.
+func (g *memoryGovernor) estimateProof(estimate uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.estimate = estimate
+}
+
+// keysReleased takes the rss once the old keys are freed as the baseline, unless a
+// job is running.
+func (g *memoryGovernor) keysReleased() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.running) == 0 {
+		g.baseline = g.rss()
+		g.update()
+	}
+}
+
+// acquire waits until one more job fits in the budget.
+func (g *memoryGovernor) acquire() *jobMemory {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for len(g.running) >= g.limit {
+		g.cond.Wait()
+	}
+	rss := g.rss()
+	m := &jobMemory{start: rss, peak: rss}
+	g.running[m] = true
+	return m
+}
+
+// release records the peak memory of a finished job.
+func (g *memoryGovernor) release(m *jobMemory) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.running, m)
+	if m.peak > m.start {
+		growth := m.peak - m.start
+		if !g.measured || growth > g.perProof {
+			g.perProof = growth
+		}
+		g.measured = true
+	}
+	g.update()
+}
+
+// sample records the rss in the peaks of the running jobs.
+func (g *memoryGovernor) sample() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.running) == 0 {
+		return
+	}
+	rss := g.rss()
+	for m := range g.running {
+		m.peak = max(m.peak, rss)
+	}
+}
+
+// sampleEvery samples the rss of the running jobs until stop is closed.
+func (g *memoryGovernor) sampleEvery(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			g.sample()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// jobMemoryGovernor limits the jobs of the workers, sized by Serve.
+var jobMemoryGovernor = newMemoryGovernor(0, 1, sdk.CurrentRSS)
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"github.com/consensys/gnark/test"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryGovernor(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	const gb = 1 << 30
+	var rss atomic.Uint64
+	rss.Store(3 * gb)
+	g := newMemoryGovernor(10*gb, 8, rss.Load)
+	assert.Equal(8, g.currentLimit(), "no limit before the keys are loaded")
+
+	// keys of 2 GB estimate a proof at 2 GB: 7 GB left fit 3
+	g.keysLoaded(1*gb, 3*gb)
+	assert.Equal(3, g.currentLimit())
+	var running []*jobMemory
+	for i := 0; i < 3; i++ {
+		running = append(running, g.acquire())
+	}
+	acquired := make(chan *jobMemory)
+	go func() {
+		acquired <- g.acquire()
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("a fourth job started beyond the budget")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// a proof measured at 5 GB leaves room for a single one
+	rss.Store(8 * gb)
+	g.sample()
+	rss.Store(3 * gb)
+	g.release(running[0])
+	assert.Equal(1, g.currentLimit())
+	g.release(running[1])
+	select {
+	case <-acquired:
+		t.Fatal("a job started beyond the budget")
+	case <-time.After(50 * time.Millisecond):
+	}
+	g.release(running[2])
+	g.release(<-acquired)
+
+	// a larger circuit still proves one job at a time
+	g.keysLoaded(3*gb, 9*gb)
+	assert.Equal(1, g.currentLimit())
+
+	// keys loaded on demand are not in the baseline, their estimate sizes the proofs
+	g.estimateProof(2 * gb)
+	g.keysLoaded(3*gb, 3*gb)
+	assert.Equal(3, g.currentLimit())
+
+	assert.Equal(8, newMemoryGovernor(0, 8, rss.Load).currentLimit(), "no budget")
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/sdk"
+	"github.com/brevis-network/pico/gnark/server/jobs"
+	"github.com/labstack/echo"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Outcomes of a proof in the metrics.
+const (
+	outcomeSuccess  = "success"
+	outcomeRejected = "rejected"
+	outcomeError    = "error"
+)
+
+// tenantMetrics counts the proofs of a tenant, from /prove and from jobs.
+type tenantMetrics struct {
+	mu           sync.Mutex
+	proofs       map[string]int
+	inflight     int
+	proveSeconds float64
+}
+
+func newTenantMetrics() *tenantMetrics {
+	return &tenantMetrics{proofs: make(map[string]int)}
+}
+
+func (m *tenantMetrics) start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inflight++
+}
+
+// finish records a proof: rejected if the witness does not fit the keys, an error
+// if proving failed.
+func (m *tenantMetrics) finish(duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inflight--
+	m.proveSeconds += duration.Seconds()
+	switch {
+	case err == nil:
+		m.proofs[outcomeSuccess]++
+	case errors.Is(err, sdk.ErrUnknownProgram) || errors.Is(err, sdk.ErrInvalidWitness):
+		m.proofs[outcomeRejected]++
+	default:
+		m.proofs[outcomeError]++
+	}
+}
+
+// Metrics answers with the metrics of every tenant in the prometheus text format,
+// labelled by tenant name.
+func Metrics(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderContentType, "text/plain; version=0.0.4")
+	c.Response().WriteHeader(http.StatusOK)
+	writeMetrics(c.Response())
+	return nil
+}
+
+func writeMetrics(w io.Writer) {
+	names := make([]string, 0, len(tenants))
+	for name := range tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "# HELP pico_proofs_total Proofs finished, by outcome: success, rejected (invalid witness) or error.")
+	fmt.Fprintln(w, "# TYPE pico_proofs_total counter")
+	for _, name := range names {
+		m := tenants[name].metrics
+		m.mu.Lock()
+		for _, outcome := range []string{outcomeSuccess, outcomeRejected, outcomeError} {
+			fmt.Fprintf(w, "pico_proofs_total{tenant=%q,outcome=%q} %d\n", name, outcome, m.proofs[outcome])
+		}
+		m.mu.Unlock()
+	}
+	fmt.Fprintln(w, "# HELP pico_proofs_inflight Proofs computing.")
+	fmt.Fprintln(w, "# TYPE pico_proofs_inflight gauge")
+	for _, name := range names {
+		m := tenants[name].metrics
+		m.mu.Lock()
+		fmt.Fprintf(w, "pico_proofs_inflight{tenant=%q} %d\n", name, m.inflight)
+		m.mu.Unlock()
+	}
+	fmt.Fprintln(w, "# HELP pico_prove_seconds_total Time spent proving.")
+	fmt.Fprintln(w, "# TYPE pico_prove_seconds_total counter")
+	for _, name := range names {
+		m := tenants[name].metrics
+		m.mu.Lock()
+		fmt.Fprintf(w, "pico_prove_seconds_total{tenant=%q} %g\n", name, m.proveSeconds)
+		m.mu.Unlock()
+	}
+	if jobStore == nil {
+		return
+	}
+	fmt.Fprintln(w, "# HELP pico_jobs_queued Jobs waiting for a worker.")
+	fmt.Fprintln(w, "# TYPE pico_jobs_queued gauge")
+	for _, name := range names {
+		fmt.Fprintf(w, "pico_jobs_queued{tenant=%q} %d\n", name, len(jobStore.List(name, "", jobs.Queued, 0)))
+	}
+	fmt.Fprintln(w, "# HELP pico_jobs_concurrency_limit Jobs proved at once within the memory budget.")
+	fmt.Fprintln(w, "# TYPE pico_jobs_concurrency_limit gauge")
+	fmt.Fprintf(w, "pico_jobs_concurrency_limit %d\n", jobMemoryGovernor.currentLimit())
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/brevis-network/pico/gnark/server/jobs"
+	"github.com/labstack/echo"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// progressInterval is how often /jobs/:id/progress reports an unfinished job.
+var progressInterval = time.Second
+
+// Phases of a job in its progress.
+const (
+	phaseQueued  = "queued"
+	phaseWitness = "witness"
+	phaseProve   = "prove"
+	phaseSign    = "sign"
+	phaseDone    = "done"
+)
+
+// phasePercent is the progress of a job when it enters each phase. Proving takes
+// nearly all the time of a job, the percentage within it is estimated.
+var phasePercent = map[string]float64{phaseQueued: 0, phaseWitness: 2, phaseProve: 5, phaseSign: 99, phaseDone: 100}
+
+// JobProgress is a line of /jobs/:id/progress.
+type JobProgress struct {
+	ID     string      `json:"id"`
+	Status jobs.Status `json:"status"`
+	Phase  string      `json:"phase"`
+	// Percent is the share of the job done, estimated while proving from the last
+	// proof of the same program; it stays at the start of the phase without one.
+	Percent float64 `json:"percent"`
+	// ElapsedMs is the time since the job was started, 0 while it is queued.
+	ElapsedMs int64 `json:"elapsed_ms"`
+	// RemainingMs is the estimated time until the proof, 0 if unknown.
+	RemainingMs int64  `json:"remaining_ms,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// jobPhase is the phase a running job is in, since start.
+type jobPhase struct {
+	name     string
+	vkeyHash string
+	start    time.Time
+}
+
+var (
+	phasesMu sync.Mutex
+	phases   = make(map[string]jobPhase)
+	// proveDurations is the duration of the last proof of each program, by vkey hash.
+	proveDurations = make(map[string]time.Duration)
+)
+
+// setJobPhase records that job entered phase.
+func setJobPhase(job jobs.Job, phase string) {
+	phasesMu.Lock()
+	defer phasesMu.Unlock()
+	phases[job.ID] = jobPhase{name: phase, vkeyHash: job.VkeyHash, start: time.Now()}
+}
+
+// clearJobPhase forgets the phase of a job whose attempt ended.
+func clearJobPhase(id string) {
+	phasesMu.Lock()
+	defer phasesMu.Unlock()
+	delete(phases, id)
+}
+
+// recordProveDuration records how long the proof of the program vkeyHash took, for the
+// progress of the next ones.
+func recordProveDuration(vkeyHash string, d time.Duration) {
+	phasesMu.Lock()
+	defer phasesMu.Unlock()
+	proveDurations[vkeyHash] = d
+}
+
+// jobProgress is the progress of job at now. A job coalesced with another has the
+// progress of the one proving for both.
+func jobProgress(job jobs.Job, now time.Time) JobProgress {
+	res := JobProgress{ID: job.ID, Status: job.Status, Phase: phaseQueued, Error: job.Error}
+	if job.StartedAt != nil {
+		res.ElapsedMs = now.Sub(*job.StartedAt).Milliseconds()
+	}
+	switch job.Status {
+	case jobs.Succeeded, jobs.Failed:
+		res.Phase, res.Percent = phaseDone, phasePercent[phaseDone]
+		return res
+	}
+
+	phasesMu.Lock()
+	defer phasesMu.Unlock()
+	phase, ok := phases[job.ID]
+	if !ok && job.CoalescedWith != "" {
+		phase, ok = phases[job.CoalescedWith]
+	}
+	if !ok {
+		return res
+	}
+	res.Phase, res.Percent = phase.name, phasePercent[phase.name]
+	expected := proveDurations[phase.vkeyHash]
+	if phase.name != phaseProve || expected == 0 {
+		return res
+	}
+	proving := now.Sub(phase.start)
+	// a proof slower than the last one stays short of the next phase
+	share := min(float64(proving)/float64(expected), 0.99)
+	res.Percent += share * (phasePercent[phaseSign] - phasePercent[phaseProve])
+	res.RemainingMs = max(expected-proving, 0).Milliseconds()
+	return res
+}
+
+// StreamJobProgress streams the progress of the job as json lines, one every
+// progressInterval, until it succeeds or fails, the last line, or the client goes
+// away. The proof is then fetched from /jobs/:id.
+func StreamJobProgress(c echo.Context) error {
+	id := c.Param("id")
+	_, ok := ownJob(c, id)
+	if !ok {
+		return c.String(http.StatusNotFound, "unknown job")
+	}
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "application/x-ndjson")
+	res.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(res)
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		job, _ := jobStore.Get(id)
+		err := encoder.Encode(jobProgress(job, time.Now()))
+		if err != nil {
+			return nil
+		}
+		res.Flush()
+		if job.Status == jobs.Succeeded || job.Status == jobs.Failed {
+			return nil
+		}
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
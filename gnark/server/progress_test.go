@@ -0,0 +1,103 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/brevis-network/pico/gnark/server/jobs"
+	"github.com/consensys/gnark/test"
+	"github.com/labstack/echo"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestJobProgress(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	var err error
+	jobStore, err = jobs.OpenStore(t.TempDir())
+	assert.NoError(err)
+	job, err := jobStore.Create([]byte(`{"vkey_hash":"0x1"}`), "0x1", "", "alice")
+	assert.NoError(err)
+	coalesced, err := jobStore.Create([]byte(`{"vkey_hash":"0x1"}`), "0x1", "", "alice")
+	assert.NoError(err)
+	assert.Equal(job.ID, coalesced.CoalescedWith)
+
+	now := time.Now()
+	p := jobProgress(job, now)
+	assert.Equal(phaseQueued, p.Phase)
+	assert.Equal(0.0, p.Percent)
+
+	job, err = jobStore.Start(job.ID)
+	assert.NoError(err)
+	setJobPhase(job, phaseProve)
+	t.Cleanup(func() { clearJobPhase(job.ID) })
+	// without a previous proof of the program the percentage is that of the phase
+	p = jobProgress(job, now)
+	assert.Equal(phaseProve, p.Phase)
+	assert.Equal(phasePercent[phaseProve], p.Percent)
+	assert.Equal(int64(0), p.RemainingMs)
+
+	recordProveDuration("0x1", 10*time.Second)
+	t.Cleanup(func() { recordProveDuration("0x1", 0) })
+	p = jobProgress(job, time.Now().Add(5*time.Second))
+	assert.InDelta(phasePercent[phaseProve]+(phasePercent[phaseSign]-phasePercent[phaseProve])/2, p.Percent, 1)
+	assert.InDelta(5000, p.RemainingMs, 1000)
+	// a slower proof does not reach the next phase
+	p = jobProgress(job, time.Now().Add(time.Minute))
+	assert.Less(p.Percent, phasePercent[phaseSign])
+	assert.Equal(int64(0), p.RemainingMs)
+	assert.Equal(phaseProve, jobProgress(coalesced, now).Phase)
+
+	job, err = jobStore.Finish(job.ID, "proof", nil, nil)
+	assert.NoError(err)
+	p = jobProgress(job, now)
+	assert.Equal(phaseDone, p.Phase)
+	assert.Equal(100.0, p.Percent)
+}
+
+func TestStreamJobProgress(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	var err error
+	jobStore, err = jobs.OpenStore(t.TempDir())
+	assert.NoError(err)
+	job, err := jobStore.Create([]byte(`{}`), "0x1", "", "")
+	assert.NoError(err)
+	job, err = jobStore.Start(job.ID)
+	assert.NoError(err)
+	setJobPhase(job, phaseWitness)
+	t.Cleanup(func() { clearJobPhase(job.ID) })
+	interval := progressInterval
+	progressInterval = 10 * time.Millisecond
+	t.Cleanup(func() { progressInterval = interval })
+
+	e := echo.New()
+	registerRoutes(e, nil, nil, nil)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_, _ = jobStore.Finish(job.ID, "", nil, nil)
+	}()
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID+"/progress", nil))
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Equal("application/x-ndjson", rec.Header().Get(echo.HeaderContentType))
+
+	var lines []JobProgress
+	scanner := bufio.NewScanner(bytes.NewReader(rec.Body.Bytes()))
+	for scanner.Scan() {
+		var p JobProgress
+		assert.NoError(json.Unmarshal(scanner.Bytes(), &p))
+		lines = append(lines, p)
+	}
+	assert.Greater(len(lines), 1)
+	assert.Equal(phaseWitness, lines[0].Phase)
+	assert.Equal(jobs.Succeeded, lines[len(lines)-1].Status)
+	assert.Equal(phaseDone, lines[len(lines)-1].Phase)
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/unknown/progress", nil))
+	assert.Equal(http.StatusNotFound, rec.Code)
+}
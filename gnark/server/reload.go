@@ -0,0 +1,107 @@
+package server
+
+import (
+	"github.com/brevis-network/pico/gnark/sdk"
+	"github.com/celer-network/goutils/log"
+	"github.com/labstack/echo"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"sync"
+	"syscall"
+)
+
+// keyGeneration is one loaded set of keys, by tenant, and the proofs running on it.
+type keyGeneration struct {
+	registries map[string]*sdk.KeyRegistry
+	inflight   sync.WaitGroup
+}
+
+var (
+	current   *keyGeneration
+	currentMu sync.RWMutex
+	// reloadMu serializes reloads, so at most two key sets are in memory.
+	reloadMu sync.Mutex
+	// loadKeys loads the keys of the tenants from the flags.
+	loadKeys = loadTenantRegistries
+)
+
+// acquireKeys returns the current keys, nil while they are loading. The caller must
+// call release once its proof is done.
+func acquireKeys() *keyGeneration {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	if current == nil {
+		return nil
+	}
+	current.inflight.Add(1)
+	return current
+}
+
+func (g *keyGeneration) release() {
+	g.inflight.Done()
+}
+
+func keysLoaded() bool {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current != nil
+}
+
+// reloadKeys loads the keys from the flags again and switches new proofs to them.
+// Proofs already running finish on the old keys, which are released afterwards. If
+// loading fails, the old keys stay in use.
+func reloadKeys() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	before := sdk.CurrentRSS()
+	loaded, err := loadKeys()
+	if err != nil {
+		return err
+	}
+
+	currentMu.Lock()
+	old := current
+	current = &keyGeneration{registries: loaded}
+	currentMu.Unlock()
+	jobMemoryGovernor.keysLoaded(before, sdk.CurrentRSS())
+
+	if old != nil {
+		log.Infof("keys reloaded, waiting for in-flight proofs on the old keys")
+		old.inflight.Wait()
+		// proofs whose request went away may still be computing on the old keys
+		for _, registry := range old.registries {
+			registry.Wait()
+		}
+		old.registries = nil
+		debug.FreeOSMemory()
+		jobMemoryGovernor.keysReleased()
+		log.Infof("old keys released")
+	}
+	return nil
+}
+
+// Reload is the admin endpoint reloading the keys, answering once the old keys are
+// drained.
+func Reload(c echo.Context) error {
+	err := reloadKeys()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "fail to reload keys: "+err.Error())
+	}
+	return c.String(http.StatusOK, "reloaded")
+}
+
+// reloadOnSighup reloads the keys whenever the process receives SIGHUP.
+func reloadOnSighup() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	for range sig {
+		log.Infof("SIGHUP received, reloading keys")
+		err := reloadKeys()
+		if err != nil {
+			log.Errorf("fail to reload keys, keep the old ones, err: %v", err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"errors"
+	"github.com/brevis-network/pico/gnark/sdk"
+	"github.com/consensys/gnark/test"
+	"github.com/labstack/echo"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// useKeys makes reloadKeys load registries from load and clears the keys afterwards.
+func useKeys(t *testing.T, load func() (map[string]*sdk.KeyRegistry, error)) {
+	loadKeys = load
+	t.Cleanup(func() {
+		loadKeys = loadTenantRegistries
+		currentMu.Lock()
+		current = nil
+		currentMu.Unlock()
+	})
+}
+
+func TestReloadDrainsOldKeys(t *testing.T) {
+	assert := test.NewAssert(t)
+	useKeys(t, func() (map[string]*sdk.KeyRegistry, error) {
+		return map[string]*sdk.KeyRegistry{"": sdk.NewKeyRegistry()}, nil
+	})
+
+	assert.NoError(reloadKeys())
+	old := acquireKeys()
+	assert.NotNil(old)
+	oldRegistry := old.registries[""]
+
+	reloaded := make(chan error, 1)
+	go func() {
+		reloaded <- reloadKeys()
+	}()
+
+	// new proofs get the new keys while the old proof is still running
+	var keys *keyGeneration
+	for keys = acquireKeys(); keys == old; keys = acquireKeys() {
+		keys.release()
+		time.Sleep(time.Millisecond)
+	}
+	assert.True(keys.registries[""] != oldRegistry)
+	keys.release()
+	select {
+	case <-reloaded:
+		t.Fatal("reload returned before the old keys were drained")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	old.release()
+	assert.NoError(<-reloaded)
+	assert.Nil(old.registries, "old keys are released")
+}
+
+func TestReloadFailureKeepsKeys(t *testing.T) {
+	assert := test.NewAssert(t)
+	registry := sdk.NewKeyRegistry()
+	useKeys(t, func() (map[string]*sdk.KeyRegistry, error) {
+		return map[string]*sdk.KeyRegistry{"": registry}, nil
+	})
+	assert.NoError(reloadKeys())
+
+	loadKeys = func() (map[string]*sdk.KeyRegistry, error) {
+		return nil, errors.New("missing pk")
+	}
+	assert.Error(reloadKeys())
+	keys := acquireKeys()
+	assert.NotNil(keys)
+	assert.True(keys.registries[""] == registry)
+	keys.release()
+}
+
+func TestReloadRoute(t *testing.T) {
+	assert := test.NewAssert(t)
+	useKeys(t, func() (map[string]*sdk.KeyRegistry, error) {
+		return map[string]*sdk.KeyRegistry{"": sdk.NewKeyRegistry()}, nil
+	})
+	auth := []echo.MiddlewareFunc{requireAPIKey([]apiKey{{client: "alice", key: []byte("a")}})}
+	reload := func(e *echo.Echo, key string) int {
+		req := httptest.NewRequest(http.MethodPost, "/admin/reload", nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// without admin keys there is no reload route, whatever the client key
+	e := echo.New()
+	registerRoutes(e, auth, auth, nil)
+	assert.Equal(http.StatusNotFound, reload(e, "a"))
+
+	e = echo.New()
+	registerRoutes(e, auth, auth, []apiKey{{client: "ops", key: []byte("admin")}})
+	assert.Equal(http.StatusUnauthorized, reload(e, "a"))
+	assert.Equal(http.StatusOK, reload(e, "admin"))
+	assert.True(keysLoaded())
+}
@@ -0,0 +1,333 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/sdk"
+	"github.com/brevis-network/pico/gnark/server/audit"
+	"github.com/brevis-network/pico/gnark/server/jobs"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/celer-network/goutils/log"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/labstack/echo"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"net/http"
+)
+
+var (
+	httpPort        *int
+	field           *string
+	curve           *string
+	pkPath          *string
+	ccsPath         *string
+	apiKeysPath     *string
+	adminKeysPath   *string
+	rateLimit       *float64
+	rateBurst       *int
+	trustProxy      *bool
+	jobsDir         *string
+	nbWorkers       *int
+	maxMemory       *int
+	jobRetries      *int
+	jobRetryBackoff *time.Duration
+	hashToField     *string
+	publicOrder     *string
+	digestBits      *string
+	publicPacking   *string
+	publicValues    *string
+	digestHash      *string
+	fixedVkeyHash   *string
+	registryPath    *string
+	fastKeys        *bool
+	keyPassphrase   *string
+	keyIdentity     *string
+	proverKeyPath   *string
+	proverScheme    *string
+	auditLogPath    *string
+	tenantsPath     *string
+
+	// proverKey signs the proofs served, nil if -prover-key-file is unset.
+	proverKey *utils.ProverKey
+)
+
+// RegisterFlags defines the flags of the prover server on fs. They must be parsed
+// before Serve is called.
+func RegisterFlags(fs *flag.FlagSet) {
+	httpPort = fs.Int("httpport", 9099, "http json listening port")
+	field = fs.String("field", "kb", "field: kb, bb")
+	curve = fs.String("curve", "bn254", "curve of the groth16 wrapper, only bn254 is supported")
+	pkPath = fs.String("pk", "./data/vm_pk", "path of proving key")
+	ccsPath = fs.String("ccs", "./data/vm_ccs", "path of ccs")
+	apiKeysPath = fs.String("api-keys", "", "path of a file with one api key per line, optionally preceded by a client name; requires a key on /prove and /jobs")
+	adminKeysPath = fs.String("admin-keys", "", "path of a file of api keys allowed on /admin/reload, which is only served if set")
+	rateLimit = fs.Float64("rate-limit", 0, "prove requests per minute allowed per client (0 for no limit)")
+	rateBurst = fs.Int("rate-burst", 1, "prove requests a client may send at once")
+	trustProxy = fs.Bool("trusted-proxy", false, "rate limit anonymous clients by X-Forwarded-For/X-Real-IP; only set behind a proxy overwriting them")
+	jobsDir = fs.String("jobs-dir", "./data/jobs", "directory persisting the proof jobs submitted to /jobs")
+	nbWorkers = fs.Int("workers", 1, "number of jobs proved at the same time")
+	maxMemory = fs.Int("max-memory", 0, "memory in MB the keys and jobs may use: the keys are kept loaded if they all fit with a proof and loaded for their proofs otherwise, programs whose keys do not fit with a proof are rejected, and fewer than -workers jobs are proved at once when their measured peak memory does not fit (0 for no limit)")
+	jobRetries = fs.Int("job-retries", 3, "times a job is retried after a transient failure, as an i/o error; a witness that does not solve is not retried")
+	jobRetryBackoff = fs.Duration("job-retry-backoff", 10*time.Second, "delay before the first retry of a job, doubling with every retry")
+	hashToField = fs.String("hash-to-field", "keccak256", "hash of the proof commitment to the field: keccak256/sha256/poseidon2, verifiers must use the same")
+	publicOrder = fs.String("public-input-order", "vkey-first", "order of the vkey hash and committed values digest in the public inputs, as set up: vkey-first/digest-first")
+	digestBits = fs.String("public-input-digest-bits", "0", "bits of the committed values digest public input, as set up (0 keeps the field element)")
+	publicPacking = fs.String("public-input-packing", "field", "packing of the public inputs, as set up: field/limbs")
+	publicValues = fs.String("public-input-values", "", "public values exposed in the public inputs, as set up: words=N/root=N (none if empty)")
+	digestHash = fs.String("public-input-digest-hash", "sha256", "hash of the public values the digest public input is, as set up: sha256/keccak256")
+	fixedVkeyHash = fs.String("public-input-vkey", "", "vkey hash of the only program the circuit verifies, as set up (any program if empty)")
+	registryPath = fs.String("registry", "", "path of key registry manifest json, serves every listed program instead of -pk/-ccs")
+	tenantsPath = fs.String("tenants", "", "path of a json array of tenants, each with its own key dir and quotas, selected by the X-Pico-Tenant header; replaces -pk/-ccs/-registry")
+	fastKeys = fs.Bool("fast-keys", false, "read the keys without checking their points, several times faster; only for keys from a trusted setup")
+	keyPassphrase = fs.String("key-passphrase-file", "", "file holding the passphrase decrypting keys encrypted at setup")
+	keyIdentity = fs.String("key-identity", "", "age identity file decrypting keys encrypted to its public key at setup")
+	proverKeyPath = fs.String("prover-key-file", "", "identity key file the proofs are signed with, returned in the X-Pico-Signature header and with the jobs (unsigned if empty)")
+	proverScheme = fs.String("prover-key-scheme", "ed25519", "scheme of -prover-key-file: ed25519/secp256k1")
+	auditLogPath = fs.String("audit-log", "", "append-only json lines file recording every proof: client, witness and vkey hashes, duration and result, exported by /admin/audit (none if empty)")
+}
+
+// Serve runs the prover server until it receives SIGINT or SIGTERM, then waits for
+// the proofs in flight.
+func Serve() {
+	if _, err := utils.NewHashToField(*hashToField); err != nil {
+		log.Fatalf("invalid -hash-to-field: %v", err)
+	}
+	os.Setenv("HASH_TO_FIELD", *hashToField)
+	if _, err := utils.ParsePublicLayout(*publicOrder, *digestBits, *publicPacking, *publicValues, *digestHash, *fixedVkeyHash); err != nil {
+		log.Fatalf("invalid public input layout: %v", err)
+	}
+	os.Setenv("PUBLIC_INPUT_ORDER", *publicOrder)
+	os.Setenv("PUBLIC_INPUT_DIGEST_BITS", *digestBits)
+	os.Setenv("PUBLIC_INPUT_PACKING", *publicPacking)
+	os.Setenv("PUBLIC_INPUT_VALUES", *publicValues)
+	os.Setenv("PUBLIC_INPUT_DIGEST_HASH", *digestHash)
+	os.Setenv("PUBLIC_INPUT_VKEY", *fixedVkeyHash)
+	if *fastKeys {
+		os.Setenv("FAST_KEYS", "1")
+	}
+	os.Setenv("KEY_PASSPHRASE_FILE", *keyPassphrase)
+	os.Setenv("KEY_IDENTITY_FILE", *keyIdentity)
+	os.Setenv("PROVER_KEY_FILE", *proverKeyPath)
+	os.Setenv("PROVER_KEY_SCHEME", *proverScheme)
+	if key, err := utils.ProverKeyFromEnv(); err != nil {
+		log.Fatalf("invalid -prover-key-file: %v", err)
+	} else if key != nil {
+		proverKey = key
+		log.Infof("signing proofs as %s prover %s", *proverScheme, key.Signer())
+	}
+	if *tenantsPath != "" {
+		loaded, err := loadTenants(*tenantsPath)
+		if err != nil {
+			log.Fatalf("fail to load tenants, err: %v", err)
+		}
+		tenants = loaded
+		log.Infof("serving %d tenants", len(tenants))
+	}
+	e := echo.New()
+
+	e.GET("/healthz", Healthz)
+	e.GET("/readyz", Readyz)
+	e.GET("/metrics", Metrics)
+	e.POST("/ready", Ready)
+
+	// every job route needs a key, only the routes starting a proof are rate limited
+	var auth, limited []echo.MiddlewareFunc
+	if *apiKeysPath != "" {
+		keys, err := loadAPIKeys(*apiKeysPath)
+		if err != nil {
+			log.Fatalf("fail to load api keys, err: %v", err)
+		}
+		log.Infof("loaded %d api keys", len(keys))
+		auth = append(auth, requireAPIKey(keys))
+	}
+	var adminKeys []apiKey
+	if *adminKeysPath != "" {
+		var err error
+		adminKeys, err = loadAPIKeys(*adminKeysPath)
+		if err != nil {
+			log.Fatalf("fail to load admin keys, err: %v", err)
+		}
+	}
+	limited = append(limited, auth...)
+	if *rateLimit > 0 {
+		limited = append(limited, newRateLimiter(*rateLimit, *rateBurst, *trustProxy).middleware)
+	}
+	registerRoutes(e, auth, limited, adminKeys)
+
+	var err error
+	jobStore, err = jobs.OpenStore(*jobsDir)
+	if err != nil {
+		log.Fatalf("fail to open job store, err: %v", err)
+	}
+	if *auditLogPath != "" {
+		auditLog, err = audit.Open(*auditLogPath)
+		if err != nil {
+			log.Fatalf("fail to open audit log, err: %v", err)
+		}
+		defer auditLog.Close()
+	}
+
+	jobMemoryGovernor = newMemoryGovernor(uint64(*maxMemory)<<20, *nbWorkers, sdk.CurrentRSS)
+	go jobMemoryGovernor.sampleEvery(memorySampleInterval, nil)
+
+	// serve health checks while the keys load, which takes minutes
+	go func() {
+		err := reloadKeys()
+		if err != nil {
+			log.Fatalf("fail to load keys, err: %v", err)
+		}
+		startWorkers(*nbWorkers)
+		accepting.Store(true)
+	}()
+	go reloadOnSighup()
+
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		log.Infof("shutting down, waiting for in-flight proofs and jobs")
+		accepting.Store(false)
+		err := e.Shutdown(context.Background())
+		if err != nil {
+			log.Errorf("fail to shut down echo server, err: %v", err)
+		}
+		jobQueue.close()
+		workers.Wait()
+	}()
+
+	log.Infof("start http %s", fmt.Sprintf("0.0.0.0:%d", *httpPort))
+	echoErr := e.Start(fmt.Sprintf("0.0.0.0:%d", *httpPort))
+	if echoErr != nil && echoErr != http.ErrServerClosed {
+		log.Fatalf("fail to start echo server, err: %v", echoErr)
+	}
+	<-shutdown
+}
+
+// registerRoutes adds the prover routes: auth guards every route, limited guards the
+// routes starting a proof, and both then select the tenant. The admin routes are only
+// added with admin keys.
+func registerRoutes(e *echo.Echo, auth, limited []echo.MiddlewareFunc, adminKeys []apiKey) {
+	auth = append(append([]echo.MiddlewareFunc{}, auth...), selectTenant)
+	limited = append(append([]echo.MiddlewareFunc{}, limited...), selectTenant)
+	e.POST("/prove", Prove, limited...)
+	if len(adminKeys) > 0 {
+		e.POST("/admin/reload", Reload, requireAPIKey(adminKeys))
+		e.GET("/admin/audit", ExportAudit, requireAPIKey(adminKeys))
+	}
+	e.POST("/jobs", CreateJob, limited...)
+	e.GET("/jobs", ListJobs, auth...)
+	e.GET("/jobs/:id", GetJob, auth...)
+	e.GET("/jobs/:id/progress", StreamJobProgress, auth...)
+	e.POST("/jobs/:id/requeue", RequeueJob, limited...)
+}
+
+// readFlagKeys reads the keys of the single tenant: the -registry manifest, or -pk and
+// -ccs.
+func readFlagKeys() (*tenantKeys, error) {
+	if *registryPath != "" {
+		log.Infof("read key registry %s", *registryPath)
+		entries, err := sdk.ReadRegistryManifest(*registryPath)
+		if err != nil {
+			return nil, err
+		}
+		return &tenantKeys{entries: entries}, nil
+	}
+
+	log.Infof("use field: %s, curve: %s", *field, *curve)
+	curveID, err := utils.ParseCurve(*curve)
+	if err != nil {
+		return nil, err
+	}
+	return &tenantKeys{field: *field, fallback: &sdk.KeySource{Curve: curveID, PkPath: *pkPath, CcsPath: *ccsPath}}, nil
+}
+
+func Ready(c echo.Context) error {
+	return json.NewEncoder(c.Response()).Encode("success")
+}
+
+type ProveReq struct {
+	WitnessJsonHex string `json:"witness_json_hex"`
+}
+
+type ProveResp struct {
+	ProofData string `json:"proof_data"`
+}
+
+func Prove(c echo.Context) error {
+	payload, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if !accepting.Load() {
+		return c.String(http.StatusServiceUnavailable, "prover not ready")
+	}
+	t := requestTenant(c)
+	// a request of a witness already being proved waits for that proof, without a slot
+	key := inflightKey(t, payload)
+	p, leader := joinProof(key)
+	start := time.Now()
+	if leader {
+		p.res, p.signature, p.err = proveShared(c.Request().Context(), t, payload)
+		finishProof(key, p)
+	} else {
+		select {
+		case <-p.done:
+		case <-c.Request().Context().Done():
+			return c.Request().Context().Err()
+		}
+	}
+	res, signature, err := p.res, p.signature, p.err
+	if errors.Is(err, errTenantBusy) {
+		return c.String(http.StatusTooManyRequests, fmt.Sprintf("tenant %s has %d proofs in flight", t.config.Name, t.config.MaxInflight))
+	}
+	if !errors.Is(err, errNotReady) {
+		recordProve(c, t, payload, time.Since(start), signature, !leader, err)
+	}
+	if errors.Is(err, errNotReady) {
+		return c.String(http.StatusServiceUnavailable, "prover not ready")
+	}
+	if errors.Is(err, sdk.ErrUnknownProgram) || errors.Is(err, sdk.ErrInvalidWitness) {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if errors.Is(err, sdk.ErrInsufficientMemory) {
+		return c.String(http.StatusInsufficientStorage, err.Error())
+	}
+	if err != nil {
+		return fmt.Errorf("fail to prove groth16: %v", err)
+	}
+	if signature != nil {
+		c.Response().Header().Set("X-Pico-Signature-Scheme", signature.Scheme)
+		c.Response().Header().Set("X-Pico-Signer", signature.Signer)
+		c.Response().Header().Set("X-Pico-Signature", signature.Signature)
+	}
+
+	return json.NewEncoder(c.Response()).Encode(res.Legacy())
+}
+
+// signedOnChainProof returns the on-chain form of a proof and its signature by the
+// prover key, nil without one.
+func signedOnChainProof(pf groth16.Proof, pubWitness witness.Witness) (*utils.OnChainProof, *utils.ProofSignature, error) {
+	res, err := utils.GetAggOnChainProof(pf, pubWitness)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get OnChainProof: %v", err)
+	}
+	if proverKey == nil {
+		return res, nil, nil
+	}
+	signature, err := proverKey.Sign(res)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, signature, nil
+}
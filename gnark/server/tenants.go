@@ -0,0 +1,317 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/sdk"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/celer-network/goutils/log"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/labstack/echo"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// tenantHeader selects the tenant of a request on a server started with -tenants.
+	tenantHeader = "X-Pico-Tenant"
+	// tenantKey is the context key holding the tenant of the request.
+	tenantKey = "tenant"
+)
+
+var errNotReady = errors.New("prover not ready")
+
+// TenantConfig is one element of the json array of -tenants: a Pico application
+// served with its own keys and quotas.
+type TenantConfig struct {
+	Name string `json:"name"`
+	// KeyDir holds the keys of the tenant: vm_pk and vm_ccs, or the registry manifest.
+	KeyDir string `json:"key_dir"`
+	// Registry is the path of a key registry manifest, relative to KeyDir, serving
+	// several programs of the tenant instead of vm_pk and vm_ccs.
+	Registry string `json:"registry,omitempty"`
+	Field    string `json:"field,omitempty"`
+	Curve    string `json:"curve,omitempty"`
+	// Clients are the names of the api key clients allowed to use the tenant, every
+	// client if empty.
+	Clients []string `json:"clients,omitempty"`
+	// MaxInflight bounds the proofs of the tenant computing at once on /prove, and
+	// MaxQueued its queued jobs. 0 leaves them unbounded.
+	MaxInflight int `json:"max_inflight,omitempty"`
+	MaxQueued   int `json:"max_queued,omitempty"`
+}
+
+// tenant is a tenant of the server. Without -tenants, the server has the single tenant
+// "" configured by the flags.
+type tenant struct {
+	config TenantConfig
+	// inflight holds a token per proof running on /prove, nil without MaxInflight.
+	inflight chan struct{}
+	metrics  *tenantMetrics
+}
+
+// tenants are the tenants of the server by name.
+var tenants = map[string]*tenant{"": newTenant(TenantConfig{})}
+
+func newTenant(config TenantConfig) *tenant {
+	t := &tenant{config: config, metrics: newTenantMetrics()}
+	if config.MaxInflight > 0 {
+		t.inflight = make(chan struct{}, config.MaxInflight)
+	}
+	return t
+}
+
+// loadTenants reads the json array of TenantConfig at path.
+func loadTenants(path string) (map[string]*tenant, error) {
+	data, err := utils.ReadArtifact(path)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read tenants: %v", err)
+	}
+	var configs []TenantConfig
+	err = json.Unmarshal(data, &configs)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse tenants %s: %v", path, err)
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("no tenant in %s", path)
+	}
+	loaded := make(map[string]*tenant)
+	for i, config := range configs {
+		if config.Name == "" {
+			return nil, fmt.Errorf("tenant %d has no name", i)
+		}
+		if loaded[config.Name] != nil {
+			return nil, fmt.Errorf("tenant %s defined twice", config.Name)
+		}
+		if config.KeyDir == "" {
+			return nil, fmt.Errorf("tenant %s has no key_dir", config.Name)
+		}
+		if config.Field == "" {
+			config.Field = "kb"
+		}
+		if config.Curve == "" {
+			config.Curve = "bn254"
+		}
+		loaded[config.Name] = newTenant(config)
+	}
+	return loaded, nil
+}
+
+// multiTenant reports whether the server was started with -tenants.
+func multiTenant() bool {
+	return tenants[""] == nil
+}
+
+// tenantKeys are the key sets of a tenant, read before any is loaded so that one
+// loading strategy fits those of every tenant in -max-memory.
+type tenantKeys struct {
+	// entries are the programs of a registry manifest.
+	entries []sdk.RegistryEntry
+	// fallback serves every program of field without a manifest.
+	field    string
+	fallback *sdk.KeySource
+}
+
+func (k *tenantKeys) sources() ([]sdk.KeySource, error) {
+	if k.fallback != nil {
+		return []sdk.KeySource{*k.fallback}, nil
+	}
+	var sources []sdk.KeySource
+	for _, entry := range k.entries {
+		source, err := entry.Source()
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+func (k *tenantKeys) load(loader *sdk.KeyLoader) (*sdk.KeyRegistry, error) {
+	if k.fallback == nil {
+		return sdk.LoadRegistryEntries(k.entries, loader)
+	}
+	session, err := loader.Load(*k.fallback)
+	if err != nil {
+		return nil, err
+	}
+	registry := sdk.NewKeyRegistry()
+	registry.SetFallback(k.field, session)
+	return registry, nil
+}
+
+// loadTenantRegistries loads the keys of every tenant, within -max-memory.
+func loadTenantRegistries() (map[string]*sdk.KeyRegistry, error) {
+	keys := make(map[string]*tenantKeys)
+	if multiTenant() {
+		for name, t := range tenants {
+			k, err := t.readKeys()
+			if err != nil {
+				return nil, fmt.Errorf("tenant %s: %v", name, err)
+			}
+			keys[name] = k
+		}
+	} else {
+		k, err := readFlagKeys()
+		if err != nil {
+			return nil, err
+		}
+		keys[""] = k
+	}
+
+	var sources []sdk.KeySource
+	for name, k := range keys {
+		tenantSources, err := k.sources()
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %v", name, err)
+		}
+		sources = append(sources, tenantSources...)
+	}
+	loader, err := sdk.NewKeyLoader(uint64(*maxMemory)<<20, sources)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("start load %d key sets, %s", len(sources), loader)
+	jobMemoryGovernor.estimateProof(loader.ProofEstimate())
+	registries := make(map[string]*sdk.KeyRegistry)
+	for name, k := range keys {
+		registry, err := k.load(loader)
+		if err != nil {
+			if multiTenant() {
+				return nil, fmt.Errorf("tenant %s: %v", name, err)
+			}
+			return nil, err
+		}
+		registries[name] = registry
+	}
+	log.Infof("end load keys")
+	return registries, nil
+}
+
+func (t *tenant) readKeys() (*tenantKeys, error) {
+	if t.config.Registry != "" {
+		path := t.config.Registry
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(t.config.KeyDir, path)
+		}
+		entries, err := sdk.ReadRegistryManifest(path)
+		if err != nil {
+			return nil, err
+		}
+		return &tenantKeys{entries: entries}, nil
+	}
+	curve, err := utils.ParseCurve(t.config.Curve)
+	if err != nil {
+		return nil, err
+	}
+	return &tenantKeys{field: t.config.Field, fallback: &sdk.KeySource{
+		Curve:   curve,
+		PkPath:  filepath.Join(t.config.KeyDir, "vm_pk"),
+		CcsPath: filepath.Join(t.config.KeyDir, "vm_ccs"),
+	}}, nil
+}
+
+// checkMemory returns sdk.ErrInsufficientMemory if the keys of vkeyHash do not fit in
+// -max-memory, so a job is rejected before it is queued.
+func (t *tenant) checkMemory(vkeyHash string) error {
+	keys := acquireKeys()
+	if keys == nil {
+		return nil
+	}
+	defer keys.release()
+	registry := keys.registries[t.config.Name]
+	if registry == nil {
+		return nil
+	}
+	program, err := registry.Lookup(vkeyHash)
+	if err != nil {
+		return nil
+	}
+	return program.Session.CheckMemory()
+}
+
+// allows reports whether the api key client may use t.
+func (t *tenant) allows(client string) bool {
+	if len(t.config.Clients) == 0 {
+		return true
+	}
+	for _, c := range t.config.Clients {
+		if c == client {
+			return true
+		}
+	}
+	return false
+}
+
+// selectTenant resolves the tenant of a request from the X-Pico-Tenant header and
+// checks its client may use it. Without -tenants, every request is for tenant "".
+func selectTenant(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		name := ""
+		if multiTenant() {
+			name = c.Request().Header.Get(tenantHeader)
+			if name == "" {
+				return c.String(http.StatusBadRequest, "missing "+tenantHeader+" header")
+			}
+		}
+		t := tenants[name]
+		if t == nil {
+			return c.String(http.StatusNotFound, "unknown tenant")
+		}
+		client, _ := c.Get(clientKey).(string)
+		if !t.allows(client) {
+			return c.String(http.StatusForbidden, "client not allowed on tenant "+name)
+		}
+		c.Set(tenantKey, t)
+		return next(c)
+	}
+}
+
+// requestTenant returns the tenant selected by selectTenant.
+func requestTenant(c echo.Context) *tenant {
+	t, _ := c.Get(tenantKey).(*tenant)
+	return t
+}
+
+// tryAcquire takes a slot of MaxInflight, false if all are taken.
+func (t *tenant) tryAcquire() bool {
+	if t.inflight == nil {
+		return true
+	}
+	select {
+	case t.inflight <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *tenant) release() {
+	if t.inflight != nil {
+		<-t.inflight
+	}
+}
+
+// prove proves the witness json data with the current keys of t, errNotReady while
+// they are loading, and records it in the metrics of t.
+func (t *tenant) prove(ctx context.Context, data []byte) (groth16.Proof, witness.Witness, error) {
+	keys := acquireKeys()
+	if keys == nil {
+		return nil, nil, errNotReady
+	}
+	defer keys.release()
+	registry := keys.registries[t.config.Name]
+	if registry == nil {
+		return nil, nil, fmt.Errorf("no keys loaded for tenant %s", t.config.Name)
+	}
+
+	t.metrics.start()
+	start := time.Now()
+	pf, pubWitness, err := registry.ProveWitness(ctx, data)
+	t.metrics.finish(time.Since(start), err)
+	return pf, pubWitness, err
+}
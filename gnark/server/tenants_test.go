@@ -0,0 +1,118 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"github.com/brevis-network/pico/gnark/sdk"
+	"github.com/brevis-network/pico/gnark/server/jobs"
+	"github.com/consensys/gnark/test"
+	"github.com/labstack/echo"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadTenants(t *testing.T) {
+	assert := test.NewAssert(t)
+	path := filepath.Join(t.TempDir(), "tenants.json")
+
+	assert.NoError(os.WriteFile(path, []byte(`[{"name": "acme", "key_dir": "/keys/acme", "max_inflight": 2}, {"name": "beta", "key_dir": "/keys/beta", "field": "bb"}]`), 0644))
+	loaded, err := loadTenants(path)
+	assert.NoError(err)
+	assert.Len(loaded, 2)
+	assert.Equal("kb", loaded["acme"].config.Field)
+	assert.Equal("bn254", loaded["acme"].config.Curve)
+	assert.Equal(2, cap(loaded["acme"].inflight))
+	assert.Equal("bb", loaded["beta"].config.Field)
+	assert.Nil(loaded["beta"].inflight)
+
+	for config, expected := range map[string]string{
+		`[]`:                     "no tenant",
+		`[{"key_dir": "/keys"}]`: "has no name",
+		`[{"name": "acme"}]`:     "has no key_dir",
+		`[{"name": "acme", "key_dir": "/keys"}, {"name": "acme", "key_dir": "/keys"}]`: "defined twice",
+	} {
+		assert.NoError(os.WriteFile(path, []byte(config), 0644))
+		_, err = loadTenants(path)
+		assert.ErrorContains(err, expected)
+	}
+}
+
+func TestTenantRoutes(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	acme := newTenant(TenantConfig{Name: "acme", Clients: []string{"alice"}, MaxInflight: 1, MaxQueued: 1})
+	tenants = map[string]*tenant{"acme": acme, "beta": newTenant(TenantConfig{Name: "beta"})}
+	t.Cleanup(func() {
+		tenants = map[string]*tenant{"": newTenant(TenantConfig{})}
+	})
+	var err error
+	jobStore, err = jobs.OpenStore(t.TempDir())
+	assert.NoError(err)
+	accepting.Store(true)
+	defer accepting.Store(false)
+
+	e := echo.New()
+	auth := []echo.MiddlewareFunc{requireAPIKey([]apiKey{{client: "alice", key: []byte("a")}, {client: "bob", key: []byte("b")}})}
+	registerRoutes(e, auth, auth, nil)
+	do := func(method, path, key, tenant string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"vkey_hash": "0x1", "committed_values_digest": "0x2"}`))
+		req.Header.Set("X-API-Key", key)
+		if tenant != "" {
+			req.Header.Set(tenantHeader, tenant)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(http.StatusBadRequest, do(http.MethodPost, "/jobs", "a", "").Code)
+	assert.Equal(http.StatusNotFound, do(http.MethodPost, "/jobs", "a", "gamma").Code)
+	assert.Equal(http.StatusForbidden, do(http.MethodPost, "/jobs", "b", "acme").Code)
+	assert.Equal(http.StatusAccepted, do(http.MethodPost, "/jobs", "a", "acme").Code)
+	rec := do(http.MethodPost, "/jobs", "a", "acme")
+	assert.Equal(http.StatusTooManyRequests, rec.Code)
+	assert.Contains(rec.Body.String(), "tenant acme has 1 queued jobs")
+	assert.Equal(http.StatusAccepted, do(http.MethodPost, "/jobs", "a", "beta").Code)
+
+	// the jobs of a tenant are not visible from another
+	listed := jobStore.List("acme", "", "", 0)
+	assert.Len(listed, 1)
+	assert.Equal(http.StatusOK, do(http.MethodGet, "/jobs/"+listed[0].ID, "a", "acme").Code)
+	assert.Equal(http.StatusNotFound, do(http.MethodGet, "/jobs/"+listed[0].ID, "a", "beta").Code)
+
+	// /prove is bounded by the proofs in flight of the tenant
+	assert.True(acme.tryAcquire())
+	rec = do(http.MethodPost, "/prove", "a", "acme")
+	assert.Equal(http.StatusTooManyRequests, rec.Code)
+	assert.Contains(rec.Body.String(), "tenant acme has 1 proofs in flight")
+	acme.release()
+	assert.Equal(http.StatusServiceUnavailable, do(http.MethodPost, "/prove", "a", "acme").Code)
+}
+
+func TestMetrics(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	acme := newTenant(TenantConfig{Name: "acme"})
+	tenants = map[string]*tenant{"acme": acme}
+	t.Cleanup(func() {
+		tenants = map[string]*tenant{"": newTenant(TenantConfig{})}
+	})
+	for _, err := range []error{nil, nil, sdk.ErrInvalidWitness, errors.New("out of memory")} {
+		acme.metrics.start()
+		acme.metrics.finish(time.Second, err)
+	}
+	acme.metrics.start()
+
+	var out bytes.Buffer
+	writeMetrics(&out)
+	assert.Contains(out.String(), `pico_proofs_total{tenant="acme",outcome="success"} 2`)
+	assert.Contains(out.String(), `pico_proofs_total{tenant="acme",outcome="rejected"} 1`)
+	assert.Contains(out.String(), `pico_proofs_total{tenant="acme",outcome="error"} 1`)
+	assert.Contains(out.String(), `pico_proofs_inflight{tenant="acme"} 1`)
+	assert.Contains(out.String(), `pico_prove_seconds_total{tenant="acme"} 4`)
+}
@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark/backend/groth16"
+	groth16_bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"io"
+	"strings"
+)
+
+// WriteBatchVerifierSolidity writes PicoBatchVerifier, a contract verifying many proofs
+// of vk in one call. As VerifyBatch in the sdk, it checks the Groth16 equations of all
+// proofs combined with weights, here derived from the calldata, with a single pairing
+// check of n+3 pairs instead of 4 per proof, and the public inputs of all proofs with
+// one scalar multiplication per input. Keys with commitments are not supported.
+func WriteBatchVerifierSolidity(w io.Writer, vk groth16.VerifyingKey) error {
+	v, ok := vk.(*groth16_bn254.VerifyingKey)
+	if !ok {
+		return fmt.Errorf("unsupported vk type %T, expected a bn254 vk", vk)
+	}
+	if len(v.CommitmentKeys) > 0 {
+		return fmt.Errorf("the batch verifier does not support keys with commitments")
+	}
+	nbPublic := len(v.G1.K) - 1
+
+	var constants, keyTerms strings.Builder
+	g1 := func(name string, p *bn254.G1Affine) {
+		fmt.Fprintf(&constants, "    uint256 constant %s_X = %s;\n    uint256 constant %s_Y = %s;\n", name, p.X.String(), name, p.Y.String())
+	}
+	g2 := func(name string, p *bn254.G2Affine) {
+		fmt.Fprintf(&constants, "    uint256 constant %s_X_0 = %s;\n    uint256 constant %s_X_1 = %s;\n", name, p.X.A0.String(), name, p.X.A1.String())
+		fmt.Fprintf(&constants, "    uint256 constant %s_Y_0 = %s;\n    uint256 constant %s_Y_1 = %s;\n", name, p.Y.A0.String(), name, p.Y.A1.String())
+	}
+	g1("ALPHA", &v.G1.Alpha)
+	g2("BETA", &v.G2.Beta)
+	g2("GAMMA", &v.G2.Gamma)
+	g2("DELTA", &v.G2.Delta)
+	for i := range v.G1.K {
+		g1(fmt.Sprintf("K_%d", i), &v.G1.K[i])
+		if i > 0 {
+			fmt.Fprintf(&keyTerms, "        (kx, ky) = ecMul(K_%d_X, K_%d_Y, s[%d]);\n        (x, y) = ecAdd(x, y, kx, ky);\n", i, i, i)
+		}
+	}
+
+	_, err := fmt.Fprintf(w, `// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.0;
+
+/// @title Batch verifier of Pico wrapper proofs
+/// @notice Verifies Groth16 proofs of the same vk in one call: for weights r_j derived from the calldata,
+/// prod e(r_j A_j, B_j) e(-sum r_j alpha, beta) e(-sum r_j L_j, gamma) e(-sum r_j C_j, delta) == 1,
+/// where L_j is the public input term of proof j.
+contract PicoBatchVerifier {
+    error ProofInvalid();
+    error PublicInputNotInField();
+    error InvalidBatchLength();
+
+    // base and scalar field moduli of BN254
+    uint256 constant P = %s;
+    uint256 constant R = %s;
+
+    // verifying key, G2 points in powers of i
+%s
+    /// @notice Reverts unless every proofs[j] is valid for inputs[j].
+    /// @param proofs The proofs as the first argument of verifyProof of the gnark verifier.
+    /// @param inputs The public inputs of the proofs.
+    function verifyProofs(uint256[8][] calldata proofs, uint256[%d][] calldata inputs) external view {
+        uint256 n = proofs.length;
+        if (n == 0 || inputs.length != n) {
+            revert InvalidBatchLength();
+        }
+        bytes32 seed = keccak256(msg.data);
+        uint256[] memory pairs = new uint256[](6 * (n + 3));
+        // s[0] is the sum of the weights, s[i] that of the weighted public input i
+        uint256[%d] memory s;
+        // sum of the weighted C points
+        uint256[2] memory c;
+        for (uint256 j = 0; j < n; j++) {
+            uint256 r = uint256(keccak256(abi.encodePacked(seed, j))) %% R;
+            addProof(pairs, s, c, proofs[j], inputs[j], r, 6 * j);
+        }
+        addKeyTerms(pairs, s, c, 6 * n);
+
+        uint256[1] memory out;
+        bool success;
+        uint256 size = pairs.length * 0x20;
+        assembly {
+            success := staticcall(gas(), 0x08, add(pairs, 0x20), size, out, 0x20)
+        }
+        if (!success || out[0] != 1) {
+            revert ProofInvalid();
+        }
+    }
+
+    function addProof(
+        uint256[] memory pairs,
+        uint256[%d] memory s,
+        uint256[2] memory c,
+        uint256[8] calldata proof,
+        uint256[%d] calldata input,
+        uint256 r,
+        uint256 o
+    ) internal view {
+        (uint256 x, uint256 y) = ecMul(proof[0], proof[1], r);
+        pairs[o] = x;
+        pairs[o + 1] = y;
+        pairs[o + 2] = proof[2];
+        pairs[o + 3] = proof[3];
+        pairs[o + 4] = proof[4];
+        pairs[o + 5] = proof[5];
+        (x, y) = ecMul(proof[6], proof[7], r);
+        (c[0], c[1]) = ecAdd(c[0], c[1], x, y);
+        s[0] = addmod(s[0], r, R);
+        for (uint256 i = 0; i < %d; i++) {
+            if (input[i] >= R) {
+                revert PublicInputNotInField();
+            }
+            s[i + 1] = addmod(s[i + 1], mulmod(r, input[i], R), R);
+        }
+    }
+
+    function addKeyTerms(uint256[] memory pairs, uint256[%d] memory s, uint256[2] memory c, uint256 o) internal view {
+        (uint256 x, uint256 y) = ecMul(ALPHA_X, ALPHA_Y, s[0]);
+        setNegatedPair(pairs, o, x, y, BETA_X_1, BETA_X_0, BETA_Y_1, BETA_Y_0);
+        uint256 kx;
+        uint256 ky;
+        (x, y) = ecMul(K_0_X, K_0_Y, s[0]);
+%s        setNegatedPair(pairs, o + 6, x, y, GAMMA_X_1, GAMMA_X_0, GAMMA_Y_1, GAMMA_Y_0);
+        setNegatedPair(pairs, o + 12, c[0], c[1], DELTA_X_1, DELTA_X_0, DELTA_Y_1, DELTA_Y_0);
+    }
+
+    function setNegatedPair(
+        uint256[] memory pairs,
+        uint256 o,
+        uint256 x,
+        uint256 y,
+        uint256 x1,
+        uint256 x0,
+        uint256 y1,
+        uint256 y0
+    ) internal pure {
+        pairs[o] = x;
+        pairs[o + 1] = (P - y) %% P;
+        pairs[o + 2] = x1;
+        pairs[o + 3] = x0;
+        pairs[o + 4] = y1;
+        pairs[o + 5] = y0;
+    }
+
+    function ecMul(uint256 x, uint256 y, uint256 scalar) internal view returns (uint256, uint256) {
+        uint256[3] memory input = [x, y, scalar];
+        uint256[2] memory result;
+        bool success;
+        assembly {
+            success := staticcall(gas(), 0x07, input, 0x60, result, 0x40)
+        }
+        if (!success) {
+            revert ProofInvalid();
+        }
+        return (result[0], result[1]);
+    }
+
+    function ecAdd(uint256 x1, uint256 y1, uint256 x2, uint256 y2) internal view returns (uint256, uint256) {
+        uint256[4] memory input = [x1, y1, x2, y2];
+        uint256[2] memory result;
+        bool success;
+        assembly {
+            success := staticcall(gas(), 0x06, input, 0x80, result, 0x40)
+        }
+        if (!success) {
+            revert ProofInvalid();
+        }
+        return (result[0], result[1]);
+    }
+}
+`, ecc.BN254.BaseField().String(), ecc.BN254.ScalarField().String(), constants.String(), nbPublic, nbPublic+1, nbPublic+1, nbPublic, nbPublic, nbPublic+1, keyTerms.String())
+	return err
+}
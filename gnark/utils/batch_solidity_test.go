@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	groth16_bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"strings"
+	"testing"
+)
+
+func TestWriteBatchVerifierSolidity(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &wrapperShapeCircuit{})
+	assert.NoError(err)
+	_, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	var out bytes.Buffer
+	assert.NoError(WriteBatchVerifierSolidity(&out, vk))
+
+	bnVk := vk.(*groth16_bn254.VerifyingKey)
+	assert.Contains(out.String(), "function verifyProofs(uint256[8][] calldata proofs, uint256[2][] calldata inputs) external view {")
+	assert.Contains(out.String(), fmt.Sprintf("uint256 constant ALPHA_X = %s;", bnVk.G1.Alpha.X.String()))
+	assert.Contains(out.String(), fmt.Sprintf("uint256 constant DELTA_Y_1 = %s;", bnVk.G2.Delta.Y.A1.String()))
+	assert.Contains(out.String(), fmt.Sprintf("uint256 constant K_2_Y = %s;", bnVk.G1.K[2].Y.String()))
+	assert.NotContains(out.String(), "K_3_X")
+	// one multiplication per public input
+	assert.Equal(2, strings.Count(out.String(), "(kx, ky) = ecMul("))
+
+	ccs, err = frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &committedCircuit{})
+	assert.NoError(err)
+	_, vk, err = groth16.Setup(ccs)
+	assert.NoError(err)
+	assert.ErrorContains(WriteBatchVerifierSolidity(&out, vk), "commitments")
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/witness"
+	"io"
+)
+
+const BundleFormatVersion = 1
+
+// bundleMagic starts every prove bundle.
+var bundleMagic = []byte("PICOBNDL")
+
+// BundleHeader describes a prove bundle: the solved full witness of a wrapper
+// circuit, and the ccs it must be proved with.
+type BundleHeader struct {
+	Version  int    `json:"version"`
+	VkeyHash string `json:"vkey_hash"`
+	// Ccs is the header of the ccs the witness was built for. The prover checks the
+	// header of its ccs against it, so it needs neither the constraints nor the
+	// witness json.
+	Ccs *CcsHeader `json:"ccs"`
+}
+
+// WriteBundle writes header and fullWitness to path, see StorageFor.
+func WriteBundle(path string, header *BundleHeader, fullWitness witness.Witness) error {
+	return WriteArtifact(path, func(w io.Writer) error {
+		err := writeHeader(w, bundleMagic, header)
+		if err != nil {
+			return err
+		}
+		_, err = fullWitness.WriteTo(w)
+		return err
+	})
+}
+
+// ReadBundle reads a prove bundle written by WriteBundle for curve.
+func ReadBundle(path string, curve ecc.ID) (*BundleHeader, witness.Witness, error) {
+	f, err := OpenArtifact(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, 1<<20)
+	var header BundleHeader
+	ok, err := readHeader(r, bundleMagic, &header)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, fmt.Errorf("%s is not a prove bundle", path)
+	}
+	if header.Version != BundleFormatVersion {
+		return nil, nil, fmt.Errorf("unsupported bundle format version %d", header.Version)
+	}
+	if header.Ccs == nil || header.Ccs.Curve != curve.String() {
+		return nil, nil, fmt.Errorf("bundle is not for curve %s", curve)
+	}
+
+	fullWitness, err := witness.New(curve.ScalarField())
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = fullWitness.ReadFrom(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fail to read bundle witness: %v", err)
+	}
+	return &header, fullWitness, nil
+}
+
+// ReadBundleHeader reads only the header of a prove bundle, nil if the file is not one.
+func ReadBundleHeader(path string) (*BundleHeader, error) {
+	f, err := OpenArtifact(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var header BundleHeader
+	ok, err := readHeader(bufio.NewReader(f), bundleMagic, &header)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &header, nil
+}
+
+// WriteWitness writes fullWitness to path in gnark's binary witness encoding, with no
+// header so other gnark provers can read it, see StorageFor.
+func WriteWitness(path string, fullWitness witness.Witness) error {
+	return WriteArtifact(path, func(w io.Writer) error {
+		_, err := fullWitness.WriteTo(w)
+		return err
+	})
+}
+
+// ReadWitness reads a full witness over curve's scalar field written by WriteWitness.
+func ReadWitness(path string, curve ecc.ID) (witness.Witness, error) {
+	f, err := OpenArtifact(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fullWitness, err := witness.New(curve.ScalarField())
+	if err != nil {
+		return nil, err
+	}
+	_, err = fullWitness.ReadFrom(bufio.NewReaderSize(f, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("fail to read witness: %v", err)
+	}
+	return fullWitness, nil
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBundle(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	dir := t.TempDir()
+	constraintsPath := filepath.Join(dir, "constraints.json")
+	assert.NoError(os.WriteFile(constraintsPath, []byte("[]"), 0644))
+	ccsHeader, err := NewCcsHeader(ecc.BN254, "kb", constraintsPath, 1)
+	assert.NoError(err)
+
+	fullWitness, err := frontend.NewWitness(&squareCircuit{X: 3, Y: 9}, ecc.BN254.ScalarField())
+	assert.NoError(err)
+	header := &BundleHeader{Version: BundleFormatVersion, VkeyHash: "9", Ccs: ccsHeader}
+	path := filepath.Join(dir, "prove_bundle.bin")
+	assert.NoError(WriteBundle(path, header, fullWitness))
+
+	readHeader, readWitness, err := ReadBundle(path, ecc.BN254)
+	assert.NoError(err)
+	assert.Equal(header, readHeader)
+	assert.NoError(readHeader.Ccs.CheckCompatible(ccsHeader))
+	want, err := fullWitness.MarshalBinary()
+	assert.NoError(err)
+	got, err := readWitness.MarshalBinary()
+	assert.NoError(err)
+	assert.Equal(want, got)
+
+	_, _, err = ReadBundle(path, ecc.BLS12_381)
+	assert.Error(err)
+	_, _, err = ReadBundle(constraintsPath, ecc.BN254)
+	assert.Error(err)
+	assert.Contains(err.Error(), "not a prove bundle")
+}
+
+func TestWitnessFile(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	fullWitness, err := frontend.NewWitness(&squareCircuit{X: 3, Y: 9}, ecc.BN254.ScalarField())
+	assert.NoError(err)
+	path := filepath.Join(t.TempDir(), "witness.bin")
+	assert.NoError(WriteWitness(path, fullWitness))
+
+	// the file is gnark's own encoding
+	data, err := os.ReadFile(path)
+	assert.NoError(err)
+	want, err := fullWitness.MarshalBinary()
+	assert.NoError(err)
+	assert.Equal(want, data)
+
+	readWitness, err := ReadWitness(path, ecc.BN254)
+	assert.NoError(err)
+	got, err := readWitness.MarshalBinary()
+	assert.NoError(err)
+	assert.Equal(want, got)
+}
@@ -0,0 +1,254 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/constraint"
+	"io"
+	"os"
+)
+
+const CcsFormatVersion = 1
+
+// ccsMagic starts every ccs file written with a header. Files without it are read as
+// the raw gnark serialization written by older versions.
+var ccsMagic = []byte("PICOCCS\x00")
+
+// CcsHeader records what a compiled constraint system was built from, so a stale ccs
+// is rejected instead of silently producing proofs for another circuit.
+type CcsHeader struct {
+	Version         int    `json:"version"`
+	GnarkVersion    string `json:"gnark_version"`
+	Curve           string `json:"curve"`
+	Field           string `json:"field"`
+	ConstraintsHash string `json:"constraints_hash"`
+	// Chunks is the number of chunk proofs the circuit verifies. Its public inputs are
+	// the vkey hash followed by one committed values digest per chunk, NbPublicInputs
+	// in total. Headers written before multi-chunk circuits leave both at 0.
+	Chunks         int `json:"chunks,omitempty"`
+	NbPublicInputs int `json:"nb_public_inputs,omitempty"`
+	// VkeyTreeSize is the number of leaves of the tree of allowed vkeys of a circuit
+	// aggregating the chunk proofs of several programs, whose root, in two limbs,
+	// replaces the vkey hash public input; 0 for the circuits of a single program.
+	VkeyTreeSize int `json:"vkey_tree_size,omitempty"`
+	// Options lists the settings that change the shape of the circuit.
+	Options map[string]string `json:"options"`
+	// CcsHash is the CcsHash of the ccs, to compare compilations across machines.
+	// Headers written before it was recorded leave it empty.
+	CcsHash string `json:"ccs_hash,omitempty"`
+}
+
+// NewCcsHeader describes the circuit verifying chunks chunk proofs, built for curve and
+// field from the constraints file and the current circuit options.
+func NewCcsHeader(curve ecc.ID, field, constraintsPath string, chunks int) (*CcsHeader, error) {
+	data, err := ReadArtifact(constraintsPath)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read constraints: %v", err)
+	}
+	hash := sha256.Sum256(data)
+
+	options := make(map[string]string)
+	for _, name := range []string{"GROTH16", "RANGE_CHECK", "POSEIDON2_CROSS_CHECK"} {
+		options[name] = os.Getenv(name)
+	}
+	layout, err := PublicLayoutFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	options["PUBLIC_INPUT_LAYOUT"] = layout.String()
+	// recorded only when set, like in ccs headers written before it
+	if threshold := os.Getenv("COMPILE_COMPRESS_THRESHOLD"); threshold != "" && threshold != "0" {
+		options["COMPILE_COMPRESS_THRESHOLD"] = threshold
+	}
+	if os.Getenv("POSEIDON2_CONSTANT_FOLDING") == "1" {
+		options["POSEIDON2_CONSTANT_FOLDING"] = "1"
+	}
+	nbPublicInputs := chunks + 1
+	if chunks == 1 {
+		nbPublicInputs = layout.NbPublicInputs()
+	}
+
+	return &CcsHeader{
+		Version:         CcsFormatVersion,
+		GnarkVersion:    GnarkVersion(),
+		Curve:           curve.String(),
+		Field:           field,
+		ConstraintsHash: hex.EncodeToString(hash[:]),
+		Chunks:          chunks,
+		NbPublicInputs:  nbPublicInputs,
+		Options:         options,
+	}, nil
+}
+
+// Aggregate records that h is the header of a circuit aggregating its chunks against
+// a tree of vkeyTreeSize allowed vkeys.
+func (h *CcsHeader) Aggregate(vkeyTreeSize int) {
+	h.VkeyTreeSize = vkeyTreeSize
+	h.NbPublicInputs = h.Chunks + 2
+}
+
+// CheckCompatible returns an error describing the first difference between h and the
+// header expected for the current run.
+func (h *CcsHeader) CheckCompatible(expected *CcsHeader) error {
+	if h.Version != CcsFormatVersion {
+		return fmt.Errorf("unsupported ccs format version %d", h.Version)
+	}
+	err := checkGnarkVersion(h.GnarkVersion, expected.GnarkVersion)
+	if err != nil {
+		return fmt.Errorf("ccs %v", err)
+	}
+	if h.Curve != expected.Curve {
+		return fmt.Errorf("ccs built for curve %s, expected %s", h.Curve, expected.Curve)
+	}
+	if h.Field != expected.Field {
+		return fmt.Errorf("ccs built for field %s, expected %s", h.Field, expected.Field)
+	}
+	if h.ConstraintsHash != expected.ConstraintsHash {
+		return fmt.Errorf("ccs built from other constraints (sha256 %s, current %s)", h.ConstraintsHash, expected.ConstraintsHash)
+	}
+	chunks := h.Chunks
+	if chunks == 0 {
+		chunks = 1
+	}
+	if chunks != expected.Chunks {
+		return fmt.Errorf("ccs built for %d chunks, witness has %d", chunks, expected.Chunks)
+	}
+	if h.VkeyTreeSize != expected.VkeyTreeSize {
+		return fmt.Errorf("ccs built for a vkey tree of %d leaves, witness has %d", h.VkeyTreeSize, expected.VkeyTreeSize)
+	}
+	if h.NbPublicInputs != 0 && h.NbPublicInputs != expected.NbPublicInputs {
+		return fmt.Errorf("ccs has %d public inputs, expected %d", h.NbPublicInputs, expected.NbPublicInputs)
+	}
+	for name, value := range expected.Options {
+		if h.Options[name] != value {
+			return fmt.Errorf("ccs built with %s=%q, current %q", name, h.Options[name], value)
+		}
+	}
+	for name, value := range h.Options {
+		if _, ok := expected.Options[name]; !ok && value != "" {
+			return fmt.Errorf("ccs built with %s=%q, current unset", name, value)
+		}
+	}
+	return nil
+}
+
+// WriteCcsArtifact writes ccs preceded by header through store.
+func WriteCcsArtifact(store ArtifactWriter, filename string, header *CcsHeader, ccs constraint.ConstraintSystem) error {
+	return store(filename, func(w io.Writer) error {
+		err := writeHeader(w, ccsMagic, header)
+		if err != nil {
+			return err
+		}
+		_, err = ccs.WriteTo(w)
+		return err
+	})
+}
+
+// ReadCcsArtifact reads a ccs file with or without header. header is nil for files
+// written without one. A header for another curve is rejected before reading the ccs.
+func ReadCcsArtifact(filename string, ccs constraint.ConstraintSystem) (*CcsHeader, error) {
+	f, err := OpenArtifact(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	progress, stop := withProgress(filename, f, artifactSize(f))
+	defer stop()
+	r := bufio.NewReaderSize(progress, 1<<20)
+	var header CcsHeader
+	ok, err := readHeader(r, ccsMagic, &header)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read ccs header: %v", err)
+	}
+	if ok {
+		c, hasCurve := ccs.(interface{ CurveID() ecc.ID })
+		if hasCurve && c.CurveID().String() != header.Curve {
+			return nil, fmt.Errorf("%s: ccs compiled for curve %s; pass -curve %s or rerun setup", filename, header.Curve, header.Curve)
+		}
+	}
+	_, err = ccs.ReadFrom(r)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &header, nil
+}
+
+// ReadCcsHeader reads only the header of a ccs file, nil if it has none.
+func ReadCcsHeader(filename string) (*CcsHeader, error) {
+	f, err := OpenArtifact(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var header CcsHeader
+	ok, err := readHeader(bufio.NewReader(f), ccsMagic, &header)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read ccs header: %v", err)
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &header, nil
+}
+
+// CcsHash returns the hex encoded sha256 of the constraints of a compiled r1cs: the
+// field, the variable counts, every constraint with its coefficients and the
+// commitments. Unlike the serialization of the ccs it leaves out the debug info, which
+// records source lines, so machines compiling the same circuit get the same hash.
+func CcsHash(ccs constraint.ConstraintSystem) (string, error) {
+	r1cs, ok := ccs.(interface{ GetR1CIterator() constraint.R1CIterator })
+	if !ok {
+		return "", fmt.Errorf("unsupported constraint system %T", ccs)
+	}
+	h := sha256.New()
+	writeInt := func(v int) {
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], uint64(v))
+		h.Write(b[:])
+	}
+	h.Write(ccs.Field().Bytes())
+	writeInt(ccs.GetNbPublicVariables())
+	writeInt(ccs.GetNbSecretVariables())
+	writeInt(ccs.GetNbInternalVariables())
+	writeInt(ccs.GetNbConstraints())
+
+	writeExpression := func(l constraint.LinearExpression) {
+		writeInt(len(l))
+		for _, t := range l {
+			writeInt(int(t.VID))
+			h.Write(ccs.GetCoefficient(int(t.CID)).Bytes())
+		}
+	}
+	it := r1cs.GetR1CIterator()
+	for r1c := it.Next(); r1c != nil; r1c = it.Next() {
+		writeExpression(r1c.L)
+		writeExpression(r1c.R)
+		writeExpression(r1c.O)
+	}
+
+	commitments, ok := ccs.GetCommitments().(constraint.Groth16Commitments)
+	if !ok {
+		return "", fmt.Errorf("unsupported commitments %T", ccs.GetCommitments())
+	}
+	writeInt(len(commitments))
+	for _, c := range commitments {
+		writeInt(c.CommitmentIndex)
+		writeInt(c.NbPublicCommitted)
+		for _, wires := range [][]int{c.PublicAndCommitmentCommitted, c.PrivateCommitted} {
+			writeInt(len(wires))
+			for _, wire := range wires {
+				writeInt(wire)
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
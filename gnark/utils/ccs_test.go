@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCcsArtifact(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &squareCircuit{})
+	assert.NoError(err)
+
+	dir := t.TempDir()
+	constraintsPath := filepath.Join(dir, "constraints.json")
+	assert.NoError(os.WriteFile(constraintsPath, []byte("[]"), 0644))
+	header, err := NewCcsHeader(ecc.BN254, "kb", constraintsPath, 1)
+	assert.NoError(err)
+
+	ccsPath := filepath.Join(dir, "vm_ccs")
+	assert.NoError(WriteCcsArtifact(WriteArtifact, ccsPath, header, ccs))
+	read := groth16.NewCS(ecc.BN254)
+	readHeader, err := ReadCcsArtifact(ccsPath, read)
+	assert.NoError(err)
+	assert.NotNil(readHeader)
+	assert.NoError(readHeader.CheckCompatible(header))
+	assert.Equal(ccs.GetNbConstraints(), read.GetNbConstraints())
+
+	other, err := NewCcsHeader(ecc.BN254, "bb", constraintsPath, 1)
+	assert.NoError(err)
+	assert.Error(readHeader.CheckCompatible(other))
+	multi, err := NewCcsHeader(ecc.BN254, "kb", constraintsPath, 3)
+	assert.NoError(err)
+	assert.Equal(4, multi.NbPublicInputs)
+	assert.Error(readHeader.CheckCompatible(multi))
+	aggregated, err := NewCcsHeader(ecc.BN254, "kb", constraintsPath, 3)
+	assert.NoError(err)
+	aggregated.Aggregate(4)
+	assert.Equal(5, aggregated.NbPublicInputs)
+	assert.ErrorContains(multi.CheckCompatible(aggregated), "vkey tree")
+
+	// headers written before the chunk count was recorded are single chunk circuits
+	legacy := *readHeader
+	legacy.Chunks, legacy.NbPublicInputs = 0, 0
+	assert.NoError(legacy.CheckCompatible(header))
+	assert.Error(legacy.CheckCompatible(multi))
+	_, err = ReadCcsArtifact(ccsPath, groth16.NewCS(ecc.BLS12_381))
+	assert.Error(err)
+
+	// a compress threshold changes the circuit, both ways
+	t.Setenv("COMPILE_COMPRESS_THRESHOLD", "0")
+	unset, err := NewCcsHeader(ecc.BN254, "kb", constraintsPath, 1)
+	assert.NoError(err)
+	assert.NoError(readHeader.CheckCompatible(unset))
+	t.Setenv("COMPILE_COMPRESS_THRESHOLD", "100")
+	compressed, err := NewCcsHeader(ecc.BN254, "kb", constraintsPath, 1)
+	assert.NoError(err)
+	assert.ErrorContains(readHeader.CheckCompatible(compressed), "COMPILE_COMPRESS_THRESHOLD")
+	assert.ErrorContains(compressed.CheckCompatible(header), "COMPILE_COMPRESS_THRESHOLD")
+
+	// files written before the header was added are still readable
+	assert.NoError(WriteCcs(ccsPath, ccs))
+	readHeader, err = ReadCcsArtifact(ccsPath, groth16.NewCS(ecc.BN254))
+	assert.NoError(err)
+	assert.Nil(readHeader)
+}
+
+func TestCcsHash(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &squareCircuit{})
+	assert.NoError(err)
+	hash, err := CcsHash(ccs)
+	assert.NoError(err)
+	assert.Len(hash, 64)
+
+	// compiling again and reading the ccs back give the same hash
+	again, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &squareCircuit{})
+	assert.NoError(err)
+	againHash, err := CcsHash(again)
+	assert.NoError(err)
+	assert.Equal(hash, againHash)
+	path := filepath.Join(t.TempDir(), "vm_ccs")
+	assert.NoError(WriteCcs(path, ccs))
+	read := groth16.NewCS(ecc.BN254)
+	_, err = ReadCcsArtifact(path, read)
+	assert.NoError(err)
+	readHash, err := CcsHash(read)
+	assert.NoError(err)
+	assert.Equal(hash, readHash)
+
+	other, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &cubeCircuit{})
+	assert.NoError(err)
+	otherHash, err := CcsHash(other)
+	assert.NoError(err)
+	assert.NotEqual(hash, otherHash)
+}
+
+type cubeCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (c *cubeCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(c.Y, api.Mul(c.X, c.X, c.X))
+	return nil
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark/backend/groth16"
+	groth16_bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"io"
+	"math/big"
+)
+
+// CommitmentKey is the Pedersen verifying key of one commitment of a wrapper vk. G2
+// points are [[x.A1, x.A0], [y.A1, y.A0]], imaginary parts first as in the proof.
+type CommitmentKey struct {
+	G         [2][2]string `json:"g"`
+	GSigmaNeg [2][2]string `json:"g_sigma_neg"`
+	// PublicInputs lists the public inputs (1-based) hashed with the commitment.
+	PublicInputs []int `json:"public_inputs"`
+}
+
+// CommitmentKeys returns the commitment keys of vk, empty for circuits without
+// commitment.
+func CommitmentKeys(vk groth16.VerifyingKey) ([]CommitmentKey, error) {
+	v, ok := vk.(*groth16_bn254.VerifyingKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported vk type %T, expected a bn254 vk", vk)
+	}
+	keys := make([]CommitmentKey, len(v.CommitmentKeys))
+	for i, key := range v.CommitmentKeys {
+		keys[i].G = encodeG2(&key.G)
+		keys[i].GSigmaNeg = encodeG2(&key.GSigmaNeg)
+		keys[i].PublicInputs = append([]int{}, v.PublicAndCommitmentCommitted[i]...)
+	}
+	return keys, nil
+}
+
+// WriteCommitmentKeySolidity writes key as a Solidity library of constants named as in
+// gnark's verifier, for contracts checking the proof commitment themselves.
+func WriteCommitmentKeySolidity(w io.Writer, key CommitmentKey) error {
+	_, err := fmt.Fprintf(w, `// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.0;
+
+/// @title Pedersen commitment key of the Pico wrapper vk
+library PicoCommitmentKey {
+    // Pedersen G point in G2 in powers of i
+    uint256 constant PEDERSEN_G_X_0 = %s;
+    uint256 constant PEDERSEN_G_X_1 = %s;
+    uint256 constant PEDERSEN_G_Y_0 = %s;
+    uint256 constant PEDERSEN_G_Y_1 = %s;
+
+    // Pedersen GSigmaNeg point in G2 in powers of i
+    uint256 constant PEDERSEN_GSIGMANEG_X_0 = %s;
+    uint256 constant PEDERSEN_GSIGMANEG_X_1 = %s;
+    uint256 constant PEDERSEN_GSIGMANEG_Y_0 = %s;
+    uint256 constant PEDERSEN_GSIGMANEG_Y_1 = %s;
+}
+`, key.G[0][1], key.G[0][0], key.G[1][1], key.G[1][0],
+		key.GSigmaNeg[0][1], key.GSigmaNeg[0][0], key.GSigmaNeg[1][1], key.GSigmaNeg[1][0])
+	return err
+}
+
+func encodeG2(p *bn254.G2Affine) [2][2]string {
+	coordinate := func(e interface{ BigInt(*big.Int) *big.Int }) string {
+		return encodeFixed(e.BigInt(new(big.Int)), coordinateSize)
+	}
+	return [2][2]string{
+		{coordinate(&p.X.A1), coordinate(&p.X.A0)},
+		{coordinate(&p.Y.A1), coordinate(&p.Y.A0)},
+	}
+}
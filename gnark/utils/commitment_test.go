@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"bytes"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/std/rangecheck"
+	"github.com/consensys/gnark/test"
+	"math/big"
+	"regexp"
+	"testing"
+)
+
+type committedCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (c *committedCircuit) Define(api frontend.API) error {
+	rangecheck.New(api).Check(c.X, 8)
+	api.AssertIsEqual(c.Y, api.Mul(c.X, c.X))
+	return nil
+}
+
+func TestCommitmentKeys(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &squareCircuit{})
+	assert.NoError(err)
+	_, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	keys, err := CommitmentKeys(vk)
+	assert.NoError(err)
+	assert.Equal(0, len(keys))
+
+	ccs, err = frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &committedCircuit{})
+	assert.NoError(err)
+	_, vk, err = groth16.Setup(ccs)
+	assert.NoError(err)
+	keys, err = CommitmentKeys(vk)
+	assert.NoError(err)
+	assert.Equal(1, len(keys))
+
+	// the constants are those of gnark's verifier
+	var ours, gnark bytes.Buffer
+	assert.NoError(WriteCommitmentKeySolidity(&ours, keys[0]))
+	assert.NoError(vk.ExportSolidity(&gnark))
+	constant := regexp.MustCompile(`uint256 constant (PEDERSEN_\w+) = (\w+);`)
+	expected := make(map[string]string)
+	for _, m := range constant.FindAllStringSubmatch(gnark.String(), -1) {
+		expected[m[1]] = m[2]
+	}
+	found := constant.FindAllStringSubmatch(ours.String(), -1)
+	assert.Equal(8, len(found))
+	for _, m := range found {
+		v, ok := new(big.Int).SetString(m[2], 0)
+		assert.True(ok)
+		assert.Equal(expected[m[1]], v.String(), m[1])
+	}
+}
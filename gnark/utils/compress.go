@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// GzipExt is appended to the path of the gzipped copy of a proof file.
+const GzipExt = ".gz"
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// CheckCompression validates a compression name; empty means none.
+func CheckCompression(compression string) error {
+	switch compression {
+	case "", CompressionNone, CompressionGzip:
+		return nil
+	default:
+		return fmt.Errorf("unknown compression %s, expected none or gzip", compression)
+	}
+}
+
+// WithCompression wraps write so its output is compressed with compression.
+func WithCompression(compression string, write func(w io.Writer) error) func(w io.Writer) error {
+	if compression != CompressionGzip {
+		return write
+	}
+	return func(w io.Writer) error {
+		zw := gzip.NewWriter(w)
+		err := write(zw)
+		if err != nil {
+			return err
+		}
+		return zw.Close()
+	}
+}
+
+// ReadProofArtifact reads a proof file written by Prove, see StorageFor, decompressing
+// it if it is the gzipped copy. Other artifacts are never decompressed.
+func ReadProofArtifact(path string) ([]byte, error) {
+	data, err := ReadArtifact(path)
+	if err != nil {
+		return nil, err
+	}
+	return decompress(data)
+}
+
+// decompress returns data unchanged unless it is gzipped.
+func decompress(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return data, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
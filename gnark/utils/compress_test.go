@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"github.com/consensys/gnark/test"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestGzipArtifact(t *testing.T) {
+	assert := test.NewAssert(t)
+	dir := t.TempDir()
+
+	for _, compression := range []string{CompressionNone, CompressionGzip} {
+		path := filepath.Join(dir, "proof.data."+compression)
+		assert.NoError(WriteArtifact(path, WithCompression(compression, func(w io.Writer) error {
+			_, err := w.Write([]byte("0x01,0x02"))
+			return err
+		})))
+		data, err := ReadProofArtifact(path)
+		assert.NoError(err)
+		assert.Equal("0x01,0x02", string(data))
+	}
+
+	// only proofs are decompressed
+	data, err := ReadArtifact(filepath.Join(dir, "proof.data.gzip"))
+	assert.NoError(err)
+	assert.Equal(gzipMagic, data[:2])
+
+	assert.NoError(CheckCompression("gzip"))
+	assert.Error(CheckCompression("zstd"))
+}
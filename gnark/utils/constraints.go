@@ -1,185 +1,351 @@
 package utils
 
 import (
+	"bytes"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
-	bn254_fr "github.com/consensys/gnark-crypto/ecc/bn254/fr"
 	"github.com/consensys/gnark/backend/groth16"
 	groth16_bn254 "github.com/consensys/gnark/backend/groth16/bn254"
-	"github.com/consensys/gnark/backend/witness"
 	"github.com/consensys/gnark/constraint"
+	gnarkio "github.com/consensys/gnark/io"
+	"io"
 	"math/big"
 	"os"
+	"strconv"
+	"strings"
 )
 
 type WitnessInput struct {
-	Vars                  []string   `json:"vars"`
-	Felts                 []string   `json:"felts"`
-	Exts                  [][]string `json:"exts"`
+	Vars                  VarArray   `json:"vars"`
+	Felts                 FeltArray  `json:"felts"`
+	Exts                  ExtArray   `json:"exts"`
 	VkeyHash              string     `json:"vkey_hash"`
 	CommittedValuesDigest string     `json:"committed_values_digest"`
+	FriConfig             *FriConfig `json:"fri_config,omitempty"`
+	// PublicValues are the 0x prefixed hex public values the digest commits to, for
+	// layouts exposing them, see PublicLayout.PublicValues.
+	PublicValues string `json:"public_values,omitempty"`
+	// Version is the witness format version, see WitnessVersion.
+	Version int `json:"version,omitempty"`
 }
 
-type Groth16Proof struct {
-	A             [2]string    `json:"a"`
-	B             [2][2]string `json:"b"`
-	C             [2]string    `json:"c"`
-	Commitment    [2]string    `json:"commitment"`
-	CommitmentPok [2]string    `json:"commitment_pok"`
+// MultiWitnessInput holds the witnesses of several chunk proofs wrapped by one circuit.
+type MultiWitnessInput struct {
+	Chunks []WitnessInput `json:"chunks"`
+	// AllowedVkeys are the vkey hashes of the programs the chunks of an aggregated
+	// witness may prove, in the order of the leaves of their tree, see VkeysRoot;
+	// empty if the chunks prove a single program.
+	AllowedVkeys []string `json:"allowed_vkeys,omitempty"`
 }
 
-func ReadProvingKey(filename string, pk groth16.ProvingKey) error {
-	f, err := os.Open(filename)
+// ParseWitness decodes a witness json. A witness with a "chunks" array is returned as
+// multi, any other as single. Witnesses of older layouts are upgraded, see
+// UpgradeWitness.
+func ParseWitness(data []byte) (single *WitnessInput, multi *MultiWitnessInput, err error) {
+	single, multi, err = parseWitness(data)
+	if err == nil && !missingCommitments(single, multi) {
+		return single, multi, nil
+	}
+	// older layouts fail to decode or decode without their commitments
+	upgraded, err := UpgradeWitness(data)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	defer f.Close()
-
-	_, err = pk.UnsafeReadFrom(f)
-	return err
+	return parseWitness(upgraded)
 }
 
-func ReadVerifyingKey(filename string, vk groth16.VerifyingKey) error {
-	f, err := os.Open(filename)
+// PublicValuesFile is the file the Rust prover writes the hex public values of a proof
+// to, without 0x, next to its witness json.
+const PublicValuesFile = "pv_file"
+
+// ReadWitnessJson reads the witness json at path. Witnesses of Rust provers before the
+// public_values field only have their public values in the PublicValuesFile next to
+// them: those of a single chunk witness without public values are added from it.
+func ReadWitnessJson(path string) ([]byte, error) {
+	data, err := ReadArtifact(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer f.Close()
-
-	_, err = vk.UnsafeReadFrom(f)
-	return err
+	pv, err := ReadArtifact(path[:strings.LastIndex(path, "/")+1] + PublicValuesFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return data, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fail to read %s: %w", PublicValuesFile, err)
+	}
+	return withPublicValues(data, "0x"+strings.TrimPrefix(strings.TrimSpace(string(pv)), "0x"))
 }
 
-func WriteProvingKey(filename string, pk groth16.ProvingKey) error {
-	f, err := os.Create(filename)
+// withPublicValues adds publicValues to the witness json data, unless it has public
+// values or chunks already. A witness that is not a json object is returned as is, for
+// ParseWitness to reject.
+func withPublicValues(data []byte, publicValues string) ([]byte, error) {
+	_, err := DecodePublicValues(publicValues)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("invalid %s: %v", PublicValuesFile, err)
 	}
-	_, err = pk.WriteTo(f)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	token, err := dec.Token()
+	if err != nil || token != json.Delim('{') {
+		return data, nil
+	}
+	empty := true
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return data, nil
+		}
+		if key == "public_values" || key == "chunks" {
+			return data, nil
+		}
+		err = dec.Decode(&skipValue{})
+		if err != nil {
+			return data, nil
+		}
+		empty = false
+	}
+	value, err := json.Marshal(publicValues)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	end := bytes.LastIndexByte(data, '}')
+	res := make([]byte, 0, len(data)+len(value)+20)
+	res = append(res, data[:end]...)
+	if !empty {
+		res = append(res, ',')
+	}
+	res = append(res, `"public_values":`...)
+	res = append(res, value...)
+	return append(res, data[end:]...), nil
+}
+
+// Validate checks the values of w are numbers of the right size and its extension
+// elements have 4 felts, so a malformed witness fails to parse instead of panicking
+// when it is assigned.
+func (w *WitnessInput) Validate() error {
+	for _, err := range []error{validateWitnessVars(w.Vars), validateWitnessFelts(w.Felts), validateWitnessExts(w.Exts)} {
+		if err != nil {
+			return err
+		}
+	}
+	return w.validateCommitments()
+}
+
+func validateWitnessVars(vars VarArray) error {
+	for i, v := range vars {
+		n, ok := new(big.Int).SetString(v, 10)
+		if !ok || n.Sign() < 0 {
+			return fmt.Errorf("invalid var %d: %q", i, v)
+		}
 	}
 	return nil
 }
 
-func WriteVerifyingKey(filename string, vk groth16.VerifyingKey) error {
-	f, err := os.Create(filename)
-	if err != nil {
-		return err
+func validateWitnessFelts(felts FeltArray) error {
+	for i, felt := range felts {
+		_, err := strconv.ParseUint(felt, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid felt %d: %q", i, felt)
+		}
 	}
+	return nil
+}
 
-	_, err = vk.WriteTo(f)
-	if err != nil {
-		return err
+func validateWitnessExts(exts ExtArray) error {
+	for i, ext := range exts {
+		if len(ext) != 4 {
+			return fmt.Errorf("ext %d has %d felts, expected 4", i, len(ext))
+		}
+		for _, felt := range ext {
+			_, err := strconv.ParseUint(felt, 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid felt of ext %d: %q", i, felt)
+			}
+		}
 	}
 	return nil
 }
 
-func GetAggOnChainProof(proof groth16.Proof, pubWitness witness.Witness) (string, error) {
-	a, b, c, _, _ := ExportProof(proof)
-	var A [2]string
-	for i := 0; i < 2; i++ {
-		A[i] = Encode(a[i].Bytes())
+func (w *WitnessInput) validateCommitments() error {
+	if _, ok := new(big.Int).SetString(w.VkeyHash, 0); w.VkeyHash != "" && !ok {
+		return fmt.Errorf("invalid vkey hash: %q", w.VkeyHash)
 	}
+	if _, ok := new(big.Int).SetString(w.CommittedValuesDigest, 0); w.CommittedValuesDigest != "" && !ok {
+		return fmt.Errorf("invalid committed values digest: %q", w.CommittedValuesDigest)
+	}
+	return nil
+}
 
-	var B [2][2]string
-	for i := 0; i < 2; i++ {
-		for j := 0; j < 2; j++ {
-			B[i][j] = Encode(b[i][j].Bytes())
+func missingCommitments(single *WitnessInput, multi *MultiWitnessInput) bool {
+	inputs := []WitnessInput{}
+	if single != nil {
+		inputs = append(inputs, *single)
+	} else {
+		inputs = multi.Chunks
+	}
+	for _, w := range inputs {
+		if w.VkeyHash == "" || w.CommittedValuesDigest == "" {
+			return true
 		}
 	}
+	return false
+}
 
-	var C [2]string
-	for i := 0; i < 2; i++ {
-		C[i] = Encode(c[i].Bytes())
-	}
-
-	/*var Commitment [2]string
-	for i := 0; i < 2; i++ {
-		Commitment[i] = Encode(commitment[i].Bytes())
-	}
-
-	var CommitmentPok [2]string
-	for i := 0; i < 2; i++ {
-		CommitmentPok[i] = Encode(commitmentPok[i].Bytes())
-	}*/
-
-	proofData := Groth16Proof{
-		A: A,
-		B: B,
-		C: C,
-		//Commitment:    Commitment,
-		//CommitmentPok: CommitmentPok,
-	}
-
-	var result = ""
-	result += proofData.A[0] + ","
-	result += proofData.A[1] + ","
-	result += proofData.B[0][0] + ","
-	result += proofData.B[0][1] + ","
-	result += proofData.B[1][0] + ","
-	result += proofData.B[1][1] + ","
-	result += proofData.C[0] + ","
-	result += proofData.C[1] + ","
-	//result += proofData.Commitment[0] + ","
-	//result += proofData.Commitment[1] + ","
-	//result += proofData.CommitmentPok[0] + ","
-	//result += proofData.CommitmentPok[1] + ","
-
-	fmt.Printf("proofData.A[0]: %s \n", proofData.A[0])
-	fmt.Printf("proofData.A[1]: %s \n", proofData.A[1])
-
-	fmt.Printf("proofData.B[0][0]: %s \n", proofData.B[0][0])
-	fmt.Printf("proofData.B[0][1]: %s \n", proofData.B[0][1])
-	fmt.Printf("proofData.B[1][0]: %s \n", proofData.B[1][0])
-	fmt.Printf("proofData.B[1][1]: %s \n", proofData.B[1][1])
-
-	fmt.Printf("proofData.C[0]: %s \n", proofData.C[0])
-	fmt.Printf("proofData.C[1]: %s \n", proofData.C[1])
-
-	/*fmt.Printf("proofData.Commitment[0]: %s \n", proofData.Commitment[0])
-	fmt.Printf("proofData.Commitment[1]: %s \n", proofData.Commitment[1])
-	fmt.Printf("proofData.CommitmentPok[0]: %s \n", proofData.CommitmentPok[0])
-	fmt.Printf("proofData.CommitmentPok[1]: %s \n", proofData.CommitmentPok[1])*/
-
-	// decode witness
-
-	swVector := pubWitness.Vector().(bn254_fr.Vector)
-
-	// 7 public input
-	for i := 0; i < len(swVector); i++ {
-		var data [32]byte
-		swVector[i].BigInt(new(big.Int)).FillBytes(data[:])
-		fmt.Printf("witness_%d: %s \n", i, Encode(data[:]))
-		if i == len(swVector)-1 {
-			result += Encode(data[:])
-		} else {
-			result += Encode(data[:]) + ","
+// parseWitness decodes the witness with decodeWitness, which validates its arrays.
+func parseWitness(data []byte) (single *WitnessInput, multi *MultiWitnessInput, err error) {
+	file, err := decodeWitness(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(file.Chunks) > 0 {
+		for i := range file.Chunks {
+			err = file.Chunks[i].validateCommitments()
+			if err != nil {
+				return nil, nil, fmt.Errorf("chunk %d: %v", i, err)
+			}
 		}
+		return nil, &MultiWitnessInput{Chunks: file.Chunks, AllowedVkeys: file.AllowedVkeys}, nil
+	}
+	err = file.WitnessInput.validateCommitments()
+	if err != nil {
+		return nil, nil, err
 	}
-	return result, nil
+	return &file.WitnessInput, nil, nil
 }
 
-// only for bn254
-func ExportProof(proof groth16.Proof) (a [2]*big.Int, b [2][2]*big.Int, c [2]*big.Int, commitment [2]*big.Int, commitmentPok [2]*big.Int) {
-	bn254Proof := proof.(*groth16_bn254.Proof)
-	// proof.Ar, proof.Bs, proof.Krs
-	a[0] = bn254Proof.Ar.X.BigInt(new(big.Int))
-	a[1] = bn254Proof.Ar.Y.BigInt(new(big.Int))
+type Groth16Proof struct {
+	A             [2]string    `json:"a"`
+	B             [2][2]string `json:"b"`
+	C             [2]string    `json:"c"`
+	Commitment    [2]string    `json:"commitment"`
+	CommitmentPok [2]string    `json:"commitment_pok"`
+}
+
+// ReadProvingKey reads a pk written by WriteProvingKey, or a legacy pk without header.
+func ReadProvingKey(filename string, pk groth16.ProvingKey) error {
+	return readKeyFile(filename, NewKeyHeader("pk", pk.CurveID()), func(r io.Reader) error {
+		return readKey(r, pk)
+	})
+}
+
+// ReadVerifyingKey reads a vk written by WriteVerifyingKey, or a legacy vk without header.
+func ReadVerifyingKey(filename string, vk groth16.VerifyingKey) error {
+	return readKeyFile(filename, NewKeyHeader("vk", vk.CurveID()), func(r io.Reader) error {
+		return readKey(r, vk)
+	})
+}
+
+// FastKeys reports whether FAST_KEYS=1: keys are written uncompressed and read without
+// the subgroup checks of their points, several times faster, for keys from a trusted
+// setup.
+func FastKeys() bool {
+	return os.Getenv("FAST_KEYS") == "1"
+}
+
+// readKey reads a compressed or raw key, checking its points unless FastKeys.
+func readKey(r io.Reader, key interface {
+	io.ReaderFrom
+	gnarkio.UnsafeReaderFrom
+}) error {
+	var err error
+	if FastKeys() {
+		_, err = key.UnsafeReadFrom(r)
+	} else {
+		_, err = key.ReadFrom(r)
+	}
+	return err
+}
+
+// writeKey writes a key compressed, or raw if FastKeys.
+func writeKey(w io.Writer, key interface {
+	io.WriterTo
+	gnarkio.WriterRawTo
+}) error {
+	var err error
+	if FastKeys() {
+		_, err = key.WriteRawTo(w)
+	} else {
+		_, err = key.WriteTo(w)
+	}
+	return err
+}
+
+func WriteProvingKey(filename string, pk groth16.ProvingKey) error {
+	return writeProvingKey(WriteArtifact, filename, pk, "")
+}
 
-	b[0][0] = bn254Proof.Bs.X.A1.BigInt(new(big.Int))
-	b[0][1] = bn254Proof.Bs.X.A0.BigInt(new(big.Int))
-	b[1][0] = bn254Proof.Bs.Y.A1.BigInt(new(big.Int))
-	b[1][1] = bn254Proof.Bs.Y.A0.BigInt(new(big.Int))
+func WriteVerifyingKey(filename string, vk groth16.VerifyingKey) error {
+	return writeVerifyingKey(WriteArtifact, filename, vk)
+}
+
+// WriteKeyPair writes vk, then pk with the fingerprint of vk in its header, through
+// store. Setup stages both so they only appear together.
+func WriteKeyPair(store ArtifactWriter, pkPath, vkPath string, pk groth16.ProvingKey, vk groth16.VerifyingKey) error {
+	fingerprint, err := VerifyingKeyFingerprint(vk)
+	if err != nil {
+		return err
+	}
+	err = writeVerifyingKey(store, vkPath, vk)
+	if err != nil {
+		return fmt.Errorf("fail to write vk: %v", err)
+	}
+	err = writeProvingKey(store, pkPath, pk, fingerprint)
+	if err != nil {
+		return fmt.Errorf("fail to write pk: %v", err)
+	}
+	return nil
+}
 
-	c[0] = bn254Proof.Krs.X.BigInt(new(big.Int))
-	c[1] = bn254Proof.Krs.Y.BigInt(new(big.Int))
+func writeProvingKey(store ArtifactWriter, filename string, pk groth16.ProvingKey, vkFingerprint string) error {
+	header := NewKeyHeader("pk", pk.CurveID())
+	header.VkFingerprint = vkFingerprint
+	header.Raw = FastKeys()
+	return writeKeyFile(store, filename, header, func(w io.Writer) error {
+		return writeKey(w, pk)
+	})
+}
 
-	//commitment[0] = bn254Proof.Commitments[0].X.BigInt(new(big.Int))
-	//commitment[1] = bn254Proof.Commitments[0].Y.BigInt(new(big.Int))
+func writeVerifyingKey(store ArtifactWriter, filename string, vk groth16.VerifyingKey) error {
+	header := NewKeyHeader("vk", vk.CurveID())
+	header.Raw = FastKeys()
+	return writeKeyFile(store, filename, header, func(w io.Writer) error {
+		return writeKey(w, vk)
+	})
+}
 
-	//commitmentPok[0] = bn254Proof.CommitmentPok.X.BigInt(new(big.Int))
-	//commitmentPok[1] = bn254Proof.CommitmentPok.Y.BigInt(new(big.Int))
+// coordinateSize is the byte length of a BN254 base field element.
+const coordinateSize = 32
+
+// ExportProof returns the affine coordinates of the proof points, with the G2 point's
+// imaginary parts first as expected by the EVM pairing precompiles. commitment and
+// commitmentPok are nil when the circuit has no commitment. It fails for proofs of
+// curves other than BN254, which have no EVM encoding.
+func ExportProof(proof groth16.Proof) (a [2]*big.Int, b [2][2]*big.Int, c [2]*big.Int, commitment [2]*big.Int, commitmentPok [2]*big.Int, err error) {
+	p, ok := proof.(*groth16_bn254.Proof)
+	if !ok {
+		err = fmt.Errorf("unsupported proof type %T, expected a bn254 proof", proof)
+		return
+	}
+	// proof.Ar, proof.Bs, proof.Krs
+	a[0] = p.Ar.X.BigInt(new(big.Int))
+	a[1] = p.Ar.Y.BigInt(new(big.Int))
+
+	b[0][0] = p.Bs.X.A1.BigInt(new(big.Int))
+	b[0][1] = p.Bs.X.A0.BigInt(new(big.Int))
+	b[1][0] = p.Bs.Y.A1.BigInt(new(big.Int))
+	b[1][1] = p.Bs.Y.A0.BigInt(new(big.Int))
+
+	c[0] = p.Krs.X.BigInt(new(big.Int))
+	c[1] = p.Krs.Y.BigInt(new(big.Int))
+
+	if len(p.Commitments) > 0 {
+		commitment[0] = p.Commitments[0].X.BigInt(new(big.Int))
+		commitment[1] = p.Commitments[0].Y.BigInt(new(big.Int))
+		commitmentPok[0] = p.CommitmentPok.X.BigInt(new(big.Int))
+		commitmentPok[1] = p.CommitmentPok.Y.BigInt(new(big.Int))
+	}
 	return
 }
 
@@ -191,25 +357,15 @@ func Encode(b []byte) string {
 	return string(enc)
 }
 
+// ReadCcs reads a ccs file with or without header, see ReadCcsArtifact.
 func ReadCcs(filename string, ccs constraint.ConstraintSystem) error {
-	f, err := os.Open(filename)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	_, err = ccs.ReadFrom(f)
+	_, err := ReadCcsArtifact(filename, ccs)
 	return err
 }
 
 func WriteCcs(filename string, css constraint.ConstraintSystem) error {
-	f, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	_, err = css.WriteTo(f)
-	if err != nil {
+	return WriteArtifact(filename, func(w io.Writer) error {
+		_, err := css.WriteTo(w)
 		return err
-	}
-	return nil
+	})
 }
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/consensys/gnark-crypto/ecc"
+	bls12381_fr "github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+	bn254_fr "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	bw6761_fr "github.com/consensys/gnark-crypto/ecc/bw6-761/fr"
+	"github.com/consensys/gnark/backend/witness"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// WrapperCurves are the curves the wrapper can target, the first one by default.
+//
+// BN254 is the only one for now: the constraints hash with Poseidon2 over the BN254
+// scalar field and compute on vars modulo it, as the Rust embed configuration does.
+// Wrapping on another curve needs an embed configuration for that curve on the Rust
+// side first, then only this list: setup and proving take the curve as an ecc.ID and
+// the helpers of this package handle every curve of SupportedCurves. The EVM outputs,
+// on-chain proofs and Solidity verifiers, stay BN254 only.
+var WrapperCurves = []ecc.ID{ecc.BN254}
+
+// SupportedCurves are the curves the helpers dispatching on the curve of a key, proof
+// or witness handle.
+var SupportedCurves = []ecc.ID{ecc.BN254, ecc.BLS12_381, ecc.BW6_761}
+
+// ParseCurve maps a curve name, as ecc.ID prints it, to one of WrapperCurves. An empty
+// name selects the first one.
+func ParseCurve(name string) (ecc.ID, error) {
+	if name == "" {
+		return WrapperCurves[0], nil
+	}
+	names := make([]string, len(WrapperCurves))
+	for i, curve := range WrapperCurves {
+		if curve.String() == name {
+			return curve, nil
+		}
+		names[i] = curve.String()
+	}
+	return ecc.UNKNOWN, fmt.Errorf("unsupported curve: %s, support %s", name, strings.Join(names, "/"))
+}
+
+// CurveFromEnv reads the curve selected through the CURVE environment variable.
+func CurveFromEnv() (ecc.ID, error) {
+	return ParseCurve(os.Getenv("CURVE"))
+}
+
+// PublicInputs returns the public witness values as integers.
+func PublicInputs(pubWitness witness.Witness) ([]*big.Int, error) {
+	switch vector := pubWitness.Vector().(type) {
+	case bn254_fr.Vector:
+		return bigInts(vector), nil
+	case bls12381_fr.Vector:
+		return bigInts(vector), nil
+	case bw6761_fr.Vector:
+		return bigInts(vector), nil
+	default:
+		return nil, fmt.Errorf("unsupported public witness type %T", pubWitness.Vector())
+	}
+}
+
+// bigInts converts the field elements of a witness vector of any curve.
+func bigInts[E any, PE interface {
+	*E
+	BigInt(*big.Int) *big.Int
+}](vector []E) []*big.Int {
+	res := make([]*big.Int, len(vector))
+	for i := range vector {
+		res[i] = PE(&vector[i]).BigInt(new(big.Int))
+	}
+	return res
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"strings"
+	"testing"
+)
+
+func TestParseCurve(t *testing.T) {
+	for _, name := range []string{"", "bn254"} {
+		curve, err := ParseCurve(name)
+		if err != nil || curve != ecc.BN254 {
+			t.Fatalf("ParseCurve(%q) = %v, %v, want bn254", name, curve, err)
+		}
+	}
+	for _, name := range []string{"bls12_381", "bw6_761"} {
+		_, err := ParseCurve(name)
+		if err == nil || !strings.Contains(err.Error(), "unsupported curve") {
+			t.Fatalf("ParseCurve(%q) error = %v, want unsupported", name, err)
+		}
+	}
+}
+
+func TestPublicInputs(t *testing.T) {
+	for _, curve := range SupportedCurves {
+		assignment := &squareCircuit{X: 3, Y: 9}
+		w, err := frontend.NewWitness(assignment, curve.ScalarField(), frontend.PublicOnly())
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs, err := PublicInputs(w)
+		if err != nil || len(inputs) != 1 || inputs[0].Int64() != 9 {
+			t.Fatalf("PublicInputs on %s = %v, %v, want [9]", curve, inputs, err)
+		}
+	}
+}
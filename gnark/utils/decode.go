@@ -0,0 +1,247 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// witnessFile is a witness json, a single witness or its chunks.
+type witnessFile struct {
+	WitnessInput
+	Chunks       []WitnessInput
+	AllowedVkeys []string
+}
+
+// witnessDecoder decodes the arrays of a witness json concurrently: the tokenizer
+// only finds where each array and each chunk ends, and hands them to their own
+// goroutine, so the megabytes of FRI openings of a large proof are not decoded one
+// after the other.
+type witnessDecoder struct {
+	data []byte
+	dec  *json.Decoder
+	// wg is shared by the decoders of the chunks.
+	wg *sync.WaitGroup
+}
+
+func newWitnessDecoder(data []byte, wg *sync.WaitGroup) *witnessDecoder {
+	return &witnessDecoder{data: data, dec: json.NewDecoder(bytes.NewReader(data)), wg: wg}
+}
+
+// inputErrors are the errors of a witness decoded in the background: of its object
+// for a chunk, and of its arrays.
+type inputErrors struct {
+	input, vars, felts, exts error
+}
+
+func (e *inputErrors) first() error {
+	for _, err := range []error{e.input, e.vars, e.felts, e.exts} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeWitness decodes and validates the arrays of a witness json, of every chunk
+// for a multi-chunk witness. The values besides the arrays are left to the caller to
+// validate.
+func decodeWitness(data []byte) (*witnessFile, error) {
+	var wg sync.WaitGroup
+	d := newWitnessDecoder(data, &wg)
+	file := new(witnessFile)
+	var errs inputErrors
+	var chunks []*WitnessInput
+	var chunkErrs []*inputErrors
+
+	err := d.decodeInput(&file.WitnessInput, &errs, &file.AllowedVkeys, func() error {
+		return d.decodeList(func(raw []byte) {
+			chunk, errs := new(WitnessInput), new(inputErrors)
+			chunks = append(chunks, chunk)
+			chunkErrs = append(chunkErrs, errs)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs.input = newWitnessDecoder(raw, &wg).decodeInput(chunk, errs, nil, nil)
+			}()
+		})
+	})
+	if err == nil {
+		_, err = d.dec.Token()
+		if err == io.EOF {
+			err = nil
+		} else if err == nil {
+			err = fmt.Errorf("unexpected data after the witness")
+		}
+	}
+	// the goroutines write into file until they are done, even when it is not returned
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	err = errs.first()
+	if err != nil {
+		return nil, err
+	}
+	for i, chunk := range chunks {
+		err = chunkErrs[i].first()
+		if err != nil {
+			return nil, fmt.Errorf("chunk %d: %v", i, err)
+		}
+		file.Chunks = append(file.Chunks, *chunk)
+	}
+	return file, nil
+}
+
+// decodeInput reads the witness object into w, the arrays in the background with
+// their errors in errs. The value of a "chunks" key is read by chunks and that of an
+// "allowed_vkeys" key into allowedVkeys, and skipped if they are nil.
+func (d *witnessDecoder) decodeInput(w *WitnessInput, errs *inputErrors, allowedVkeys *[]string, chunks func() error) error {
+	dec := d.dec
+	err := d.expectDelim('{')
+	if err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key := token.(string)
+		// the arrays would be decoded twice at once, ParseWitness leaves a witness
+		// with duplicate keys to UpgradeWitness
+		if seen[key] {
+			return fmt.Errorf("duplicate key %q", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "vars":
+			err = d.decodeArray(&errs.vars, func(raw []byte) error {
+				err := w.Vars.UnmarshalJSON(raw)
+				if err != nil {
+					return err
+				}
+				return validateWitnessVars(w.Vars)
+			})
+		case "felts":
+			err = d.decodeArray(&errs.felts, func(raw []byte) error {
+				err := w.Felts.UnmarshalJSON(raw)
+				if err != nil {
+					return err
+				}
+				return validateWitnessFelts(w.Felts)
+			})
+		case "exts":
+			err = d.decodeArray(&errs.exts, func(raw []byte) error {
+				err := w.Exts.UnmarshalJSON(raw)
+				if err != nil {
+					return err
+				}
+				return validateWitnessExts(w.Exts)
+			})
+		case "vkey_hash":
+			err = dec.Decode(&w.VkeyHash)
+		case "committed_values_digest":
+			err = dec.Decode(&w.CommittedValuesDigest)
+		case "fri_config":
+			err = dec.Decode(&w.FriConfig)
+		case "public_values":
+			err = dec.Decode(&w.PublicValues)
+		case "version":
+			err = dec.Decode(&w.Version)
+		case "chunks":
+			if chunks != nil {
+				err = chunks()
+			} else {
+				err = dec.Decode(&skipValue{})
+			}
+		case "allowed_vkeys":
+			if allowedVkeys != nil {
+				err = dec.Decode(allowedVkeys)
+			} else {
+				err = dec.Decode(&skipValue{})
+			}
+		default:
+			err = dec.Decode(&skipValue{})
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+	}
+	return d.expectDelim('}')
+}
+
+// decodeArray finds the end of the next value and decodes it with decode in the
+// background, into err. The value is checked to be valid json, decode does not need
+// to scan it again.
+func (d *witnessDecoder) decodeArray(err *error, decode func(raw []byte) error) error {
+	raw, scanErr := d.rawValue()
+	if scanErr != nil {
+		return scanErr
+	}
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		*err = decode(raw)
+	}()
+	return nil
+}
+
+// decodeList passes every element of the next json list to element, a null list has
+// none.
+func (d *witnessDecoder) decodeList(element func(raw []byte)) error {
+	token, err := d.dec.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil
+	}
+	if token != json.Delim('[') {
+		return fmt.Errorf("expected a list, found %v", token)
+	}
+	for d.dec.More() {
+		raw, err := d.rawValue()
+		if err != nil {
+			return err
+		}
+		element(raw)
+	}
+	return d.expectDelim(']')
+}
+
+// rawValue scans the next value and returns it as a slice of the decoded data, so
+// the arrays are not copied.
+func (d *witnessDecoder) rawValue() ([]byte, error) {
+	start := d.dec.InputOffset()
+	err := d.dec.Decode(&skipValue{})
+	if err != nil {
+		return nil, err
+	}
+	// the offset before the value is that of the previous token, followed by the
+	// separator
+	return bytes.TrimLeft(d.data[start:d.dec.InputOffset()], ":, \t\r\n"), nil
+}
+
+// skipValue is decoded from any json value without keeping it.
+type skipValue struct{}
+
+func (skipValue) UnmarshalJSON([]byte) error {
+	return nil
+}
+
+func (d *witnessDecoder) expectDelim(delim json.Delim) error {
+	token, err := d.dec.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %v, found %v", delim, token)
+	}
+	return nil
+}
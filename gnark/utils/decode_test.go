@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"encoding/json"
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecodeWitness(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	chunk := WitnessInput{
+		Vars:                  VarArray{"7"},
+		Felts:                 FeltArray{"1", "2"},
+		Exts:                  ExtArray{{"1", "2", "3", "4"}},
+		VkeyHash:              "0x01",
+		CommittedValuesDigest: "0x02",
+		FriConfig:             &FriConfig{},
+		PublicValues:          "0x03",
+		Version:               2,
+	}
+	data, err := json.MarshalIndent(map[string]any{"chunks": []WitnessInput{chunk, chunk}, "_config": "skipped", "allowed_vkeys": []string{"0x01", "0x03"}}, "", "  ")
+	assert.NoError(err)
+	_, multi, err := ParseWitness(data)
+	assert.NoError(err)
+	assert.Equal([]WitnessInput{chunk, chunk}, multi.Chunks)
+	assert.Equal([]string{"0x01", "0x03"}, multi.AllowedVkeys)
+
+	single, multi, err := ParseWitness([]byte(` {"vkey_hash": "1", "felts" : ["3"], "committed_values_digest": "2", "chunks": null} `))
+	assert.NoError(err)
+	assert.Nil(multi)
+	assert.Equal(FeltArray{"3"}, single.Felts)
+
+	// the errors of the arrays decoded in the background name their chunk
+	_, _, err = ParseWitness([]byte(`{"chunks": [{"felts": ["1"]}, {"felts": ["x"]}]}`))
+	assert.ErrorContains(err, `chunk 1: invalid felt 0: "x"`)
+	_, _, err = ParseWitness([]byte(`{"chunks": [{"felts": ["1"]}, {"felts": 1}]}`))
+	assert.ErrorContains(err, "chunk 1")
+
+	// a duplicate key is decoded by the upgrade, keeping its last value
+	single, _, err = ParseWitness([]byte(`{"felts": ["1"], "felts": ["2"], "vkey_hash": "1", "committed_values_digest": "2"}`))
+	assert.NoError(err)
+	assert.Equal(FeltArray{"2"}, single.Felts)
+
+	for _, invalid := range []string{
+		`{"vkey_hash": "1", "committed_values_digest": "2"} {}`,
+		`{"felts": ["1"]`,
+		`["1"]`,
+	} {
+		_, _, err = ParseWitness([]byte(invalid))
+		assert.Error(err, invalid)
+	}
+}
+
+func TestReadWitnessJson(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// the witnesses of testdata are those the Rust prover writes, see the tests of
+	// GnarkWitness: with public values, and without them next to a pv_file
+	data, err := ReadWitnessJson("testdata/rust_witness/groth16_witness.json")
+	assert.NoError(err)
+	withValues, _, err := ParseWitness(data)
+	assert.NoError(err)
+	values, err := DecodePublicValues(withValues.PublicValues)
+	assert.NoError(err)
+	assert.Len(values, 64)
+	assert.Equal(withValues.CommittedValuesDigest, CommittedValuesDigest(values).String())
+	assert.Equal(WitnessVersion, withValues.FormatVersion())
+
+	data, err = ReadWitnessJson("testdata/rust_witness_pv_file/groth16_witness.json")
+	assert.NoError(err)
+	fromFile, _, err := ParseWitness(data)
+	assert.NoError(err)
+	assert.Equal(withValues, fromFile)
+
+	// without a pv_file the witness has no public values
+	dir := t.TempDir()
+	witnessPath := filepath.Join(dir, "groth16_witness.json")
+	raw, err := os.ReadFile("testdata/rust_witness_pv_file/groth16_witness.json")
+	assert.NoError(err)
+	assert.NoError(os.WriteFile(witnessPath, raw, 0644))
+	data, err = ReadWitnessJson(witnessPath)
+	assert.NoError(err)
+	assert.Equal(raw, data)
+
+	// the public values of the witness win over a pv_file, which is not added to chunks
+	// or an empty object
+	assert.NoError(os.WriteFile(filepath.Join(dir, PublicValuesFile), []byte("0102\n"), 0644))
+	data, err = ReadWitnessJson(witnessPath)
+	assert.NoError(err)
+	single, _, err := ParseWitness(data)
+	assert.NoError(err)
+	assert.Equal("0x0102", single.PublicValues)
+	for _, witness := range []string{`{"public_values": "0x03"}`, `{"chunks": []}`} {
+		data, err = withPublicValues([]byte(witness), "0x0102")
+		assert.NoError(err)
+		assert.Equal(witness, string(data))
+	}
+	data, err = withPublicValues([]byte(` { } `), "0x0102")
+	assert.NoError(err)
+	assert.Equal(` { "public_values":"0x0102"} `, string(data))
+	assert.NoError(os.WriteFile(filepath.Join(dir, PublicValuesFile), []byte("0x0g"), 0644))
+	_, err = ReadWitnessJson(witnessPath)
+	assert.ErrorContains(err, "invalid pv_file")
+}
@@ -0,0 +1,259 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// partialFiles tracks the temporary files of writes in progress, so they can be
+// removed if the process is interrupted.
+var partialFiles = struct {
+	sync.Mutex
+	names   map[string]struct{}
+	aborted bool
+}{names: make(map[string]struct{})}
+
+// RemovePartialFiles removes the temporary files of all writes in progress and makes
+// later writes fail. It is meant to run right before the process exits on cancellation.
+func RemovePartialFiles() {
+	partialFiles.Lock()
+	defer partialFiles.Unlock()
+	partialFiles.aborted = true
+	for name := range partialFiles.names {
+		os.Remove(name)
+	}
+	partialFiles.names = make(map[string]struct{})
+}
+
+// writtenFiles records the files written once RecordWrittenFiles is called.
+var writtenFiles = struct {
+	sync.Mutex
+	recording bool
+	names     []string
+}{}
+
+// RecordWrittenFiles starts recording the artifacts written by WriteArtifact,
+// WriteFileAtomic and StagedWrites, for the cli to report them. The record is never
+// trimmed, so long running processes do not call it.
+func RecordWrittenFiles() {
+	writtenFiles.Lock()
+	defer writtenFiles.Unlock()
+	writtenFiles.recording = true
+}
+
+// WrittenFiles returns the artifacts written since RecordWrittenFiles, in the order
+// they were first written.
+func WrittenFiles() []string {
+	writtenFiles.Lock()
+	defer writtenFiles.Unlock()
+	return append([]string(nil), writtenFiles.names...)
+}
+
+func recordWrittenFile(name string) {
+	writtenFiles.Lock()
+	defer writtenFiles.Unlock()
+	if !writtenFiles.recording {
+		return
+	}
+	for _, n := range writtenFiles.names {
+		if n == name {
+			return
+		}
+	}
+	writtenFiles.names = append(writtenFiles.names, name)
+}
+
+func trackPartialFile(name string) error {
+	partialFiles.Lock()
+	defer partialFiles.Unlock()
+	if partialFiles.aborted {
+		return errors.New("writes aborted")
+	}
+	partialFiles.names[name] = struct{}{}
+	return nil
+}
+
+func untrackPartialFile(name string) {
+	partialFiles.Lock()
+	defer partialFiles.Unlock()
+	delete(partialFiles.names, name)
+}
+
+// WriteFileAtomic streams write's output into a temporary file next to filename and
+// renames it into place only once everything has been flushed to disk. An interrupted
+// or failed write leaves any previous file untouched and removes the partial one, so
+// readers never see a truncated artifact.
+func WriteFileAtomic(filename string, write func(w io.Writer) error) (err error) {
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := os.CreateTemp(dir, "."+base+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := f.Name()
+	err = trackPartialFile(tmpName)
+	if err != nil {
+		f.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(tmpName)
+		}
+		untrackPartialFile(tmpName)
+	}()
+
+	err = write(f)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if err != nil {
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tmpName, 0644)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmpName, filename)
+	if err != nil {
+		return err
+	}
+	recordWrittenFile(filename)
+	return nil
+}
+
+// WriteBytesAtomic is WriteFileAtomic for data already held in memory.
+func WriteBytesAtomic(filename string, data []byte) error {
+	return WriteFileAtomic(filename, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// ArtifactWriter stores what write produces at path, like WriteArtifact or
+// (*StagedWrites).Write.
+type ArtifactWriter func(path string, write func(w io.Writer) error) error
+
+// StagedWrites groups writes that must appear together, such as the outputs of a
+// setup. Write keeps each output under a temporary name, Commit moves them into place
+// in the order they were written and Abort discards whatever was not committed.
+type StagedWrites struct {
+	files []stagedFile
+}
+
+type stagedFile struct {
+	path string
+	tmp  string
+}
+
+// Write stages write's output for path. Local files are staged next to path so Commit
+// only renames them; other storages are spooled to a local temporary file and
+// uploaded by Commit.
+func (s *StagedWrites) Write(path string, write func(w io.Writer) error) (err error) {
+	storage, name, err := StorageFor(path)
+	if err != nil {
+		return err
+	}
+	dir, base := "", "pico-upload"
+	if _, ok := storage.(LocalStorage); ok {
+		dir, base = filepath.Split(name)
+		if dir == "" {
+			dir = "."
+		}
+	}
+	f, err := os.CreateTemp(dir, "."+base+".tmp-*")
+	if err != nil {
+		return err
+	}
+	err = trackPartialFile(f.Name())
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			untrackPartialFile(f.Name())
+		}
+	}()
+
+	err = write(f)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if err != nil {
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	s.files = append(s.files, stagedFile{path: path, tmp: f.Name()})
+	return nil
+}
+
+// Commit moves the staged files into place, in the order they were written. It stops
+// at the first failure, leaving the remaining files staged for Abort.
+func (s *StagedWrites) Commit() error {
+	for len(s.files) > 0 {
+		file := s.files[0]
+		err := commitStaged(file)
+		if err != nil {
+			return fmt.Errorf("fail to write %s: %v", file.path, err)
+		}
+		os.Remove(file.tmp)
+		untrackPartialFile(file.tmp)
+		recordWrittenFile(file.path)
+		s.files = s.files[1:]
+	}
+	return nil
+}
+
+func commitStaged(file stagedFile) error {
+	storage, name, err := StorageFor(file.path)
+	if err != nil {
+		return err
+	}
+	if _, ok := storage.(LocalStorage); ok {
+		err = os.Chmod(file.tmp, 0644)
+		if err != nil {
+			return err
+		}
+		return os.Rename(file.tmp, name)
+	}
+	return storage.Write(context.Background(), name, func(w io.Writer) error {
+		f, err := os.Open(file.tmp)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	})
+}
+
+// Abort removes the files staged and not committed. It is a no-op after Commit.
+func (s *StagedWrites) Abort() {
+	for _, file := range s.files {
+		os.Remove(file.tmp)
+		untrackPartialFile(file.tmp)
+	}
+	s.files = nil
+}
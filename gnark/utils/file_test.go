@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"github.com/consensys/gnark/test"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemovePartialFiles(t *testing.T) {
+	assert := test.NewAssert(t)
+	defer func() {
+		partialFiles.Lock()
+		partialFiles.aborted = false
+		partialFiles.Unlock()
+	}()
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "vm_pk")
+	err := WriteFileAtomic(filename, func(w io.Writer) error {
+		_, err := w.Write([]byte("partial"))
+		assert.NoError(err)
+		RemovePartialFiles()
+		entries, err := os.ReadDir(dir)
+		assert.NoError(err)
+		assert.Empty(entries)
+		return nil
+	})
+	assert.Error(err)
+	_, err = os.Stat(filename)
+	assert.True(os.IsNotExist(err))
+
+	assert.Error(WriteBytesAtomic(filename, []byte("late")))
+}
+
+func TestStagedWrites(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	dir := t.TempDir()
+	pkPath := filepath.Join(dir, "vm_pk")
+	vkPath := filepath.Join(dir, "vm_vk")
+	assert.NoError(WriteBytesAtomic(pkPath, []byte("old pk")))
+	assert.NoError(WriteBytesAtomic(vkPath, []byte("old vk")))
+	write := func(data string) func(w io.Writer) error {
+		return func(w io.Writer) error {
+			_, err := w.Write([]byte(data))
+			return err
+		}
+	}
+	read := func(path string) string {
+		data, err := os.ReadFile(path)
+		assert.NoError(err)
+		return string(data)
+	}
+
+	// an interrupted setup leaves the previous outputs and no temporary file
+	staged := &StagedWrites{}
+	assert.NoError(staged.Write(vkPath, write("new vk")))
+	assert.NoError(staged.Write(pkPath, write("new pk")))
+	staged.Abort()
+	assert.Equal("old vk", read(vkPath))
+	assert.Equal("old pk", read(pkPath))
+	entries, err := os.ReadDir(dir)
+	assert.NoError(err)
+	assert.Len(entries, 2)
+
+	staged = &StagedWrites{}
+	assert.NoError(staged.Write(vkPath, write("new vk")))
+	assert.NoError(staged.Write(pkPath, write("new pk")))
+	assert.Equal("old pk", read(pkPath))
+	assert.NoError(staged.Commit())
+	staged.Abort()
+	assert.Equal("new vk", read(vkPath))
+	assert.Equal("new pk", read(pkPath))
+	entries, err = os.ReadDir(dir)
+	assert.NoError(err)
+	assert.Len(entries, 2)
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/consensys/gnark/backend/groth16"
+	groth16_bls12381 "github.com/consensys/gnark/backend/groth16/bls12-381"
+	groth16_bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	groth16_bw6761 "github.com/consensys/gnark/backend/groth16/bw6-761"
+	"os"
+	"strings"
+)
+
+// FingerprintPath is where setups predating the pk header fingerprint stored the
+// fingerprint of the vk generated together with the pk at pkPath.
+func FingerprintPath(pkPath string) string {
+	return pkPath + ".vk_fingerprint"
+}
+
+// VerifyingKeyFingerprint returns the hex encoded sha256 of vk's serialization.
+func VerifyingKeyFingerprint(vk groth16.VerifyingKey) (string, error) {
+	h := sha256.New()
+	_, err := vk.WriteTo(h)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CheckKeyFingerprint compares vk against the fingerprint in the header of the pk at
+// pkPath, or in the file next to it for older setups. It only reads the header and
+// can run before the (large) pk has been read. Keys written before fingerprints
+// existed are accepted, reported by checked=false.
+func CheckKeyFingerprint(pkPath string, vk groth16.VerifyingKey) (checked bool, err error) {
+	header, err := ReadKeyHeader(pkPath)
+	if err != nil {
+		return false, err
+	}
+	expected := ""
+	if header != nil {
+		expected = header.VkFingerprint
+	}
+	if expected == "" {
+		data, err := ReadArtifact(FingerprintPath(pkPath))
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		expected = strings.TrimSpace(string(data))
+	}
+
+	actual, err := VerifyingKeyFingerprint(vk)
+	if err != nil {
+		return false, err
+	}
+	if expected != actual {
+		return true, fmt.Errorf("verifying key does not match proving key %s: vk fingerprint %s, expected %s (keys from different setups?)", pkPath, actual, expected)
+	}
+	return true, nil
+}
+
+// CheckKeyPair checks that pk and vk come from the same setup by comparing the
+// toxic-waste commitments both keys carry.
+func CheckKeyPair(pk groth16.ProvingKey, vk groth16.VerifyingKey) error {
+	if pk.CurveID() != vk.CurveID() {
+		return fmt.Errorf("proving key and verifying key are for different curves")
+	}
+	pkPoints, pkCommitments, err := setupPoints(pk)
+	if err != nil {
+		return err
+	}
+	vkPoints, vkCommitments, err := setupPoints(vk)
+	if err != nil {
+		return err
+	}
+	for i := range pkPoints {
+		if !bytes.Equal(pkPoints[i], vkPoints[i]) {
+			return fmt.Errorf("proving key and verifying key come from different setups")
+		}
+	}
+	if pkCommitments != vkCommitments {
+		return fmt.Errorf("proving key has %d commitment keys, verifying key has %d", pkCommitments, vkCommitments)
+	}
+	return nil
+}
+
+// setupPoints returns the points of a pk or vk that its counterpart carries too, α, β
+// and δ in G1 then β and δ in G2, and its number of commitment keys.
+func setupPoints(key any) ([][]byte, int, error) {
+	switch key := key.(type) {
+	case *groth16_bn254.ProvingKey:
+		return [][]byte{key.G1.Alpha.Marshal(), key.G1.Beta.Marshal(), key.G1.Delta.Marshal(), key.G2.Beta.Marshal(), key.G2.Delta.Marshal()}, len(key.CommitmentKeys), nil
+	case *groth16_bn254.VerifyingKey:
+		return [][]byte{key.G1.Alpha.Marshal(), key.G1.Beta.Marshal(), key.G1.Delta.Marshal(), key.G2.Beta.Marshal(), key.G2.Delta.Marshal()}, len(key.CommitmentKeys), nil
+	case *groth16_bls12381.ProvingKey:
+		return [][]byte{key.G1.Alpha.Marshal(), key.G1.Beta.Marshal(), key.G1.Delta.Marshal(), key.G2.Beta.Marshal(), key.G2.Delta.Marshal()}, len(key.CommitmentKeys), nil
+	case *groth16_bls12381.VerifyingKey:
+		return [][]byte{key.G1.Alpha.Marshal(), key.G1.Beta.Marshal(), key.G1.Delta.Marshal(), key.G2.Beta.Marshal(), key.G2.Delta.Marshal()}, len(key.CommitmentKeys), nil
+	case *groth16_bw6761.ProvingKey:
+		return [][]byte{key.G1.Alpha.Marshal(), key.G1.Beta.Marshal(), key.G1.Delta.Marshal(), key.G2.Beta.Marshal(), key.G2.Delta.Marshal()}, len(key.CommitmentKeys), nil
+	case *groth16_bw6761.VerifyingKey:
+		return [][]byte{key.G1.Alpha.Marshal(), key.G1.Beta.Marshal(), key.G1.Delta.Marshal(), key.G2.Beta.Marshal(), key.G2.Delta.Marshal()}, len(key.CommitmentKeys), nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported key type %T", key)
+	}
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type squareCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (c *squareCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(c.Y, api.Mul(c.X, c.X))
+	return nil
+}
+
+func TestKeyPairMismatch(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &squareCircuit{})
+	assert.NoError(err)
+	pk1, vk1, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	_, vk2, err := groth16.Setup(ccs)
+	assert.NoError(err)
+
+	assert.NoError(CheckKeyPair(pk1, vk1))
+	assert.Error(CheckKeyPair(pk1, vk2))
+	for _, curve := range SupportedCurves[1:] {
+		other, err := frontend.Compile(curve.ScalarField(), r1cs.NewBuilder, &squareCircuit{})
+		assert.NoError(err)
+		pk, vk, err := groth16.Setup(other)
+		assert.NoError(err)
+		assert.NoError(CheckKeyPair(pk, vk), curve)
+		assert.Error(CheckKeyPair(pk, vk1), curve)
+	}
+
+	dir := t.TempDir()
+	pkPath := filepath.Join(dir, "vm_pk")
+	vkPath := filepath.Join(dir, "vm_vk")
+	assert.NoError(WriteProvingKey(pkPath, pk1))
+	checked, err := CheckKeyFingerprint(pkPath, vk1)
+	assert.NoError(err)
+	assert.False(checked)
+
+	// older setups stored the fingerprint next to the pk
+	fingerprint, err := VerifyingKeyFingerprint(vk1)
+	assert.NoError(err)
+	assert.NoError(os.WriteFile(FingerprintPath(pkPath), []byte(fingerprint+"\n"), 0644))
+	checked, err = CheckKeyFingerprint(pkPath, vk1)
+	assert.NoError(err)
+	assert.True(checked)
+	_, err = CheckKeyFingerprint(pkPath, vk2)
+	assert.Error(err)
+
+	// the fingerprint in the pk header takes precedence over a stale file
+	assert.NoError(WriteKeyPair(WriteArtifact, pkPath, vkPath, pk1, vk2))
+	checked, err = CheckKeyFingerprint(pkPath, vk2)
+	assert.NoError(err)
+	assert.True(checked)
+	_, err = CheckKeyFingerprint(pkPath, vk1)
+	assert.Error(err)
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// FriConfig holds the FRI parameters the Rust side used to build the constraints and
+// the witness. They used to be implied by the shape of the constraints; carrying them
+// explicitly lets the prover check that both agree.
+type FriConfig struct {
+	LogBlowup       int `json:"log_blowup"`
+	NumQueries      int `json:"num_queries"`
+	ProofOfWorkBits int `json:"proof_of_work_bits"`
+}
+
+func (c *FriConfig) Validate() error {
+	if c.LogBlowup <= 0 {
+		return fmt.Errorf("invalid fri log_blowup: %d", c.LogBlowup)
+	}
+	if c.NumQueries <= 0 {
+		return fmt.Errorf("invalid fri num_queries: %d", c.NumQueries)
+	}
+	if c.ProofOfWorkBits < 0 {
+		return fmt.Errorf("invalid fri proof_of_work_bits: %d", c.ProofOfWorkBits)
+	}
+	return nil
+}
+
+type Constraint struct {
+	Opcode string     `json:"opcode"`
+	Args   [][]string `json:"args"`
+}
+
+// ConstraintsFile is the content of constraints.json. The file is either a bare array
+// of constraints (the original schema) or an object carrying the fri config next to
+// the constraints.
+type ConstraintsFile struct {
+	FriConfig   *FriConfig   `json:"fri_config,omitempty"`
+	Constraints []Constraint `json:"constraints"`
+}
+
+func ReadConstraints(fileName string) (*ConstraintsFile, error) {
+	data, err := ReadArtifact(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return ParseConstraints(data)
+}
+
+// ParseConstraints decodes and validates the content of constraints.json.
+func ParseConstraints(data []byte) (*ConstraintsFile, error) {
+	var file ConstraintsFile
+	var err error
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(data, &file.Constraints)
+	} else {
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error deserializing JSON: %v", err)
+	}
+
+	if file.FriConfig != nil {
+		err = file.FriConfig.Validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = file.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// ConstraintsPath is the constraints file the verifier circuits read, CONSTRAINTS_JSON
+// or constraints.json by default.
+func ConstraintsPath() string {
+	path := os.Getenv("CONSTRAINTS_JSON")
+	if path == "" {
+		path = "constraints.json"
+	}
+	return path
+}
+
+// LoadConstraints reads the constraints at ConstraintsPath and checks their fri config
+// against the witness's.
+func LoadConstraints(witnessConfig *FriConfig) (*ConstraintsFile, error) {
+	file, err := ReadConstraints(ConstraintsPath())
+	if err != nil {
+		return nil, err
+	}
+	err = file.CheckFriConfig(witnessConfig)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// CheckFriConfig fails if the witness was generated with other fri parameters than the
+// constraints. Files without a fri config are accepted.
+func (f *ConstraintsFile) CheckFriConfig(witnessConfig *FriConfig) error {
+	if f.FriConfig == nil || witnessConfig == nil {
+		return nil
+	}
+	if *f.FriConfig != *witnessConfig {
+		return fmt.Errorf("witness fri config %+v does not match constraints fri config %+v", *witnessConfig, *f.FriConfig)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"testing"
+)
+
+func FuzzParseWitness(f *testing.F) {
+	f.Add([]byte(`{"vars": ["1"], "felts": ["2"], "exts": [["1", "2", "3", "4"]], "vkey_hash": "0x7", "committed_values_digest": "9"}`))
+	f.Add([]byte(`{"vars": "0x01", "felts": "0x00000002", "exts": "0x00000001000000020000000300000004"}`))
+	f.Add([]byte(`{"chunks": [{"vars": ["1"], "vkey_hash": "7", "committed_values_digest": "9"}]}`))
+	f.Add([]byte(`{"exts": ["1", "2", "3", "4"], "vkeyHash": "7", "commited_values_digest": "9"}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		single, multi, err := ParseWitness(data)
+		if err != nil {
+			return
+		}
+		inputs := []WitnessInput{}
+		if single != nil {
+			inputs = append(inputs, *single)
+		} else {
+			inputs = multi.Chunks
+		}
+		for _, w := range inputs {
+			if err := w.Validate(); err != nil {
+				t.Fatalf("parsed witness does not validate: %v", err)
+			}
+		}
+	})
+}
+
+func FuzzParseConstraints(f *testing.F) {
+	f.Add([]byte(`[{"opcode": "ImmV", "args": [["q"], ["100"]]}, {"opcode": "AssertEqV", "args": [["q"], ["q"]]}]`))
+	f.Add([]byte(`{"fri_config": {"log_blowup": 1, "num_queries": 100, "proof_of_work_bits": 16}, "constraints": []}`))
+	f.Add([]byte(`[{"opcode": "Num2BitsV", "args": [["b0", "b1"], ["v"], ["2"]]}, {"opcode": "WitnessE", "args": [["e"], ["0"]]}]`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		file, err := ParseConstraints(data)
+		if err != nil {
+			return
+		}
+		if err := file.Validate(); err != nil {
+			t.Fatalf("parsed constraints do not validate: %v", err)
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
+	"math/big"
+)
+
+// GatewayProof returns the proof in the format of the Brevis gateway: the 0x-prefixed
+// hex abi encoding of (bytes proof, bytes32 vkeyHash, bytes32 digest), the arguments
+// of IPicoVerifier.verify. proof is the 32 byte words of the proof points, then those
+// of the commitment and its proof of knowledge if the proof has one.
+func GatewayProof(proof groth16.Proof, pubWitness witness.Witness) ([]byte, error) {
+	pubInputs, err := PublicInputs(pubWitness)
+	if err != nil {
+		return nil, err
+	}
+	layout, err := PublicLayoutFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	vkeyHash, digest, err := layout.Unpack(pubInputs)
+	if err != nil {
+		return nil, fmt.Errorf("the gateway format needs a single chunk wrapper: %v", err)
+	}
+	onChainProof, err := GetAggOnChainProof(proof, pubWitness)
+	if err != nil {
+		return nil, err
+	}
+	words := onChainProof.Words()
+
+	// the head holds the offset of the dynamic proof, then the static words; the tail
+	// the length of the proof and its words
+	head := []*big.Int{big.NewInt(3 * 32), vkeyHash, digest, big.NewInt(int64(32 * len(words)))}
+	data := make([]byte, 32*(len(head)+len(words)))
+	for i, w := range append(head, words...) {
+		w.FillBytes(data[32*i : 32*(i+1)])
+	}
+	return []byte(Encode(data)), nil
+}
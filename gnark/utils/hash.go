@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr/poseidon2"
+	"golang.org/x/crypto/sha3"
+	"hash"
+	"os"
+)
+
+const (
+	HashToFieldKeccak256 = "keccak256"
+	HashToFieldSha256    = "sha256"
+	HashToFieldPoseidon2 = "poseidon2"
+)
+
+// HashToFieldName is the hash to field function selected through the HASH_TO_FIELD
+// environment variable, keccak256 if unset.
+func HashToFieldName() string {
+	name := os.Getenv("HASH_TO_FIELD")
+	if name == "" {
+		return HashToFieldKeccak256
+	}
+	return name
+}
+
+// HashToFieldFromEnv returns a new hasher of HashToFieldName. The prover hashes the
+// commitment to the field with it, and the verifier must use the same function.
+func HashToFieldFromEnv() (hash.Hash, error) {
+	return NewHashToField(HashToFieldName())
+}
+
+// NewHashToField returns a new hasher for the commitment hash to field: keccak256 (the
+// default, cheapest on the EVM), sha256 or poseidon2 over BN254.
+func NewHashToField(name string) (hash.Hash, error) {
+	switch name {
+	case "", HashToFieldKeccak256:
+		return sha3.NewLegacyKeccak256(), nil
+	case HashToFieldSha256:
+		return sha256.New(), nil
+	case HashToFieldPoseidon2:
+		return &poseidon2Hash{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported hash to field: %s, support keccak256/sha256/poseidon2", name)
+	}
+}
+
+// poseidon2Limb is the size of the chunks poseidon2Hash absorbs, small enough for any
+// chunk to be a canonical field element.
+const poseidon2Limb = 16
+
+// poseidon2Hash is the Poseidon2 Merkle-Damgard hash of gnark-crypto over inputs split
+// into 16 byte big-endian limbs, as the hasher itself only accepts canonical field
+// elements and the commitment is hashed as base field coordinates.
+type poseidon2Hash struct {
+	data []byte
+}
+
+func (h *poseidon2Hash) Write(p []byte) (int, error) {
+	h.data = append(h.data, p...)
+	return len(p), nil
+}
+
+func (h *poseidon2Hash) Sum(b []byte) []byte {
+	md := poseidon2.NewMerkleDamgardHasher()
+	for data := h.data; len(data) > 0; {
+		n := min(len(data), poseidon2Limb)
+		var word [fr.Bytes]byte
+		copy(word[fr.Bytes-n:], data[:n])
+		md.Write(word[:])
+		data = data[n:]
+	}
+	return md.Sum(b)
+}
+
+func (h *poseidon2Hash) Reset() {
+	h.data = h.data[:0]
+}
+
+func (h *poseidon2Hash) Size() int {
+	return fr.Bytes
+}
+
+func (h *poseidon2Hash) BlockSize() int {
+	return poseidon2Limb
+}
@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"bytes"
+	"github.com/consensys/gnark/test"
+	"testing"
+)
+
+func TestHashToField(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	_, err := NewHashToField("blake2")
+	assert.Error(err)
+
+	t.Setenv("HASH_TO_FIELD", "")
+	assert.Equal(HashToFieldKeccak256, HashToFieldName())
+
+	// poseidon2 hashes words above the scalar field, as commitment coordinates are
+	h, err := NewHashToField(HashToFieldPoseidon2)
+	assert.NoError(err)
+	high := bytes.Repeat([]byte{0xff}, 64)
+	h.Write(high)
+	digest := h.Sum(nil)
+	assert.Equal(32, len(digest))
+	h.Reset()
+	high[63] = 0xfe
+	h.Write(high)
+	assert.NotEqual(digest, h.Sum(nil))
+	h.Reset()
+	high[63] = 0xff
+	h.Write(high)
+	assert.Equal(digest, h.Sum(nil))
+}
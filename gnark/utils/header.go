@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/consensys/gnark-crypto/ecc"
+	"io"
+	"runtime/debug"
+	"strings"
+)
+
+const KeyFormatVersion = 1
+
+// maxHeaderSize bounds the header length read from a file, so a corrupt length can not
+// make readHeader allocate gigabytes.
+const maxHeaderSize = 1 << 20
+
+// keyMagic starts every pk and vk file written with a header. Files without it are
+// read as the raw gnark serialization written by older versions.
+var keyMagic = []byte("PICOKEY\x00")
+
+// KeyHeader describes a pk or vk file, so a file for another curve or gnark version is
+// rejected before minutes are spent deserializing it.
+type KeyHeader struct {
+	Version      int    `json:"version"`
+	Kind         string `json:"kind"`
+	Curve        string `json:"curve"`
+	GnarkVersion string `json:"gnark_version"`
+	// VkFingerprint is the fingerprint of the vk generated together with a pk, so a
+	// pk can never be paired with another vk unnoticed.
+	VkFingerprint string `json:"vk_fingerprint,omitempty"`
+	// Raw is set for keys written uncompressed with FAST_KEYS=1.
+	Raw bool `json:"raw,omitempty"`
+}
+
+// NewKeyHeader describes a key of kind "pk" or "vk" for curve written by this binary.
+func NewKeyHeader(kind string, curve ecc.ID) *KeyHeader {
+	return &KeyHeader{
+		Version:      KeyFormatVersion,
+		Kind:         kind,
+		Curve:        curve.String(),
+		GnarkVersion: GnarkVersion(),
+	}
+}
+
+// CheckCompatible returns an error telling how to fix the first difference between h
+// and the header expected by this binary.
+func (h *KeyHeader) CheckCompatible(expected *KeyHeader) error {
+	if h.Version != KeyFormatVersion {
+		return fmt.Errorf("unsupported key format version %d, rerun setup", h.Version)
+	}
+	if h.Kind != expected.Kind {
+		return fmt.Errorf("file holds a %s, expected a %s; check the -pk and -vk paths", h.Kind, expected.Kind)
+	}
+	if h.Curve != expected.Curve {
+		return fmt.Errorf("%s generated for curve %s, expected %s; pass -curve %s or rerun setup", h.Kind, h.Curve, expected.Curve, h.Curve)
+	}
+	err := checkGnarkVersion(h.GnarkVersion, expected.GnarkVersion)
+	if err != nil {
+		return fmt.Errorf("%s %v; rerun setup or build with gnark %s", h.Kind, err, h.GnarkVersion)
+	}
+	return nil
+}
+
+// checkGnarkVersion accepts artifacts written by the same gnark major.minor as the
+// running one, whose serialization formats match. Other differences, including
+// versions that can not be parsed, are only reported.
+func checkGnarkVersion(written, running string) error {
+	if written == running {
+		return nil
+	}
+	writtenMinor, ok1 := gnarkMinorVersion(written)
+	runningMinor, ok2 := gnarkMinorVersion(running)
+	if ok1 && ok2 && writtenMinor != runningMinor {
+		return fmt.Errorf("written by gnark %s, this binary uses %s", written, running)
+	}
+	fmt.Printf("warning: artifact written by gnark %s, this binary uses %s\n", written, running)
+	return nil
+}
+
+// gnarkMinorVersion returns the "vMAJOR.MINOR" prefix of a module version.
+func gnarkMinorVersion(version string) (string, bool) {
+	if !strings.HasPrefix(version, "v") {
+		return "", false
+	}
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 3 {
+		return "", false
+	}
+	return parts[0] + "." + parts[1], true
+}
+
+// ReadKeyHeader reads only the header of a pk or vk file, nil if it has none.
+func ReadKeyHeader(filename string) (*KeyHeader, error) {
+	f, err := OpenArtifact(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := decryptKeyFile(bufio.NewReader(f), filename)
+	if err != nil {
+		return nil, err
+	}
+	var header KeyHeader
+	ok, err := readHeader(r, keyMagic, &header)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &header, nil
+}
+
+// readKeyFile opens a pk or vk file, decrypting it if it is encrypted, and checks its
+// header, if any, against expected. The returned reader is positioned at the gnark
+// serialization.
+func readKeyFile(filename string, expected *KeyHeader, read func(r io.Reader) error) error {
+	f, err := OpenArtifact(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	progress, stop := withProgress(filename, f, artifactSize(f))
+	defer stop()
+	r, err := decryptKeyFile(bufio.NewReaderSize(progress, 1<<20), filename)
+	if err != nil {
+		return err
+	}
+	var header KeyHeader
+	ok, err := readHeader(r, keyMagic, &header)
+	if err != nil {
+		return err
+	}
+	if ok {
+		err = header.CheckCompatible(expected)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+	}
+	return read(r)
+}
+
+// writeKeyFile writes a pk or vk file through store, encrypted if keys are encrypted
+// at rest.
+func writeKeyFile(store ArtifactWriter, filename string, header *KeyHeader, write func(w io.Writer) error) error {
+	return store(filename, func(w io.Writer) error {
+		w, finish, err := encryptKeyFile(w)
+		if err != nil {
+			return err
+		}
+		err = writeHeader(w, keyMagic, header)
+		if err != nil {
+			return err
+		}
+		err = write(w)
+		if err != nil {
+			return err
+		}
+		return finish()
+	})
+}
+
+// writeHeader writes magic, the length of the json encoded header as a big-endian
+// uint32 and the header itself.
+func writeHeader(w io.Writer, magic []byte, header any) error {
+	data, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(data)))
+	for _, b := range [][]byte{magic, length[:], data} {
+		_, err := w.Write(b)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readHeader decodes a header written by writeHeader into header. ok is false and
+// nothing is consumed if r does not start with magic.
+func readHeader(r *bufio.Reader, magic []byte, header any) (ok bool, err error) {
+	prefix, err := r.Peek(len(magic))
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	if !bytes.Equal(prefix, magic) {
+		return false, nil
+	}
+	_, err = r.Discard(len(magic))
+	if err != nil {
+		return false, err
+	}
+
+	var length [4]byte
+	_, err = io.ReadFull(r, length[:])
+	if err != nil {
+		return false, fmt.Errorf("fail to read header: %v", err)
+	}
+	size := binary.BigEndian.Uint32(length[:])
+	if size > maxHeaderSize {
+		return false, fmt.Errorf("header of %d bytes exceeds %d bytes, file is corrupt", size, maxHeaderSize)
+	}
+	data := make([]byte, size)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return false, fmt.Errorf("fail to read header: %v", err)
+	}
+
+	err = json.Unmarshal(data, header)
+	if err != nil {
+		return false, fmt.Errorf("fail to parse header: %v", err)
+	}
+	return true, nil
+}
+
+// GnarkVersion is the version of the gnark module this binary is built with.
+func GnarkVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == "github.com/consensys/gnark" {
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeyHeader(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &squareCircuit{})
+	assert.NoError(err)
+	pk, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+
+	dir := t.TempDir()
+	pkPath := filepath.Join(dir, "vm_pk")
+	vkPath := filepath.Join(dir, "vm_vk")
+	assert.NoError(WriteProvingKey(pkPath, pk))
+	assert.NoError(WriteVerifyingKey(vkPath, vk))
+
+	header, err := ReadKeyHeader(vkPath)
+	assert.NoError(err)
+	assert.Equal("vk", header.Kind)
+	assert.Equal(ecc.BN254.String(), header.Curve)
+
+	readPk := groth16.NewProvingKey(ecc.BN254)
+	assert.NoError(ReadProvingKey(pkPath, readPk))
+	readVk := groth16.NewVerifyingKey(ecc.BN254)
+	assert.NoError(ReadVerifyingKey(vkPath, readVk))
+	assert.NoError(CheckKeyPair(readPk, readVk))
+
+	err = ReadVerifyingKey(pkPath, groth16.NewVerifyingKey(ecc.BN254))
+	assert.Error(err)
+	assert.Contains(err.Error(), "holds a pk")
+	err = ReadVerifyingKey(vkPath, groth16.NewVerifyingKey(ecc.BLS12_381))
+	assert.Error(err)
+	assert.Contains(err.Error(), "-curve bn254")
+
+	// keys written before the header was added are still readable
+	legacyPath := filepath.Join(dir, "legacy_vk")
+	assert.NoError(WriteFileAtomic(legacyPath, func(w io.Writer) error {
+		_, err := vk.WriteTo(w)
+		return err
+	}))
+	header, err = ReadKeyHeader(legacyPath)
+	assert.NoError(err)
+	assert.Nil(header)
+	assert.NoError(ReadVerifyingKey(legacyPath, groth16.NewVerifyingKey(ecc.BN254)))
+}
+
+func TestFastKeys(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &squareCircuit{})
+	assert.NoError(err)
+	pk, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+
+	dir := t.TempDir()
+	pkPath := filepath.Join(dir, "vm_pk")
+	vkPath := filepath.Join(dir, "vm_vk")
+	t.Setenv("FAST_KEYS", "")
+	assert.NoError(WriteProvingKey(pkPath, pk))
+	compressed, err := os.Stat(pkPath)
+	assert.NoError(err)
+	t.Setenv("FAST_KEYS", "1")
+	assert.NoError(WriteKeyPair(WriteArtifact, pkPath, vkPath, pk, vk))
+	raw, err := os.Stat(pkPath)
+	assert.NoError(err)
+	assert.Greater(raw.Size(), compressed.Size())
+	header, err := ReadKeyHeader(pkPath)
+	assert.NoError(err)
+	assert.True(header.Raw)
+
+	// raw keys are read with and without the checks
+	for _, fast := range []string{"1", ""} {
+		t.Setenv("FAST_KEYS", fast)
+		readPk := groth16.NewProvingKey(ecc.BN254)
+		assert.NoError(ReadProvingKey(pkPath, readPk))
+		readVk := groth16.NewVerifyingKey(ecc.BN254)
+		assert.NoError(ReadVerifyingKey(vkPath, readVk))
+		assert.NoError(CheckKeyPair(readPk, readVk))
+		checked, err := CheckKeyFingerprint(pkPath, readVk)
+		assert.NoError(err)
+		assert.True(checked)
+	}
+}
+
+func TestKeyHeaderGnarkVersion(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	expected := &KeyHeader{Version: KeyFormatVersion, Kind: "vk", Curve: "bn254", GnarkVersion: "v0.14.0"}
+	for version, compatible := range map[string]bool{
+		"v0.14.0":                            true,
+		"v0.14.2":                            true,
+		"v0.14.1-0.20250101000000-abcdef123": true,
+		"unknown":                            true,
+		"(devel)":                            true,
+		"v0.13.0":                            false,
+		"v1.14.0":                            false,
+	} {
+		header := *expected
+		header.GnarkVersion = version
+		err := header.CheckCompatible(expected)
+		if compatible {
+			assert.NoError(err, version)
+		} else {
+			assert.Error(err, version)
+		}
+	}
+}
+
+func TestReadHeaderSizeCap(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	var data bytes.Buffer
+	data.Write(keyMagic)
+	data.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	var header KeyHeader
+	_, err := readHeader(bufio.NewReader(&data), keyMagic, &header)
+	assert.Error(err)
+	assert.Contains(err.Error(), "corrupt")
+}
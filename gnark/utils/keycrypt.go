@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"filippo.io/age"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Keys are encrypted at rest as age files, with filippo.io/age, when setup runs with
+// KEY_PASSPHRASE_FILE, the file holding a passphrase, or KEY_RECIPIENT, comma separated
+// age public keys. They are decrypted when read with the same passphrase, or with the
+// age secret keys of the identity file at KEY_IDENTITY_FILE. Keys written in plaintext
+// are still read without either.
+
+// ageVersionLine starts the header of an age file.
+const ageVersionLine = "age-encryption.org/v1"
+
+// ageScryptWorkFactor is the log2 of the scrypt cost of passphrase encryption, the
+// default of the age cli.
+var ageScryptWorkFactor = 18
+
+// keyPassphrase reads the passphrase of KEY_PASSPHRASE_FILE, nil if unset.
+func keyPassphrase() ([]byte, error) {
+	path := os.Getenv("KEY_PASSPHRASE_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read key passphrase: %v", err)
+	}
+	passphrase := bytes.TrimRight(data, "\r\n")
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("key passphrase file %s is empty", path)
+	}
+	return passphrase, nil
+}
+
+// keyRecipients are the recipients the keys are encrypted to, none to write them in
+// plaintext.
+func keyRecipients() ([]age.Recipient, error) {
+	passphrase, err := keyPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	var recipients []age.Recipient
+	for _, s := range strings.Split(os.Getenv("KEY_RECIPIENT"), ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		recipient, err := age.ParseX25519Recipient(s)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, recipient)
+	}
+	if passphrase != nil {
+		// age files encrypted with a passphrase have no other recipient
+		if len(recipients) > 0 {
+			return nil, fmt.Errorf("keys are encrypted with a passphrase or to recipients, not both")
+		}
+		recipient, err := age.NewScryptRecipient(string(passphrase))
+		if err != nil {
+			return nil, err
+		}
+		recipient.SetWorkFactor(ageScryptWorkFactor)
+		recipients = append(recipients, recipient)
+	}
+	return recipients, nil
+}
+
+// keyIdentities are the identities decrypting the keys.
+func keyIdentities() ([]age.Identity, error) {
+	passphrase, err := keyPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	var identities []age.Identity
+	if passphrase != nil {
+		identity, err := age.NewScryptIdentity(string(passphrase))
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	path := os.Getenv("KEY_IDENTITY_FILE")
+	if path == "" {
+		return identities, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read key identity: %v", err)
+	}
+	// an identity file of age-keygen: one secret key per line, with comments
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		identity, err := age.ParseX25519Identity(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, i+1, err)
+		}
+		identities = append(identities, identity)
+	}
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("key identity file %s has no secret key", path)
+	}
+	return identities, nil
+}
+
+// encryptKeyFile returns the writer of a key file to w, encrypted if keyRecipients
+// are set. finish must be called once the key is written.
+func encryptKeyFile(w io.Writer) (encrypted io.Writer, finish func() error, err error) {
+	recipients, err := keyRecipients()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(recipients) == 0 {
+		return w, func() error { return nil }, nil
+	}
+	aw, err := age.Encrypt(w, recipients...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fail to encrypt key: %v", err)
+	}
+	return aw, aw.Close, nil
+}
+
+// decryptKeyFile returns the reader of the key file filename read from r, decrypted if
+// it is encrypted.
+func decryptKeyFile(r *bufio.Reader, filename string) (*bufio.Reader, error) {
+	if !isAgeFile(r) {
+		return r, nil
+	}
+	identities, err := keyIdentities()
+	if err != nil {
+		return nil, err
+	}
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("%s is encrypted, set -key-passphrase-file or -key-identity", filename)
+	}
+	decrypted, err := age.Decrypt(r, identities...)
+	var noIdentity *age.NoIdentityMatchError
+	if errors.As(err, &noIdentity) {
+		return nil, fmt.Errorf("%s is encrypted, no identity matches its recipients; check -key-passphrase-file and -key-identity", filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fail to decrypt %s: %v", filename, err)
+	}
+	return bufio.NewReaderSize(decrypted, 1<<20), nil
+}
+
+// isAgeFile reports whether r, buffered, starts with an age header.
+func isAgeFile(r *bufio.Reader) bool {
+	prefix, _ := r.Peek(len(ageVersionLine) + 1)
+	return string(prefix) == ageVersionLine+"\n"
+}
+
+// OpenKeyArtifact opens a pk or vk file for reading like OpenArtifact, decrypted if it
+// is encrypted.
+func OpenKeyArtifact(path string) (io.ReadCloser, error) {
+	f, err := OpenArtifact(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := decryptKeyFile(bufio.NewReader(f), path)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, f}, nil
+}
+
+// IsKeyEncrypted reports whether the key file at path is encrypted.
+func IsKeyEncrypted(path string) (bool, error) {
+	f, err := OpenArtifact(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	return isAgeFile(bufio.NewReader(f)), nil
+}
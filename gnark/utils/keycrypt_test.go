@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"filippo.io/age"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/test"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestAgeIdentity(t *testing.T) (identity, recipient string) {
+	x25519, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return x25519.String(), x25519.Recipient().String()
+}
+
+func TestKeyEncryption(t *testing.T) {
+	assert := test.NewAssert(t)
+	ageScryptWorkFactor = 10
+	dir := t.TempDir()
+	passphraseFile := filepath.Join(dir, "passphrase")
+	assert.NoError(os.WriteFile(passphraseFile, []byte("correct horse\n"), 0600))
+	identity, recipient := newTestAgeIdentity(t)
+	identityFile := filepath.Join(dir, "identity")
+	assert.NoError(os.WriteFile(identityFile, []byte("# created: today\n# public key: "+recipient+"\n"+identity+"\n"), 0600))
+
+	header := NewKeyHeader("vk", ecc.BN254)
+	write := func(name string) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(writeKeyFile(WriteArtifact, path, header, func(w io.Writer) error {
+			_, err := w.Write([]byte("key"))
+			return err
+		}))
+		return path
+	}
+	read := func(path string) (string, error) {
+		var key []byte
+		err := readKeyFile(path, header, func(r io.Reader) error {
+			var err error
+			key, err = io.ReadAll(r)
+			return err
+		})
+		return string(key), err
+	}
+
+	plain := write("plain_vk")
+	t.Setenv("KEY_PASSPHRASE_FILE", passphraseFile)
+	passphraseKey := write("passphrase_vk")
+	t.Setenv("KEY_RECIPIENT", recipient)
+	_, err := keyRecipients()
+	assert.ErrorContains(err, "not both")
+	t.Setenv("KEY_PASSPHRASE_FILE", "")
+	recipientKey := write("recipient_vk")
+
+	for _, path := range []string{passphraseKey, recipientKey} {
+		encrypted, err := IsKeyEncrypted(path)
+		assert.NoError(err)
+		assert.True(encrypted)
+		_, err = read(path)
+		assert.ErrorContains(err, "is encrypted, set -key-passphrase-file or -key-identity")
+	}
+
+	t.Setenv("KEY_PASSPHRASE_FILE", passphraseFile)
+	t.Setenv("KEY_IDENTITY_FILE", identityFile)
+	for _, path := range []string{plain, passphraseKey, recipientKey} {
+		key, err := read(path)
+		assert.NoError(err, path)
+		assert.Equal("key", key)
+		readHeader, err := ReadKeyHeader(path)
+		assert.NoError(err)
+		assert.Equal(header, readHeader)
+	}
+
+	t.Setenv("KEY_PASSPHRASE_FILE", "")
+	_, err = read(passphraseKey)
+	assert.ErrorContains(err, "no identity matches")
+	wrongPassphraseFile := filepath.Join(dir, "wrong_passphrase")
+	assert.NoError(os.WriteFile(wrongPassphraseFile, []byte("wrong horse\n"), 0600))
+	t.Setenv("KEY_PASSPHRASE_FILE", wrongPassphraseFile)
+	_, err = read(passphraseKey)
+	assert.ErrorContains(err, "no identity matches")
+}
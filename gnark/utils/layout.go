@@ -0,0 +1,380 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/brevis-network/pico/gnark/decompose"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	PublicInputOrderVkeyFirst   = "vkey-first"
+	PublicInputOrderDigestFirst = "digest-first"
+
+	PublicInputPackingField = "field"
+	PublicInputPackingLimbs = "limbs"
+
+	PublicInputValuesWords = "words"
+	PublicInputValuesRoot  = "root"
+
+	PublicInputDigestHashSha256    = "sha256"
+	PublicInputDigestHashKeccak256 = "keccak256"
+)
+
+const (
+	// limbBits is the size of the limbs of PublicInputPackingLimbs.
+	limbBits = 128
+	// fieldBits is the bit length of the BN254 scalar field.
+	fieldBits = 254
+)
+
+// PublicLayout controls how the vkey hash and the committed values digest of a single
+// chunk wrapper are laid out in its public inputs, to match existing on-chain
+// verifiers. The zero value is the default layout: the vkey hash, then the digest,
+// each as one field element.
+type PublicLayout struct {
+	// DigestFirst puts the committed values digest before the vkey hash.
+	DigestFirst bool
+	// DigestBits truncates the digest to its low DigestBits bits, 0 keeps all of it.
+	DigestBits int
+	// Limbs splits every value in two 128 bit limbs, high limb first, for verifiers
+	// taking 256 bit words.
+	Limbs bool
+	// PublicValues exposes the public values the digest commits to after the vkey
+	// hash and the digest, for values of exactly PublicValues 32 byte words: each
+	// word in two 128 bit limbs, high limb first, or with PublicValuesRoot only the
+	// root of their Merkle tree, see PublicValuesRoot. 0 exposes no value.
+	PublicValues     int
+	PublicValuesRoot bool
+	// KeccakDigest exposes the keccak256 of the public values in place of the
+	// committed values digest, their sha256, which the circuit then checks privately.
+	// It needs PublicValues, and the 3 top bits of the hash are dropped likewise.
+	KeccakDigest bool
+	// FixedVkeyHash is the 0x prefixed vkey hash of the only program the circuit
+	// verifies, a constant of the circuit instead of a public input; empty for any
+	// program.
+	FixedVkeyHash string
+}
+
+// ParsePublicLayout parses the order (vkey-first/digest-first), digest bits, packing
+// (field/limbs), exposed public values (words=N/root=N), digest hash
+// (sha256/keccak256) and fixed vkey hash of a layout. Empty values select the defaults.
+func ParsePublicLayout(order, digestBits, packing, values, digestHash, fixedVkeyHash string) (PublicLayout, error) {
+	var layout PublicLayout
+	switch order {
+	case "", PublicInputOrderVkeyFirst:
+	case PublicInputOrderDigestFirst:
+		layout.DigestFirst = true
+	default:
+		return layout, fmt.Errorf("unsupported public input order: %s, support vkey-first/digest-first", order)
+	}
+	if digestBits != "" {
+		bits, err := strconv.Atoi(digestBits)
+		if err != nil || bits < 0 || bits >= fieldBits {
+			return layout, fmt.Errorf("invalid digest bits: %s, expected 0 to %d", digestBits, fieldBits-1)
+		}
+		layout.DigestBits = bits
+	}
+	switch packing {
+	case "", PublicInputPackingField:
+	case PublicInputPackingLimbs:
+		layout.Limbs = true
+	default:
+		return layout, fmt.Errorf("unsupported public input packing: %s, support field/limbs", packing)
+	}
+	if values != "" {
+		mode, words, ok := strings.Cut(values, "=")
+		n, err := strconv.Atoi(words)
+		if !ok || (mode != PublicInputValuesWords && mode != PublicInputValuesRoot) || err != nil || n <= 0 {
+			return layout, fmt.Errorf("invalid public input values: %s, expected words=N or root=N with N > 0", values)
+		}
+		layout.PublicValues = n
+		layout.PublicValuesRoot = mode == PublicInputValuesRoot
+	}
+	switch digestHash {
+	case "", PublicInputDigestHashSha256:
+	case PublicInputDigestHashKeccak256:
+		if layout.PublicValues == 0 {
+			return layout, fmt.Errorf("a %s digest is computed from the public values, expose them with words=N or root=N", digestHash)
+		}
+		layout.KeccakDigest = true
+	default:
+		return layout, fmt.Errorf("unsupported public input digest hash: %s, support sha256/keccak256", digestHash)
+	}
+	if fixedVkeyHash != "" {
+		vkeyHash, ok := new(big.Int).SetString(fixedVkeyHash, 0)
+		if !ok || vkeyHash.Sign() < 0 || vkeyHash.Cmp(ecc.BN254.ScalarField()) >= 0 {
+			return layout, fmt.Errorf("invalid fixed vkey hash: %s, expected a BN254 field element", fixedVkeyHash)
+		}
+		layout.FixedVkeyHash = fmt.Sprintf("%#x", vkeyHash)
+	}
+	return layout, nil
+}
+
+// PublicLayoutFromEnv reads the layout selected through the PUBLIC_INPUT_ORDER,
+// PUBLIC_INPUT_DIGEST_BITS, PUBLIC_INPUT_PACKING, PUBLIC_INPUT_VALUES,
+// PUBLIC_INPUT_DIGEST_HASH and PUBLIC_INPUT_VKEY environment variables.
+func PublicLayoutFromEnv() (PublicLayout, error) {
+	return ParsePublicLayout(os.Getenv("PUBLIC_INPUT_ORDER"), os.Getenv("PUBLIC_INPUT_DIGEST_BITS"), os.Getenv("PUBLIC_INPUT_PACKING"),
+		os.Getenv("PUBLIC_INPUT_VALUES"), os.Getenv("PUBLIC_INPUT_DIGEST_HASH"), os.Getenv("PUBLIC_INPUT_VKEY"))
+}
+
+// IsDefault reports whether l is the layout of the circuits without layout options.
+func (l PublicLayout) IsDefault() bool {
+	return l == PublicLayout{}
+}
+
+// String describes l for the ccs header, empty for the default layout so headers
+// written before layouts existed stay compatible.
+func (l PublicLayout) String() string {
+	if l.IsDefault() {
+		return ""
+	}
+	order, packing := PublicInputOrderVkeyFirst, PublicInputPackingField
+	if l.DigestFirst {
+		order = PublicInputOrderDigestFirst
+	}
+	if l.Limbs {
+		packing = PublicInputPackingLimbs
+	}
+	res := fmt.Sprintf("%s,digest-bits=%d,%s", order, l.DigestBits, packing)
+	if l.PublicValues > 0 {
+		res += "," + l.valuesString()
+	}
+	if l.KeccakDigest {
+		res += ",digest-hash=" + PublicInputDigestHashKeccak256
+	}
+	if l.FixedVkeyHash != "" {
+		res += ",vkey=" + l.FixedVkeyHash
+	}
+	return res
+}
+
+// fixedVkeyHash is the value of FixedVkeyHash, nil if l verifies any program.
+func (l PublicLayout) fixedVkeyHash() *big.Int {
+	if l.FixedVkeyHash == "" {
+		return nil
+	}
+	vkeyHash, _ := new(big.Int).SetString(l.FixedVkeyHash, 0)
+	return vkeyHash
+}
+
+// CheckVkeyHash fails if l is fixed to the vkey hash of another program.
+func (l PublicLayout) CheckVkeyHash(vkeyHash *big.Int) error {
+	if fixed := l.fixedVkeyHash(); fixed != nil && fixed.Cmp(vkeyHash) != 0 {
+		return fmt.Errorf("vkey hash %#x, the circuit is fixed to %s", vkeyHash, l.FixedVkeyHash)
+	}
+	return nil
+}
+
+// layoutValues returns the values the public inputs of l hold, in order: vkeyHash and
+// digest, or only digest if the vkey hash is fixed.
+func layoutValues[T any](l PublicLayout, vkeyHash, digest T) []T {
+	switch {
+	case l.FixedVkeyHash != "":
+		return []T{digest}
+	case l.DigestFirst:
+		return []T{digest, vkeyHash}
+	default:
+		return []T{vkeyHash, digest}
+	}
+}
+
+// NbPublicInputs is the number of public inputs of a single chunk wrapper.
+func (l PublicLayout) NbPublicInputs() int {
+	return l.nbDigestInputs() + l.nbValueInputs()
+}
+
+// nbDigestInputs is the number of public inputs of the vkey hash and the digest.
+func (l PublicLayout) nbDigestInputs() int {
+	n := len(layoutValues(l, 0, 0))
+	if l.Limbs {
+		return 2 * n
+	}
+	return n
+}
+
+// Pack returns the public inputs of a wrapper committing to vkeyHash and
+// committedValuesDigest, without those of the public values, see PackPublicValues.
+func (l PublicLayout) Pack(vkeyHash, committedValuesDigest *big.Int) []*big.Int {
+	digest := new(big.Int).Set(committedValuesDigest)
+	if l.DigestBits > 0 {
+		digest.Mod(digest, new(big.Int).Lsh(big.NewInt(1), uint(l.DigestBits)))
+	}
+	values := layoutValues(l, vkeyHash, digest)
+	if !l.Limbs {
+		return values
+	}
+	mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), limbBits), big.NewInt(1))
+	var public []*big.Int
+	for _, v := range values {
+		public = append(public, new(big.Int).Rsh(v, limbBits), new(big.Int).And(v, mask))
+	}
+	return public
+}
+
+// Unpack returns the vkey hash and committed values digest, truncated to DigestBits,
+// of the public inputs of a wrapper. For the default layout the digest is the first
+// of a multi-chunk wrapper; with KeccakDigest it is the keccak256 of the public values.
+// The vkey hash of a layout with FixedVkeyHash is the fixed one.
+func (l PublicLayout) Unpack(public []*big.Int) (vkeyHash, committedValuesDigest *big.Int, err error) {
+	if l.IsDefault() {
+		if len(public) < 2 {
+			return nil, nil, fmt.Errorf("expected at least 2 public inputs, got %d", len(public))
+		}
+		return public[0], public[1], nil
+	}
+	if len(public) != l.NbPublicInputs() {
+		return nil, nil, fmt.Errorf("expected %d public inputs, got %d", l.NbPublicInputs(), len(public))
+	}
+	values := public[:l.nbDigestInputs()]
+	if l.Limbs {
+		values = nil
+		for i := 0; i < l.nbDigestInputs(); i += 2 {
+			values = append(values, new(big.Int).Add(new(big.Int).Lsh(public[i], limbBits), public[i+1]))
+		}
+	}
+	switch {
+	case l.FixedVkeyHash != "":
+		return l.fixedVkeyHash(), values[0], nil
+	case l.DigestFirst:
+		return values[1], values[0], nil
+	default:
+		return values[0], values[1], nil
+	}
+}
+
+// Constrain asserts public holds vkeyHash and committedValuesDigest laid out as l. The
+// digest is truncated and the values split in limbs by bit decomposition, or with d
+// if it is not nil. publicValues are the bytes of the public values l exposes, see
+// constrainPublicValues, nil if it exposes none.
+func (l PublicLayout) Constrain(api frontend.API, d *decompose.Decomposer, public []frontend.Variable, vkeyHash, committedValuesDigest frontend.Variable, publicValues []frontend.Variable) error {
+	if len(public) != l.NbPublicInputs() {
+		return fmt.Errorf("expected %d public inputs, got %d", l.NbPublicInputs(), len(public))
+	}
+	digest := committedValuesDigest
+	if l.PublicValues > 0 {
+		var err error
+		digest, err = l.constrainPublicValues(api, public[l.nbDigestInputs():], committedValuesDigest, publicValues)
+		if err != nil {
+			return err
+		}
+	}
+	if l.DigestBits > 0 {
+		if d != nil {
+			digest = d.Split(digest, l.DigestBits, fieldBits-l.DigestBits)[0]
+		} else {
+			// the full decomposition is unique, so is its truncation
+			digest = api.FromBinary(api.ToBinary(digest)[:l.DigestBits]...)
+		}
+	}
+	if fixed := l.fixedVkeyHash(); fixed != nil {
+		api.AssertIsEqual(vkeyHash, fixed)
+	}
+	values := layoutValues(l, vkeyHash, digest)
+	if !l.Limbs {
+		for i, v := range values {
+			api.AssertIsEqual(public[i], v)
+		}
+		return nil
+	}
+	for i, v := range values {
+		if d != nil {
+			limbs := d.Split(v, limbBits, fieldBits-limbBits)
+			api.AssertIsEqual(public[2*i], limbs[1])
+			api.AssertIsEqual(public[2*i+1], limbs[0])
+			continue
+		}
+		bits := api.ToBinary(v)
+		api.AssertIsEqual(public[2*i], api.FromBinary(bits[limbBits:]...))
+		api.AssertIsEqual(public[2*i+1], api.FromBinary(bits[:limbBits]...))
+	}
+	return nil
+}
+
+// WriteSolidity writes a Solidity library packing a vkey hash and committed values
+// digest into the public inputs of the verifier exported by setup. When l exposes
+// public values, the library packs the vkey hash and the public values instead,
+// hashing them to the digest with sha256, or keccak256 with KeccakDigest.
+func (l PublicLayout) WriteSolidity(w io.Writer) error {
+	n := l.NbPublicInputs()
+	_, err := fmt.Fprintf(w, `// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.0;
+
+/// @title Public inputs of the Pico wrapper verifier
+/// @notice Layout: %s
+library PicoPublicInputs {
+`, l.describe())
+	if err != nil {
+		return err
+	}
+	if l.FixedVkeyHash != "" {
+		_, err = fmt.Fprintf(w, `    /// @notice The vkey hash of the only program the verifier accepts proofs of.
+    uint256 internal constant VKEY_HASH = %s;
+
+    error UnexpectedVkeyHash(uint256 vkeyHash);
+
+`, l.FixedVkeyHash)
+		if err != nil {
+			return err
+		}
+	}
+	if l.PublicValues > 0 {
+		_, err = fmt.Fprintf(w, `    error InvalidPublicValuesLength(uint256 length);
+
+    function pack(uint256 vkeyHash, bytes memory publicValues) internal pure returns (uint256[%d] memory input) {
+        if (publicValues.length != %d) {
+            revert InvalidPublicValuesLength(publicValues.length);
+        }
+        uint256 committedValuesDigest = uint256(%s(publicValues)) & ((1 << 253) - 1);
+`, n, 32*l.PublicValues, l.digestHash())
+	} else {
+		_, err = fmt.Fprintf(w, "    function pack(uint256 vkeyHash, uint256 committedValuesDigest) internal pure returns (uint256[%d] memory input) {\n", n)
+	}
+	if err != nil {
+		return err
+	}
+	if l.FixedVkeyHash != "" {
+		_, err = fmt.Fprint(w, "        if (vkeyHash != VKEY_HASH) {\n            revert UnexpectedVkeyHash(vkeyHash);\n        }\n")
+		if err != nil {
+			return err
+		}
+	}
+	if l.DigestBits > 0 {
+		_, err = fmt.Fprintf(w, "        committedValuesDigest &= (1 << %d) - 1;\n", l.DigestBits)
+		if err != nil {
+			return err
+		}
+	}
+	names := layoutValues(l, "vkeyHash", "committedValuesDigest")
+	for i, name := range names {
+		if l.Limbs {
+			_, err = fmt.Fprintf(w, "        input[%d] = %s >> %d;\n        input[%d] = %s & ((1 << %d) - 1);\n", 2*i, name, limbBits, 2*i+1, name, limbBits)
+		} else {
+			_, err = fmt.Fprintf(w, "        input[%d] = %s;\n", i, name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if l.PublicValues > 0 {
+		err = l.writePublicValuesSolidity(w)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprint(w, "    }\n}\n")
+	return err
+}
+
+func (l PublicLayout) describe() string {
+	if l.IsDefault() {
+		return "vkey-first,digest-bits=0,field"
+	}
+	return l.String()
+}
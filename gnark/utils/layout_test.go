@@ -0,0 +1,248 @@
+package utils
+
+import (
+	"bytes"
+	"github.com/brevis-network/pico/gnark/decompose"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"math/big"
+	"testing"
+)
+
+func TestPublicLayout(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	layout, err := ParsePublicLayout("", "", "", "", "", "")
+	assert.NoError(err)
+	assert.True(layout.IsDefault())
+	assert.Equal("", layout.String())
+	_, err = ParsePublicLayout("digest-last", "", "", "", "", "")
+	assert.Error(err)
+	_, err = ParsePublicLayout("", "254", "", "", "", "")
+	assert.Error(err)
+	_, err = ParsePublicLayout("", "", "bytes", "", "", "")
+	assert.Error(err)
+
+	layout, err = ParsePublicLayout(PublicInputOrderDigestFirst, "253", PublicInputPackingLimbs, "", "", "")
+	assert.NoError(err)
+	assert.Equal(PublicLayout{DigestFirst: true, DigestBits: 253, Limbs: true}, layout)
+	assert.Equal("digest-first,digest-bits=253,limbs", layout.String())
+
+	var sol bytes.Buffer
+	assert.NoError(layout.WriteSolidity(&sol))
+	for _, line := range []string{
+		"returns (uint256[4] memory input)",
+		"committedValuesDigest &= (1 << 253) - 1;",
+		"input[0] = committedValuesDigest >> 128;",
+		"input[1] = committedValuesDigest & ((1 << 128) - 1);",
+		"input[2] = vkeyHash >> 128;",
+		"input[3] = vkeyHash & ((1 << 128) - 1);",
+	} {
+		assert.Contains(sol.String(), line)
+	}
+}
+
+type layoutCircuit struct {
+	Public                [4]frontend.Variable `gnark:",public"`
+	VkeyHash              frontend.Variable
+	CommittedValuesDigest frontend.Variable
+
+	layout PublicLayout
+	lookup bool
+}
+
+func (c *layoutCircuit) Define(api frontend.API) error {
+	var d *decompose.Decomposer
+	if c.lookup {
+		d = decompose.New(api)
+	}
+	return c.layout.Constrain(api, d, c.Public[:], c.VkeyHash, c.CommittedValuesDigest, nil)
+}
+
+func TestConstrainLayout(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	layout := PublicLayout{DigestFirst: true, DigestBits: 253, Limbs: true}
+	vkeyHash, _ := new(big.Int).SetString("0x1c3f0eaa7c5b0e4a8b2c0b1d4f7e3a9b6c5d4e3f2a1b0c9d8e7f6a5b4c3d2e1f", 0)
+	digest := new(big.Int).Sub(ecc.BN254.ScalarField(), big.NewInt(1))
+	var counts [2]int
+	for i, lookup := range []bool{false, true} {
+		assignment := &layoutCircuit{VkeyHash: vkeyHash, CommittedValuesDigest: digest}
+		for j, v := range layout.Pack(vkeyHash, digest) {
+			assignment.Public[j] = v
+		}
+		circuit := &layoutCircuit{layout: layout, lookup: lookup}
+		assert.NoError(test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()))
+		assignment.Public[0] = 0
+		assert.Error(test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()))
+
+		ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
+		assert.NoError(err)
+		counts[i] = ccs.GetNbConstraints()
+	}
+	t.Logf("constraints: bits %d, lookup %d", counts[0], counts[1])
+	assert.Less(counts[1], counts[0])
+}
+
+type fixedVkeyCircuit struct {
+	Public                []frontend.Variable `gnark:",public"`
+	VkeyHash              frontend.Variable
+	CommittedValuesDigest frontend.Variable
+
+	layout PublicLayout
+}
+
+func (c *fixedVkeyCircuit) Define(api frontend.API) error {
+	return c.layout.Constrain(api, nil, c.Public, c.VkeyHash, c.CommittedValuesDigest, nil)
+}
+
+func TestFixedVkeyLayout(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	layout, err := ParsePublicLayout("", "", "", "", "", "42")
+	assert.NoError(err)
+	assert.Equal(PublicLayout{FixedVkeyHash: "0x2a"}, layout)
+	assert.Equal("vkey-first,digest-bits=0,field,vkey=0x2a", layout.String())
+	assert.Equal(1, layout.NbPublicInputs())
+	for _, vkey := range []string{"0xzz", "-1", ecc.BN254.ScalarField().String()} {
+		_, err = ParsePublicLayout("", "", "", "", "", vkey)
+		assert.Error(err)
+	}
+	limbs, err := ParsePublicLayout(PublicInputOrderDigestFirst, "", PublicInputPackingLimbs, "words=2", "", "0x2a")
+	assert.NoError(err)
+	assert.Equal(6, limbs.NbPublicInputs())
+
+	vkeyHash := big.NewInt(42)
+	assert.NoError(layout.CheckVkeyHash(vkeyHash))
+	assert.Error(layout.CheckVkeyHash(big.NewInt(43)))
+	digest := big.NewInt(7)
+	public := layout.Pack(vkeyHash, digest)
+	assert.Equal([]*big.Int{digest}, public)
+	unpackedVkeyHash, unpackedDigest, err := layout.Unpack(public)
+	assert.NoError(err)
+	assert.Equal(0, unpackedVkeyHash.Cmp(vkeyHash))
+	assert.Equal(0, unpackedDigest.Cmp(digest))
+
+	for _, l := range []PublicLayout{layout, {Limbs: true, FixedVkeyHash: "0x2a"}} {
+		circuit := &fixedVkeyCircuit{Public: make([]frontend.Variable, l.NbPublicInputs()), layout: l}
+		assignment := &fixedVkeyCircuit{VkeyHash: vkeyHash, CommittedValuesDigest: digest}
+		for _, v := range l.Pack(vkeyHash, digest) {
+			assignment.Public = append(assignment.Public, v)
+		}
+		assert.NoError(test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()))
+		assignment.VkeyHash = 43
+		assert.Error(test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()), "proof of another program")
+	}
+
+	var sol bytes.Buffer
+	assert.NoError(layout.WriteSolidity(&sol))
+	for _, line := range []string{
+		"uint256 internal constant VKEY_HASH = 0x2a;",
+		"revert UnexpectedVkeyHash(vkeyHash);",
+		"returns (uint256[1] memory input)",
+		"input[0] = committedValuesDigest;",
+	} {
+		assert.Contains(sol.String(), line)
+	}
+}
+
+func TestPublicValuesLayout(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	layout, err := ParsePublicLayout("", "", "", "words=2", "", "")
+	assert.NoError(err)
+	assert.Equal(PublicLayout{PublicValues: 2}, layout)
+	assert.Equal("vkey-first,digest-bits=0,field,words=2", layout.String())
+	assert.Equal(6, layout.NbPublicInputs())
+	layout, err = ParsePublicLayout("", "", PublicInputPackingLimbs, "root=3", "", "")
+	assert.NoError(err)
+	assert.Equal(PublicLayout{Limbs: true, PublicValues: 3, PublicValuesRoot: true}, layout)
+	assert.Equal(6, layout.NbPublicInputs())
+	for _, values := range []string{"words", "words=0", "leaves=2", "root=x"} {
+		_, err = ParsePublicLayout("", "", "", values, "", "")
+		assert.Error(err)
+	}
+	keccak, err := ParsePublicLayout("", "", "", "words=2", PublicInputDigestHashKeccak256, "")
+	assert.NoError(err)
+	assert.Equal(PublicLayout{PublicValues: 2, KeccakDigest: true}, keccak)
+	assert.Equal("vkey-first,digest-bits=0,field,words=2,digest-hash=keccak256", keccak.String())
+	_, err = ParsePublicLayout("", "", "", "", PublicInputDigestHashKeccak256, "")
+	assert.Error(err, "the keccak digest needs the public values")
+	_, err = ParsePublicLayout("", "", "", "words=2", "blake3", "")
+	assert.Error(err)
+
+	// the root of a single word is the word
+	word := bytes.Repeat([]byte{0xab}, 32)
+	root := PublicValuesRoot(word)
+	assert.Equal(word, root[:])
+
+	var sol bytes.Buffer
+	assert.NoError(layout.WriteSolidity(&sol))
+	for _, line := range []string{
+		"function pack(uint256 vkeyHash, bytes memory publicValues) internal pure returns (uint256[6] memory input)",
+		"if (publicValues.length != 96) {",
+		"bytes32[] memory nodes = new bytes32[](4);",
+		"input[4] = uint256(nodes[0]) >> 128;",
+	} {
+		assert.Contains(sol.String(), line)
+	}
+	sol.Reset()
+	assert.NoError(keccak.WriteSolidity(&sol))
+	assert.Contains(sol.String(), "uint256 committedValuesDigest = uint256(keccak256(publicValues)) & ((1 << 253) - 1);")
+}
+
+type publicValuesCircuit struct {
+	Public                [6]frontend.Variable `gnark:",public"`
+	VkeyHash              frontend.Variable
+	CommittedValuesDigest frontend.Variable
+	PublicValues          [96]frontend.Variable
+
+	layout PublicLayout
+}
+
+func (c *publicValuesCircuit) Define(api frontend.API) error {
+	return c.layout.Constrain(api, nil, c.Public[:c.layout.NbPublicInputs()], c.VkeyHash, c.CommittedValuesDigest, c.PublicValues[:32*c.layout.PublicValues])
+}
+
+func TestConstrainPublicValues(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	vkeyHash := big.NewInt(42)
+	for _, layout := range []PublicLayout{{PublicValues: 2}, {Limbs: true, PublicValues: 3, PublicValuesRoot: true}, {PublicValues: 2, KeccakDigest: true}} {
+		publicValues := make([]byte, 32*layout.PublicValues)
+		for i := range publicValues {
+			publicValues[i] = byte(i * 7)
+		}
+		public, err := layout.PackPublicValues(vkeyHash, publicValues)
+		assert.NoError(err)
+		assert.Len(public, layout.NbPublicInputs())
+		unpackedVkeyHash, digest, err := layout.Unpack(public)
+		assert.NoError(err)
+		assert.Equal(0, unpackedVkeyHash.Cmp(vkeyHash))
+		assert.Equal(0, digest.Cmp(layout.Digest(publicValues)))
+		assert.Equal(layout.KeccakDigest, digest.Cmp(CommittedValuesDigest(publicValues)) != 0)
+
+		assignment := &publicValuesCircuit{VkeyHash: vkeyHash, CommittedValuesDigest: CommittedValuesDigest(publicValues)}
+		for i := range assignment.Public {
+			assignment.Public[i] = 0
+		}
+		for i, v := range public {
+			assignment.Public[i] = v
+		}
+		for i := range assignment.PublicValues {
+			assignment.PublicValues[i] = 0
+		}
+		for i, b := range publicValues {
+			assignment.PublicValues[i] = b
+		}
+		circuit := &publicValuesCircuit{layout: layout}
+		assert.NoError(test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()))
+		assignment.PublicValues[1] = 0xff
+		assert.Error(test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()))
+
+		_, err = layout.PackPublicValues(vkeyHash, publicValues[1:])
+		assert.Error(err)
+	}
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// legacyWitnessKeys are the keys of older groth16_witness.json layouts and their
+// current names.
+var legacyWitnessKeys = [][2]string{
+	{"commited_values_digest", "committed_values_digest"},
+	{"committedValuesDigest", "committed_values_digest"},
+	{"vkeyHash", "vkey_hash"},
+	{"friConfig", "fri_config"},
+}
+
+// witnessKeys are the keys of the current layout.
+var witnessKeys = map[string]bool{
+	"vars": true, "felts": true, "exts": true, "vkey_hash": true, "committed_values_digest": true,
+	"fri_config": true, "public_values": true, "version": true, "_config": true,
+}
+
+// UpgradeWitness rewrites a witness json of an older layout, single or multi-chunk,
+// into the current one: legacy key names are renamed and extension elements written
+// as one flat felt list are regrouped by 4. It fails naming what it cannot read, so a
+// witness it does not support does not end as an unsatisfied constraint.
+func UpgradeWitness(data []byte) ([]byte, error) {
+	var file map[string]json.RawMessage
+	err := json.Unmarshal(data, &file)
+	if err != nil {
+		return nil, fmt.Errorf("witness is not a json object: %v", err)
+	}
+	chunksData, ok := file["chunks"]
+	if !ok {
+		err = upgradeWitnessInput(file)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(file)
+	}
+
+	var chunks []map[string]json.RawMessage
+	err = json.Unmarshal(chunksData, &chunks)
+	if err != nil {
+		return nil, fmt.Errorf("witness chunks are not a list of objects: %v", err)
+	}
+	for i := range chunks {
+		err = upgradeWitnessInput(chunks[i])
+		if err != nil {
+			return nil, fmt.Errorf("chunk %d: %v", i, err)
+		}
+	}
+	file["chunks"], err = json.Marshal(chunks)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(file)
+}
+
+func upgradeWitnessInput(w map[string]json.RawMessage) error {
+	for _, rename := range legacyWitnessKeys {
+		value, ok := w[rename[0]]
+		if !ok {
+			continue
+		}
+		if _, ok := w[rename[1]]; ok {
+			return fmt.Errorf("witness has both %s and its legacy name %s", rename[1], rename[0])
+		}
+		w[rename[1]] = value
+		delete(w, rename[0])
+	}
+
+	// a list of strings is either flat felts or, as hex strings unmarshal to neither,
+	// not a legacy layout
+	var flat []string
+	if exts, ok := w["exts"]; ok && json.Unmarshal(exts, &flat) == nil && len(flat) > 0 {
+		if len(flat)%4 != 0 {
+			return fmt.Errorf("flat exts have %d felts, not a multiple of 4", len(flat))
+		}
+		grouped := make([][]string, len(flat)/4)
+		for i := range grouped {
+			grouped[i] = flat[4*i : 4*i+4]
+		}
+		data, err := json.Marshal(grouped)
+		if err != nil {
+			return err
+		}
+		w["exts"] = data
+	}
+
+	// missing commitments next to keys of no known layout are a layout this build
+	// does not know
+	unknown := unknownWitnessKeys(w)
+	for _, key := range []string{"vkey_hash", "committed_values_digest"} {
+		if _, ok := w[key]; !ok && unknown != "" {
+			return fmt.Errorf("unsupported witness layout: no %s%s", key, unknown)
+		}
+	}
+	return nil
+}
+
+func unknownWitnessKeys(w map[string]json.RawMessage) string {
+	var unknown []string
+	for key := range w {
+		if !witnessKeys[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) == 0 {
+		return ""
+	}
+	sort.Strings(unknown)
+	return fmt.Sprintf(", unknown keys %v", unknown)
+}
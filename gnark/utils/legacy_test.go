@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"github.com/consensys/gnark/test"
+	"testing"
+)
+
+func TestUpgradeWitness(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// renamed keys and flat exts
+	single, _, err := ParseWitness([]byte(`{
+		"vars": ["1"], "felts": ["2"], "exts": ["1", "2", "3", "4", "5", "6", "7", "8"],
+		"vkeyHash": "7", "commited_values_digest": "9"
+	}`))
+	assert.NoError(err)
+	assert.Equal("7", single.VkeyHash)
+	assert.Equal("9", single.CommittedValuesDigest)
+	assert.Equal(ExtArray{{"1", "2", "3", "4"}, {"5", "6", "7", "8"}}, single.Exts)
+
+	_, multi, err := ParseWitness([]byte(`{"chunks": [
+		{"vars": ["1"], "vkey_hash": "7", "committed_values_digest": "9"},
+		{"vars": ["2"], "vkey_hash": "7", "committedValuesDigest": "10"}
+	]}`))
+	assert.NoError(err)
+	assert.Equal("10", multi.Chunks[1].CommittedValuesDigest)
+
+	// the current layout is not rewritten
+	single, _, err = ParseWitness([]byte(`{"exts": [["1", "2", "3", "4"]], "vkey_hash": "7", "committed_values_digest": "9"}`))
+	assert.NoError(err)
+	assert.Equal(ExtArray{{"1", "2", "3", "4"}}, single.Exts)
+
+	_, _, err = ParseWitness([]byte(`{"exts": ["1", "2", "3"], "vkey_hash": "7", "committed_values_digest": "9"}`))
+	assert.ErrorContains(err, "flat exts have 3 felts, not a multiple of 4")
+	_, _, err = ParseWitness([]byte(`{"vkeyHash": "7", "vkey_hash": "8", "commited_values_digest": "9"}`))
+	assert.ErrorContains(err, "witness has both vkey_hash and its legacy name vkeyHash")
+	_, _, err = ParseWitness([]byte(`{"vars": ["1"], "program_hash": "7", "digest": "9"}`))
+	assert.ErrorContains(err, "unsupported witness layout: no vkey_hash, unknown keys [digest program_hash]")
+	_, _, err = ParseWitness([]byte(`{"chunks": [{"vars": ["1"], "vkey": "7"}]}`))
+	assert.ErrorContains(err, "chunk 0: unsupported witness layout")
+	_, _, err = ParseWitness([]byte(`[1, 2]`))
+	assert.ErrorContains(err, "witness is not a json object")
+}
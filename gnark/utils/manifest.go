@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/consensys/gnark-crypto/ecc"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// ManifestExt is appended to the path of a proof file for the path of its manifest.
+const ManifestExt = ".manifest.json"
+
+// ArtifactDigest identifies a file by its sha256, as sha256sum prints it.
+type ArtifactDigest struct {
+	Path   string `json:"path"`
+	Sha256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// DigestArtifact hashes the artifact at path, see StorageFor.
+func DigestArtifact(path string) (*ArtifactDigest, error) {
+	r, err := OpenArtifact(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, r)
+	if err != nil {
+		return nil, fmt.Errorf("fail to hash %s: %v", path, err)
+	}
+	return &ArtifactDigest{Path: path, Sha256: hex.EncodeToString(h.Sum(nil)), Size: size}, nil
+}
+
+// ToolVersions are the versions of the tools that produced an artifact.
+type ToolVersions struct {
+	// Pico is the version of this module, or its vcs revision for development builds.
+	Pico           string `json:"pico"`
+	Gnark          string `json:"gnark"`
+	GnarkCrypto    string `json:"gnark_crypto"`
+	Go             string `json:"go"`
+	CircuitVersion int    `json:"circuit_version"`
+}
+
+// CurrentToolVersions returns the versions this binary is built with.
+func CurrentToolVersions() ToolVersions {
+	versions := ToolVersions{Pico: "unknown", Gnark: GnarkVersion(), GnarkCrypto: "unknown", Go: runtime.Version(), CircuitVersion: WitnessVersion}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return versions
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == "github.com/consensys/gnark-crypto" {
+			versions.GnarkCrypto = dep.Version
+		}
+	}
+	versions.Pico = info.Main.Version
+	revision, modified := "", false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision != "" && (versions.Pico == "" || versions.Pico == "(devel)") {
+		versions.Pico = revision
+		if modified {
+			versions.Pico += "-dirty"
+		}
+	}
+	return versions
+}
+
+// ProofManifest binds a proof to the witness and keys it was proved from and the
+// tools that proved it, so the artifacts can be audited and matched long after.
+type ProofManifest struct {
+	CreatedAt    time.Time       `json:"created_at"`
+	Curve        string          `json:"curve"`
+	ProofFormat  string          `json:"proof_format"`
+	HashToField  string          `json:"hash_to_field"`
+	PublicInputs []string        `json:"public_inputs"`
+	Witness      *ArtifactDigest `json:"witness"`
+	Vk           *ArtifactDigest `json:"vk"`
+	// Ccs is nil when the circuit was compiled for the proof rather than read.
+	Ccs       *ArtifactDigest `json:"ccs,omitempty"`
+	Proof     *ArtifactDigest `json:"proof"`
+	Signature *ArtifactDigest `json:"signature,omitempty"`
+	Versions  ToolVersions    `json:"versions"`
+}
+
+// NewProofManifest hashes the artifacts of a proof, the optional ones when they exist:
+// the ccs, which may have been compiled instead, and the signature.
+func NewProofManifest(curve ecc.ID, witnessPath, vkPath, ccsPath, proofPath string, publicInputs []string) (*ProofManifest, error) {
+	m := &ProofManifest{
+		CreatedAt:    time.Now().UTC(),
+		Curve:        curve.String(),
+		ProofFormat:  os.Getenv("PROOF_FORMAT"),
+		HashToField:  HashToFieldName(),
+		PublicInputs: publicInputs,
+		Versions:     CurrentToolVersions(),
+	}
+	if m.ProofFormat == "" {
+		m.ProofFormat = ProofFormatLegacy
+	}
+	var err error
+	for _, artifact := range []struct {
+		path     string
+		digest   **ArtifactDigest
+		optional bool
+	}{
+		{witnessPath, &m.Witness, false},
+		{vkPath, &m.Vk, false},
+		{ccsPath, &m.Ccs, true},
+		{proofPath, &m.Proof, false},
+		{proofPath + SignatureExt, &m.Signature, true},
+	} {
+		if artifact.path == "" {
+			continue
+		}
+		*artifact.digest, err = DigestArtifact(artifact.path)
+		if artifact.optional && errors.Is(err, os.ErrNotExist) {
+			*artifact.digest, err = nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// WriteProofManifest writes m next to its proof file.
+func WriteProofManifest(m *ProofManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteArtifact(m.Proof.Path+ManifestExt, func(w io.Writer) error {
+		_, err := w.Write(append(data, '\n'))
+		return err
+	})
+}
+
+// ReadProofManifest reads the manifest at path.
+func ReadProofManifest(path string) (*ProofManifest, error) {
+	data, err := ReadArtifact(path)
+	if err != nil {
+		return nil, err
+	}
+	var m ProofManifest
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse manifest %s: %v", path, err)
+	}
+	if m.Proof == nil {
+		return nil, fmt.Errorf("manifest %s has no proof", path)
+	}
+	return &m, nil
+}
+
+// Check hashes the artifacts of m again, at their recorded paths, and returns the
+// description of every one that is missing or changed since.
+func (m *ProofManifest) Check() []string {
+	var mismatches []string
+	for _, artifact := range []struct {
+		name   string
+		digest *ArtifactDigest
+	}{{"witness", m.Witness}, {"vk", m.Vk}, {"ccs", m.Ccs}, {"proof", m.Proof}, {"signature", m.Signature}} {
+		if artifact.digest == nil {
+			continue
+		}
+		actual, err := DigestArtifact(artifact.digest.Path)
+		if err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("%s %s: %v", artifact.name, artifact.digest.Path, err))
+			continue
+		}
+		if actual.Sha256 != artifact.digest.Sha256 {
+			mismatches = append(mismatches, fmt.Sprintf("%s %s: sha256 %s, manifest has %s", artifact.name, artifact.digest.Path, actual.Sha256, artifact.digest.Sha256))
+		}
+	}
+	return mismatches
+}
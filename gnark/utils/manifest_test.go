@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/test"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProofManifest(t *testing.T) {
+	assert := test.NewAssert(t)
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+	for _, name := range []string{"witness.json", "vm_vk", "proof.data"} {
+		assert.NoError(os.WriteFile(path(name), []byte(name), 0644))
+	}
+
+	// the ccs may have been compiled rather than read, the signature is optional
+	m, err := NewProofManifest(ecc.BN254, path("witness.json"), path("vm_vk"), path("vm_ccs"), path("proof.data"), []string{"1", "2"})
+	assert.NoError(err)
+	assert.Nil(m.Ccs)
+	assert.Nil(m.Signature)
+	assert.Equal(int64(len("proof.data")), m.Proof.Size)
+	assert.Equal(HashToFieldKeccak256, m.HashToField)
+	assert.NoError(WriteProofManifest(m))
+
+	read, err := ReadProofManifest(path("proof.data") + ManifestExt)
+	assert.NoError(err)
+	assert.Equal(m.Proof, read.Proof)
+	assert.Empty(read.Check())
+
+	assert.NoError(os.WriteFile(path("proof.data"), []byte("other proof"), 0644))
+	assert.NoError(os.Remove(path("vm_vk")))
+	assert.Len(read.Check(), 2)
+
+	_, err = NewProofManifest(ecc.BN254, path("witness.json"), path("vm_vk"), "", path("proof.data"), nil)
+	assert.Error(err, "the vk is required")
+}
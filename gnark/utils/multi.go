@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+)
+
+// Validate checks that the chunks can be wrapped together: they must prove the same
+// program (vkey hash), or with AllowedVkeys one of the allowed programs each, with the
+// same fri config and witness shape, and no chunk may appear twice. The circuit binds
+// every chunk to the shared vkey hash public input, or to the root of the allowed
+// vkeys, and publishes the committed values digests in chunk order; it does not link
+// the executions of consecutive chunks, which the constraints do not expose.
+func (m *MultiWitnessInput) Validate() error {
+	if len(m.Chunks) == 0 {
+		return fmt.Errorf("witness has no chunks")
+	}
+	allowed, err := m.AllowedVkeyHashes()
+	if err != nil {
+		return err
+	}
+	first := &m.Chunks[0]
+	vkeyHash, ok := new(big.Int).SetString(first.VkeyHash, 0)
+	if !ok {
+		return fmt.Errorf("invalid vkey hash: %q", first.VkeyHash)
+	}
+	if m.Aggregated() && VkeyIndex(allowed, vkeyHash) < 0 {
+		return fmt.Errorf("chunk 0 has vkey hash %s, not an allowed vkey", first.VkeyHash)
+	}
+	for i := 1; i < len(m.Chunks); i++ {
+		chunk := &m.Chunks[i]
+		hash, ok := new(big.Int).SetString(chunk.VkeyHash, 0)
+		switch {
+		case !ok:
+			return fmt.Errorf("invalid vkey hash of chunk %d: %q", i, chunk.VkeyHash)
+		case m.Aggregated() && VkeyIndex(allowed, hash) < 0:
+			return fmt.Errorf("chunk %d has vkey hash %s, not an allowed vkey", i, chunk.VkeyHash)
+		case !m.Aggregated() && hash.Cmp(vkeyHash) != 0:
+			return fmt.Errorf("chunk %d has vkey hash %s, expected %s", i, chunk.VkeyHash, first.VkeyHash)
+		}
+		if chunk.FormatVersion() != first.FormatVersion() {
+			return fmt.Errorf("chunk %d has witness version %d, chunk 0 has %d", i, chunk.FormatVersion(), first.FormatVersion())
+		}
+		if !reflect.DeepEqual(chunk.FriConfig, first.FriConfig) {
+			return fmt.Errorf("chunk %d has another fri config than chunk 0", i)
+		}
+		if len(chunk.Vars) != len(first.Vars) || len(chunk.Felts) != len(first.Felts) || len(chunk.Exts) != len(first.Exts) {
+			return fmt.Errorf("chunk %d has %d vars, %d felts and %d exts, chunk 0 has %d, %d and %d", i,
+				len(chunk.Vars), len(chunk.Felts), len(chunk.Exts), len(first.Vars), len(first.Felts), len(first.Exts))
+		}
+		for j := 0; j < i; j++ {
+			if reflect.DeepEqual(*chunk, m.Chunks[j]) {
+				return fmt.Errorf("chunk %d is a duplicate of chunk %d", i, j)
+			}
+		}
+	}
+	return nil
+}
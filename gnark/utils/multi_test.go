@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMultiWitnessValidate(t *testing.T) {
+	chunks := func() []WitnessInput {
+		return []WitnessInput{
+			{Vars: VarArray{"7", "100"}, VkeyHash: "7", CommittedValuesDigest: "100"},
+			{Vars: VarArray{"7", "200"}, VkeyHash: "0x7", CommittedValuesDigest: "200"},
+		}
+	}
+	for _, c := range []struct {
+		name  string
+		edit  func(chunks []WitnessInput) []WitnessInput
+		error string
+	}{
+		{"valid", func(chunks []WitnessInput) []WitnessInput { return chunks }, ""},
+		{"empty", func([]WitnessInput) []WitnessInput { return nil }, "no chunks"},
+		{"vkey hash", func(chunks []WitnessInput) []WitnessInput {
+			chunks[1].VkeyHash = "8"
+			return chunks
+		}, "vkey hash"},
+		{"fri config", func(chunks []WitnessInput) []WitnessInput {
+			chunks[1].FriConfig = &FriConfig{LogBlowup: 1, NumQueries: 100}
+			return chunks
+		}, "fri config"},
+		{"shape", func(chunks []WitnessInput) []WitnessInput {
+			chunks[1].Vars = append(chunks[1].Vars, "1")
+			return chunks
+		}, "vars"},
+		{"duplicate", func(chunks []WitnessInput) []WitnessInput {
+			return append(chunks, chunks[0])
+		}, "duplicate of chunk 0"},
+	} {
+		multi := MultiWitnessInput{Chunks: c.edit(chunks())}
+		err := multi.Validate()
+		if c.error == "" && err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if c.error != "" && (err == nil || !strings.Contains(err.Error(), c.error)) {
+			t.Fatalf("%s: error %v, want %q", c.name, err, c.error)
+		}
+	}
+}
+
+func TestAggregatedWitnessValidate(t *testing.T) {
+	chunks := []WitnessInput{
+		{Vars: VarArray{"7", "100"}, VkeyHash: "7", CommittedValuesDigest: "100"},
+		{Vars: VarArray{"8", "200"}, VkeyHash: "0x8", CommittedValuesDigest: "200"},
+	}
+	for _, c := range []struct {
+		name    string
+		allowed []string
+		error   string
+	}{
+		{"valid", []string{"0x8", "9", "7"}, ""},
+		{"not allowed", []string{"7", "9"}, "chunk 1 has vkey hash 0x8, not an allowed vkey"},
+		{"first not allowed", []string{"8"}, "chunk 0"},
+		{"invalid", []string{"7", "8", "x"}, "invalid allowed vkey hash"},
+		{"duplicate", []string{"7", "8", "0x7"}, "allowed vkey 2 is a duplicate of allowed vkey 0"},
+	} {
+		multi := MultiWitnessInput{Chunks: chunks, AllowedVkeys: c.allowed}
+		err := multi.Validate()
+		if c.error == "" && err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if c.error != "" && (err == nil || !strings.Contains(err.Error(), c.error)) {
+			t.Fatalf("%s: error %v, want %q", c.name, err, c.error)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
+	"math/big"
+	"strings"
+)
+
+// OnChainProof is a wrapper proof as the verifier contract takes it: the affine
+// coordinates of its points, with the imaginary parts of B first, and its public
+// inputs.
+type OnChainProof struct {
+	A [2]*big.Int
+	B [2][2]*big.Int
+	C [2]*big.Int
+	// Commitment and CommitmentPok are nil when the circuit has no commitment.
+	Commitment    [2]*big.Int
+	CommitmentPok [2]*big.Int
+	PublicInputs  []*big.Int
+}
+
+// GetAggOnChainProof collects the proof points and public inputs of a wrapper proof.
+func GetAggOnChainProof(proof groth16.Proof, pubWitness witness.Witness) (*OnChainProof, error) {
+	a, b, c, commitment, commitmentPok, err := ExportProof(proof)
+	if err != nil {
+		return nil, err
+	}
+	pubInputs, err := PublicInputs(pubWitness)
+	if err != nil {
+		return nil, err
+	}
+	return &OnChainProof{A: a, B: b, C: c, Commitment: commitment, CommitmentPok: commitmentPok, PublicInputs: pubInputs}, nil
+}
+
+// HasCommitment reports whether the proof carries a commitment.
+func (p *OnChainProof) HasCommitment() bool {
+	return p.Commitment[0] != nil
+}
+
+// Words returns the 32 byte words of the proof points, then of the commitment and its
+// proof of knowledge if the proof has one, in the order of verifyProof.
+func (p *OnChainProof) Words() []*big.Int {
+	words := []*big.Int{p.A[0], p.A[1], p.B[0][0], p.B[0][1], p.B[1][0], p.B[1][1], p.C[0], p.C[1]}
+	if p.HasCommitment() {
+		words = append(words, p.Commitment[0], p.Commitment[1], p.CommitmentPok[0], p.CommitmentPok[1])
+	}
+	return words
+}
+
+// ABIEncode returns the abi encoded arguments of the verifyProof overload of the
+// proof, without selector. All arguments are fixed size arrays, so they are encoded in
+// place as 32 byte words.
+func (p *OnChainProof) ABIEncode() []byte {
+	words := append(p.Words(), p.PublicInputs...)
+	data := make([]byte, 32*len(words))
+	for i, w := range words {
+		w.FillBytes(data[32*i : 32*(i+1)])
+	}
+	return data
+}
+
+// Legacy renders the legacy proof string read by the Rust sdk: the 8 proof words as
+// 0x prefixed hex without leading zero bytes, then the public inputs as 32 byte hex
+// words, comma separated. The format is fixed; the commitment of circuits using
+// lookup range checks is left out, it is only carried by the json proof and the
+// calldata: callers writing a proof with HasCommitment in this format should warn.
+func (p *OnChainProof) Legacy() string {
+	var res []string
+	for _, w := range []*big.Int{p.A[0], p.A[1], p.B[0][0], p.B[0][1], p.B[1][0], p.B[1][1], p.C[0], p.C[1]} {
+		res = append(res, Encode(w.Bytes()))
+	}
+	for _, v := range p.PublicInputs {
+		res = append(res, encodeFixed(v, 32))
+	}
+	return strings.Join(res, ",")
+}
+
+// onChainProofJson is the json of OnChainProof, 0x prefixed 32 byte hex words.
+type onChainProofJson struct {
+	A             [2]string    `json:"a"`
+	B             [2][2]string `json:"b"`
+	C             [2]string    `json:"c"`
+	Commitment    *[2]string   `json:"commitment,omitempty"`
+	CommitmentPok *[2]string   `json:"commitment_pok,omitempty"`
+	PublicInputs  []string     `json:"public_inputs"`
+}
+
+func (p *OnChainProof) MarshalJSON() ([]byte, error) {
+	var res onChainProofJson
+	for i := 0; i < 2; i++ {
+		res.A[i] = encodeFixed(p.A[i], coordinateSize)
+		res.C[i] = encodeFixed(p.C[i], coordinateSize)
+		for j := 0; j < 2; j++ {
+			res.B[i][j] = encodeFixed(p.B[i][j], coordinateSize)
+		}
+	}
+	if p.HasCommitment() {
+		res.Commitment = &[2]string{encodeFixed(p.Commitment[0], coordinateSize), encodeFixed(p.Commitment[1], coordinateSize)}
+		res.CommitmentPok = &[2]string{encodeFixed(p.CommitmentPok[0], coordinateSize), encodeFixed(p.CommitmentPok[1], coordinateSize)}
+	}
+	res.PublicInputs = make([]string, len(p.PublicInputs))
+	for i, v := range p.PublicInputs {
+		res.PublicInputs[i] = encodeFixed(v, 32)
+	}
+	return json.Marshal(res)
+}
+
+func (p *OnChainProof) UnmarshalJSON(data []byte) error {
+	var res onChainProofJson
+	err := json.Unmarshal(data, &res)
+	if err != nil {
+		return err
+	}
+	words := []string{res.A[0], res.A[1], res.B[0][0], res.B[0][1], res.B[1][0], res.B[1][1], res.C[0], res.C[1]}
+	if res.Commitment != nil && res.CommitmentPok != nil {
+		words = append(words, res.Commitment[0], res.Commitment[1], res.CommitmentPok[0], res.CommitmentPok[1])
+	}
+	values := make([]*big.Int, len(words)+len(res.PublicInputs))
+	for i, w := range append(words, res.PublicInputs...) {
+		v, ok := new(big.Int).SetString(strings.TrimPrefix(w, "0x"), 16)
+		if !ok {
+			return fmt.Errorf("invalid proof word %d: %q", i, w)
+		}
+		values[i] = v
+	}
+	*p = OnChainProof{
+		A:            [2]*big.Int{values[0], values[1]},
+		B:            [2][2]*big.Int{{values[2], values[3]}, {values[4], values[5]}},
+		C:            [2]*big.Int{values[6], values[7]},
+		PublicInputs: values[len(words):],
+	}
+	if len(words) == 12 {
+		p.Commitment = [2]*big.Int{values[8], values[9]}
+		p.CommitmentPok = [2]*big.Int{values[10], values[11]}
+	}
+	return nil
+}
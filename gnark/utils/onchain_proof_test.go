@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"encoding/json"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark/backend/groth16"
+	groth16_bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"math/big"
+	"testing"
+)
+
+func TestOnChainProof(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	for _, circuit := range []frontend.Circuit{&wrapperShapeCircuit{}, &rangeCheckedCircuit{}} {
+		ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
+		assert.NoError(err)
+		pk, _, err := groth16.Setup(ccs)
+		assert.NoError(err)
+		var assignment frontend.Circuit = &wrapperShapeCircuit{VkeyHash: 3, CommittedValuesDigest: 21, X: 7}
+		if _, ok := circuit.(*rangeCheckedCircuit); ok {
+			assignment = &rangeCheckedCircuit{VkeyHash: 3, CommittedValuesDigest: 21, X: 7}
+		}
+		fullWitness, err := frontend.NewWitness(assignment, ecc.BN254.ScalarField())
+		assert.NoError(err)
+		pubWitness, err := fullWitness.Public()
+		assert.NoError(err)
+		proof, err := groth16.Prove(ccs, pk, fullWitness)
+		assert.NoError(err)
+
+		p, err := GetAggOnChainProof(proof, pubWitness)
+		assert.NoError(err)
+		words := 8
+		if p.HasCommitment() {
+			words = 12
+		}
+		assert.Len(p.Words(), words)
+		data := p.ABIEncode()
+		assert.Len(data, 32*(words+2))
+		assert.Equal(0, new(big.Int).SetBytes(data[32*(words+1):]).Cmp(big.NewInt(21)))
+
+		encoded, err := json.Marshal(p)
+		assert.NoError(err)
+		var decoded OnChainProof
+		assert.NoError(json.Unmarshal(encoded, &decoded))
+		assert.Equal(p.ABIEncode(), decoded.ABIEncode())
+		assert.Equal(p.HasCommitment(), decoded.HasCommitment())
+		assert.Equal(p.Legacy(), decoded.Legacy())
+	}
+}
+
+// baselineLegacyProof is the proof string GetAggOnChainProof returned before the
+// OnChainProof type, for the proof of TestLegacyProofBaseline: proof words without
+// leading zero bytes, public inputs padded to 32 bytes.
+const baselineLegacyProof = "0x01,0x02," +
+	"0x198e9393920d483a7260bfb731fb5d25f1aa493335a9e71297e485b7aef312c2," +
+	"0x1800deef121f1e76426a00665e5c4479674322d4f75edadd46debd5cd992f6ed," +
+	"0x090689d0585ff075ec9e99ad690c3395bc4b313370b38ef355acdadcd122975b," +
+	"0x12c85ea5db8c6deb4aab71808dcb408fe3d1e7690c43d37b4ce6cc0166fa7daa," +
+	"0x17c139df0efee0f766bc0204762b774362e4ded88953a39ce849a8a7fa163fa9," +
+	"0x01e0559bacb160664764a357af8a9fe70baa9258e0b959273ffc5718c6d4cc7c," +
+	"0x0000000000000000000000000000000000000000000000000000000000000003," +
+	"0x0000000000000000000000000000000000000000000000000000000000000015"
+
+func TestLegacyProofBaseline(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	_, _, g1, g2 := bn254.Generators()
+	var krs bn254.G1Affine
+	krs.ScalarMultiplication(&g1, big.NewInt(5))
+	proof := &groth16_bn254.Proof{Ar: g1, Bs: g2, Krs: krs}
+	fullWitness, err := frontend.NewWitness(&wrapperShapeCircuit{VkeyHash: 3, CommittedValuesDigest: 21, X: 7}, ecc.BN254.ScalarField())
+	assert.NoError(err)
+	pubWitness, err := fullWitness.Public()
+	assert.NoError(err)
+
+	p, err := GetAggOnChainProof(proof, pubWitness)
+	assert.NoError(err)
+	assert.Equal(baselineLegacyProof, p.Legacy())
+}
+
+func TestOnChainProofCurve(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	_, err := GetAggOnChainProof(groth16.NewProof(ecc.BLS12_381), nil)
+	assert.ErrorContains(err, "expected a bn254 proof")
+}
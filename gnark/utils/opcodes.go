@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// opcodeArgs is the number of argument lists of each opcode of constraints.json.
+var opcodeArgs = map[string]int{
+	"ImmV": 2, "ImmF": 2, "ImmE": 2,
+	"AddV": 3, "AddF": 3, "AddE": 3, "AddEF": 3,
+	"SubV": 3, "SubF": 3, "SubE": 3, "SubEF": 3,
+	"MulV": 3, "MulF": 3, "MulE": 3, "MulEF": 3,
+	"DivF": 3, "DivE": 3, "DivEF": 3,
+	"NegE": 2, "InvE": 2,
+	"Num2BitsV": 3, "Num2BitsF": 2,
+	"Permute": 3, "PermuteKoalaBear": 16, "PermuteBabyBear": 16,
+	"SelectV": 4, "SelectF": 4, "SelectE": 4,
+	"AssertEqV": 2, "AssertEqF": 2, "AssertNeF": 2, "AssertEqE": 2,
+	"PrintV": 1, "PrintF": 1, "PrintE": 1,
+	"WitnessV": 2, "WitnessF": 2, "WitnessE": 2,
+	"CommitVkeyHash": 1, "CommitCommitedValuesDigest": 1,
+	"Ext2Felt": 5, "CircuitFelts2Ext": 5, "CircuitFelt2Var": 2,
+	"ReduceE": 1,
+}
+
+const (
+	// maxNum2BitsV bounds the bits of Num2BitsV, the size of a var.
+	maxNum2BitsV = 256
+	// feltBits is the number of bits Num2BitsF decomposes a felt into.
+	feltBits = 31
+)
+
+// Validate checks the shape of every constraint of a known opcode, so a malformed
+// file fails to load instead of panicking while the circuit is compiled. Unknown
+// opcodes are left to the verifier, which rejects those it does not handle.
+func (f *ConstraintsFile) Validate() error {
+	for i, cs := range f.Constraints {
+		err := cs.validate()
+		if err != nil {
+			return fmt.Errorf("constraint %d (%s): %v", i, cs.Opcode, err)
+		}
+	}
+	return nil
+}
+
+func (cs *Constraint) validate() error {
+	n, ok := opcodeArgs[cs.Opcode]
+	if !ok {
+		return nil
+	}
+	if len(cs.Args) != n {
+		return fmt.Errorf("has %d arguments, expected %d", len(cs.Args), n)
+	}
+	for i, arg := range cs.Args {
+		if len(arg) == 0 {
+			return fmt.Errorf("argument %d is empty", i)
+		}
+	}
+
+	switch cs.Opcode {
+	case "ImmV":
+		if _, ok := new(big.Int).SetString(cs.Args[1][0], 10); !ok {
+			return fmt.Errorf("invalid var %q", cs.Args[1][0])
+		}
+	case "ImmF":
+		return validateFelts(cs.Args[1][:1])
+	case "ImmE":
+		if len(cs.Args[1]) != 4 {
+			return fmt.Errorf("extension element has %d felts, expected 4", len(cs.Args[1]))
+		}
+		return validateFelts(cs.Args[1])
+	case "Num2BitsV":
+		bits, err := strconv.Atoi(cs.Args[2][0])
+		if err != nil || bits < len(cs.Args[0]) || bits > maxNum2BitsV {
+			return fmt.Errorf("invalid number of bits %q for %d outputs", cs.Args[2][0], len(cs.Args[0]))
+		}
+	case "Num2BitsF":
+		if len(cs.Args[0]) > feltBits {
+			return fmt.Errorf("has %d outputs, a felt has %d bits", len(cs.Args[0]), feltBits)
+		}
+	case "WitnessV", "WitnessF", "WitnessE":
+		if _, err := strconv.ParseUint(cs.Args[1][0], 10, 31); err != nil {
+			return fmt.Errorf("invalid witness index %q", cs.Args[1][0])
+		}
+	}
+	return nil
+}
+
+func validateFelts(felts []string) error {
+	for _, felt := range felts {
+		if _, err := strconv.ParseUint(felt, 10, 32); err != nil {
+			return fmt.Errorf("invalid felt %q", felt)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"github.com/consensys/gnark/test"
+	"testing"
+)
+
+func TestParseConstraintsValidates(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	_, err := ParseConstraints([]byte(`[{"opcode": "Unknown", "args": []}, {"opcode": "ImmE", "args": [["e"], ["1", "2", "3", "4"]]}]`))
+	assert.NoError(err)
+
+	for data, message := range map[string]string{
+		`[{"opcode": "AddF", "args": [["a"], ["b"]]}]`:                           "constraint 0 (AddF): has 2 arguments, expected 3",
+		`[{"opcode": "AssertEqV", "args": [["a"], []]}]`:                         "argument 1 is empty",
+		`[{"opcode": "ImmV", "args": [["a"], ["0x1"]]}]`:                         `invalid var "0x1"`,
+		`[{"opcode": "ImmE", "args": [["e"], ["1", "2"]]}]`:                      "extension element has 2 felts, expected 4",
+		`[{"opcode": "Num2BitsV", "args": [["b"], ["v"], ["1000000000"]]}]`:      `invalid number of bits "1000000000"`,
+		`[{"opcode": "Num2BitsV", "args": [["b0", "b1", "b2"], ["v"], ["2"]]}]`:  "invalid number of bits \"2\" for 3 outputs",
+		`[{"opcode": "WitnessF", "args": [["f"], ["-1"]]}]`:                      `invalid witness index "-1"`,
+		`{"constraints": [{"opcode": "ImmF", "args": [["f"], ["4294967296"]]}]}`: `invalid felt "4294967296"`,
+	} {
+		_, err := ParseConstraints([]byte(data))
+		assert.ErrorContains(err, message, data)
+	}
+
+	_, _, err = ParseWitness([]byte(`{"felts": ["x"], "vkey_hash": "7", "committed_values_digest": "9"}`))
+	assert.ErrorContains(err, `invalid felt 0: "x"`)
+	_, _, err = ParseWitness([]byte(`{"exts": [["1", "2", "3", "4"], ["1"]], "vkey_hash": "7", "committed_values_digest": "9"}`))
+	assert.ErrorContains(err, "ext 1 has 1 felts, expected 4")
+	_, _, err = ParseWitness([]byte(`{"chunks": [{"vars": ["-1"], "vkey_hash": "7", "committed_values_digest": "9"}]}`))
+	assert.ErrorContains(err, `chunk 0: invalid var 0: "-1"`)
+	_, _, err = ParseWitness([]byte(`{"vkey_hash": "seven", "committed_values_digest": "9"}`))
+	assert.ErrorContains(err, `invalid vkey hash: "seven"`)
+}
@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"sync"
+)
+
+// Witness arrays are either JSON arrays of decimal strings or a single hex string
+// packing all elements big-endian: 4 bytes per felt, 16 bytes per extension element
+// and 32 bytes per var.
+const (
+	packedFeltSize = 4
+	packedExtSize  = 4 * packedFeltSize
+	packedVarSize  = 32
+)
+
+// FeltArray holds the felts of a witness.
+type FeltArray []string
+
+// ExtArray holds the extension elements of a witness, 4 felts each.
+type ExtArray [][]string
+
+// VarArray holds the native field vars of a witness.
+type VarArray []string
+
+func (a *FeltArray) UnmarshalJSON(data []byte) error {
+	ok, err := unpackHex(data, packedFeltSize, func(packed []byte) {
+		*a = make(FeltArray, len(packed)/packedFeltSize)
+		unpackFelts(packed, *a)
+	})
+	if err == nil && !ok {
+		err = json.Unmarshal(data, (*[]string)(a))
+	}
+	return err
+}
+
+func (a *ExtArray) UnmarshalJSON(data []byte) error {
+	ok, err := unpackHex(data, packedExtSize, func(packed []byte) {
+		*a = make(ExtArray, len(packed)/packedExtSize)
+		// one backing array for the felts of every element
+		felts := make([]string, 4*len(*a))
+		unpackFelts(packed, felts)
+		for i := range *a {
+			(*a)[i] = felts[4*i : 4*i+4 : 4*i+4]
+		}
+	})
+	if err == nil && !ok {
+		err = json.Unmarshal(data, (*[][]string)(a))
+	}
+	return err
+}
+
+func (a *VarArray) UnmarshalJSON(data []byte) error {
+	ok, err := unpackHex(data, packedVarSize, func(packed []byte) {
+		*a = make(VarArray, len(packed)/packedVarSize)
+		var v big.Int
+		splitDecimals(*a, 78, func(i int, digits []byte) []byte {
+			return v.SetBytes(packed[i*packedVarSize:(i+1)*packedVarSize]).Append(digits, 10)
+		})
+	})
+	if err == nil && !ok {
+		err = json.Unmarshal(data, (*[]string)(a))
+	}
+	return err
+}
+
+// PackFelts encodes felts as a packed hex string.
+func PackFelts(felts []string) (string, error) {
+	packed := make([]byte, 0, len(felts)*packedFeltSize)
+	for _, felt := range felts {
+		v, err := strconv.ParseUint(felt, 10, 32)
+		if err != nil {
+			return "", fmt.Errorf("invalid felt %q: %v", felt, err)
+		}
+		packed = binary.BigEndian.AppendUint32(packed, uint32(v))
+	}
+	return Encode(packed), nil
+}
+
+// PackExts encodes extension elements as a packed hex string.
+func PackExts(exts [][]string) (string, error) {
+	var felts []string
+	for _, ext := range exts {
+		if len(ext) != 4 {
+			return "", fmt.Errorf("extension element has %d felts, expected 4", len(ext))
+		}
+		felts = append(felts, ext...)
+	}
+	return PackFelts(felts)
+}
+
+// PackVars encodes vars as a packed hex string.
+func PackVars(vars []string) (string, error) {
+	packed := make([]byte, len(vars)*packedVarSize)
+	for i, v := range vars {
+		n, ok := new(big.Int).SetString(v, 10)
+		if !ok || n.Sign() < 0 || n.BitLen() > 8*packedVarSize {
+			return "", fmt.Errorf("invalid var %q", v)
+		}
+		n.FillBytes(packed[i*packedVarSize : (i+1)*packedVarSize])
+	}
+	return Encode(packed), nil
+}
+
+// packedBuffers recycles the buffers packed arrays are decoded into, a server parses
+// a witness of several MB for every proof.
+var packedBuffers = sync.Pool{New: func() any { return new([]byte) }}
+
+// unpackHex decodes data into a buffer passed to unpack if it is a JSON string, ok
+// is false for any other JSON value. The buffer is reused after unpack returns.
+func unpackHex(data []byte, size int, unpack func(packed []byte)) (ok bool, err error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '"' {
+		return false, nil
+	}
+	var digits []byte
+	if len(data) >= 2 && data[len(data)-1] == '"' && bytes.IndexByte(data[1:len(data)-1], '\\') < 0 {
+		digits = data[1 : len(data)-1]
+	} else {
+		// hex digits are never escaped, let encoding/json decode or reject the string
+		var s string
+		err = json.Unmarshal(data, &s)
+		if err != nil {
+			return false, err
+		}
+		digits = []byte(s)
+	}
+	digits = bytes.TrimPrefix(digits, []byte("0x"))
+
+	buf := packedBuffers.Get().(*[]byte)
+	defer packedBuffers.Put(buf)
+	if cap(*buf) < hex.DecodedLen(len(digits)) {
+		*buf = make([]byte, hex.DecodedLen(len(digits)))
+	}
+	packed := (*buf)[:hex.DecodedLen(len(digits))]
+	_, err = hex.Decode(packed, digits)
+	if err != nil {
+		return false, fmt.Errorf("invalid packed array: %v", err)
+	}
+	if len(packed)%size != 0 {
+		return false, fmt.Errorf("packed array of %d bytes is not a multiple of %d", len(packed), size)
+	}
+	unpack(packed)
+	return true, nil
+}
+
+// unpackFelts formats the packed felts as decimal strings into felts.
+func unpackFelts(packed []byte, felts []string) {
+	splitDecimals(felts, 10, func(i int, digits []byte) []byte {
+		return strconv.AppendUint(digits, uint64(binary.BigEndian.Uint32(packed[i*packedFeltSize:])), 10)
+	})
+}
+
+// splitDecimals sets values[i] to the at most maxDigits digits appended by format(i).
+// The values share one allocation, instead of one per value.
+func splitDecimals(values []string, maxDigits int, format func(i int, digits []byte) []byte) {
+	ends := make([]int, len(values))
+	digits := make([]byte, 0, len(values)*maxDigits)
+	for i := range values {
+		digits = format(i, digits)
+		ends[i] = len(digits)
+	}
+	all := string(digits)
+	start := 0
+	for i, end := range ends {
+		values[i] = all[start:end]
+		start = end
+	}
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"encoding/json"
+	"github.com/consensys/gnark/test"
+	"testing"
+)
+
+func TestPackedWitness(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	plain := WitnessInput{
+		Vars:  VarArray{"0", "21888242871839275222246405745257275088548364400416034343698204186575808495616"},
+		Felts: FeltArray{"1", "2130706432", "65536"},
+		Exts:  ExtArray{{"1", "2", "3", "4"}, {"0", "0", "0", "2130706432"}},
+	}
+	vars, err := PackVars(plain.Vars)
+	assert.NoError(err)
+	felts, err := PackFelts(plain.Felts)
+	assert.NoError(err)
+	assert.Equal("0x000000017f00000000010000", felts)
+	exts, err := PackExts(plain.Exts)
+	assert.NoError(err)
+
+	data, err := json.Marshal(map[string]string{"vars": vars, "felts": felts, "exts": exts})
+	assert.NoError(err)
+	single, _, err := ParseWitness(data)
+	assert.NoError(err)
+	assert.Equal(plain.Vars, single.Vars)
+	assert.Equal(plain.Felts, single.Felts)
+	assert.Equal(plain.Exts, single.Exts)
+
+	data, err = json.Marshal(plain)
+	assert.NoError(err)
+	single, _, err = ParseWitness(data)
+	assert.NoError(err)
+	assert.Equal(plain.Felts, single.Felts)
+	assert.Equal(plain.Exts, single.Exts)
+
+	// an escaped string is still decoded
+	single, _, err = ParseWitness([]byte(`{"felts": "\u0030x000000017f00000000010000"}`))
+	assert.NoError(err)
+	assert.Equal(plain.Felts, single.Felts)
+
+	_, _, err = ParseWitness([]byte(`{"felts": "0x0001"}`))
+	assert.Error(err)
+	_, _, err = ParseWitness([]byte(`{"felts": "0x0g000000"}`))
+	assert.Error(err)
+}
+
+// BenchmarkParsePackedWitness parses a packed witness of the size of a pico proof.
+func BenchmarkParsePackedWitness(b *testing.B) {
+	felts := make([]string, 200000)
+	for i := range felts {
+		felts[i] = "2130706432"
+	}
+	packedFelts, err := PackFelts(felts)
+	if err != nil {
+		b.Fatal(err)
+	}
+	packedExts, err := PackFelts(felts)
+	if err != nil {
+		b.Fatal(err)
+	}
+	packedVars, err := PackVars(felts[:1000])
+	if err != nil {
+		b.Fatal(err)
+	}
+	data, err := json.Marshal(map[string]string{"vars": packedVars, "felts": packedFelts, "exts": packedExts, "vkey_hash": "1", "committed_values_digest": "2"})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _, err = ParseWitness(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
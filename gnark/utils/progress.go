@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval is how often a slow read reports its progress.
+var progressInterval = 10 * time.Second
+
+// progressReader counts the bytes read through it, so a long key load can report
+// throughput and an ETA instead of looking hung.
+type progressReader struct {
+	r    io.Reader
+	read atomic.Int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read.Add(int64(n))
+	return n, err
+}
+
+// withProgress reports the progress of reading size bytes (-1 if unknown) of name
+// from r every progressInterval until the returned stop is called. Reads finishing
+// within the first interval print nothing.
+func withProgress(name string, r io.Reader, size int64) (io.Reader, func()) {
+	p := &progressReader{r: r}
+	start := time.Now()
+	done := make(chan struct{})
+	reported := make(chan bool, 1)
+	go func() {
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		logged := false
+		for {
+			select {
+			case <-ticker.C:
+				logged = true
+				fmt.Println(progressLine(name, p.read.Load(), size, time.Since(start)))
+			case <-done:
+				reported <- logged
+				return
+			}
+		}
+	}()
+	return p, func() {
+		close(done)
+		if <-reported {
+			elapsed := time.Since(start)
+			fmt.Printf("read %s: %d MB in %v (%.1f MB/s)\n", name, p.read.Load()>>20, elapsed.Round(time.Second), mbPerSecond(p.read.Load(), elapsed))
+		}
+	}
+}
+
+func progressLine(name string, read, size int64, elapsed time.Duration) string {
+	rate := mbPerSecond(read, elapsed)
+	if size <= 0 {
+		return fmt.Sprintf("reading %s: %d MB, %.1f MB/s", name, read>>20, rate)
+	}
+	line := fmt.Sprintf("reading %s: %d/%d MB (%.0f%%), %.1f MB/s", name, read>>20, size>>20, 100*float64(read)/float64(size), rate)
+	if read > 0 && read < size {
+		eta := time.Duration(float64(elapsed) * float64(size-read) / float64(read))
+		line += fmt.Sprintf(", eta %v", eta.Round(time.Second))
+	}
+	return line
+}
+
+func mbPerSecond(n int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(n) / (1 << 20) / elapsed.Seconds()
+}
+
+// sizedReadCloser carries the length of an object read over http.
+type sizedReadCloser struct {
+	io.ReadCloser
+	size int64
+}
+
+// artifactSize is the size of an artifact opened with OpenArtifact, -1 if unknown.
+func artifactSize(r io.ReadCloser) int64 {
+	switch r := r.(type) {
+	case *os.File:
+		info, err := r.Stat()
+		if err != nil {
+			return -1
+		}
+		return info.Size()
+	case sizedReadCloser:
+		return r.size
+	default:
+		return -1
+	}
+}
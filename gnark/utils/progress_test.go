@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"github.com/consensys/gnark/test"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressLine(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	line := progressLine("vm_pk", 1<<30, 4<<30, 10*time.Second)
+	assert.Equal("reading vm_pk: 1024/4096 MB (25%), 102.4 MB/s, eta 30s", line)
+	line = progressLine("s3://bucket/vm_pk", 1<<30, -1, 10*time.Second)
+	assert.Equal("reading s3://bucket/vm_pk: 1024 MB, 102.4 MB/s", line)
+}
+
+func TestProgressReader(t *testing.T) {
+	assert := test.NewAssert(t)
+	defer func(interval time.Duration) { progressInterval = interval }(progressInterval)
+	progressInterval = time.Millisecond
+
+	r, stop := withProgress("vm_pk", strings.NewReader("key data"), 8)
+	time.Sleep(5 * time.Millisecond)
+	data, err := io.ReadAll(r)
+	stop()
+	assert.NoError(err)
+	assert.Equal("key data", string(data))
+	assert.Equal(int64(8), r.(*progressReader).read.Load())
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
+	"math/big"
+)
+
+const (
+	ProofFormatLegacy = "legacy"
+	ProofFormatJson   = "json"
+	// ProofFormatGateway is the format of GatewayProof. Proof files in this format are
+	// not read back by the commands checking proofs.
+	ProofFormatGateway = "gateway"
+)
+
+// PicoProof is the structured proof document. Every value is a 0x-prefixed big-endian
+// hex string padded to 32 bytes.
+type PicoProof struct {
+	VkeyHash              string       `json:"vkey_hash"`
+	CommittedValuesDigest string       `json:"committed_values_digest"`
+	Proof                 Groth16Proof `json:"proof"`
+	PublicInputs          []string     `json:"public_inputs"`
+	// HashToField is the function the commitment is hashed to the field with, see
+	// NewHashToField.
+	HashToField string `json:"hash_to_field"`
+	// Signature is the signature of the prover, if it has a prover key.
+	Signature *ProofSignature `json:"signature,omitempty"`
+}
+
+// NewPicoProof collects the proof points and public inputs of a wrapper proof.
+func NewPicoProof(proof groth16.Proof, pubWitness witness.Witness) (*PicoProof, error) {
+	pubInputs, err := PublicInputs(pubWitness)
+	if err != nil {
+		return nil, err
+	}
+	if len(pubInputs) < 2 {
+		return nil, fmt.Errorf("expected at least 2 public inputs, got %d", len(pubInputs))
+	}
+
+	a, b, c, commitment, commitmentPok, err := ExportProof(proof)
+	if err != nil {
+		return nil, err
+	}
+	var res PicoProof
+	for i := 0; i < 2; i++ {
+		res.Proof.A[i] = encodeFixed(a[i], coordinateSize)
+		res.Proof.C[i] = encodeFixed(c[i], coordinateSize)
+		for j := 0; j < 2; j++ {
+			res.Proof.B[i][j] = encodeFixed(b[i][j], coordinateSize)
+		}
+		if commitment[i] != nil {
+			res.Proof.Commitment[i] = encodeFixed(commitment[i], coordinateSize)
+			res.Proof.CommitmentPok[i] = encodeFixed(commitmentPok[i], coordinateSize)
+		}
+	}
+
+	for i := 0; i < len(pubInputs); i++ {
+		res.PublicInputs = append(res.PublicInputs, encodeFixed(pubInputs[i], 32))
+	}
+	layout, err := PublicLayoutFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	vkeyHash, digest, err := layout.Unpack(pubInputs)
+	if err != nil {
+		return nil, err
+	}
+	res.VkeyHash = encodeFixed(vkeyHash, 32)
+	res.CommittedValuesDigest = encodeFixed(digest, 32)
+	res.HashToField = HashToFieldName()
+	return &res, nil
+}
+
+// FormatProof renders proof in the requested output format. The legacy format is the
+// comma separated hex list read by the Rust sdk. Json proofs are signed with the key
+// of ProverKeyFromEnv, if any, see FormatProofSignature for the other formats.
+func FormatProof(format string, proof groth16.Proof, pubWitness witness.Witness) ([]byte, error) {
+	switch format {
+	case "", ProofFormatLegacy:
+		res, err := GetAggOnChainProof(proof, pubWitness)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(res.Legacy()), nil
+	case ProofFormatJson:
+		res, err := NewPicoProof(proof, pubWitness)
+		if err != nil {
+			return nil, err
+		}
+		onChain, err := GetAggOnChainProof(proof, pubWitness)
+		if err != nil {
+			return nil, err
+		}
+		res.Signature, err = signProofFromEnv(onChain)
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(res, "", "  ")
+	case ProofFormatGateway:
+		return GatewayProof(proof, pubWitness)
+	default:
+		return nil, fmt.Errorf("unknown proof format: %s", format)
+	}
+}
+
+func encodeFixed(v *big.Int, size int) string {
+	data := make([]byte, size)
+	v.FillBytes(data)
+	return Encode(data)
+}
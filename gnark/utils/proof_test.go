@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"encoding/json"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	groth16_bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/std/rangecheck"
+	"github.com/consensys/gnark/test"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// wrapperShapeCircuit has the public inputs of the wrapper circuit.
+type wrapperShapeCircuit struct {
+	VkeyHash              frontend.Variable `gnark:",public"`
+	CommittedValuesDigest frontend.Variable `gnark:",public"`
+	X                     frontend.Variable
+}
+
+func (c *wrapperShapeCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(c.CommittedValuesDigest, api.Mul(c.VkeyHash, c.X))
+	return nil
+}
+
+func TestJsonProofRoundTrip(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &wrapperShapeCircuit{})
+	assert.NoError(err)
+	pk, vk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	fullWitness, err := frontend.NewWitness(&wrapperShapeCircuit{VkeyHash: 3, CommittedValuesDigest: 21, X: 7}, ecc.BN254.ScalarField())
+	assert.NoError(err)
+	pubWitness, err := fullWitness.Public()
+	assert.NoError(err)
+	proof, err := groth16.Prove(ccs, pk, fullWitness)
+	assert.NoError(err)
+
+	data, err := FormatProof(ProofFormatJson, proof, pubWitness)
+	assert.NoError(err)
+	var decoded PicoProof
+	assert.NoError(json.Unmarshal(data, &decoded))
+
+	pubInputs, err := PublicInputs(pubWitness)
+	assert.NoError(err)
+	assert.Equal(len(pubInputs), len(decoded.PublicInputs))
+	for i := range pubInputs {
+		assert.Len(decoded.PublicInputs[i], 66)
+		assert.Equal(0, parseHex(t, decoded.PublicInputs[i]).Cmp(pubInputs[i]))
+	}
+	assert.Equal(decoded.PublicInputs[0], decoded.VkeyHash)
+	assert.Equal(decoded.PublicInputs[1], decoded.CommittedValuesDigest)
+	assert.Equal(0, parseHex(t, decoded.VkeyHash).Cmp(big.NewInt(3)))
+	assert.Equal(0, parseHex(t, decoded.CommittedValuesDigest).Cmp(big.NewInt(21)))
+	assert.Equal("", decoded.Proof.Commitment[0], "no commitment without lookup range checks")
+
+	// the points decode to a proof that still verifies
+	var rebuilt groth16_bn254.Proof
+	rebuilt.Ar.X.SetBigInt(parseHex(t, decoded.Proof.A[0]))
+	rebuilt.Ar.Y.SetBigInt(parseHex(t, decoded.Proof.A[1]))
+	rebuilt.Bs.X.A1.SetBigInt(parseHex(t, decoded.Proof.B[0][0]))
+	rebuilt.Bs.X.A0.SetBigInt(parseHex(t, decoded.Proof.B[0][1]))
+	rebuilt.Bs.Y.A1.SetBigInt(parseHex(t, decoded.Proof.B[1][0]))
+	rebuilt.Bs.Y.A0.SetBigInt(parseHex(t, decoded.Proof.B[1][1]))
+	rebuilt.Krs.X.SetBigInt(parseHex(t, decoded.Proof.C[0]))
+	rebuilt.Krs.Y.SetBigInt(parseHex(t, decoded.Proof.C[1]))
+	assert.NoError(groth16.Verify(&rebuilt, vk, pubWitness))
+}
+
+func parseHex(t *testing.T, s string) *big.Int {
+	v, ok := new(big.Int).SetString(s, 0)
+	if !ok {
+		t.Fatalf("invalid hex %s", s)
+	}
+	return v
+}
+
+// rangeCheckedCircuit uses the lookup range checker, so its proofs carry a commitment.
+type rangeCheckedCircuit struct {
+	VkeyHash              frontend.Variable `gnark:",public"`
+	CommittedValuesDigest frontend.Variable `gnark:",public"`
+	X                     frontend.Variable
+}
+
+func (c *rangeCheckedCircuit) Define(api frontend.API) error {
+	rangecheck.New(api).Check(c.X, 8)
+	api.AssertIsEqual(c.CommittedValuesDigest, api.Mul(c.VkeyHash, c.X))
+	return nil
+}
+
+func TestLegacyProofFormat(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &rangeCheckedCircuit{})
+	assert.NoError(err)
+	pk, _, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	fullWitness, err := frontend.NewWitness(&rangeCheckedCircuit{VkeyHash: 3, CommittedValuesDigest: 21, X: 7}, ecc.BN254.ScalarField())
+	assert.NoError(err)
+	pubWitness, err := fullWitness.Public()
+	assert.NoError(err)
+	proof, err := groth16.Prove(ccs, pk, fullWitness)
+	assert.NoError(err)
+	assert.NotEmpty(proof.(*groth16_bn254.Proof).Commitments)
+
+	// 8 proof words and the 2 public inputs, whatever the proof carries besides
+	onChainProof, err := GetAggOnChainProof(proof, pubWitness)
+	assert.NoError(err)
+	words := strings.Split(onChainProof.Legacy(), ",")
+	assert.Len(words, 10)
+	for _, word := range words {
+		assert.Len(word, 66)
+	}
+	assert.Equal(0, parseHex(t, words[8]).Cmp(big.NewInt(3)))
+	assert.Equal(0, parseHex(t, words[9]).Cmp(big.NewInt(21)))
+}
+
+func TestGatewayProof(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &wrapperShapeCircuit{})
+	assert.NoError(err)
+	pk, _, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	fullWitness, err := frontend.NewWitness(&wrapperShapeCircuit{VkeyHash: 3, CommittedValuesDigest: 21, X: 7}, ecc.BN254.ScalarField())
+	assert.NoError(err)
+	pubWitness, err := fullWitness.Public()
+	assert.NoError(err)
+	proof, err := groth16.Prove(ccs, pk, fullWitness)
+	assert.NoError(err)
+
+	data, err := FormatProof(ProofFormatGateway, proof, pubWitness)
+	assert.NoError(err)
+	jsonData, err := FormatProof(ProofFormatJson, proof, pubWitness)
+	assert.NoError(err)
+	var decoded PicoProof
+	assert.NoError(json.Unmarshal(jsonData, &decoded))
+
+	// offset of the proof, vkey hash, digest, then the length and words of the proof
+	text := strings.TrimPrefix(string(data), "0x")
+	assert.Equal(64*(4+8), len(text))
+	word := func(i int) string {
+		return "0x" + text[64*i:64*(i+1)]
+	}
+	p := decoded.Proof
+	expected := []string{"", decoded.VkeyHash, decoded.CommittedValuesDigest, "", p.A[0], p.A[1], p.B[0][0], p.B[0][1], p.B[1][0], p.B[1][1], p.C[0], p.C[1]}
+	assert.Equal(0, parseHex(t, word(0)).Cmp(big.NewInt(96)))
+	assert.Equal(0, parseHex(t, word(3)).Cmp(big.NewInt(256)))
+	for i, w := range expected {
+		if w != "" {
+			assert.Equal(w, word(i), "word %d", i)
+		}
+	}
+}
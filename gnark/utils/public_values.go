@@ -0,0 +1,272 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/hash/sha2"
+	stdsha3 "github.com/consensys/gnark/std/hash/sha3"
+	"github.com/consensys/gnark/std/math/uints"
+	"golang.org/x/crypto/sha3"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// publicValueWordSize is the size of the words of the public values a layout exposes,
+// the words of their ABI encoding.
+const publicValueWordSize = 32
+
+// DecodePublicValues decodes the 0x prefixed hex public values of a witness.
+func DecodePublicValues(publicValues string) ([]byte, error) {
+	res, err := hex.DecodeString(strings.TrimPrefix(publicValues, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public values: %v", err)
+	}
+	return res, nil
+}
+
+// PublicValuesRoot is the root of the Merkle tree of the 32 byte words of publicValues,
+// padded with zero words to a power of two: a node is the sha256 of its two children
+// and the root of a single word is the word.
+func PublicValuesRoot(publicValues []byte) [32]byte {
+	levels := merkleLevels(publicValues)
+	return levels[len(levels)-1][0]
+}
+
+// merkleLevels returns the levels of the Merkle tree of PublicValuesRoot over the 32
+// byte words of data, from the padded words up to the root.
+func merkleLevels(data []byte) [][][32]byte {
+	nodes := make([][32]byte, nextPowerOfTwo(len(data)/publicValueWordSize))
+	for i := range nodes {
+		copy(nodes[i][:], data[min(i*publicValueWordSize, len(data)):])
+	}
+	levels := [][][32]byte{nodes}
+	for len(nodes) > 1 {
+		parents := make([][32]byte, len(nodes)/2)
+		for i := range parents {
+			parents[i] = sha256.Sum256(append(nodes[2*i][:], nodes[2*i+1][:]...))
+		}
+		levels = append(levels, parents)
+		nodes = parents
+	}
+	return levels
+}
+
+// CommittedValuesDigest returns the committed values digest of publicValues: their
+// sha256 with the 3 top bits dropped to fit the field, as the Rust prover commits them.
+func CommittedValuesDigest(publicValues []byte) *big.Int {
+	hash := sha256.Sum256(publicValues)
+	return hashToDigest(hash[:])
+}
+
+// Digest returns the digest the public inputs of l expose for publicValues, before its
+// truncation to DigestBits: the committed values digest, or with KeccakDigest the
+// keccak256 of publicValues with the 3 top bits dropped likewise.
+func (l PublicLayout) Digest(publicValues []byte) *big.Int {
+	if !l.KeccakDigest {
+		return CommittedValuesDigest(publicValues)
+	}
+	h := sha3.NewLegacyKeccak256()
+	h.Write(publicValues)
+	return hashToDigest(h.Sum(nil))
+}
+
+func hashToDigest(hash []byte) *big.Int {
+	hash[0] &= 0x1f
+	return new(big.Int).SetBytes(hash)
+}
+
+// PackPublicValues returns the public inputs of a wrapper committing to vkeyHash and
+// publicValues, as the Solidity library of l packs them: those of Pack for the digest
+// of publicValues, then those of the public values l exposes.
+func (l PublicLayout) PackPublicValues(vkeyHash *big.Int, publicValues []byte) ([]*big.Int, error) {
+	if l.PublicValues == 0 {
+		return nil, fmt.Errorf("public input layout %s exposes no public values", l)
+	}
+	if len(publicValues) != publicValueWordSize*l.PublicValues {
+		return nil, fmt.Errorf("public values are %d bytes, layout %s expects %d", len(publicValues), l, publicValueWordSize*l.PublicValues)
+	}
+	public := l.Pack(vkeyHash, l.Digest(publicValues))
+
+	words := publicValues
+	if l.PublicValuesRoot {
+		root := PublicValuesRoot(publicValues)
+		words = root[:]
+	}
+	for i := 0; i < len(words); i += publicValueWordSize {
+		public = append(public, new(big.Int).SetBytes(words[i:i+16]), new(big.Int).SetBytes(words[i+16:i+32]))
+	}
+	return public, nil
+}
+
+func (l PublicLayout) valuesString() string {
+	if l.PublicValuesRoot {
+		return fmt.Sprintf("%s=%d", PublicInputValuesRoot, l.PublicValues)
+	}
+	return fmt.Sprintf("%s=%d", PublicInputValuesWords, l.PublicValues)
+}
+
+// nbValueInputs is the number of public inputs of the public values.
+func (l PublicLayout) nbValueInputs() int {
+	switch {
+	case l.PublicValues == 0:
+		return 0
+	case l.PublicValuesRoot:
+		return 2
+	default:
+		return 2 * l.PublicValues
+	}
+}
+
+// digestHash is the Solidity function hashing the public values to the digest.
+func (l PublicLayout) digestHash() string {
+	if l.KeccakDigest {
+		return PublicInputDigestHashKeccak256
+	}
+	return PublicInputDigestHashSha256
+}
+
+// constrainPublicValues asserts that the bytes publicValues hash to
+// committedValuesDigest and that public holds the words l exposes, or their root. It
+// returns the digest l exposes, see Digest.
+func (l PublicLayout) constrainPublicValues(api frontend.API, public []frontend.Variable, committedValuesDigest frontend.Variable, publicValues []frontend.Variable) (frontend.Variable, error) {
+	if len(publicValues) != publicValueWordSize*l.PublicValues {
+		return nil, fmt.Errorf("expected %d bytes of public values, got %d", publicValueWordSize*l.PublicValues, len(publicValues))
+	}
+	bytes, values, err := AssertCommittedValues(api, committedValuesDigest, publicValues)
+	if err != nil {
+		return nil, err
+	}
+	digest := committedValuesDigest
+	if l.KeccakDigest {
+		hash, err := keccak256Sum(api, values)
+		if err != nil {
+			return nil, err
+		}
+		hash[0] = bytes.And(hash[0], uints.NewU8(0x1f))
+		digest = packBytes(api, bytes, hash)
+	}
+
+	words := values
+	if l.PublicValuesRoot {
+		nodes := make([][]uints.U8, nextPowerOfTwo(l.PublicValues))
+		for i := range nodes {
+			nodes[i] = uints.NewU8Array(make([]uint8, publicValueWordSize))
+			if i < l.PublicValues {
+				nodes[i] = values[i*publicValueWordSize : (i+1)*publicValueWordSize]
+			}
+		}
+		for len(nodes) > 1 {
+			for i := range len(nodes) / 2 {
+				nodes[i], err = sha256Sum(api, append(append([]uints.U8{}, nodes[2*i]...), nodes[2*i+1]...))
+				if err != nil {
+					return nil, err
+				}
+			}
+			nodes = nodes[:len(nodes)/2]
+		}
+		words = nodes[0]
+	}
+	for i := 0; i < len(words)/publicValueWordSize; i++ {
+		word := words[i*publicValueWordSize : (i+1)*publicValueWordSize]
+		api.AssertIsEqual(public[2*i], packBytes(api, bytes, word[:16]))
+		api.AssertIsEqual(public[2*i+1], packBytes(api, bytes, word[16:]))
+	}
+	return digest, nil
+}
+
+// AssertCommittedValues asserts in the circuit that the bytes publicValues hash to
+// committedValuesDigest, as CommittedValuesDigest, and returns them range checked with
+// the uints.Bytes API checking them.
+func AssertCommittedValues(api frontend.API, committedValuesDigest frontend.Variable, publicValues []frontend.Variable) (*uints.Bytes, []uints.U8, error) {
+	bytes, err := uints.NewBytes(api)
+	if err != nil {
+		return nil, nil, err
+	}
+	values := make([]uints.U8, len(publicValues))
+	for i, v := range publicValues {
+		values[i] = bytes.ValueOf(v)
+	}
+	hash, err := sha256Sum(api, values)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the digest drops the 3 top bits of the hash to fit the field
+	hash[0] = bytes.And(hash[0], uints.NewU8(0x1f))
+	api.AssertIsEqual(committedValuesDigest, packBytes(api, bytes, hash))
+	return bytes, values, nil
+}
+
+// writePublicValuesSolidity writes the lines of the Solidity pack function setting the
+// public inputs of the public values, from its publicValues argument.
+func (l PublicLayout) writePublicValuesSolidity(w io.Writer) error {
+	base := l.nbDigestInputs()
+	if !l.PublicValuesRoot {
+		_, err := fmt.Fprintf(w, `        for (uint256 i = 0; i < %d; i++) {
+            uint256 word;
+            assembly {
+                word := mload(add(publicValues, mul(add(i, 1), 32)))
+            }
+            input[%d + 2 * i] = word >> 128;
+            input[%d + 2 * i + 1] = word & ((1 << 128) - 1);
+        }
+`, l.PublicValues, base, base)
+		return err
+	}
+	_, err := fmt.Fprintf(w, `        bytes32[] memory nodes = new bytes32[](%d);
+        for (uint256 i = 0; i < %d; i++) {
+            bytes32 word;
+            assembly {
+                word := mload(add(publicValues, mul(add(i, 1), 32)))
+            }
+            nodes[i] = word;
+        }
+        for (uint256 width = %d; width > 1; width /= 2) {
+            for (uint256 i = 0; i < width / 2; i++) {
+                nodes[i] = sha256(abi.encodePacked(nodes[2 * i], nodes[2 * i + 1]));
+            }
+        }
+        input[%d] = uint256(nodes[0]) >> 128;
+        input[%d] = uint256(nodes[0]) & ((1 << 128) - 1);
+`, nextPowerOfTwo(l.PublicValues), l.PublicValues, nextPowerOfTwo(l.PublicValues), base, base+1)
+	return err
+}
+
+// sha256Sum returns the sha256 of data in the circuit.
+func sha256Sum(api frontend.API, data []uints.U8) ([]uints.U8, error) {
+	h, err := sha2.New(api)
+	if err != nil {
+		return nil, err
+	}
+	h.Write(data)
+	return h.Sum(), nil
+}
+
+// keccak256Sum returns the keccak256 of data in the circuit, as Solidity's keccak256.
+func keccak256Sum(api frontend.API, data []uints.U8) ([]uints.U8, error) {
+	h, err := stdsha3.NewLegacyKeccak256(api)
+	if err != nil {
+		return nil, err
+	}
+	h.Write(data)
+	return h.Sum(), nil
+}
+
+// packBytes returns the big endian value of data, which must fit the field.
+func packBytes(api frontend.API, bytes *uints.Bytes, data []uints.U8) frontend.Variable {
+	var res frontend.Variable = 0
+	for _, b := range data {
+		res = api.Add(api.Mul(res, 256), bytes.Value(b))
+	}
+	return res
+}
+
+func nextPowerOfTwo(n int) int {
+	res := 1
+	for res < n {
+		res *= 2
+	}
+	return res
+}
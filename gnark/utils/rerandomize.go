@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend/groth16"
+	groth16_bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"math/big"
+)
+
+// RerandomizeProof returns a fresh proof of the same statement as proof, unlinkable
+// to it: with random r1, r2, A' = A/r1, B' = r1·B + r1·r2·[δ]₂ and C' = C + r2·A,
+// so e(A', B') = e(A, B)·e(r2·A, [δ]₂) and the verification equation still holds.
+// The commitments and their proof of knowledge are kept. proof is not modified.
+func RerandomizeProof(proof groth16.Proof, vk groth16.VerifyingKey) (groth16.Proof, error) {
+	p, ok := proof.(*groth16_bn254.Proof)
+	if !ok {
+		return nil, fmt.Errorf("rerandomizing proofs of curve %s is not supported", proof.CurveID())
+	}
+	v, ok := vk.(*groth16_bn254.VerifyingKey)
+	if !ok {
+		return nil, fmt.Errorf("rerandomizing a bn254 proof needs its bn254 vk")
+	}
+
+	var r1, r2, r1Inv fr.Element
+	for r1.IsZero() {
+		_, err := r1.SetRandom()
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err := r2.SetRandom()
+	if err != nil {
+		return nil, err
+	}
+	r1Inv.Inverse(&r1)
+	var s1, s2, s1Inv, r1r2 big.Int
+	r1.BigInt(&s1)
+	r2.BigInt(&s2)
+	r1Inv.BigInt(&s1Inv)
+	var product fr.Element
+	product.Mul(&r1, &r2).BigInt(&r1r2)
+
+	res := &groth16_bn254.Proof{
+		Commitments:   append(p.Commitments[:0:0], p.Commitments...),
+		CommitmentPok: p.CommitmentPok,
+	}
+	res.Ar.ScalarMultiplication(&p.Ar, &s1Inv)
+	delta := v.G2.Delta
+	delta.ScalarMultiplication(&delta, &r1r2)
+	res.Bs.ScalarMultiplication(&p.Bs, &s1)
+	res.Bs.Add(&res.Bs, &delta)
+	res.Krs.ScalarMultiplication(&p.Ar, &s2)
+	res.Krs.Add(&res.Krs, &p.Krs)
+	return res, nil
+}
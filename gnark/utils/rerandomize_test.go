@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	groth16_bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"testing"
+)
+
+func TestRerandomizeProof(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// with and without commitment
+	for _, tc := range []struct{ circuit, assignment frontend.Circuit }{
+		{&wrapperShapeCircuit{}, &wrapperShapeCircuit{VkeyHash: 3, CommittedValuesDigest: 21, X: 7}},
+		{&rangeCheckedCircuit{}, &rangeCheckedCircuit{VkeyHash: 3, CommittedValuesDigest: 21, X: 7}},
+	} {
+		ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, tc.circuit)
+		assert.NoError(err)
+		pk, vk, err := groth16.Setup(ccs)
+		assert.NoError(err)
+		fullWitness, err := frontend.NewWitness(tc.assignment, ecc.BN254.ScalarField())
+		assert.NoError(err)
+		pubWitness, err := fullWitness.Public()
+		assert.NoError(err)
+		proof, err := groth16.Prove(ccs, pk, fullWitness)
+		assert.NoError(err)
+
+		rerandomized, err := RerandomizeProof(proof, vk)
+		assert.NoError(err)
+		assert.NoError(groth16.Verify(rerandomized, vk, pubWitness))
+		p, r := proof.(*groth16_bn254.Proof), rerandomized.(*groth16_bn254.Proof)
+		assert.False(p.Ar.Equal(&r.Ar) || p.Bs.Equal(&r.Bs) || p.Krs.Equal(&r.Krs), "every point of the proof changes")
+		assert.NoError(groth16.Verify(proof, vk, pubWitness), "the proof is not modified")
+	}
+}
@@ -0,0 +1,210 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/consensys/gnark-crypto/ecc/secp256k1/ecdsa"
+	"github.com/consensys/gnark-crypto/ecc/secp256k1/fr"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
+	"golang.org/x/crypto/sha3"
+	"math/big"
+	"os"
+	"strings"
+)
+
+const (
+	SignatureEd25519   = "ed25519"
+	SignatureSecp256k1 = "secp256k1"
+)
+
+// signatureDomain prefixes the proof words in the message signed by a prover, so the
+// signature of a proof is not the signature of anything else.
+const signatureDomain = "pico-proof-v1"
+
+// ProofSignature is the signature of a proof by the identity key of the prover that
+// produced it, 0x prefixed hex. Signer is the ed25519 public key, or the address of a
+// secp256k1 key; Signature is the ed25519 signature, or r || s || v with v 27 or 28,
+// as ecrecover takes it. Package verify checks it.
+type ProofSignature struct {
+	Scheme    string `json:"scheme"`
+	Signer    string `json:"signer"`
+	Signature string `json:"signature"`
+}
+
+// SignatureDigest is the digest a prover signs for proof:
+// keccak256(abi.encodePacked("pico-proof-v1", proof.ABIEncode())).
+func SignatureDigest(proof *OnChainProof) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write([]byte(signatureDomain))
+	h.Write(proof.ABIEncode())
+	return h.Sum(nil)
+}
+
+// ProverKey is the identity key a prover signs its proofs with, so the consumers of a
+// proving service can tell which operator produced a proof.
+type ProverKey struct {
+	scheme    string
+	ed25519   ed25519.PrivateKey
+	secp256k1 *ecdsa.PrivateKey
+	signer    string
+}
+
+// SignatureExt is appended to the path of a proof file in the legacy or gateway format
+// for the path of its signature, the json of ProofSignature. Json proofs carry their
+// signature.
+const SignatureExt = ".sig"
+
+// ProverKeyFromEnv loads the prover key of the file at PROVER_KEY_FILE, nil if unset.
+// Its scheme is PROVER_KEY_SCHEME, ed25519 by default.
+func ProverKeyFromEnv() (*ProverKey, error) {
+	path := os.Getenv("PROVER_KEY_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read prover key: %v", err)
+	}
+	scheme := os.Getenv("PROVER_KEY_SCHEME")
+	if scheme == "" {
+		scheme = SignatureEd25519
+	}
+	key, err := ParseProverKey(scheme, data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prover key %s: %v", path, err)
+	}
+	return key, nil
+}
+
+// ParseProverKey reads a prover key of scheme: for ed25519 a PKCS #8 PEM key, as
+// written by openssl genpkey -algorithm ed25519, or a hex seed; for secp256k1 a hex
+// private key, with or without 0x prefix, as an ethereum account key.
+func ParseProverKey(scheme string, data []byte) (*ProverKey, error) {
+	text := strings.TrimSpace(string(data))
+	switch scheme {
+	case SignatureEd25519:
+		if block, _ := pem.Decode([]byte(text)); block != nil {
+			parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := parsed.(ed25519.PrivateKey)
+			if !ok {
+				return nil, fmt.Errorf("pem key is not an ed25519 key")
+			}
+			return newEd25519ProverKey(key), nil
+		}
+		seed, err := hex.DecodeString(strings.TrimPrefix(text, "0x"))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("expected a pem key or a %d byte hex seed", ed25519.SeedSize)
+		}
+		return newEd25519ProverKey(ed25519.NewKeyFromSeed(seed)), nil
+	case SignatureSecp256k1:
+		secret, err := hex.DecodeString(strings.TrimPrefix(text, "0x"))
+		if err != nil || len(secret) != fr.Bytes {
+			return nil, fmt.Errorf("expected a %d byte hex private key", fr.Bytes)
+		}
+		scalar := new(big.Int).SetBytes(secret)
+		if scalar.Sign() == 0 || scalar.Cmp(fr.Modulus()) >= 0 {
+			return nil, fmt.Errorf("private key is not a secp256k1 scalar")
+		}
+		var key ecdsa.PrivateKey
+		key.PublicKey.A.ScalarMultiplicationBase(scalar)
+		_, err = key.SetBytes(append(key.PublicKey.Bytes(), secret...))
+		if err != nil {
+			return nil, err
+		}
+		return &ProverKey{scheme: scheme, secp256k1: &key, signer: secp256k1Address(&key.PublicKey)}, nil
+	default:
+		return nil, fmt.Errorf("unknown prover key scheme %q, expected %s or %s", scheme, SignatureEd25519, SignatureSecp256k1)
+	}
+}
+
+func newEd25519ProverKey(key ed25519.PrivateKey) *ProverKey {
+	return &ProverKey{scheme: SignatureEd25519, ed25519: key, signer: Encode(key.Public().(ed25519.PublicKey))}
+}
+
+// Signer identifies the key in its signatures: its 0x prefixed hex public key for
+// ed25519, its address for secp256k1.
+func (k *ProverKey) Signer() string {
+	return k.signer
+}
+
+// Sign signs proof with k.
+func (k *ProverKey) Sign(proof *OnChainProof) (*ProofSignature, error) {
+	digest := SignatureDigest(proof)
+	res := &ProofSignature{Scheme: k.scheme, Signer: k.signer}
+	if k.ed25519 != nil {
+		res.Signature = Encode(ed25519.Sign(k.ed25519, digest))
+		return res, nil
+	}
+	for {
+		v, r, s, err := k.secp256k1.SignForRecover(digest, nil)
+		if err != nil {
+			return nil, fmt.Errorf("fail to sign proof: %v", err)
+		}
+		// ecrecover only takes the parity of y, an x above the order is left to another
+		// nonce
+		if v > 1 {
+			continue
+		}
+		// and contracts reject the upper half of s, as transactions do
+		if s.Cmp(new(big.Int).Rsh(fr.Modulus(), 1)) > 0 {
+			s.Sub(fr.Modulus(), s)
+			v ^= 1
+		}
+		signature := make([]byte, 65)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:64])
+		signature[64] = byte(v) + 27
+		res.Signature = Encode(signature)
+		return res, nil
+	}
+}
+
+// SignProof signs a wrapper proof and its public witness with k.
+func (k *ProverKey) SignProof(proof groth16.Proof, pubWitness witness.Witness) (*ProofSignature, error) {
+	onChain, err := GetAggOnChainProof(proof, pubWitness)
+	if err != nil {
+		return nil, err
+	}
+	return k.Sign(onChain)
+}
+
+// secp256k1Address is the ethereum address of a secp256k1 public key.
+func secp256k1Address(publicKey *ecdsa.PublicKey) string {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(publicKey.Bytes())
+	return Encode(h.Sum(nil)[12:])
+}
+
+// signProofFromEnv signs proof with the key of ProverKeyFromEnv, nil if there is none.
+func signProofFromEnv(proof *OnChainProof) (*ProofSignature, error) {
+	key, err := ProverKeyFromEnv()
+	if err != nil || key == nil {
+		return nil, err
+	}
+	return key.Sign(proof)
+}
+
+// FormatProofSignature renders the detached signature of a proof file in format, nil
+// for json proofs, which carry their signature, or without a prover key.
+func FormatProofSignature(format string, proof groth16.Proof, pubWitness witness.Witness) ([]byte, error) {
+	if format == ProofFormatJson {
+		return nil, nil
+	}
+	onChain, err := GetAggOnChainProof(proof, pubWitness)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signProofFromEnv(onChain)
+	if err != nil || signature == nil {
+		return nil, err
+	}
+	return json.MarshalIndent(signature, "", "  ")
+}
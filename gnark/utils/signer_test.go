@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"github.com/consensys/gnark/test"
+	"testing"
+)
+
+func TestParseProverKey(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	seed, _ := hex.DecodeString("9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60")
+	key := ed25519.NewKeyFromSeed(seed)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(err)
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	// RFC 8032 test 1
+	publicKey := "0xd75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a"
+	for _, data := range []string{string(pemKey), hex.EncodeToString(seed) + "\n", "0x" + hex.EncodeToString(seed)} {
+		parsed, err := ParseProverKey(SignatureEd25519, []byte(data))
+		assert.NoError(err, data)
+		assert.Equal(publicKey, parsed.Signer())
+	}
+
+	parsed, err := ParseProverKey(SignatureSecp256k1, []byte("0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318\n"))
+	assert.NoError(err)
+	assert.Equal("0x2c7536e3605d9c16a7a3d7b1898e529396a65c23", parsed.Signer())
+
+	for scheme, data := range map[string]string{
+		SignatureEd25519:   "0x1234",
+		SignatureSecp256k1: "0xfffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141",
+		"rsa":              "0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318",
+	} {
+		_, err = ParseProverKey(scheme, []byte(data))
+		assert.Error(err, scheme)
+	}
+}
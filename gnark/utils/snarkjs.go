@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/constraint"
+	"io"
+	"math/big"
+)
+
+// Section types of the .r1cs format.
+const (
+	r1csHeaderSection      = 1
+	r1csConstraintsSection = 2
+	r1csWireLabelSection   = 3
+)
+
+// Section types of the .wtns format.
+const (
+	wtnsHeaderSection = 1
+	wtnsValuesSection = 2
+)
+
+// WriteSnarkjsR1cs writes ccs in the binary .r1cs format of circom and snarkjs. The
+// wires of gnark map one to one to those of the format: the constant one, the public
+// inputs, the secret inputs, then the internal wires, each labelled with its index.
+// Groth16 commitments have no equivalent: their wires are exported as internal wires,
+// which no exported constraint binds to the committed ones.
+func WriteSnarkjsR1cs(w io.Writer, ccs constraint.ConstraintSystem) error {
+	r1cs, ok := ccs.(interface {
+		GetR1CIterator() constraint.R1CIterator
+		ToBigInt(constraint.U64) *big.Int
+	})
+	if !ok {
+		return fmt.Errorf("unsupported constraint system %T", ccs)
+	}
+	modulus := ccs.Field()
+	n8 := (modulus.BitLen() + 63) / 64 * 8
+	nbWires := ccs.GetNbPublicVariables() + ccs.GetNbSecretVariables() + ccs.GetNbInternalVariables()
+
+	bw := bufio.NewWriter(w)
+	writeUint32 := func(v int) {
+		binary.Write(bw, binary.LittleEndian, uint32(v))
+	}
+	writeUint64 := func(v int) {
+		binary.Write(bw, binary.LittleEndian, uint64(v))
+	}
+	// field elements are little endian
+	element := make([]byte, n8)
+	writeElement := func(v *big.Int) {
+		v.FillBytes(element)
+		for i, j := 0, len(element)-1; i < j; i, j = i+1, j-1 {
+			element[i], element[j] = element[j], element[i]
+		}
+		bw.Write(element)
+	}
+	expressionSize := func(l constraint.LinearExpression) int {
+		return 4 + len(l)*(4+n8)
+	}
+	writeExpression := func(l constraint.LinearExpression) {
+		writeUint32(len(l))
+		for _, t := range l {
+			writeUint32(int(t.VID))
+			writeElement(r1cs.ToBigInt(ccs.GetCoefficient(int(t.CID))))
+		}
+	}
+
+	bw.WriteString("r1cs")
+	writeUint32(1)
+	writeUint32(3)
+
+	writeUint32(r1csHeaderSection)
+	writeUint64(4 + n8 + 4*4 + 8 + 4)
+	writeUint32(n8)
+	writeElement(modulus)
+	writeUint32(nbWires)
+	writeUint32(0)
+	writeUint32(ccs.GetNbPublicVariables() - 1)
+	writeUint32(ccs.GetNbSecretVariables())
+	writeUint64(nbWires)
+	writeUint32(ccs.GetNbConstraints())
+
+	// the section size precedes the constraints, so they are iterated twice instead of
+	// holding millions of them in memory
+	size := 0
+	it := r1cs.GetR1CIterator()
+	for r1c := it.Next(); r1c != nil; r1c = it.Next() {
+		size += expressionSize(r1c.L) + expressionSize(r1c.R) + expressionSize(r1c.O)
+	}
+	writeUint32(r1csConstraintsSection)
+	writeUint64(size)
+	it = r1cs.GetR1CIterator()
+	for r1c := it.Next(); r1c != nil; r1c = it.Next() {
+		writeExpression(r1c.L)
+		writeExpression(r1c.R)
+		writeExpression(r1c.O)
+	}
+
+	writeUint32(r1csWireLabelSection)
+	writeUint64(8 * nbWires)
+	for i := 0; i < nbWires; i++ {
+		writeUint64(i)
+	}
+	return bw.Flush()
+}
+
+// WriteSnarkjsWtns writes the values of the wires of a solved BN254 circuit in the
+// binary .wtns format of snarkjs, in the order of WriteSnarkjsR1cs.
+func WriteSnarkjsWtns(w io.Writer, wires fr.Vector) error {
+	bw := bufio.NewWriter(w)
+	writeUint32 := func(v int) {
+		binary.Write(bw, binary.LittleEndian, uint32(v))
+	}
+	writeUint64 := func(v int) {
+		binary.Write(bw, binary.LittleEndian, uint64(v))
+	}
+
+	bw.WriteString("wtns")
+	writeUint32(2)
+	writeUint32(2)
+
+	writeUint32(wtnsHeaderSection)
+	writeUint64(4 + fr.Bytes + 4)
+	writeUint32(fr.Bytes)
+	modulus := fr.Modulus()
+	element := modulus.FillBytes(make([]byte, fr.Bytes))
+	for i, j := 0, len(element)-1; i < j; i, j = i+1, j-1 {
+		element[i], element[j] = element[j], element[i]
+	}
+	bw.Write(element)
+	writeUint32(len(wires))
+
+	writeUint32(wtnsValuesSection)
+	writeUint64(fr.Bytes * len(wires))
+	var value [fr.Bytes]byte
+	for i := range wires {
+		fr.LittleEndian.PutElement(&value, wires[i])
+		bw.Write(value[:])
+	}
+	return bw.Flush()
+}
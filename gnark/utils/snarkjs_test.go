@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/consensys/gnark-crypto/ecc"
+	cs_bn254 "github.com/consensys/gnark/constraint/bn254"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"math/big"
+	"testing"
+)
+
+func TestWriteSnarkjsR1cs(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &cubeCircuit{})
+	assert.NoError(err)
+	var buf bytes.Buffer
+	assert.NoError(WriteSnarkjsR1cs(&buf, ccs))
+
+	r := bytes.NewReader(buf.Bytes())
+	readUint32 := func() int {
+		var v uint32
+		assert.NoError(binary.Read(r, binary.LittleEndian, &v))
+		return int(v)
+	}
+	readUint64 := func() int {
+		var v uint64
+		assert.NoError(binary.Read(r, binary.LittleEndian, &v))
+		return int(v)
+	}
+	readElement := func() *big.Int {
+		b := make([]byte, 32)
+		_, err := r.Read(b)
+		assert.NoError(err)
+		for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+			b[i], b[j] = b[j], b[i]
+		}
+		return new(big.Int).SetBytes(b)
+	}
+
+	magic := make([]byte, 4)
+	_, err = r.Read(magic)
+	assert.NoError(err)
+	assert.Equal("r1cs", string(magic))
+	assert.Equal(1, readUint32())
+	assert.Equal(3, readUint32())
+
+	assert.Equal(r1csHeaderSection, readUint32())
+	assert.Equal(64, readUint64())
+	assert.Equal(32, readUint32())
+	assert.Equal(0, readElement().Cmp(ecc.BN254.ScalarField()))
+	nbWires := readUint32()
+	assert.Equal(ccs.GetNbPublicVariables()+ccs.GetNbSecretVariables()+ccs.GetNbInternalVariables(), nbWires)
+	assert.Equal(0, readUint32())
+	assert.Equal(1, readUint32())
+	assert.Equal(1, readUint32())
+	assert.Equal(nbWires, readUint64())
+	nbConstraints := readUint32()
+	assert.Equal(ccs.GetNbConstraints(), nbConstraints)
+
+	// the exported constraints hold on the wires solved by gnark
+	fullWitness, err := frontend.NewWitness(&cubeCircuit{X: 3, Y: 27}, ecc.BN254.ScalarField())
+	assert.NoError(err)
+	solution, err := ccs.Solve(fullWitness)
+	assert.NoError(err)
+	wires := solution.(*cs_bn254.R1CSSolution).W
+	modulus := ecc.BN254.ScalarField()
+	evaluate := func() *big.Int {
+		sum := new(big.Int)
+		for n := readUint32(); n > 0; n-- {
+			wire := readUint32()
+			coeff := readElement()
+			var value big.Int
+			wires[wire].BigInt(&value)
+			sum.Add(sum, value.Mul(&value, coeff))
+		}
+		return sum.Mod(sum, modulus)
+	}
+	assert.Equal(r1csConstraintsSection, readUint32())
+	readUint64()
+	for i := 0; i < nbConstraints; i++ {
+		a, b, c := evaluate(), evaluate(), evaluate()
+		assert.Equal(0, new(big.Int).Mod(a.Mul(a, b), modulus).Cmp(c), "constraint %d", i)
+	}
+
+	assert.Equal(r1csWireLabelSection, readUint32())
+	assert.Equal(8*nbWires, readUint64())
+	for i := 0; i < nbWires; i++ {
+		assert.Equal(i, readUint64())
+	}
+	assert.Equal(0, r.Len())
+}
+
+func TestWriteSnarkjsWtns(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &cubeCircuit{})
+	assert.NoError(err)
+	fullWitness, err := frontend.NewWitness(&cubeCircuit{X: 3, Y: 27}, ecc.BN254.ScalarField())
+	assert.NoError(err)
+	solution, err := ccs.Solve(fullWitness)
+	assert.NoError(err)
+	wires := solution.(*cs_bn254.R1CSSolution).W
+	var buf bytes.Buffer
+	assert.NoError(WriteSnarkjsWtns(&buf, wires))
+
+	r := bytes.NewReader(buf.Bytes())
+	readUint32 := func() int {
+		var v uint32
+		assert.NoError(binary.Read(r, binary.LittleEndian, &v))
+		return int(v)
+	}
+	readUint64 := func() int {
+		var v uint64
+		assert.NoError(binary.Read(r, binary.LittleEndian, &v))
+		return int(v)
+	}
+	readElement := func() *big.Int {
+		b := make([]byte, 32)
+		_, err := r.Read(b)
+		assert.NoError(err)
+		for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+			b[i], b[j] = b[j], b[i]
+		}
+		return new(big.Int).SetBytes(b)
+	}
+
+	magic := make([]byte, 4)
+	_, err = r.Read(magic)
+	assert.NoError(err)
+	assert.Equal("wtns", string(magic))
+	assert.Equal(2, readUint32())
+	assert.Equal(2, readUint32())
+
+	assert.Equal(wtnsHeaderSection, readUint32())
+	assert.Equal(40, readUint64())
+	assert.Equal(32, readUint32())
+	assert.Equal(0, readElement().Cmp(ecc.BN254.ScalarField()))
+	assert.Equal(len(wires), readUint32())
+
+	// the one wire, the public input, the secret input, then the internal wires
+	assert.Equal(wtnsValuesSection, readUint32())
+	assert.Equal(32*len(wires), readUint64())
+	assert.Equal(ccs.GetNbPublicVariables()+ccs.GetNbSecretVariables()+ccs.GetNbInternalVariables(), len(wires))
+	assert.Equal(int64(1), readElement().Int64())
+	assert.Equal(int64(27), readElement().Int64())
+	assert.Equal(int64(3), readElement().Int64())
+	for i := 3; i < len(wires); i++ {
+		var value big.Int
+		wires[i].BigInt(&value)
+		assert.Equal(0, readElement().Cmp(&value), "wire %d", i)
+	}
+	assert.Equal(0, r.Len())
+}
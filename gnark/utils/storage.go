@@ -0,0 +1,537 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Storage reads and writes artifacts by name. Paths accepted by the Read*/Write*
+// helpers of this package are resolved with StorageFor, so keys, ccs, witnesses and
+// proofs can live in a bucket instead of on local disk.
+type Storage interface {
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+	// Write stores what write produces under name. Readers never see a partial object.
+	Write(ctx context.Context, name string, write func(w io.Writer) error) error
+}
+
+// StorageFor returns the storage of path and the object name within it:
+// s3://bucket/key for S3, gs://bucket/object for Google Cloud Storage and a local
+// path for anything else.
+func StorageFor(path string) (Storage, string, error) {
+	scheme, rest, ok := strings.Cut(path, "://")
+	if !ok {
+		return LocalStorage{}, path, nil
+	}
+	bucket, name, _ := strings.Cut(rest, "/")
+	if bucket == "" || name == "" {
+		return nil, "", fmt.Errorf("invalid storage path %s, expected %s://bucket/name", path, scheme)
+	}
+	switch scheme {
+	case "s3":
+		return NewS3Storage(bucket), name, nil
+	case "gs":
+		return NewGCSStorage(bucket), name, nil
+	case "file":
+		return LocalStorage{}, rest, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported storage scheme %s", scheme)
+	}
+}
+
+// OpenArtifact opens path for reading, see StorageFor.
+func OpenArtifact(path string) (io.ReadCloser, error) {
+	storage, name, err := StorageFor(path)
+	if err != nil {
+		return nil, err
+	}
+	return storage.Open(context.Background(), name)
+}
+
+// ReadArtifact reads all of path, see StorageFor.
+func ReadArtifact(path string) ([]byte, error) {
+	r, err := OpenArtifact(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// ArtifactSize returns the size of path, see StorageFor. It fails for an object whose
+// storage does not tell its size.
+func ArtifactSize(path string) (int64, error) {
+	r, err := OpenArtifact(path)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	size := artifactSize(r)
+	if size < 0 {
+		return 0, fmt.Errorf("size of %s is unknown", path)
+	}
+	return size, nil
+}
+
+// WriteArtifact stores what write produces at path, see StorageFor.
+func WriteArtifact(path string, write func(w io.Writer) error) error {
+	storage, name, err := StorageFor(path)
+	if err != nil {
+		return err
+	}
+	err = storage.Write(context.Background(), name, write)
+	if err != nil {
+		return err
+	}
+	// local files are recorded by WriteFileAtomic
+	if _, ok := storage.(LocalStorage); !ok {
+		recordWrittenFile(path)
+	}
+	return nil
+}
+
+// LocalStorage reads and writes files, names are paths.
+type LocalStorage struct{}
+
+func (LocalStorage) Open(_ context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+func (LocalStorage) Write(_ context.Context, name string, write func(w io.Writer) error) error {
+	return WriteFileAtomic(name, write)
+}
+
+// writeThroughTempFile spools write's output to a temporary file, so it can be
+// uploaded with a known length and removed if interrupted, then calls upload.
+func writeThroughTempFile(write func(w io.Writer) error, upload func(f *os.File, size int64) error) error {
+	f, err := os.CreateTemp("", "pico-upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	err = trackPartialFile(f.Name())
+	if err != nil {
+		return err
+	}
+	defer untrackPartialFile(f.Name())
+
+	err = write(f)
+	if err != nil {
+		return err
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	return upload(f, size)
+}
+
+func checkResponse(resp *http.Response, what string) error {
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("fail to %s: %w", what, os.ErrNotExist)
+	}
+	return fmt.Errorf("fail to %s: %s: %s", what, resp.Status, bytes.TrimSpace(body))
+}
+
+// S3Storage talks to S3 or a compatible service with SigV4 signed requests. The
+// credentials come from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and the optional
+// AWS_SESSION_TOKEN, the region from AWS_REGION (us-east-1 by default). Setting
+// AWS_ENDPOINT_URL selects another service, addressed path-style.
+type S3Storage struct {
+	Bucket       string
+	Region       string
+	Endpoint     string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	// PartSize is the size of the parts of a multipart upload; larger objects are
+	// uploaded in parts, smaller ones with a single PUT.
+	PartSize int64
+	Client   *http.Client
+}
+
+const (
+	s3DefaultPartSize = 64 << 20
+	// s3MaxParts is the most parts S3 accepts in a multipart upload.
+	s3MaxParts = 10000
+)
+
+func NewS3Storage(bucket string) *S3Storage {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &S3Storage{
+		Bucket:       bucket,
+		Region:       region,
+		Endpoint:     os.Getenv("AWS_ENDPOINT_URL"),
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		PartSize:     s3DefaultPartSize,
+		Client:       http.DefaultClient,
+	}
+}
+
+func (s *S3Storage) objectURL(name string) string {
+	if s.Endpoint != "" {
+		return strings.TrimSuffix(s.Endpoint, "/") + "/" + s.Bucket + "/" + awsURIEncode(name, false)
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.Bucket, s.Region, awsURIEncode(name, false))
+}
+
+func (s *S3Storage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, time.Now())
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	err = checkResponse(resp, "get s3://"+s.Bucket+"/"+name)
+	if err != nil {
+		return nil, err
+	}
+	return sizedReadCloser{resp.Body, resp.ContentLength}, nil
+}
+
+func (s *S3Storage) Write(ctx context.Context, name string, write func(w io.Writer) error) error {
+	return writeThroughTempFile(write, func(f *os.File, size int64) error {
+		if size > s.PartSize {
+			return s.writeMultipart(ctx, name, f, size)
+		}
+		resp, err := s.do(ctx, http.MethodPut, s.objectURL(name), f, size)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		return checkResponse(resp, "put s3://"+s.Bucket+"/"+name)
+	})
+}
+
+func (s *S3Storage) do(ctx context.Context, method, u string, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	s.sign(req, time.Now())
+	return s.Client.Do(req)
+}
+
+type s3CompletedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+// writeMultipart uploads f in parts, as a single PUT is limited to 5 GB. The upload
+// is aborted if a part fails, so S3 does not keep the parts around.
+func (s *S3Storage) writeMultipart(ctx context.Context, name string, f *os.File, size int64) error {
+	object := "s3://" + s.Bucket + "/" + name
+	u := s.objectURL(name)
+	resp, err := s.do(ctx, http.MethodPost, u+"?uploads", nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	err = checkResponse(resp, "start multipart upload of "+object)
+	if err != nil {
+		return err
+	}
+	var started struct {
+		UploadId string
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&started)
+	if err != nil {
+		return fmt.Errorf("fail to start multipart upload of %s: %v", object, err)
+	}
+	upload := "uploadId=" + awsURIEncode(started.UploadId, true)
+
+	err = s.uploadParts(ctx, u+"?"+upload, object, f, size)
+	if err != nil {
+		abort, abortErr := s.do(context.WithoutCancel(ctx), http.MethodDelete, u+"?"+upload, nil, 0)
+		if abortErr == nil {
+			abortErr = checkResponse(abort, "abort multipart upload of "+object)
+			abort.Body.Close()
+		}
+		if abortErr != nil {
+			fmt.Printf("fail to abort multipart upload %s of %s: %v\n", started.UploadId, object, abortErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *S3Storage) uploadParts(ctx context.Context, u, object string, f *os.File, size int64) error {
+	partSize := s.PartSize
+	if size > partSize*s3MaxParts {
+		partSize = (size + s3MaxParts - 1) / s3MaxParts
+	}
+	var completed struct {
+		XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+		Parts   []s3CompletedPart `xml:"Part"`
+	}
+	for offset, number := int64(0), 1; offset < size; offset, number = offset+partSize, number+1 {
+		n := min(partSize, size-offset)
+		resp, err := s.do(ctx, http.MethodPut, fmt.Sprintf("%s&partNumber=%d", u, number), io.NewSectionReader(f, offset, n), n)
+		if err != nil {
+			return err
+		}
+		err = checkResponse(resp, fmt.Sprintf("upload part %d of %s", number, object))
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		completed.Parts = append(completed.Parts, s3CompletedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+	}
+
+	body, err := xml.Marshal(completed)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodPost, u, bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	err = checkResponse(resp, "complete multipart upload of "+object)
+	if err != nil {
+		return err
+	}
+	// S3 may answer 200 and report the failure in the body
+	var result struct {
+		XMLName xml.Name
+		Message string
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return fmt.Errorf("fail to complete multipart upload of %s: %v", object, err)
+	}
+	if result.XMLName.Local == "Error" {
+		return fmt.Errorf("fail to complete multipart upload of %s: %s", object, result.Message)
+	}
+	return nil
+}
+
+// sign adds a SigV4 authorization to req. The payload is left unsigned, which S3
+// accepts over TLS, so bodies can be streamed.
+func (s *S3Storage) sign(req *http.Request, now time.Time) {
+	s.signPayload(req, now, "UNSIGNED-PAYLOAD")
+}
+
+// signPayload adds a SigV4 authorization to req for the payload hash payloadHash, the
+// hex sha256 of the body or UNSIGNED-PAYLOAD. Only the host and x-amz-* headers are
+// signed.
+func (s *S3Storage) signPayload(req *http.Request, now time.Time, payloadHash string) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	if s.SessionToken != "" {
+		req.Header.Set("x-amz-security-token", s.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsURIEncode(req.URL.Path, false),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsURIEncode is the UriEncode of SigV4: every byte but the unreserved characters
+// of RFC 3986 (A-Z, a-z, 0-9, '-', '.', '_' and '~') is encoded as %XX in upper case
+// hex, so a space is %20 and never +, and '=', ':', '@', '&' or '$' are encoded too.
+// '/' is kept unless encodeSlash, for paths. S3 keys are encoded once.
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' || c == '/' && !encodeSlash {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3CanonicalQuery is the canonical query string of SigV4: the parameters encoded
+// with awsURIEncode, sorted by name then value, a parameter without value as "name=".
+func s3CanonicalQuery(query url.Values) string {
+	type param struct{ name, value string }
+	var params []param
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, param{awsURIEncode(name, true), awsURIEncode(value, true)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i].name != params[j].name {
+			return params[i].name < params[j].name
+		}
+		return params[i].value < params[j].value
+	})
+	encoded := make([]string, len(params))
+	for i, p := range params {
+		encoded[i] = p.name + "=" + p.value
+	}
+	return strings.Join(encoded, "&")
+}
+
+// GCSStorage talks to Google Cloud Storage through its JSON API. The access token
+// comes from GCS_ACCESS_TOKEN, or from the metadata server when running on GCP.
+// GCS_ENDPOINT selects another endpoint, e.g. an emulator.
+type GCSStorage struct {
+	Bucket   string
+	Endpoint string
+	Token    string
+	Client   *http.Client
+}
+
+func NewGCSStorage(bucket string) *GCSStorage {
+	endpoint := os.Getenv("GCS_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "https://storage.googleapis.com"
+	}
+	return &GCSStorage{
+		Bucket:   bucket,
+		Endpoint: strings.TrimSuffix(endpoint, "/"),
+		Token:    os.Getenv("GCS_ACCESS_TOKEN"),
+		Client:   http.DefaultClient,
+	}
+}
+
+func (s *GCSStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", s.Endpoint, url.PathEscape(s.Bucket), url.PathEscape(name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	err = checkResponse(resp, "get gs://"+s.Bucket+"/"+name)
+	if err != nil {
+		return nil, err
+	}
+	return sizedReadCloser{resp.Body, resp.ContentLength}, nil
+}
+
+func (s *GCSStorage) Write(ctx context.Context, name string, write func(w io.Writer) error) error {
+	return writeThroughTempFile(write, func(f *os.File, size int64) error {
+		u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s", s.Endpoint, url.PathEscape(s.Bucket), url.QueryEscape(name))
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, f)
+		if err != nil {
+			return err
+		}
+		req.ContentLength = size
+		req.Header.Set("Content-Type", "application/octet-stream")
+		resp, err := s.do(ctx, req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		return checkResponse(resp, "upload gs://"+s.Bucket+"/"+name)
+	})
+}
+
+func (s *GCSStorage) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	token := s.Token
+	if token == "" {
+		var err error
+		token, err = gcpMetadataToken(ctx, s.Client)
+		if err != nil {
+			return nil, fmt.Errorf("no GCS_ACCESS_TOKEN and no metadata server: %v", err)
+		}
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return s.Client.Do(req)
+}
+
+func gcpMetadataToken(ctx context.Context, client *http.Client) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	err = checkResponse(resp, "get metadata token")
+	if err != nil {
+		return "", err
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+)
+
+// WitnessVersion is the format version of the witnesses of the current Pico release.
+// Bump it when the circuit of a witness changes shape, and keep the construction of
+// the previous version so its witnesses stay provable.
+const WitnessVersion = 1
+
+// FormatVersion is the format version of w. Witnesses without a version predate
+// versioning and have the format of version 1.
+func (w *WitnessInput) FormatVersion() int {
+	if w.Version == 0 {
+		return 1
+	}
+	return w.Version
+}
+
+// FormatVersion is the format version of the chunks, which Validate checks agree.
+func (m *MultiWitnessInput) FormatVersion() int {
+	if len(m.Chunks) == 0 {
+		return WitnessVersion
+	}
+	return m.Chunks[0].FormatVersion()
+}
+
+// CircuitVersions maps the witness format versions a build supports to the circuit
+// construction of each.
+type CircuitVersions[T any] map[int]T
+
+// Select returns the construction for witnesses of version.
+func (v CircuitVersions[T]) Select(version int) (T, error) {
+	construction, ok := v[version]
+	if !ok {
+		var supported []int
+		for known := range v {
+			supported = append(supported, known)
+		}
+		sort.Ints(supported)
+		var zero T
+		return zero, fmt.Errorf("unsupported witness version %d, this build supports %v", version, supported)
+	}
+	return construction, nil
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"github.com/consensys/gnark/test"
+	"testing"
+)
+
+func TestCircuitVersions(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	single, _, err := ParseWitness([]byte(`{"vkey_hash": "1", "committed_values_digest": "2"}`))
+	assert.NoError(err)
+	assert.Equal(1, single.FormatVersion())
+	single, _, err = ParseWitness([]byte(`{"version": 2, "vkey_hash": "1", "committed_values_digest": "2"}`))
+	assert.NoError(err)
+	assert.Equal(2, single.FormatVersion())
+
+	versions := CircuitVersions[string]{1: "v1", 3: "v3"}
+	construction, err := versions.Select(3)
+	assert.NoError(err)
+	assert.Equal("v3", construction)
+	_, err = versions.Select(2)
+	assert.ErrorContains(err, "unsupported witness version 2, this build supports [1 3]")
+
+	_, multi, err := ParseWitness([]byte(`{"chunks": [
+		{"vars": ["1"], "vkey_hash": "1", "committed_values_digest": "2"},
+		{"version": 2, "vars": ["2"], "vkey_hash": "1", "committed_values_digest": "3"}
+	]}`))
+	assert.NoError(err)
+	assert.ErrorContains(multi.Validate(), "chunk 1 has witness version 2, chunk 0 has 1")
+	multi.Chunks[1].Version = 1
+	assert.NoError(multi.Validate())
+	assert.Equal(1, multi.FormatVersion())
+}
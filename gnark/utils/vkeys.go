@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/math/uints"
+	"math/big"
+)
+
+// Aggregated tells whether m wraps the chunks of several programs, binding them to the
+// root of the tree of its AllowedVkeys instead of a single vkey hash.
+func (m *MultiWitnessInput) Aggregated() bool {
+	return len(m.AllowedVkeys) > 0
+}
+
+// AllowedVkeyHashes parses the AllowedVkeys of m, which must be distinct BN254 field
+// elements.
+func (m *MultiWitnessInput) AllowedVkeyHashes() ([]*big.Int, error) {
+	var res []*big.Int
+	for i, vkey := range m.AllowedVkeys {
+		hash, ok := new(big.Int).SetString(vkey, 0)
+		if !ok || hash.Sign() < 0 || hash.Cmp(ecc.BN254.ScalarField()) >= 0 {
+			return nil, fmt.Errorf("invalid allowed vkey hash: %q", vkey)
+		}
+		for j, other := range res {
+			if other.Cmp(hash) == 0 {
+				return nil, fmt.Errorf("allowed vkey %d is a duplicate of allowed vkey %d", i, j)
+			}
+		}
+		res = append(res, hash)
+	}
+	return res, nil
+}
+
+// VkeyIndex is the index of vkeyHash in vkeyHashes, -1 if it is not one of them.
+func VkeyIndex(vkeyHashes []*big.Int, vkeyHash *big.Int) int {
+	for i, hash := range vkeyHashes {
+		if hash.Cmp(vkeyHash) == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// VkeyTreeSize is the number of leaves of the tree of nbVkeys allowed vkeys, padded
+// with zero leaves to a power of two.
+func VkeyTreeSize(nbVkeys int) int {
+	return nextPowerOfTwo(nbVkeys)
+}
+
+// VkeysRoot is the root of the Merkle tree of the allowed vkeyHashes: the root of
+// PublicValuesRoot over their 32 byte big endian words.
+func VkeysRoot(vkeyHashes []*big.Int) [32]byte {
+	levels := merkleLevels(vkeyWords(vkeyHashes))
+	return levels[len(levels)-1][0]
+}
+
+// VkeyPath returns the siblings of the leaf at index of the tree of VkeysRoot, from
+// the leaf up.
+func VkeyPath(vkeyHashes []*big.Int, index int) [][32]byte {
+	var res [][32]byte
+	levels := merkleLevels(vkeyWords(vkeyHashes))
+	for _, nodes := range levels[:len(levels)-1] {
+		res = append(res, nodes[index^1])
+		index /= 2
+	}
+	return res
+}
+
+func vkeyWords(vkeyHashes []*big.Int) []byte {
+	res := make([]byte, publicValueWordSize*len(vkeyHashes))
+	for i, hash := range vkeyHashes {
+		hash.FillBytes(res[i*publicValueWordSize : (i+1)*publicValueWordSize])
+	}
+	return res
+}
+
+// AssertVkeyAllowed asserts in the circuit that vkeyHash is the leaf at index of the
+// tree of VkeysRoot whose root is given as two 128 bit limbs, high first. path holds
+// the 32 bytes of each sibling of VkeyPath, from the leaf up.
+func AssertVkeyAllowed(api frontend.API, root [2]frontend.Variable, vkeyHash, index frontend.Variable, path []frontend.Variable) error {
+	if len(path)%publicValueWordSize != 0 {
+		return fmt.Errorf("vkey path has %d bytes, expected 32 per level", len(path))
+	}
+	bytes, err := uints.NewBytes(api)
+	if err != nil {
+		return err
+	}
+	// the leaf is the big endian word of the vkey hash
+	bits := api.ToBinary(vkeyHash)
+	for len(bits) < 8*publicValueWordSize {
+		bits = append(bits, 0)
+	}
+	node := make([]uints.U8, publicValueWordSize)
+	for i := range node {
+		j := 8 * (publicValueWordSize - 1 - i)
+		node[i] = bytes.ValueOf(api.FromBinary(bits[j : j+8]...))
+	}
+
+	depth := len(path) / publicValueWordSize
+	indexBits := api.ToBinary(index, max(depth, 1))
+	for level := range depth {
+		sibling := path[level*publicValueWordSize : (level+1)*publicValueWordSize]
+		left := make([]uints.U8, publicValueWordSize)
+		right := make([]uints.U8, publicValueWordSize)
+		for i := range node {
+			left[i] = bytes.ValueOf(api.Select(indexBits[level], sibling[i], bytes.Value(node[i])))
+			right[i] = bytes.ValueOf(api.Select(indexBits[level], bytes.Value(node[i]), sibling[i]))
+		}
+		node, err = sha256Sum(api, append(left, right...))
+		if err != nil {
+			return err
+		}
+	}
+	api.AssertIsEqual(root[0], packBytes(api, bytes, node[:16]))
+	api.AssertIsEqual(root[1], packBytes(api, bytes, node[16:]))
+	return nil
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"math/big"
+	"testing"
+)
+
+type vkeyAllowedCircuit struct {
+	Root     [2]frontend.Variable `gnark:",public"`
+	VkeyHash frontend.Variable
+	Index    frontend.Variable
+	Path     []frontend.Variable
+}
+
+func (c *vkeyAllowedCircuit) Define(api frontend.API) error {
+	return AssertVkeyAllowed(api, c.Root, c.VkeyHash, c.Index, c.Path)
+}
+
+func newVkeyAllowedAssignment(vkeyHashes []*big.Int, index int) *vkeyAllowedCircuit {
+	root := VkeysRoot(vkeyHashes)
+	res := &vkeyAllowedCircuit{
+		Root:     [2]frontend.Variable{new(big.Int).SetBytes(root[:16]), new(big.Int).SetBytes(root[16:])},
+		VkeyHash: vkeyHashes[index],
+		Index:    index,
+	}
+	for _, sibling := range VkeyPath(vkeyHashes, index) {
+		for _, b := range sibling {
+			res.Path = append(res.Path, b)
+		}
+	}
+	return res
+}
+
+func TestVkeysRoot(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	vkeyHashes := []*big.Int{big.NewInt(7), big.NewInt(8), new(big.Int).Sub(ecc.BN254.ScalarField(), big.NewInt(1))}
+	assert.Equal(4, VkeyTreeSize(len(vkeyHashes)))
+	words := vkeyWords(vkeyHashes)
+	assert.Equal(PublicValuesRoot(words), VkeysRoot(vkeyHashes))
+
+	// the path of a leaf hashes up to the root
+	for index := range vkeyHashes {
+		path := VkeyPath(vkeyHashes, index)
+		assert.Len(path, 2)
+		var node [32]byte
+		vkeyHashes[index].FillBytes(node[:])
+		for level, sibling := range path {
+			if index>>level&1 == 0 {
+				node = sha256.Sum256(append(node[:], sibling[:]...))
+			} else {
+				node = sha256.Sum256(append(sibling[:], node[:]...))
+			}
+		}
+		assert.Equal(VkeysRoot(vkeyHashes), node)
+	}
+
+	// a single vkey is its own root
+	single := VkeysRoot(vkeyHashes[:1])
+	assert.Equal(0, new(big.Int).SetBytes(single[:]).Cmp(vkeyHashes[0]))
+	assert.Empty(VkeyPath(vkeyHashes[:1], 0))
+}
+
+func TestAssertVkeyAllowed(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	vkeyHashes := []*big.Int{big.NewInt(7), big.NewInt(8), new(big.Int).Sub(ecc.BN254.ScalarField(), big.NewInt(1))}
+	for _, allowed := range [][]*big.Int{vkeyHashes, vkeyHashes[:1]} {
+		circuit := &vkeyAllowedCircuit{Path: make([]frontend.Variable, 32*len(VkeyPath(allowed, 0)))}
+		for index := range allowed {
+			assert.NoError(test.IsSolved(circuit, newVkeyAllowedAssignment(allowed, index), ecc.BN254.ScalarField()))
+		}
+
+		other := newVkeyAllowedAssignment(allowed, 0)
+		other.VkeyHash = 9
+		assert.Error(test.IsSolved(circuit, other, ecc.BN254.ScalarField()), "vkey not allowed")
+	}
+
+	moved := newVkeyAllowedAssignment(vkeyHashes, 1)
+	moved.Index = 0
+	assert.Error(test.IsSolved(&vkeyAllowedCircuit{Path: make([]frontend.Variable, 64)}, moved, ecc.BN254.ScalarField()), "leaf at another index")
+}
@@ -0,0 +1,99 @@
+package verifier_core
+
+import (
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark/frontend"
+	"math/big"
+)
+
+// AggregateCircuit verifies the chunk proofs of several programs in one wrap. Each
+// chunk proves the program of its own vkey hash, which stays private: the circuit
+// checks that it is a leaf of the Merkle tree of the allowed vkeys, see
+// utils.VkeysRoot, whose root is the first public input in two 128 bit limbs, high
+// first. A verifier thus binds the proof to a set of programs instead of one, and the
+// committed values digests follow in chunk order.
+type AggregateCircuit[F, E any] struct {
+	VkeysRoot              [2]frontend.Variable `gnark:",public"`
+	CommittedValuesDigests []frontend.Variable  `gnark:",public"`
+	VkeyHashes             []frontend.Variable
+	// VkeyIndices are the leaves of the vkey hashes of the chunks and VkeyPaths the 32
+	// bytes of each sibling of their paths to the root.
+	VkeyIndices []frontend.Variable
+	VkeyPaths   [][]frontend.Variable
+	Chunks      []Chunk[F, E]
+
+	friConfig *utils.FriConfig
+	builder   FieldBuilder[F, E]
+	options   Options
+}
+
+// NewAggregateCircuit validates witnessInput, which must have allowed vkeys, and
+// assigns its chunks with builder.
+func NewAggregateCircuit[F, E any](witnessInput utils.MultiWitnessInput, builder FieldBuilder[F, E], opts Options) (*AggregateCircuit[F, E], error) {
+	if !witnessInput.Aggregated() {
+		return nil, fmt.Errorf("witness has no allowed vkeys to aggregate its chunks against")
+	}
+	err := witnessInput.Validate()
+	if err != nil {
+		return nil, err
+	}
+	allowed, err := witnessInput.AllowedVkeyHashes()
+	if err != nil {
+		return nil, err
+	}
+
+	root := utils.VkeysRoot(allowed)
+	circuit := &AggregateCircuit[F, E]{
+		VkeysRoot: [2]frontend.Variable{new(big.Int).SetBytes(root[:16]), new(big.Int).SetBytes(root[16:])},
+		friConfig: witnessInput.Chunks[0].FriConfig,
+		builder:   builder,
+		options:   opts,
+	}
+	for _, chunk := range witnessInput.Chunks {
+		vkeyHash, _ := new(big.Int).SetString(chunk.VkeyHash, 0)
+		index := utils.VkeyIndex(allowed, vkeyHash)
+		var path []frontend.Variable
+		for _, sibling := range utils.VkeyPath(allowed, index) {
+			for _, b := range sibling {
+				path = append(path, b)
+			}
+		}
+		circuit.VkeyHashes = append(circuit.VkeyHashes, chunk.VkeyHash)
+		circuit.VkeyIndices = append(circuit.VkeyIndices, index)
+		circuit.VkeyPaths = append(circuit.VkeyPaths, path)
+		circuit.CommittedValuesDigests = append(circuit.CommittedValuesDigests, chunk.CommittedValuesDigest)
+		circuit.Chunks = append(circuit.Chunks, NewChunk(chunk, builder))
+	}
+	return circuit, nil
+}
+
+// NbChunks is the number of chunk proofs the circuit verifies.
+func (circuit *AggregateCircuit[F, E]) NbChunks() int {
+	return len(circuit.Chunks)
+}
+
+// VkeyTreeSize is the number of leaves of the tree of allowed vkeys.
+func (circuit *AggregateCircuit[F, E]) VkeyTreeSize() int {
+	return 1 << (len(circuit.VkeyPaths[0]) / 32)
+}
+
+func (circuit *AggregateCircuit[F, E]) Define(api frontend.API) error {
+	file, err := utils.LoadConstraints(circuit.friConfig)
+	if err != nil {
+		return err
+	}
+
+	verifier := NewInterpreter(api, circuit.builder.NewField(api, circuit.options))
+	for i, chunk := range circuit.Chunks {
+		err = utils.AssertVkeyAllowed(api, circuit.VkeysRoot, circuit.VkeyHashes[i], circuit.VkeyIndices[i], circuit.VkeyPaths[i])
+		if err != nil {
+			return fmt.Errorf("chunk %d: %v", i, err)
+		}
+		err = verifier.Verify(file, chunk, circuit.VkeyHashes[i], circuit.CommittedValuesDigests[i])
+		if err != nil {
+			return fmt.Errorf("chunk %d: %v", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package verifier_core
+
+import (
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark/frontend"
+)
+
+// Options select a variant of the verifier circuits. Every variant has its own
+// constraint system and keys; the zero value is the default circuit.
+type Options struct {
+	// LookupRangeCheck range checks with gnark's commitment based (LogUp) range
+	// checker instead of bit decomposition. The proof then carries a commitment.
+	LookupRangeCheck bool
+	// LookupDecomposition splits values with the shared lookup gadget of the
+	// decompose package: the remainders of the KoalaBear reductions, see
+	// koalabear.WithLookupDecomposition, and the truncated digest and limbs of the
+	// public input layouts, which would be bit decomposed otherwise. It needs
+	// LookupRangeCheck; BabyBear reductions ignore it.
+	LookupDecomposition bool
+	// Poseidon2CrossCheck compares every permutation with the native implementation
+	// while the witness is solved, for debugging.
+	Poseidon2CrossCheck bool
+	// Poseidon2ConstantFolding computes the permutations of constant states while
+	// compiling rather than constraining them, see
+	// poseidon2.Poseidon2KoalaBearChip.EnableConstantFolding.
+	Poseidon2ConstantFolding bool
+}
+
+// FieldBuilder creates the Field of a verifier when its circuit is defined, and
+// assigns the felts and exts of the witnesses. It is an interface rather than a func
+// so gnark can compare cloned circuits. Each field package provides one.
+type FieldBuilder[F, E any] interface {
+	NewField(api frontend.API, opts Options) Field[F, E]
+	NewF(value string) F
+	NewE(value []string) E
+}
+
+// Circuit is the wrapper of a single chunk proof, exposing the vkey hash and the
+// committed values digest as public inputs.
+type Circuit[F, E any] struct {
+	VkeyHash              frontend.Variable `gnark:",public"`
+	CommittedValuesDigest frontend.Variable `gnark:",public"`
+	Vars                  []frontend.Variable
+	Felts                 []F
+	Exts                  []E
+
+	friConfig *utils.FriConfig
+	builder   FieldBuilder[F, E]
+	options   Options
+}
+
+// NewCircuit assigns witnessInput with the constructors of builder.
+func NewCircuit[F, E any](witnessInput utils.WitnessInput, builder FieldBuilder[F, E], opts Options) *Circuit[F, E] {
+	chunk := NewChunk(witnessInput, builder)
+	return &Circuit[F, E]{
+		VkeyHash:              witnessInput.VkeyHash,
+		CommittedValuesDigest: witnessInput.CommittedValuesDigest,
+		Vars:                  chunk.Vars,
+		Felts:                 chunk.Felts,
+		Exts:                  chunk.Exts,
+		friConfig:             witnessInput.FriConfig,
+		builder:               builder,
+		options:               opts,
+	}
+}
+
+// NewChunk assigns the vars, felts and exts of witnessInput.
+func NewChunk[F, E any](witnessInput utils.WitnessInput, builder FieldBuilder[F, E]) Chunk[F, E] {
+	vars := make([]frontend.Variable, len(witnessInput.Vars))
+	felts := make([]F, len(witnessInput.Felts))
+	exts := make([]E, len(witnessInput.Exts))
+	for i := 0; i < len(witnessInput.Vars); i++ {
+		vars[i] = frontend.Variable(witnessInput.Vars[i])
+	}
+	for i := 0; i < len(witnessInput.Felts); i++ {
+		felts[i] = builder.NewF(witnessInput.Felts[i])
+	}
+	for i := 0; i < len(witnessInput.Exts); i++ {
+		exts[i] = builder.NewE(witnessInput.Exts[i])
+	}
+	return Chunk[F, E]{
+		Vars:  vars,
+		Felts: felts,
+		Exts:  exts,
+	}
+}
+
+func (circuit *Circuit[F, E]) Define(api frontend.API) error {
+	file, err := utils.LoadConstraints(circuit.friConfig)
+	if err != nil {
+		return err
+	}
+	chunk := Chunk[F, E]{Vars: circuit.Vars, Felts: circuit.Felts, Exts: circuit.Exts}
+	return NewInterpreter(api, circuit.builder.NewField(api, circuit.options)).Verify(file, chunk, circuit.VkeyHash, circuit.CommittedValuesDigest)
+}
@@ -0,0 +1,102 @@
+package verifier_core
+
+import (
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark/frontend"
+)
+
+// PublicValues are the values a verified Pico proof commits to.
+type PublicValues struct {
+	VkeyHash              frontend.Variable
+	CommittedValuesDigest frontend.Variable
+}
+
+// EmbeddedProof is the witness of a Pico proof verified inside an application's own
+// circuit. Embed it as a field of the circuit, built with NewEmbeddedProof for both
+// compiling and assigning, and call Verify in Define. Its values are private; the
+// circuit decides which of the returned PublicValues to expose or constrain.
+type EmbeddedProof[F, E any] struct {
+	VkeyHash              frontend.Variable
+	CommittedValuesDigest frontend.Variable
+	// PublicValues are the bytes of the public values of the witness, for statements.
+	PublicValues []frontend.Variable
+	Chunk        Chunk[F, E]
+
+	statements []Statement
+	// err is the error decoding the public values, returned by Verify.
+	err       error
+	friConfig *utils.FriConfig
+	builder   FieldBuilder[F, E]
+	options   Options
+}
+
+// NewEmbeddedProof assigns witnessInput with builder. Verify also constrains the
+// public values of witnessInput with statements, if any, which need the public_values
+// of the witness.
+func NewEmbeddedProof[F, E any](witnessInput utils.WitnessInput, builder FieldBuilder[F, E], opts Options, statements ...Statement) *EmbeddedProof[F, E] {
+	proof := &EmbeddedProof[F, E]{
+		VkeyHash:              witnessInput.VkeyHash,
+		CommittedValuesDigest: witnessInput.CommittedValuesDigest,
+		Chunk:                 NewChunk(witnessInput, builder),
+		statements:            statements,
+		friConfig:             witnessInput.FriConfig,
+		builder:               builder,
+		options:               opts,
+	}
+	if len(statements) == 0 {
+		return proof
+	}
+	publicValues, err := utils.DecodePublicValues(witnessInput.PublicValues)
+	switch {
+	case err != nil:
+		proof.err = err
+	case len(publicValues) == 0:
+		proof.err = fmt.Errorf("statements need the public values of the witness")
+	}
+	for _, b := range publicValues {
+		proof.PublicValues = append(proof.PublicValues, b)
+	}
+	return proof
+}
+
+// Verify constrains the proof against the constraints, the same ones as the wrapper
+// circuits, then its public values with the statements of the proof, and returns the
+// values it commits to.
+func (p *EmbeddedProof[F, E]) Verify(api frontend.API) (PublicValues, error) {
+	if p.err != nil {
+		return PublicValues{}, p.err
+	}
+	file, err := utils.LoadConstraints(p.friConfig)
+	if err != nil {
+		return PublicValues{}, err
+	}
+	err = NewInterpreter(api, p.builder.NewField(api, p.options)).Verify(file, p.Chunk, p.VkeyHash, p.CommittedValuesDigest)
+	if err != nil {
+		return PublicValues{}, err
+	}
+	err = p.constrainStatements(api)
+	if err != nil {
+		return PublicValues{}, err
+	}
+	return PublicValues{VkeyHash: p.VkeyHash, CommittedValuesDigest: p.CommittedValuesDigest}, nil
+}
+
+// constrainStatements checks that the public values hash to the committed values
+// digest and constrains them with the statements.
+func (p *EmbeddedProof[F, E]) constrainStatements(api frontend.API) error {
+	if len(p.statements) == 0 {
+		return nil
+	}
+	bytes, values, err := utils.AssertCommittedValues(api, p.CommittedValuesDigest, p.PublicValues)
+	if err != nil {
+		return err
+	}
+	for i, statement := range p.statements {
+		err = statement.Constrain(api, &StatementValues{api: api, bytes: bytes, values: values})
+		if err != nil {
+			return fmt.Errorf("statement %d: %v", i, err)
+		}
+	}
+	return nil
+}
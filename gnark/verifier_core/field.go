@@ -0,0 +1,53 @@
+package verifier_core
+
+import (
+	"github.com/consensys/gnark/frontend"
+)
+
+// Field is the 31 bit field of the recursion proofs a verifier checks, with felts F
+// and degree 4 extension elements E. The field chips (*koalabear.Chip,
+// *babybear.Chip) provide the arithmetic; each verifier package adds the
+// constructors and its Poseidon2 permutation.
+type Field[F, E any] interface {
+	NewF(value string) F
+	NewE(value []string) E
+	Felts2Ext(a, b, c, d F) E
+	// FeltValue and ExtValues return the native variables holding f and e.
+	FeltValue(f F) frontend.Variable
+	ExtValues(e E) [4]frontend.Variable
+	// PermuteOpcode is the opcode of Permute in the constraints, e.g. PermuteKoalaBear.
+	PermuteOpcode() string
+	Permute(state *[16]F)
+
+	RangeCheck(v frontend.Variable, bits int)
+	AddF(a, b F, forceReduce ...bool) F
+	SubF(a, b F) F
+	MulF(a, b F, forceReduce ...bool) F
+	DivF(a, b F) F
+	AddE(a, b E) E
+	AddEF(a E, b F) E
+	SubE(a, b E) E
+	SubEF(a E, b F) E
+	MulE(a, b E) E
+	MulEF(a E, b F) E
+	DivE(a, b E) E
+	DivEF(a E, b F) E
+	NegE(a E) E
+	InvE(a E) E
+	SelectF(cond frontend.Variable, a, b F) F
+	SelectE(cond frontend.Variable, a, b E) E
+	Ext2Felt(e E) [4]F
+	ToBinary(f F) []frontend.Variable
+	AssertIsEqualF(a, b F)
+	AssertNotEqualF(a, b F)
+	AssertIsEqualE(a, b E)
+	ReduceSlow(f F) F
+	ReduceE(e E) E
+}
+
+// Chunk is the witness of a single recursion-layer proof.
+type Chunk[F, E any] struct {
+	Vars  []frontend.Variable
+	Felts []F
+	Exts  []E
+}
@@ -0,0 +1,201 @@
+package verifier_core
+
+import (
+	"fmt"
+	"github.com/brevis-network/pico/gnark/poseidon2"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"strconv"
+)
+
+// Interpreter evaluates constraints.json over field. The chips are shared by all
+// chunks verified in one circuit.
+type Interpreter[F, E any] struct {
+	api     frontend.API
+	hashAPI *poseidon2.Poseidon2Chip
+	field   Field[F, E]
+}
+
+// NewInterpreter returns an interpreter over field, which must be built on api.
+func NewInterpreter[F, E any](api frontend.API, field Field[F, E]) *Interpreter[F, E] {
+	return &Interpreter[F, E]{
+		api:     api,
+		hashAPI: poseidon2.NewChip(api),
+		field:   field,
+	}
+}
+
+// Verify checks one chunk against the constraints, committing to vkeyHash and
+// committedValuesDigest.
+func (it *Interpreter[F, E]) Verify(file *utils.ConstraintsFile, chunk Chunk[F, E], vkeyHash, committedValuesDigest frontend.Variable) error {
+	api := it.api
+	hashAPI := it.hashAPI
+	fieldAPI := it.field
+	constraints := file.Constraints
+
+	vars := make(map[string]frontend.Variable)
+	felts := make(map[string]F)
+	exts := make(map[string]E)
+
+	// Iterate through the witnesses and range check them, if necessary.
+	for i := 0; i < len(chunk.Felts); i++ {
+		fieldAPI.RangeCheck(fieldAPI.FeltValue(chunk.Felts[i]), 31)
+	}
+	for i := 0; i < len(chunk.Exts); i++ {
+		for _, v := range fieldAPI.ExtValues(chunk.Exts[i]) {
+			fieldAPI.RangeCheck(v, 31)
+		}
+	}
+
+	// Iterate through the instructions and handle each opcode.
+	for _, cs := range constraints {
+		switch cs.Opcode {
+		case "ImmV":
+			vars[cs.Args[0][0]] = frontend.Variable(cs.Args[1][0])
+		case "ImmF":
+			felts[cs.Args[0][0]] = fieldAPI.NewF(cs.Args[1][0])
+		case "ImmE":
+			exts[cs.Args[0][0]] = fieldAPI.NewE(cs.Args[1])
+		case "AddV":
+			vars[cs.Args[0][0]] = api.Add(vars[cs.Args[1][0]], vars[cs.Args[2][0]])
+		case "AddF":
+			felts[cs.Args[0][0]] = fieldAPI.AddF(felts[cs.Args[1][0]], felts[cs.Args[2][0]])
+		case "AddE":
+			exts[cs.Args[0][0]] = fieldAPI.AddE(exts[cs.Args[1][0]], exts[cs.Args[2][0]])
+		case "AddEF":
+			exts[cs.Args[0][0]] = fieldAPI.AddEF(exts[cs.Args[1][0]], felts[cs.Args[2][0]])
+		case "SubV":
+			vars[cs.Args[0][0]] = api.Sub(vars[cs.Args[1][0]], vars[cs.Args[2][0]])
+		case "SubF":
+			felts[cs.Args[0][0]] = fieldAPI.SubF(felts[cs.Args[1][0]], felts[cs.Args[2][0]])
+		case "DivF":
+			felts[cs.Args[0][0]] = fieldAPI.DivF(felts[cs.Args[1][0]], felts[cs.Args[2][0]])
+		case "SubE":
+			exts[cs.Args[0][0]] = fieldAPI.SubE(exts[cs.Args[1][0]], exts[cs.Args[2][0]])
+		case "SubEF":
+			exts[cs.Args[0][0]] = fieldAPI.SubEF(exts[cs.Args[1][0]], felts[cs.Args[2][0]])
+		case "MulV":
+			vars[cs.Args[0][0]] = api.Mul(vars[cs.Args[1][0]], vars[cs.Args[2][0]])
+		case "MulF":
+			felts[cs.Args[0][0]] = fieldAPI.MulF(felts[cs.Args[1][0]], felts[cs.Args[2][0]])
+		case "MulE":
+			exts[cs.Args[0][0]] = fieldAPI.MulE(exts[cs.Args[1][0]], exts[cs.Args[2][0]])
+		case "MulEF":
+			exts[cs.Args[0][0]] = fieldAPI.MulEF(exts[cs.Args[1][0]], felts[cs.Args[2][0]])
+		case "DivE":
+			exts[cs.Args[0][0]] = fieldAPI.DivE(exts[cs.Args[1][0]], exts[cs.Args[2][0]])
+		case "DivEF":
+			exts[cs.Args[0][0]] = fieldAPI.DivEF(exts[cs.Args[1][0]], felts[cs.Args[2][0]])
+		case "NegE":
+			exts[cs.Args[0][0]] = fieldAPI.NegE(exts[cs.Args[1][0]])
+		case "InvE":
+			exts[cs.Args[0][0]] = fieldAPI.InvE(exts[cs.Args[1][0]])
+		case "Num2BitsV":
+			numBits, err := strconv.Atoi(cs.Args[2][0])
+			if err != nil {
+				return fmt.Errorf("error converting number of bits to int: %v", err)
+			}
+			bits := api.ToBinary(vars[cs.Args[1][0]], numBits)
+			for i := 0; i < len(cs.Args[0]); i++ {
+				vars[cs.Args[0][i]] = bits[i]
+			}
+		case "Num2BitsF":
+			bits := fieldAPI.ToBinary(felts[cs.Args[1][0]])
+			for i := 0; i < len(cs.Args[0]); i++ {
+				vars[cs.Args[0][i]] = bits[i]
+			}
+		case "Permute":
+			if api.Compiler().Field().Cmp(ecc.BN254.ScalarField()) != 0 {
+				return fmt.Errorf("opcode Permute uses the BN254 poseidon2 parameters and cannot run over field %s", api.Compiler().Field())
+			}
+			state := [3]frontend.Variable{vars[cs.Args[0][0]], vars[cs.Args[1][0]], vars[cs.Args[2][0]]}
+			hashAPI.PermuteMut(&state)
+			vars[cs.Args[0][0]] = state[0]
+			vars[cs.Args[1][0]] = state[1]
+			vars[cs.Args[2][0]] = state[2]
+		case fieldAPI.PermuteOpcode():
+			var state [16]F
+			for i := 0; i < 16; i++ {
+				state[i] = felts[cs.Args[i][0]]
+			}
+			fieldAPI.Permute(&state)
+			for i := 0; i < 16; i++ {
+				felts[cs.Args[i][0]] = state[i]
+			}
+		case "SelectV":
+			vars[cs.Args[0][0]] = api.Select(vars[cs.Args[1][0]], vars[cs.Args[2][0]], vars[cs.Args[3][0]])
+		case "SelectF":
+			felts[cs.Args[0][0]] = fieldAPI.SelectF(vars[cs.Args[1][0]], felts[cs.Args[2][0]], felts[cs.Args[3][0]])
+		case "SelectE":
+			exts[cs.Args[0][0]] = fieldAPI.SelectE(vars[cs.Args[1][0]], exts[cs.Args[2][0]], exts[cs.Args[3][0]])
+		case "Ext2Felt":
+			out := fieldAPI.Ext2Felt(exts[cs.Args[4][0]])
+			for i := 0; i < 4; i++ {
+				felts[cs.Args[i][0]] = out[i]
+			}
+		case "AssertEqV":
+			api.AssertIsEqual(vars[cs.Args[0][0]], vars[cs.Args[1][0]])
+		case "AssertEqF":
+			fieldAPI.AssertIsEqualF(felts[cs.Args[0][0]], felts[cs.Args[1][0]])
+		case "AssertNeF":
+			fieldAPI.AssertNotEqualF(felts[cs.Args[0][0]], felts[cs.Args[1][0]])
+		case "AssertEqE":
+			fieldAPI.AssertIsEqualE(exts[cs.Args[0][0]], exts[cs.Args[1][0]])
+		case "PrintV":
+			api.Println(vars[cs.Args[0][0]])
+		case "PrintF":
+			f := fieldAPI.ReduceSlow(felts[cs.Args[0][0]])
+			api.Println(fieldAPI.FeltValue(f))
+		case "PrintE":
+			e := fieldAPI.ExtValues(fieldAPI.ReduceE(exts[cs.Args[0][0]]))
+			api.Println(e[0])
+			api.Println(e[1])
+			api.Println(e[2])
+			api.Println(e[3])
+		case "WitnessV":
+			i, err := witnessIndex(cs.Args[1][0], len(chunk.Vars))
+			if err != nil {
+				return err
+			}
+			vars[cs.Args[0][0]] = chunk.Vars[i]
+		case "WitnessF":
+			i, err := witnessIndex(cs.Args[1][0], len(chunk.Felts))
+			if err != nil {
+				return err
+			}
+			felts[cs.Args[0][0]] = chunk.Felts[i]
+		case "WitnessE":
+			i, err := witnessIndex(cs.Args[1][0], len(chunk.Exts))
+			if err != nil {
+				return err
+			}
+			exts[cs.Args[0][0]] = chunk.Exts[i]
+		case "CommitVkeyHash":
+			element := vars[cs.Args[0][0]]
+			api.AssertIsEqual(vkeyHash, element)
+		case "CommitCommitedValuesDigest":
+			element := vars[cs.Args[0][0]]
+			api.AssertIsEqual(committedValuesDigest, element)
+		case "CircuitFelts2Ext":
+			exts[cs.Args[0][0]] = fieldAPI.Felts2Ext(felts[cs.Args[1][0]], felts[cs.Args[2][0]], felts[cs.Args[3][0]], felts[cs.Args[4][0]])
+		case "CircuitFelt2Var":
+			vars[cs.Args[0][0]] = fieldAPI.FeltValue(fieldAPI.ReduceSlow(felts[cs.Args[1][0]]))
+		case "ReduceE":
+			exts[cs.Args[0][0]] = fieldAPI.ReduceE(exts[cs.Args[0][0]])
+		default:
+			return fmt.Errorf("unhandled opcode: %s", cs.Opcode)
+		}
+	}
+
+	return nil
+}
+
+// witnessIndex parses the index of a Witness opcode into a witness array of size n.
+func witnessIndex(index string, n int) (int, error) {
+	i, err := strconv.Atoi(index)
+	if err != nil || i < 0 || i >= n {
+		return 0, fmt.Errorf("witness index %s out of range, the witness has %d values", index, n)
+	}
+	return i, nil
+}
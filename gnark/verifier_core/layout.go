@@ -0,0 +1,90 @@
+package verifier_core
+
+import (
+	"fmt"
+	"github.com/brevis-network/pico/gnark/decompose"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark/frontend"
+	"math/big"
+)
+
+// LayoutCircuit is a single chunk wrapper with a utils.PublicLayout other than the
+// default. The vkey hash, committed values digest and public values are private,
+// PublicInputs exposes them.
+type LayoutCircuit[F, E any] struct {
+	PublicInputs          []frontend.Variable `gnark:",public"`
+	VkeyHash              frontend.Variable
+	CommittedValuesDigest frontend.Variable
+	// PublicValues are the bytes of the public values, for layouts exposing them.
+	PublicValues []frontend.Variable
+	Chunk        Chunk[F, E]
+
+	layout    utils.PublicLayout
+	friConfig *utils.FriConfig
+	builder   FieldBuilder[F, E]
+	options   Options
+}
+
+// NewLayoutCircuit assigns witnessInput with builder and lays its public inputs out
+// as layout.
+func NewLayoutCircuit[F, E any](witnessInput utils.WitnessInput, layout utils.PublicLayout, builder FieldBuilder[F, E], opts Options) (*LayoutCircuit[F, E], error) {
+	vkeyHash, ok := new(big.Int).SetString(witnessInput.VkeyHash, 0)
+	if !ok {
+		return nil, fmt.Errorf("invalid vkey hash: %q", witnessInput.VkeyHash)
+	}
+	err := layout.CheckVkeyHash(vkeyHash)
+	if err != nil {
+		return nil, err
+	}
+	digest, ok := new(big.Int).SetString(witnessInput.CommittedValuesDigest, 0)
+	if !ok {
+		return nil, fmt.Errorf("invalid committed values digest: %q", witnessInput.CommittedValuesDigest)
+	}
+	circuit := &LayoutCircuit[F, E]{
+		VkeyHash:              witnessInput.VkeyHash,
+		CommittedValuesDigest: witnessInput.CommittedValuesDigest,
+		Chunk:                 NewChunk(witnessInput, builder),
+		layout:                layout,
+		friConfig:             witnessInput.FriConfig,
+		builder:               builder,
+		options:               opts,
+	}
+	public := layout.Pack(vkeyHash, digest)
+	if layout.PublicValues > 0 {
+		var publicValues []byte
+		publicValues, err = utils.DecodePublicValues(witnessInput.PublicValues)
+		if err != nil {
+			return nil, err
+		}
+		if utils.CommittedValuesDigest(publicValues).Cmp(digest) != 0 {
+			return nil, fmt.Errorf("public values do not hash to the committed values digest %s", witnessInput.CommittedValuesDigest)
+		}
+		public, err = layout.PackPublicValues(vkeyHash, publicValues)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range publicValues {
+			circuit.PublicValues = append(circuit.PublicValues, b)
+		}
+	}
+	for _, v := range public {
+		circuit.PublicInputs = append(circuit.PublicInputs, v)
+	}
+	return circuit, nil
+}
+
+func (circuit *LayoutCircuit[F, E]) Define(api frontend.API) error {
+	file, err := utils.LoadConstraints(circuit.friConfig)
+	if err != nil {
+		return err
+	}
+	err = NewInterpreter(api, circuit.builder.NewField(api, circuit.options)).Verify(file, circuit.Chunk, circuit.VkeyHash, circuit.CommittedValuesDigest)
+	if err != nil {
+		return err
+	}
+	var d *decompose.Decomposer
+	if circuit.options.LookupDecomposition {
+		d = decompose.New(api)
+	}
+	return circuit.layout.Constrain(api, d, circuit.PublicInputs, circuit.VkeyHash, circuit.CommittedValuesDigest, circuit.PublicValues)
+}
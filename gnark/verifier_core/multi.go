@@ -0,0 +1,62 @@
+package verifier_core
+
+import (
+	"fmt"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark/frontend"
+)
+
+// MultiCircuit verifies several chunk proofs of the same program in one wrap, so a
+// large execution needs a single on-chain proof. Every chunk runs the constraints
+// against its own witness. The chunks share the vkey hash and each one exposes its
+// committed values digest as a public input, in chunk order.
+type MultiCircuit[F, E any] struct {
+	VkeyHash               frontend.Variable   `gnark:",public"`
+	CommittedValuesDigests []frontend.Variable `gnark:",public"`
+	Chunks                 []Chunk[F, E]
+
+	friConfig *utils.FriConfig
+	builder   FieldBuilder[F, E]
+	options   Options
+}
+
+// NewMultiCircuit validates witnessInput and assigns its chunks with builder.
+func NewMultiCircuit[F, E any](witnessInput utils.MultiWitnessInput, builder FieldBuilder[F, E], opts Options) (*MultiCircuit[F, E], error) {
+	err := witnessInput.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	circuit := &MultiCircuit[F, E]{
+		VkeyHash:  witnessInput.Chunks[0].VkeyHash,
+		friConfig: witnessInput.Chunks[0].FriConfig,
+		builder:   builder,
+		options:   opts,
+	}
+	for _, chunk := range witnessInput.Chunks {
+		circuit.CommittedValuesDigests = append(circuit.CommittedValuesDigests, chunk.CommittedValuesDigest)
+		circuit.Chunks = append(circuit.Chunks, NewChunk(chunk, builder))
+	}
+	return circuit, nil
+}
+
+// NbChunks is the number of chunk proofs the circuit verifies.
+func (circuit *MultiCircuit[F, E]) NbChunks() int {
+	return len(circuit.Chunks)
+}
+
+func (circuit *MultiCircuit[F, E]) Define(api frontend.API) error {
+	file, err := utils.LoadConstraints(circuit.friConfig)
+	if err != nil {
+		return err
+	}
+
+	verifier := NewInterpreter(api, circuit.builder.NewField(api, circuit.options))
+	for i, chunk := range circuit.Chunks {
+		err = verifier.Verify(file, chunk, circuit.VkeyHash, circuit.CommittedValuesDigests[i])
+		if err != nil {
+			return fmt.Errorf("chunk %d: %v", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,386 @@
+package verifier_core
+
+import (
+	"fmt"
+	"github.com/brevis-network/pico/gnark/poseidon2"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"math/big"
+	"strconv"
+)
+
+// NativeField is a verifier field outside the circuit: felts modulo Modulus and the
+// degree 4 extension F[X]/(X^4 - W), with the Poseidon2 permutation of the field.
+type NativeField struct {
+	Modulus uint64
+	W       uint64
+	// PermuteOpcode is the opcode of Permute in the constraints, as Field.PermuteOpcode.
+	PermuteOpcode string
+	Permute       poseidon2.NativePermutation
+}
+
+// Commitments are the public values the constraints commit a chunk to.
+type Commitments struct {
+	VkeyHash              *big.Int
+	CommittedValuesDigest *big.Int
+}
+
+// Evaluate runs the constraints over the witness of one chunk outside the circuit, in
+// a fraction of the time of solving it. It fails on the first constraint the witness
+// does not satisfy and returns the public values the constraints commit to.
+func (f NativeField) Evaluate(file *utils.ConstraintsFile, input utils.WitnessInput) (Commitments, error) {
+	var commitments Commitments
+	witness, err := f.newNativeChunk(input)
+	if err != nil {
+		return commitments, err
+	}
+
+	vars := make(map[string]fr.Element)
+	felts := make(map[string]uint64)
+	exts := make(map[string][4]uint64)
+	for i, cs := range file.Constraints {
+		err = f.evaluate(cs, witness, vars, felts, exts, &commitments)
+		if err != nil {
+			return commitments, fmt.Errorf("constraint %d (%s): %v", i, cs.Opcode, err)
+		}
+	}
+	if commitments.VkeyHash == nil || commitments.CommittedValuesDigest == nil {
+		return commitments, fmt.Errorf("constraints do not commit to the vkey hash and committed values digest")
+	}
+	return commitments, nil
+}
+
+// nativeChunk is a chunk witness as native values.
+type nativeChunk struct {
+	vars  []fr.Element
+	felts []uint64
+	exts  [][4]uint64
+}
+
+func (f NativeField) newNativeChunk(input utils.WitnessInput) (nativeChunk, error) {
+	var chunk nativeChunk
+	chunk.vars = make([]fr.Element, len(input.Vars))
+	for i, v := range input.Vars {
+		_, err := chunk.vars[i].SetString(v)
+		if err != nil {
+			return chunk, fmt.Errorf("invalid var %d: %v", i, err)
+		}
+	}
+	chunk.felts = make([]uint64, len(input.Felts))
+	for i, v := range input.Felts {
+		felt, err := f.parseFelt(v)
+		if err != nil {
+			return chunk, fmt.Errorf("felt %d: %v", i, err)
+		}
+		chunk.felts[i] = felt
+	}
+	chunk.exts = make([][4]uint64, len(input.Exts))
+	for i, ext := range input.Exts {
+		if len(ext) != 4 {
+			return chunk, fmt.Errorf("ext %d has %d felts, expected 4", i, len(ext))
+		}
+		for j, v := range ext {
+			felt, err := f.parseFelt(v)
+			if err != nil {
+				return chunk, fmt.Errorf("ext %d: %v", i, err)
+			}
+			chunk.exts[i][j] = felt
+		}
+	}
+	return chunk, nil
+}
+
+// parseFelt parses a felt, which the circuit range checks to 31 bits.
+func (f NativeField) parseFelt(value string) (uint64, error) {
+	felt, err := strconv.ParseUint(value, 10, 31)
+	if err != nil {
+		return 0, fmt.Errorf("invalid felt %q", value)
+	}
+	return felt % f.Modulus, nil
+}
+
+func (f NativeField) evaluate(cs utils.Constraint, chunk nativeChunk, vars map[string]fr.Element, felts map[string]uint64, exts map[string][4]uint64, commitments *Commitments) error {
+	arg := func(i int) string {
+		return cs.Args[i][0]
+	}
+	switch cs.Opcode {
+	case "ImmV":
+		var v fr.Element
+		_, err := v.SetString(arg(1))
+		if err != nil {
+			return err
+		}
+		vars[arg(0)] = v
+	case "ImmF":
+		felt, err := strconv.ParseUint(arg(1), 10, 32)
+		if err != nil {
+			return err
+		}
+		felts[arg(0)] = felt % f.Modulus
+	case "ImmE", "CircuitFelts2Ext":
+		var e [4]uint64
+		for i := range e {
+			if cs.Opcode == "CircuitFelts2Ext" {
+				e[i] = felts[arg(i+1)]
+				continue
+			}
+			felt, err := strconv.ParseUint(cs.Args[1][i], 10, 32)
+			if err != nil {
+				return err
+			}
+			e[i] = felt % f.Modulus
+		}
+		exts[arg(0)] = e
+	case "AddV", "SubV", "MulV":
+		a, b := vars[arg(1)], vars[arg(2)]
+		var res fr.Element
+		switch cs.Opcode {
+		case "AddV":
+			res.Add(&a, &b)
+		case "SubV":
+			res.Sub(&a, &b)
+		default:
+			res.Mul(&a, &b)
+		}
+		vars[arg(0)] = res
+	case "AddF":
+		felts[arg(0)] = f.add(felts[arg(1)], felts[arg(2)])
+	case "SubF":
+		felts[arg(0)] = f.sub(felts[arg(1)], felts[arg(2)])
+	case "MulF":
+		felts[arg(0)] = f.mul(felts[arg(1)], felts[arg(2)])
+	case "DivF":
+		inv, err := f.inverse(felts[arg(2)])
+		if err != nil {
+			return err
+		}
+		felts[arg(0)] = f.mul(felts[arg(1)], inv)
+	case "AddE", "SubE", "MulE", "DivE":
+		a, b := exts[arg(1)], exts[arg(2)]
+		switch cs.Opcode {
+		case "AddE":
+			exts[arg(0)] = f.addE(a, b)
+		case "SubE":
+			exts[arg(0)] = f.addE(a, f.negE(b))
+		case "MulE":
+			exts[arg(0)] = f.mulE(a, b)
+		default:
+			inv, err := f.inverseE(b)
+			if err != nil {
+				return err
+			}
+			exts[arg(0)] = f.mulE(a, inv)
+		}
+	case "AddEF":
+		a := exts[arg(1)]
+		a[0] = f.add(a[0], felts[arg(2)])
+		exts[arg(0)] = a
+	case "SubEF":
+		a := exts[arg(1)]
+		a[0] = f.sub(a[0], felts[arg(2)])
+		exts[arg(0)] = a
+	case "MulEF", "DivEF":
+		b := felts[arg(2)]
+		if cs.Opcode == "DivEF" {
+			inv, err := f.inverse(b)
+			if err != nil {
+				return err
+			}
+			b = inv
+		}
+		a := exts[arg(1)]
+		for i := range a {
+			a[i] = f.mul(a[i], b)
+		}
+		exts[arg(0)] = a
+	case "NegE":
+		exts[arg(0)] = f.negE(exts[arg(1)])
+	case "InvE":
+		inv, err := f.inverseE(exts[arg(1)])
+		if err != nil {
+			return err
+		}
+		exts[arg(0)] = inv
+	case "Num2BitsV":
+		numBits, err := strconv.Atoi(arg(2))
+		if err != nil {
+			return fmt.Errorf("error converting number of bits to int: %v", err)
+		}
+		v := vars[arg(1)]
+		value := v.BigInt(new(big.Int))
+		if value.BitLen() > numBits {
+			return fmt.Errorf("var %s does not fit in %d bits", value, numBits)
+		}
+		for i, name := range cs.Args[0] {
+			var bit fr.Element
+			bit.SetUint64(uint64(value.Bit(i)))
+			vars[name] = bit
+		}
+	case "Num2BitsF":
+		felt := felts[arg(1)]
+		for i, name := range cs.Args[0] {
+			var bit fr.Element
+			bit.SetUint64(felt >> i & 1)
+			vars[name] = bit
+		}
+	case "Permute":
+		state := [3]fr.Element{vars[arg(0)], vars[arg(1)], vars[arg(2)]}
+		poseidon2.PermuteBN254(&state)
+		for i := range state {
+			vars[arg(i)] = state[i]
+		}
+	case f.PermuteOpcode:
+		var state [poseidon2.FieldWidth]uint32
+		for i := range state {
+			state[i] = uint32(felts[arg(i)])
+		}
+		f.Permute(&state)
+		for i := range state {
+			felts[arg(i)] = uint64(state[i])
+		}
+	case "SelectV", "SelectF", "SelectE":
+		cond := vars[arg(1)]
+		if !cond.IsZero() && !cond.IsOne() {
+			return fmt.Errorf("condition %s is not a bit", cond.String())
+		}
+		choice := 3
+		if cond.IsOne() {
+			choice = 2
+		}
+		switch cs.Opcode {
+		case "SelectV":
+			vars[arg(0)] = vars[arg(choice)]
+		case "SelectF":
+			felts[arg(0)] = felts[arg(choice)]
+		default:
+			exts[arg(0)] = exts[arg(choice)]
+		}
+	case "Ext2Felt":
+		e := exts[arg(4)]
+		for i := range e {
+			felts[arg(i)] = e[i]
+		}
+	case "AssertEqV":
+		a, b := vars[arg(0)], vars[arg(1)]
+		if !a.Equal(&b) {
+			return fmt.Errorf("%s != %s", a.String(), b.String())
+		}
+	case "AssertEqF":
+		if felts[arg(0)] != felts[arg(1)] {
+			return fmt.Errorf("%d != %d", felts[arg(0)], felts[arg(1)])
+		}
+	case "AssertNeF":
+		if felts[arg(0)] == felts[arg(1)] {
+			return fmt.Errorf("both are %d", felts[arg(0)])
+		}
+	case "AssertEqE":
+		if exts[arg(0)] != exts[arg(1)] {
+			return fmt.Errorf("%v != %v", exts[arg(0)], exts[arg(1)])
+		}
+	case "PrintV", "PrintF", "PrintE", "ReduceE":
+	case "WitnessV":
+		i, err := witnessIndex(arg(1), len(chunk.vars))
+		if err != nil {
+			return err
+		}
+		vars[arg(0)] = chunk.vars[i]
+	case "WitnessF":
+		i, err := witnessIndex(arg(1), len(chunk.felts))
+		if err != nil {
+			return err
+		}
+		felts[arg(0)] = chunk.felts[i]
+	case "WitnessE":
+		i, err := witnessIndex(arg(1), len(chunk.exts))
+		if err != nil {
+			return err
+		}
+		exts[arg(0)] = chunk.exts[i]
+	case "CommitVkeyHash":
+		v := vars[arg(0)]
+		commitments.VkeyHash = v.BigInt(new(big.Int))
+	case "CommitCommitedValuesDigest":
+		v := vars[arg(0)]
+		commitments.CommittedValuesDigest = v.BigInt(new(big.Int))
+	case "CircuitFelt2Var":
+		var v fr.Element
+		v.SetUint64(felts[arg(1)])
+		vars[arg(0)] = v
+	default:
+		return fmt.Errorf("unhandled opcode: %s", cs.Opcode)
+	}
+	return nil
+}
+
+func (f NativeField) add(a, b uint64) uint64 {
+	return (a + b) % f.Modulus
+}
+
+func (f NativeField) sub(a, b uint64) uint64 {
+	return (a + f.Modulus - b) % f.Modulus
+}
+
+func (f NativeField) mul(a, b uint64) uint64 {
+	return a * b % f.Modulus
+}
+
+func (f NativeField) inverse(a uint64) (uint64, error) {
+	if a == 0 {
+		return 0, fmt.Errorf("inverse of zero")
+	}
+	res, base := uint64(1), a
+	for e := f.Modulus - 2; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			res = f.mul(res, base)
+		}
+		base = f.mul(base, base)
+	}
+	return res, nil
+}
+
+func (f NativeField) addE(a, b [4]uint64) [4]uint64 {
+	for i := range a {
+		a[i] = f.add(a[i], b[i])
+	}
+	return a
+}
+
+func (f NativeField) negE(a [4]uint64) [4]uint64 {
+	for i := range a {
+		a[i] = f.sub(0, a[i])
+	}
+	return a
+}
+
+func (f NativeField) mulE(a, b [4]uint64) [4]uint64 {
+	var res [4]uint64
+	for i := range a {
+		for j := range b {
+			v := f.mul(a[i], b[j])
+			if i+j >= 4 {
+				res[i+j-4] = f.add(res[i+j-4], f.mul(v, f.W))
+			} else {
+				res[i+j] = f.add(res[i+j], v)
+			}
+		}
+	}
+	return res
+}
+
+// inverseE is a^(p^4-2).
+func (f NativeField) inverseE(a [4]uint64) ([4]uint64, error) {
+	if a == [4]uint64{} {
+		return a, fmt.Errorf("inverse of zero")
+	}
+	p := new(big.Int).SetUint64(f.Modulus)
+	e := new(big.Int).Exp(p, big.NewInt(4), nil)
+	e.Sub(e, big.NewInt(2))
+	res := [4]uint64{1}
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		res = f.mulE(res, res)
+		if e.Bit(i) == 1 {
+			res = f.mulE(res, a)
+		}
+	}
+	return res, nil
+}
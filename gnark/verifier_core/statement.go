@@ -0,0 +1,96 @@
+package verifier_core
+
+import (
+	"fmt"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/math/uints"
+)
+
+// abiWordSize is the size of the words of the ABI encoding of the public values.
+const abiWordSize = 32
+
+// Statement is an application constraint on the public values of a verified Pico
+// proof, e.g. that an output block number is above a bound, proved in the same Groth16
+// proof as the Pico proof itself. Pass statements to NewEmbeddedProof.
+type Statement interface {
+	Constrain(api frontend.API, values *StatementValues) error
+}
+
+// StatementFunc is a Statement defined by a function.
+type StatementFunc func(api frontend.API, values *StatementValues) error
+
+func (f StatementFunc) Constrain(api frontend.API, values *StatementValues) error {
+	return f(api, values)
+}
+
+// StatementValues decode the public values a Statement constrains, the bytes whose
+// sha256 the committed values digest of the proof is, in the circuit. The accessors
+// read the words of their ABI encoding, as abi.encode writes them.
+type StatementValues struct {
+	api    frontend.API
+	bytes  *uints.Bytes
+	values []uints.U8
+}
+
+// Len is the number of bytes of the public values.
+func (v *StatementValues) Len() int {
+	return len(v.values)
+}
+
+// Byte returns the byte at i.
+func (v *StatementValues) Byte(i int) frontend.Variable {
+	return v.bytes.Value(v.values[i])
+}
+
+// Uint returns word, the index of a 32 byte word, as an ABI uint of bits bits and
+// asserts that its higher bits are zero. bits is a multiple of 8 up to 248, so the
+// value fits the field; Bytes32 reads wider words.
+func (v *StatementValues) Uint(word, bits int) (frontend.Variable, error) {
+	if bits <= 0 || bits%8 != 0 || bits > 248 {
+		return nil, fmt.Errorf("invalid uint size: %d bits, expected a multiple of 8 up to 248", bits)
+	}
+	bytes, err := v.word(word)
+	if err != nil {
+		return nil, err
+	}
+	high := abiWordSize - bits/8
+	for _, b := range bytes[:high] {
+		v.api.AssertIsEqual(v.bytes.Value(b), 0)
+	}
+	return v.pack(bytes[high:]), nil
+}
+
+// Bool returns word as an ABI bool, asserting that it is 0 or 1.
+func (v *StatementValues) Bool(word int) (frontend.Variable, error) {
+	res, err := v.Uint(word, 8)
+	if err != nil {
+		return nil, err
+	}
+	v.api.AssertIsBoolean(res)
+	return res, nil
+}
+
+// Bytes32 returns word as two 128 bit limbs, high first.
+func (v *StatementValues) Bytes32(word int) ([2]frontend.Variable, error) {
+	bytes, err := v.word(word)
+	if err != nil {
+		return [2]frontend.Variable{}, err
+	}
+	return [2]frontend.Variable{v.pack(bytes[:16]), v.pack(bytes[16:])}, nil
+}
+
+func (v *StatementValues) word(word int) ([]uints.U8, error) {
+	if word < 0 || abiWordSize*(word+1) > len(v.values) {
+		return nil, fmt.Errorf("public values have %d bytes, no word %d", len(v.values), word)
+	}
+	return v.values[abiWordSize*word : abiWordSize*(word+1)], nil
+}
+
+// pack returns the big endian value of bytes.
+func (v *StatementValues) pack(bytes []uints.U8) frontend.Variable {
+	var res frontend.Variable = 0
+	for _, b := range bytes {
+		res = v.api.Add(v.api.Mul(res, 256), v.bytes.Value(b))
+	}
+	return res
+}
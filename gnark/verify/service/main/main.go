@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/verify/service"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func main() {
+	httpPort := flag.Int("httpport", 9098, "http listening port")
+	vkPath := flag.String("vk", "./data/vm_vk", "path of the plaintext verifying key, read once at start")
+	hashToField := flag.String("hash-to-field", "keccak256", "hash of the commitment of legacy proofs, which do not record it: keccak256/sha256/poseidon2")
+	expectProver := flag.String("expect-prover", "", "comma separated signers, ed25519 public keys or ethereum addresses, one of which must have signed the proofs (any or none if empty)")
+	flag.Parse()
+
+	vk, err := service.ReadVerifyingKey(*vkPath)
+	if err != nil {
+		log.Fatalf("fail to read vk %s: %v", *vkPath, err)
+	}
+	config := service.Config{HashToField: *hashToField}
+	for _, signer := range strings.Split(*expectProver, ",") {
+		if signer = strings.TrimSpace(signer); signer != "" {
+			config.ExpectedProvers = append(config.ExpectedProvers, signer)
+		}
+	}
+	server := &http.Server{
+		Addr:              fmt.Sprintf("0.0.0.0:%d", *httpPort),
+		Handler:           service.New(vk, config).Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		_ = server.Shutdown(context.Background())
+	}()
+	log.Printf("verifying proofs of %s with %d public inputs on port %d", *vkPath, vk.NbPublicInputs(), *httpPort)
+	err = server.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("fail to serve: %v", err)
+	}
+}
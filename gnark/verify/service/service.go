@@ -0,0 +1,153 @@
+// Package service serves the verification of Pico wrapper proofs over http with
+// package verify alone: the vk is read once and kept in memory, and neither the pk,
+// the circuit nor the prover are built in, so API gateways can check incoming proofs
+// at high rates on small machines.
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/brevis-network/pico/gnark/verify"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// MaxProofSize bounds the body of /verify, far above the json proof of a wrapper.
+const MaxProofSize = 64 << 10
+
+// Config is how a Service checks proofs besides their vk.
+type Config struct {
+	// HashToField hashes the commitments of legacy proofs, which do not record it:
+	// keccak256 if empty, sha256 or poseidon2.
+	HashToField string
+	// ExpectedProvers are the signers, ed25519 public keys or ethereum addresses, one
+	// of which must have signed the proofs; proofs need not be signed if empty.
+	ExpectedProvers []string
+}
+
+// Result is the answer of /verify.
+type Result struct {
+	Valid bool `json:"valid"`
+	// PublicInputs are the decimal public inputs of a valid proof.
+	PublicInputs []string `json:"public_inputs,omitempty"`
+	// Signer is the prover that signed a valid proof, empty if it is not signed.
+	Signer string `json:"signer,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Service verifies proofs against one vk.
+type Service struct {
+	vk     *verify.VerifyingKey
+	config Config
+
+	valid, invalid, malformed atomic.Int64
+}
+
+// New returns the service verifying proofs against vk.
+func New(vk *verify.VerifyingKey, config Config) *Service {
+	return &Service{vk: vk, config: config}
+}
+
+// ReadVerifyingKey reads the plaintext vk file at path, written by setup.
+func ReadVerifyingKey(path string) (*verify.VerifyingKey, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("fail to open vk: %v", err)
+	}
+	defer f.Close()
+	return verify.ReadVerifyingKey(f)
+}
+
+// Handler serves POST /verify, GET /healthz and GET /metrics.
+func (s *Service) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /verify", s.serveVerify)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	})
+	mux.HandleFunc("GET /metrics", s.serveMetrics)
+	return mux
+}
+
+// serveVerify checks the proof file in the body, in legacy or json format, and
+// answers with its Result: 200 if it is valid, 422 if it does not verify and 400 if
+// it is not a proof.
+func (s *Service) serveVerify(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxProofSize))
+	if err != nil {
+		s.malformed.Add(1)
+		writeResult(w, http.StatusBadRequest, Result{Error: fmt.Sprintf("fail to read proof: %v", err)})
+		return
+	}
+	proof, err := verify.ParseProof(data)
+	if err != nil {
+		s.malformed.Add(1)
+		writeResult(w, http.StatusBadRequest, Result{Error: err.Error()})
+		return
+	}
+	err = s.Verify(proof)
+	if err != nil {
+		s.invalid.Add(1)
+		writeResult(w, http.StatusUnprocessableEntity, Result{Error: err.Error()})
+		return
+	}
+	s.valid.Add(1)
+	res := Result{Valid: true}
+	for _, input := range proof.PublicInputs {
+		res.PublicInputs = append(res.PublicInputs, input.String())
+	}
+	if proof.Signature != nil {
+		res.Signer = proof.Signature.Signer
+	}
+	writeResult(w, http.StatusOK, res)
+}
+
+// Verify checks proof against the vk, then its signature if it has one or the config
+// expects one.
+func (s *Service) Verify(proof *verify.Proof) error {
+	if proof.HashToField == "" {
+		proof.HashToField = s.config.HashToField
+	}
+	err := verify.Verify(s.vk, proof)
+	if err != nil {
+		return err
+	}
+	if proof.Signature != nil {
+		err = verify.VerifySignature(proof, proof.Signature)
+		if err != nil {
+			return err
+		}
+	}
+	if len(s.config.ExpectedProvers) == 0 {
+		return nil
+	}
+	if proof.Signature == nil {
+		return fmt.Errorf("%w: proof is not signed, expected a signature of %s", verify.ErrInvalidSignature, strings.Join(s.config.ExpectedProvers, ","))
+	}
+	for _, signer := range s.config.ExpectedProvers {
+		if strings.EqualFold(signer, proof.Signature.Signer) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: proof signed by %s, expected %s", verify.ErrInvalidSignature, proof.Signature.Signer, strings.Join(s.config.ExpectedProvers, ","))
+}
+
+// serveMetrics answers with the proofs checked, by outcome, in the prometheus text
+// format.
+func (s *Service) serveMetrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintln(w, "# HELP pico_verifications_total Proofs checked, by outcome: valid, invalid or malformed.")
+	fmt.Fprintln(w, "# TYPE pico_verifications_total counter")
+	fmt.Fprintf(w, "pico_verifications_total{outcome=\"valid\"} %d\n", s.valid.Load())
+	fmt.Fprintf(w, "pico_verifications_total{outcome=\"invalid\"} %d\n", s.invalid.Load())
+	fmt.Fprintf(w, "pico_verifications_total{outcome=\"malformed\"} %d\n", s.malformed.Load())
+}
+
+func writeResult(w http.ResponseWriter, status int, res Result) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type wrapperCircuit struct {
+	VkeyHash              frontend.Variable `gnark:",public"`
+	CommittedValuesDigest frontend.Variable `gnark:",public"`
+	X                     frontend.Variable
+}
+
+func (c *wrapperCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(c.CommittedValuesDigest, api.Mul(c.VkeyHash, c.X))
+	return nil
+}
+
+func TestService(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &wrapperCircuit{})
+	assert.NoError(err)
+	pk, gnarkVk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	vkPath := filepath.Join(t.TempDir(), "vm_vk")
+	assert.NoError(utils.WriteVerifyingKey(vkPath, gnarkVk))
+	vk, err := ReadVerifyingKey(vkPath)
+	assert.NoError(err)
+
+	fullWitness, err := frontend.NewWitness(&wrapperCircuit{VkeyHash: 3, CommittedValuesDigest: 21, X: 7}, ecc.BN254.ScalarField())
+	assert.NoError(err)
+	pubWitness, err := fullWitness.Public()
+	assert.NoError(err)
+	proof, err := groth16.Prove(ccs, pk, fullWitness)
+	assert.NoError(err)
+	jsonProof, err := utils.FormatProof(utils.ProofFormatJson, proof, pubWitness)
+	assert.NoError(err)
+	legacyProof, err := utils.FormatProof(utils.ProofFormatLegacy, proof, pubWitness)
+	assert.NoError(err)
+
+	handler := New(vk, Config{}).Handler()
+	post := func(h http.Handler, body []byte) (int, Result) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/verify", bytes.NewReader(body)))
+		var res Result
+		assert.NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+		return rec.Code, res
+	}
+	for _, data := range [][]byte{jsonProof, legacyProof} {
+		code, res := post(handler, data)
+		assert.Equal(http.StatusOK, code)
+		assert.True(res.Valid)
+		assert.Equal([]string{"3", "21"}, res.PublicInputs)
+	}
+
+	// the last word of a legacy proof is the digest
+	tampered := []byte(strings.TrimSpace(string(legacyProof)) + "0")
+	code, res := post(handler, tampered)
+	assert.Equal(http.StatusUnprocessableEntity, code)
+	assert.False(res.Valid)
+	code, res = post(handler, []byte("not a proof"))
+	assert.Equal(http.StatusBadRequest, code)
+	assert.NotEmpty(res.Error)
+	code, _ = post(handler, bytes.Repeat([]byte("0"), MaxProofSize+1))
+	assert.Equal(http.StatusBadRequest, code)
+
+	// unsigned proofs are rejected when a prover is expected
+	code, res = post(New(vk, Config{ExpectedProvers: []string{"0xd75a98"}}).Handler(), jsonProof)
+	assert.Equal(http.StatusUnprocessableEntity, code)
+	assert.Contains(res.Error, "not signed")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(rec.Body.String(), `pico_verifications_total{outcome="valid"} 2`)
+	assert.Contains(rec.Body.String(), `pico_verifications_total{outcome="invalid"} 1`)
+	assert.Contains(rec.Body.String(), `pico_verifications_total{outcome="malformed"} 2`)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/verify", nil))
+	assert.Equal(http.StatusMethodNotAllowed, rec.Code)
+}
+
+// TestDependencies keeps the service free of the prover and the gnark frontend.
+func TestDependencies(t *testing.T) {
+	out, err := exec.Command("go", "list", "-deps", "-f", "{{if not .Standard}}{{.ImportPath}}{{end}}", ".", "./main").Output()
+	if err != nil {
+		t.Skipf("go list unavailable: %v", err)
+	}
+	for _, dep := range strings.Fields(string(out)) {
+		if strings.HasPrefix(dep, "github.com/brevis-network/pico/gnark/verify") {
+			continue
+		}
+		if strings.HasPrefix(dep, "github.com/consensys/gnark/") || strings.HasPrefix(dep, "github.com/ethereum/") || strings.HasPrefix(dep, "github.com/brevis-network/") {
+			t.Errorf("verify service depends on %s", dep)
+		}
+	}
+}
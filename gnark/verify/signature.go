@@ -0,0 +1,118 @@
+package verify
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/consensys/gnark-crypto/ecc/secp256k1/ecdsa"
+	"golang.org/x/crypto/sha3"
+	"math/big"
+	"strings"
+)
+
+// The schemes, signed message and ProofSignature mirror the prover signatures of utils.
+const (
+	SignatureEd25519   = "ed25519"
+	SignatureSecp256k1 = "secp256k1"
+)
+
+const signatureDomain = "pico-proof-v1"
+
+// ErrInvalidSignature is returned when the prover signature of a proof does not check.
+var ErrInvalidSignature = errors.New("invalid prover signature")
+
+// ProofSignature is the signature of a proof by the identity key of the prover that
+// produced it, 0x prefixed hex. Signer is the ed25519 public key, or the address of a
+// secp256k1 key; Signature is the ed25519 signature, or r || s || v with v 27 or 28,
+// as ecrecover takes it.
+type ProofSignature struct {
+	Scheme    string `json:"scheme"`
+	Signer    string `json:"signer"`
+	Signature string `json:"signature"`
+}
+
+// Words returns the proof as the verifyProof overload of the solidity verifier takes
+// it: its points, its commitment and proof of knowledge if it has one, then its public
+// inputs, as 32 byte big-endian words.
+func (p *Proof) Words() []byte {
+	var words []byte
+	appendPoint := func(coordinates ...interface{ Bytes() [32]byte }) {
+		for _, c := range coordinates {
+			b := c.Bytes()
+			words = append(words, b[:]...)
+		}
+	}
+	appendPoint(&p.Ar.X, &p.Ar.Y, &p.Bs.X.A1, &p.Bs.X.A0, &p.Bs.Y.A1, &p.Bs.Y.A0, &p.Krs.X, &p.Krs.Y)
+	if len(p.Commitments) > 0 {
+		appendPoint(&p.Commitments[0].X, &p.Commitments[0].Y, &p.CommitmentPok.X, &p.CommitmentPok.Y)
+	}
+	for i := range p.PublicInputs {
+		appendPoint(&p.PublicInputs[i])
+	}
+	return words
+}
+
+// SignatureDigest is the digest a prover signs for the proof of words, as returned by
+// Proof.Words: keccak256(abi.encodePacked("pico-proof-v1", words)).
+func SignatureDigest(words []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write([]byte(signatureDomain))
+	h.Write(words)
+	return h.Sum(nil)
+}
+
+// VerifySignature checks that sig signs proof, returning ErrInvalidSignature if it
+// does not.
+func VerifySignature(proof *Proof, sig *ProofSignature) error {
+	digest := SignatureDigest(proof.Words())
+	signature, err := hex.DecodeString(strings.TrimPrefix(sig.Signature, "0x"))
+	if err != nil {
+		return fmt.Errorf("%w: signature is not hex", ErrInvalidSignature)
+	}
+	switch sig.Scheme {
+	case SignatureEd25519:
+		publicKey, err := hex.DecodeString(strings.TrimPrefix(sig.Signer, "0x"))
+		if err != nil || len(publicKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("%w: invalid ed25519 signer %q", ErrInvalidSignature, sig.Signer)
+		}
+		if !ed25519.Verify(publicKey, digest, signature) {
+			return ErrInvalidSignature
+		}
+		return nil
+	case SignatureSecp256k1:
+		signer, err := RecoverSigner(digest, signature)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(signer, sig.Signer) {
+			return fmt.Errorf("%w: signed by %s, not %s", ErrInvalidSignature, signer, sig.Signer)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown scheme %q", ErrInvalidSignature, sig.Scheme)
+	}
+}
+
+// RecoverSigner returns the address of the secp256k1 key whose signature of digest,
+// r || s || v, is signature, as ecrecover does.
+func RecoverSigner(digest, signature []byte) (string, error) {
+	if len(signature) != 65 || (signature[64] != 27 && signature[64] != 28) {
+		return "", fmt.Errorf("%w: expected 65 bytes r || s || v with v 27 or 28", ErrInvalidSignature)
+	}
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:64])
+	var publicKey ecdsa.PublicKey
+	err := publicKey.RecoverFrom(digest, uint(signature[64]-27), r, s)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	return signerAddress(&publicKey), nil
+}
+
+// signerAddress is the 0x prefixed ethereum address of a secp256k1 public key.
+func signerAddress(publicKey *ecdsa.PublicKey) string {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(publicKey.Bytes())
+	return "0x" + hex.EncodeToString(h.Sum(nil)[12:])
+}
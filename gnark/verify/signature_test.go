@@ -0,0 +1,94 @@
+package verify
+
+import (
+	"encoding/json"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+	"golang.org/x/crypto/sha3"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifySignature(t *testing.T) {
+	assert := test.NewAssert(t)
+	keys := map[string]string{
+		SignatureEd25519:   "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60",
+		SignatureSecp256k1: "0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318",
+	}
+
+	for _, circuit := range []frontend.Circuit{&wrapperCircuit{}, &rangeCheckedCircuit{}} {
+		ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
+		assert.NoError(err)
+		pk, _, err := groth16.Setup(ccs)
+		assert.NoError(err)
+		assignment := &wrapperCircuit{VkeyHash: 3, CommittedValuesDigest: 21, X: 7}
+		var fullAssignment frontend.Circuit = assignment
+		if _, ok := circuit.(*rangeCheckedCircuit); ok {
+			fullAssignment = (*rangeCheckedCircuit)(assignment)
+		}
+		fullWitness, err := frontend.NewWitness(fullAssignment, ecc.BN254.ScalarField())
+		assert.NoError(err)
+		pubWitness, err := fullWitness.Public()
+		assert.NoError(err)
+		proof, err := groth16.Prove(ccs, pk, fullWitness, backend.WithProverHashToFieldFunction(sha3.NewLegacyKeccak256()))
+		assert.NoError(err)
+
+		// the words signed by the prover are the abi encoding of its on-chain proof
+		t.Setenv("PROVER_KEY_FILE", "")
+		onChain, err := utils.GetAggOnChainProof(proof, pubWitness)
+		assert.NoError(err)
+		data, err := utils.FormatProof(utils.ProofFormatJson, proof, pubWitness)
+		assert.NoError(err)
+		unsigned, err := ParseProof(data)
+		assert.NoError(err)
+		assert.Nil(unsigned.Signature)
+		assert.Equal(utils.SignatureDigest(onChain), SignatureDigest(unsigned.Words()))
+
+		for scheme, key := range keys {
+			keyPath := filepath.Join(t.TempDir(), "prover_key")
+			assert.NoError(os.WriteFile(keyPath, []byte(key+"\n"), 0600))
+			t.Setenv("PROVER_KEY_FILE", keyPath)
+			t.Setenv("PROVER_KEY_SCHEME", scheme)
+
+			data, err := utils.FormatProof(utils.ProofFormatJson, proof, pubWitness)
+			assert.NoError(err)
+			parsed, err := ParseProof(data)
+			assert.NoError(err)
+			assert.NotNil(parsed.Signature, scheme)
+			assert.Equal(scheme, parsed.Signature.Scheme)
+			assert.NoError(VerifySignature(parsed, parsed.Signature), scheme)
+
+			// the detached signature of a legacy proof file is the same
+			data, err = utils.FormatProofSignature(utils.ProofFormatLegacy, proof, pubWitness)
+			assert.NoError(err)
+			var detached ProofSignature
+			assert.NoError(json.Unmarshal(data, &detached))
+			assert.NoError(VerifySignature(parsed, &detached), scheme)
+
+			tampered := *parsed.Signature
+			tampered.Signer = otherSigner(t, scheme)
+			assert.ErrorIs(VerifySignature(parsed, &tampered), ErrInvalidSignature, scheme)
+			parsed.PublicInputs[1].SetUint64(22)
+			assert.ErrorIs(VerifySignature(parsed, parsed.Signature), ErrInvalidSignature, scheme)
+		}
+	}
+}
+
+// otherSigner returns the signer of a key of scheme other than the test key.
+func otherSigner(t *testing.T, scheme string) string {
+	other := map[string]string{
+		SignatureEd25519:   "4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb",
+		SignatureSecp256k1: "0xb71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291",
+	}
+	key, err := utils.ParseProverKey(scheme, []byte(other[scheme]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key.Signer()
+}
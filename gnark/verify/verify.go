@@ -0,0 +1,351 @@
+// Package verify checks Pico wrapper proofs with nothing but gnark-crypto: it reads
+// the vk file written by setup and the proof file written by prove, and runs the
+// Groth16 verifier of gnark over BN254. It does not import the prover, the gnark
+// frontend or go-ethereum, so services that only verify stay small.
+package verify
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fp"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr/pedersen"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr/poseidon2"
+	"golang.org/x/crypto/sha3"
+	"hash"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// ErrInvalidProof is returned when the pairing check of a well formed proof fails.
+var ErrInvalidProof = errors.New("invalid proof")
+
+// keyMagic and maxHeaderSize match the key file header of utils.
+var keyMagic = []byte("PICOKEY\x00")
+
+const maxHeaderSize = 1 << 20
+
+// VerifyingKey is a BN254 Groth16 vk as serialized by gnark.
+type VerifyingKey struct {
+	Alpha bn254.G1Affine
+	Beta  bn254.G2Affine
+	Gamma bn254.G2Affine
+	Delta bn254.G2Affine
+	K     []bn254.G1Affine
+	// PublicAndCommitmentCommitted lists, per commitment, the public inputs (1-based)
+	// hashed with it.
+	PublicAndCommitmentCommitted [][]uint64
+	CommitmentKeys               []pedersen.VerifyingKey
+}
+
+// NbPublicInputs is the number of public inputs of proofs of vk.
+func (vk *VerifyingKey) NbPublicInputs() int {
+	return len(vk.K) - len(vk.PublicAndCommitmentCommitted) - 1
+}
+
+// ReadVerifyingKey reads a vk file written by setup, with or without key header.
+func ReadVerifyingKey(r io.Reader) (*VerifyingKey, error) {
+	br := bufio.NewReader(r)
+	err := skipKeyHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	vk := &VerifyingKey{}
+	// the G1 beta and delta are only used by the prover
+	var betaG1, deltaG1 bn254.G1Affine
+	var nbCommitments uint32
+	dec := bn254.NewDecoder(br)
+	for i, v := range []any{&vk.Alpha, &betaG1, &vk.Beta, &vk.Gamma, &deltaG1, &vk.Delta, &vk.K, &vk.PublicAndCommitmentCommitted, &nbCommitments} {
+		err = dec.Decode(v)
+		if err != nil {
+			return nil, fmt.Errorf("fail to read vk field %d: %v", i, err)
+		}
+	}
+	vk.CommitmentKeys = make([]pedersen.VerifyingKey, nbCommitments)
+	for i := range vk.CommitmentKeys {
+		_, err = vk.CommitmentKeys[i].ReadFrom(br)
+		if err != nil {
+			return nil, fmt.Errorf("fail to read commitment key %d: %v", i, err)
+		}
+	}
+	if len(vk.K) <= len(vk.PublicAndCommitmentCommitted) {
+		return nil, fmt.Errorf("vk has %d public input points for %d commitments", len(vk.K), len(vk.PublicAndCommitmentCommitted))
+	}
+	return vk, nil
+}
+
+// skipKeyHeader consumes the key header of a vk file, if any, and checks it is a
+// BN254 vk.
+func skipKeyHeader(r *bufio.Reader) error {
+	prefix, err := r.Peek(len(keyMagic))
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if !bytes.Equal(prefix, keyMagic) {
+		return nil
+	}
+	_, err = r.Discard(len(keyMagic))
+	if err != nil {
+		return err
+	}
+	var length [4]byte
+	_, err = io.ReadFull(r, length[:])
+	if err != nil {
+		return fmt.Errorf("fail to read header: %v", err)
+	}
+	size := binary.BigEndian.Uint32(length[:])
+	if size > maxHeaderSize {
+		return fmt.Errorf("header of %d bytes exceeds %d bytes, file is corrupt", size, maxHeaderSize)
+	}
+	data := make([]byte, size)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return fmt.Errorf("fail to read header: %v", err)
+	}
+	var header struct {
+		Kind  string `json:"kind"`
+		Curve string `json:"curve"`
+	}
+	err = json.Unmarshal(data, &header)
+	if err != nil {
+		return fmt.Errorf("fail to parse header: %v", err)
+	}
+	if header.Kind != "vk" || header.Curve != ecc.BN254.String() {
+		return fmt.Errorf("file holds a %s %s, expected a bn254 vk", header.Curve, header.Kind)
+	}
+	return nil
+}
+
+// Proof is a BN254 Groth16 proof and its public inputs.
+type Proof struct {
+	Ar, Krs bn254.G1Affine
+	Bs      bn254.G2Affine
+	// Commitments and CommitmentPok are empty for circuits without commitment.
+	Commitments   []bn254.G1Affine
+	CommitmentPok bn254.G1Affine
+	PublicInputs  []fr.Element
+	// HashToField is the function the commitment is hashed to the field with:
+	// keccak256 if empty, sha256 or poseidon2. Legacy proofs do not record it.
+	HashToField string
+	// Signature is the signature of the prover of a json proof, nil if it is not signed.
+	Signature *ProofSignature
+}
+
+// ParseProof reads a proof file written by prove, in legacy (comma separated hex
+// words) or json format. The legacy format leaves out commitments, so proofs with a
+// commitment must be in json.
+func ParseProof(data []byte) (*Proof, error) {
+	text := strings.TrimSpace(string(data))
+	if strings.HasPrefix(text, "0x") && !strings.Contains(text, ",") {
+		return nil, fmt.Errorf("proofs in the gateway format are not read back, prove in the legacy or json format")
+	}
+	var words []string
+	hasCommitment := false
+	hashToField := ""
+	var signature *ProofSignature
+	if strings.HasPrefix(text, "{") {
+		var file struct {
+			Proof struct {
+				A             [2]string    `json:"a"`
+				B             [2][2]string `json:"b"`
+				C             [2]string    `json:"c"`
+				Commitment    [2]string    `json:"commitment"`
+				CommitmentPok [2]string    `json:"commitment_pok"`
+			} `json:"proof"`
+			PublicInputs []string        `json:"public_inputs"`
+			HashToField  string          `json:"hash_to_field"`
+			Signature    *ProofSignature `json:"signature"`
+		}
+		err := json.Unmarshal(data, &file)
+		if err != nil {
+			return nil, fmt.Errorf("fail to parse json proof: %v", err)
+		}
+		p := file.Proof
+		words = []string{p.A[0], p.A[1], p.B[0][0], p.B[0][1], p.B[1][0], p.B[1][1], p.C[0], p.C[1]}
+		if p.Commitment[0] != "" {
+			hasCommitment = true
+			words = append(words, p.Commitment[0], p.Commitment[1], p.CommitmentPok[0], p.CommitmentPok[1])
+		}
+		words = append(words, file.PublicInputs...)
+		hashToField = file.HashToField
+		signature = file.Signature
+	} else {
+		words = strings.Split(text, ",")
+	}
+
+	values := make([]*big.Int, len(words))
+	for i, word := range words {
+		v, ok := new(big.Int).SetString(strings.TrimPrefix(strings.TrimSpace(word), "0x"), 16)
+		if !ok {
+			return nil, fmt.Errorf("invalid proof word %d: %q", i, word)
+		}
+		values[i] = v
+	}
+	head := 8
+	if hasCommitment {
+		head = 12
+	}
+	if len(values) <= head {
+		return nil, fmt.Errorf("proof has %d words, expected more than %d", len(values), head)
+	}
+
+	for i, v := range values[:head] {
+		if v.Cmp(fp.Modulus()) >= 0 {
+			return nil, fmt.Errorf("proof word %d is not a coordinate", i)
+		}
+	}
+	// points are in the order of the solidity verifier, G2 coordinates imaginary part first
+	proof := &Proof{HashToField: hashToField, Signature: signature}
+	proof.Ar.X.SetBigInt(values[0])
+	proof.Ar.Y.SetBigInt(values[1])
+	proof.Bs.X.A1.SetBigInt(values[2])
+	proof.Bs.X.A0.SetBigInt(values[3])
+	proof.Bs.Y.A1.SetBigInt(values[4])
+	proof.Bs.Y.A0.SetBigInt(values[5])
+	proof.Krs.X.SetBigInt(values[6])
+	proof.Krs.Y.SetBigInt(values[7])
+	if hasCommitment {
+		var commitment bn254.G1Affine
+		commitment.X.SetBigInt(values[8])
+		commitment.Y.SetBigInt(values[9])
+		proof.Commitments = []bn254.G1Affine{commitment}
+		proof.CommitmentPok.X.SetBigInt(values[10])
+		proof.CommitmentPok.Y.SetBigInt(values[11])
+	}
+	for i, v := range values[head:] {
+		if v.Cmp(fr.Modulus()) >= 0 {
+			return nil, fmt.Errorf("public input %d is not in the field", i)
+		}
+		var e fr.Element
+		e.SetBigInt(v)
+		proof.PublicInputs = append(proof.PublicInputs, e)
+	}
+	return proof, nil
+}
+
+// Verify checks proof against vk as gnark's groth16.Verify does for the wrapper,
+// hashing commitments to the field with proof.HashToField.
+func Verify(vk *VerifyingKey, proof *Proof) error {
+	if len(proof.PublicInputs) != vk.NbPublicInputs() {
+		return fmt.Errorf("proof has %d public inputs, vk expects %d", len(proof.PublicInputs), vk.NbPublicInputs())
+	}
+	if len(proof.Commitments) != len(vk.PublicAndCommitmentCommitted) {
+		return fmt.Errorf("proof has %d commitments, vk expects %d", len(proof.Commitments), len(vk.PublicAndCommitmentCommitted))
+	}
+	points := append([]bn254.G1Affine{proof.Ar, proof.Krs, proof.CommitmentPok}, proof.Commitments...)
+	for _, p := range points {
+		if !p.IsInSubGroup() {
+			return fmt.Errorf("proof has points outside of the subgroup")
+		}
+	}
+	if !proof.Bs.IsInSubGroup() {
+		return fmt.Errorf("proof has points outside of the subgroup")
+	}
+
+	// the commitments extend the public inputs with their hashes
+	inputs := append([]fr.Element{}, proof.PublicInputs...)
+	var commitmentHashes []byte
+	for i, committed := range vk.PublicAndCommitmentCommitted {
+		h, err := newHashToField(proof.HashToField)
+		if err != nil {
+			return err
+		}
+		h.Write(proof.Commitments[i].Marshal())
+		for _, j := range committed {
+			if j == 0 || int(j) > len(proof.PublicInputs) {
+				return fmt.Errorf("vk commits to public input %d of %d", j, len(proof.PublicInputs))
+			}
+			b := proof.PublicInputs[j-1].Bytes()
+			h.Write(b[:])
+		}
+		var e fr.Element
+		e.SetBytes(h.Sum(nil))
+		inputs = append(inputs, e)
+		b := e.Bytes()
+		commitmentHashes = append(commitmentHashes, b[:]...)
+	}
+	if len(vk.CommitmentKeys) > 0 {
+		challenge, err := fr.Hash(commitmentHashes, []byte("G16-BSB22"), 1)
+		if err != nil {
+			return err
+		}
+		err = pedersen.BatchVerifyMultiVk(vk.CommitmentKeys, proof.Commitments, []bn254.G1Affine{proof.CommitmentPok}, challenge[0])
+		if err != nil {
+			return fmt.Errorf("%w: commitment: %v", ErrInvalidProof, err)
+		}
+	}
+
+	// e(A, B) == e(α, β) · e(K_0 + Σ x_i·K_(i+1) + Σ commitments, γ) · e(C, δ)
+	var kSum bn254.G1Jac
+	_, err := kSum.MultiExp(vk.K[1:], inputs, ecc.MultiExpConfig{})
+	if err != nil {
+		return err
+	}
+	kSum.AddMixed(&vk.K[0])
+	for i := range proof.Commitments {
+		kSum.AddMixed(&proof.Commitments[i])
+	}
+	var k, negA bn254.G1Affine
+	k.FromJacobian(&kSum)
+	negA.Neg(&proof.Ar)
+	ok, err := bn254.PairingCheck(
+		[]bn254.G1Affine{negA, vk.Alpha, k, proof.Krs},
+		[]bn254.G2Affine{proof.Bs, vk.Beta, vk.Gamma, vk.Delta},
+	)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// newHashToField mirrors utils.NewHashToField.
+func newHashToField(name string) (hash.Hash, error) {
+	switch name {
+	case "", "keccak256":
+		return sha3.NewLegacyKeccak256(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "poseidon2":
+		return &poseidon2Hash{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported hash to field: %s", name)
+	}
+}
+
+// poseidon2Hash is the poseidon2 hash to field of utils, absorbing 16 byte limbs.
+type poseidon2Hash struct {
+	data []byte
+}
+
+func (h *poseidon2Hash) Write(p []byte) (int, error) {
+	h.data = append(h.data, p...)
+	return len(p), nil
+}
+
+func (h *poseidon2Hash) Sum(b []byte) []byte {
+	md := poseidon2.NewMerkleDamgardHasher()
+	for data := h.data; len(data) > 0; {
+		n := min(len(data), 16)
+		var word [fr.Bytes]byte
+		copy(word[fr.Bytes-n:], data[:n])
+		md.Write(word[:])
+		data = data[n:]
+	}
+	return md.Sum(b)
+}
+
+func (h *poseidon2Hash) Reset()         { h.data = h.data[:0] }
+func (h *poseidon2Hash) Size() int      { return fr.Bytes }
+func (h *poseidon2Hash) BlockSize() int { return 16 }
@@ -0,0 +1,154 @@
+package verify
+
+import (
+	"bytes"
+	"github.com/brevis-network/pico/gnark/utils"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/std/rangecheck"
+	"github.com/consensys/gnark/test"
+	"golang.org/x/crypto/sha3"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type wrapperCircuit struct {
+	VkeyHash              frontend.Variable `gnark:",public"`
+	CommittedValuesDigest frontend.Variable `gnark:",public"`
+	X                     frontend.Variable
+}
+
+func (c *wrapperCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(c.CommittedValuesDigest, api.Mul(c.VkeyHash, c.X))
+	return nil
+}
+
+// rangeCheckedCircuit uses the lookup range checker, so its proofs carry a commitment.
+type rangeCheckedCircuit wrapperCircuit
+
+func (c *rangeCheckedCircuit) Define(api frontend.API) error {
+	rangecheck.New(api).Check(c.X, 8)
+	api.AssertIsEqual(c.CommittedValuesDigest, api.Mul(c.VkeyHash, c.X))
+	return nil
+}
+
+func TestVerify(t *testing.T) {
+	for _, circuit := range []frontend.Circuit{&wrapperCircuit{}, &rangeCheckedCircuit{}} {
+		assert := test.NewAssert(t)
+
+		ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
+		assert.NoError(err)
+		pk, gnarkVk, err := groth16.Setup(ccs)
+		assert.NoError(err)
+		vkPath := filepath.Join(t.TempDir(), "vm_vk")
+		assert.NoError(utils.WriteVerifyingKey(vkPath, gnarkVk))
+
+		assignment := &wrapperCircuit{VkeyHash: 3, CommittedValuesDigest: 21, X: 7}
+		var fullAssignment frontend.Circuit = assignment
+		if _, ok := circuit.(*rangeCheckedCircuit); ok {
+			fullAssignment = (*rangeCheckedCircuit)(assignment)
+		}
+		fullWitness, err := frontend.NewWitness(fullAssignment, ecc.BN254.ScalarField())
+		assert.NoError(err)
+		pubWitness, err := fullWitness.Public()
+		assert.NoError(err)
+		proof, err := groth16.Prove(ccs, pk, fullWitness, backend.WithProverHashToFieldFunction(sha3.NewLegacyKeccak256()))
+		assert.NoError(err)
+
+		f, err := os.Open(vkPath)
+		assert.NoError(err)
+		vk, err := ReadVerifyingKey(f)
+		f.Close()
+		assert.NoError(err)
+		assert.Equal(2, vk.NbPublicInputs())
+
+		data, err := utils.FormatProof(utils.ProofFormatJson, proof, pubWitness)
+		assert.NoError(err)
+		parsed, err := ParseProof(data)
+		assert.NoError(err)
+		assert.NoError(Verify(vk, parsed))
+
+		parsed.PublicInputs[1].SetUint64(22)
+		assert.ErrorIs(Verify(vk, parsed), ErrInvalidProof)
+
+		// the legacy format has no commitment, so only proofs without one verify from it
+		legacy, err := utils.FormatProof(utils.ProofFormatLegacy, proof, pubWitness)
+		assert.NoError(err)
+		parsed, err = ParseProof(legacy)
+		assert.NoError(err)
+		if len(vk.CommitmentKeys) == 0 {
+			assert.NoError(Verify(vk, parsed))
+		} else {
+			assert.Error(Verify(vk, parsed))
+		}
+
+		// keys without header, as written by older setups
+		var raw bytes.Buffer
+		_, err = gnarkVk.WriteTo(&raw)
+		assert.NoError(err)
+		_, err = ReadVerifyingKey(&raw)
+		assert.NoError(err)
+	}
+}
+
+func TestVerifyHashToField(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &rangeCheckedCircuit{})
+	assert.NoError(err)
+	pk, gnarkVk, err := groth16.Setup(ccs)
+	assert.NoError(err)
+	var raw bytes.Buffer
+	_, err = gnarkVk.WriteTo(&raw)
+	assert.NoError(err)
+	vk, err := ReadVerifyingKey(&raw)
+	assert.NoError(err)
+
+	fullWitness, err := frontend.NewWitness(&rangeCheckedCircuit{VkeyHash: 3, CommittedValuesDigest: 21, X: 7}, ecc.BN254.ScalarField())
+	assert.NoError(err)
+	pubWitness, err := fullWitness.Public()
+	assert.NoError(err)
+	for _, name := range []string{utils.HashToFieldKeccak256, utils.HashToFieldSha256, utils.HashToFieldPoseidon2} {
+		t.Setenv("HASH_TO_FIELD", name)
+		hashToField, err := utils.HashToFieldFromEnv()
+		assert.NoError(err)
+		proof, err := groth16.Prove(ccs, pk, fullWitness, backend.WithProverHashToFieldFunction(hashToField))
+		assert.NoError(err)
+
+		data, err := utils.FormatProof(utils.ProofFormatJson, proof, pubWitness)
+		assert.NoError(err)
+		parsed, err := ParseProof(data)
+		assert.NoError(err)
+		assert.Equal(name, parsed.HashToField)
+		assert.NoError(Verify(vk, parsed), name)
+
+		// a proof checked with another hash does not verify
+		parsed.HashToField = utils.HashToFieldSha256
+		if name == utils.HashToFieldSha256 {
+			parsed.HashToField = utils.HashToFieldKeccak256
+		}
+		assert.Error(Verify(vk, parsed), name)
+	}
+}
+
+// TestDependencies keeps the package free of the prover and the gnark frontend.
+func TestDependencies(t *testing.T) {
+	out, err := exec.Command("go", "list", "-deps", "-f", "{{if not .Standard}}{{.ImportPath}}{{end}}", ".").Output()
+	if err != nil {
+		t.Skipf("go list unavailable: %v", err)
+	}
+	for _, dep := range strings.Fields(string(out)) {
+		if dep == "github.com/brevis-network/pico/gnark/verify" {
+			continue
+		}
+		if strings.HasPrefix(dep, "github.com/consensys/gnark/") || strings.HasPrefix(dep, "github.com/ethereum/") || strings.HasPrefix(dep, "github.com/brevis-network/") {
+			t.Errorf("verify depends on %s", dep)
+		}
+	}
+}
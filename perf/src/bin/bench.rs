@@ -180,8 +180,12 @@ fn bench_bb(bench_program: &BenchProgram) -> Result<PerformanceReport> {
                 BabyBearBn254,
                 BabyBearBn254Poseidon2,
             >::build(&onchain_stdin);
-            let gnark_witness =
-                build_gnark_config_with_str(constraints, witness, PathBuf::from("./"));
+            let gnark_witness = build_gnark_config_with_str(
+                constraints,
+                witness,
+                proof.pv_stream.as_deref(),
+                PathBuf::from("./"),
+            );
             let gnark_proof_data = send_gnark_prove_task(gnark_witness);
             info!(
                 "gnark prove success with proof data {}",
@@ -303,8 +307,12 @@ fn bench_kb(bench_program: &BenchProgram) -> Result<PerformanceReport> {
                 KoalaBearBn254,
                 KoalaBearBn254Poseidon2,
             >::build(&onchain_stdin);
-            let gnark_witness =
-                build_gnark_config_with_str(constraints, witness, PathBuf::from("./"));
+            let gnark_witness = build_gnark_config_with_str(
+                constraints,
+                witness,
+                proof.pv_stream.as_deref(),
+                PathBuf::from("./"),
+            );
             let gnark_proof_data = send_gnark_prove_task(gnark_witness);
             info!(
                 "gnark prove success with proof data {}",
@@ -216,7 +216,12 @@ macro_rules! create_sdk_prove_client {
                 let (constraints, witness) =
                     OnchainVerifierCircuit::<$fc, $bn254_sc>::build(&onchain_stdin);
                 save_embed_proof_data(&riscv_proof, &embed_proof, &outdir)?;
-                build_gnark_config(constraints, witness, &outdir);
+                build_gnark_config(
+                    constraints,
+                    witness,
+                    riscv_proof.pv_stream.as_deref(),
+                    &outdir,
+                );
                 Ok(())
             }
 
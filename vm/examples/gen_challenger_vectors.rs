@@ -0,0 +1,118 @@
+//! Generates the test vectors of the gnark challenger package from plonky3's
+//! DuplexChallenger, the challenger of the KoalaBear stark config:
+//!
+//! cargo run --release --example gen_challenger_vectors > gnark/challenger/testdata/challenger_vectors.json
+//!
+//! Each vector is a transcript: observations and the samples the challenger returns
+//! after them.
+use p3_challenger::{CanObserve, CanSample, CanSampleBits, FieldChallenger, GrindingChallenger};
+use p3_field::{FieldAlgebra, FieldExtensionAlgebra, PrimeField32};
+use p3_koala_bear::KoalaBear;
+use pico_vm::{
+    configs::stark_config::kb_poseidon2::{SC_Challenge, SC_Challenger},
+    primitives::Poseidon2Init,
+};
+use rand::{rngs::StdRng, Rng, SeedableRng};
+use serde::Serialize;
+
+#[derive(Serialize)]
+struct TranscriptOp {
+    op: &'static str,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    bits: Option<usize>,
+    values: Vec<u32>,
+}
+
+/// The proof of work bits of the check_witness ops, low enough to grind quickly.
+const WITNESS_BITS: usize = 8;
+
+fn transcript(rng: &mut StdRng, steps: &[&'static str]) -> Vec<TranscriptOp> {
+    let mut challenger = SC_Challenger::new(KoalaBear::init());
+    let mut ops = vec![];
+    for &op in steps {
+        let (bits, values) = match op {
+            "observe" => {
+                let values: Vec<u32> = (0..rng.gen_range(1..12))
+                    .map(|_| rng.gen_range(0..KoalaBear::ORDER_U32))
+                    .collect();
+                for &v in &values {
+                    challenger.observe(KoalaBear::from_canonical_u32(v));
+                }
+                (None, values)
+            }
+            "sample" => {
+                let values = (0..rng.gen_range(1..10))
+                    .map(|_| {
+                        let v: KoalaBear = challenger.sample();
+                        v.as_canonical_u32()
+                    })
+                    .collect();
+                (None, values)
+            }
+            "sample_ext" => {
+                let v: SC_Challenge = challenger.sample_ext_element();
+                let values = v
+                    .as_base_slice()
+                    .iter()
+                    .map(|c| c.as_canonical_u32())
+                    .collect();
+                (None, values)
+            }
+            "sample_bits" => {
+                let bits = rng.gen_range(1..31);
+                (Some(bits), vec![challenger.sample_bits(bits) as u32])
+            }
+            "check_witness" => {
+                let witness = challenger.grind(WITNESS_BITS);
+                (Some(WITNESS_BITS), vec![witness.as_canonical_u32()])
+            }
+            _ => panic!("unknown transcript op: {op}"),
+        };
+        ops.push(TranscriptOp { op, bits, values });
+    }
+    ops
+}
+
+fn main() {
+    let mut rng = StdRng::seed_from_u64(348);
+    let vectors = [
+        transcript(&mut rng, &["sample"]),
+        transcript(
+            &mut rng,
+            &[
+                "observe",
+                "sample",
+                "observe",
+                "observe",
+                "sample_ext",
+                "sample_bits",
+            ],
+        ),
+        transcript(
+            &mut rng,
+            &[
+                "observe",
+                "sample_ext",
+                "check_witness",
+                "sample_bits",
+                "observe",
+                "sample",
+                "sample",
+            ],
+        ),
+        transcript(
+            &mut rng,
+            &[
+                "observe",
+                "observe",
+                "observe",
+                "sample",
+                "sample",
+                "sample_ext",
+                "check_witness",
+                "sample_bits",
+            ],
+        ),
+    ];
+    println!("{}", serde_json::to_string_pretty(&vectors).unwrap());
+}
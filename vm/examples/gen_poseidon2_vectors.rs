@@ -0,0 +1,56 @@
+//! Records test vectors of the gnark poseidon2 package from the width 16 Poseidon2
+//! permutations of the prover, KoalaBear (kb) or BabyBear (bb):
+//!
+//! cargo run --release --example gen_poseidon2_vectors -- kb > gnark/poseidon2/testdata/koalabear_vectors.json
+//! cargo run --release --example gen_poseidon2_vectors -- bb > gnark/poseidon2/testdata/babybear_vectors.json
+use p3_baby_bear::BabyBear;
+use p3_field::{FieldAlgebra, PrimeField32};
+use p3_koala_bear::KoalaBear;
+use p3_symmetric::Permutation;
+use pico_vm::primitives::{consts::PERMUTATION_WIDTH, Poseidon2Init};
+use rand::{rngs::StdRng, Rng, SeedableRng};
+use serde::Serialize;
+
+#[derive(Serialize)]
+struct Vector {
+    input: [u32; PERMUTATION_WIDTH],
+    output: [u32; PERMUTATION_WIDTH],
+}
+
+/// The all-zero state, the state 0..16, the all p-1 state and random states.
+fn vectors<F>() -> Vec<Vector>
+where
+    F: PrimeField32 + Poseidon2Init,
+    F::Poseidon2: Permutation<[F; PERMUTATION_WIDTH]>,
+{
+    let perm = F::init();
+    let mut rng = StdRng::seed_from_u64(317);
+    let mut inputs = vec![
+        [0; PERMUTATION_WIDTH],
+        core::array::from_fn(|i| i as u32),
+        [F::ORDER_U32 - 1; PERMUTATION_WIDTH],
+    ];
+    for _ in 0..2 {
+        inputs.push(core::array::from_fn(|_| rng.gen_range(0..F::ORDER_U32)));
+    }
+    inputs
+        .into_iter()
+        .map(|input| {
+            let output = perm.permute(input.map(F::from_canonical_u32));
+            Vector {
+                input,
+                output: output.map(|v| v.as_canonical_u32()),
+            }
+        })
+        .collect()
+}
+
+fn main() {
+    let field = std::env::args().nth(1).unwrap_or_default();
+    let vectors = match field.as_str() {
+        "kb" => vectors::<KoalaBear>(),
+        "bb" => vectors::<BabyBear>(),
+        _ => panic!("usage: gen_poseidon2_vectors kb|bb"),
+    };
+    println!("{}", serde_json::to_string_pretty(&vectors).unwrap());
+}
@@ -466,7 +466,12 @@ macro_rules! run {
             let (constraints, witness) =
                 OnchainVerifierCircuit::<$embed_cc, $embed_sc>::build(&onchain_stdin);
 
-            build_gnark_config(constraints, witness, PathBuf::from("./"));
+            build_gnark_config(
+                constraints,
+                witness,
+                Some(pv_stream.as_slice()),
+                PathBuf::from("./"),
+            );
             info!("Finished exporting gnark data");
 
             stats.embed = (embed_time, embed_proof_size);
@@ -3,6 +3,10 @@ use p3_field::{FieldAlgebra, FieldExtensionAlgebra, PrimeField};
 use serde::{Deserialize, Serialize};
 use std::{fs::File, io::Write, marker::PhantomData};
 
+/// The format version of [GnarkWitness], `WitnessVersion` on the Go side. Bump both when the
+/// gnark circuit changes shape.
+pub const GNARK_WITNESS_VERSION: u32 = 1;
+
 /// A witness that can be used to initialize values for witness generation inside Gnark.
 #[derive(Debug, Clone, Default, Serialize, Deserialize)]
 pub struct GnarkWitness<EmbedFC> {
@@ -11,6 +15,12 @@ pub struct GnarkWitness<EmbedFC> {
     pub exts: Vec<Vec<String>>,
     pub vkey_hash: String,
     pub committed_values_digest: String,
+    #[serde(default)]
+    pub version: u32,
+    /// The 0x prefixed hex public values the digest commits to, also written to `pv_file`.
+    /// Empty if unknown, then the gnark side falls back to the `pv_file` next to the witness.
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub public_values: String,
     pub _config: PhantomData<EmbedFC>,
 }
 
@@ -46,10 +56,18 @@ impl<EmbedFC: FieldGenericConfig> GnarkWitness<EmbedFC> {
                 .committed_values_digest
                 .as_canonical_biguint()
                 .to_string(),
+            version: GNARK_WITNESS_VERSION,
+            public_values: String::new(),
             _config: PhantomData,
         }
     }
 
+    /// Sets the public values the committed values digest of the witness commits to.
+    pub fn with_public_values(mut self, public_values: &[u8]) -> Self {
+        self.public_values = format!("0x{}", hex::encode(public_values));
+        self
+    }
+
     /// Saves the witness to a given path.
     #[allow(unused)]
     pub fn save(&self, path: &str) {
@@ -58,3 +76,69 @@ impl<EmbedFC: FieldGenericConfig> GnarkWitness<EmbedFC> {
         file.write_all(serialized.as_bytes()).unwrap();
     }
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::{
+        compiler::recursion::circuit::utils::field_bytes_to_bn254,
+        configs::field_config::KoalaBearBn254,
+    };
+    use p3_bn254_fr::Bn254Fr;
+    use p3_koala_bear::KoalaBear;
+
+    /// The sha256 of [public_values].
+    const PUBLIC_VALUES_SHA256: [u8; 32] = [
+        28, 181, 41, 63, 130, 0, 62, 151, 190, 54, 119, 128, 241, 168, 155, 192, 106, 191, 163,
+        206, 194, 132, 197, 68, 122, 56, 159, 184, 7, 219, 86, 140,
+    ];
+
+    /// ABI encoded public values: the block number 1000 and true.
+    fn public_values() -> Vec<u8> {
+        let mut public_values = vec![0u8; 64];
+        public_values[30..32].copy_from_slice(&1000u16.to_be_bytes());
+        public_values[63] = 1;
+        public_values
+    }
+
+    /// A witness whose first var is committed as the vkey hash and second as the digest.
+    fn witness() -> Witness<KoalaBearBn254> {
+        let vkey_hash = Bn254Fr::from_canonical_u64(0x1234_5678_9abc_def0);
+        let digest = field_bytes_to_bn254(&PUBLIC_VALUES_SHA256.map(KoalaBear::from_canonical_u8));
+        Witness {
+            vars: vec![vkey_hash, digest],
+            felts: vec![],
+            exts: vec![],
+            vkey_hash,
+            committed_values_digest: digest,
+        }
+    }
+
+    /// The gnark wrapper tests read these witnesses as the prover writes them: with their
+    /// public values, and without them next to a pv_file as before the field was added.
+    #[test]
+    fn test_gnark_testdata() {
+        let witness_json = serde_json::to_string(
+            &GnarkWitness::new(witness()).with_public_values(&public_values()),
+        )
+        .unwrap();
+        assert_eq!(
+            witness_json,
+            include_str!(
+                "../../../../../../gnark/utils/testdata/rust_witness/groth16_witness.json"
+            )
+        );
+
+        let witness_json = serde_json::to_string(&GnarkWitness::new(witness())).unwrap();
+        assert_eq!(
+            witness_json,
+            include_str!(
+                "../../../../../../gnark/utils/testdata/rust_witness_pv_file/groth16_witness.json"
+            )
+        );
+        assert_eq!(
+            hex::encode(public_values()),
+            include_str!("../../../../../../gnark/utils/testdata/rust_witness_pv_file/pv_file")
+        );
+    }
+}
@@ -58,6 +58,7 @@ pub fn save_embed_proof_data<SC: StarkGenericConfig, Bn254SC: StarkGenericConfig
 pub fn build_gnark_config<EmbedFC: FieldGenericConfig>(
     constraints: Vec<Constraint>,
     witness: Witness<EmbedFC>,
+    public_values: Option<&[u8]>,
     build_dir: impl AsRef<Path>,
 ) {
     let build_dir = build_dir.as_ref().to_path_buf();
@@ -70,7 +71,10 @@ pub fn build_gnark_config<EmbedFC: FieldGenericConfig>(
 
     // Write witness.
     let witness_path = build_dir.join(GROTH16_JSON_FILE);
-    let gnark_witness = GnarkWitness::new(witness);
+    let mut gnark_witness = GnarkWitness::new(witness);
+    if let Some(public_values) = public_values {
+        gnark_witness = gnark_witness.with_public_values(public_values);
+    }
     let mut file = File::create(witness_path).unwrap();
     let serialized = serde_json::to_string(&gnark_witness).unwrap();
     file.write_all(serialized.as_bytes()).unwrap();
@@ -80,6 +84,7 @@ pub fn build_gnark_config<EmbedFC: FieldGenericConfig>(
 pub fn build_gnark_config_with_str<EmbedFC: FieldGenericConfig>(
     constraints: Vec<Constraint>,
     witness: Witness<EmbedFC>,
+    public_values: Option<&[u8]>,
     build_dir: impl AsRef<Path>,
 ) -> String {
     let build_dir = build_dir.as_ref().to_path_buf();
@@ -92,7 +97,10 @@ pub fn build_gnark_config_with_str<EmbedFC: FieldGenericConfig>(
 
     // Write witness.
     let witness_path = build_dir.join(GROTH16_JSON_FILE);
-    let gnark_witness = GnarkWitness::new(witness);
+    let mut gnark_witness = GnarkWitness::new(witness);
+    if let Some(public_values) = public_values {
+        gnark_witness = gnark_witness.with_public_values(public_values);
+    }
     let mut witness_file = File::create(witness_path).unwrap();
     let witness_json = serde_json::to_string(&gnark_witness).unwrap();
     witness_file.write_all(witness_json.as_bytes()).unwrap();